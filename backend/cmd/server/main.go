@@ -49,6 +49,13 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	// redisConnectAttempts 必须连接Redis时的最大连接次数
+	redisConnectAttempts = 5
+	// redisConnectRetryDelay 连接Redis失败后的重试间隔
+	redisConnectRetryDelay = 3 * time.Second
+)
+
 // @securityDefinitions.apikey Bearer
 // @in header
 // @name Authorization
@@ -107,7 +114,18 @@ func main() {
 		MinIdleConns: cfg.Redis.MinIdleConns,
 	}
 
-	if err := cache.Connect(redisConfig); err != nil {
+	// WebSocket 背板配置为 redis 时多实例部署依赖 Redis，连接失败时重试，仍失败则拒绝启动
+	redisRequired := cfg.WebSocket.Backplane == "redis"
+	err = cache.Connect(redisConfig)
+	for attempt := 1; err != nil && redisRequired && attempt < redisConnectAttempts; attempt++ {
+		logger.Warn("连接Redis失败，稍后重试", logger.Int("attempt", attempt), logger.String("error", err.Error()))
+		time.Sleep(redisConnectRetryDelay)
+		err = cache.Connect(redisConfig)
+	}
+	if err != nil {
+		if redisRequired {
+			logger.Fatal("连接Redis失败，WebSocket 背板配置为 redis 时必须连接Redis", logger.String("error", err.Error()))
+		}
 		logger.Warn("连接Redis失败，将使用内存缓存", logger.String("error", err.Error()))
 	} else {
 		defer cache.Close()
@@ -117,7 +135,10 @@ func main() {
 	// 初始化依赖注入容器
 	var the_container *container.Container
 	if db != nil {
-		the_container = container.NewContainer(db, cfg)
+		the_container, err = container.NewContainer(db, cfg)
+		if err != nil {
+			logger.Fatal("初始化依赖注入容器失败", logger.String("error", err.Error()))
+		}
 		logger.Info("依赖注入容器初始化成功")
 	} else {
 		logger.Warn("数据库未连接，部分功能不可用")
@@ -143,10 +164,10 @@ func main() {
 
 	// 设置路由
 	if the_container != nil {
-		routes.SetupRoutes(engine, the_container.AuthHandler, the_container.UserHandler, the_container.MentorHandler, the_container.CourseHandler, the_container.AppointmentHandler, the_container.CircleHandler, the_container.PostHandler, the_container.CommentHandler, the_container.ReviewHandler, the_container.NotificationHandler, the_container.LearningHandler, the_container.StudentHandler, the_container.IncomeHandler, the_container.PaymentHandler, the_container.UploadHandler, the_container.SearchHandler, the_container.StatsHandler, the_container.ChatHandler, the_container.WebSocketHandler)
+		routes.SetupRoutes(engine, cfg.JWT.Secret, the_container.AuthService, the_container.PermissionChecker, the_container.AuthHandler, the_container.UserHandler, the_container.MentorHandler, the_container.CourseHandler, the_container.AppointmentHandler, the_container.CircleHandler, the_container.PostHandler, the_container.CommentHandler, the_container.ReviewHandler, the_container.NotificationHandler, the_container.LearningHandler, the_container.StudentHandler, the_container.IncomeHandler, the_container.PaymentHandler, the_container.UploadHandler, the_container.SearchHandler, the_container.StatsHandler, the_container.ChatHandler, the_container.WebSocketHandler, the_container.CalendarHandler, the_container.MeetingHandler)
 	} else {
 		// 如果数据库未连接，使用默认路由
-		routes.SetupRoutes(engine, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+		routes.SetupRoutes(engine, cfg.JWT.Secret, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	}
 
 	// 添加Swagger文档路由
@@ -179,6 +200,11 @@ func main() {
 		}
 	}()
 
+	// 启动定时任务
+	if the_container != nil {
+		the_container.Scheduler.Start()
+	}
+
 	// 等待中断信号
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -194,5 +220,12 @@ func main() {
 		logger.Error("服务器关闭失败", logger.String("error", err.Error()))
 	}
 
+	// 等待正在执行的定时任务结束
+	if the_container != nil {
+		if err := the_container.Scheduler.Stop(ctx); err != nil {
+			logger.Error("定时任务停止超时", logger.String("error", err.Error()))
+		}
+	}
+
 	logger.Info("服务器已关闭")
 }
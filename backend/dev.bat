@@ -3,6 +3,21 @@ echo Starting Master Guide Backend for local development...
 
 REM 设置环境变量
 set CONFIG_PATH=./configs/config.local.yaml
+if "%WITHDRAWAL_BANK_ACCOUNT_KEY%"=="" (
+    echo Please set WITHDRAWAL_BANK_ACCOUNT_KEY ^(base64 encoded 32 bytes, e.g. openssl rand -base64 32^)
+    pause
+    exit /b 1
+)
+if "%INCOME_REPORT_SIGNING_SECRET%"=="" (
+    echo Please set INCOME_REPORT_SIGNING_SECRET ^(random string, e.g. openssl rand -hex 32^)
+    pause
+    exit /b 1
+)
+if "%MEETING_SELFHOSTED_SECRET%"=="" (
+    echo Please set MEETING_SELFHOSTED_SECRET ^(random string, e.g. openssl rand -hex 32^)
+    pause
+    exit /b 1
+)
 
 REM 启动数据库和Redis（如果Docker可用）
 docker --version >nul 2>&1
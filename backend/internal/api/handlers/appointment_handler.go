@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
@@ -13,18 +14,20 @@ import (
 // AppointmentHandler 预约处理器
 type AppointmentHandler struct {
 	appointmentService service.AppointmentService
+	packageService     service.AppointmentPackageService
 }
 
 // NewAppointmentHandler 创建预约处理器
-func NewAppointmentHandler(appointmentService service.AppointmentService) *AppointmentHandler {
+func NewAppointmentHandler(appointmentService service.AppointmentService, packageService service.AppointmentPackageService) *AppointmentHandler {
 	return &AppointmentHandler{
 		appointmentService: appointmentService,
+		packageService:     packageService,
 	}
 }
 
 // CreateAppointment 创建预约
 // @Summary 创建预约
-// @Description 学生创建预约大师
+// @Description 学生创建预约大师，预约时间需在大师可预约时段内且不与已有预约冲突；付费预约返回支付信息，支付完成后自动确认；使用已购买套餐时扣减一次套餐次数并直接确认
 // @Tags 预约管理
 // @Accept json
 // @Produce json
@@ -32,6 +35,10 @@ func NewAppointmentHandler(appointmentService service.AppointmentService) *Appoi
 // @Success 200 {object} model.Response{data=model.CreateAppointmentResponse}
 // @Failure 400 {object} model.ErrorResponse
 // @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Failure 503 {object} model.ErrorResponse
 // @Router /appointments [post]
 func (h *AppointmentHandler) CreateAppointment(c *gin.Context) {
 	var req model.CreateAppointmentRequest
@@ -44,13 +51,22 @@ func (h *AppointmentHandler) CreateAppointment(c *gin.Context) {
 		return
 	}
 
-	// 从JWT中获取学生ID，这里暂时使用模拟值
-	studentID := "USER_00000000001" // TODO: 从JWT中获取
+	// 从JWT中获取学生ID
+	studentID := c.GetString("user_id")
+	if studentID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
 
-	response, err := h.appointmentService.CreateAppointment(c.Request.Context(), studentID, &req)
+	response, err := h.appointmentService.CreateAppointment(c.Request.Context(), studentID, &req, clientInfo(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Code:      500,
+		statusCode := bookingErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
 			Message:   err.Error(),
 			Timestamp: time.Now().Format(time.RFC3339),
 		})
@@ -65,6 +81,60 @@ func (h *AppointmentHandler) CreateAppointment(c *gin.Context) {
 	})
 }
 
+// CreateRecurringAppointment 创建重复预约
+// @Summary 创建重复预约
+// @Description 使用已购买套餐按每周重复规则批量创建预约，每次预约扣减一次套餐次数；任一预约不可预约或冲突时全部不创建
+// @Tags 预约管理
+// @Accept json
+// @Produce json
+// @Param appointment body model.CreateRecurringAppointmentRequest true "重复预约信息"
+// @Success 200 {object} model.Response{data=model.CreateRecurringAppointmentResponse}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /appointments/recurring [post]
+func (h *AppointmentHandler) CreateRecurringAppointment(c *gin.Context) {
+	var req model.CreateRecurringAppointmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "请求参数错误",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	studentID := c.GetString("user_id")
+	if studentID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	response, err := h.appointmentService.CreateRecurringAppointment(c.Request.Context(), studentID, &req)
+	if err != nil {
+		statusCode := bookingErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "重复预约创建成功",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
 // GetAppointments 获取预约列表
 // @Summary 获取预约列表
 // @Description 获取预约列表，支持分页和筛选
@@ -97,8 +167,16 @@ func (h *AppointmentHandler) GetAppointments(c *gin.Context) {
 		req.PageSize = 20
 	}
 
-	// 从JWT中获取用户ID，这里暂时使用模拟值
-	userID := "USER_00000000001" // TODO: 从JWT中获取
+	// 从JWT中获取用户ID
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
 
 	response, err := h.appointmentService.GetAppointments(c.Request.Context(), userID, &req)
 	if err != nil {
@@ -164,7 +242,7 @@ func (h *AppointmentHandler) GetAppointmentDetail(c *gin.Context) {
 
 // UpdateAppointmentStatus 更新预约状态
 // @Summary 更新预约状态
-// @Description 更新预约状态（确认、完成等）
+// @Description 按状态流转规则更新预约状态：大师确认/拒绝待确认预约、开始和完成预约，双方可标记未出席；拒绝、未出席和大师取消需填写原因
 // @Tags 预约管理
 // @Accept json
 // @Produce json
@@ -172,7 +250,9 @@ func (h *AppointmentHandler) GetAppointmentDetail(c *gin.Context) {
 // @Param status body model.UpdateAppointmentStatusRequest true "状态信息"
 // @Success 200 {object} model.Response{data=model.UpdateAppointmentStatusResponse}
 // @Failure 400 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
 // @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
 // @Router /appointments/{appointment_id}/status [put]
 func (h *AppointmentHandler) UpdateAppointmentStatus(c *gin.Context) {
 	appointmentID := c.Param("appointment_id")
@@ -195,10 +275,21 @@ func (h *AppointmentHandler) UpdateAppointmentStatus(c *gin.Context) {
 		return
 	}
 
-	response, err := h.appointmentService.UpdateAppointmentStatus(c.Request.Context(), appointmentID, &req)
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	response, err := h.appointmentService.UpdateAppointmentStatus(c.Request.Context(), appointmentID, userID, &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Code:      500,
+		statusCode := appointmentErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
 			Message:   err.Error(),
 			Timestamp: time.Now().Format(time.RFC3339),
 		})
@@ -215,14 +306,17 @@ func (h *AppointmentHandler) UpdateAppointmentStatus(c *gin.Context) {
 
 // CancelAppointment 取消预约
 // @Summary 取消预约
-// @Description 取消指定预约
+// @Description 学生或大师在预约开始前取消预约，大师取消需填写原因
 // @Tags 预约管理
 // @Accept json
 // @Produce json
 // @Param appointment_id path string true "预约ID"
+// @Param cancel body model.CancelAppointmentRequest false "取消原因"
 // @Success 200 {object} model.Response{data=model.CancelAppointmentResponse}
 // @Failure 400 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
 // @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
 // @Router /appointments/{appointment_id} [delete]
 func (h *AppointmentHandler) CancelAppointment(c *gin.Context) {
 	appointmentID := c.Param("appointment_id")
@@ -235,10 +329,34 @@ func (h *AppointmentHandler) CancelAppointment(c *gin.Context) {
 		return
 	}
 
-	response, err := h.appointmentService.CancelAppointment(c.Request.Context(), appointmentID)
+	// 请求体可选
+	var req model.CancelAppointmentRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.Response{
+				Code:      400,
+				Message:   "请求参数错误",
+				Timestamp: time.Now().Format(time.RFC3339),
+			})
+			return
+		}
+	}
+
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	response, err := h.appointmentService.CancelAppointment(c.Request.Context(), appointmentID, userID, req.Reason)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Code:      500,
+		statusCode := appointmentErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
 			Message:   err.Error(),
 			Timestamp: time.Now().Format(time.RFC3339),
 		})
@@ -253,6 +371,105 @@ func (h *AppointmentHandler) CancelAppointment(c *gin.Context) {
 	})
 }
 
+// RescheduleAppointment 预约改期
+// @Summary 预约改期
+// @Description 学生或大师在预约开始前将待确认或已确认的预约改到新的时间，仅影响该次预约，重复预约的其他预约不变
+// @Tags 预约管理
+// @Accept json
+// @Produce json
+// @Param appointment_id path string true "预约ID"
+// @Param reschedule body model.RescheduleAppointmentRequest true "新的预约时间"
+// @Success 200 {object} model.Response{data=model.RescheduleAppointmentResponse}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /appointments/{appointment_id}/reschedule [put]
+func (h *AppointmentHandler) RescheduleAppointment(c *gin.Context) {
+	var req model.RescheduleAppointmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "请求参数错误",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	response, err := h.appointmentService.RescheduleAppointment(c.Request.Context(), c.Param("appointment_id"), userID, &req)
+	if err != nil {
+		statusCode := appointmentErrorStatus(err)
+		if statusCode == http.StatusInternalServerError {
+			statusCode = bookingErrorStatus(err)
+		}
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "预约改期成功",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// GetAppointmentStatusHistory 获取预约状态变更记录
+// @Summary 获取预约状态变更记录
+// @Description 获取预约的状态变更历史，包括操作方和原因，仅预约双方可查看
+// @Tags 预约管理
+// @Accept json
+// @Produce json
+// @Param appointment_id path string true "预约ID"
+// @Success 200 {object} model.Response{data=model.AppointmentStatusHistoryResponse}
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Router /appointments/{appointment_id}/history [get]
+func (h *AppointmentHandler) GetAppointmentStatusHistory(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	response, err := h.appointmentService.GetAppointmentStatusHistory(c.Request.Context(), c.Param("appointment_id"), userID)
+	if err != nil {
+		statusCode := appointmentErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "success",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
 // GetMentorAppointmentStats 获取大师预约统计
 // @Summary 获取大师预约统计
 // @Description 获取大师的预约统计信息
@@ -261,10 +478,20 @@ func (h *AppointmentHandler) CancelAppointment(c *gin.Context) {
 // @Produce json
 // @Success 200 {object} model.Response{data=model.MentorAppointmentStatsResponse}
 // @Failure 400 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Security Bearer
 // @Router /appointments/mentor-stats [get]
 func (h *AppointmentHandler) GetMentorAppointmentStats(c *gin.Context) {
-	// 从JWT中获取大师ID，这里暂时使用模拟值
-	mentorID := "MENTOR_00000000001" // TODO: 从JWT中获取
+	// 从权限中间件获取大师档案ID
+	mentorID := c.GetString("mentor_id")
+	if mentorID == "" {
+		c.JSON(http.StatusForbidden, model.Response{
+			Code:      403,
+			Message:   "只有大师可以查看预约统计",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
 
 	response, err := h.appointmentService.GetMentorAppointmentStats(c.Request.Context(), mentorID)
 	if err != nil {
@@ -283,3 +510,50 @@ func (h *AppointmentHandler) GetMentorAppointmentStats(c *gin.Context) {
 		Timestamp: time.Now().Format(time.RFC3339),
 	})
 }
+
+// appointmentErrorStatus 将预约状态变更错误映射为HTTP状态码
+func appointmentErrorStatus(err error) int {
+	var transitionErr *service.AppointmentTransitionError
+	if errors.As(err, &transitionErr) {
+		return http.StatusConflict
+	}
+	switch err.Error() {
+	case "预约不存在":
+		return http.StatusNotFound
+	case "无权操作该预约", "无权执行该状态变更":
+		return http.StatusForbidden
+	case "预约状态已变化，请刷新后重试":
+		return http.StatusConflict
+	case "预约尚未开始", "预约已开始，不能取消", "请填写原因", "预约当前状态不能改期", "预约已开始，不能改期":
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// bookingErrorStatus 将创建预约和改期的业务错误映射为HTTP状态码，未列出的错误为 500
+// 重复预约中某一次预约的错误带有时间前缀，按原因映射
+func bookingErrorStatus(err error) int {
+	var conflictErr *service.AppointmentConflictError
+	if errors.As(err, &conflictErr) {
+		return bookingErrorStatus(conflictErr.Err)
+	}
+	if cause := errors.Unwrap(err); cause != nil {
+		return bookingErrorStatus(cause)
+	}
+	switch err.Error() {
+	case "大师不存在", "套餐不存在":
+		return http.StatusNotFound
+	case "该时段已被预约", "您在该时段已有其他预约", "学生在该时段已有其他预约", "订单已存在":
+		return http.StatusConflict
+	case "不能预约自己", "预约时间需至少提前1小时", "所选时间不在大师可预约时段内", "新的预约时间与原时间相同",
+		"预约时长需与套餐一致", "该套餐不能预约此大师", "套餐未支付或已失效", "套餐已过期", "套餐剩余次数不足", "预约时间超出套餐有效期",
+		"重复规则不合法", "首次预约时间需落在重复规则的日期内", "重复规则的截止时间不能早于首次预约时间", "重复预约不能超过52次", "重复规则至少需要包含两次预约",
+		"不支持的支付方式", "支付方式未启用", "支付金额超出限制", "支付金额与订单不符", "支付币种与订单不符", "业务订单状态不允许支付":
+		return http.StatusBadRequest
+	case "支付方式暂不可用", "创建支付失败，请稍后重试":
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"master-guide-backend/internal/service"
+)
+
+func TestBookingErrorStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"mentor not found", errors.New("大师不存在"), http.StatusNotFound},
+		{"slot taken", errors.New("该时段已被预约"), http.StatusConflict},
+		{"validation", errors.New("预约时间需至少提前1小时"), http.StatusBadRequest},
+		{"package duration with detail", fmt.Errorf("%w（%d分钟）", errors.New("预约时长需与套餐一致"), 60), http.StatusBadRequest},
+		{"recurring occurrence not bookable", fmt.Errorf("2026-10-19 10:00：%w", errors.New("所选时间不在大师可预约时段内")), http.StatusBadRequest},
+		{"recurring occurrence conflict", &service.AppointmentConflictError{Time: time.Now(), Err: errors.New("您在该时段已有其他预约")}, http.StatusConflict},
+		{"payment gateway unavailable", errors.New("创建支付失败，请稍后重试"), http.StatusServiceUnavailable},
+		{"unknown error", errors.New("pq: connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bookingErrorStatus(tt.err); got != tt.want {
+				t.Fatalf("bookingErrorStatus(%q) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"master-guide-backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListMentorPackages 获取大师在售的咨询套餐
+// @Summary 获取大师咨询套餐
+// @Description 获取大师在售的咨询套餐，价格按大师当前时薪和套餐优惠计算
+// @Tags 咨询套餐
+// @Accept json
+// @Produce json
+// @Param mentor_id path string true "大师ID"
+// @Success 200 {object} model.Response{data=model.AppointmentPackageListResponse}
+// @Failure 404 {object} model.ErrorResponse
+// @Router /mentors/{mentor_id}/packages [get]
+func (h *AppointmentHandler) ListMentorPackages(c *gin.Context) {
+	response, err := h.packageService.ListPackages(c.Request.Context(), c.Param("mentor_id"), true)
+	if err != nil {
+		statusCode := packageErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "success",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// ListMyPackages 获取当前大师的全部咨询套餐
+// @Summary 获取我的咨询套餐
+// @Description 获取当前大师发布的全部咨询套餐，包括已下架的套餐
+// @Tags 咨询套餐
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.Response{data=model.AppointmentPackageListResponse}
+// @Failure 403 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /mentors/me/packages [get]
+func (h *AppointmentHandler) ListMyPackages(c *gin.Context) {
+	mentorID := c.GetString("mentor_id")
+	if mentorID == "" {
+		c.JSON(http.StatusForbidden, model.Response{
+			Code:      403,
+			Message:   "只有大师可以管理咨询套餐",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	response, err := h.packageService.ListPackages(c.Request.Context(), mentorID, false)
+	if err != nil {
+		statusCode := packageErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "success",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// CreateMyPackage 发布咨询套餐
+// @Summary 发布咨询套餐
+// @Description 大师发布包含多次咨询的套餐，设置次数、每次时长、优惠比例和有效天数
+// @Tags 咨询套餐
+// @Accept json
+// @Produce json
+// @Param package body model.AppointmentPackageRequest true "套餐信息"
+// @Success 200 {object} model.Response{data=model.AppointmentPackageInfo}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /mentors/me/packages [post]
+func (h *AppointmentHandler) CreateMyPackage(c *gin.Context) {
+	mentorID := c.GetString("mentor_id")
+	if mentorID == "" {
+		c.JSON(http.StatusForbidden, model.Response{
+			Code:      403,
+			Message:   "只有大师可以管理咨询套餐",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	var req model.AppointmentPackageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "请求参数错误",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	response, err := h.packageService.CreatePackage(c.Request.Context(), mentorID, &req)
+	if err != nil {
+		statusCode := packageErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "套餐已发布",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// UpdateMyPackage 修改咨询套餐
+// @Summary 修改咨询套餐
+// @Description 大师修改或下架自己的咨询套餐，已购买的套餐按购买时的内容使用
+// @Tags 咨询套餐
+// @Accept json
+// @Produce json
+// @Param package_id path string true "套餐ID"
+// @Param package body model.AppointmentPackageRequest true "套餐信息"
+// @Success 200 {object} model.Response{data=model.AppointmentPackageInfo}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /mentors/me/packages/{package_id} [put]
+func (h *AppointmentHandler) UpdateMyPackage(c *gin.Context) {
+	mentorID := c.GetString("mentor_id")
+	if mentorID == "" {
+		c.JSON(http.StatusForbidden, model.Response{
+			Code:      403,
+			Message:   "只有大师可以管理咨询套餐",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	var req model.AppointmentPackageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "请求参数错误",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	response, err := h.packageService.UpdatePackage(c.Request.Context(), mentorID, c.Param("package_id"), &req)
+	if err != nil {
+		statusCode := packageErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "套餐已更新",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// PurchasePackage 购买咨询套餐
+// @Summary 购买咨询套餐
+// @Description 学生购买咨询套餐并返回支付信息，支付完成后开通，有效期自开通时起算
+// @Tags 咨询套餐
+// @Accept json
+// @Produce json
+// @Param package_id path string true "套餐ID"
+// @Param purchase body model.PurchaseAppointmentPackageRequest true "支付方式"
+// @Success 200 {object} model.Response{data=model.PurchaseAppointmentPackageResponse}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Router /appointment-packages/{package_id}/purchase [post]
+func (h *AppointmentHandler) PurchasePackage(c *gin.Context) {
+	var req model.PurchaseAppointmentPackageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "请求参数错误",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	studentID := c.GetString("user_id")
+	if studentID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	response, err := h.packageService.PurchasePackage(c.Request.Context(), studentID, c.Param("package_id"), &req, clientInfo(c))
+	if err != nil {
+		statusCode := http.StatusBadRequest
+		switch err.Error() {
+		case "套餐不存在", "大师不存在":
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "套餐订单已创建",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// ListMyPurchases 获取已购买的咨询套餐
+// @Summary 获取已购买的咨询套餐
+// @Description 获取当前用户购买的咨询套餐、已用次数和有效期
+// @Tags 咨询套餐
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.Response{data=model.PackagePurchaseListResponse}
+// @Failure 401 {object} model.ErrorResponse
+// @Router /appointment-packages/purchases [get]
+func (h *AppointmentHandler) ListMyPurchases(c *gin.Context) {
+	studentID := c.GetString("user_id")
+	if studentID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	response, err := h.packageService.ListPurchases(c.Request.Context(), studentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:      500,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "success",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// packageErrorStatus 将咨询套餐业务错误映射为HTTP状态码
+func packageErrorStatus(err error) int {
+	switch err.Error() {
+	case "大师不存在", "套餐不存在":
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"master-guide-backend/internal/model"
@@ -44,7 +47,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	response, err := h.authService.Register(c.Request.Context(), &req)
+	response, err := h.authService.Register(c.Request.Context(), &req, clientInfo(c))
 	if err != nil {
 		statusCode := http.StatusInternalServerError
 		if err.Error() == "邮箱已存在" {
@@ -76,6 +79,8 @@ func (h *AuthHandler) Register(c *gin.Context) {
 // @Success 200 {object} model.Response{data=model.AuthResponse}
 // @Failure 400 {object} model.ErrorResponse
 // @Failure 401 {object} model.ErrorResponse
+// @Failure 423 {object} model.Response{data=model.LoginLockedResponse}
+// @Failure 429 {object} model.Response{data=model.LoginLockedResponse}
 // @Router /auth/login [post]
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req model.LoginRequest
@@ -88,7 +93,27 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	response, err := h.authService.Login(c.Request.Context(), &req)
+	response, err := h.authService.Login(c.Request.Context(), &req, clientInfo(c))
+	var lockErr *service.LoginLockedError
+	if errors.As(err, &lockErr) {
+		// 账户锁定返回423，IP锁定返回429，便于客户端区分密码错误
+		statusCode := http.StatusLocked
+		if lockErr.Scope == service.LoginLockScopeIP {
+			statusCode = http.StatusTooManyRequests
+		}
+		retryAfter := int(math.Ceil(lockErr.RetryAfter.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.JSON(statusCode, model.Response{
+			Code:    statusCode,
+			Message: lockErr.Error(),
+			Data: model.LoginLockedResponse{
+				Scope:      lockErr.Scope,
+				RetryAfter: retryAfter,
+			},
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
 	if err != nil {
 		statusCode := http.StatusInternalServerError
 		if err.Error() == "用户名或密码错误" || err.Error() == "账户已被禁用" || err.Error() == "用户没有可用身份" || err.Error() == "用户没有活跃身份" {
@@ -133,7 +158,7 @@ func (h *AuthHandler) SwitchIdentity(c *gin.Context) {
 		return
 	}
 
-	// 从JWT中获取用户ID（这里简化处理，实际应该从JWT中解析）
+	// 从JWT中获取用户ID
 	userID := c.GetString("user_id")
 	if userID == "" {
 		c.JSON(http.StatusUnauthorized, model.Response{
@@ -144,7 +169,7 @@ func (h *AuthHandler) SwitchIdentity(c *gin.Context) {
 		return
 	}
 
-	response, err := h.authService.SwitchIdentity(c.Request.Context(), userID, req.IdentityID)
+	response, err := h.authService.SwitchIdentity(c.Request.Context(), userID, c.GetString("session_id"), req.IdentityID)
 	if err != nil {
 		statusCode := http.StatusInternalServerError
 		if err.Error() == "身份不存在" || err.Error() == "无权访问此身份" || err.Error() == "身份未激活" {
@@ -168,16 +193,96 @@ func (h *AuthHandler) SwitchIdentity(c *gin.Context) {
 
 // RefreshToken 刷新Token
 // @Summary 刷新Token
-// @Description 刷新JWT Token接口
+// @Description 使用刷新令牌换取新的访问令牌和刷新令牌，旧刷新令牌随即失效；重复使用已失效的刷新令牌会注销整个会话
 // @Tags 认证
 // @Accept json
 // @Produce json
-// @Security Bearer
+// @Param request body model.RefreshTokenRequest true "刷新Token请求"
 // @Success 200 {object} model.Response{data=model.TokenResponse}
+// @Failure 400 {object} model.ErrorResponse
 // @Failure 401 {object} model.ErrorResponse
 // @Router /auth/refresh [post]
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
-	// 从JWT中获取用户ID（这里简化处理，实际应该从JWT中解析）
+	var req model.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "请求参数错误",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	response, err := h.authService.RefreshToken(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "刷新令牌无效或已过期" || err.Error() == "刷新令牌已被使用，会话已注销" || err.Error() == "用户不存在" || err.Error() == "账户已被禁用" || err.Error() == "用户没有活跃身份" {
+			statusCode = http.StatusUnauthorized
+		}
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "Token刷新成功",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// Logout 退出登录
+// @Summary 退出登录
+// @Description 注销当前登录会话，当前访问令牌和刷新令牌立即失效
+// @Tags 认证
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} model.Response
+// @Failure 401 {object} model.ErrorResponse
+// @Router /auth/logout [post]
+func (h *AuthHandler) Logout(c *gin.Context) {
+	sessionID := c.GetString("session_id")
+	if sessionID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	if err := h.authService.Logout(c.Request.Context(), sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:      500,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "退出登录成功",
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// ListSessions 获取登录会话列表
+// @Summary 获取登录会话列表
+// @Description 获取当前用户所有有效的登录设备
+// @Tags 认证
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} model.Response{data=model.SessionListResponse}
+// @Failure 401 {object} model.ErrorResponse
+// @Router /auth/sessions [get]
+func (h *AuthHandler) ListSessions(c *gin.Context) {
 	userID := c.GetString("user_id")
 	if userID == "" {
 		c.JSON(http.StatusUnauthorized, model.Response{
@@ -188,11 +293,52 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	response, err := h.authService.RefreshToken(c.Request.Context(), userID)
+	response, err := h.authService.ListSessions(c.Request.Context(), userID, c.GetString("session_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:      500,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "success",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// RevokeSession 注销指定登录会话
+// @Summary 注销指定登录会话
+// @Description 移除指定登录设备，该设备的令牌立即失效
+// @Tags 认证
+// @Accept json
+// @Produce json
+// @Param session_id path string true "会话ID"
+// @Security Bearer
+// @Success 200 {object} model.Response
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Router /auth/sessions/{session_id} [delete]
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	err := h.authService.RevokeSession(c.Request.Context(), userID, c.Param("session_id"))
 	if err != nil {
 		statusCode := http.StatusInternalServerError
-		if err.Error() == "用户不存在" || err.Error() == "账户已被禁用" || err.Error() == "用户没有活跃身份" {
-			statusCode = http.StatusUnauthorized
+		if err.Error() == "会话不存在" {
+			statusCode = http.StatusNotFound
 		}
 		c.JSON(statusCode, model.Response{
 			Code:      statusCode,
@@ -204,15 +350,14 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 
 	c.JSON(http.StatusOK, model.Response{
 		Code:      0,
-		Message:   "Token刷新成功",
-		Data:      response,
+		Message:   "会话已注销",
 		Timestamp: time.Now().Format(time.RFC3339),
 	})
 }
 
 // ChangePassword 修改密码
 // @Summary 修改密码
-// @Description 用户修改密码接口
+// @Description 用户修改密码接口，成功后当前设备之外的登录会话全部失效
 // @Tags 认证
 // @Accept json
 // @Produce json
@@ -233,7 +378,7 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 
-	// 从JWT中获取用户ID（这里简化处理，实际应该从JWT中解析）
+	// 从JWT中获取用户ID
 	userID := c.GetString("user_id")
 	if userID == "" {
 		c.JSON(http.StatusUnauthorized, model.Response{
@@ -244,7 +389,7 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 
-	err := h.authService.ChangePassword(c.Request.Context(), userID, req.CurrentPassword, req.NewPassword)
+	err := h.authService.ChangePassword(c.Request.Context(), userID, c.GetString("session_id"), req.CurrentPassword, req.NewPassword)
 	if err != nil {
 		statusCode := http.StatusInternalServerError
 		if err.Error() == "用户不存在" || err.Error() == "当前密码错误" {
@@ -264,3 +409,90 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 		Timestamp: time.Now().Format(time.RFC3339),
 	})
 }
+
+// ForgotPassword 忘记密码
+// @Summary 忘记密码
+// @Description 向注册邮箱发送重置密码链接；无论邮箱是否注册均返回相同结果
+// @Tags 认证
+// @Accept json
+// @Produce json
+// @Param request body model.ForgotPasswordRequest true "忘记密码请求"
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.ErrorResponse
+// @Router /auth/forgot-password [post]
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req model.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "请求参数错误",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	if err := h.authService.ForgotPassword(c.Request.Context(), req.Email, clientInfo(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:      500,
+			Message:   "服务器内部错误",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "如果该邮箱已注册，重置密码邮件已发送",
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// ResetPassword 重置密码
+// @Summary 重置密码
+// @Description 使用邮件中的重置令牌设置新密码，成功后所有登录会话失效
+// @Tags 认证
+// @Accept json
+// @Produce json
+// @Param request body model.ResetPasswordRequest true "重置密码请求"
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.ErrorResponse
+// @Router /auth/reset-password [post]
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req model.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "请求参数错误",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	err := h.authService.ResetPassword(c.Request.Context(), req.Token, req.NewPassword, clientInfo(c))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "重置令牌无效或已过期" {
+			statusCode = http.StatusBadRequest
+		}
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "密码重置成功，请重新登录",
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// clientInfo 获取请求客户端信息
+func clientInfo(c *gin.Context) *model.ClientInfo {
+	return &model.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	}
+}
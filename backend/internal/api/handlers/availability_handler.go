@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"master-guide-backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetMentorAvailability 获取大师可预约时间设置
+// @Summary 获取大师可预约时间设置
+// @Description 获取大师的时区、预约间隔、每周可预约时段和今后的例外
+// @Tags 大师管理
+// @Accept json
+// @Produce json
+// @Param mentor_id path string true "大师ID"
+// @Success 200 {object} model.Response{data=model.AvailabilityResponse}
+// @Failure 404 {object} model.ErrorResponse
+// @Router /mentors/{mentor_id}/availability [get]
+func (h *MentorHandler) GetMentorAvailability(c *gin.Context) {
+	mentorID := c.Param("mentor_id")
+	if mentorID == "" {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "大师ID不能为空",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	response, err := h.availabilityService.GetAvailability(c.Request.Context(), mentorID)
+	if err != nil {
+		statusCode := availabilityErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "success",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// GetAvailableSlots 获取大师可预约时段
+// @Summary 获取大师可预约时段
+// @Description 按大师的可预约时间、预约间隔和已有预约计算指定日期范围内可预约的时段
+// @Tags 大师管理
+// @Accept json
+// @Produce json
+// @Param mentor_id path string true "大师ID"
+// @Param from query string false "开始日期 YYYY-MM-DD，默认今天"
+// @Param to query string false "结束日期 YYYY-MM-DD，默认开始日期后14天"
+// @Param duration query int false "预约时长（分钟）" default(60)
+// @Success 200 {object} model.Response{data=model.AvailableSlotsResponse}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Router /mentors/{mentor_id}/slots [get]
+func (h *MentorHandler) GetAvailableSlots(c *gin.Context) {
+	mentorID := c.Param("mentor_id")
+	if mentorID == "" {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "大师ID不能为空",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	var req model.AvailableSlotsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "请求参数错误",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	response, err := h.availabilityService.ListAvailableSlots(c.Request.Context(), mentorID, &req)
+	if err != nil {
+		statusCode := availabilityErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "success",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// UpdateMyAvailability 设置当前大师的可预约时间
+// @Summary 设置可预约时间
+// @Description 设置时区、预约间隔，并整体替换每周可预约时段
+// @Tags 大师管理
+// @Accept json
+// @Produce json
+// @Param availability body model.UpdateAvailabilityRequest true "可预约时间"
+// @Success 200 {object} model.Response{data=model.AvailabilityResponse}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /mentors/me/availability [put]
+func (h *MentorHandler) UpdateMyAvailability(c *gin.Context) {
+	mentorID := c.GetString("mentor_id")
+	if mentorID == "" {
+		c.JSON(http.StatusForbidden, model.Response{
+			Code:      403,
+			Message:   "只有大师可以设置可预约时间",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	var req model.UpdateAvailabilityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "请求参数错误",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	response, err := h.availabilityService.UpdateAvailability(c.Request.Context(), mentorID, &req)
+	if err != nil {
+		statusCode := availabilityErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "可预约时间已更新",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// CreateAvailabilityException 添加可预约时间例外
+// @Summary 添加可预约时间例外
+// @Description 添加请假（available=false，不传时间表示全天）或临时加开时段（available=true）
+// @Tags 大师管理
+// @Accept json
+// @Produce json
+// @Param exception body model.CreateAvailabilityExceptionRequest true "例外信息"
+// @Success 200 {object} model.Response{data=model.MentorAvailabilityException}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /mentors/me/availability/exceptions [post]
+func (h *MentorHandler) CreateAvailabilityException(c *gin.Context) {
+	mentorID := c.GetString("mentor_id")
+	if mentorID == "" {
+		c.JSON(http.StatusForbidden, model.Response{
+			Code:      403,
+			Message:   "只有大师可以设置可预约时间",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	var req model.CreateAvailabilityExceptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "请求参数错误",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	response, err := h.availabilityService.CreateException(c.Request.Context(), mentorID, &req)
+	if err != nil {
+		statusCode := availabilityErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "可预约时间例外已添加",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// DeleteAvailabilityException 删除可预约时间例外
+// @Summary 删除可预约时间例外
+// @Description 删除当前大师的一条可预约时间例外
+// @Tags 大师管理
+// @Accept json
+// @Produce json
+// @Param exception_id path string true "例外ID"
+// @Success 200 {object} model.Response
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /mentors/me/availability/exceptions/{exception_id} [delete]
+func (h *MentorHandler) DeleteAvailabilityException(c *gin.Context) {
+	mentorID := c.GetString("mentor_id")
+	if mentorID == "" {
+		c.JSON(http.StatusForbidden, model.Response{
+			Code:      403,
+			Message:   "只有大师可以设置可预约时间",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	if err := h.availabilityService.DeleteException(c.Request.Context(), mentorID, c.Param("exception_id")); err != nil {
+		statusCode := availabilityErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "可预约时间例外已删除",
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// availabilityErrorStatus 将可预约时间业务错误映射为HTTP状态码
+func availabilityErrorStatus(err error) int {
+	switch err.Error() {
+	case "大师不存在", "可预约时间例外不存在":
+		return http.StatusNotFound
+	case "时区不合法", "可预约时段存在重叠", "可预约时段结束时间需晚于开始时间", "时间格式错误，应为 HH:MM",
+		"日期格式错误", "不能修改过去日期的可预约时间", "加开时段需指定开始和结束时间",
+		"预约时长不合法", "查询日期范围不能超过31天":
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"master-guide-backend/internal/model"
+	"master-guide-backend/internal/service"
+	"master-guide-backend/pkg/ical"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CalendarHandler 日历处理器
+type CalendarHandler struct {
+	calendarService service.CalendarService
+}
+
+// NewCalendarHandler 创建日历处理器
+func NewCalendarHandler(calendarService service.CalendarService) *CalendarHandler {
+	return &CalendarHandler{
+		calendarService: calendarService,
+	}
+}
+
+// CreateFeed 生成日历订阅地址
+// @Summary 生成日历订阅地址
+// @Description 生成包含当前用户预约的 iCalendar 订阅地址，可添加到日历应用；重新生成后旧地址失效，地址只返回一次
+// @Tags 日历
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.Response{data=model.CalendarFeedResponse}
+// @Failure 401 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /calendar/feed [post]
+func (h *CalendarHandler) CreateFeed(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	response, err := h.calendarService.CreateFeed(c.Request.Context(), userID)
+	if err != nil {
+		statusCode := calendarErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "日历订阅地址已生成",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// DeleteFeed 取消日历订阅
+// @Summary 取消日历订阅
+// @Description 删除当前用户的日历订阅，订阅地址随即失效
+// @Tags 日历
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.Response
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /calendar/feed [delete]
+func (h *CalendarHandler) DeleteFeed(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	if err := h.calendarService.DeleteFeed(c.Request.Context(), userID); err != nil {
+		statusCode := calendarErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "日历订阅已取消",
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// GetFeed 获取日历订阅内容
+// @Summary 获取日历订阅内容
+// @Description 日历应用通过订阅地址拉取用户的预约，无需登录，凭地址中的令牌访问
+// @Tags 日历
+// @Produce text/calendar
+// @Param token path string true "订阅令牌"
+// @Success 200 {string} string "iCalendar 文本"
+// @Failure 404 {object} model.ErrorResponse
+// @Router /calendar/feeds/{token} [get]
+func (h *CalendarHandler) GetFeed(c *gin.Context) {
+	data, err := h.calendarService.GetFeed(c.Request.Context(), c.Param("token"))
+	if err != nil {
+		statusCode := calendarErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Data(http.StatusOK, ical.ContentType, data)
+}
+
+// GetAppointmentICS 下载预约日历文件
+// @Summary 下载预约日历文件
+// @Description 下载单个预约的 .ics 文件，可导入日历应用，仅预约双方可下载
+// @Tags 预约管理
+// @Produce text/calendar
+// @Param appointment_id path string true "预约ID"
+// @Success 200 {string} string "iCalendar 文本"
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /appointments/{appointment_id}/ics [get]
+func (h *CalendarHandler) GetAppointmentICS(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	appointmentID := c.Param("appointment_id")
+	data, err := h.calendarService.GetAppointmentICS(c.Request.Context(), appointmentID, userID)
+	if err != nil {
+		statusCode := calendarErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="appointment-%s.ics"`, appointmentID))
+	c.Data(http.StatusOK, ical.ContentType, data)
+}
+
+// calendarErrorStatus 将日历业务错误映射为HTTP状态码
+func calendarErrorStatus(err error) int {
+	switch err.Error() {
+	case "日历订阅不存在", "预约不存在":
+		return http.StatusNotFound
+	case "无权操作该预约":
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
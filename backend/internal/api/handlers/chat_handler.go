@@ -53,7 +53,7 @@ func (h *ChatHandler) GetOnlineUsers(c *gin.Context) {
 
 // GetChatMessages 获取聊天记录
 // @Summary 获取聊天记录
-// @Description 获取私聊或圈子聊天记录
+// @Description 获取与指定用户的私聊记录或已加入圈子的聊天记录，按发送时间倒序；获取私聊记录时对方发来的消息记为已送达
 // @Tags 聊天
 // @Accept json
 // @Produce json
@@ -63,9 +63,22 @@ func (h *ChatHandler) GetOnlineUsers(c *gin.Context) {
 // @Param page_size query int false "每页数量" default(50)
 // @Success 200 {object} model.Response{data=model.ChatMessagesResponse}
 // @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
 // @Failure 500 {object} model.ErrorResponse
+// @Security Bearer
 // @Router /chat/messages [get]
 func (h *ChatHandler) GetChatMessages(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权访问",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
 	var req model.ChatMessagesRequest
 	if err := c.ShouldBindQuery(&req); err != nil {
 		c.JSON(http.StatusBadRequest, model.Response{
@@ -87,7 +100,48 @@ func (h *ChatHandler) GetChatMessages(c *gin.Context) {
 	}
 
 	// 获取聊天记录
-	result, err := h.chatService.GetChatMessages(c.Request.Context(), &req)
+	result, err := h.chatService.GetChatMessages(c.Request.Context(), userID, &req)
+	if err != nil {
+		statusCode := chatErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "success",
+		Data:      result,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// GetConversations 获取聊天会话列表
+// @Summary 获取聊天会话列表
+// @Description 获取当前用户的私聊会话和已加入圈子的群聊会话，包含最后一条消息和未读数，按最后一条消息时间倒序
+// @Tags 聊天
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.Response{data=model.ChatConversationsResponse}
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /chat/conversations [get]
+func (h *ChatHandler) GetConversations(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权访问",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	result, err := h.chatService.GetConversations(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.Response{
 			Code:      500,
@@ -104,3 +158,13 @@ func (h *ChatHandler) GetChatMessages(c *gin.Context) {
 		Timestamp: time.Now().Format(time.RFC3339),
 	})
 }
+
+// chatErrorStatus 将聊天业务错误映射为HTTP状态码
+func chatErrorStatus(err error) int {
+	switch err.Error() {
+	case "未加入该圈子":
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -129,6 +129,8 @@ func (h *CourseHandler) GetCourseDetail(c *gin.Context) {
 // @Success 200 {object} model.Response{data=model.CreateCourseResponse}
 // @Failure 400 {object} model.ErrorResponse
 // @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Security Bearer
 // @Router /courses [post]
 func (h *CourseHandler) CreateCourse(c *gin.Context) {
 	var req model.CreateCourseRequest
@@ -141,8 +143,16 @@ func (h *CourseHandler) CreateCourse(c *gin.Context) {
 		return
 	}
 
-	// 从JWT中获取大师ID，这里暂时使用模拟值
-	mentorID := "MENTOR_00000000001" // TODO: 从JWT中获取
+	// 从权限中间件获取大师档案ID
+	mentorID := c.GetString("mentor_id")
+	if mentorID == "" {
+		c.JSON(http.StatusForbidden, model.Response{
+			Code:      403,
+			Message:   "只有认证大师可以创建课程",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
 
 	response, err := h.courseService.CreateCourse(c.Request.Context(), mentorID, &req)
 	if err != nil {
@@ -164,7 +174,7 @@ func (h *CourseHandler) CreateCourse(c *gin.Context) {
 
 // EnrollCourse 报名课程
 // @Summary 报名课程
-// @Description 用户报名指定课程
+// @Description 用户报名指定课程，付费课程返回支付信息，支付完成后开通
 // @Tags 课程管理
 // @Accept json
 // @Produce json
@@ -195,14 +205,25 @@ func (h *CourseHandler) EnrollCourse(c *gin.Context) {
 		return
 	}
 
-	// 从JWT中获取用户ID，这里暂时使用模拟值
-	userID := "USER_00000000001" // TODO: 从JWT中获取
+	// 从JWT中获取用户ID
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
 
-	response, err := h.courseService.EnrollCourse(c.Request.Context(), userID, courseID, &req)
+	response, err := h.courseService.EnrollCourse(c.Request.Context(), userID, courseID, &req, clientInfo(c))
 	if err != nil {
-		statusCode := http.StatusInternalServerError
-		if err.Error() == "已报名该课程" {
+		statusCode := http.StatusBadRequest
+		switch err.Error() {
+		case "已报名该课程", "报名待支付，请先完成支付":
 			statusCode = http.StatusConflict
+		case "课程不存在":
+			statusCode = http.StatusNotFound
 		}
 		c.JSON(statusCode, model.Response{
 			Code:      statusCode,
@@ -220,6 +241,66 @@ func (h *CourseHandler) EnrollCourse(c *gin.Context) {
 	})
 }
 
+// CancelEnrollment 退课
+// @Summary 退课
+// @Description 取消课程报名。待支付的报名直接取消；已开通的报名按报名时间和学习进度自动退款
+// @Tags 课程管理
+// @Accept json
+// @Produce json
+// @Param course_id path string true "课程ID"
+// @Success 200 {object} model.Response{data=model.CancelEnrollmentResponse}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Router /courses/{course_id}/enroll [delete]
+func (h *CourseHandler) CancelEnrollment(c *gin.Context) {
+	courseID := c.Param("course_id")
+	if courseID == "" {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "课程ID不能为空",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	response, err := h.courseService.CancelEnrollment(c.Request.Context(), userID, courseID)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch err.Error() {
+		case "未报名该课程":
+			statusCode = http.StatusNotFound
+		case "课程已学完，不能退课":
+			statusCode = http.StatusBadRequest
+		case "报名状态已变化，请刷新后重试":
+			statusCode = http.StatusConflict
+		}
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "退课成功",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
 // GetCourseProgress 获取课程进度
 // @Summary 获取课程进度
 // @Description 获取用户在指定课程中的学习进度
@@ -242,8 +323,16 @@ func (h *CourseHandler) GetCourseProgress(c *gin.Context) {
 		return
 	}
 
-	// 从JWT中获取用户ID，这里暂时使用模拟值
-	userID := "USER_00000000001" // TODO: 从JWT中获取
+	// 从JWT中获取用户ID
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
 
 	response, err := h.courseService.GetCourseProgress(c.Request.Context(), userID, courseID)
 	if err != nil {
@@ -334,8 +423,8 @@ func (h *CourseHandler) SearchCourses(c *gin.Context) {
 func (h *CourseHandler) GetRecommendedCourses(c *gin.Context) {
 	userID := c.Query("user_id")
 	if userID == "" {
-		// 从JWT中获取用户ID，这里暂时使用模拟值
-		userID = "USER_00000000001" // TODO: 从JWT中获取
+		// 未指定时使用当前登录用户，匿名访问则返回通用推荐
+		userID = c.GetString("user_id")
 	}
 
 	response, err := h.courseService.GetRecommendedCourses(c.Request.Context(), userID)
@@ -387,8 +476,16 @@ func (h *CourseHandler) GetEnrolledCourses(c *gin.Context) {
 		req.PageSize = 20
 	}
 
-	// 从JWT中获取用户ID，这里暂时使用模拟值
-	userID := "USER_00000000001" // TODO: 从JWT中获取
+	// 从JWT中获取用户ID
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
 
 	response, err := h.courseService.GetEnrolledCourses(c.Request.Context(), userID, &req)
 	if err != nil {
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"master-guide-backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListFeeRules 获取费率规则列表
+// @Summary 获取费率规则列表
+// @Description 管理员查看平台佣金和提现手续费规则
+// @Tags 收入管理
+// @Accept json
+// @Produce json
+// @Param fee_type query string false "规则类型" Enums(platform_commission, withdrawal)
+// @Param status query string false "规则状态" Enums(active, inactive)
+// @Success 200 {object} model.Response{data=model.FeeRuleListResponse}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /admin/fee-rules [get]
+func (h *IncomeHandler) ListFeeRules(c *gin.Context) {
+	var req model.ListFeeRulesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "请求参数错误",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	response, err := h.feeRuleService.ListRules(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:      500,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "success",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// CreateFeeRule 创建费率规则
+// @Summary 创建费率规则
+// @Description 管理员按订单类型、领域、大师等级和生效时间配置平台佣金或提现手续费，促销规则优先于普通规则
+// @Tags 收入管理
+// @Accept json
+// @Produce json
+// @Param rule body model.FeeRuleRequest true "费率规则"
+// @Success 200 {object} model.Response{data=model.FeeRule}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /admin/fee-rules [post]
+func (h *IncomeHandler) CreateFeeRule(c *gin.Context) {
+	var req model.FeeRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "请求参数错误",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	rule, err := h.feeRuleService.CreateRule(c.Request.Context(), &req)
+	if err != nil {
+		statusCode := feeRuleErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "费率规则已创建",
+		Data:      rule,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// UpdateFeeRule 修改费率规则
+// @Summary 修改费率规则
+// @Description 管理员修改费率规则；已被收入或提现记录使用的规则只能修改名称、说明、状态、优先级和失效时间
+// @Tags 收入管理
+// @Accept json
+// @Produce json
+// @Param rule_id path string true "费率规则ID"
+// @Param rule body model.FeeRuleRequest true "费率规则"
+// @Success 200 {object} model.Response{data=model.FeeRule}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /admin/fee-rules/{rule_id} [put]
+func (h *IncomeHandler) UpdateFeeRule(c *gin.Context) {
+	var req model.FeeRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "请求参数错误",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	rule, err := h.feeRuleService.UpdateRule(c.Request.Context(), c.Param("rule_id"), &req)
+	if err != nil {
+		statusCode := feeRuleErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "费率规则已更新",
+		Data:      rule,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// UpdateMentorTier 设置大师等级
+// @Summary 设置大师等级
+// @Description 管理员设置大师等级，之后确认的收入和申请的提现按新等级匹配费率规则
+// @Tags 收入管理
+// @Accept json
+// @Produce json
+// @Param mentor_id path string true "大师ID"
+// @Param tier body model.UpdateMentorTierRequest true "大师等级"
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /admin/mentors/{mentor_id}/tier [put]
+func (h *IncomeHandler) UpdateMentorTier(c *gin.Context) {
+	var req model.UpdateMentorTierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "请求参数错误",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	if err := h.feeRuleService.UpdateMentorTier(c.Request.Context(), c.Param("mentor_id"), req.Tier); err != nil {
+		statusCode := feeRuleErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "大师等级已更新",
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// feeRuleErrorStatus 将费率规则业务错误映射为HTTP状态码
+func feeRuleErrorStatus(err error) int {
+	switch err.Error() {
+	case "费率规则不存在", "大师不存在":
+		return http.StatusNotFound
+	case "费率规则已被使用，不能修改计费条件，请新建规则":
+		return http.StatusConflict
+	case "提现手续费规则不能指定订单类型", "最低手续费不能高于最高手续费", "失效时间必须晚于生效时间":
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -12,13 +12,21 @@ import (
 
 // IncomeHandler 收入处理器
 type IncomeHandler struct {
-	incomeService service.IncomeService
+	incomeService     service.IncomeService
+	ledgerService     service.LedgerService
+	feeRuleService    service.FeeRuleService
+	withdrawalService service.WithdrawalService
+	reportService     service.IncomeReportService
 }
 
 // NewIncomeHandler 创建收入处理器
-func NewIncomeHandler(incomeService service.IncomeService) *IncomeHandler {
+func NewIncomeHandler(incomeService service.IncomeService, ledgerService service.LedgerService, feeRuleService service.FeeRuleService, withdrawalService service.WithdrawalService, reportService service.IncomeReportService) *IncomeHandler {
 	return &IncomeHandler{
-		incomeService: incomeService,
+		incomeService:     incomeService,
+		ledgerService:     ledgerService,
+		feeRuleService:    feeRuleService,
+		withdrawalService: withdrawalService,
+		reportService:     reportService,
 	}
 }
 
@@ -46,7 +54,7 @@ func (h *IncomeHandler) GetIncomeStats(c *gin.Context) {
 		return
 	}
 
-	// 从上下文获取大师ID（实际应该从JWT token中获取）
+	// 从上下文获取大师ID
 	mentorID := c.GetString("mentor_id")
 	if mentorID == "" {
 		c.JSON(http.StatusUnauthorized, model.Response{
@@ -186,14 +194,14 @@ func (h *IncomeHandler) GetIncomeTrends(c *gin.Context) {
 
 // ExportIncomeReport 导出收入报告
 // @Summary 导出收入报告
-// @Description 导出大师的收入报告
+// @Description 导出大师在指定区间的收入报告，包含汇总、按服务项目汇总、收入明细和提现记录；区间较短时立即生成并返回签名下载地址，较长时排队生成，完成后通知大师并可通过报告状态接口获取下载地址
 // @Tags 收入管理
 // @Accept json
 // @Produce json
 // @Param format query string true "导出格式" Enums(csv, excel, pdf)
 // @Param start_date query string true "开始日期" format(date)
-// @Param end_date query string true "结束日期" format(date)
-// @Param type query string true "收入类型" Enums(all, course_enrollment, appointment)
+// @Param end_date query string true "结束日期（含当天）" format(date)
+// @Param type query string true "收入类型" Enums(all, course_enrollment, appointment, appointment_package)
 // @Success 200 {object} model.Response{data=model.IncomeExportResponse}
 // @Failure 400 {object} model.ErrorResponse
 // @Failure 500 {object} model.ErrorResponse
@@ -220,19 +228,24 @@ func (h *IncomeHandler) ExportIncomeReport(c *gin.Context) {
 		return
 	}
 
-	response, err := h.incomeService.ExportIncomeReport(c.Request.Context(), mentorID, &req)
+	response, err := h.reportService.ExportIncomeReport(c.Request.Context(), mentorID, &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Code:      500,
+		statusCode := incomeReportErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
 			Message:   err.Error(),
 			Timestamp: time.Now().Format(time.RFC3339),
 		})
 		return
 	}
 
+	message := "报告生成成功"
+	if response.Status == model.IncomeReportStatusPending {
+		message = "报告正在生成，完成后将通知您"
+	}
 	c.JSON(http.StatusOK, model.Response{
 		Code:      0,
-		Message:   "报告生成成功",
+		Message:   message,
 		Data:      response,
 		Timestamp: time.Now().Format(time.RFC3339),
 	})
@@ -244,7 +257,7 @@ func (h *IncomeHandler) ExportIncomeReport(c *gin.Context) {
 // @Tags 收入管理
 // @Accept json
 // @Produce json
-// @Param status query string false "提现状态" Enums(pending, completed, failed)
+// @Param status query string false "提现状态" Enums(pending, approved, rejected, processing, completed, failed)
 // @Param start_date query string false "开始日期" format(date)
 // @Param end_date query string false "结束日期" format(date)
 // @Param page query int false "页码" default(1)
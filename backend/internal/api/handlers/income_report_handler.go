@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"master-guide-backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetIncomeReport 获取收入报告状态
+// @Summary 获取收入报告状态
+// @Description 获取导出的收入报告生成状态，已生成的报告返回签名下载地址及其过期时间
+// @Tags 收入管理
+// @Accept json
+// @Produce json
+// @Param report_id path string true "报告ID"
+// @Success 200 {object} model.Response{data=model.IncomeExportResponse}
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /income/reports/{report_id} [get]
+func (h *IncomeHandler) GetIncomeReport(c *gin.Context) {
+	mentorID := c.GetString("mentor_id")
+	if mentorID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权访问",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	response, err := h.reportService.GetIncomeReport(c.Request.Context(), mentorID, c.Param("report_id"))
+	if err != nil {
+		statusCode := incomeReportErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "success",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// DownloadIncomeReport 下载收入报告
+// @Summary 下载收入报告
+// @Description 通过导出接口或报告状态接口返回的签名地址下载收入报告文件，地址在过期时间前有效，无需登录
+// @Tags 收入管理
+// @Produce application/octet-stream
+// @Param report_id path string true "报告ID"
+// @Param expires query int true "过期时间（Unix 秒）"
+// @Param signature query string true "签名"
+// @Success 200 {file} file
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 410 {object} model.ErrorResponse
+// @Router /income/reports/{report_id}/download [get]
+func (h *IncomeHandler) DownloadIncomeReport(c *gin.Context) {
+	file, err := h.reportService.OpenReportDownload(c.Request.Context(), c.Param("report_id"), c.Query("expires"), c.Query("signature"))
+	if err != nil {
+		statusCode := incomeReportErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, file.FileName))
+	c.Header("Cache-Control", "private, no-store")
+	c.Data(http.StatusOK, file.ContentType, file.Data)
+}
+
+// incomeReportErrorStatus 将收入报告业务错误映射为HTTP状态码
+func incomeReportErrorStatus(err error) int {
+	switch err.Error() {
+	case "结束日期不能早于开始日期", "导出区间不能超过3年":
+		return http.StatusBadRequest
+	case "下载地址无效":
+		return http.StatusForbidden
+	case "报告不存在", "报告文件不存在":
+		return http.StatusNotFound
+	case "下载地址已过期":
+		return http.StatusGone
+	case "报告导出服务未配置":
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
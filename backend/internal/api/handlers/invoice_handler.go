@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"master-guide-backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListInvoices 获取票据列表
+// @Summary 获取票据列表
+// @Description 获取当前用户作为购买方收到的服务收据、平台服务费账单及红字票据，或作为销售方（大师）由平台代开的服务收据
+// @Tags 支付管理
+// @Accept json
+// @Produce json
+// @Param role query string false "buyer 或 seller，为空时返回全部"
+// @Param document_type query string false "invoice 或 credit_note"
+// @Param category query string false "service 或 platform_fee"
+// @Param start_date query string false "开具日期起（YYYY-MM-DD）"
+// @Param end_date query string false "开具日期止（YYYY-MM-DD）"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} model.Response{data=model.InvoiceListResponse}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /payments/invoices [get]
+func (h *PaymentHandler) ListInvoices(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权访问",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	var req model.InvoiceListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "请求参数错误",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	response, err := h.invoiceService.ListInvoices(c.Request.Context(), userID, &req)
+	if err != nil {
+		statusCode := invoiceErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "success",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// GetInvoice 获取票据详情
+// @Summary 获取票据详情
+// @Description 获取票据的买卖双方、明细和税额，只有票据的购买方或销售方可以查看
+// @Tags 支付管理
+// @Accept json
+// @Produce json
+// @Param invoice_id path string true "票据ID"
+// @Success 200 {object} model.Response{data=model.Invoice}
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /payments/invoices/{invoice_id} [get]
+func (h *PaymentHandler) GetInvoice(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权访问",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	var req model.GetInvoiceRequest
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "请求参数错误",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	invoice, err := h.invoiceService.GetInvoice(c.Request.Context(), userID, req.InvoiceID)
+	if err != nil {
+		statusCode := invoiceErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "success",
+		Data:      invoice,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// DownloadInvoice 下载票据
+// @Summary 下载票据
+// @Description 下载票据的 PDF 或 JSON 文件，只有票据的购买方或销售方可以下载
+// @Tags 支付管理
+// @Produce application/pdf
+// @Produce application/json
+// @Param invoice_id path string true "票据ID"
+// @Param format query string false "pdf（默认）或 json"
+// @Success 200 {file} file
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /payments/invoices/{invoice_id}/download [get]
+func (h *PaymentHandler) DownloadInvoice(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权访问",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	var req model.DownloadInvoiceRequest
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "请求参数错误",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "请求参数错误",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	file, err := h.invoiceService.RenderInvoice(c.Request.Context(), userID, req.InvoiceID, req.Format)
+	if err != nil {
+		statusCode := invoiceErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, file.FileName))
+	c.Header("Cache-Control", "private, no-store")
+	c.Data(http.StatusOK, file.ContentType, file.Data)
+}
+
+// invoiceErrorStatus 将票据业务错误映射为HTTP状态码
+func invoiceErrorStatus(err error) int {
+	switch err.Error() {
+	case "不支持的票据格式":
+		return http.StatusBadRequest
+	case "票据不存在":
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
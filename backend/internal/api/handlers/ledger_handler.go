@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"master-guide-backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetLedgerReconciliation 获取账本对账报告
+// @Summary 获取账本对账报告
+// @Description 管理员查看复式记账试算平衡、各账户余额，以及支付、退款、大师收入、平台服务费和提现与业务记录的核对结果，金额单位为分
+// @Tags 收入管理
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.Response{data=model.LedgerReconciliationReport}
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /admin/ledger/reconciliation [get]
+func (h *IncomeHandler) GetLedgerReconciliation(c *gin.Context) {
+	report, err := h.ledgerService.GetReconciliationReport(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:      500,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "success",
+		Data:      report,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"master-guide-backend/internal/meeting"
+	"master-guide-backend/internal/model"
+	"master-guide-backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// MeetingHandler 视频咨询会议处理器
+type MeetingHandler struct {
+	meetingService service.MeetingService
+	signalingHub   *meeting.SignalingHub
+	upgrader       *websocket.Upgrader
+}
+
+// NewMeetingHandler 创建视频咨询会议处理器，signalingHub 为空时不提供自建会议信令，checkOrigin 校验信令握手请求的来源
+func NewMeetingHandler(meetingService service.MeetingService, signalingHub *meeting.SignalingHub, checkOrigin func(r *http.Request) bool) *MeetingHandler {
+	return &MeetingHandler{
+		meetingService: meetingService,
+		signalingHub:   signalingHub,
+		upgrader:       newWebSocketUpgrader(checkOrigin),
+	}
+}
+
+// GetJoinInfo 获取视频咨询入会信息
+// @Summary 获取视频咨询入会信息
+// @Description 获取本人的入会地址和凭证，仅预约双方可获取，且只在开始前至结束后的入会时间窗口内返回
+// @Tags 预约管理
+// @Accept json
+// @Produce json
+// @Param appointment_id path string true "预约ID"
+// @Success 200 {object} model.Response{data=model.MeetingJoinResponse}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /appointments/{appointment_id}/meeting [get]
+func (h *MeetingHandler) GetJoinInfo(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	response, err := h.meetingService.GetJoinInfo(c.Request.Context(), c.Param("appointment_id"), userID)
+	if err != nil {
+		statusCode := meetingErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "success",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// HandleSignaling 自建会议信令连接
+// @Summary 自建会议信令连接
+// @Description 凭入会凭证建立 WebSocket 连接，在会议室参与方之间转发 offer、answer、candidate、hangup 消息
+// @Tags WebSocket
+// @Param room_id path string true "会议室ID"
+// @Param token query string true "入会凭证"
+// @Success 101 {string} string "Switching Protocols"
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Router /ws/meetings/{room_id} [get]
+func (h *MeetingHandler) HandleSignaling(c *gin.Context) {
+	if h.signalingHub == nil {
+		c.JSON(http.StatusNotFound, model.Response{
+			Code:      404,
+			Message:   "会议室不存在",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	claims, err := h.meetingService.AuthorizeSignaling(c.Request.Context(), c.Param("room_id"), c.Query("token"))
+	if err != nil {
+		statusCode := meetingErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("Meeting signaling upgrade failed: %v", err)
+		return
+	}
+	h.signalingHub.Serve(conn, claims)
+}
+
+// meetingErrorStatus 将视频咨询会议业务错误映射为HTTP状态码
+func meetingErrorStatus(err error) int {
+	switch err.Error() {
+	case "预约不存在", "会议室不存在":
+		return http.StatusNotFound
+	case "无权操作该预约", "会议尚未开放", "会议已结束":
+		return http.StatusForbidden
+	case "入会凭证无效":
+		return http.StatusUnauthorized
+	case "该预约不是视频咨询":
+		return http.StatusBadRequest
+	case "预约当前状态不能进入会议":
+		return http.StatusConflict
+	case "会议室暂不可用，请稍后重试":
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
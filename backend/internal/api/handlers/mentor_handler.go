@@ -12,13 +12,15 @@ import (
 
 // MentorHandler 大师处理器
 type MentorHandler struct {
-	mentorService service.MentorService
+	mentorService       service.MentorService
+	availabilityService service.AvailabilityService
 }
 
 // NewMentorHandler 创建大师处理器
-func NewMentorHandler(mentorService service.MentorService) *MentorHandler {
+func NewMentorHandler(mentorService service.MentorService, availabilityService service.AvailabilityService) *MentorHandler {
 	return &MentorHandler{
-		mentorService: mentorService,
+		mentorService:       mentorService,
+		availabilityService: availabilityService,
 	}
 }
 
@@ -13,18 +13,20 @@ import (
 // PaymentHandler 支付处理器
 type PaymentHandler struct {
 	paymentService service.PaymentService
+	invoiceService service.InvoiceService
 }
 
 // NewPaymentHandler 创建支付处理器
-func NewPaymentHandler(paymentService service.PaymentService) *PaymentHandler {
+func NewPaymentHandler(paymentService service.PaymentService, invoiceService service.InvoiceService) *PaymentHandler {
 	return &PaymentHandler{
 		paymentService: paymentService,
+		invoiceService: invoiceService,
 	}
 }
 
 // CreatePaymentOrder 创建支付订单
 // @Summary 创建支付订单
-// @Description 创建支付订单，返回支付URL和二维码
+// @Description 为待支付的课程报名或预约创建支付订单，返回支付URL和二维码；金额须与业务订单一致
 // @Tags 支付管理
 // @Accept json
 // @Produce json
@@ -44,7 +46,18 @@ func (h *PaymentHandler) CreatePaymentOrder(c *gin.Context) {
 		return
 	}
 
-	response, err := h.paymentService.CreatePaymentOrder(c.Request.Context(), &req)
+	// 从JWT中获取用户ID
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	response, err := h.paymentService.CreatePaymentOrder(c.Request.Context(), userID, &req, clientInfo(c))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, model.Response{
 			Code:      400,
@@ -64,7 +77,7 @@ func (h *PaymentHandler) CreatePaymentOrder(c *gin.Context) {
 
 // QueryPaymentStatus 查询支付状态
 // @Summary 查询支付状态
-// @Description 查询指定订单的支付状态
+// @Description 查询指定订单的支付状态，仅下单用户和管理员可查询
 // @Tags 支付管理
 // @Accept json
 // @Produce json
@@ -84,7 +97,7 @@ func (h *PaymentHandler) QueryPaymentStatus(c *gin.Context) {
 		return
 	}
 
-	response, err := h.paymentService.QueryPaymentStatus(c.Request.Context(), orderID)
+	response, err := h.paymentService.QueryPaymentStatus(c.Request.Context(), c.GetString("user_id"), c.GetBool("is_admin"), orderID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, model.Response{
 			Code:      404,
@@ -148,13 +161,14 @@ func (h *PaymentHandler) ListPaymentHistory(c *gin.Context) {
 
 // CreateRefund 申请退款
 // @Summary 申请退款
-// @Description 申请支付退款
+// @Description 管理员对已完成的支付发起退款，同一笔支付可多次部分退款，累计不超过支付金额。取消预约和退课时按退款策略自动退款
 // @Tags 支付管理
 // @Accept json
 // @Produce json
 // @Param request body model.CreateRefundRequest true "退款申请信息"
 // @Success 200 {object} model.Response{data=model.CreateRefundResponse}
 // @Failure 400 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
 // @Router /payments/refunds [post]
 func (h *PaymentHandler) CreateRefund(c *gin.Context) {
 	var req model.CreateRefundRequest
@@ -187,7 +201,7 @@ func (h *PaymentHandler) CreateRefund(c *gin.Context) {
 
 // QueryRefundStatus 查询退款状态
 // @Summary 查询退款状态
-// @Description 查询指定退款的处理状态
+// @Description 查询指定退款的处理状态，仅原订单的下单用户和管理员可查询
 // @Tags 支付管理
 // @Accept json
 // @Produce json
@@ -207,7 +221,7 @@ func (h *PaymentHandler) QueryRefundStatus(c *gin.Context) {
 		return
 	}
 
-	response, err := h.paymentService.QueryRefundStatus(c.Request.Context(), refundID)
+	response, err := h.paymentService.QueryRefundStatus(c.Request.Context(), c.GetString("user_id"), c.GetBool("is_admin"), refundID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, model.Response{
 			Code:      404,
@@ -295,18 +309,19 @@ func (h *PaymentHandler) GetPaymentStats(c *gin.Context) {
 
 // ProcessPaymentWebhook 支付回调处理
 // @Summary 支付回调处理
-// @Description 处理支付网关回调
+// @Description 处理支付网关异步通知，按网关协议校验签名后更新支付状态，并以网关要求的格式应答
 // @Tags 支付管理
-// @Accept json
+// @Accept plain
 // @Produce json
-// @Param gateway path string true "支付网关"
-// @Param request body model.PaymentWebhookRequest true "回调数据"
+// @Param gateway path string true "支付网关(alipay/wechat/sandbox)"
+// @Param request body string true "网关原始回调报文"
 // @Success 200 {object} model.Response{data=model.PaymentWebhookResponse}
 // @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
 // @Router /payments/webhook/{gateway} [post]
 func (h *PaymentHandler) ProcessPaymentWebhook(c *gin.Context) {
-	gateway := c.Param("gateway")
-	if gateway == "" {
+	gatewayName := c.Param("gateway")
+	if gatewayName == "" {
 		c.JSON(http.StatusBadRequest, model.Response{
 			Code:      400,
 			Message:   "支付网关不能为空",
@@ -315,7 +330,66 @@ func (h *PaymentHandler) ProcessPaymentWebhook(c *gin.Context) {
 		return
 	}
 
-	var req model.PaymentWebhookRequest
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "请求参数错误",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	response, err := h.paymentService.ProcessPaymentWebhook(c.Request.Context(), gatewayName, c.Request.Header, body)
+
+	// 真实网关要求按其协议应答，否则会持续重试通知
+	if contentType, ack := h.paymentService.PaymentWebhookAck(gatewayName, err == nil); contentType != "" {
+		c.Data(http.StatusOK, contentType, ack)
+		return
+	}
+
+	if err != nil {
+		status := http.StatusBadRequest
+		if err.Error() == "签名验证失败" {
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, model.Response{
+			Code:      status,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "回调处理成功",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// SandboxEnabled 是否启用了沙箱支付，未启用时不注册模拟支付路由
+func (h *PaymentHandler) SandboxEnabled() bool {
+	return h.paymentService != nil && h.paymentService.SandboxEnabled()
+}
+
+// SimulateSandboxPayment 模拟沙箱支付结果
+// @Summary 模拟沙箱支付结果
+// @Description 仅启用沙箱网关时注册，仅管理员可用；生成签名回调并按真实回调流程处理，用于本地联调和端到端测试支付流程
+// @Tags 支付管理
+// @Accept json
+// @Produce json
+// @Param charge_id path string true "沙箱支付单ID"
+// @Param request body model.SimulateSandboxPaymentRequest true "模拟结果"
+// @Success 200 {object} model.Response{data=model.PaymentWebhookResponse}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /payments/sandbox/charges/{charge_id}/simulate [post]
+func (h *PaymentHandler) SimulateSandboxPayment(c *gin.Context) {
+	var req model.SimulateSandboxPaymentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, model.Response{
 			Code:      400,
@@ -325,10 +399,58 @@ func (h *PaymentHandler) ProcessPaymentWebhook(c *gin.Context) {
 		return
 	}
 
-	response, err := h.paymentService.ProcessPaymentWebhook(c.Request.Context(), gateway, &req)
+	response, err := h.paymentService.SimulateSandboxPayment(c.Request.Context(), c.Param("charge_id"), req.Status)
 	if err != nil {
+		status := http.StatusBadRequest
+		if err.Error() == "沙箱支付单不存在" || err.Error() == "沙箱支付未启用" {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, model.Response{
+			Code:      status,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "模拟支付成功",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// ListWebhookEvents 获取支付回调事件列表
+// @Summary 获取支付回调事件列表
+// @Description 管理员查看支付回调收件箱，可按网关、处理状态和订单筛选
+// @Tags 支付管理
+// @Accept json
+// @Produce json
+// @Param gateway query string false "支付网关"
+// @Param status query string false "处理状态" Enums(received, processed, ignored, rejected, failed)
+// @Param order_id query string false "支付订单ID"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20)
+// @Success 200 {object} model.Response{data=model.PaymentWebhookEventListResponse}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Router /admin/payments/webhook-events [get]
+func (h *PaymentHandler) ListWebhookEvents(c *gin.Context) {
+	var req model.ListPaymentWebhookEventsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
 		c.JSON(http.StatusBadRequest, model.Response{
 			Code:      400,
+			Message:   "请求参数错误",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	response, err := h.paymentService.ListWebhookEvents(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:      500,
 			Message:   err.Error(),
 			Timestamp: time.Now().Format(time.RFC3339),
 		})
@@ -337,7 +459,46 @@ func (h *PaymentHandler) ProcessPaymentWebhook(c *gin.Context) {
 
 	c.JSON(http.StatusOK, model.Response{
 		Code:      0,
-		Message:   "回调处理成功",
+		Message:   "success",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// ReplayWebhookEvent 重放支付回调
+// @Summary 重放支付回调
+// @Description 管理员重新处理未成功的支付回调（已处理的事件不可重放），沿用首次接收时已验签的回调数据
+// @Tags 支付管理
+// @Accept json
+// @Produce json
+// @Param event_id path string true "回调事件ID"
+// @Success 200 {object} model.Response{data=model.PaymentWebhookResponse}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Router /admin/payments/webhook-events/{event_id}/replay [post]
+func (h *PaymentHandler) ReplayWebhookEvent(c *gin.Context) {
+	response, err := h.paymentService.ReplayWebhookEvent(c.Request.Context(), c.Param("event_id"))
+	if err != nil {
+		status := http.StatusBadRequest
+		switch err.Error() {
+		case "回调事件不存在":
+			status = http.StatusNotFound
+		case "回调事件已处理":
+			status = http.StatusConflict
+		}
+		c.JSON(status, model.Response{
+			Code:      status,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "回调重放成功",
 		Data:      response,
 		Timestamp: time.Now().Format(time.RFC3339),
 	})
@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"master-guide-backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListAdminWithdrawals 获取提现审核列表
+// @Summary 获取提现审核列表
+// @Description 管理员按状态和大师查看提现申请，银行账号仅显示脱敏后的后4位
+// @Tags 收入管理
+// @Accept json
+// @Produce json
+// @Param status query string false "提现状态" Enums(pending, approved, rejected, processing, completed, failed)
+// @Param mentor_id query string false "大师ID"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20)
+// @Success 200 {object} model.Response{data=model.AdminWithdrawalsResponse}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /admin/withdrawals [get]
+func (h *IncomeHandler) ListAdminWithdrawals(c *gin.Context) {
+	var req model.AdminWithdrawalsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "请求参数错误",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	response, err := h.withdrawalService.ListWithdrawals(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:      500,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "success",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// ApproveWithdrawal 审核通过提现申请
+// @Summary 审核通过提现申请
+// @Description 管理员审核通过待审核的提现申请，通过后可加入打款批次
+// @Tags 收入管理
+// @Accept json
+// @Produce json
+// @Param withdrawal_id path string true "提现ID"
+// @Success 200 {object} model.Response{data=model.WithdrawalModel}
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /admin/withdrawals/{withdrawal_id}/approve [post]
+func (h *IncomeHandler) ApproveWithdrawal(c *gin.Context) {
+	withdrawal, err := h.withdrawalService.ApproveWithdrawal(c.Request.Context(), c.Param("withdrawal_id"), c.GetString("user_id"))
+	if err != nil {
+		statusCode := payoutErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "提现申请已审核通过",
+		Data:      withdrawal,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// RejectWithdrawal 驳回提现申请
+// @Summary 驳回提现申请
+// @Description 管理员驳回待审核的提现申请并说明原因，提现金额退回大师可提现余额
+// @Tags 收入管理
+// @Accept json
+// @Produce json
+// @Param withdrawal_id path string true "提现ID"
+// @Param request body model.RejectWithdrawalRequest true "驳回原因"
+// @Success 200 {object} model.Response{data=model.WithdrawalModel}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /admin/withdrawals/{withdrawal_id}/reject [post]
+func (h *IncomeHandler) RejectWithdrawal(c *gin.Context) {
+	var req model.RejectWithdrawalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "请填写驳回原因",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	withdrawal, err := h.withdrawalService.RejectWithdrawal(c.Request.Context(), c.Param("withdrawal_id"), c.GetString("user_id"), strings.TrimSpace(req.Reason))
+	if err != nil {
+		statusCode := payoutErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "提现申请已驳回",
+		Data:      withdrawal,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// ListPayoutBatches 获取打款批次列表
+// @Summary 获取打款批次列表
+// @Description 管理员查看打款批次及其成功、失败笔数
+// @Tags 收入管理
+// @Accept json
+// @Produce json
+// @Param status query string false "批次状态" Enums(processing, completed)
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20)
+// @Success 200 {object} model.Response{data=model.PayoutBatchesResponse}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /admin/payout-batches [get]
+func (h *IncomeHandler) ListPayoutBatches(c *gin.Context) {
+	var req model.PayoutBatchesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "请求参数错误",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	response, err := h.withdrawalService.ListPayoutBatches(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:      500,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "success",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// CreatePayoutBatch 创建打款批次
+// @Summary 创建打款批次
+// @Description 管理员将已审核通过的提现放入新的打款批次，提现状态变为打款中；不指定提现时按审核时间先后选取
+// @Tags 收入管理
+// @Accept json
+// @Produce json
+// @Param request body model.CreatePayoutBatchRequest false "提现ID列表"
+// @Success 200 {object} model.Response{data=model.PayoutBatch}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /admin/payout-batches [post]
+func (h *IncomeHandler) CreatePayoutBatch(c *gin.Context) {
+	var req model.CreatePayoutBatchRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.Response{
+				Code:      400,
+				Message:   "请求参数错误",
+				Timestamp: time.Now().Format(time.RFC3339),
+			})
+			return
+		}
+	}
+
+	batch, err := h.withdrawalService.CreatePayoutBatch(c.Request.Context(), c.GetString("user_id"), &req)
+	if err != nil {
+		statusCode := payoutErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "打款批次已创建",
+		Data:      batch,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// ExportPayoutBatch 导出打款批次
+// @Summary 导出打款批次
+// @Description 管理员下载打款批次的银行转账文件（CSV），包含完整银行账号，请妥善保管
+// @Tags 收入管理
+// @Produce text/csv
+// @Param batch_id path string true "打款批次ID"
+// @Success 200 {file} file
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /admin/payout-batches/{batch_id}/export [get]
+func (h *IncomeHandler) ExportPayoutBatch(c *gin.Context) {
+	batchID := c.Param("batch_id")
+	data, err := h.withdrawalService.ExportPayoutBatch(c.Request.Context(), batchID)
+	if err != nil {
+		statusCode := payoutErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="payout-%s.csv"`, batchID))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", data)
+}
+
+// ImportPayoutResults 导入打款结果
+// @Summary 导入打款结果
+// @Description 管理员上传银行打款结果（CSV，列 withdrawal_id、result、reference、reason，result 取 success 或 failed），成功的提现完成，失败的提现金额退回大师可提现余额；重复导入不会重复处理
+// @Tags 收入管理
+// @Accept multipart/form-data
+// @Produce json
+// @Param batch_id path string true "打款批次ID"
+// @Param file formData file true "打款结果文件"
+// @Success 200 {object} model.Response{data=model.PayoutImportResponse}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Security Bearer
+// @Router /admin/payout-batches/{batch_id}/import [post]
+func (h *IncomeHandler) ImportPayoutResults(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "获取文件失败",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "读取文件失败",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+	defer file.Close()
+
+	response, err := h.withdrawalService.ImportPayoutResults(c.Request.Context(), c.Param("batch_id"), file)
+	if err != nil {
+		statusCode := payoutErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "打款结果已导入",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// payoutErrorStatus 将提现审核和打款业务错误映射为HTTP状态码
+func payoutErrorStatus(err error) int {
+	switch err.Error() {
+	case "提现申请不存在", "打款批次不存在":
+		return http.StatusNotFound
+	case "提现申请状态已变更":
+		return http.StatusConflict
+	case "没有可打款的提现申请", "打款结果文件格式错误":
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
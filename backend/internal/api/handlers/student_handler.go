@@ -37,11 +37,12 @@ func NewStudentHandler(studentService service.StudentService) *StudentHandler {
 // @Success 200 {object} model.Response{data=model.StudentListResponse}
 // @Failure 400 {object} model.Response
 // @Failure 401 {object} model.Response
+// @Failure 403 {object} model.Response
 // @Failure 500 {object} model.Response
 // @Router /students [get]
 func (h *StudentHandler) GetStudents(c *gin.Context) {
-	// 从JWT中获取导师ID
-	mentorID := c.GetString("user_id")
+	// 从权限中间件获取大师档案ID
+	mentorID := c.GetString("mentor_id")
 	if mentorID == "" {
 		c.JSON(http.StatusUnauthorized, model.Response{
 			Code:    401,
@@ -71,8 +72,9 @@ func (h *StudentHandler) GetStudents(c *gin.Context) {
 	// 调用服务
 	response, err := h.studentService.GetStudents(c.Request.Context(), mentorID, &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Code:    500,
+		statusCode := studentErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:    statusCode,
 			Message: err.Error(),
 		})
 		return
@@ -95,10 +97,21 @@ func (h *StudentHandler) GetStudents(c *gin.Context) {
 // @Success 200 {object} model.Response{data=model.StudentDetailResponse}
 // @Failure 400 {object} model.Response
 // @Failure 401 {object} model.Response
+// @Failure 403 {object} model.Response
 // @Failure 404 {object} model.Response
 // @Failure 500 {object} model.Response
 // @Router /students/{student_id} [get]
 func (h *StudentHandler) GetStudentByID(c *gin.Context) {
+	// 从权限中间件获取大师档案ID
+	mentorID := c.GetString("mentor_id")
+	if mentorID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:    401,
+			Message: "未授权访问",
+		})
+		return
+	}
+
 	studentID := c.Param("student_id")
 	if studentID == "" {
 		c.JSON(http.StatusBadRequest, model.Response{
@@ -108,10 +121,11 @@ func (h *StudentHandler) GetStudentByID(c *gin.Context) {
 		return
 	}
 
-	response, err := h.studentService.GetStudentByID(c.Request.Context(), studentID)
+	response, err := h.studentService.GetStudentByID(c.Request.Context(), mentorID, studentID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Code:    500,
+		statusCode := studentErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:    statusCode,
 			Message: err.Error(),
 		})
 		return
@@ -133,11 +147,12 @@ func (h *StudentHandler) GetStudentByID(c *gin.Context) {
 // @Success 200 {object} model.Response{data=model.StudentStatsResponse}
 // @Failure 400 {object} model.Response
 // @Failure 401 {object} model.Response
+// @Failure 403 {object} model.Response
 // @Failure 500 {object} model.Response
 // @Router /students/stats [get]
 func (h *StudentHandler) GetStudentStats(c *gin.Context) {
-	// 从JWT中获取导师ID
-	mentorID := c.GetString("user_id")
+	// 从权限中间件获取大师档案ID
+	mentorID := c.GetString("mentor_id")
 	if mentorID == "" {
 		c.JSON(http.StatusUnauthorized, model.Response{
 			Code:    401,
@@ -148,8 +163,9 @@ func (h *StudentHandler) GetStudentStats(c *gin.Context) {
 
 	response, err := h.studentService.GetStudentStats(c.Request.Context(), mentorID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Code:    500,
+		statusCode := studentErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:    statusCode,
 			Message: err.Error(),
 		})
 		return
@@ -173,11 +189,12 @@ func (h *StudentHandler) GetStudentStats(c *gin.Context) {
 // @Success 200 {object} model.Response{data=model.SendMessageResponse}
 // @Failure 400 {object} model.Response
 // @Failure 401 {object} model.Response
+// @Failure 403 {object} model.Response
 // @Failure 500 {object} model.Response
 // @Router /students/{student_id}/messages [post]
 func (h *StudentHandler) SendMessage(c *gin.Context) {
-	// 从JWT中获取导师ID
-	mentorID := c.GetString("user_id")
+	// 从权限中间件获取大师档案ID
+	mentorID := c.GetString("mentor_id")
 	if mentorID == "" {
 		c.JSON(http.StatusUnauthorized, model.Response{
 			Code:    401,
@@ -206,8 +223,9 @@ func (h *StudentHandler) SendMessage(c *gin.Context) {
 
 	response, err := h.studentService.SendMessage(c.Request.Context(), mentorID, studentID, &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Code:    500,
+		statusCode := studentErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:    statusCode,
 			Message: err.Error(),
 		})
 		return
@@ -232,11 +250,12 @@ func (h *StudentHandler) SendMessage(c *gin.Context) {
 // @Success 200 {object} model.Response{data=model.MessageListResponse}
 // @Failure 400 {object} model.Response
 // @Failure 401 {object} model.Response
+// @Failure 403 {object} model.Response
 // @Failure 500 {object} model.Response
 // @Router /students/{student_id}/messages [get]
 func (h *StudentHandler) GetMessages(c *gin.Context) {
-	// 从JWT中获取导师ID
-	mentorID := c.GetString("user_id")
+	// 从权限中间件获取大师档案ID
+	mentorID := c.GetString("mentor_id")
 	if mentorID == "" {
 		c.JSON(http.StatusUnauthorized, model.Response{
 			Code:    401,
@@ -260,8 +279,9 @@ func (h *StudentHandler) GetMessages(c *gin.Context) {
 
 	response, err := h.studentService.GetMessages(c.Request.Context(), mentorID, studentID, page, pageSize)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Code:    500,
+		statusCode := studentErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:    statusCode,
 			Message: err.Error(),
 		})
 		return
@@ -286,11 +306,12 @@ func (h *StudentHandler) GetMessages(c *gin.Context) {
 // @Success 200 {object} model.Response{data=model.StudentProgressResponse}
 // @Failure 400 {object} model.Response
 // @Failure 401 {object} model.Response
+// @Failure 403 {object} model.Response
 // @Failure 500 {object} model.Response
 // @Router /students/{student_id}/courses/{course_id}/progress [put]
 func (h *StudentHandler) UpdateStudentProgress(c *gin.Context) {
-	// 从JWT中获取导师ID
-	mentorID := c.GetString("user_id")
+	// 从权限中间件获取大师档案ID
+	mentorID := c.GetString("mentor_id")
 	if mentorID == "" {
 		c.JSON(http.StatusUnauthorized, model.Response{
 			Code:    401,
@@ -320,8 +341,9 @@ func (h *StudentHandler) UpdateStudentProgress(c *gin.Context) {
 
 	response, err := h.studentService.UpdateStudentProgress(c.Request.Context(), mentorID, studentID, courseID, &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Code:    500,
+		statusCode := studentErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:    statusCode,
 			Message: err.Error(),
 		})
 		return
@@ -346,11 +368,12 @@ func (h *StudentHandler) UpdateStudentProgress(c *gin.Context) {
 // @Success 200 {object} model.Response{data=model.GradeAssignmentResponse}
 // @Failure 400 {object} model.Response
 // @Failure 401 {object} model.Response
+// @Failure 403 {object} model.Response
 // @Failure 500 {object} model.Response
 // @Router /students/{student_id}/assignments/{assignment_id}/grade [post]
 func (h *StudentHandler) GradeAssignment(c *gin.Context) {
-	// 从JWT中获取导师ID
-	mentorID := c.GetString("user_id")
+	// 从权限中间件获取大师档案ID
+	mentorID := c.GetString("mentor_id")
 	if mentorID == "" {
 		c.JSON(http.StatusUnauthorized, model.Response{
 			Code:    401,
@@ -380,8 +403,9 @@ func (h *StudentHandler) GradeAssignment(c *gin.Context) {
 
 	response, err := h.studentService.GradeAssignment(c.Request.Context(), mentorID, studentID, assignmentID, &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Code:    500,
+		statusCode := studentErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:    statusCode,
 			Message: err.Error(),
 		})
 		return
@@ -405,11 +429,12 @@ func (h *StudentHandler) GradeAssignment(c *gin.Context) {
 // @Success 200 {object} model.Response{data=model.StudentReportResponse}
 // @Failure 400 {object} model.Response
 // @Failure 401 {object} model.Response
+// @Failure 403 {object} model.Response
 // @Failure 500 {object} model.Response
 // @Router /students/{student_id}/report [get]
 func (h *StudentHandler) GetStudentReport(c *gin.Context) {
-	// 从JWT中获取导师ID
-	mentorID := c.GetString("user_id")
+	// 从权限中间件获取大师档案ID
+	mentorID := c.GetString("mentor_id")
 	if mentorID == "" {
 		c.JSON(http.StatusUnauthorized, model.Response{
 			Code:    401,
@@ -438,8 +463,9 @@ func (h *StudentHandler) GetStudentReport(c *gin.Context) {
 
 	response, err := h.studentService.GetStudentReport(c.Request.Context(), mentorID, studentID, &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Code:    500,
+		statusCode := studentErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:    statusCode,
 			Message: err.Error(),
 		})
 		return
@@ -451,3 +477,15 @@ func (h *StudentHandler) GetStudentReport(c *gin.Context) {
 		Data:    response,
 	})
 }
+
+// studentErrorStatus 将学生服务错误映射为HTTP状态码
+func studentErrorStatus(err error) int {
+	switch err.Error() {
+	case "学生不存在", "作业不存在":
+		return http.StatusNotFound
+	case "无权访问该学生":
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -57,7 +57,7 @@ func (h *UploadHandler) UploadFile(c *gin.Context) {
 		return
 	}
 
-	// 获取用户ID（这里简化处理，实际应该从JWT token中获取）
+	// 从JWT中获取用户ID
 	userID := c.GetString("user_id")
 	if userID == "" {
 		userID = "default_user" // 临时默认用户ID
@@ -12,13 +12,15 @@ import (
 
 // UserHandler 用户处理器
 type UserHandler struct {
-	userService service.UserService
+	userService         service.UserService
+	verificationService service.VerificationService
 }
 
 // NewUserHandler 创建用户处理器
-func NewUserHandler(userService service.UserService) *UserHandler {
+func NewUserHandler(userService service.UserService, verificationService service.VerificationService) *UserHandler {
 	return &UserHandler{
-		userService: userService,
+		userService:         userService,
+		verificationService: verificationService,
 	}
 }
 
@@ -34,7 +36,7 @@ func NewUserHandler(userService service.UserService) *UserHandler {
 // @Failure 404 {object} model.ErrorResponse
 // @Router /users/profile [get]
 func (h *UserHandler) GetUserProfile(c *gin.Context) {
-	// 从JWT中获取用户ID（这里简化处理，实际应该从JWT中解析）
+	// 从JWT中获取用户ID
 	userID := c.GetString("user_id")
 	if userID == "" {
 		c.JSON(http.StatusUnauthorized, model.Response{
@@ -90,7 +92,7 @@ func (h *UserHandler) UpdateUserProfile(c *gin.Context) {
 		return
 	}
 
-	// 从JWT中获取用户ID和身份ID（这里简化处理）
+	// 从JWT中获取用户ID和身份ID
 	userID := c.GetString("user_id")
 	identityID := c.GetString("identity_id")
 	if userID == "" {
@@ -134,7 +136,7 @@ func (h *UserHandler) UpdateUserProfile(c *gin.Context) {
 // @Failure 401 {object} model.ErrorResponse
 // @Router /users/identities [get]
 func (h *UserHandler) GetUserIdentities(c *gin.Context) {
-	// 从JWT中获取用户ID（这里简化处理）
+	// 从JWT中获取用户ID
 	userID := c.GetString("user_id")
 	if userID == "" {
 		c.JSON(http.StatusUnauthorized, model.Response{
@@ -186,7 +188,7 @@ func (h *UserHandler) CreateUserIdentity(c *gin.Context) {
 		return
 	}
 
-	// 从JWT中获取用户ID（这里简化处理）
+	// 从JWT中获取用户ID
 	userID := c.GetString("user_id")
 	if userID == "" {
 		c.JSON(http.StatusUnauthorized, model.Response{
@@ -253,7 +255,7 @@ func (h *UserHandler) UpdateUserIdentity(c *gin.Context) {
 		return
 	}
 
-	// 从JWT中获取用户ID（这里简化处理）
+	// 从JWT中获取用户ID
 	userID := c.GetString("user_id")
 	if userID == "" {
 		c.JSON(http.StatusUnauthorized, model.Response{
@@ -296,7 +298,7 @@ func (h *UserHandler) UpdateUserIdentity(c *gin.Context) {
 // @Failure 401 {object} model.ErrorResponse
 // @Router /users/stats/learning [get]
 func (h *UserHandler) GetLearningStats(c *gin.Context) {
-	// 从JWT中获取用户ID（这里简化处理）
+	// 从JWT中获取用户ID
 	userID := c.GetString("user_id")
 	if userID == "" {
 		c.JSON(http.StatusUnauthorized, model.Response{
@@ -336,7 +338,7 @@ func (h *UserHandler) GetLearningStats(c *gin.Context) {
 // @Failure 401 {object} model.ErrorResponse
 // @Router /users/stats/teaching [get]
 func (h *UserHandler) GetTeachingStats(c *gin.Context) {
-	// 从JWT中获取用户ID（这里简化处理）
+	// 从JWT中获取用户ID
 	userID := c.GetString("user_id")
 	if userID == "" {
 		c.JSON(http.StatusUnauthorized, model.Response{
@@ -376,7 +378,7 @@ func (h *UserHandler) GetTeachingStats(c *gin.Context) {
 // @Failure 401 {object} model.ErrorResponse
 // @Router /users/stats/general [get]
 func (h *UserHandler) GetGeneralStats(c *gin.Context) {
-	// 从JWT中获取用户ID（这里简化处理）
+	// 从JWT中获取用户ID
 	userID := c.GetString("user_id")
 	if userID == "" {
 		c.JSON(http.StatusUnauthorized, model.Response{
@@ -417,7 +419,7 @@ func (h *UserHandler) GetGeneralStats(c *gin.Context) {
 // @Failure 401 {object} model.ErrorResponse
 // @Router /users/achievements [get]
 func (h *UserHandler) GetUserAchievements(c *gin.Context) {
-	// 从JWT中获取用户ID（这里简化处理）
+	// 从JWT中获取用户ID
 	userID := c.GetString("user_id")
 	if userID == "" {
 		c.JSON(http.StatusUnauthorized, model.Response{
@@ -459,7 +461,7 @@ func (h *UserHandler) GetUserAchievements(c *gin.Context) {
 // @Failure 401 {object} model.ErrorResponse
 // @Router /users/preferences [get]
 func (h *UserHandler) GetUserPreferences(c *gin.Context) {
-	// 从JWT中获取用户ID（这里简化处理）
+	// 从JWT中获取用户ID
 	userID := c.GetString("user_id")
 	if userID == "" {
 		c.JSON(http.StatusUnauthorized, model.Response{
@@ -511,7 +513,7 @@ func (h *UserHandler) SaveUserPreferences(c *gin.Context) {
 		return
 	}
 
-	// 从JWT中获取用户ID（这里简化处理）
+	// 从JWT中获取用户ID
 	userID := c.GetString("user_id")
 	if userID == "" {
 		c.JSON(http.StatusUnauthorized, model.Response{
@@ -550,7 +552,7 @@ func (h *UserHandler) SaveUserPreferences(c *gin.Context) {
 // @Failure 401 {object} model.ErrorResponse
 // @Router /users/recommended-learning-path [get]
 func (h *UserHandler) GetRecommendedLearningPath(c *gin.Context) {
-	// 从JWT中获取用户ID（这里简化处理）
+	// 从JWT中获取用户ID
 	userID := c.GetString("user_id")
 	if userID == "" {
 		c.JSON(http.StatusUnauthorized, model.Response{
@@ -607,3 +609,121 @@ func (h *UserHandler) GetLearningPathStats(c *gin.Context) {
 		Timestamp: time.Now().Format(time.RFC3339),
 	})
 }
+
+// SendVerificationCode 发送邮箱/手机号验证码
+// @Summary 发送验证码
+// @Description 向当前用户的邮箱或手机号发送验证码，同一号码发送间隔和每日次数受限
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param request body model.SendVerificationCodeRequest true "发送验证码请求"
+// @Success 200 {object} model.Response{data=model.SendVerificationCodeResponse}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 429 {object} model.ErrorResponse
+// @Router /users/verification/code [post]
+func (h *UserHandler) SendVerificationCode(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	var req model.SendVerificationCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "请求参数错误",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	response, err := h.verificationService.SendCode(c.Request.Context(), userID, req.Channel)
+	if err != nil {
+		statusCode := verificationErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "验证码已发送",
+		Data:      response,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// ConfirmVerificationCode 确认邮箱/手机号验证码
+// @Summary 确认验证码
+// @Description 校验验证码，成功后标记邮箱或手机号已验证
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param request body model.ConfirmVerificationCodeRequest true "确认验证码请求"
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 429 {object} model.ErrorResponse
+// @Router /users/verification/confirm [post]
+func (h *UserHandler) ConfirmVerificationCode(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:      401,
+			Message:   "未授权",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	var req model.ConfirmVerificationCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:      400,
+			Message:   "请求参数错误",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	if err := h.verificationService.ConfirmCode(c.Request.Context(), userID, req.Channel, req.Code); err != nil {
+		statusCode := verificationErrorStatus(err)
+		c.JSON(statusCode, model.Response{
+			Code:      statusCode,
+			Message:   err.Error(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:      0,
+		Message:   "验证成功",
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// verificationErrorStatus 根据验证码业务错误返回对应的HTTP状态码
+func verificationErrorStatus(err error) int {
+	switch err.Error() {
+	case "用户不存在":
+		return http.StatusNotFound
+	case "不支持的验证方式", "未绑定手机号", "邮箱已验证", "手机号已验证", "验证码无效或已过期", "验证码错误":
+		return http.StatusBadRequest
+	case "验证码发送过于频繁，请稍后再试", "今日验证码发送次数已达上限", "验证码错误次数过多，请重新获取":
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
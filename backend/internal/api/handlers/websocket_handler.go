@@ -5,46 +5,77 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
+	"master-guide-backend/internal/model"
 	"master-guide-backend/internal/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
 
+// websocketTokenProtocol 通过子协议传递令牌时使用的协议名，浏览器以 ["bearer", "<token>"] 作为子协议列表发起连接
+const websocketTokenProtocol = "bearer"
+
 // WebSocketHandler WebSocket 处理器
 type WebSocketHandler struct {
 	websocketMgr *utils.WebSocketManager
+	upgrader     *websocket.Upgrader
 }
 
-// NewWebSocketHandler 创建 WebSocket 处理器
-func NewWebSocketHandler(websocketMgr *utils.WebSocketManager) *WebSocketHandler {
+// NewWebSocketHandler 创建 WebSocket 处理器，checkOrigin 校验握手请求的来源
+func NewWebSocketHandler(websocketMgr *utils.WebSocketManager, checkOrigin func(r *http.Request) bool) *WebSocketHandler {
 	return &WebSocketHandler{
 		websocketMgr: websocketMgr,
+		upgrader:     newWebSocketUpgrader(checkOrigin),
 	}
 }
 
-// WebSocket 连接升级器
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		// 允许所有来源，生产环境应该限制
-		return true
-	},
+// newWebSocketUpgrader 创建 WebSocket 连接升级器
+func newWebSocketUpgrader(checkOrigin func(r *http.Request) bool) *websocket.Upgrader {
+	return &websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     checkOrigin,
+	}
 }
 
 // HandleWebSocket WebSocket 连接处理
 // @Summary WebSocket 连接
-// @Description 建立 WebSocket 连接，支持实时消息和在线状态
+// @Description 建立 WebSocket 连接，支持实时消息和在线状态。访问令牌可通过 token 查询参数、Authorization 头或子协议 ["bearer", "<token>"] 在握手时提供，
+// @Description 也可在连接后发送 authenticate 事件提供；未在超时时间内完成认证的连接将被关闭
 // @Tags WebSocket
 // @Accept json
 // @Produce json
+// @Param token query string false "访问令牌"
 // @Success 101 {string} string "Switching Protocols"
+// @Failure 401 {object} model.ErrorResponse
 // @Router /ws [get]
 func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
+	token, protocol := websocketToken(c.Request)
+
+	// 握手时携带的令牌无效时直接拒绝，不升级连接
+	var claims *utils.JWTClaims
+	if token != "" {
+		var err error
+		claims, err = h.websocketMgr.AuthenticateToken(c.Request.Context(), token)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, model.Response{
+				Code:      401,
+				Message:   err.Error(),
+				Timestamp: time.Now().Format(time.RFC3339),
+			})
+			return
+		}
+	}
+
 	// 升级 HTTP 连接为 WebSocket
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	var responseHeader http.Header
+	if protocol != "" {
+		responseHeader = http.Header{"Sec-WebSocket-Protocol": {protocol}}
+	}
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, responseHeader)
 	if err != nil {
 		log.Printf("WebSocket upgrade failed: %v", err)
 		return
@@ -56,14 +87,17 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 	// 创建 WebSocket 客户端
 	client := &utils.WebSocketClient{
 		ID:       clientID,
-		UserID:   "", // 将在认证后设置
 		Conn:     conn,
 		Send:     make(chan []byte, 256),
 		Manager:  h.websocketMgr,
 		IsOnline: false,
 	}
+	if claims != nil {
+		h.websocketMgr.BindClient(client, claims)
+		client.AnnounceAuthenticated()
+	}
 
-	// 注册客户端
+	// 注册客户端，未认证的连接超时后关闭
 	h.websocketMgr.RegisterClient(client)
 
 	// 启动读写协程
@@ -77,3 +111,24 @@ func (h *WebSocketHandler) generateClientID() string {
 	rand.Read(b)
 	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
 }
+
+// websocketToken 从握手请求中提取访问令牌，依次检查 token 查询参数、Authorization 头和子协议
+// 令牌来自子协议时返回需要回应的协议名
+func websocketToken(r *http.Request) (token, protocol string) {
+	if token := strings.TrimSpace(r.URL.Query().Get("token")); token != "" {
+		return token, ""
+	}
+
+	parts := strings.SplitN(strings.TrimSpace(r.Header.Get("Authorization")), " ", 2)
+	if len(parts) == 2 && strings.EqualFold(parts[0], "Bearer") {
+		return strings.TrimSpace(parts[1]), ""
+	}
+
+	protocols := websocket.Subprotocols(r)
+	for i := 0; i+1 < len(protocols); i++ {
+		if strings.EqualFold(protocols[i], websocketTokenProtocol) {
+			return protocols[i+1], protocols[i]
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"master-guide-backend/internal/model"
+	"master-guide-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 认证信息在gin上下文中的键
+const (
+	ContextKeyUserID       = "user_id"
+	ContextKeyIdentityID   = "identity_id"
+	ContextKeyIdentityType = "identity_type"
+	ContextKeySessionID    = "session_id"
+)
+
+// SessionChecker 登录会话有效性校验，用于让退出登录、修改密码等操作立即使访问令牌失效
+type SessionChecker interface {
+	IsSessionActive(ctx context.Context, sessionID string) (bool, error)
+}
+
+// PublicRoute 无需认证即可访问的路由
+type PublicRoute struct {
+	Method string
+	Path   string // gin 路由模板，如 /api/v1/mentors/:mentor_id
+}
+
+// JWTAuth JWT认证中间件
+// 校验 Authorization 头中的 Bearer Token，并将用户信息写入上下文；
+// 白名单路由在携带有效Token时同样会写入用户信息，但不会因缺少Token被拒绝。
+// sessionChecker 为空时不校验会话状态
+func JWTAuth(secret string, publicRoutes []PublicRoute, sessionChecker SessionChecker) gin.HandlerFunc {
+	public := make(map[string]struct{}, len(publicRoutes))
+	for _, route := range publicRoutes {
+		public[route.Method+" "+route.Path] = struct{}{}
+	}
+
+	return func(c *gin.Context) {
+		_, isPublic := public[c.Request.Method+" "+c.FullPath()]
+
+		tokenString := extractBearerToken(c.GetHeader("Authorization"))
+		if tokenString == "" {
+			if isPublic {
+				c.Next()
+				return
+			}
+			abortUnauthorized(c, "未提供认证令牌")
+			return
+		}
+
+		claims, err := AuthenticateToken(c.Request.Context(), tokenString, secret, sessionChecker)
+		if err != nil {
+			if isPublic {
+				c.Next()
+				return
+			}
+			abortUnauthorized(c, err.Error())
+			return
+		}
+
+		c.Set(ContextKeyUserID, claims.UserID)
+		c.Set(ContextKeyIdentityID, claims.IdentityID)
+		c.Set(ContextKeyIdentityType, claims.IdentityType)
+		c.Set(ContextKeySessionID, claims.SessionID)
+		c.Next()
+	}
+}
+
+// AuthenticateToken 校验访问令牌的签名、有效期和登录会话，返回令牌中的用户和身份信息
+// sessionChecker 为空时不校验会话状态
+func AuthenticateToken(ctx context.Context, tokenString, secret string, sessionChecker SessionChecker) (*utils.JWTClaims, error) {
+	claims, err := utils.ParseToken(tokenString, secret)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, errors.New("认证令牌已过期")
+		}
+		return nil, errors.New("认证令牌无效")
+	}
+	if claims.UserID == "" || claims.IdentityID == "" || claims.SessionID == "" {
+		return nil, errors.New("认证令牌无效")
+	}
+
+	if sessionChecker != nil {
+		active, err := sessionChecker.IsSessionActive(ctx, claims.SessionID)
+		if err != nil || !active {
+			return nil, errors.New("登录会话已失效")
+		}
+	}
+	return claims, nil
+}
+
+// TokenAuthenticator 使用访问令牌认证 WebSocket 连接
+type TokenAuthenticator struct {
+	secret         string
+	sessionChecker SessionChecker
+}
+
+// NewTokenAuthenticator 创建访问令牌认证器，sessionChecker 为空时不校验会话状态
+func NewTokenAuthenticator(secret string, sessionChecker SessionChecker) *TokenAuthenticator {
+	return &TokenAuthenticator{
+		secret:         secret,
+		sessionChecker: sessionChecker,
+	}
+}
+
+// AuthenticateToken 校验访问令牌
+func (a *TokenAuthenticator) AuthenticateToken(ctx context.Context, token string) (*utils.JWTClaims, error) {
+	return AuthenticateToken(ctx, token, a.secret, a.sessionChecker)
+}
+
+// IsSessionActive 检查登录会话是否仍有效，sessionChecker 为空时视为有效
+func (a *TokenAuthenticator) IsSessionActive(ctx context.Context, sessionID string) (bool, error) {
+	if a.sessionChecker == nil {
+		return true, nil
+	}
+	return a.sessionChecker.IsSessionActive(ctx, sessionID)
+}
+
+// extractBearerToken 从 Authorization 头中提取 Bearer Token
+func extractBearerToken(header string) string {
+	parts := strings.SplitN(strings.TrimSpace(header), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(parts[1])
+}
+
+// abortUnauthorized 以统一格式返回401
+func abortUnauthorized(c *gin.Context, message string) {
+	c.AbortWithStatusJSON(http.StatusUnauthorized, model.Response{
+		Code:      401,
+		Message:   message,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
@@ -1,6 +1,9 @@
 package middleware
 
 import (
+	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"master-guide-backend/pkg/config"
@@ -19,3 +22,29 @@ func CORS(cfg config.CORSConfig) gin.HandlerFunc {
 		MaxAge:           time.Duration(cfg.MaxAge) * time.Second,
 	})
 }
+
+// WebSocketOriginChecker 按 CORS 允许的来源校验 WebSocket 握手请求的 Origin
+// 未携带 Origin 的非浏览器客户端和同源请求始终放行，允许来源包含 * 时放行所有来源
+func WebSocketOriginChecker(cfg config.CORSConfig) func(r *http.Request) bool {
+	allowed := make(map[string]struct{}, len(cfg.AllowedOrigins))
+	allowAll := false
+	for _, origin := range cfg.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimRight(strings.TrimSpace(origin), "/"))
+		if origin == "*" {
+			allowAll = true
+		}
+		allowed[origin] = struct{}{}
+	}
+
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" || allowAll {
+			return true
+		}
+		if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+			return true
+		}
+		_, ok := allowed[strings.ToLower(strings.TrimRight(origin, "/"))]
+		return ok
+	}
+}
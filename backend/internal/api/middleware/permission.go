@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"master-guide-backend/internal/model"
+	"master-guide-backend/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContextKeyMentorID 当前大师档案ID在gin上下文中的键
+const ContextKeyMentorID = "mentor_id"
+
+// ContextKeyIsAdmin 当前用户是否为管理员在gin上下文中的键
+const ContextKeyIsAdmin = "is_admin"
+
+// 身份类型
+const (
+	IdentityTypeMaster     = "master"
+	IdentityTypeApprentice = "apprentice"
+)
+
+// Permission 路由访问权限声明
+type Permission struct {
+	IdentityTypes   []string // 允许的身份类型，为空表示不限制
+	RequireVerified bool     // 是否要求身份已通过认证
+	RequireMentor   bool     // 是否要求存在有效的大师档案
+
+	RequireEmailVerified bool // 是否要求邮箱已验证
+	RequirePhoneVerified bool // 是否要求手机号已验证
+
+	RequireAdmin bool // 是否要求为配置中的管理员用户
+}
+
+// 常用权限
+var (
+	// PermMaster 已激活的大师身份
+	PermMaster = Permission{IdentityTypes: []string{IdentityTypeMaster}, RequireMentor: true}
+	// PermVerifiedMaster 已激活且已认证的大师身份
+	PermVerifiedMaster = Permission{IdentityTypes: []string{IdentityTypeMaster}, RequireVerified: true, RequireMentor: true}
+	// PermApprentice 已激活的学徒身份
+	PermApprentice = Permission{IdentityTypes: []string{IdentityTypeApprentice}}
+	// PermVerifiedContact 邮箱和手机号均已验证，用于提现等资金操作
+	PermVerifiedContact = Permission{RequireEmailVerified: true, RequirePhoneVerified: true}
+	// PermAdmin 管理员，用于支付回调重放等运维操作
+	PermAdmin = Permission{RequireAdmin: true}
+)
+
+// PermissionChecker 基于当前身份的权限校验器
+type PermissionChecker struct {
+	userRepo     repository.UserRepository
+	identityRepo repository.IdentityRepository
+	mentorRepo   repository.MentorRepository
+	adminUserIDs []string
+}
+
+// NewPermissionChecker 创建权限校验器
+func NewPermissionChecker(userRepo repository.UserRepository, identityRepo repository.IdentityRepository, mentorRepo repository.MentorRepository, adminUserIDs []string) *PermissionChecker {
+	return &PermissionChecker{
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		mentorRepo:   mentorRepo,
+		adminUserIDs: adminUserIDs,
+	}
+}
+
+// Require 返回校验指定权限的中间件，需在 JWTAuth 之后使用
+// 校验通过后会以数据库中的身份信息刷新上下文，大师身份同时写入 mentor_id
+func (p *PermissionChecker) Require(perm Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString(ContextKeyUserID)
+		identityID := c.GetString(ContextKeyIdentityID)
+		if userID == "" || identityID == "" {
+			abortUnauthorized(c, "未授权")
+			return
+		}
+
+		identity, err := p.identityRepo.GetByID(c.Request.Context(), identityID)
+		if err != nil || identity.UserID != userID {
+			abortUnauthorized(c, "身份无效")
+			return
+		}
+
+		if perm.RequireAdmin && !containsString(p.adminUserIDs, userID) {
+			abortForbidden(c, "需要管理员权限")
+			return
+		}
+
+		if len(perm.IdentityTypes) > 0 && !containsString(perm.IdentityTypes, identity.IdentityType) {
+			abortForbidden(c, "当前身份无权访问")
+			return
+		}
+
+		if identity.Status != "active" {
+			abortForbidden(c, "身份未激活")
+			return
+		}
+
+		if perm.RequireVerified && identity.VerificationStatus != "verified" {
+			abortForbidden(c, "身份未通过认证")
+			return
+		}
+
+		if perm.RequireEmailVerified || perm.RequirePhoneVerified {
+			user, err := p.userRepo.GetByID(c.Request.Context(), userID)
+			if err != nil {
+				abortUnauthorized(c, "用户不存在")
+				return
+			}
+			if perm.RequireEmailVerified && !user.EmailVerified {
+				abortForbidden(c, "请先完成邮箱验证")
+				return
+			}
+			if perm.RequirePhoneVerified && !user.PhoneVerified {
+				abortForbidden(c, "请先完成手机号验证")
+				return
+			}
+		}
+
+		c.Set(ContextKeyIdentityType, identity.IdentityType)
+
+		if identity.IdentityType == IdentityTypeMaster {
+			mentor, err := p.mentorRepo.GetMentorByIdentityID(c.Request.Context(), identity.ID)
+			if err == nil && mentor.Status == "active" {
+				c.Set(ContextKeyMentorID, mentor.ID)
+			} else if perm.RequireMentor {
+				abortForbidden(c, "大师档案不存在或已停用")
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// MarkAdmin 返回标记当前用户是否为管理员的中间件，需在 JWTAuth 之后使用
+// 用于普通用户和管理员均可访问、但管理员可查看他人数据的接口
+func (p *PermissionChecker) MarkAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(ContextKeyIsAdmin, containsString(p.adminUserIDs, c.GetString(ContextKeyUserID)))
+		c.Next()
+	}
+}
+
+// containsString 判断切片中是否包含指定字符串
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
+
+// abortForbidden 以统一格式返回403
+func abortForbidden(c *gin.Context, message string) {
+	c.AbortWithStatusJSON(http.StatusForbidden, model.Response{
+		Code:      403,
+		Message:   message,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"master-guide-backend/internal/model"
+	"master-guide-backend/pkg/cache"
+	"master-guide-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitRule 限流规则（固定窗口计数）
+type RateLimitRule struct {
+	Name    string                      // 规则名称，用于区分计数键
+	Limit   int64                       // 窗口内允许的最大请求数
+	Window  time.Duration               // 统计窗口
+	KeyFunc func(c *gin.Context) string // 限流维度，为空时按客户端IP
+}
+
+// RateLimit 通用限流中间件，计数保存在缓存中，缓存不可用时放行
+func RateLimit(rule RateLimitRule) gin.HandlerFunc {
+	keyFunc := rule.KeyFunc
+	if keyFunc == nil {
+		keyFunc = KeyByIP
+	}
+	windowSeconds := int64(rule.Window / time.Second)
+	if windowSeconds <= 0 {
+		windowSeconds = 1
+	}
+
+	return func(c *gin.Context) {
+		now := time.Now().Unix()
+		windowStart := now - now%windowSeconds
+		key := fmt.Sprintf("ratelimit:%s:%s:%d", rule.Name, keyFunc(c), windowStart)
+
+		count, err := cache.Incr(c.Request.Context(), key)
+		if err != nil {
+			logger.Warn("限流计数失败，已放行请求", logger.String("rule", rule.Name), logger.String("error", err.Error()))
+			c.Next()
+			return
+		}
+		if count == 1 {
+			_ = cache.Expire(c.Request.Context(), key, time.Duration(windowSeconds)*time.Second)
+		}
+
+		remaining := rule.Limit - count
+		if remaining < 0 {
+			remaining = 0
+		}
+		c.Header("X-RateLimit-Limit", strconv.FormatInt(rule.Limit, 10))
+		c.Header("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+
+		if count > rule.Limit {
+			c.Header("Retry-After", strconv.FormatInt(windowStart+windowSeconds-now, 10))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, model.Response{
+				Code:      429,
+				Message:   "请求过于频繁，请稍后再试",
+				Timestamp: time.Now().Format(time.RFC3339),
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// KeyByIP 按客户端IP限流
+func KeyByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// KeyByUserOrIP 已登录按用户限流，未登录按客户端IP限流
+func KeyByUserOrIP(c *gin.Context) string {
+	if userID := c.GetString(ContextKeyUserID); userID != "" {
+		return "user:" + userID
+	}
+	return KeyByIP(c)
+}
@@ -1,15 +1,69 @@
 package routes
 
 import (
+	"net/http"
+	"time"
+
 	"master-guide-backend/internal/api/handlers"
+	"master-guide-backend/internal/api/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
+// publicRoutes 无需登录即可访问的路由白名单
+var publicRoutes = []middleware.PublicRoute{
+	{Method: http.MethodGet, Path: "/api/v1/health"},
+
+	{Method: http.MethodPost, Path: "/api/v1/auth/register"},
+	{Method: http.MethodPost, Path: "/api/v1/auth/login"},
+	{Method: http.MethodPost, Path: "/api/v1/auth/refresh"},
+	{Method: http.MethodPost, Path: "/api/v1/auth/forgot-password"},
+	{Method: http.MethodPost, Path: "/api/v1/auth/reset-password"},
+
+	{Method: http.MethodGet, Path: "/api/v1/mentors"},
+	{Method: http.MethodGet, Path: "/api/v1/mentors/:mentor_id"},
+	{Method: http.MethodGet, Path: "/api/v1/mentors/search"},
+	{Method: http.MethodGet, Path: "/api/v1/mentors/recommended"},
+	{Method: http.MethodGet, Path: "/api/v1/mentors/:mentor_id/reviews"},
+	{Method: http.MethodGet, Path: "/api/v1/mentors/:mentor_id/availability"},
+	{Method: http.MethodGet, Path: "/api/v1/mentors/:mentor_id/slots"},
+	{Method: http.MethodGet, Path: "/api/v1/mentors/:mentor_id/packages"},
+
+	{Method: http.MethodGet, Path: "/api/v1/courses"},
+	{Method: http.MethodGet, Path: "/api/v1/courses/:course_id"},
+	{Method: http.MethodGet, Path: "/api/v1/courses/search"},
+	{Method: http.MethodGet, Path: "/api/v1/courses/recommended"},
+
+	{Method: http.MethodGet, Path: "/api/v1/circles"},
+	{Method: http.MethodGet, Path: "/api/v1/circles/recommended"},
+	{Method: http.MethodGet, Path: "/api/v1/circles/:circle_id/posts"},
+	{Method: http.MethodGet, Path: "/api/v1/posts/:post_id/comments"},
+
+	{Method: http.MethodGet, Path: "/api/v1/reviews"},
+	{Method: http.MethodGet, Path: "/api/v1/reviews/:review_id"},
+	{Method: http.MethodGet, Path: "/api/v1/reviews/stats"},
+
+	{Method: http.MethodGet, Path: "/api/v1/payments/methods"},
+	{Method: http.MethodPost, Path: "/api/v1/payments/webhook/:gateway"},
+
+	{Method: http.MethodGet, Path: "/api/v1/search"},
+
+	{Method: http.MethodGet, Path: "/api/v1/calendar/feeds/:token"},
+	{Method: http.MethodGet, Path: "/api/v1/income/reports/:report_id/download"},
+}
+
+// 接口限流规则
+var (
+	registerRateLimit       = middleware.RateLimitRule{Name: "register", Limit: 10, Window: time.Hour}
+	forgotPasswordRateLimit = middleware.RateLimitRule{Name: "forgot_password", Limit: 10, Window: time.Hour}
+	paymentOrderRateLimit   = middleware.RateLimitRule{Name: "payment_orders", Limit: 30, Window: time.Minute, KeyFunc: middleware.KeyByUserOrIP}
+)
+
 // SetupRoutes 设置路由
-func SetupRoutes(r *gin.Engine, authHandler *handlers.AuthHandler, userHandler *handlers.UserHandler, mentorHandler *handlers.MentorHandler, courseHandler *handlers.CourseHandler, appointmentHandler *handlers.AppointmentHandler, circleHandler *handlers.CircleHandler, postHandler *handlers.PostHandler, commentHandler *handlers.CommentHandler, reviewHandler *handlers.ReviewHandler, notificationHandler *handlers.NotificationHandler, learningHandler *handlers.LearningHandler, studentHandler *handlers.StudentHandler, incomeHandler *handlers.IncomeHandler, paymentHandler *handlers.PaymentHandler, uploadHandler *handlers.UploadHandler, searchHandler *handlers.SearchHandler, statsHandler *handlers.StatsHandler, chatHandler *handlers.ChatHandler, websocketHandler *handlers.WebSocketHandler) {
+func SetupRoutes(r *gin.Engine, jwtSecret string, sessionChecker middleware.SessionChecker, permissionChecker *middleware.PermissionChecker, authHandler *handlers.AuthHandler, userHandler *handlers.UserHandler, mentorHandler *handlers.MentorHandler, courseHandler *handlers.CourseHandler, appointmentHandler *handlers.AppointmentHandler, circleHandler *handlers.CircleHandler, postHandler *handlers.PostHandler, commentHandler *handlers.CommentHandler, reviewHandler *handlers.ReviewHandler, notificationHandler *handlers.NotificationHandler, learningHandler *handlers.LearningHandler, studentHandler *handlers.StudentHandler, incomeHandler *handlers.IncomeHandler, paymentHandler *handlers.PaymentHandler, uploadHandler *handlers.UploadHandler, searchHandler *handlers.SearchHandler, statsHandler *handlers.StatsHandler, chatHandler *handlers.ChatHandler, websocketHandler *handlers.WebSocketHandler, calendarHandler *handlers.CalendarHandler, meetingHandler *handlers.MeetingHandler) {
 	// API v1 路由组
 	v1 := r.Group("/api/v1")
+	v1.Use(middleware.JWTAuth(jwtSecret, publicRoutes, sessionChecker))
 	{
 		// 健康检查
 		v1.GET("/health", func(c *gin.Context) {
@@ -23,11 +77,16 @@ func SetupRoutes(r *gin.Engine, authHandler *handlers.AuthHandler, userHandler *
 		auth := v1.Group("/auth")
 		{
 			if authHandler != nil {
-				auth.POST("/register", authHandler.Register)
+				auth.POST("/register", middleware.RateLimit(registerRateLimit), authHandler.Register)
 				auth.POST("/login", authHandler.Login)
 				auth.POST("/refresh", authHandler.RefreshToken)
 				auth.POST("/switch-identity", authHandler.SwitchIdentity)
 				auth.POST("/change-password", authHandler.ChangePassword)
+				auth.POST("/forgot-password", middleware.RateLimit(forgotPasswordRateLimit), authHandler.ForgotPassword)
+				auth.POST("/reset-password", authHandler.ResetPassword)
+				auth.POST("/logout", authHandler.Logout)
+				auth.GET("/sessions", authHandler.ListSessions)
+				auth.DELETE("/sessions/:session_id", authHandler.RevokeSession)
 			} else {
 				auth.POST("/register", func(c *gin.Context) {
 					c.JSON(200, gin.H{"message": "Register endpoint - TODO"})
@@ -58,6 +117,8 @@ func SetupRoutes(r *gin.Engine, authHandler *handlers.AuthHandler, userHandler *
 				users.PUT("/preferences", userHandler.SaveUserPreferences)
 				users.GET("/recommended-learning-path", userHandler.GetRecommendedLearningPath)
 				users.GET("/learning-path-stats", userHandler.GetLearningPathStats)
+				users.POST("/verification/code", userHandler.SendVerificationCode)
+				users.POST("/verification/confirm", userHandler.ConfirmVerificationCode)
 			} else {
 				users.GET("/profile", func(c *gin.Context) {
 					c.JSON(200, gin.H{"message": "Get user profile - TODO"})
@@ -83,6 +144,17 @@ func SetupRoutes(r *gin.Engine, authHandler *handlers.AuthHandler, userHandler *
 				mentors.GET("/search", mentorHandler.SearchMentors)
 				mentors.GET("/recommended", mentorHandler.GetRecommendedMentors)
 				mentors.GET("/:mentor_id/reviews", mentorHandler.GetMentorReviews)
+				mentors.GET("/:mentor_id/availability", mentorHandler.GetMentorAvailability)
+				mentors.GET("/:mentor_id/slots", mentorHandler.GetAvailableSlots)
+				mentors.PUT("/me/availability", permissionChecker.Require(middleware.PermMaster), mentorHandler.UpdateMyAvailability)
+				mentors.POST("/me/availability/exceptions", permissionChecker.Require(middleware.PermMaster), mentorHandler.CreateAvailabilityException)
+				mentors.DELETE("/me/availability/exceptions/:exception_id", permissionChecker.Require(middleware.PermMaster), mentorHandler.DeleteAvailabilityException)
+				if appointmentHandler != nil {
+					mentors.GET("/:mentor_id/packages", appointmentHandler.ListMentorPackages)
+					mentors.GET("/me/packages", permissionChecker.Require(middleware.PermMaster), appointmentHandler.ListMyPackages)
+					mentors.POST("/me/packages", permissionChecker.Require(middleware.PermMaster), appointmentHandler.CreateMyPackage)
+					mentors.PUT("/me/packages/:package_id", permissionChecker.Require(middleware.PermMaster), appointmentHandler.UpdateMyPackage)
+				}
 			} else {
 				mentors.GET("", func(c *gin.Context) {
 					c.JSON(200, gin.H{"message": "Get mentors list - TODO"})
@@ -105,8 +177,9 @@ func SetupRoutes(r *gin.Engine, authHandler *handlers.AuthHandler, userHandler *
 			if courseHandler != nil {
 				courses.GET("", courseHandler.GetCourses)
 				courses.GET("/:course_id", courseHandler.GetCourseDetail)
-				courses.POST("", courseHandler.CreateCourse)
+				courses.POST("", permissionChecker.Require(middleware.PermVerifiedMaster), courseHandler.CreateCourse)
 				courses.POST("/:course_id/enroll", courseHandler.EnrollCourse)
+				courses.DELETE("/:course_id/enroll", courseHandler.CancelEnrollment)
 				courses.GET("/:course_id/progress", courseHandler.GetCourseProgress)
 				courses.GET("/search", courseHandler.SearchCourses)
 				courses.GET("/recommended", courseHandler.GetRecommendedCourses)
@@ -145,10 +218,19 @@ func SetupRoutes(r *gin.Engine, authHandler *handlers.AuthHandler, userHandler *
 			if appointmentHandler != nil {
 				appointments.GET("", appointmentHandler.GetAppointments)
 				appointments.POST("", appointmentHandler.CreateAppointment)
+				appointments.POST("/recurring", appointmentHandler.CreateRecurringAppointment)
 				appointments.GET("/:appointment_id", appointmentHandler.GetAppointmentDetail)
 				appointments.PUT("/:appointment_id/status", appointmentHandler.UpdateAppointmentStatus)
+				appointments.PUT("/:appointment_id/reschedule", appointmentHandler.RescheduleAppointment)
 				appointments.DELETE("/:appointment_id", appointmentHandler.CancelAppointment)
-				appointments.GET("/mentor-stats", appointmentHandler.GetMentorAppointmentStats)
+				appointments.GET("/:appointment_id/history", appointmentHandler.GetAppointmentStatusHistory)
+				appointments.GET("/mentor-stats", permissionChecker.Require(middleware.PermMaster), appointmentHandler.GetMentorAppointmentStats)
+				if calendarHandler != nil {
+					appointments.GET("/:appointment_id/ics", calendarHandler.GetAppointmentICS)
+				}
+				if meetingHandler != nil {
+					appointments.GET("/:appointment_id/meeting", meetingHandler.GetJoinInfo)
+				}
 			} else {
 				appointments.GET("", func(c *gin.Context) {
 					c.JSON(200, gin.H{"message": "Get appointments list - TODO"})
@@ -171,6 +253,25 @@ func SetupRoutes(r *gin.Engine, authHandler *handlers.AuthHandler, userHandler *
 			}
 		}
 
+		// 咨询套餐购买相关路由
+		if appointmentHandler != nil {
+			appointmentPackages := v1.Group("/appointment-packages")
+			{
+				appointmentPackages.POST("/:package_id/purchase", appointmentHandler.PurchasePackage)
+				appointmentPackages.GET("/purchases", appointmentHandler.ListMyPurchases)
+			}
+		}
+
+		// 日历相关路由
+		if calendarHandler != nil {
+			calendar := v1.Group("/calendar")
+			{
+				calendar.POST("/feed", calendarHandler.CreateFeed)
+				calendar.DELETE("/feed", calendarHandler.DeleteFeed)
+				calendar.GET("/feeds/:token", calendarHandler.GetFeed)
+			}
+		}
+
 		// 圈子相关路由
 		circles := v1.Group("/circles")
 		{
@@ -384,16 +485,23 @@ func SetupRoutes(r *gin.Engine, authHandler *handlers.AuthHandler, userHandler *
 			}
 		}
 
+		// 收入报告下载使用签名地址，无需登录
+		if incomeHandler != nil {
+			v1.GET("/income/reports/:report_id/download", incomeHandler.DownloadIncomeReport)
+		}
+
 		// 收入相关路由
 		income := v1.Group("/income")
 		{
 			if incomeHandler != nil {
+				income.Use(permissionChecker.Require(middleware.PermVerifiedMaster))
 				income.GET("/stats", incomeHandler.GetIncomeStats)
 				income.GET("/transactions", incomeHandler.GetIncomeTransactions)
 				income.GET("/trends", incomeHandler.GetIncomeTrends)
 				income.GET("/export", incomeHandler.ExportIncomeReport)
+				income.GET("/reports/:report_id", incomeHandler.GetIncomeReport)
 				income.GET("/withdrawals", incomeHandler.GetWithdrawals)
-				income.POST("/withdrawals", incomeHandler.CreateWithdrawal)
+				income.POST("/withdrawals", permissionChecker.Require(middleware.PermVerifiedContact), incomeHandler.CreateWithdrawal)
 				income.GET("/available", incomeHandler.GetAvailableIncome)
 			} else {
 				income.GET("/stats", func(c *gin.Context) {
@@ -424,14 +532,21 @@ func SetupRoutes(r *gin.Engine, authHandler *handlers.AuthHandler, userHandler *
 		payments := v1.Group("/payments")
 		{
 			if paymentHandler != nil {
-				payments.POST("/orders", paymentHandler.CreatePaymentOrder)
-				payments.GET("/orders/:order_id/status", paymentHandler.QueryPaymentStatus)
+				payments.POST("/orders", middleware.RateLimit(paymentOrderRateLimit), paymentHandler.CreatePaymentOrder)
+				payments.GET("/orders/:order_id/status", permissionChecker.MarkAdmin(), paymentHandler.QueryPaymentStatus)
 				payments.GET("/history", paymentHandler.ListPaymentHistory)
-				payments.POST("/refunds", paymentHandler.CreateRefund)
-				payments.GET("/refunds/:refund_id/status", paymentHandler.QueryRefundStatus)
+				payments.POST("/refunds", permissionChecker.Require(middleware.PermAdmin), paymentHandler.CreateRefund)
+				payments.GET("/refunds/:refund_id/status", permissionChecker.MarkAdmin(), paymentHandler.QueryRefundStatus)
 				payments.GET("/methods", paymentHandler.ListPaymentMethods)
 				payments.GET("/stats", paymentHandler.GetPaymentStats)
+				payments.GET("/invoices", paymentHandler.ListInvoices)
+				payments.GET("/invoices/:invoice_id", paymentHandler.GetInvoice)
+				payments.GET("/invoices/:invoice_id/download", paymentHandler.DownloadInvoice)
 				payments.POST("/webhook/:gateway", paymentHandler.ProcessPaymentWebhook)
+				// 沙箱模拟支付可将任意订单标记为已支付，仅在启用沙箱时注册且仅管理员可用
+				if paymentHandler.SandboxEnabled() {
+					payments.POST("/sandbox/charges/:charge_id/simulate", permissionChecker.Require(middleware.PermAdmin), paymentHandler.SimulateSandboxPayment)
+				}
 			} else {
 				payments.POST("/orders", func(c *gin.Context) {
 					c.JSON(200, gin.H{"message": "Create payment order - TODO"})
@@ -484,6 +599,32 @@ func SetupRoutes(r *gin.Engine, authHandler *handlers.AuthHandler, userHandler *
 			}
 		}
 
+		// 管理员路由
+		admin := v1.Group("/admin")
+		{
+			if permissionChecker != nil {
+				admin.Use(permissionChecker.Require(middleware.PermAdmin))
+			}
+			if paymentHandler != nil {
+				admin.GET("/payments/webhook-events", paymentHandler.ListWebhookEvents)
+				admin.POST("/payments/webhook-events/:event_id/replay", paymentHandler.ReplayWebhookEvent)
+			}
+			if incomeHandler != nil {
+				admin.GET("/ledger/reconciliation", incomeHandler.GetLedgerReconciliation)
+				admin.GET("/fee-rules", incomeHandler.ListFeeRules)
+				admin.POST("/fee-rules", incomeHandler.CreateFeeRule)
+				admin.PUT("/fee-rules/:rule_id", incomeHandler.UpdateFeeRule)
+				admin.PUT("/mentors/:mentor_id/tier", incomeHandler.UpdateMentorTier)
+				admin.GET("/withdrawals", incomeHandler.ListAdminWithdrawals)
+				admin.POST("/withdrawals/:withdrawal_id/approve", incomeHandler.ApproveWithdrawal)
+				admin.POST("/withdrawals/:withdrawal_id/reject", incomeHandler.RejectWithdrawal)
+				admin.GET("/payout-batches", incomeHandler.ListPayoutBatches)
+				admin.POST("/payout-batches", incomeHandler.CreatePayoutBatch)
+				admin.GET("/payout-batches/:batch_id/export", incomeHandler.ExportPayoutBatch)
+				admin.POST("/payout-batches/:batch_id/import", incomeHandler.ImportPayoutResults)
+			}
+		}
+
 		// 统计相关路由
 		statsGroup := v1.Group("/stats")
 		{
@@ -502,6 +643,7 @@ func SetupRoutes(r *gin.Engine, authHandler *handlers.AuthHandler, userHandler *
 			if chatHandler != nil {
 				chatGroup.GET("/online-users", chatHandler.GetOnlineUsers)
 				chatGroup.GET("/messages", chatHandler.GetChatMessages)
+				chatGroup.GET("/conversations", chatHandler.GetConversations)
 			} else {
 				chatGroup.GET("/online-users", func(c *gin.Context) {
 					c.JSON(200, gin.H{"message": "Get online users - TODO"})
@@ -509,6 +651,9 @@ func SetupRoutes(r *gin.Engine, authHandler *handlers.AuthHandler, userHandler *
 				chatGroup.GET("/messages", func(c *gin.Context) {
 					c.JSON(200, gin.H{"message": "Get chat messages - TODO"})
 				})
+				chatGroup.GET("/conversations", func(c *gin.Context) {
+					c.JSON(200, gin.H{"message": "Get chat conversations - TODO"})
+				})
 			}
 		}
 
@@ -516,11 +661,16 @@ func SetupRoutes(r *gin.Engine, authHandler *handlers.AuthHandler, userHandler *
 		if websocketHandler != nil {
 			r.GET("/ws", websocketHandler.HandleWebSocket)
 		}
+		// 自建会议信令，凭入会凭证访问
+		if meetingHandler != nil {
+			r.GET("/ws/meetings/:room_id", meetingHandler.HandleSignaling)
+		}
 
 		// 学生管理路由
 		students := v1.Group("/students")
 		{
 			if studentHandler != nil {
+				students.Use(permissionChecker.Require(middleware.PermVerifiedMaster))
 				students.GET("", studentHandler.GetStudents)
 				students.GET("/stats", studentHandler.GetStudentStats)
 				students.GET("/:student_id", studentHandler.GetStudentByID)
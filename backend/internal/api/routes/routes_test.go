@@ -0,0 +1,109 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"master-guide-backend/internal/api/handlers"
+	"master-guide-backend/internal/api/middleware"
+	"master-guide-backend/internal/model"
+	"master-guide-backend/internal/repository"
+	"master-guide-backend/internal/service"
+	"master-guide-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testJWTSecret = "test-secret"
+
+// stubPaymentService 只实现沙箱相关方法的支付服务
+type stubPaymentService struct {
+	service.PaymentService
+	sandbox   bool
+	simulated int
+}
+
+func (s *stubPaymentService) SandboxEnabled() bool {
+	return s.sandbox
+}
+
+func (s *stubPaymentService) SimulateSandboxPayment(ctx context.Context, chargeID, status string) (*model.PaymentWebhookResponse, error) {
+	s.simulated++
+	return &model.PaymentWebhookResponse{Processed: true, Status: "completed"}, nil
+}
+
+// stubIdentityRepository 按ID返回已激活的身份
+type stubIdentityRepository struct {
+	repository.IdentityRepository
+	identities map[string]*model.UserIdentity
+}
+
+func (r *stubIdentityRepository) GetByID(ctx context.Context, id string) (*model.UserIdentity, error) {
+	identity, ok := r.identities[id]
+	if !ok {
+		return nil, errors.New("identity not found")
+	}
+	return identity, nil
+}
+
+func newSandboxTestRouter(t *testing.T, paymentService service.PaymentService) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	identities := &stubIdentityRepository{identities: map[string]*model.UserIdentity{}}
+	for _, userID := range []string{"admin-1", "student-1"} {
+		identity := &model.UserIdentity{UserID: userID, IdentityType: "apprentice", Status: "active"}
+		identity.ID = "identity-" + userID
+		identities.identities[identity.ID] = identity
+	}
+	permissionChecker := middleware.NewPermissionChecker(nil, identities, nil, []string{"admin-1"})
+
+	r := gin.New()
+	SetupRoutes(r, testJWTSecret, nil, permissionChecker, &handlers.AuthHandler{}, &handlers.UserHandler{}, handlers.NewMentorHandler(nil, nil), &handlers.CourseHandler{}, &handlers.AppointmentHandler{}, &handlers.CircleHandler{}, &handlers.PostHandler{}, &handlers.CommentHandler{}, &handlers.ReviewHandler{}, &handlers.NotificationHandler{}, &handlers.LearningHandler{}, &handlers.StudentHandler{}, &handlers.IncomeHandler{}, handlers.NewPaymentHandler(paymentService, nil), &handlers.UploadHandler{}, &handlers.SearchHandler{}, &handlers.StatsHandler{}, &handlers.ChatHandler{}, &handlers.WebSocketHandler{}, &handlers.CalendarHandler{}, &handlers.MeetingHandler{})
+	return r
+}
+
+func simulateSandboxPayment(t *testing.T, r *gin.Engine, userID string) int {
+	t.Helper()
+	token, err := utils.GenerateToken(userID, "identity-"+userID, "apprentice", "session-"+userID, testJWTSecret, 1)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/payments/sandbox/charges/SBX_1/simulate", strings.NewReader(`{"status":"succeeded"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestSandboxSimulateRoute(t *testing.T) {
+	tests := []struct {
+		name          string
+		sandbox       bool
+		userID        string
+		wantStatus    int
+		wantSimulated int
+	}{
+		{"sandbox disabled", false, "admin-1", http.StatusNotFound, 0},
+		{"non-admin forbidden", true, "student-1", http.StatusForbidden, 0},
+		{"admin allowed", true, "admin-1", http.StatusOK, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paymentService := &stubPaymentService{sandbox: tt.sandbox}
+			r := newSandboxTestRouter(t, paymentService)
+			if status := simulateSandboxPayment(t, r, tt.userID); status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if paymentService.simulated != tt.wantSimulated {
+				t.Fatalf("simulated %d times, want %d", paymentService.simulated, tt.wantSimulated)
+			}
+		})
+	}
+}
@@ -1,12 +1,26 @@
 package container
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
 	"master-guide-backend/internal/api/handlers"
+	"master-guide-backend/internal/api/middleware"
+	"master-guide-backend/internal/gateway"
+	"master-guide-backend/internal/meeting"
 	"master-guide-backend/internal/repository"
 	"master-guide-backend/internal/service"
 	"master-guide-backend/internal/utils"
+	"master-guide-backend/pkg/cache"
 	"master-guide-backend/pkg/config"
+	"master-guide-backend/pkg/logger"
+	"master-guide-backend/pkg/scheduler"
+	"master-guide-backend/pkg/sender"
 
+	"github.com/go-redis/redis/v8"
 	"gorm.io/gorm"
 )
 
@@ -35,6 +49,9 @@ type Container struct {
 	SearchRepository        repository.SearchRepository
 	StatsRepository         repository.StatsRepository
 	ChatRepository          repository.ChatRepository
+	SessionRepository       repository.SessionRepository
+	PasswordResetRepository repository.PasswordResetRepository
+	AuditRepository         repository.AuditRepository
 
 	// Services
 	AuthService         service.AuthService
@@ -55,6 +72,13 @@ type Container struct {
 	SearchService       service.SearchService
 	StatsService        service.StatsService
 	ChatService         service.ChatService
+	VerificationService service.VerificationService
+
+	// Middlewares
+	PermissionChecker *middleware.PermissionChecker
+
+	// Scheduler
+	Scheduler *scheduler.Scheduler
 
 	// Handlers
 	AuthHandler         *handlers.AuthHandler
@@ -76,10 +100,12 @@ type Container struct {
 	StatsHandler        *handlers.StatsHandler
 	ChatHandler         *handlers.ChatHandler
 	WebSocketHandler    *handlers.WebSocketHandler
+	CalendarHandler     *handlers.CalendarHandler
+	MeetingHandler      *handlers.MeetingHandler
 }
 
-// NewContainer 创建依赖注入容器
-func NewContainer(db *gorm.DB, cfg *config.Config) *Container {
+// NewContainer 创建依赖注入容器，配置要求的外部依赖不可用时返回错误
+func NewContainer(db *gorm.DB, cfg *config.Config) (*Container, error) {
 	// 初始化Repositories
 	userRepo := repository.NewUserRepository(db)
 	identityRepo := repository.NewIdentityRepository(db)
@@ -89,6 +115,8 @@ func NewContainer(db *gorm.DB, cfg *config.Config) *Container {
 	courseRepo := repository.NewCourseRepository(db)
 	courseContentRepo := repository.NewCourseContentRepository(db)
 	appointmentRepo := repository.NewAppointmentRepository(db)
+	appointmentPackageRepo := repository.NewAppointmentPackageRepository(db)
+	availabilityRepo := repository.NewAvailabilityRepository(db)
 	circleRepo := repository.NewCircleRepository(db)
 	postRepo := repository.NewPostRepository(db)
 	commentRepo := repository.NewCommentRepository(db)
@@ -103,38 +131,92 @@ func NewContainer(db *gorm.DB, cfg *config.Config) *Container {
 	searchRepo := repository.NewSearchRepository(db)
 	statsRepo := repository.NewStatsRepository(db)
 	chatRepo := repository.NewChatRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	passwordResetRepo := repository.NewPasswordResetRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	fulfillmentRepo := repository.NewFulfillmentRepository(db)
+	calendarRepo := repository.NewCalendarRepository(db)
+	meetingRepo := repository.NewMeetingRepository(db)
+	ledgerRepo := repository.NewLedgerRepository(db)
+	feeRuleRepo := repository.NewFeeRuleRepository(db)
+	withdrawalRepo := repository.NewWithdrawalRepository(db)
+	incomeReportRepo := repository.NewIncomeReportRepository(db)
+	invoiceRepo := repository.NewInvoiceRepository(db)
+
+	// 初始化邮件/短信发送器
+	msgSender := sender.New(&sender.Config{
+		Driver:   cfg.Sender.Driver,
+		FilePath: cfg.Sender.FilePath,
+	})
 
 	// 初始化Services
-	authService := service.NewAuthService(userRepo, identityRepo, cfg.JWT.Secret, cfg.JWT.ExpireHours)
+	authService := service.NewAuthService(userRepo, identityRepo, sessionRepo, passwordResetRepo, auditRepo, msgSender, cfg.JWT.Secret, cfg.JWT.ExpireHours, cfg.JWT.RefreshExpireHours, cfg.PasswordReset, cfg.LoginProtection)
+	verificationService := service.NewVerificationService(userRepo, msgSender, cfg.Verification)
 	userService := service.NewUserService(userRepo, identityRepo, profileRepo, preferencesRepo, learningRepo, mentorRepo, appointmentRepo)
 	mentorService := service.NewMentorService(mentorRepo)
-	courseService := service.NewCourseService(courseRepo, courseContentRepo)
-	appointmentService := service.NewAppointmentService(appointmentRepo, mentorRepo)
+	meetingProvider, signalingHub, err := newMeetingProvider(&cfg.Meeting)
+	if err != nil {
+		return nil, err
+	}
+	meetingService := service.NewMeetingService(meetingRepo, appointmentRepo, meetingProvider, cfg.Meeting)
+	feeRuleService := service.NewFeeRuleService(feeRuleRepo, mentorRepo, cfg.Payment.PlatformFeeRate)
+	invoiceService := service.NewInvoiceService(invoiceRepo, cfg.Invoice)
+	fulfillmentService := service.NewFulfillmentService(fulfillmentRepo, paymentRepo, courseRepo, appointmentRepo, appointmentPackageRepo, meetingService, feeRuleService, invoiceService)
+	paymentGateways, err := newPaymentGateways(&cfg.Payment)
+	if err != nil {
+		return nil, err
+	}
+	paymentService := service.NewPaymentService(paymentRepo, paymentGateways, fulfillmentService)
+	courseService := service.NewCourseService(courseRepo, courseContentRepo, paymentService, cfg.RefundPolicy)
+	availabilityService := service.NewAvailabilityService(availabilityRepo, mentorRepo, appointmentRepo)
+	appointmentService := service.NewAppointmentService(appointmentRepo, mentorRepo, appointmentPackageRepo, availabilityService, paymentService, meetingService, cfg.RefundPolicy)
+	appointmentPackageService := service.NewAppointmentPackageService(appointmentPackageRepo, mentorRepo, paymentService)
 	circleService := service.NewCircleService(circleRepo)
 	postService := service.NewPostService(postRepo)
 	commentService := service.NewCommentService(commentRepo)
 	reviewService := service.NewReviewService(reviewRepo)
 	notificationService := service.NewNotificationService(notificationRepo)
+	calendarService := service.NewCalendarService(calendarRepo, appointmentRepo, notificationService, cfg.Calendar)
 	learningService := service.NewLearningService(learningRepo)
-	studentService := service.NewStudentService(studentRepo, userRepo, identityRepo, appointmentRepo, messageRepo)
-	incomeService := service.NewIncomeService(incomeRepo)
-	paymentService := service.NewPaymentService(paymentRepo)
+	studentService := service.NewStudentService(studentRepo, userRepo, identityRepo, appointmentRepo, messageRepo, mentorRepo)
+	bankAccountCipher, err := newBankAccountCipher(&cfg.Withdrawal)
+	if err != nil {
+		return nil, err
+	}
+	incomeService := service.NewIncomeService(incomeRepo, mentorRepo, feeRuleService, notificationService, bankAccountCipher, cfg.Settlement)
+	withdrawalService := service.NewWithdrawalService(withdrawalRepo, mentorRepo, notificationService, bankAccountCipher, cfg.Withdrawal.PayoutBatchSize)
+	if err := checkIncomeReportConfig(&cfg.IncomeReport); err != nil {
+		return nil, err
+	}
+	incomeReportService := service.NewIncomeReportService(incomeReportRepo, mentorRepo, notificationService, cfg.IncomeReport)
+	ledgerService := service.NewLedgerService(ledgerRepo)
 	uploadService := service.NewUploadService(uploadRepo)
 	searchService := service.NewSearchService(searchRepo)
 	statsService := service.NewStatsService(statsRepo)
 
 	// 创建 WebSocket 管理器
-	websocketMgr := utils.NewWebSocketManager()
+	websocketBackplane, websocketPresence, err := newWebSocketBackplane(&cfg.WebSocket, cache.GetClient())
+	if err != nil {
+		return nil, err
+	}
+	websocketMgr := utils.NewWebSocketManager(middleware.NewTokenAuthenticator(cfg.JWT.Secret, authService), websocketBackplane, websocketPresence, cfg.WebSocket)
 	go websocketMgr.Start()
 
-	chatService := service.NewChatService(chatRepo, websocketMgr)
+	chatService := service.NewChatService(chatRepo, circleRepo, websocketMgr)
+
+	// 初始化中间件
+	permissionChecker := middleware.NewPermissionChecker(userRepo, identityRepo, mentorRepo, cfg.Admin.UserIDs)
+	websocketOriginChecker := middleware.WebSocketOriginChecker(cfg.CORS)
+
+	// 初始化定时任务
+	jobScheduler := newScheduler(db, &cfg.Scheduler, paymentService, invoiceService, calendarService, meetingService, incomeService, incomeReportService)
 
 	// 初始化Handlers
 	authHandler := handlers.NewAuthHandler(authService)
-	userHandler := handlers.NewUserHandler(userService)
-	mentorHandler := handlers.NewMentorHandler(mentorService)
+	userHandler := handlers.NewUserHandler(userService, verificationService)
+	mentorHandler := handlers.NewMentorHandler(mentorService, availabilityService)
 	courseHandler := handlers.NewCourseHandler(courseService)
-	appointmentHandler := handlers.NewAppointmentHandler(appointmentService)
+	appointmentHandler := handlers.NewAppointmentHandler(appointmentService, appointmentPackageService)
 	circleHandler := handlers.NewCircleHandler(circleService)
 	postHandler := handlers.NewPostHandler(postService)
 	commentHandler := handlers.NewCommentHandler(commentService)
@@ -142,13 +224,15 @@ func NewContainer(db *gorm.DB, cfg *config.Config) *Container {
 	notificationHandler := handlers.NewNotificationHandler(notificationService)
 	learningHandler := handlers.NewLearningHandler(learningService)
 	studentHandler := handlers.NewStudentHandler(studentService)
-	incomeHandler := handlers.NewIncomeHandler(incomeService)
-	paymentHandler := handlers.NewPaymentHandler(paymentService)
+	incomeHandler := handlers.NewIncomeHandler(incomeService, ledgerService, feeRuleService, withdrawalService, incomeReportService)
+	paymentHandler := handlers.NewPaymentHandler(paymentService, invoiceService)
 	uploadHandler := handlers.NewUploadHandler(uploadService)
 	searchHandler := handlers.NewSearchHandler(searchService)
 	statsHandler := handlers.NewStatsHandler(statsService)
 	chatHandler := handlers.NewChatHandler(chatService)
-	websocketHandler := handlers.NewWebSocketHandler(websocketMgr)
+	websocketHandler := handlers.NewWebSocketHandler(websocketMgr, websocketOriginChecker)
+	calendarHandler := handlers.NewCalendarHandler(calendarService)
+	meetingHandler := handlers.NewMeetingHandler(meetingService, signalingHub, websocketOriginChecker)
 
 	return &Container{
 		UserRepository:          userRepo,
@@ -173,6 +257,9 @@ func NewContainer(db *gorm.DB, cfg *config.Config) *Container {
 		SearchRepository:        searchRepo,
 		StatsRepository:         statsRepo,
 		ChatRepository:          chatRepo,
+		SessionRepository:       sessionRepo,
+		PasswordResetRepository: passwordResetRepo,
+		AuditRepository:         auditRepo,
 		AuthService:             authService,
 		UserService:             userService,
 		MentorService:           mentorService,
@@ -191,6 +278,9 @@ func NewContainer(db *gorm.DB, cfg *config.Config) *Container {
 		SearchService:           searchService,
 		StatsService:            statsService,
 		ChatService:             chatService,
+		VerificationService:     verificationService,
+		PermissionChecker:       permissionChecker,
+		Scheduler:               jobScheduler,
 		AuthHandler:             authHandler,
 		UserHandler:             userHandler,
 		MentorHandler:           mentorHandler,
@@ -210,5 +300,234 @@ func NewContainer(db *gorm.DB, cfg *config.Config) *Container {
 		StatsHandler:            statsHandler,
 		ChatHandler:             chatHandler,
 		WebSocketHandler:        websocketHandler,
+		CalendarHandler:         calendarHandler,
+		MeetingHandler:          meetingHandler,
+	}, nil
+}
+
+// newPaymentGateways 根据配置注册支付网关，启用沙箱但未配置回调签名密钥时返回错误
+// 已启用的真实网关优先，其余在沙箱模式下声明的支付方式由沙箱网关代为处理
+func newPaymentGateways(cfg *config.PaymentConfig) (*gateway.Registry, error) {
+	registry := gateway.NewRegistry()
+	notifyURL := func(name string) string {
+		return strings.TrimRight(cfg.APIBaseURL, "/") + "/payments/webhook/" + name
+	}
+
+	if cfg.Alipay.Enabled {
+		gw, err := gateway.NewAlipayGateway(gateway.AlipayConfig{
+			AppID:      cfg.Alipay.AppID,
+			PrivateKey: cfg.Alipay.PrivateKey,
+			PublicKey:  cfg.Alipay.PublicKey,
+			GatewayURL: cfg.Alipay.GatewayURL,
+			NotifyURL:  notifyURL(gateway.AlipayName),
+		})
+		if err != nil {
+			logger.Warn("支付宝网关初始化失败", logger.String("error", err.Error()))
+		} else {
+			registry.Register("alipay", gw)
+		}
+	}
+
+	if cfg.Wechat.Enabled {
+		gw, err := gateway.NewWechatGateway(gateway.WechatConfig{
+			AppID:      cfg.Wechat.AppID,
+			MchID:      cfg.Wechat.MchID,
+			APIKey:     cfg.Wechat.APIKey,
+			CertFile:   cfg.Wechat.CertFile,
+			KeyFile:    cfg.Wechat.KeyFile,
+			GatewayURL: cfg.Wechat.GatewayURL,
+			NotifyURL:  notifyURL(gateway.WechatName),
+		})
+		if err != nil {
+			logger.Warn("微信支付网关初始化失败", logger.String("error", err.Error()))
+		} else {
+			registry.Register("wechat", gw)
+		}
+	}
+
+	if cfg.Sandbox.Enabled {
+		// 空密钥签名的回调可被任何人伪造，启用沙箱时必须配置密钥
+		sandbox, err := gateway.NewSandboxGateway(cfg.Sandbox.Secret, strings.TrimRight(cfg.APIBaseURL, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("沙箱网关初始化失败: %w", err)
+		}
+		for _, method := range cfg.Sandbox.Methods {
+			// 真实支付方式不得由沙箱代为处理，否则未配置真实网关时学生可免费完成支付
+			if method == gateway.AlipayName || method == gateway.WechatName {
+				logger.Warn("沙箱网关不能处理真实支付方式，已忽略", logger.String("method", method))
+				continue
+			}
+			if _, err := registry.ForMethod(method); err != nil {
+				registry.Register(method, sandbox)
+			}
+		}
+	}
+
+	return registry, nil
+}
+
+// newMeetingProvider 创建视频会议服务，自建会议服务同时返回其信令中继；未配置时不创建会议室，自建会议服务缺少签名密钥时返回错误
+func newMeetingProvider(cfg *config.MeetingConfig) (meeting.MeetingProvider, *meeting.SignalingHub, error) {
+	switch cfg.Provider {
+	case meeting.SelfHostedName:
+		if cfg.SelfHosted.Secret == "" {
+			return nil, nil, errors.New("未配置自建会议服务入会凭证签名密钥，请设置环境变量 MEETING_SELFHOSTED_SECRET")
+		}
+		provider, err := meeting.NewSelfHostedProvider(meeting.SelfHostedConfig{
+			Secret:       cfg.SelfHosted.Secret,
+			JoinPageURL:  cfg.SelfHosted.JoinPageURL,
+			SignalingURL: cfg.SelfHosted.SignalingURL,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("自建会议服务初始化失败: %w", err)
+		}
+		return provider, provider.Hub(), nil
+	case "":
+		return nil, nil, nil
+	default:
+		logger.Warn("不支持的会议服务", logger.String("provider", cfg.Provider))
+		return nil, nil, nil
+	}
+}
+
+// newWebSocketBackplane 创建 WebSocket 背板和在线状态登记，多实例部署使用 Redis，未配置时使用进程内实现
+// 显式配置为 redis 而 Redis 不可用时返回错误，避免各实例静默退化为互不相通的进程内实现
+func newWebSocketBackplane(cfg *config.WebSocketConfig, client *redis.Client) (utils.WebSocketBackplane, utils.WebSocketPresence, error) {
+	presenceTTL := time.Duration(cfg.PresenceTTL) * time.Second
+	if presenceTTL <= 0 {
+		presenceTTL = 45 * time.Second
+	}
+
+	switch cfg.Backplane {
+	case "redis":
+		if client == nil {
+			return nil, nil, errors.New("WebSocket 背板配置为 redis，但 Redis 不可用")
+		}
+		return utils.NewRedisBackplane(client), utils.NewRedisPresence(client, presenceTTL), nil
+	case "", "memory":
+		return utils.NewMemoryBackplane(), utils.NewMemoryPresence(presenceTTL), nil
+	default:
+		return nil, nil, fmt.Errorf("不支持的 WebSocket 背板: %s", cfg.Backplane)
+	}
+}
+
+// newScheduler 注册周期任务，未启用时返回不含任务的调度器
+func newScheduler(db *gorm.DB, cfg *config.SchedulerConfig, paymentService service.PaymentService, invoiceService service.InvoiceService, calendarService service.CalendarService, meetingService service.MeetingService, incomeService service.IncomeService, incomeReportService service.IncomeReportService) *scheduler.Scheduler {
+	jobs := scheduler.New(scheduler.NewDBLocker(db))
+	if !cfg.Enabled {
+		return jobs
+	}
+
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	interval := func(seconds, fallback int) time.Duration {
+		if seconds <= 0 {
+			seconds = fallback
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	jobs.Register(scheduler.Job{
+		Name:     "payment.expire_orders",
+		Interval: interval(cfg.PaymentExpireInterval, 60),
+		Run: func(ctx context.Context) error {
+			_, err := paymentService.ExpirePendingOrders(ctx, batchSize)
+			return err
+		},
+	})
+	jobs.Register(scheduler.Job{
+		Name:     "payment.retry_fulfillment",
+		Interval: interval(cfg.FulfillmentRetryInterval, 300),
+		Run: func(ctx context.Context) error {
+			_, err := paymentService.RetryUnfulfilledOrders(ctx, batchSize)
+			return err
+		},
+	})
+	jobs.Register(scheduler.Job{
+		Name:     "payment.retry_refund_reversal",
+		Interval: interval(cfg.FulfillmentRetryInterval, 300),
+		Run: func(ctx context.Context) error {
+			_, err := paymentService.RetryRefundReversals(ctx, batchSize)
+			return err
+		},
+	})
+	jobs.Register(scheduler.Job{
+		Name:     "payment.retry_refunds",
+		Interval: interval(cfg.FulfillmentRetryInterval, 300),
+		Run: func(ctx context.Context) error {
+			_, err := paymentService.RetryFailedRefunds(ctx, batchSize)
+			return err
+		},
+	})
+	jobs.Register(scheduler.Job{
+		Name:     "payment.issue_invoices",
+		Interval: interval(cfg.FulfillmentRetryInterval, 300),
+		Run: func(ctx context.Context) error {
+			_, err := invoiceService.IssueMissingInvoices(ctx, batchSize)
+			return err
+		},
+	})
+	jobs.Register(scheduler.Job{
+		Name:     "appointment.send_reminders",
+		Interval: interval(cfg.AppointmentReminderInterval, 60),
+		Run: func(ctx context.Context) error {
+			_, err := calendarService.SendDueReminders(ctx, batchSize)
+			return err
+		},
+	})
+	jobs.Register(scheduler.Job{
+		Name:     "meeting.provision_rooms",
+		Interval: interval(cfg.MeetingProvisionInterval, 300),
+		Run: func(ctx context.Context) error {
+			_, err := meetingService.ProvisionPendingRooms(ctx, batchSize)
+			return err
+		},
+	})
+	jobs.Register(scheduler.Job{
+		Name:     "income.settle_matured",
+		Interval: interval(cfg.IncomeSettlementInterval, 3600),
+		Run: func(ctx context.Context) error {
+			_, err := incomeService.SettleMaturedIncome(ctx, batchSize)
+			return err
+		},
+	})
+	jobs.Register(scheduler.Job{
+		Name:     "income.generate_reports",
+		Interval: interval(cfg.IncomeReportInterval, 60),
+		Run: func(ctx context.Context) error {
+			_, err := incomeReportService.GenerateQueuedReports(ctx, batchSize)
+			return err
+		},
+	})
+	jobs.Register(scheduler.Job{
+		Name:     "income.cleanup_reports",
+		Interval: interval(cfg.IncomeReportInterval, 60),
+		Run: func(ctx context.Context) error {
+			_, err := incomeReportService.CleanupExpiredReports(ctx, batchSize)
+			return err
+		},
+	})
+	return jobs
+}
+
+// checkIncomeReportConfig 检查收入报告配置，下载地址为公开接口，签名密钥未配置时返回错误
+func checkIncomeReportConfig(cfg *config.IncomeReportConfig) error {
+	if cfg.SigningSecret == "" {
+		return errors.New("未配置收入报告下载地址签名密钥，请设置环境变量 INCOME_REPORT_SIGNING_SECRET")
+	}
+	return nil
+}
+
+// newBankAccountCipher 根据配置创建银行账号加密器，密钥未配置或无效时返回错误
+func newBankAccountCipher(cfg *config.WithdrawalConfig) (*utils.FieldCipher, error) {
+	if cfg.BankAccountKey == "" {
+		return nil, errors.New("未配置银行账号加密密钥，请设置环境变量 WITHDRAWAL_BANK_ACCOUNT_KEY")
+	}
+	cipher, err := utils.NewFieldCipher(cfg.BankAccountKey)
+	if err != nil {
+		return nil, fmt.Errorf("银行账号加密密钥无效: %w", err)
 	}
+	return cipher, nil
 }
@@ -0,0 +1,181 @@
+package container
+
+import (
+	"fmt"
+	"testing"
+
+	"master-guide-backend/internal/gateway"
+	"master-guide-backend/pkg/config"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestNewPaymentGatewaysSandbox(t *testing.T) {
+	tests := []struct {
+		name        string
+		sandbox     config.SandboxPaymentConfig
+		wantMethods []string
+		wantAbsent  []string
+		wantEnabled bool
+		wantErr     bool
+	}{
+		{
+			name:       "disabled",
+			sandbox:    config.SandboxPaymentConfig{Enabled: false, Methods: []string{"sandbox"}},
+			wantAbsent: []string{"sandbox", "alipay", "wechat"},
+		},
+		{
+			name:    "enabled without secret",
+			sandbox: config.SandboxPaymentConfig{Enabled: true, Methods: []string{"sandbox"}},
+			wantErr: true,
+		},
+		{
+			name:        "enabled",
+			sandbox:     config.SandboxPaymentConfig{Enabled: true, Secret: "secret", Methods: []string{"sandbox"}},
+			wantMethods: []string{"sandbox"},
+			wantAbsent:  []string{"alipay", "wechat"},
+			wantEnabled: true,
+		},
+		{
+			name:        "real methods are never mapped to sandbox",
+			sandbox:     config.SandboxPaymentConfig{Enabled: true, Secret: "secret", Methods: []string{"sandbox", "alipay", "wechat"}},
+			wantMethods: []string{"sandbox"},
+			wantAbsent:  []string{"alipay", "wechat"},
+			wantEnabled: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry, err := newPaymentGateways(&config.PaymentConfig{APIBaseURL: "http://localhost:8080/api/v1", Sandbox: tt.sandbox})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("newPaymentGateways succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newPaymentGateways: %v", err)
+			}
+			if _, ok := registry.Sandbox(); ok != tt.wantEnabled {
+				t.Fatalf("Sandbox() enabled = %v, want %v", ok, tt.wantEnabled)
+			}
+			for _, method := range tt.wantMethods {
+				gw, err := registry.ForMethod(method)
+				if err != nil || gw.Name() != gateway.SandboxName {
+					t.Errorf("ForMethod(%q) = %v, %v; want sandbox gateway", method, gw, err)
+				}
+			}
+			for _, method := range tt.wantAbsent {
+				if gw, err := registry.ForMethod(method); err == nil {
+					t.Errorf("ForMethod(%q) = %s, want no gateway", method, gw.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestNewWebSocketBackplane(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "localhost:0"})
+	defer client.Close()
+
+	tests := []struct {
+		name          string
+		backplane     string
+		client        *redis.Client
+		wantBackplane string
+		wantErr       bool
+	}{
+		{name: "default", backplane: "", wantBackplane: "*utils.memoryBackplane"},
+		{name: "memory", backplane: "memory", client: client, wantBackplane: "*utils.memoryBackplane"},
+		{name: "redis", backplane: "redis", client: client, wantBackplane: "*utils.redisBackplane"},
+		{name: "redis unavailable", backplane: "redis", wantErr: true},
+		{name: "unknown", backplane: "kafka", client: client, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backplane, presence, err := newWebSocketBackplane(&config.WebSocketConfig{Backplane: tt.backplane}, tt.client)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("newWebSocketBackplane(%q) = %T, want error", tt.backplane, backplane)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newWebSocketBackplane(%q): %v", tt.backplane, err)
+			}
+			if got := fmt.Sprintf("%T", backplane); got != tt.wantBackplane {
+				t.Fatalf("backplane = %s, want %s", got, tt.wantBackplane)
+			}
+			if presence == nil {
+				t.Fatalf("presence is nil")
+			}
+		})
+	}
+}
+
+func TestNewBankAccountCipher(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		wantErr bool
+	}{
+		{name: "missing", key: "", wantErr: true},
+		{name: "invalid", key: "not-a-key", wantErr: true},
+		{name: "valid", key: "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cipher, err := newBankAccountCipher(&config.WithdrawalConfig{BankAccountKey: tt.key})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("newBankAccountCipher(%q) succeeded, want error", tt.key)
+				}
+				return
+			}
+			if err != nil || cipher == nil {
+				t.Fatalf("newBankAccountCipher(%q) = %v, %v", tt.key, cipher, err)
+			}
+		})
+	}
+}
+
+func TestCheckIncomeReportConfig(t *testing.T) {
+	if err := checkIncomeReportConfig(&config.IncomeReportConfig{}); err == nil {
+		t.Fatal("checkIncomeReportConfig without signing secret succeeded, want error")
+	}
+	if err := checkIncomeReportConfig(&config.IncomeReportConfig{SigningSecret: "secret"}); err != nil {
+		t.Fatalf("checkIncomeReportConfig with signing secret: %v", err)
+	}
+}
+
+func TestNewMeetingProvider(t *testing.T) {
+	tests := []struct {
+		name         string
+		cfg          config.MeetingConfig
+		wantProvider bool
+		wantErr      bool
+	}{
+		{name: "disabled", cfg: config.MeetingConfig{}},
+		{name: "selfhosted without secret", cfg: config.MeetingConfig{Provider: "selfhosted"}, wantErr: true},
+		{name: "selfhosted", cfg: config.MeetingConfig{Provider: "selfhosted", SelfHosted: config.SelfHostedMeetingConfig{Secret: "secret"}}, wantProvider: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, hub, err := newMeetingProvider(&tt.cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("newMeetingProvider succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newMeetingProvider: %v", err)
+			}
+			if (provider != nil) != tt.wantProvider || (hub != nil) != tt.wantProvider {
+				t.Fatalf("newMeetingProvider = %v, %v, want provider %v", provider, hub, tt.wantProvider)
+			}
+		})
+	}
+}
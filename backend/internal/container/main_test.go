@@ -0,0 +1,15 @@
+package container
+
+import (
+	"os"
+	"testing"
+
+	"master-guide-backend/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	if err := logger.Init("error", "json", "stdout", ""); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
@@ -0,0 +1,318 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AlipayName 支付宝网关名称
+const AlipayName = "alipay"
+
+// alipayDefaultGatewayURL 支付宝开放平台网关
+const alipayDefaultGatewayURL = "https://openapi.alipay.com/gateway.do"
+
+// alipayCurrency 当面付仅支持人民币结算
+const alipayCurrency = "CNY"
+
+// AlipayConfig 支付宝配置
+type AlipayConfig struct {
+	AppID      string
+	PrivateKey string // 应用私钥
+	PublicKey  string // 支付宝公钥
+	GatewayURL string
+	NotifyURL  string
+}
+
+// AlipayGateway 支付宝当面付（扫码）网关，请求与回调均使用RSA2签名
+type AlipayGateway struct {
+	config AlipayConfig
+	signer *RSASigner
+	client *http.Client
+}
+
+// NewAlipayGateway 创建支付宝网关
+func NewAlipayGateway(config AlipayConfig) (*AlipayGateway, error) {
+	if config.AppID == "" {
+		return nil, errors.New("alipay app_id is required")
+	}
+	signer, err := NewRSASigner(config.PrivateKey, config.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("alipay key: %w", err)
+	}
+	if config.GatewayURL == "" {
+		config.GatewayURL = alipayDefaultGatewayURL
+	}
+	return &AlipayGateway{
+		config: config,
+		signer: signer,
+		client: &http.Client{Timeout: 15 * time.Second},
+	}, nil
+}
+
+// Name 网关名称
+func (g *AlipayGateway) Name() string {
+	return AlipayName
+}
+
+// CreateCharge 预下单（alipay.trade.precreate），返回二维码内容
+func (g *AlipayGateway) CreateCharge(ctx context.Context, req *ChargeRequest) (*ChargeResult, error) {
+	biz := map[string]string{
+		"out_trade_no": req.OrderID,
+		"total_amount": formatYuan(req.Amount),
+		"subject":      req.Subject,
+	}
+	if !req.ExpiresAt.IsZero() {
+		biz["time_expire"] = req.ExpiresAt.Format("2006-01-02 15:04:05")
+	}
+
+	var resp struct {
+		alipayResponse
+		OutTradeNo string `json:"out_trade_no"`
+		QRCode     string `json:"qr_code"`
+	}
+	if err := g.call(ctx, "alipay.trade.precreate", biz, true, &resp); err != nil {
+		return nil, err
+	}
+
+	return &ChargeResult{
+		ChargeID:   resp.OutTradeNo,
+		PaymentURL: resp.QRCode,
+		QRCode:     resp.QRCode,
+	}, nil
+}
+
+// QueryCharge 查询交易（alipay.trade.query）
+func (g *AlipayGateway) QueryCharge(ctx context.Context, orderID string) (*ChargeStatus, error) {
+	var resp struct {
+		alipayResponse
+		TradeNo     string `json:"trade_no"`
+		TradeStatus string `json:"trade_status"`
+		TotalAmount string `json:"total_amount"`
+		SendPayDate string `json:"send_pay_date"`
+	}
+	err := g.call(ctx, "alipay.trade.query", map[string]string{"out_trade_no": orderID}, false, &resp)
+	if err != nil {
+		var apiErr *AlipayError
+		if errors.As(err, &apiErr) && apiErr.SubCode == "ACQ.TRADE_NOT_EXIST" {
+			return nil, ErrChargeNotFound
+		}
+		return nil, err
+	}
+
+	amount, _ := strconv.ParseFloat(resp.TotalAmount, 64)
+	status := &ChargeStatus{
+		OrderID:       orderID,
+		TransactionID: resp.TradeNo,
+		Status:        alipayTradeStatus(resp.TradeStatus),
+		Amount:        amount,
+		Currency:      alipayCurrency,
+	}
+	if paidAt, err := time.ParseInLocation("2006-01-02 15:04:05", resp.SendPayDate, chinaLocation()); err == nil {
+		status.PaidAt = &paidAt
+	}
+	return status, nil
+}
+
+// Refund 退款（alipay.trade.refund），资金变动后同步返回成功
+func (g *AlipayGateway) Refund(ctx context.Context, req *RefundRequest) (*RefundResult, error) {
+	biz := map[string]string{
+		"out_trade_no":   req.OrderID,
+		"refund_amount":  formatYuan(req.Amount),
+		"out_request_no": req.RefundID,
+		"refund_reason":  req.Reason,
+	}
+	var resp struct {
+		alipayResponse
+		TradeNo    string `json:"trade_no"`
+		FundChange string `json:"fund_change"`
+	}
+	if err := g.call(ctx, "alipay.trade.refund", biz, false, &resp); err != nil {
+		return nil, err
+	}
+
+	result := &RefundResult{RefundTransactionID: resp.TradeNo, Status: RefundStatusPending}
+	if resp.FundChange == "Y" {
+		result.Status = RefundStatusSucceeded
+	}
+	return result, nil
+}
+
+// VerifyWebhook 校验异步通知：除 sign 和 sign_type 外的参数按名称排序拼接后用支付宝公钥验签
+// 通知时间 notify_time 缺失或超出重试有效期的通知视为重放
+func (g *AlipayGateway) VerifyWebhook(ctx context.Context, header http.Header, body []byte) (*WebhookEvent, error) {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	params := make(map[string]string, len(values))
+	for key := range values {
+		params[key] = values.Get(key)
+	}
+
+	content := canonicalQuery(params, "sign", "sign_type")
+	if !g.signer.Verify([]byte(content), params["sign"]) {
+		return nil, ErrInvalidSignature
+	}
+	if params["app_id"] != g.config.AppID {
+		return nil, ErrInvalidSignature
+	}
+	notifyTime, err := time.ParseInLocation("2006-01-02 15:04:05", params["notify_time"], chinaLocation())
+	if err != nil || !webhookTimeFresh(notifyTime) {
+		return nil, ErrInvalidSignature
+	}
+
+	amount, _ := strconv.ParseFloat(params["total_amount"], 64)
+	event := &WebhookEvent{
+		EventID:       params["notify_id"],
+		Type:          EventTypePayment,
+		OrderID:       params["out_trade_no"],
+		TransactionID: params["trade_no"],
+		Status:        alipayTradeStatus(params["trade_status"]),
+		Amount:        amount,
+		Currency:      alipayCurrency,
+		OccurredAt:    notifyTime,
+	}
+	// 退款也会触发交易状态通知，带有 out_biz_no 和 refund_fee
+	// refund_fee 为该交易的累计退款金额，无法与单笔退款核对，因此不作为事件金额
+	if params["refund_fee"] != "" && params["out_biz_no"] != "" {
+		event.Type = EventTypeRefund
+		event.RefundID = params["out_biz_no"]
+		event.Status = RefundStatusSucceeded
+		event.Amount = 0
+	}
+	return event, nil
+}
+
+// WebhookAck 支付宝要求返回纯文本 success
+func (g *AlipayGateway) WebhookAck(success bool) (string, []byte) {
+	if success {
+		return "text/plain; charset=utf-8", []byte("success")
+	}
+	return "text/plain; charset=utf-8", []byte("failure")
+}
+
+// AlipayError 支付宝业务错误
+type AlipayError struct {
+	Code    string
+	Msg     string
+	SubCode string
+	SubMsg  string
+}
+
+// Error 实现error接口
+func (e *AlipayError) Error() string {
+	return fmt.Sprintf("alipay error %s %s: %s", e.Code, e.SubCode, e.SubMsg)
+}
+
+// alipayResponse 支付宝公共响应参数
+type alipayResponse struct {
+	Code    string `json:"code"`
+	Msg     string `json:"msg"`
+	SubCode string `json:"sub_code"`
+	SubMsg  string `json:"sub_msg"`
+}
+
+// call 调用支付宝开放接口并校验响应签名
+func (g *AlipayGateway) call(ctx context.Context, method string, biz map[string]string, withNotify bool, out interface{}) error {
+	bizContent, err := json.Marshal(biz)
+	if err != nil {
+		return err
+	}
+
+	params := map[string]string{
+		"app_id":      g.config.AppID,
+		"method":      method,
+		"format":      "JSON",
+		"charset":     "utf-8",
+		"sign_type":   "RSA2",
+		"timestamp":   time.Now().In(chinaLocation()).Format("2006-01-02 15:04:05"),
+		"version":     "1.0",
+		"biz_content": string(bizContent),
+	}
+	if withNotify && g.config.NotifyURL != "" {
+		params["notify_url"] = g.config.NotifyURL
+	}
+	sign, err := g.signer.Sign([]byte(canonicalQuery(params, "sign")))
+	if err != nil {
+		return err
+	}
+	params["sign"] = sign
+
+	form := url.Values{}
+	for key, value := range params {
+		form.Set(key, value)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, g.config.GatewayURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded;charset=utf-8")
+
+	httpResp, err := g.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	raw, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return err
+	}
+
+	// 响应节点名为接口名的点替换为下划线并加 _response 后缀，签名针对该节点原文
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return err
+	}
+	node := envelope[strings.ReplaceAll(method, ".", "_")+"_response"]
+	if node == nil {
+		return fmt.Errorf("alipay: unexpected response for %s", method)
+	}
+
+	var common alipayResponse
+	if err := json.Unmarshal(node, &common); err != nil {
+		return err
+	}
+	if common.Code != "10000" {
+		return &AlipayError{Code: common.Code, Msg: common.Msg, SubCode: common.SubCode, SubMsg: common.SubMsg}
+	}
+
+	var responseSign string
+	if err := json.Unmarshal(envelope["sign"], &responseSign); err != nil || !g.signer.Verify(node, responseSign) {
+		return ErrInvalidSignature
+	}
+	return json.Unmarshal(node, out)
+}
+
+// alipayTradeStatus 支付宝交易状态映射
+func alipayTradeStatus(status string) string {
+	switch status {
+	case "TRADE_SUCCESS", "TRADE_FINISHED":
+		return ChargeStatusSucceeded
+	case "TRADE_CLOSED":
+		return ChargeStatusClosed
+	default:
+		return ChargeStatusPending
+	}
+}
+
+// formatYuan 金额格式化为两位小数的元
+func formatYuan(amount float64) string {
+	return strconv.FormatFloat(fromCents(toCents(amount)), 'f', 2, 64)
+}
+
+// chinaLocation 网关时间统一使用北京时间
+func chinaLocation() *time.Location {
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		return time.FixedZone("CST", 8*3600)
+	}
+	return loc
+}
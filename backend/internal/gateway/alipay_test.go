@@ -0,0 +1,188 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// newTestRSAKeys 生成测试用RSA密钥，返回PEM私钥和Base64公钥
+func newTestRSAKeys(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey: %v", err)
+	}
+	return string(privateKey), base64.StdEncoding.EncodeToString(publicKey)
+}
+
+// signAlipayNotify 以支付宝私钥对通知参数签名并编码为表单
+func signAlipayNotify(t *testing.T, privateKey string, params map[string]string) url.Values {
+	t.Helper()
+	signer, err := NewRSASigner(privateKey, "")
+	if err != nil {
+		t.Fatalf("NewRSASigner: %v", err)
+	}
+	sign, err := signer.Sign([]byte(canonicalQuery(params, "sign", "sign_type")))
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	form := url.Values{}
+	for key, value := range params {
+		form.Set(key, value)
+	}
+	form.Set("sign", sign)
+	form.Set("sign_type", "RSA2")
+	return form
+}
+
+func TestAlipayVerifyWebhook(t *testing.T) {
+	alipayPrivateKey, alipayPublicKey := newTestRSAKeys(t)
+	otherPrivateKey, _ := newTestRSAKeys(t)
+	gw, err := NewAlipayGateway(AlipayConfig{AppID: "app-1", PublicKey: alipayPublicKey})
+	if err != nil {
+		t.Fatalf("NewAlipayGateway: %v", err)
+	}
+
+	notifyTime := func(offset time.Duration) string {
+		return time.Now().Add(offset).In(chinaLocation()).Format("2006-01-02 15:04:05")
+	}
+	notify := func(overrides map[string]string) map[string]string {
+		params := map[string]string{
+			"app_id":       "app-1",
+			"notify_id":    "notify-1",
+			"notify_time":  notifyTime(0),
+			"out_trade_no": "PAYORDER_1",
+			"trade_no":     "2024000001",
+			"trade_status": "TRADE_SUCCESS",
+			"total_amount": "199.00",
+		}
+		for key, value := range overrides {
+			if value == "" {
+				delete(params, key)
+			} else {
+				params[key] = value
+			}
+		}
+		return params
+	}
+
+	tests := []struct {
+		name    string
+		body    func() string
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			body: func() string { return signAlipayNotify(t, alipayPrivateKey, notify(nil)).Encode() },
+		},
+		{
+			name: "tampered body",
+			body: func() string {
+				form := signAlipayNotify(t, alipayPrivateKey, notify(nil))
+				form.Set("total_amount", "0.01")
+				return form.Encode()
+			},
+			wantErr: true,
+		},
+		{
+			name:    "wrong key",
+			body:    func() string { return signAlipayNotify(t, otherPrivateKey, notify(nil)).Encode() },
+			wantErr: true,
+		},
+		{
+			name: "missing signature",
+			body: func() string {
+				form := signAlipayNotify(t, alipayPrivateKey, notify(nil))
+				form.Del("sign")
+				return form.Encode()
+			},
+			wantErr: true,
+		},
+		{
+			name: "other app",
+			body: func() string {
+				return signAlipayNotify(t, alipayPrivateKey, notify(map[string]string{"app_id": "app-2"})).Encode()
+			},
+			wantErr: true,
+		},
+		{
+			name: "expired notify time",
+			body: func() string {
+				return signAlipayNotify(t, alipayPrivateKey, notify(map[string]string{"notify_time": notifyTime(-27 * time.Hour)})).Encode()
+			},
+			wantErr: true,
+		},
+		{
+			name: "future notify time",
+			body: func() string {
+				return signAlipayNotify(t, alipayPrivateKey, notify(map[string]string{"notify_time": notifyTime(time.Hour)})).Encode()
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing notify time",
+			body: func() string {
+				return signAlipayNotify(t, alipayPrivateKey, notify(map[string]string{"notify_time": ""})).Encode()
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := gw.VerifyWebhook(context.Background(), nil, []byte(tt.body()))
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSignature) {
+					t.Fatalf("VerifyWebhook error = %v, want ErrInvalidSignature", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyWebhook: %v", err)
+			}
+			if event.EventID != "notify-1" || event.OrderID != "PAYORDER_1" || event.Status != ChargeStatusSucceeded || event.Amount != 199 {
+				t.Fatalf("unexpected event %+v", event)
+			}
+		})
+	}
+}
+
+func TestAlipayVerifyWebhookRefund(t *testing.T) {
+	alipayPrivateKey, alipayPublicKey := newTestRSAKeys(t)
+	gw, err := NewAlipayGateway(AlipayConfig{AppID: "app-1", PublicKey: alipayPublicKey})
+	if err != nil {
+		t.Fatalf("NewAlipayGateway: %v", err)
+	}
+	form := signAlipayNotify(t, alipayPrivateKey, map[string]string{
+		"app_id":       "app-1",
+		"notify_id":    "notify-2",
+		"notify_time":  time.Now().In(chinaLocation()).Format("2006-01-02 15:04:05"),
+		"out_trade_no": "PAYORDER_1",
+		"trade_no":     "2024000001",
+		"trade_status": "TRADE_SUCCESS",
+		"out_biz_no":   "PAYREFUND_1",
+		"refund_fee":   "50.00",
+	})
+
+	event, err := gw.VerifyWebhook(context.Background(), nil, []byte(form.Encode()))
+	if err != nil {
+		t.Fatalf("VerifyWebhook: %v", err)
+	}
+	// 退款通知的 refund_fee 为累计退款金额，不作为事件金额
+	if event.Type != EventTypeRefund || event.RefundID != "PAYREFUND_1" || event.Status != RefundStatusSucceeded || event.Amount != 0 {
+		t.Fatalf("unexpected refund event %+v", event)
+	}
+}
@@ -0,0 +1,173 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 网关侧支付状态
+const (
+	ChargeStatusPending   = "pending"
+	ChargeStatusSucceeded = "succeeded"
+	ChargeStatusFailed    = "failed"
+	ChargeStatusClosed    = "closed"
+)
+
+// 网关侧退款状态
+const (
+	RefundStatusPending   = "pending"
+	RefundStatusSucceeded = "succeeded"
+	RefundStatusFailed    = "failed"
+)
+
+// 回调事件类型
+const (
+	EventTypePayment = "payment"
+	EventTypeRefund  = "refund"
+)
+
+// 网关错误
+var (
+	ErrGatewayNotFound   = errors.New("payment gateway not found")
+	ErrInvalidSignature  = errors.New("invalid webhook signature")
+	ErrChargeNotFound    = errors.New("charge not found")
+	ErrUnsupportedAction = errors.New("action not supported by gateway")
+)
+
+// ChargeRequest 下单请求
+type ChargeRequest struct {
+	OrderID   string // 商户订单号，即 payment_orders.id
+	Amount    float64
+	Currency  string
+	Subject   string
+	ClientIP  string
+	ExpiresAt time.Time
+}
+
+// ChargeResult 下单结果
+type ChargeResult struct {
+	ChargeID   string // 网关侧预下单标识，可能为空
+	PaymentURL string // 支付跳转地址
+	QRCode     string // 二维码内容，由前端渲染为二维码
+}
+
+// ChargeStatus 支付查询结果
+type ChargeStatus struct {
+	OrderID       string
+	TransactionID string
+	Status        string
+	Amount        float64
+	Currency      string
+	PaidAt        *time.Time
+}
+
+// RefundRequest 退款请求
+type RefundRequest struct {
+	OrderID       string
+	RefundID      string // 商户退款单号，即 payment_refunds.id
+	TransactionID string
+	TotalAmount   float64
+	Amount        float64
+	Reason        string
+}
+
+// RefundResult 退款结果
+type RefundResult struct {
+	RefundTransactionID string
+	Status              string
+}
+
+// WebhookEvent 已验签的网关回调事件
+type WebhookEvent struct {
+	EventID       string // 网关通知ID，用于去重
+	Type          string
+	OrderID       string
+	RefundID      string
+	TransactionID string
+	Status        string
+	Amount        float64
+	Currency      string
+	OccurredAt    time.Time
+}
+
+// PaymentGateway 支付网关适配接口
+type PaymentGateway interface {
+	// Name 网关名称，对应回调地址 /payments/webhook/{gateway}
+	Name() string
+	CreateCharge(ctx context.Context, req *ChargeRequest) (*ChargeResult, error)
+	QueryCharge(ctx context.Context, orderID string) (*ChargeStatus, error)
+	Refund(ctx context.Context, req *RefundRequest) (*RefundResult, error)
+	// VerifyWebhook 校验回调签名并解析事件，签名不正确时返回 ErrInvalidSignature
+	VerifyWebhook(ctx context.Context, header http.Header, body []byte) (*WebhookEvent, error)
+	// WebhookAck 返回网关要求的回调应答，contentType 为空时由调用方返回默认JSON
+	WebhookAck(success bool) (contentType string, body []byte)
+}
+
+// Registry 支付网关注册表，按支付方式ID和网关名称索引
+type Registry struct {
+	mu       sync.RWMutex
+	byMethod map[string]PaymentGateway
+	byName   map[string]PaymentGateway
+}
+
+// NewRegistry 创建网关注册表
+func NewRegistry() *Registry {
+	return &Registry{
+		byMethod: make(map[string]PaymentGateway),
+		byName:   make(map[string]PaymentGateway),
+	}
+}
+
+// Register 为支付方式注册网关，同一网关可服务多个支付方式
+func (r *Registry) Register(methodID string, gw PaymentGateway) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.byMethod[methodID] = gw
+	r.byName[gw.Name()] = gw
+}
+
+// ForMethod 根据支付方式ID获取网关
+func (r *Registry) ForMethod(methodID string) (PaymentGateway, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	gw, ok := r.byMethod[methodID]
+	if !ok {
+		return nil, ErrGatewayNotFound
+	}
+	return gw, nil
+}
+
+// ByName 根据网关名称获取网关
+func (r *Registry) ByName(name string) (PaymentGateway, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	gw, ok := r.byName[name]
+	if !ok {
+		return nil, ErrGatewayNotFound
+	}
+	return gw, nil
+}
+
+// Sandbox 获取已注册的沙箱网关
+func (r *Registry) Sandbox() (*SandboxGateway, bool) {
+	gw, err := r.ByName(SandboxName)
+	if err != nil {
+		return nil, false
+	}
+	sandbox, ok := gw.(*SandboxGateway)
+	return sandbox, ok
+}
+
+// toCents 金额转换为分
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// fromCents 分转换为金额
+func fromCents(cents int64) float64 {
+	return float64(cents) / 100
+}
@@ -0,0 +1,262 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SandboxName 沙箱网关名称
+const SandboxName = "sandbox"
+
+// 沙箱回调签名头
+const (
+	SandboxSignatureHeader = "X-Sandbox-Signature"
+	SandboxTimestampHeader = "X-Sandbox-Timestamp"
+)
+
+// sandboxWebhookTolerance 回调时间戳允许的最大偏差
+const sandboxWebhookTolerance = 5 * time.Minute
+
+// SandboxGateway 完全在本地运行的沙箱网关，用于开发联调和端到端测试
+// 下单后通过 Simulate 模拟用户支付结果，生成与真实网关同样经过签名的回调
+type SandboxGateway struct {
+	signer  *HMACSigner
+	baseURL string
+
+	mu      sync.Mutex
+	charges map[string]*sandboxCharge // key: 商户订单号
+	refunds map[string]*RefundResult  // key: 商户退款单号
+}
+
+// sandboxCharge 沙箱订单
+type sandboxCharge struct {
+	ChargeID      string
+	OrderID       string
+	Amount        float64
+	Currency      string
+	Status        string
+	TransactionID string
+	PaidAt        *time.Time
+	ExpiresAt     time.Time
+}
+
+// sandboxWebhookPayload 沙箱回调内容
+type sandboxWebhookPayload struct {
+	EventID       string  `json:"event_id"`
+	Type          string  `json:"type"`
+	OrderID       string  `json:"order_id"`
+	RefundID      string  `json:"refund_id,omitempty"`
+	TransactionID string  `json:"transaction_id"`
+	Status        string  `json:"status"`
+	Amount        float64 `json:"amount"`
+	Currency      string  `json:"currency"`
+	OccurredAt    int64   `json:"occurred_at"`
+}
+
+// NewSandboxGateway 创建沙箱网关，secret 用于回调签名且不能为空，baseURL 为API根地址
+func NewSandboxGateway(secret, baseURL string) (*SandboxGateway, error) {
+	if secret == "" {
+		return nil, errors.New("sandbox webhook secret is required")
+	}
+	return &SandboxGateway{
+		signer:  NewHMACSigner(secret),
+		baseURL: baseURL,
+		charges: make(map[string]*sandboxCharge),
+		refunds: make(map[string]*RefundResult),
+	}, nil
+}
+
+// Name 网关名称
+func (g *SandboxGateway) Name() string {
+	return SandboxName
+}
+
+// CreateCharge 沙箱下单
+func (g *SandboxGateway) CreateCharge(ctx context.Context, req *ChargeRequest) (*ChargeResult, error) {
+	chargeID := "SBX_" + randomHex(12)
+	charge := &sandboxCharge{
+		ChargeID:  chargeID,
+		OrderID:   req.OrderID,
+		Amount:    req.Amount,
+		Currency:  req.Currency,
+		Status:    ChargeStatusPending,
+		ExpiresAt: req.ExpiresAt,
+	}
+
+	g.mu.Lock()
+	g.charges[req.OrderID] = charge
+	g.mu.Unlock()
+
+	query := url.Values{}
+	query.Set("charge_id", chargeID)
+	query.Set("order_id", req.OrderID)
+	query.Set("amount", strconv.FormatFloat(req.Amount, 'f', 2, 64))
+	query.Set("currency", req.Currency)
+
+	return &ChargeResult{
+		ChargeID:   chargeID,
+		PaymentURL: fmt.Sprintf("%s/payments/sandbox/charges/%s/simulate", g.baseURL, chargeID),
+		QRCode:     "mgsandbox://pay?" + query.Encode(),
+	}, nil
+}
+
+// QueryCharge 查询沙箱订单
+func (g *SandboxGateway) QueryCharge(ctx context.Context, orderID string) (*ChargeStatus, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	charge, ok := g.charges[orderID]
+	if !ok {
+		return nil, ErrChargeNotFound
+	}
+	if charge.Status == ChargeStatusPending && !charge.ExpiresAt.IsZero() && time.Now().After(charge.ExpiresAt) {
+		charge.Status = ChargeStatusClosed
+	}
+	return &ChargeStatus{
+		OrderID:       charge.OrderID,
+		TransactionID: charge.TransactionID,
+		Status:        charge.Status,
+		Amount:        charge.Amount,
+		Currency:      charge.Currency,
+		PaidAt:        charge.PaidAt,
+	}, nil
+}
+
+// Refund 沙箱退款，同步成功；同一退款单号重复请求返回首次结果
+func (g *SandboxGateway) Refund(ctx context.Context, req *RefundRequest) (*RefundResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if result, ok := g.refunds[req.RefundID]; ok {
+		return result, nil
+	}
+	charge, ok := g.charges[req.OrderID]
+	if !ok || charge.Status != ChargeStatusSucceeded {
+		return nil, ErrChargeNotFound
+	}
+	if toCents(req.Amount) > toCents(charge.Amount) {
+		return &RefundResult{Status: RefundStatusFailed}, nil
+	}
+
+	result := &RefundResult{
+		RefundTransactionID: "SBXR_" + randomHex(12),
+		Status:              RefundStatusSucceeded,
+	}
+	g.refunds[req.RefundID] = result
+	return result, nil
+}
+
+// VerifyWebhook 校验沙箱回调签名：HMAC-SHA256(secret, timestamp + "." + body)
+func (g *SandboxGateway) VerifyWebhook(ctx context.Context, header http.Header, body []byte) (*WebhookEvent, error) {
+	timestamp := header.Get(SandboxTimestampHeader)
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	if skew := time.Since(time.Unix(ts, 0)); skew > sandboxWebhookTolerance || skew < -sandboxWebhookTolerance {
+		return nil, ErrInvalidSignature
+	}
+	if !g.signer.Verify(sandboxSignedContent(timestamp, body), header.Get(SandboxSignatureHeader)) {
+		return nil, ErrInvalidSignature
+	}
+
+	var payload sandboxWebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	return &WebhookEvent{
+		EventID:       payload.EventID,
+		Type:          payload.Type,
+		OrderID:       payload.OrderID,
+		RefundID:      payload.RefundID,
+		TransactionID: payload.TransactionID,
+		Status:        payload.Status,
+		Amount:        payload.Amount,
+		Currency:      payload.Currency,
+		OccurredAt:    time.Unix(payload.OccurredAt, 0),
+	}, nil
+}
+
+// WebhookAck 沙箱回调使用默认JSON应答
+func (g *SandboxGateway) WebhookAck(success bool) (string, []byte) {
+	return "", nil
+}
+
+// Simulate 模拟用户完成或放弃支付，返回签名后的回调请求头和内容
+func (g *SandboxGateway) Simulate(chargeID, status string) (http.Header, []byte, error) {
+	if status != ChargeStatusSucceeded && status != ChargeStatusFailed {
+		return nil, nil, fmt.Errorf("unsupported sandbox status: %s", status)
+	}
+
+	g.mu.Lock()
+	var charge *sandboxCharge
+	for _, c := range g.charges {
+		if c.ChargeID == chargeID {
+			charge = c
+			break
+		}
+	}
+	if charge == nil {
+		g.mu.Unlock()
+		return nil, nil, ErrChargeNotFound
+	}
+	if charge.Status == ChargeStatusPending {
+		now := time.Now()
+		charge.Status = status
+		if status == ChargeStatusSucceeded {
+			charge.TransactionID = "SBXT_" + randomHex(12)
+			charge.PaidAt = &now
+		}
+	}
+	payload := sandboxWebhookPayload{
+		EventID:       "SBXE_" + randomHex(12),
+		Type:          EventTypePayment,
+		OrderID:       charge.OrderID,
+		TransactionID: charge.TransactionID,
+		Status:        charge.Status,
+		Amount:        charge.Amount,
+		Currency:      charge.Currency,
+		OccurredAt:    time.Now().Unix(),
+	}
+	g.mu.Unlock()
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	return g.SignWebhook(body), body, nil
+}
+
+// SignWebhook 为回调内容生成签名头，测试中也可用于构造任意回调
+func (g *SandboxGateway) SignWebhook(body []byte) http.Header {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set(SandboxTimestampHeader, timestamp)
+	header.Set(SandboxSignatureHeader, g.signer.Sign(sandboxSignedContent(timestamp, body)))
+	return header
+}
+
+// sandboxSignedContent 沙箱回调待签名内容
+func sandboxSignedContent(timestamp string, body []byte) []byte {
+	content := make([]byte, 0, len(timestamp)+1+len(body))
+	content = append(content, timestamp...)
+	content = append(content, '.')
+	return append(content, body...)
+}
+
+// randomHex 生成随机十六进制串
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
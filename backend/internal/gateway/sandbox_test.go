@@ -0,0 +1,112 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNewSandboxGatewayRequiresSecret(t *testing.T) {
+	if _, err := NewSandboxGateway("", "http://localhost:8080/api/v1"); err == nil {
+		t.Fatal("NewSandboxGateway without secret succeeded, want error")
+	}
+}
+
+func TestSandboxVerifyWebhook(t *testing.T) {
+	gw, err := NewSandboxGateway("secret", "http://localhost:8080/api/v1")
+	if err != nil {
+		t.Fatalf("NewSandboxGateway: %v", err)
+	}
+	otherKey, err := NewSandboxGateway("other-secret", "http://localhost:8080/api/v1")
+	if err != nil {
+		t.Fatalf("NewSandboxGateway: %v", err)
+	}
+	body := []byte(`{"event_id":"SBXE_1","type":"payment","order_id":"PAYORDER_1","transaction_id":"SBXT_1","status":"succeeded","amount":199,"currency":"CNY","occurred_at":1700000000}`)
+
+	// signedAt 以指定时间戳签名
+	signedAt := func(signer *SandboxGateway, at time.Time) http.Header {
+		timestamp := strconv.FormatInt(at.Unix(), 10)
+		header := http.Header{}
+		header.Set(SandboxTimestampHeader, timestamp)
+		header.Set(SandboxSignatureHeader, signer.signer.Sign(sandboxSignedContent(timestamp, body)))
+		return header
+	}
+
+	tests := []struct {
+		name    string
+		header  func() http.Header
+		body    []byte
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			header: func() http.Header { return gw.SignWebhook(body) },
+			body:   body,
+		},
+		{
+			name:    "tampered body",
+			header:  func() http.Header { return gw.SignWebhook(body) },
+			body:    []byte(`{"event_id":"SBXE_1","type":"payment","order_id":"PAYORDER_2","transaction_id":"SBXT_1","status":"succeeded","amount":199,"currency":"CNY","occurred_at":1700000000}`),
+			wantErr: true,
+		},
+		{
+			name:    "wrong key",
+			header:  func() http.Header { return otherKey.SignWebhook(body) },
+			body:    body,
+			wantErr: true,
+		},
+		{
+			name: "missing signature",
+			header: func() http.Header {
+				header := gw.SignWebhook(body)
+				header.Del(SandboxSignatureHeader)
+				return header
+			},
+			body:    body,
+			wantErr: true,
+		},
+		{
+			name:    "expired timestamp",
+			header:  func() http.Header { return signedAt(gw, time.Now().Add(-sandboxWebhookTolerance-time.Minute)) },
+			body:    body,
+			wantErr: true,
+		},
+		{
+			name:    "future timestamp",
+			header:  func() http.Header { return signedAt(gw, time.Now().Add(sandboxWebhookTolerance+time.Minute)) },
+			body:    body,
+			wantErr: true,
+		},
+		{
+			name: "missing timestamp",
+			header: func() http.Header {
+				header := gw.SignWebhook(body)
+				header.Del(SandboxTimestampHeader)
+				return header
+			},
+			body:    body,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := gw.VerifyWebhook(context.Background(), tt.header(), tt.body)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSignature) {
+					t.Fatalf("VerifyWebhook error = %v, want ErrInvalidSignature", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyWebhook: %v", err)
+			}
+			if event.EventID != "SBXE_1" || event.OrderID != "PAYORDER_1" || event.Status != ChargeStatusSucceeded || event.Amount != 199 {
+				t.Fatalf("unexpected event %+v", event)
+			}
+		})
+	}
+}
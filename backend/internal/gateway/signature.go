@@ -0,0 +1,186 @@
+package gateway
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// webhookMaxAge 支付宝和微信支付在约25小时内重试异步通知，通知时间早于该时长的视为重放
+const webhookMaxAge = 26 * time.Hour
+
+// webhookMaxClockSkew 通知时间允许超前本机时间的最大偏差
+const webhookMaxClockSkew = 5 * time.Minute
+
+// HMACSigner HMAC-SHA256 签名
+type HMACSigner struct {
+	key []byte
+}
+
+// NewHMACSigner 创建HMAC-SHA256签名器
+func NewHMACSigner(key string) *HMACSigner {
+	return &HMACSigner{key: []byte(key)}
+}
+
+// Sign 计算签名，返回小写十六进制
+func (s *HMACSigner) Sign(data []byte) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 校验签名，十六进制大小写不敏感
+func (s *HMACSigner) Verify(data []byte, signature string) bool {
+	expected, err := hex.DecodeString(strings.ToLower(strings.TrimSpace(signature)))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(data)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// RSASigner SHA256withRSA（RSA2）签名，私钥用于签名请求，公钥用于验证网关响应和回调
+type RSASigner struct {
+	privateKey *rsa.PrivateKey
+	publicKey  *rsa.PublicKey
+}
+
+// NewRSASigner 创建RSA2签名器，密钥支持PEM或去掉首尾行的Base64文本，可只提供其一
+func NewRSASigner(privateKey, publicKey string) (*RSASigner, error) {
+	signer := &RSASigner{}
+	if privateKey != "" {
+		key, err := ParseRSAPrivateKey(privateKey)
+		if err != nil {
+			return nil, err
+		}
+		signer.privateKey = key
+	}
+	if publicKey != "" {
+		key, err := ParseRSAPublicKey(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		signer.publicKey = key
+	}
+	return signer, nil
+}
+
+// Sign 签名，返回Base64
+func (s *RSASigner) Sign(data []byte) (string, error) {
+	if s.privateKey == nil {
+		return "", errors.New("rsa private key not configured")
+	}
+	digest := sha256.Sum256(data)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// Verify 验证Base64签名
+func (s *RSASigner) Verify(data []byte, signature string) bool {
+	if s.publicKey == nil {
+		return false
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	digest := sha256.Sum256(data)
+	return rsa.VerifyPKCS1v15(s.publicKey, crypto.SHA256, digest[:], sig) == nil
+}
+
+// ParseRSAPrivateKey 解析PKCS#1或PKCS#8格式的RSA私钥
+func ParseRSAPrivateKey(key string) (*rsa.PrivateKey, error) {
+	der, err := decodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if pk, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return pk, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	pk, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not rsa")
+	}
+	return pk, nil
+}
+
+// ParseRSAPublicKey 解析PKIX或PKCS#1格式的RSA公钥
+func ParseRSAPublicKey(key string) (*rsa.PublicKey, error) {
+	der, err := decodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if parsed, err := x509.ParsePKIXPublicKey(der); err == nil {
+		pk, ok := parsed.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.New("public key is not rsa")
+		}
+		return pk, nil
+	}
+	return x509.ParsePKCS1PublicKey(der)
+}
+
+// decodeKey 解码PEM或裸Base64密钥
+func decodeKey(key string) ([]byte, error) {
+	key = strings.TrimSpace(key)
+	if strings.HasPrefix(key, "-----BEGIN") {
+		block, _ := pem.Decode([]byte(key))
+		if block == nil {
+			return nil, errors.New("invalid pem key")
+		}
+		return block.Bytes, nil
+	}
+	return base64.StdEncoding.DecodeString(key)
+}
+
+// canonicalQuery 按参数名升序拼接 k=v，跳过空值和排除的参数，用于网关签名
+func canonicalQuery(params map[string]string, exclude ...string) string {
+	skip := make(map[string]struct{}, len(exclude))
+	for _, key := range exclude {
+		skip[key] = struct{}{}
+	}
+
+	keys := make([]string, 0, len(params))
+	for key, value := range params {
+		if _, ok := skip[key]; ok || value == "" {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for i, key := range keys {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(params[key])
+	}
+	return b.String()
+}
+
+// webhookTimeFresh 判断通知时间是否在有效期内，过早或超前均视为无效
+func webhookTimeFresh(at time.Time) bool {
+	age := time.Since(at)
+	return age <= webhookMaxAge && age >= -webhookMaxClockSkew
+}
@@ -0,0 +1,128 @@
+package gateway
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHMACSigner(t *testing.T) {
+	signer := NewHMACSigner("key")
+	data := []byte("payload")
+	signature := signer.Sign(data)
+
+	tests := []struct {
+		name      string
+		signer    *HMACSigner
+		data      []byte
+		signature string
+		want      bool
+	}{
+		{name: "valid", signer: signer, data: data, signature: signature, want: true},
+		{name: "upper case", signer: signer, data: data, signature: strings.ToUpper(signature), want: true},
+		{name: "tampered data", signer: signer, data: []byte("payload2"), signature: signature},
+		{name: "wrong key", signer: NewHMACSigner("other"), data: data, signature: signature},
+		{name: "not hex", signer: signer, data: data, signature: "zz"},
+		{name: "empty", signer: signer, data: data},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.signer.Verify(tt.data, tt.signature); got != tt.want {
+				t.Fatalf("Verify = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRSASigner(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey: %v", err)
+	}
+	pkix, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey: %v", err)
+	}
+	otherPublic, err := x509.MarshalPKIXPublicKey(&other.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey: %v", err)
+	}
+
+	privateKeys := map[string]string{
+		"pkcs1 pem":    string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		"pkcs8 base64": base64.StdEncoding.EncodeToString(pkcs8),
+	}
+	publicKey := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix}))
+	data := []byte("a=1&b=2")
+
+	for name, privateKey := range privateKeys {
+		t.Run(name, func(t *testing.T) {
+			signer, err := NewRSASigner(privateKey, publicKey)
+			if err != nil {
+				t.Fatalf("NewRSASigner: %v", err)
+			}
+			signature, err := signer.Sign(data)
+			if err != nil {
+				t.Fatalf("Sign: %v", err)
+			}
+			if !signer.Verify(data, signature) {
+				t.Fatal("Verify rejected a valid signature")
+			}
+			if signer.Verify([]byte("a=1&b=3"), signature) {
+				t.Fatal("Verify accepted tampered data")
+			}
+			if signer.Verify(data, "not-base64!") {
+				t.Fatal("Verify accepted a malformed signature")
+			}
+
+			wrongKey, err := NewRSASigner("", base64.StdEncoding.EncodeToString(otherPublic))
+			if err != nil {
+				t.Fatalf("NewRSASigner: %v", err)
+			}
+			if wrongKey.Verify(data, signature) {
+				t.Fatal("Verify accepted a signature from another key")
+			}
+		})
+	}
+
+	if _, err := NewRSASigner("not-a-key", ""); err == nil {
+		t.Fatal("NewRSASigner with invalid key succeeded, want error")
+	}
+	if signer, _ := NewRSASigner("", ""); signer.Verify(data, "") {
+		t.Fatal("Verify without public key succeeded")
+	}
+}
+
+func TestWebhookTimeFresh(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset time.Duration
+		want   bool
+	}{
+		{name: "now", want: true},
+		{name: "retried within max age", offset: -25 * time.Hour, want: true},
+		{name: "older than max age", offset: -webhookMaxAge - time.Minute},
+		{name: "small clock skew", offset: time.Minute, want: true},
+		{name: "too far in the future", offset: webhookMaxClockSkew + time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := webhookTimeFresh(time.Now().Add(tt.offset)); got != tt.want {
+				t.Fatalf("webhookTimeFresh(now%+v) = %v, want %v", tt.offset, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WechatName 微信支付网关名称
+const WechatName = "wechat"
+
+// wechatDefaultGatewayURL 微信支付API地址
+const wechatDefaultGatewayURL = "https://api.mch.weixin.qq.com"
+
+// WechatConfig 微信支付配置
+type WechatConfig struct {
+	AppID      string
+	MchID      string
+	APIKey     string // 商户API密钥，用于HMAC-SHA256签名
+	CertFile   string // 商户证书，退款接口需要
+	KeyFile    string
+	GatewayURL string
+	NotifyURL  string
+}
+
+// WechatGateway 微信支付Native扫码网关（V2接口），请求与回调使用HMAC-SHA256签名
+type WechatGateway struct {
+	config     WechatConfig
+	client     *http.Client
+	certClient *http.Client
+}
+
+// NewWechatGateway 创建微信支付网关
+func NewWechatGateway(config WechatConfig) (*WechatGateway, error) {
+	if config.AppID == "" || config.MchID == "" || config.APIKey == "" {
+		return nil, errors.New("wechat app_id, mch_id and api_key are required")
+	}
+	if config.GatewayURL == "" {
+		config.GatewayURL = wechatDefaultGatewayURL
+	}
+
+	g := &WechatGateway{
+		config: config,
+		client: &http.Client{Timeout: 15 * time.Second},
+	}
+	if config.CertFile != "" && config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("wechat cert: %w", err)
+		}
+		g.certClient = &http.Client{
+			Timeout: 15 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+			},
+		}
+	}
+	return g, nil
+}
+
+// Name 网关名称
+func (g *WechatGateway) Name() string {
+	return WechatName
+}
+
+// CreateCharge 统一下单（trade_type=NATIVE），返回二维码链接 code_url
+func (g *WechatGateway) CreateCharge(ctx context.Context, req *ChargeRequest) (*ChargeResult, error) {
+	params := map[string]string{
+		"body":             req.Subject,
+		"out_trade_no":     req.OrderID,
+		"total_fee":        strconv.FormatInt(toCents(req.Amount), 10),
+		"spbill_create_ip": req.ClientIP,
+		"notify_url":       g.config.NotifyURL,
+		"trade_type":       "NATIVE",
+		"product_id":       req.OrderID,
+	}
+	if req.Currency != "" {
+		params["fee_type"] = req.Currency
+	}
+	if !req.ExpiresAt.IsZero() {
+		params["time_expire"] = req.ExpiresAt.In(chinaLocation()).Format("20060102150405")
+	}
+
+	resp, err := g.call(ctx, g.client, "/pay/unifiedorder", params)
+	if err != nil {
+		return nil, err
+	}
+	return &ChargeResult{
+		ChargeID:   resp["prepay_id"],
+		PaymentURL: resp["code_url"],
+		QRCode:     resp["code_url"],
+	}, nil
+}
+
+// QueryCharge 查询订单
+func (g *WechatGateway) QueryCharge(ctx context.Context, orderID string) (*ChargeStatus, error) {
+	resp, err := g.call(ctx, g.client, "/pay/orderquery", map[string]string{"out_trade_no": orderID})
+	if err != nil {
+		var apiErr *WechatError
+		if errors.As(err, &apiErr) && apiErr.Code == "ORDERNOTEXIST" {
+			return nil, ErrChargeNotFound
+		}
+		return nil, err
+	}
+
+	totalFee, _ := strconv.ParseInt(resp["total_fee"], 10, 64)
+	status := &ChargeStatus{
+		OrderID:       orderID,
+		TransactionID: resp["transaction_id"],
+		Status:        wechatTradeState(resp["trade_state"]),
+		Amount:        fromCents(totalFee),
+		Currency:      wechatCurrency(resp["fee_type"]),
+	}
+	if paidAt, err := time.ParseInLocation("20060102150405", resp["time_end"], chinaLocation()); err == nil {
+		status.PaidAt = &paidAt
+	}
+	return status, nil
+}
+
+// Refund 申请退款，需要商户证书；受理成功后退款结果异步到账
+func (g *WechatGateway) Refund(ctx context.Context, req *RefundRequest) (*RefundResult, error) {
+	if g.certClient == nil {
+		return nil, ErrUnsupportedAction
+	}
+	params := map[string]string{
+		"out_trade_no":  req.OrderID,
+		"out_refund_no": req.RefundID,
+		"total_fee":     strconv.FormatInt(toCents(req.TotalAmount), 10),
+		"refund_fee":    strconv.FormatInt(toCents(req.Amount), 10),
+		"refund_desc":   req.Reason,
+	}
+	resp, err := g.call(ctx, g.certClient, "/secapi/pay/refund", params)
+	if err != nil {
+		return nil, err
+	}
+	return &RefundResult{
+		RefundTransactionID: resp["refund_id"],
+		Status:              RefundStatusPending,
+	}, nil
+}
+
+// VerifyWebhook 校验支付结果通知签名
+// 通知不含发送时间，支付完成时间 time_end 缺失或超出重试有效期的通知视为重放
+func (g *WechatGateway) VerifyWebhook(ctx context.Context, header http.Header, body []byte) (*WebhookEvent, error) {
+	params, err := decodeWechatXML(body)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	if !g.verify(params) || params["mch_id"] != g.config.MchID {
+		return nil, ErrInvalidSignature
+	}
+	if params["return_code"] != "SUCCESS" {
+		return nil, fmt.Errorf("wechat notify: %s", params["return_msg"])
+	}
+	paidAt, err := time.ParseInLocation("20060102150405", params["time_end"], chinaLocation())
+	if err != nil || !webhookTimeFresh(paidAt) {
+		return nil, ErrInvalidSignature
+	}
+
+	totalFee, _ := strconv.ParseInt(params["total_fee"], 10, 64)
+	status := ChargeStatusFailed
+	if params["result_code"] == "SUCCESS" {
+		status = ChargeStatusSucceeded
+	}
+	event := &WebhookEvent{
+		// 微信支付通知没有独立的通知ID，同一笔交易的重复通知使用交易号去重
+		EventID:       "wechat:" + params["transaction_id"] + ":" + params["result_code"],
+		Type:          EventTypePayment,
+		OrderID:       params["out_trade_no"],
+		TransactionID: params["transaction_id"],
+		Status:        status,
+		Amount:        fromCents(totalFee),
+		Currency:      wechatCurrency(params["fee_type"]),
+		OccurredAt:    paidAt,
+	}
+	return event, nil
+}
+
+// WebhookAck 微信支付要求返回XML应答
+func (g *WechatGateway) WebhookAck(success bool) (string, []byte) {
+	if success {
+		return "text/xml; charset=utf-8", encodeWechatXML(map[string]string{"return_code": "SUCCESS", "return_msg": "OK"})
+	}
+	return "text/xml; charset=utf-8", encodeWechatXML(map[string]string{"return_code": "FAIL", "return_msg": "FAIL"})
+}
+
+// WechatError 微信支付业务错误
+type WechatError struct {
+	Code string
+	Msg  string
+}
+
+// Error 实现error接口
+func (e *WechatError) Error() string {
+	return fmt.Sprintf("wechat error %s: %s", e.Code, e.Msg)
+}
+
+// call 调用微信支付接口，补充公共参数并签名，校验响应签名和业务结果
+func (g *WechatGateway) call(ctx context.Context, client *http.Client, path string, params map[string]string) (map[string]string, error) {
+	params["appid"] = g.config.AppID
+	params["mch_id"] = g.config.MchID
+	params["nonce_str"] = randomHex(16)
+	params["sign_type"] = "HMAC-SHA256"
+	params["sign"] = g.sign(params)
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, g.config.GatewayURL+path, bytes.NewReader(encodeWechatXML(params)))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "text/xml; charset=utf-8")
+
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	raw, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := decodeWechatXML(raw)
+	if err != nil {
+		return nil, err
+	}
+	if resp["return_code"] != "SUCCESS" {
+		return nil, &WechatError{Code: resp["return_code"], Msg: resp["return_msg"]}
+	}
+	if !g.verify(resp) {
+		return nil, ErrInvalidSignature
+	}
+	if resp["result_code"] != "SUCCESS" {
+		return nil, &WechatError{Code: resp["err_code"], Msg: resp["err_code_des"]}
+	}
+	return resp, nil
+}
+
+// sign 计算签名：参数排序拼接后追加 &key=API密钥，HMAC-SHA256 后转大写
+func (g *WechatGateway) sign(params map[string]string) string {
+	content := canonicalQuery(params, "sign") + "&key=" + g.config.APIKey
+	return strings.ToUpper(NewHMACSigner(g.config.APIKey).Sign([]byte(content)))
+}
+
+// verify 校验签名
+func (g *WechatGateway) verify(params map[string]string) bool {
+	content := canonicalQuery(params, "sign") + "&key=" + g.config.APIKey
+	return NewHMACSigner(g.config.APIKey).Verify([]byte(content), params["sign"])
+}
+
+// wechatTradeState 微信交易状态映射
+func wechatTradeState(state string) string {
+	switch state {
+	case "SUCCESS", "REFUND":
+		return ChargeStatusSucceeded
+	case "CLOSED", "REVOKED":
+		return ChargeStatusClosed
+	case "PAYERROR":
+		return ChargeStatusFailed
+	default:
+		return ChargeStatusPending
+	}
+}
+
+// encodeWechatXML 将扁平参数编码为微信支付XML
+func encodeWechatXML(params map[string]string) []byte {
+	var b bytes.Buffer
+	b.WriteString("<xml>")
+	for key, value := range params {
+		b.WriteString("<" + key + "><![CDATA[")
+		b.WriteString(strings.ReplaceAll(value, "]]>", "]]]]><![CDATA[>"))
+		b.WriteString("]]></" + key + ">")
+	}
+	b.WriteString("</xml>")
+	return b.Bytes()
+}
+
+// decodeWechatXML 解析微信支付扁平XML
+func decodeWechatXML(data []byte) (map[string]string, error) {
+	params := make(map[string]string)
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var current string
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "xml" {
+				current = t.Name.Local
+			}
+		case xml.CharData:
+			if current != "" {
+				params[current] += string(t)
+			}
+		case xml.EndElement:
+			current = ""
+		}
+	}
+	if len(params) == 0 {
+		return nil, errors.New("empty wechat xml")
+	}
+	return params, nil
+}
+
+// wechatCurrency 通知中未携带币种时默认为人民币
+func wechatCurrency(feeType string) string {
+	if feeType == "" {
+		return "CNY"
+	}
+	return feeType
+}
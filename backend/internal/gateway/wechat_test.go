@@ -0,0 +1,136 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWechatVerifyWebhook(t *testing.T) {
+	gw, err := NewWechatGateway(WechatConfig{AppID: "wx-app", MchID: "mch-1", APIKey: "api-key"})
+	if err != nil {
+		t.Fatalf("NewWechatGateway: %v", err)
+	}
+	otherKey, err := NewWechatGateway(WechatConfig{AppID: "wx-app", MchID: "mch-1", APIKey: "other-key"})
+	if err != nil {
+		t.Fatalf("NewWechatGateway: %v", err)
+	}
+
+	timeEnd := func(offset time.Duration) string {
+		return time.Now().Add(offset).In(chinaLocation()).Format("20060102150405")
+	}
+	notify := func(overrides map[string]string) map[string]string {
+		params := map[string]string{
+			"appid":          "wx-app",
+			"mch_id":         "mch-1",
+			"nonce_str":      "nonce",
+			"sign_type":      "HMAC-SHA256",
+			"return_code":    "SUCCESS",
+			"result_code":    "SUCCESS",
+			"out_trade_no":   "PAYORDER_1",
+			"transaction_id": "4200000001",
+			"total_fee":      "19900",
+			"time_end":       timeEnd(0),
+		}
+		for key, value := range overrides {
+			if value == "" {
+				delete(params, key)
+			} else {
+				params[key] = value
+			}
+		}
+		return params
+	}
+	signed := func(signer *WechatGateway, params map[string]string) map[string]string {
+		params["sign"] = signer.sign(params)
+		return params
+	}
+
+	tests := []struct {
+		name    string
+		params  func() map[string]string
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			params: func() map[string]string { return signed(gw, notify(nil)) },
+		},
+		{
+			name: "tampered body",
+			params: func() map[string]string {
+				params := signed(gw, notify(nil))
+				params["total_fee"] = "1"
+				return params
+			},
+			wantErr: true,
+		},
+		{
+			name:    "wrong key",
+			params:  func() map[string]string { return signed(otherKey, notify(nil)) },
+			wantErr: true,
+		},
+		{
+			name:    "missing signature",
+			params:  func() map[string]string { return notify(nil) },
+			wantErr: true,
+		},
+		{
+			name:    "other merchant",
+			params:  func() map[string]string { return signed(gw, notify(map[string]string{"mch_id": "mch-2"})) },
+			wantErr: true,
+		},
+		{
+			name: "expired time_end",
+			params: func() map[string]string {
+				return signed(gw, notify(map[string]string{"time_end": timeEnd(-27 * time.Hour)}))
+			},
+			wantErr: true,
+		},
+		{
+			name:    "future time_end",
+			params:  func() map[string]string { return signed(gw, notify(map[string]string{"time_end": timeEnd(time.Hour)})) },
+			wantErr: true,
+		},
+		{
+			name:    "missing time_end",
+			params:  func() map[string]string { return signed(gw, notify(map[string]string{"time_end": ""})) },
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := gw.VerifyWebhook(context.Background(), nil, encodeWechatXML(tt.params()))
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSignature) {
+					t.Fatalf("VerifyWebhook error = %v, want ErrInvalidSignature", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyWebhook: %v", err)
+			}
+			if event.OrderID != "PAYORDER_1" || event.TransactionID != "4200000001" || event.Status != ChargeStatusSucceeded || event.Amount != 199 || event.Currency != "CNY" {
+				t.Fatalf("unexpected event %+v", event)
+			}
+		})
+	}
+}
+
+func TestWechatSignatureCaseInsensitive(t *testing.T) {
+	gw, err := NewWechatGateway(WechatConfig{AppID: "wx-app", MchID: "mch-1", APIKey: "api-key"})
+	if err != nil {
+		t.Fatalf("NewWechatGateway: %v", err)
+	}
+	params := map[string]string{"appid": "wx-app", "mch_id": "mch-1", "nonce_str": "nonce", "empty": ""}
+	params["sign"] = gw.sign(params)
+	if !gw.verify(params) {
+		t.Fatal("verify rejected upper-case signature")
+	}
+	// 空值参数不参与签名，补充取值后原签名失效
+	params["empty"] = "value"
+	if gw.verify(params) {
+		t.Fatal("verify accepted signature after a parameter changed")
+	}
+}
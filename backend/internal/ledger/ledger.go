@@ -0,0 +1,296 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// 平台账户编码
+const (
+	AccountGatewayClearing = "platform:gateway_clearing" // 支付网关清算资金，资产
+	AccountStudentPayments = "platform:student_payments" // 学生已付款未履约的资金，负债
+	AccountPlatformFees    = "platform:platform_fees"    // 平台服务费收入
+)
+
+// 账户类型
+const (
+	AccountTypeAsset     = "asset"
+	AccountTypeLiability = "liability"
+	AccountTypeRevenue   = "revenue"
+)
+
+// 记账凭证类型
+const (
+	TypePaymentReceived     = "payment_received"
+	TypeOrderFulfilled      = "order_fulfilled"
+	TypeIncomeReversed      = "income_reversed"
+	TypeIncomeSettled       = "income_settled"
+	TypeRefundPaid          = "refund_paid"
+	TypeWithdrawalRequested = "withdrawal_requested"
+	TypeWithdrawalPaid      = "withdrawal_paid"
+	TypeWithdrawalFailed    = "withdrawal_failed"
+)
+
+// DefaultCurrency 默认记账币种
+const DefaultCurrency = "CNY"
+
+// ErrUnbalanced 记账凭证借贷不平衡
+var ErrUnbalanced = errors.New("记账凭证借贷不平衡")
+
+// Account 账户定义
+type Account struct {
+	Code          string
+	Name          string
+	AccountType   string
+	NormalBalance string // debit 或 credit
+	OwnerType     string // platform 或 mentor
+	OwnerID       string
+}
+
+// Posting 单条分录，Amount 借记为正、贷记为负
+type Posting struct {
+	Account string
+	Amount  int64
+}
+
+// Transaction 复式记账凭证，金额以分为单位，分录合计必须为0
+// IdempotencyKey 保证同一业务事件只记账一次
+type Transaction struct {
+	IdempotencyKey string
+	Type           string
+	Description    string
+	Currency       string
+	PaymentOrderID string
+	RefundID       string
+	WithdrawalID   string
+	MentorID       string
+	OccurredAt     time.Time
+	Postings       []Posting
+}
+
+// MentorFrozen 大师待结算收入账户编码，余额为结算期内冻结、尚不可提现的收入
+func MentorFrozen(mentorID string) string {
+	return "mentor:" + mentorID + ":frozen"
+}
+
+// MentorPayable 大师应付款账户编码，余额为大师可提现金额
+func MentorPayable(mentorID string) string {
+	return "mentor:" + mentorID + ":payable"
+}
+
+// MentorWithdrawalsInTransit 大师提现在途账户编码，余额为已申请未打款的提现金额
+func MentorWithdrawalsInTransit(mentorID string) string {
+	return "mentor:" + mentorID + ":withdrawals_in_transit"
+}
+
+// LookupAccount 根据账户编码解析账户定义
+func LookupAccount(code string) (*Account, error) {
+	switch code {
+	case AccountGatewayClearing:
+		return &Account{Code: code, Name: "支付网关清算", AccountType: AccountTypeAsset, NormalBalance: "debit", OwnerType: "platform"}, nil
+	case AccountStudentPayments:
+		return &Account{Code: code, Name: "学生预付款", AccountType: AccountTypeLiability, NormalBalance: "credit", OwnerType: "platform"}, nil
+	case AccountPlatformFees:
+		return &Account{Code: code, Name: "平台服务费", AccountType: AccountTypeRevenue, NormalBalance: "credit", OwnerType: "platform"}, nil
+	}
+
+	parts := strings.Split(code, ":")
+	if len(parts) == 3 && parts[0] == "mentor" && parts[1] != "" {
+		switch parts[2] {
+		case "frozen":
+			return &Account{Code: code, Name: "大师待结算收入", AccountType: AccountTypeLiability, NormalBalance: "credit", OwnerType: "mentor", OwnerID: parts[1]}, nil
+		case "payable":
+			return &Account{Code: code, Name: "大师应付款", AccountType: AccountTypeLiability, NormalBalance: "credit", OwnerType: "mentor", OwnerID: parts[1]}, nil
+		case "withdrawals_in_transit":
+			return &Account{Code: code, Name: "大师提现在途", AccountType: AccountTypeLiability, NormalBalance: "credit", OwnerType: "mentor", OwnerID: parts[1]}, nil
+		}
+	}
+	return nil, fmt.Errorf("未知的记账账户: %s", code)
+}
+
+// IsZero 判断凭证是否所有分录金额均为0，如免费订单，此时无需记账
+func (t *Transaction) IsZero() bool {
+	for _, p := range t.Postings {
+		if p.Amount != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate 校验凭证至少包含两条非零分录且借贷平衡，并去除金额为0的分录
+func (t *Transaction) Validate() error {
+	if t.IdempotencyKey == "" || t.Type == "" {
+		return errors.New("记账凭证缺少幂等键或类型")
+	}
+
+	postings := t.Postings[:0]
+	var sum int64
+	for _, p := range t.Postings {
+		if p.Amount == 0 {
+			continue
+		}
+		if _, err := LookupAccount(p.Account); err != nil {
+			return err
+		}
+		sum += p.Amount
+		postings = append(postings, p)
+	}
+	t.Postings = postings
+	if len(postings) < 2 || sum != 0 {
+		return ErrUnbalanced
+	}
+	if t.Currency == "" {
+		t.Currency = DefaultCurrency
+	}
+	return nil
+}
+
+// ToMinor 金额转换为分
+func ToMinor(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// FromMinor 分转换为金额
+func FromMinor(amount int64) float64 {
+	return float64(amount) / 100
+}
+
+// PaymentReceived 学生支付完成：网关清算资金增加，同时形成对学生的预收负债
+func PaymentReceived(orderID string, amount int64, at time.Time) *Transaction {
+	return &Transaction{
+		IdempotencyKey: "payment:" + orderID,
+		Type:           TypePaymentReceived,
+		Description:    "学生支付",
+		PaymentOrderID: orderID,
+		OccurredAt:     at,
+		Postings: []Posting{
+			{Account: AccountGatewayClearing, Amount: amount},
+			{Account: AccountStudentPayments, Amount: -amount},
+		},
+	}
+}
+
+// OrderFulfilled 订单履约：学生预付款转为大师待结算收入和平台服务费
+func OrderFulfilled(orderID, mentorID string, amount, fee int64, at time.Time) *Transaction {
+	return &Transaction{
+		IdempotencyKey: "fulfill:" + orderID,
+		Type:           TypeOrderFulfilled,
+		Description:    "订单履约入账",
+		PaymentOrderID: orderID,
+		MentorID:       mentorID,
+		OccurredAt:     at,
+		Postings: []Posting{
+			{Account: AccountStudentPayments, Amount: amount},
+			{Account: MentorFrozen(mentorID), Amount: -(amount - fee)},
+			{Account: AccountPlatformFees, Amount: -fee},
+		},
+	}
+}
+
+// IncomeSettled 收入结算：结算期满后大师待结算收入转为可提现的应付款，金额为扣除结算前退款后的净收入
+func IncomeSettled(incomeID, orderID, mentorID string, amount int64, at time.Time) *Transaction {
+	return &Transaction{
+		IdempotencyKey: "settle:" + incomeID,
+		Type:           TypeIncomeSettled,
+		Description:    "收入结算",
+		PaymentOrderID: orderID,
+		MentorID:       mentorID,
+		OccurredAt:     at,
+		Postings: []Posting{
+			{Account: MentorFrozen(mentorID), Amount: amount},
+			{Account: MentorPayable(mentorID), Amount: -amount},
+		},
+	}
+}
+
+// IncomeReversed 退款冲正：按冲正金额和退还的平台费把大师收入和服务费转回学生预付款，金额均为正数
+// 收入尚在结算期内时从待结算收入扣回，已结算时从应付款扣回，应付款可因此为借方余额（大师欠款），由后续收入抵扣
+func IncomeReversed(refundID, orderID, mentorID string, amount, fee int64, settled bool, at time.Time) *Transaction {
+	account := MentorFrozen(mentorID)
+	if settled {
+		account = MentorPayable(mentorID)
+	}
+	return &Transaction{
+		IdempotencyKey: "reversal:" + refundID,
+		Type:           TypeIncomeReversed,
+		Description:    "退款冲正收入",
+		PaymentOrderID: orderID,
+		RefundID:       refundID,
+		MentorID:       mentorID,
+		OccurredAt:     at,
+		Postings: []Posting{
+			{Account: account, Amount: amount - fee},
+			{Account: AccountPlatformFees, Amount: fee},
+			{Account: AccountStudentPayments, Amount: -amount},
+		},
+	}
+}
+
+// RefundPaid 退款完成：网关清算资金退回学生，预收负债减少
+func RefundPaid(refundID, orderID string, amount int64, at time.Time) *Transaction {
+	return &Transaction{
+		IdempotencyKey: "refund:" + refundID,
+		Type:           TypeRefundPaid,
+		Description:    "退款完成",
+		PaymentOrderID: orderID,
+		RefundID:       refundID,
+		OccurredAt:     at,
+		Postings: []Posting{
+			{Account: AccountStudentPayments, Amount: amount},
+			{Account: AccountGatewayClearing, Amount: -amount},
+		},
+	}
+}
+
+// WithdrawalRequested 申请提现：大师应付款转入提现在途
+func WithdrawalRequested(withdrawalID, mentorID string, amount int64, at time.Time) *Transaction {
+	return &Transaction{
+		IdempotencyKey: "withdrawal:" + withdrawalID + ":requested",
+		Type:           TypeWithdrawalRequested,
+		Description:    "申请提现",
+		WithdrawalID:   withdrawalID,
+		MentorID:       mentorID,
+		OccurredAt:     at,
+		Postings: []Posting{
+			{Account: MentorPayable(mentorID), Amount: amount},
+			{Account: MentorWithdrawalsInTransit(mentorID), Amount: -amount},
+		},
+	}
+}
+
+// WithdrawalPaid 提现打款：在途金额扣除手续费后从网关清算资金付出，手续费计入平台服务费
+func WithdrawalPaid(withdrawalID, mentorID string, amount, fee int64, at time.Time) *Transaction {
+	return &Transaction{
+		IdempotencyKey: "withdrawal:" + withdrawalID + ":paid",
+		Type:           TypeWithdrawalPaid,
+		Description:    "提现打款",
+		WithdrawalID:   withdrawalID,
+		MentorID:       mentorID,
+		OccurredAt:     at,
+		Postings: []Posting{
+			{Account: MentorWithdrawalsInTransit(mentorID), Amount: amount},
+			{Account: AccountGatewayClearing, Amount: -(amount - fee)},
+			{Account: AccountPlatformFees, Amount: -fee},
+		},
+	}
+}
+
+// WithdrawalFailed 提现失败：在途金额退回大师应付款
+func WithdrawalFailed(withdrawalID, mentorID string, amount int64, at time.Time) *Transaction {
+	return &Transaction{
+		IdempotencyKey: "withdrawal:" + withdrawalID + ":failed",
+		Type:           TypeWithdrawalFailed,
+		Description:    "提现失败退回",
+		WithdrawalID:   withdrawalID,
+		MentorID:       mentorID,
+		OccurredAt:     at,
+		Postings: []Posting{
+			{Account: MentorWithdrawalsInTransit(mentorID), Amount: amount},
+			{Account: MentorPayable(mentorID), Amount: -amount},
+		},
+	}
+}
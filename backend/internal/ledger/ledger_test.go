@@ -0,0 +1,200 @@
+package ledger
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTransactionValidate(t *testing.T) {
+	tests := []struct {
+		name         string
+		txn          Transaction
+		wantErr      error
+		wantPostings int
+	}{
+		{
+			name: "balanced",
+			txn: Transaction{IdempotencyKey: "k", Type: TypePaymentReceived, Postings: []Posting{
+				{Account: AccountGatewayClearing, Amount: 100},
+				{Account: AccountStudentPayments, Amount: -100},
+			}},
+			wantPostings: 2,
+		},
+		{
+			name: "zero postings dropped",
+			txn: Transaction{IdempotencyKey: "k", Type: TypeOrderFulfilled, Postings: []Posting{
+				{Account: AccountStudentPayments, Amount: 100},
+				{Account: MentorFrozen("m1"), Amount: -100},
+				{Account: AccountPlatformFees, Amount: 0},
+			}},
+			wantPostings: 2,
+		},
+		{
+			name: "unbalanced",
+			txn: Transaction{IdempotencyKey: "k", Type: TypePaymentReceived, Postings: []Posting{
+				{Account: AccountGatewayClearing, Amount: 100},
+				{Account: AccountStudentPayments, Amount: -99},
+			}},
+			wantErr: ErrUnbalanced,
+		},
+		{
+			name: "single posting",
+			txn: Transaction{IdempotencyKey: "k", Type: TypePaymentReceived, Postings: []Posting{
+				{Account: AccountGatewayClearing, Amount: 100},
+				{Account: AccountStudentPayments, Amount: 0},
+			}},
+			wantErr: ErrUnbalanced,
+		},
+		{
+			name:    "all zero",
+			txn:     Transaction{IdempotencyKey: "k", Type: TypePaymentReceived, Postings: []Posting{{Account: AccountGatewayClearing}, {Account: AccountStudentPayments}}},
+			wantErr: ErrUnbalanced,
+		},
+		{
+			name: "unknown account",
+			txn: Transaction{IdempotencyKey: "k", Type: TypePaymentReceived, Postings: []Posting{
+				{Account: "platform:unknown", Amount: 100},
+				{Account: AccountStudentPayments, Amount: -100},
+			}},
+			wantErr: errors.New("未知的记账账户: platform:unknown"),
+		},
+		{
+			name: "missing idempotency key",
+			txn: Transaction{Type: TypePaymentReceived, Postings: []Posting{
+				{Account: AccountGatewayClearing, Amount: 100},
+				{Account: AccountStudentPayments, Amount: -100},
+			}},
+			wantErr: errors.New("记账凭证缺少幂等键或类型"),
+		},
+		{
+			name: "missing type",
+			txn: Transaction{IdempotencyKey: "k", Postings: []Posting{
+				{Account: AccountGatewayClearing, Amount: 100},
+				{Account: AccountStudentPayments, Amount: -100},
+			}},
+			wantErr: errors.New("记账凭证缺少幂等键或类型"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txn := tt.txn
+			err := txn.Validate()
+			if tt.wantErr != nil {
+				if err == nil || err.Error() != tt.wantErr.Error() {
+					t.Fatalf("Validate() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if len(txn.Postings) != tt.wantPostings {
+				t.Fatalf("Validate() kept %d postings, want %d", len(txn.Postings), tt.wantPostings)
+			}
+			if txn.Currency != DefaultCurrency {
+				t.Fatalf("Currency = %q, want %q", txn.Currency, DefaultCurrency)
+			}
+		})
+	}
+}
+
+func TestTransactionValidateKeepsCurrency(t *testing.T) {
+	txn := PaymentReceived("order-1", 100, time.Now())
+	txn.Currency = "USD"
+	if err := txn.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if txn.Currency != "USD" {
+		t.Fatalf("Currency = %q, want USD", txn.Currency)
+	}
+}
+
+func TestToMinor(t *testing.T) {
+	tests := []struct {
+		amount float64
+		want   int64
+	}{
+		{amount: 0, want: 0},
+		{amount: 0.01, want: 1},
+		{amount: 0.1 + 0.2, want: 30},
+		{amount: 19.99, want: 1999},
+		{amount: 199, want: 19900},
+		{amount: 1.155, want: 116},
+		{amount: 0.004, want: 0},
+		{amount: 0.005, want: 1},
+		{amount: -12.34, want: -1234},
+		{amount: 99999999.99, want: 9999999999},
+	}
+	for _, tt := range tests {
+		if got := ToMinor(tt.amount); got != tt.want {
+			t.Errorf("ToMinor(%v) = %d, want %d", tt.amount, got, tt.want)
+		}
+		if got := ToMinor(FromMinor(tt.want)); got != tt.want {
+			t.Errorf("ToMinor(FromMinor(%d)) = %d", tt.want, got)
+		}
+	}
+}
+
+func TestTransactionBuildersBalance(t *testing.T) {
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		txn          *Transaction
+		wantPostings int
+	}{
+		{name: "payment received", txn: PaymentReceived("o1", 19900, at), wantPostings: 2},
+		{name: "order fulfilled", txn: OrderFulfilled("o1", "m1", 19900, 1990, at), wantPostings: 3},
+		{name: "order fulfilled without fee", txn: OrderFulfilled("o1", "m1", 19900, 0, at), wantPostings: 2},
+		{name: "income settled", txn: IncomeSettled("i1", "o1", "m1", 17910, at), wantPostings: 2},
+		{name: "income reversed before settlement", txn: IncomeReversed("r1", "o1", "m1", 9950, 995, false, at), wantPostings: 3},
+		{name: "income reversed after settlement", txn: IncomeReversed("r1", "o1", "m1", 9950, 995, true, at), wantPostings: 3},
+		{name: "refund paid", txn: RefundPaid("r1", "o1", 9950, at), wantPostings: 2},
+		{name: "withdrawal requested", txn: WithdrawalRequested("w1", "m1", 10000, at), wantPostings: 2},
+		{name: "withdrawal paid", txn: WithdrawalPaid("w1", "m1", 10000, 100, at), wantPostings: 3},
+		{name: "withdrawal failed", txn: WithdrawalFailed("w1", "m1", 10000, at), wantPostings: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.txn.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if len(tt.txn.Postings) != tt.wantPostings {
+				t.Fatalf("postings = %+v, want %d", tt.txn.Postings, tt.wantPostings)
+			}
+		})
+	}
+}
+
+func TestLookupAccount(t *testing.T) {
+	tests := []struct {
+		code      string
+		wantType  string
+		wantOwner string
+		wantErr   bool
+	}{
+		{code: AccountGatewayClearing, wantType: AccountTypeAsset},
+		{code: AccountStudentPayments, wantType: AccountTypeLiability},
+		{code: AccountPlatformFees, wantType: AccountTypeRevenue},
+		{code: MentorFrozen("m1"), wantType: AccountTypeLiability, wantOwner: "m1"},
+		{code: MentorPayable("m1"), wantType: AccountTypeLiability, wantOwner: "m1"},
+		{code: MentorWithdrawalsInTransit("m1"), wantType: AccountTypeLiability, wantOwner: "m1"},
+		{code: "mentor::payable", wantErr: true},
+		{code: "mentor:m1:unknown", wantErr: true},
+		{code: "mentor:m1:payable:extra", wantErr: true},
+		{code: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		account, err := LookupAccount(tt.code)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("LookupAccount(%q) = %+v, want error", tt.code, account)
+			}
+			continue
+		}
+		if err != nil || account.AccountType != tt.wantType || account.OwnerID != tt.wantOwner {
+			t.Errorf("LookupAccount(%q) = %+v, %v; want type %s owner %q", tt.code, account, err, tt.wantType, tt.wantOwner)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package meeting
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// 会议参与方角色
+const (
+	RoleHost  = "host"  // 大师
+	RoleGuest = "guest" // 学生
+)
+
+// 会议服务错误
+var (
+	ErrRoomNotFound = errors.New("meeting room not found")
+	ErrInvalidToken = errors.New("invalid meeting token")
+	ErrTokenExpired = errors.New("meeting token expired")
+	ErrTokenNotYet  = errors.New("meeting token not yet valid")
+	ErrRoomFull     = errors.New("meeting room is full")
+	ErrNotSupported = errors.New("action not supported by meeting provider")
+)
+
+// Participant 会议参与方
+type Participant struct {
+	UserID      string
+	Role        string
+	DisplayName string
+}
+
+// RoomRequest 创建会议室请求
+type RoomRequest struct {
+	AppointmentID string
+	Title         string
+	StartsAt      time.Time
+	EndsAt        time.Time
+	NotBefore     time.Time // 最早可进入时间
+	ExpiresAt     time.Time // 进入会议的截止时间，入会凭证在此之后失效
+	Participants  []Participant
+}
+
+// ParticipantAccess 参与方的入会地址和凭证
+type ParticipantAccess struct {
+	UserID  string
+	Role    string
+	JoinURL string
+	Token   string
+}
+
+// Room 会议服务创建的会议室
+type Room struct {
+	RoomID       string // 会议服务侧的会议室ID
+	SignalingURL string // 信令地址，仅自建会议服务提供
+	Participants []ParticipantAccess
+}
+
+// Claims 已校验的入会凭证内容
+type Claims struct {
+	RoomID    string `json:"room"`
+	UserID    string `json:"sub"`
+	Role      string `json:"role"`
+	NotBefore int64  `json:"nbf"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// MeetingProvider 视频会议服务适配接口
+type MeetingProvider interface {
+	// Name 会议服务名称，记录在会议室上
+	Name() string
+	// CreateRoom 创建会议室，并为每个参与方生成入会地址和凭证
+	CreateRoom(ctx context.Context, req *RoomRequest) (*Room, error)
+	// CloseRoom 关闭会议室，已入会的参与方被断开，会议室不存在时不返回错误
+	CloseRoom(ctx context.Context, roomID string) error
+}
+
+// TokenVerifier 由自行校验入会凭证的会议服务实现，供本服务的信令接口使用
+type TokenVerifier interface {
+	VerifyToken(token string, now time.Time) (*Claims, error)
+}
@@ -0,0 +1,152 @@
+package meeting
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SelfHostedName 自建会议服务名称
+const SelfHostedName = "selfhosted"
+
+// selfHostedMaxPeers 一对一咨询会议室的最大参与方数
+const selfHostedMaxPeers = 2
+
+// SelfHostedConfig 自建会议服务配置
+type SelfHostedConfig struct {
+	Secret       string // 入会凭证签名密钥
+	JoinPageURL  string // 前端会议页面，入会地址为 {join_page_url}/{room_id}?token={token}
+	SignalingURL string // 信令地址前缀，会议室信令地址为 {signaling_url}/{room_id}
+}
+
+// SelfHostedProvider 无需外部服务的自建会议服务
+// 入会凭证为 HMAC-SHA256 签名的令牌，由本服务的 WebSocket 信令接口校验，音视频由参与方之间通过 WebRTC 直连传输
+type SelfHostedProvider struct {
+	config SelfHostedConfig
+	hub    *SignalingHub
+}
+
+// NewSelfHostedProvider 创建自建会议服务
+func NewSelfHostedProvider(cfg SelfHostedConfig) (*SelfHostedProvider, error) {
+	if cfg.Secret == "" {
+		return nil, errors.New("selfhosted meeting secret is required")
+	}
+	cfg.JoinPageURL = strings.TrimRight(cfg.JoinPageURL, "/")
+	cfg.SignalingURL = strings.TrimRight(cfg.SignalingURL, "/")
+	return &SelfHostedProvider{
+		config: cfg,
+		hub:    NewSignalingHub(selfHostedMaxPeers),
+	}, nil
+}
+
+// Name 会议服务名称
+func (p *SelfHostedProvider) Name() string {
+	return SelfHostedName
+}
+
+// Hub 信令中继
+func (p *SelfHostedProvider) Hub() *SignalingHub {
+	return p.hub
+}
+
+// CreateRoom 生成会议室ID，并为每个参与方签发在入会时间窗口内有效的凭证
+func (p *SelfHostedProvider) CreateRoom(ctx context.Context, req *RoomRequest) (*Room, error) {
+	roomID, err := newRoomID()
+	if err != nil {
+		return nil, err
+	}
+
+	room := &Room{
+		RoomID:       roomID,
+		SignalingURL: p.config.SignalingURL + "/" + roomID,
+		Participants: make([]ParticipantAccess, 0, len(req.Participants)),
+	}
+	for _, participant := range req.Participants {
+		token, err := p.signToken(&Claims{
+			RoomID:    roomID,
+			UserID:    participant.UserID,
+			Role:      participant.Role,
+			NotBefore: req.NotBefore.Unix(),
+			ExpiresAt: req.ExpiresAt.Unix(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		room.Participants = append(room.Participants, ParticipantAccess{
+			UserID:  participant.UserID,
+			Role:    participant.Role,
+			JoinURL: p.config.JoinPageURL + "/" + roomID + "?token=" + url.QueryEscape(token),
+			Token:   token,
+		})
+	}
+	return room, nil
+}
+
+// CloseRoom 断开会议室内的信令连接，已签发的凭证由调用方在校验时按会议室状态拒绝
+func (p *SelfHostedProvider) CloseRoom(ctx context.Context, roomID string) error {
+	p.hub.CloseRoom(roomID)
+	return nil
+}
+
+// VerifyToken 校验入会凭证的签名和有效期
+func (p *SelfHostedProvider) VerifyToken(token string, now time.Time) (*Claims, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(p.sign([]byte(payload)), expected) {
+		return nil, ErrInvalidToken
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(data, &claims); err != nil || claims.RoomID == "" || claims.UserID == "" {
+		return nil, ErrInvalidToken
+	}
+
+	if now.Unix() < claims.NotBefore {
+		return nil, ErrTokenNotYet
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, ErrTokenExpired
+	}
+	return &claims, nil
+}
+
+// signToken 生成凭证：Base64URL(JSON) + "." + 十六进制 HMAC-SHA256 签名
+func (p *SelfHostedProvider) signToken(claims *Claims) (string, error) {
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + hex.EncodeToString(p.sign([]byte(payload))), nil
+}
+
+// sign 计算 HMAC-SHA256 签名
+func (p *SelfHostedProvider) sign(data []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(p.config.Secret))
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// newRoomID 生成不可猜测的会议室ID
+func newRoomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
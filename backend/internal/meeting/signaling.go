@@ -0,0 +1,293 @@
+package meeting
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 信令连接参数
+const (
+	signalingWriteWait  = 10 * time.Second
+	signalingPongWait   = 60 * time.Second
+	signalingPingPeriod = 54 * time.Second
+	signalingReadLimit  = 64 * 1024 // SDP 可能达到数KB
+	signalingSendBuffer = 32
+)
+
+// 信令消息类型
+const (
+	SignalJoined     = "joined"      // 服务端：入会成功，附带已在会议室的参与方
+	SignalPeerJoined = "peer_joined" // 服务端：其他参与方入会
+	SignalPeerLeft   = "peer_left"   // 服务端：其他参与方离开
+	SignalRoomClosed = "room_closed" // 服务端：会议室已关闭或入会凭证到期
+	SignalError      = "error"       // 服务端：错误，随后断开连接
+	SignalOffer      = "offer"       // 参与方之间转发：SDP offer
+	SignalAnswer     = "answer"      // 参与方之间转发：SDP answer
+	SignalCandidate  = "candidate"   // 参与方之间转发：ICE candidate
+	SignalHangup     = "hangup"      // 参与方之间转发：挂断
+)
+
+// relaySignals 可在参与方之间转发的消息类型
+var relaySignals = map[string]bool{
+	SignalOffer:     true,
+	SignalAnswer:    true,
+	SignalCandidate: true,
+	SignalHangup:    true,
+}
+
+// SignalPeer 会议室内的参与方
+type SignalPeer struct {
+	UserID string `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+// SignalMessage 信令消息，参与方发送的消息由服务端填写 from 后转发，to 为空时转发给其他所有参与方
+type SignalMessage struct {
+	Type    string          `json:"type"`
+	From    string          `json:"from,omitempty"`
+	To      string          `json:"to,omitempty"`
+	Peers   []SignalPeer    `json:"peers,omitempty"`
+	Message string          `json:"message,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// SignalingHub 自建会议的信令中继，在同一会议室的参与方之间转发 WebRTC 协商消息
+// 连接只保存在当前实例内存中，多实例部署时同一会议室的参与方需连接到同一实例
+type SignalingHub struct {
+	maxPeers int
+
+	mu    sync.Mutex
+	rooms map[string]map[string]*signalingPeer // key: 会议室ID -> 用户ID
+}
+
+// signalingPeer 一个参与方的信令连接
+type signalingPeer struct {
+	roomID string
+	userID string
+	role   string
+	conn   *websocket.Conn
+	send   chan []byte
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewSignalingHub 创建信令中继，maxPeers 为每个会议室的最大参与方数
+func NewSignalingHub(maxPeers int) *SignalingHub {
+	if maxPeers <= 0 {
+		maxPeers = 2
+	}
+	return &SignalingHub{
+		maxPeers: maxPeers,
+		rooms:    make(map[string]map[string]*signalingPeer),
+	}
+}
+
+// Serve 以已校验的入会凭证加入会议室并处理信令消息，连接断开后返回
+// 同一用户重复入会时替换旧连接；入会凭证到期时断开连接
+func (h *SignalingHub) Serve(conn *websocket.Conn, claims *Claims) {
+	peer := &signalingPeer{
+		roomID: claims.RoomID,
+		userID: claims.UserID,
+		role:   claims.Role,
+		conn:   conn,
+		send:   make(chan []byte, signalingSendBuffer),
+		done:   make(chan struct{}),
+	}
+	go peer.writePump()
+
+	others, err := h.join(peer)
+	if err != nil {
+		peer.deliver(&SignalMessage{Type: SignalError, Message: "会议室人数已满"})
+		peer.close()
+		return
+	}
+	defer h.leave(peer)
+
+	peer.deliver(&SignalMessage{Type: SignalJoined, Peers: others})
+	h.broadcast(peer, &SignalMessage{Type: SignalPeerJoined, From: peer.userID, Peers: []SignalPeer{{UserID: peer.userID, Role: peer.role}}})
+
+	expiry := time.AfterFunc(time.Until(time.Unix(claims.ExpiresAt, 0)), func() {
+		peer.deliver(&SignalMessage{Type: SignalRoomClosed, Message: "入会凭证已过期"})
+		peer.close()
+	})
+	defer expiry.Stop()
+
+	peer.readPump(func(message *SignalMessage) {
+		if !relaySignals[message.Type] {
+			peer.deliver(&SignalMessage{Type: SignalError, Message: "不支持的消息类型"})
+			return
+		}
+		message.From = peer.userID
+		message.Peers = nil
+		message.Message = ""
+		h.broadcast(peer, message)
+	})
+}
+
+// CloseRoom 通知会议室内的参与方会议已关闭并断开连接
+func (h *SignalingHub) CloseRoom(roomID string) {
+	h.mu.Lock()
+	peers := make([]*signalingPeer, 0, len(h.rooms[roomID]))
+	for _, peer := range h.rooms[roomID] {
+		peers = append(peers, peer)
+	}
+	delete(h.rooms, roomID)
+	h.mu.Unlock()
+
+	for _, peer := range peers {
+		peer.deliver(&SignalMessage{Type: SignalRoomClosed, Message: "会议已关闭"})
+		peer.close()
+	}
+}
+
+// join 加入会议室，返回已在会议室的其他参与方
+func (h *SignalingHub) join(peer *signalingPeer) ([]SignalPeer, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	room, ok := h.rooms[peer.roomID]
+	if !ok {
+		room = make(map[string]*signalingPeer)
+		h.rooms[peer.roomID] = room
+	}
+
+	if previous, ok := room[peer.userID]; ok {
+		previous.deliver(&SignalMessage{Type: SignalRoomClosed, Message: "已在其他设备入会"})
+		previous.close()
+		delete(room, peer.userID)
+	}
+	if len(room) >= h.maxPeers {
+		return nil, ErrRoomFull
+	}
+
+	others := make([]SignalPeer, 0, len(room))
+	for _, other := range room {
+		others = append(others, SignalPeer{UserID: other.userID, Role: other.role})
+	}
+	room[peer.userID] = peer
+	return others, nil
+}
+
+// leave 离开会议室，并通知其他参与方
+func (h *SignalingHub) leave(peer *signalingPeer) {
+	peer.close()
+
+	h.mu.Lock()
+	room := h.rooms[peer.roomID]
+	current, ok := room[peer.userID]
+	if !ok || current != peer {
+		// 已被新连接替换或会议室已关闭
+		h.mu.Unlock()
+		return
+	}
+	delete(room, peer.userID)
+	if len(room) == 0 {
+		delete(h.rooms, peer.roomID)
+	}
+	h.mu.Unlock()
+
+	h.broadcast(peer, &SignalMessage{Type: SignalPeerLeft, From: peer.userID})
+}
+
+// broadcast 将消息发送给会议室内除发送方以外的参与方，指定 To 时只发送给该参与方
+func (h *SignalingHub) broadcast(from *signalingPeer, message *SignalMessage) {
+	h.mu.Lock()
+	targets := make([]*signalingPeer, 0, len(h.rooms[from.roomID]))
+	for userID, peer := range h.rooms[from.roomID] {
+		if userID == from.userID || (message.To != "" && userID != message.To) {
+			continue
+		}
+		targets = append(targets, peer)
+	}
+	h.mu.Unlock()
+
+	for _, peer := range targets {
+		peer.deliver(message)
+	}
+}
+
+// deliver 将消息放入发送队列，队列已满时断开连接
+func (p *signalingPeer) deliver(message *SignalMessage) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	select {
+	case <-p.done:
+	case p.send <- data:
+	default:
+		p.close()
+	}
+}
+
+// close 断开连接，可重复调用
+func (p *signalingPeer) close() {
+	p.closeOnce.Do(func() {
+		close(p.done)
+	})
+}
+
+// readPump 读取参与方发送的消息，连接断开或被关闭后返回
+func (p *signalingPeer) readPump(handle func(*SignalMessage)) {
+	p.conn.SetReadLimit(signalingReadLimit)
+	_ = p.conn.SetReadDeadline(time.Now().Add(signalingPongWait))
+	p.conn.SetPongHandler(func(string) error {
+		return p.conn.SetReadDeadline(time.Now().Add(signalingPongWait))
+	})
+
+	for {
+		_, data, err := p.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var message SignalMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			p.deliver(&SignalMessage{Type: SignalError, Message: "消息格式错误"})
+			continue
+		}
+		handle(&message)
+	}
+}
+
+// writePump 发送队列中的消息并定时发送 ping，连接关闭时先发送完已排队的消息
+func (p *signalingPeer) writePump() {
+	ticker := time.NewTicker(signalingPingPeriod)
+	defer func() {
+		ticker.Stop()
+		_ = p.conn.Close()
+	}()
+
+	for {
+		select {
+		case data := <-p.send:
+			_ = p.conn.SetWriteDeadline(time.Now().Add(signalingWriteWait))
+			if err := p.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				p.close()
+				return
+			}
+		case <-ticker.C:
+			_ = p.conn.SetWriteDeadline(time.Now().Add(signalingWriteWait))
+			if err := p.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				p.close()
+				return
+			}
+		case <-p.done:
+			for {
+				select {
+				case data := <-p.send:
+					_ = p.conn.SetWriteDeadline(time.Now().Add(signalingWriteWait))
+					if p.conn.WriteMessage(websocket.TextMessage, data) != nil {
+						return
+					}
+				default:
+					_ = p.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+					return
+				}
+			}
+		}
+	}
+}
@@ -5,16 +5,19 @@ import "time"
 // AppointmentModel 预约模型
 type AppointmentModel struct {
 	BaseModel
-	StudentID       string    `json:"student_id" gorm:"not null"`
-	MentorID        string    `json:"mentor_id" gorm:"not null"`
-	AppointmentTime time.Time `json:"appointment_time" gorm:"not null"`
-	DurationMinutes int       `json:"duration_minutes" gorm:"not null"`
-	MeetingType     string    `json:"meeting_type" gorm:"default:'video'"`
-	Status          string    `json:"status" gorm:"default:'pending'"`
-	Price           float64   `json:"price" gorm:"not null"`
-	Notes           string    `json:"notes" gorm:"type:text"`
-	CreatedAt       time.Time `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt       time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	StudentID             string    `json:"student_id" gorm:"not null"`
+	MentorID              string    `json:"mentor_id" gorm:"not null"`
+	AppointmentTime       time.Time `json:"appointment_time" gorm:"not null"`
+	DurationMinutes       int       `json:"duration_minutes" gorm:"not null"`
+	MeetingType           string    `json:"meeting_type" gorm:"default:'video'"`
+	Status                string    `json:"status" gorm:"default:'pending'"`
+	Price                 float64   `json:"price" gorm:"not null"`
+	Notes                 string    `json:"notes" gorm:"type:text"`
+	PackagePurchaseID     *string   `json:"package_purchase_id,omitempty"` // 使用套餐预约时扣减次数的套餐
+	PackageCreditReturned bool      `json:"-" gorm:"default:false"`        // 取消后已退回套餐次数
+	SeriesID              *string   `json:"series_id,omitempty"`           // 重复预约系列
+	CreatedAt             time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt             time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 
 	// 关联关系
 	Student *User   `json:"student,omitempty" gorm:"foreignKey:StudentID"`
@@ -25,3 +28,54 @@ type AppointmentModel struct {
 func (AppointmentModel) TableName() string {
 	return "appointments"
 }
+
+// 预约状态
+const (
+	AppointmentStatusPendingPayment = "pending_payment" // 待支付
+	AppointmentStatusPending        = "pending"         // 待大师确认
+	AppointmentStatusConfirmed      = "confirmed"
+	AppointmentStatusInProgress     = "in_progress"
+	AppointmentStatusCompleted      = "completed"
+	AppointmentStatusRejected       = "rejected" // 大师拒绝
+	AppointmentStatusCancelled      = "cancelled"
+	AppointmentStatusNoShow         = "no_show" // 约定时间未出席
+)
+
+// AppointmentActiveStatuses 占用大师和学生时间的预约状态
+var AppointmentActiveStatuses = []string{
+	AppointmentStatusPendingPayment,
+	AppointmentStatusPending,
+	AppointmentStatusConfirmed,
+	AppointmentStatusInProgress,
+}
+
+// 预约方式
+const (
+	MeetingTypeVideo = "video"
+	MeetingTypeVoice = "voice"
+	MeetingTypeText  = "text"
+)
+
+// 预约状态变更的操作方
+const (
+	AppointmentActorStudent = "student"
+	AppointmentActorMentor  = "mentor"
+	AppointmentActorSystem  = "system" // 支付、过期等系统流程
+)
+
+// AppointmentStatusHistory 预约状态变更记录
+type AppointmentStatusHistory struct {
+	ID            string    `json:"id" gorm:"primaryKey;type:varchar(32)"`
+	AppointmentID string    `json:"appointment_id" gorm:"not null"`
+	FromStatus    string    `json:"from_status"` // 创建预约时为空
+	ToStatus      string    `json:"to_status" gorm:"not null"`
+	ActorType     string    `json:"actor_type" gorm:"not null"`
+	ActorID       string    `json:"actor_id"` // 系统操作时为空
+	Reason        string    `json:"reason"`
+	CreatedAt     time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName 指定表名
+func (AppointmentStatusHistory) TableName() string {
+	return "appointment_status_history"
+}
@@ -0,0 +1,95 @@
+package model
+
+import (
+	"math"
+	"time"
+)
+
+// AppointmentPackage 大师发布的咨询套餐，如“10次每周咨询”
+type AppointmentPackage struct {
+	ID              string    `json:"id" gorm:"primaryKey;type:varchar(32)"`
+	MentorID        string    `json:"mentor_id" gorm:"not null"`
+	Title           string    `json:"title" gorm:"not null"`
+	Description     string    `json:"description" gorm:"type:text"`
+	SessionCount    int       `json:"session_count" gorm:"not null"`    // 包含的咨询次数
+	DurationMinutes int       `json:"duration_minutes" gorm:"not null"` // 每次咨询时长
+	DiscountRate    float64   `json:"discount_rate" gorm:"not null"`    // 套餐优惠比例，如 0.1 表示按原价九折
+	ValidityDays    int       `json:"validity_days" gorm:"not null"`    // 购买后多少天内有效
+	Status          string    `json:"status" gorm:"default:'active'"`
+	CreatedAt       time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (AppointmentPackage) TableName() string {
+	return "appointment_packages"
+}
+
+// 咨询套餐状态
+const (
+	AppointmentPackageStatusActive   = "active"
+	AppointmentPackageStatusInactive = "inactive" // 已下架，已购买的套餐不受影响
+)
+
+// AppointmentPackagePurchase 学生购买的咨询套餐，预约时按次扣减
+// 购买时记录套餐的次数、时长、价格和有效期，大师修改套餐不影响已购买的套餐
+type AppointmentPackagePurchase struct {
+	ID              string     `json:"id" gorm:"primaryKey;type:varchar(32)"`
+	PackageID       string     `json:"package_id" gorm:"not null"`
+	MentorID        string     `json:"mentor_id" gorm:"not null"`
+	StudentID       string     `json:"student_id" gorm:"not null"`
+	Title           string     `json:"title" gorm:"not null"`
+	TotalSessions   int        `json:"total_sessions" gorm:"not null"`
+	UsedSessions    int        `json:"used_sessions" gorm:"not null;default:0"`
+	RevokedSessions int        `json:"revoked_sessions" gorm:"not null;default:0"` // 退款后收回的次数
+	DurationMinutes int        `json:"duration_minutes" gorm:"not null"`
+	ValidityDays    int        `json:"validity_days" gorm:"not null"`
+	Price           float64    `json:"price" gorm:"not null"`
+	Status          string     `json:"status" gorm:"default:'pending_payment'"`
+	ActivatedAt     *time.Time `json:"activated_at"`
+	ExpiresAt       *time.Time `json:"expires_at"` // 支付完成后按有效天数计算，预约时间需早于该时间
+	CreatedAt       time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (AppointmentPackagePurchase) TableName() string {
+	return "appointment_package_purchases"
+}
+
+// RemainingSessions 剩余可预约次数
+func (p *AppointmentPackagePurchase) RemainingSessions() int {
+	return int(math.Max(0, float64(p.TotalSessions-p.UsedSessions-p.RevokedSessions)))
+}
+
+// SessionPrice 平均每次咨询的价格
+func (p *AppointmentPackagePurchase) SessionPrice() float64 {
+	if p.TotalSessions <= 0 {
+		return 0
+	}
+	return math.Round(p.Price/float64(p.TotalSessions)*100) / 100
+}
+
+// 已购买套餐状态
+const (
+	PackagePurchaseStatusPendingPayment = "pending_payment"
+	PackagePurchaseStatusActive         = "active"
+	PackagePurchaseStatusCancelled      = "cancelled" // 未完成支付
+	PackagePurchaseStatusRefunded       = "refunded"  // 已全额退款，次数全部收回
+)
+
+// AppointmentSeries 按重复规则批量创建的预约
+type AppointmentSeries struct {
+	ID                string    `json:"id" gorm:"primaryKey;type:varchar(32)"`
+	StudentID         string    `json:"student_id" gorm:"not null"`
+	MentorID          string    `json:"mentor_id" gorm:"not null"`
+	PackagePurchaseID string    `json:"package_purchase_id" gorm:"not null"`
+	Rule              string    `json:"rule" gorm:"not null"` // RRULE 格式，如 FREQ=WEEKLY;INTERVAL=1;BYDAY=MO,WE;COUNT=10
+	Timezone          string    `json:"timezone" gorm:"not null"`
+	CreatedAt         time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName 指定表名
+func (AppointmentSeries) TableName() string {
+	return "appointment_series"
+}
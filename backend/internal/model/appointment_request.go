@@ -4,11 +4,55 @@ import "time"
 
 // CreateAppointmentRequest 创建预约请求
 type CreateAppointmentRequest struct {
-	MentorID        string    `json:"mentor_id" binding:"required"`
+	MentorID          string    `json:"mentor_id" binding:"required"`
+	AppointmentTime   time.Time `json:"appointment_time" binding:"required"`
+	DurationMinutes   int       `json:"duration_minutes" binding:"required,min=15,max=480"`
+	MeetingType       string    `json:"meeting_type" binding:"required,oneof=video voice text"`
+	Notes             string    `json:"notes"`
+	PaymentMethod     string    `json:"payment_method" binding:"required_without=PackagePurchaseID"`
+	PackagePurchaseID string    `json:"package_purchase_id"` // 使用已购买套餐预约时无需支付，时长需与套餐一致
+}
+
+// CreateRecurringAppointmentRequest 使用已购买套餐创建重复预约请求
+type CreateRecurringAppointmentRequest struct {
+	MentorID             string          `json:"mentor_id" binding:"required"`
+	PackagePurchaseID    string          `json:"package_purchase_id" binding:"required"`
+	FirstAppointmentTime time.Time       `json:"first_appointment_time" binding:"required"` // 第一次预约的开始时间，需落在重复规则的某一天
+	MeetingType          string          `json:"meeting_type" binding:"required,oneof=video voice text"`
+	Notes                string          `json:"notes"`
+	Recurrence           *RecurrenceRule `json:"recurrence" binding:"required"`
+}
+
+// RecurrenceRule 重复规则，参照 RRULE 的 FREQ、INTERVAL、BYDAY、COUNT、UNTIL，按大师时区展开
+// Count 和 Until 至少提供一个，同时提供时以先达到的为准
+type RecurrenceRule struct {
+	Frequency string     `json:"frequency" binding:"required,oneof=weekly"`
+	Interval  int        `json:"interval" binding:"omitempty,min=1,max=4"` // 每隔几周，默认 1
+	ByDay     []string   `json:"by_day" binding:"required,min=1,max=7,dive,oneof=MO TU WE TH FR SA SU"`
+	Count     int        `json:"count" binding:"required_without=Until,omitempty,min=2,max=52"` // 共预约几次
+	Until     *time.Time `json:"until" binding:"required_without=Count"`                        // 最后一次预约的开始时间不晚于该时间
+}
+
+// RescheduleAppointmentRequest 预约改期请求
+type RescheduleAppointmentRequest struct {
 	AppointmentTime time.Time `json:"appointment_time" binding:"required"`
-	DurationMinutes int       `json:"duration_minutes" binding:"required,min=15,max=480"`
-	MeetingType     string    `json:"meeting_type" binding:"required,oneof=video voice text"`
-	Notes           string    `json:"notes"`
+	Reason          string    `json:"reason" binding:"max=500"`
+}
+
+// AppointmentPackageRequest 创建或更新咨询套餐请求
+type AppointmentPackageRequest struct {
+	Title           string  `json:"title" binding:"required,max=200"`
+	Description     string  `json:"description" binding:"max=2000"`
+	SessionCount    int     `json:"session_count" binding:"required,min=2,max=100"`
+	DurationMinutes int     `json:"duration_minutes" binding:"required,min=15,max=480"`
+	DiscountRate    float64 `json:"discount_rate" binding:"min=0,max=0.9"`
+	ValidityDays    int     `json:"validity_days" binding:"required,min=7,max=730"`
+	Status          string  `json:"status" binding:"omitempty,oneof=active inactive"` // 默认 active
+}
+
+// PurchaseAppointmentPackageRequest 购买咨询套餐请求
+type PurchaseAppointmentPackageRequest struct {
+	PaymentMethod string `json:"payment_method" binding:"required"`
 }
 
 // AppointmentListRequest 获取预约列表请求
@@ -20,5 +64,11 @@ type AppointmentListRequest struct {
 
 // UpdateAppointmentStatusRequest 更新预约状态请求
 type UpdateAppointmentStatusRequest struct {
-	Status string `json:"status" binding:"required,oneof=pending confirmed completed cancelled"`
+	Status string `json:"status" binding:"required,oneof=confirmed in_progress completed rejected cancelled no_show"`
+	Reason string `json:"reason" binding:"max=500"` // 拒绝、取消和未出席时必填
+}
+
+// CancelAppointmentRequest 取消预约请求
+type CancelAppointmentRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
 }
@@ -31,20 +31,47 @@ type AppointmentDetail struct {
 
 // CreateAppointmentResponse 创建预约响应
 type CreateAppointmentResponse struct {
-	AppointmentID string  `json:"appointment_id"`
-	Status        string  `json:"status"`
-	Price         float64 `json:"price"`
+	AppointmentID string                      `json:"appointment_id"`
+	Status        string                      `json:"status"`
+	Price         float64                     `json:"price"`
+	Payment       *CreatePaymentOrderResponse `json:"payment,omitempty"` // 待支付时返回支付信息
+}
+
+// CreateRecurringAppointmentResponse 创建重复预约响应
+type CreateRecurringAppointmentResponse struct {
+	SeriesID          string                       `json:"series_id"`
+	Rule              string                       `json:"rule"`
+	Appointments      []*CreateAppointmentResponse `json:"appointments"`
+	RemainingSessions int                          `json:"remaining_sessions"` // 套餐剩余可预约次数
+}
+
+// RescheduleAppointmentResponse 预约改期响应
+type RescheduleAppointmentResponse struct {
+	AppointmentID   string    `json:"appointment_id"`
+	Status          string    `json:"status"`
+	AppointmentTime time.Time `json:"appointment_time"`
 }
 
 // UpdateAppointmentStatusResponse 更新预约状态响应
 type UpdateAppointmentStatusResponse struct {
-	AppointmentID string `json:"appointment_id"`
-	Status        string `json:"status"`
+	AppointmentID         string              `json:"appointment_id"`
+	Status                string              `json:"status"`
+	Refund                *CancellationRefund `json:"refund,omitempty"`                  // 已支付的预约被拒绝或取消时返回
+	PackageCreditReturned bool                `json:"package_credit_returned,omitempty"` // 套餐预约按退款策略可全额退款时退回套餐次数
 }
 
 // CancelAppointmentResponse 取消预约响应
 type CancelAppointmentResponse struct {
-	AppointmentID string `json:"appointment_id"`
+	AppointmentID         string              `json:"appointment_id"`
+	Status                string              `json:"status"`
+	Refund                *CancellationRefund `json:"refund,omitempty"`                  // 已支付的预约取消时返回
+	PackageCreditReturned bool                `json:"package_credit_returned,omitempty"` // 套餐预约按退款策略可全额退款时退回套餐次数
+}
+
+// AppointmentStatusHistoryResponse 预约状态变更记录响应
+type AppointmentStatusHistoryResponse struct {
+	AppointmentID string                      `json:"appointment_id"`
+	History       []*AppointmentStatusHistory `json:"history"`
 }
 
 // AppointmentListResponse 预约列表响应
@@ -73,3 +100,28 @@ type MentorAppointmentStats struct {
 type MentorAppointmentStatsResponse struct {
 	Stats *MentorAppointmentStats `json:"stats"`
 }
+
+// AppointmentPackageInfo 咨询套餐信息，价格按大师当前时薪计算
+type AppointmentPackageInfo struct {
+	*AppointmentPackage
+	OriginalPrice float64 `json:"original_price"` // 按次单独预约的总价
+	Price         float64 `json:"price"`          // 套餐优惠后的价格
+}
+
+// AppointmentPackageListResponse 咨询套餐列表响应
+type AppointmentPackageListResponse struct {
+	Packages []*AppointmentPackageInfo `json:"packages"`
+}
+
+// PurchaseAppointmentPackageResponse 购买咨询套餐响应
+type PurchaseAppointmentPackageResponse struct {
+	PurchaseID string                      `json:"purchase_id"`
+	Status     string                      `json:"status"`
+	Price      float64                     `json:"price"`
+	Payment    *CreatePaymentOrderResponse `json:"payment,omitempty"`
+}
+
+// PackagePurchaseListResponse 已购买套餐列表响应
+type PackagePurchaseListResponse struct {
+	Purchases []*AppointmentPackagePurchase `json:"purchases"`
+}
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// 审计操作类型
+const (
+	AuditOperationCreate = "CREATE"
+	AuditOperationUpdate = "UPDATE"
+	AuditOperationDelete = "DELETE"
+)
+
+// AuditLog 审计日志模型
+type AuditLog struct {
+	ID        string    `json:"id" gorm:"primaryKey;type:varchar(32)"`
+	UserID    *string   `json:"user_id"`
+	Table     string    `json:"table_name" gorm:"column:table_name;not null"`
+	RecordID  string    `json:"record_id" gorm:"not null"`
+	Operation string    `json:"operation" gorm:"not null"`
+	OldValues JSONMap   `json:"old_values" gorm:"type:jsonb"`
+	NewValues JSONMap   `json:"new_values" gorm:"type:jsonb"`
+	IPAddress *string   `json:"ip_address" gorm:"type:inet"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName 指定表名
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 // RegisterRequest 注册请求
 type RegisterRequest struct {
 	Email           string          `json:"email" binding:"required,email"`
@@ -32,10 +34,41 @@ type ChangePasswordRequest struct {
 	NewPassword     string `json:"new_password" binding:"required,min=8"`
 }
 
+// ForgotPasswordRequest 忘记密码请求
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest 重置密码请求
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8"`
+}
+
+// LoginLockedResponse 登录被临时锁定时的响应数据
+type LoginLockedResponse struct {
+	Scope      string `json:"scope"`       // account: 账户锁定, ip: IP锁定
+	RetryAfter int    `json:"retry_after"` // 剩余锁定时间（秒）
+}
+
+// RefreshTokenRequest 刷新Token请求
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// ClientInfo 登录客户端信息
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
 // AuthResponse 认证响应
 type AuthResponse struct {
 	UserID          string         `json:"user_id"`
 	Token           string         `json:"token"`
+	RefreshToken    string         `json:"refresh_token,omitempty"`
+	ExpiresIn       int            `json:"expires_in"`
+	SessionID       string         `json:"session_id"`
 	CurrentIdentity *IdentityInfo  `json:"current_identity,omitempty"`
 	Identities      []IdentityInfo `json:"identities,omitempty"`
 }
@@ -50,5 +83,24 @@ type IdentityInfo struct {
 
 // TokenResponse Token响应
 type TokenResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// SessionInfo 登录会话信息
+type SessionInfo struct {
+	ID         string    `json:"id"`
+	IdentityID string    `json:"identity_id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	IsCurrent  bool      `json:"is_current"`
+}
+
+// SessionListResponse 登录会话列表响应
+type SessionListResponse struct {
+	Sessions []SessionInfo `json:"sessions"`
 }
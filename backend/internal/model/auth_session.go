@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+// AuthSession 登录会话模型（每个登录设备一条）
+type AuthSession struct {
+	BaseModel
+	UserID       string     `json:"user_id" gorm:"not null"`
+	IdentityID   string     `json:"identity_id" gorm:"not null"`
+	UserAgent    string     `json:"user_agent"`
+	IPAddress    string     `json:"ip_address"`
+	LastUsedAt   time.Time  `json:"last_used_at"`
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	RevokeReason string     `json:"revoke_reason"`
+}
+
+// RefreshToken 刷新令牌模型，仅保存令牌哈希
+type RefreshToken struct {
+	BaseModel
+	SessionID string     `json:"session_id" gorm:"not null"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+}
+
+// TableName 指定表名
+func (AuthSession) TableName() string {
+	return "auth_sessions"
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// IsActive 会话是否有效
+func (s *AuthSession) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && s.ExpiresAt.After(now)
+}
@@ -0,0 +1,90 @@
+package model
+
+import "time"
+
+// MentorAvailabilityRule 大师每周固定可预约时段，时间为大师所在时区的本地时间
+type MentorAvailabilityRule struct {
+	ID        string    `json:"id" gorm:"primaryKey;type:varchar(32)"`
+	MentorID  string    `json:"mentor_id" gorm:"not null"`
+	Weekday   int       `json:"weekday" gorm:"not null"`    // 0 表示周日，6 表示周六
+	StartTime string    `json:"start_time" gorm:"not null"` // HH:MM
+	EndTime   string    `json:"end_time" gorm:"not null"`   // HH:MM，24:00 表示当天结束
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (MentorAvailabilityRule) TableName() string {
+	return "mentor_availability_rules"
+}
+
+// MentorAvailabilityException 大师可预约时间的例外，如请假或临时加开时段
+type MentorAvailabilityException struct {
+	ID        string    `json:"id" gorm:"primaryKey;type:varchar(32)"`
+	MentorID  string    `json:"mentor_id" gorm:"not null"`
+	Date      time.Time `json:"date" gorm:"type:date;not null"` // 大师所在时区的日期
+	StartTime string    `json:"start_time"`                     // 为空表示全天
+	EndTime   string    `json:"end_time"`
+	Available bool      `json:"available" gorm:"default:false"` // true 为加开时段，false 为不可预约
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (MentorAvailabilityException) TableName() string {
+	return "mentor_availability_exceptions"
+}
+
+// AvailabilityRuleInput 每周可预约时段
+type AvailabilityRuleInput struct {
+	Weekday   int    `json:"weekday" binding:"min=0,max=6"`
+	StartTime string `json:"start_time" binding:"required"`
+	EndTime   string `json:"end_time" binding:"required"`
+}
+
+// UpdateAvailabilityRequest 设置可预约时间请求，rules 整体替换已有的每周时段
+type UpdateAvailabilityRequest struct {
+	Timezone      string                   `json:"timezone" binding:"required"`
+	BufferMinutes int                      `json:"buffer_minutes" binding:"min=0,max=240"`
+	Rules         []*AvailabilityRuleInput `json:"rules" binding:"dive"`
+}
+
+// CreateAvailabilityExceptionRequest 添加可预约时间例外请求
+type CreateAvailabilityExceptionRequest struct {
+	Date      string `json:"date" binding:"required"` // YYYY-MM-DD
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	Available bool   `json:"available"`
+	Reason    string `json:"reason"`
+}
+
+// AvailabilityResponse 大师可预约时间设置
+type AvailabilityResponse struct {
+	MentorID      string                         `json:"mentor_id"`
+	Timezone      string                         `json:"timezone"`
+	BufferMinutes int                            `json:"buffer_minutes"`
+	Rules         []*MentorAvailabilityRule      `json:"rules"`
+	Exceptions    []*MentorAvailabilityException `json:"exceptions"` // 今天及以后的例外
+}
+
+// AvailableSlotsRequest 查询可预约时段请求
+type AvailableSlotsRequest struct {
+	From            string `form:"from"`     // YYYY-MM-DD，默认今天
+	To              string `form:"to"`       // YYYY-MM-DD，默认 from 之后 14 天
+	DurationMinutes int    `form:"duration"` // 预约时长，默认 60 分钟
+}
+
+// AvailableSlot 可预约时段
+type AvailableSlot struct {
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+// AvailableSlotsResponse 可预约时段列表
+type AvailableSlotsResponse struct {
+	MentorID        string           `json:"mentor_id"`
+	Timezone        string           `json:"timezone"`
+	DurationMinutes int              `json:"duration_minutes"`
+	Slots           []*AvailableSlot `json:"slots"`
+}
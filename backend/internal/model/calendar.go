@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// CalendarFeed 用户的日历订阅令牌，订阅地址不需要登录，凭令牌访问
+type CalendarFeed struct {
+	ID             string     `json:"id" gorm:"primaryKey;type:varchar(32)"`
+	UserID         string     `json:"user_id" gorm:"not null"`
+	TokenHash      string     `json:"-" gorm:"not null"`
+	LastAccessedAt *time.Time `json:"last_accessed_at"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (CalendarFeed) TableName() string {
+	return "calendar_feeds"
+}
+
+// AppointmentReminder 已发送的预约提醒，每个预约的每个提醒时间点只发送一次
+type AppointmentReminder struct {
+	ID            string    `json:"id" gorm:"primaryKey;type:varchar(32)"`
+	AppointmentID string    `json:"appointment_id" gorm:"not null"`
+	OffsetMinutes int       `json:"offset_minutes" gorm:"not null"` // 预约开始前多少分钟
+	SentAt        time.Time `json:"sent_at" gorm:"autoCreateTime"`
+}
+
+// TableName 指定表名
+func (AppointmentReminder) TableName() string {
+	return "appointment_reminders"
+}
+
+// NotificationTypeAppointmentReminder 预约提醒通知类型
+const NotificationTypeAppointmentReminder = "appointment_reminder"
+
+// CalendarFeedResponse 日历订阅地址，令牌只在生成时返回一次
+type CalendarFeedResponse struct {
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -111,10 +111,19 @@ type CreateCourseResponse struct {
 
 // EnrollCourseResponse 报名课程响应
 type EnrollCourseResponse struct {
-	EnrollmentID string `json:"enrollment_id"`
-	CourseID     string `json:"course_id"`
-	Status       string `json:"status"`
-	PaymentURL   string `json:"payment_url"`
+	EnrollmentID string                      `json:"enrollment_id"`
+	CourseID     string                      `json:"course_id"`
+	Status       string                      `json:"status"`
+	PaymentURL   string                      `json:"payment_url"`
+	Payment      *CreatePaymentOrderResponse `json:"payment,omitempty"` // 待支付时返回支付信息
+}
+
+// CancelEnrollmentResponse 退课响应
+type CancelEnrollmentResponse struct {
+	EnrollmentID string              `json:"enrollment_id"`
+	CourseID     string              `json:"course_id"`
+	Status       string              `json:"status"`
+	Refund       *CancellationRefund `json:"refund,omitempty"` // 已支付的报名退课时返回
 }
 
 // CourseProgressResponse 课程进度响应
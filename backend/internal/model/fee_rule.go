@@ -0,0 +1,50 @@
+package model
+
+import "time"
+
+// FeeRule 费率规则，用于确认大师收入时计算平台佣金和申请提现时计算手续费
+// 订单类型、领域、大师等级为空时匹配全部；促销规则优先，其次按优先级和匹配条件数量选择
+type FeeRule struct {
+	ID            string     `json:"id" gorm:"primaryKey;type:varchar(32)"`
+	Name          string     `json:"name" gorm:"not null"`
+	FeeType       string     `json:"fee_type" gorm:"not null"`
+	OrderType     *string    `json:"order_type"`  // 仅平台佣金规则使用
+	Domain        *string    `json:"domain"`      // 大师所属领域编码
+	MentorTier    *string    `json:"mentor_tier"` // 大师等级
+	Rate          float64    `json:"rate" gorm:"type:decimal(6,4);not null;default:0"`
+	FixedFee      float64    `json:"fixed_fee" gorm:"type:decimal(10,2);not null;default:0"`
+	MinFee        *float64   `json:"min_fee" gorm:"type:decimal(10,2)"`
+	MaxFee        *float64   `json:"max_fee" gorm:"type:decimal(10,2)"`
+	IsPromotional bool       `json:"is_promotional" gorm:"default:false"`
+	Priority      int        `json:"priority" gorm:"default:0"`
+	EffectiveFrom time.Time  `json:"effective_from" gorm:"not null"`
+	EffectiveTo   *time.Time `json:"effective_to"`
+	Status        string     `json:"status" gorm:"default:'active'"`
+	Description   string     `json:"description"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (FeeRule) TableName() string {
+	return "fee_rules"
+}
+
+// 费率规则类型
+const (
+	FeeTypePlatformCommission = "platform_commission" // 确认收入时从订单金额中扣除的平台佣金
+	FeeTypeWithdrawal         = "withdrawal"          // 申请提现时从提现金额中扣除的手续费
+)
+
+// 费率规则状态
+const (
+	FeeRuleStatusActive   = "active"
+	FeeRuleStatusInactive = "inactive"
+)
+
+// 大师等级
+const (
+	MentorTierStandard = "standard"
+	MentorTierSenior   = "senior"
+	MentorTierExpert   = "expert"
+)
@@ -5,17 +5,23 @@ import "time"
 // IncomeTransactionModel 收入交易模型
 type IncomeTransactionModel struct {
 	BaseModel
-	MentorID        string     `json:"mentor_id" gorm:"not null"`
-	StudentID       string     `json:"student_id" gorm:"not null"`
-	TransactionType string     `json:"transaction_type" gorm:"not null"`
-	Amount          float64    `json:"amount" gorm:"type:decimal(10,2);not null"`
-	PlatformFee     float64    `json:"platform_fee" gorm:"type:decimal(10,2);not null;default:0"`
-	NetIncome       float64    `json:"net_income" gorm:"type:decimal(10,2);not null"`
-	Status          string     `json:"status" gorm:"default:'pending'"`
-	Description     string     `json:"description"`
-	CourseID        *string    `json:"course_id"`
-	AppointmentID   *string    `json:"appointment_id"`
-	CompletedAt     *time.Time `json:"completed_at"`
+	MentorID            string     `json:"mentor_id" gorm:"not null"`
+	StudentID           string     `json:"student_id" gorm:"not null"`
+	TransactionType     string     `json:"transaction_type" gorm:"not null"`
+	Amount              float64    `json:"amount" gorm:"type:decimal(10,2);not null"`
+	PlatformFee         float64    `json:"platform_fee" gorm:"type:decimal(10,2);not null;default:0"`
+	NetIncome           float64    `json:"net_income" gorm:"type:decimal(10,2);not null"`
+	Status              string     `json:"status" gorm:"default:'pending'"`
+	Description         string     `json:"description"`
+	CourseID            *string    `json:"course_id"`
+	AppointmentID       *string    `json:"appointment_id"`
+	PaymentOrderID      *string    `json:"payment_order_id"`
+	RefundID            *string    `json:"refund_id"`             // 退款冲正记录对应的退款单
+	SourceTransactionID *string    `json:"source_transaction_id"` // 退款冲正记录对应的原收入记录
+	FeeRuleID           *string    `json:"fee_rule_id"`           // 计算平台佣金所用的费率规则，为空表示使用默认费率
+	SettlementStatus    string     `json:"settlement_status" gorm:"default:'frozen'"`
+	SettledAt           *time.Time `json:"settled_at"`
+	CompletedAt         *time.Time `json:"completed_at"`
 
 	// 关联关系
 	Mentor      *Mentor           `json:"mentor,omitempty" gorm:"foreignKey:MentorID"`
@@ -29,17 +35,34 @@ func (IncomeTransactionModel) TableName() string {
 	return "income_transactions"
 }
 
+// IncomeTransactionTypeRefund 退款冲正，金额、平台费和净收入均为负数
+const IncomeTransactionTypeRefund = "refund"
+
+// 收入结算状态：履约入账后冻结，服务完成并过结算期后可提现
+const (
+	IncomeSettlementFrozen  = "frozen"
+	IncomeSettlementSettled = "settled"
+)
+
 // WithdrawalModel 提现模型
 type WithdrawalModel struct {
 	BaseModel
-	MentorID    string     `json:"mentor_id" gorm:"not null"`
-	Amount      float64    `json:"amount" gorm:"type:decimal(10,2);not null"`
-	Fee         float64    `json:"fee" gorm:"type:decimal(10,2);not null;default:0"`
-	NetAmount   float64    `json:"net_amount" gorm:"type:decimal(10,2);not null"`
-	Status      string     `json:"status" gorm:"default:'pending'"`
-	BankAccount string     `json:"bank_account" gorm:"not null"`
-	BankName    string     `json:"bank_name" gorm:"not null"`
-	CompletedAt *time.Time `json:"completed_at"`
+	MentorID          string     `json:"mentor_id" gorm:"not null"`
+	Amount            float64    `json:"amount" gorm:"type:decimal(10,2);not null"`
+	Fee               float64    `json:"fee" gorm:"type:decimal(10,2);not null;default:0"`
+	NetAmount         float64    `json:"net_amount" gorm:"type:decimal(10,2);not null"`
+	Status            string     `json:"status" gorm:"default:'pending'"`
+	BankAccount       string     `json:"-" gorm:"not null"` // 加密后的银行账号
+	BankAccountMasked string     `json:"bank_account" gorm:"not null"`
+	BankName          string     `json:"bank_name" gorm:"not null"`
+	FeeRuleID         *string    `json:"fee_rule_id"` // 计算手续费所用的费率规则，为空表示使用默认费率
+	ReviewedBy        *string    `json:"reviewed_by"`
+	ReviewedAt        *time.Time `json:"reviewed_at"`
+	RejectReason      *string    `json:"reject_reason"`
+	PayoutBatchID     *string    `json:"payout_batch_id"`
+	PayoutReference   *string    `json:"payout_reference"`
+	FailureReason     *string    `json:"failure_reason"`
+	CompletedAt       *time.Time `json:"completed_at"`
 
 	// 关联关系
 	Mentor *Mentor `json:"mentor,omitempty" gorm:"foreignKey:MentorID"`
@@ -49,3 +72,95 @@ type WithdrawalModel struct {
 func (WithdrawalModel) TableName() string {
 	return "withdrawals"
 }
+
+// 提现状态：待审核 -> 已通过 -> 打款中 -> 已完成/打款失败，待审核也可被驳回
+const (
+	WithdrawalStatusPending    = "pending"
+	WithdrawalStatusApproved   = "approved"
+	WithdrawalStatusRejected   = "rejected"
+	WithdrawalStatusProcessing = "processing"
+	WithdrawalStatusCompleted  = "completed"
+	WithdrawalStatusFailed     = "failed"
+)
+
+// NotificationTypeWithdrawal 提现进度通知类型
+const NotificationTypeWithdrawal = "withdrawal"
+
+// PayoutBatch 打款批次，已审核通过的提现按批次导出给银行转账
+type PayoutBatch struct {
+	BaseModel
+	Status          string     `json:"status" gorm:"default:'processing'"`
+	WithdrawalCount int        `json:"withdrawal_count"`
+	TotalAmount     float64    `json:"total_amount" gorm:"type:decimal(12,2)"`
+	TotalNetAmount  float64    `json:"total_net_amount" gorm:"type:decimal(12,2)"`
+	SucceededCount  int        `json:"succeeded_count"`
+	FailedCount     int        `json:"failed_count"`
+	CreatedBy       *string    `json:"created_by"`
+	ExportedAt      *time.Time `json:"exported_at"`
+	CompletedAt     *time.Time `json:"completed_at"`
+}
+
+// TableName 指定表名
+func (PayoutBatch) TableName() string {
+	return "payout_batches"
+}
+
+// 打款批次状态
+const (
+	PayoutBatchStatusProcessing = "processing"
+	PayoutBatchStatusCompleted  = "completed"
+)
+
+// IncomeReport 收入报告导出任务，生成的文件记录在 upload_files 中
+type IncomeReport struct {
+	BaseModel
+	MentorID     string     `json:"mentor_id" gorm:"not null"`
+	Format       string     `json:"format" gorm:"not null"`
+	IncomeType   string     `json:"income_type" gorm:"default:'all'"`
+	StartDate    time.Time  `json:"start_date" gorm:"type:date"`
+	EndDate      time.Time  `json:"end_date" gorm:"type:date"`
+	Status       string     `json:"status" gorm:"default:'pending'"`
+	UploadFileID *string    `json:"upload_file_id"`
+	ErrorMessage *string    `json:"error_message"`
+	Attempts     int        `json:"attempts"`
+	CompletedAt  *time.Time `json:"completed_at"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+
+	// 关联关系
+	UploadFile *UploadFile `json:"upload_file,omitempty" gorm:"foreignKey:UploadFileID"`
+}
+
+// TableName 指定表名
+func (IncomeReport) TableName() string {
+	return "income_reports"
+}
+
+// 收入报告状态：排队 -> 生成中 -> 已完成/失败，已完成的报告下载地址过期后删除文件
+const (
+	IncomeReportStatusPending    = "pending"
+	IncomeReportStatusProcessing = "processing"
+	IncomeReportStatusCompleted  = "completed"
+	IncomeReportStatusFailed     = "failed"
+	IncomeReportStatusExpired    = "expired"
+)
+
+// UploadTypeIncomeReport 收入报告文件的上传类型
+const UploadTypeIncomeReport = "income_report"
+
+// NotificationTypeIncomeReport 收入报告生成结果通知类型
+const NotificationTypeIncomeReport = "income_report"
+
+// IncomeReportLine 收入报告中的一笔收入或退款冲正，退款冲正按原收入归类
+type IncomeReportLine struct {
+	ID              string    `json:"id"`
+	TransactionType string    `json:"transaction_type"`
+	Category        string    `json:"category"` // 原收入类型：course_enrollment、appointment、appointment_package
+	ItemID          string    `json:"item_id"`  // 课程、预约或已购买套餐ID
+	ItemName        string    `json:"item_name"`
+	StudentName     string    `json:"student_name"`
+	Amount          float64   `json:"amount"`
+	PlatformFee     float64   `json:"platform_fee"`
+	NetIncome       float64   `json:"net_income"`
+	Status          string    `json:"status"`
+	CreatedAt       time.Time `json:"created_at"`
+}
@@ -28,15 +28,15 @@ type IncomeTrendsRequest struct {
 // IncomeExportRequest 导出收入报告请求
 type IncomeExportRequest struct {
 	Format    string    `json:"format" form:"format" binding:"required,oneof=csv excel pdf"`
-	StartDate time.Time `json:"start_date" form:"start_date" binding:"required" time:"2006-01-02"`
-	EndDate   time.Time `json:"end_date" form:"end_date" binding:"required" time:"2006-01-02"`
-	Type      string    `json:"type" form:"type" binding:"required,oneof=all course_enrollment appointment"`
+	StartDate time.Time `json:"start_date" form:"start_date" binding:"required" time_format:"2006-01-02"`
+	EndDate   time.Time `json:"end_date" form:"end_date" binding:"required" time_format:"2006-01-02"` // 包含结束当天
+	Type      string    `json:"type" form:"type" binding:"required,oneof=all course_enrollment appointment appointment_package"`
 }
 
 // WithdrawalsRequest 获取提现记录请求
 type WithdrawalsRequest struct {
 	PaginationRequest
-	Status    string    `json:"status" form:"status" binding:"oneof=pending completed failed"`
+	Status    string    `json:"status" form:"status" binding:"omitempty,oneof=pending approved rejected processing completed failed"`
 	StartDate time.Time `json:"start_date" form:"start_date" time:"2006-01-02"`
 	EndDate   time.Time `json:"end_date" form:"end_date" time:"2006-01-02"`
 }
@@ -47,3 +47,57 @@ type CreateWithdrawalRequest struct {
 	BankAccount string  `json:"bank_account" binding:"required"`
 	BankName    string  `json:"bank_name" binding:"required"`
 }
+
+// ListFeeRulesRequest 获取费率规则列表请求
+type ListFeeRulesRequest struct {
+	FeeType string `form:"fee_type" binding:"omitempty,oneof=platform_commission withdrawal"`
+	Status  string `form:"status" binding:"omitempty,oneof=active inactive"`
+}
+
+// FeeRuleRequest 创建或修改费率规则请求
+// 费率为 0-1 的小数，固定费用、最低和最高手续费单位为元；不填生效时间时立即生效
+type FeeRuleRequest struct {
+	Name          string     `json:"name" binding:"required,max=100"`
+	FeeType       string     `json:"fee_type" binding:"required,oneof=platform_commission withdrawal"`
+	OrderType     string     `json:"order_type" binding:"omitempty,oneof=course_enrollment appointment appointment_package"`
+	Domain        string     `json:"domain" binding:"omitempty,max=50"`
+	MentorTier    string     `json:"mentor_tier" binding:"omitempty,oneof=standard senior expert"`
+	Rate          float64    `json:"rate" binding:"min=0,max=1"`
+	FixedFee      float64    `json:"fixed_fee" binding:"min=0"`
+	MinFee        *float64   `json:"min_fee" binding:"omitempty,min=0"`
+	MaxFee        *float64   `json:"max_fee" binding:"omitempty,min=0"`
+	IsPromotional bool       `json:"is_promotional"`
+	Priority      int        `json:"priority"`
+	EffectiveFrom *time.Time `json:"effective_from"`
+	EffectiveTo   *time.Time `json:"effective_to"`
+	Status        string     `json:"status" binding:"omitempty,oneof=active inactive"`
+	Description   string     `json:"description"`
+}
+
+// UpdateMentorTierRequest 设置大师等级请求
+type UpdateMentorTierRequest struct {
+	Tier string `json:"tier" binding:"required,oneof=standard senior expert"`
+}
+
+// AdminWithdrawalsRequest 提现审核列表请求
+type AdminWithdrawalsRequest struct {
+	PaginationRequest
+	Status   string `form:"status" binding:"omitempty,oneof=pending approved rejected processing completed failed"`
+	MentorID string `form:"mentor_id"`
+}
+
+// RejectWithdrawalRequest 驳回提现请求
+type RejectWithdrawalRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// CreatePayoutBatchRequest 创建打款批次请求，不指定提现时按审核时间先后选取已通过的提现
+type CreatePayoutBatchRequest struct {
+	WithdrawalIDs []string `json:"withdrawal_ids" binding:"omitempty,max=1000"`
+}
+
+// PayoutBatchesRequest 打款批次列表请求
+type PayoutBatchesRequest struct {
+	PaginationRequest
+	Status string `form:"status" binding:"omitempty,oneof=processing completed"`
+}
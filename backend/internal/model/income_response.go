@@ -53,6 +53,10 @@ type IncomeTransaction struct {
 	CompletedAt *time.Time `json:"completed_at"`
 	PlatformFee float64    `json:"platform_fee"`
 	NetIncome   float64    `json:"net_income"`
+	FeeRuleID   *string    `json:"fee_rule_id"`
+
+	SettlementStatus string     `json:"settlement_status"` // frozen 结算期内冻结，settled 已可提现
+	SettledAt        *time.Time `json:"settled_at"`
 }
 
 // IncomeTransactionsResponse 收入明细响应
@@ -75,22 +79,34 @@ type IncomeTrendsResponse struct {
 	Trends []*IncomeTrend `json:"trends"`
 }
 
-// IncomeExportResponse 导出收入报告响应
+// IncomeExportResponse 导出收入报告响应，报告排队生成时下载地址为空，生成后通过报告状态接口获取
 type IncomeExportResponse struct {
-	DownloadURL string    `json:"download_url"`
-	ExpiresAt   time.Time `json:"expires_at"`
+	ReportID     string     `json:"report_id"`
+	Status       string     `json:"status"` // pending 排队中，processing 生成中，completed 已生成，failed 生成失败，expired 已过期
+	Format       string     `json:"format"`
+	StartDate    string     `json:"start_date"`
+	EndDate      string     `json:"end_date"`
+	DownloadURL  string     `json:"download_url,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	ErrorMessage string     `json:"error_message,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
 }
 
 // Withdrawal 提现记录
 type Withdrawal struct {
-	ID          string     `json:"id"`
-	Amount      float64    `json:"amount"`
-	Status      string     `json:"status"`
-	BankAccount string     `json:"bank_account"`
-	CreatedAt   time.Time  `json:"created_at"`
-	CompletedAt *time.Time `json:"completed_at"`
-	Fee         float64    `json:"fee"`
-	NetAmount   float64    `json:"net_amount"`
+	ID            string     `json:"id"`
+	Amount        float64    `json:"amount"`
+	Status        string     `json:"status"`
+	BankAccount   string     `json:"bank_account"` // 脱敏银行账号
+	BankName      string     `json:"bank_name"`
+	CreatedAt     time.Time  `json:"created_at"`
+	CompletedAt   *time.Time `json:"completed_at"`
+	Fee           float64    `json:"fee"`
+	NetAmount     float64    `json:"net_amount"`
+	FeeRuleID     *string    `json:"fee_rule_id"`
+	ReviewedAt    *time.Time `json:"reviewed_at"`
+	RejectReason  *string    `json:"reject_reason"`
+	FailureReason *string    `json:"failure_reason"`
 }
 
 // WithdrawalsResponse 提现记录响应
@@ -108,6 +124,7 @@ type CreateWithdrawalResponse struct {
 // AvailableIncome 可提现金额信息
 type AvailableIncome struct {
 	AvailableAmount float64 `json:"available_amount"`
+	FrozenAmount    float64 `json:"frozen_amount"`
 	PendingAmount   float64 `json:"pending_amount"`
 	TotalEarned     float64 `json:"total_earned"`
 	TotalWithdrawn  float64 `json:"total_withdrawn"`
@@ -117,10 +134,38 @@ type AvailableIncome struct {
 
 // AvailableIncomeResponse 可提现金额响应
 type AvailableIncomeResponse struct {
-	AvailableAmount float64 `json:"available_amount"`
+	AvailableAmount float64 `json:"available_amount"` // 已结算可提现金额
+	FrozenAmount    float64 `json:"frozen_amount"`    // 结算期内冻结的收入
+	SettlementDays  int     `json:"settlement_days"`  // 服务完成后的结算天数
 	PendingAmount   float64 `json:"pending_amount"`
 	TotalEarned     float64 `json:"total_earned"`
 	TotalWithdrawn  float64 `json:"total_withdrawn"`
 	MinWithdrawal   float64 `json:"min_withdrawal"`
 	MaxWithdrawal   float64 `json:"max_withdrawal"`
 }
+
+// FeeRuleListResponse 费率规则列表响应
+type FeeRuleListResponse struct {
+	Rules []*FeeRule `json:"rules"`
+}
+
+// AdminWithdrawalsResponse 提现审核列表响应
+type AdminWithdrawalsResponse struct {
+	Withdrawals []*WithdrawalModel  `json:"withdrawals"`
+	Pagination  *PaginationResponse `json:"pagination"`
+}
+
+// PayoutBatchesResponse 打款批次列表响应
+type PayoutBatchesResponse struct {
+	Batches    []*PayoutBatch      `json:"batches"`
+	Pagination *PaginationResponse `json:"pagination"`
+}
+
+// PayoutImportResponse 打款结果导入响应
+type PayoutImportResponse struct {
+	Batch     *PayoutBatch `json:"batch"`
+	Succeeded int          `json:"succeeded"` // 本次确认打款成功的笔数
+	Failed    int          `json:"failed"`    // 本次确认打款失败的笔数
+	Skipped   int          `json:"skipped"`   // 已处理过的重复结果
+	Errors    []string     `json:"errors"`
+}
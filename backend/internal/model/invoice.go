@@ -0,0 +1,82 @@
+package model
+
+import "time"
+
+// Invoice 票据，支付订单履约后开具服务收据和平台服务费账单，退款冲正后开具对应的红字票据
+// 票据开具后不可修改，买卖双方信息为开具时的快照；红字票据金额为负数
+type Invoice struct {
+	ID                  string    `json:"id" gorm:"primaryKey;type:varchar(32)"`
+	InvoiceNumber       string    `json:"invoice_number" gorm:"not null"`
+	DocumentType        string    `json:"document_type" gorm:"not null"`
+	Category            string    `json:"category" gorm:"not null"`
+	IncomeTransactionID string    `json:"income_transaction_id" gorm:"not null"`
+	PaymentOrderID      *string   `json:"payment_order_id"`
+	RefundID            *string   `json:"refund_id"`
+	OriginalInvoiceID   *string   `json:"original_invoice_id"` // 红字票据冲销的原票据
+	OrderType           string    `json:"order_type" gorm:"not null"`
+	MentorID            string    `json:"mentor_id" gorm:"not null"`
+	BuyerUserID         *string   `json:"buyer_user_id"`
+	BuyerName           string    `json:"buyer_name" gorm:"not null"`
+	BuyerEmail          string    `json:"buyer_email"`
+	BuyerTaxID          string    `json:"buyer_tax_id"`
+	BuyerAddress        string    `json:"buyer_address"`
+	SellerUserID        *string   `json:"seller_user_id"` // 为空表示平台
+	SellerName          string    `json:"seller_name" gorm:"not null"`
+	SellerEmail         string    `json:"seller_email"`
+	SellerTaxID         string    `json:"seller_tax_id"`
+	SellerAddress       string    `json:"seller_address"`
+	Currency            string    `json:"currency" gorm:"default:'CNY'"`
+	Subtotal            float64   `json:"subtotal" gorm:"type:decimal(10,2);not null"`
+	TaxRate             float64   `json:"tax_rate" gorm:"type:decimal(5,4);not null;default:0"`
+	TaxAmount           float64   `json:"tax_amount" gorm:"type:decimal(10,2);not null;default:0"`
+	Total               float64   `json:"total" gorm:"type:decimal(10,2);not null"`
+	IssuedAt            time.Time `json:"issued_at" gorm:"not null"`
+	CreatedAt           time.Time `json:"created_at" gorm:"autoCreateTime"`
+
+	// 关联关系
+	Items []*InvoiceItem `json:"items,omitempty" gorm:"foreignKey:InvoiceID"`
+}
+
+// TableName 指定表名
+func (Invoice) TableName() string {
+	return "invoices"
+}
+
+// InvoiceItem 票据明细，单价和金额为含税价
+type InvoiceItem struct {
+	ID          string    `json:"id" gorm:"primaryKey;type:varchar(32)"`
+	InvoiceID   string    `json:"invoice_id" gorm:"not null"`
+	LineNo      int       `json:"line_no" gorm:"not null"`
+	Description string    `json:"description" gorm:"not null"`
+	Quantity    int       `json:"quantity" gorm:"not null;default:1"`
+	UnitPrice   float64   `json:"unit_price" gorm:"type:decimal(10,2);not null"`
+	Amount      float64   `json:"amount" gorm:"type:decimal(10,2);not null"`
+	TaxAmount   float64   `json:"tax_amount" gorm:"type:decimal(10,2);not null;default:0"`
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName 指定表名
+func (InvoiceItem) TableName() string {
+	return "invoice_items"
+}
+
+// 票据类型：正常票据和冲销原票据的红字票据
+const (
+	InvoiceDocumentTypeInvoice    = "invoice"
+	InvoiceDocumentTypeCreditNote = "credit_note"
+)
+
+// 票据类别：学生购买服务的收据（大师为销售方，平台代开）和大师的平台服务费账单（平台为销售方）
+const (
+	InvoiceCategoryService     = "service"
+	InvoiceCategoryPlatformFee = "platform_fee"
+)
+
+// InvoiceParty 票据买方或卖方信息
+type InvoiceParty struct {
+	UserID  string
+	Name    string
+	Email   string
+	TaxID   string
+	Address string
+}
@@ -22,3 +22,14 @@ type LearningRecordModel struct {
 func (LearningRecordModel) TableName() string {
 	return "learning_records"
 }
+
+// 报名支付相关的学习记录状态
+const (
+	LearningStatusPendingPayment = "pending_payment" // 已报名待支付
+	LearningStatusEnrolled       = "enrolled"
+	LearningStatusCancelled      = "cancelled" // 支付失败或超时后释放
+	LearningStatusDropped        = "dropped"   // 学生退课
+)
+
+// LearningDroppableStatuses 可以退课的学习记录状态，已学完的课程不能退课
+var LearningDroppableStatuses = []string{LearningStatusEnrolled, "learning", "paused"}
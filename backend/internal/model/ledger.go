@@ -0,0 +1,89 @@
+package model
+
+import "time"
+
+// LedgerAccount 复式记账账户
+type LedgerAccount struct {
+	ID            string    `json:"id" gorm:"primaryKey;type:varchar(32)"`
+	Code          string    `json:"code" gorm:"not null"`
+	Name          string    `json:"name" gorm:"not null"`
+	AccountType   string    `json:"account_type" gorm:"not null"`
+	NormalBalance string    `json:"normal_balance" gorm:"not null"`
+	OwnerType     string    `json:"owner_type" gorm:"not null"`
+	OwnerID       *string   `json:"owner_id"`
+	Currency      string    `json:"currency" gorm:"default:'CNY'"`
+	CreatedAt     time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName 指定表名
+func (LedgerAccount) TableName() string {
+	return "ledger_accounts"
+}
+
+// LedgerTransaction 记账凭证，每个资金业务事件一笔
+type LedgerTransaction struct {
+	ID              string    `json:"id" gorm:"primaryKey;type:varchar(32)"`
+	IdempotencyKey  string    `json:"idempotency_key" gorm:"not null"`
+	TransactionType string    `json:"transaction_type" gorm:"not null"`
+	Description     string    `json:"description"`
+	Currency        string    `json:"currency" gorm:"default:'CNY'"`
+	PaymentOrderID  *string   `json:"payment_order_id"`
+	RefundID        *string   `json:"refund_id"`
+	WithdrawalID    *string   `json:"withdrawal_id"`
+	MentorID        *string   `json:"mentor_id"`
+	OccurredAt      time.Time `json:"occurred_at" gorm:"not null"`
+	CreatedAt       time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName 指定表名
+func (LedgerTransaction) TableName() string {
+	return "ledger_transactions"
+}
+
+// LedgerEntry 记账分录，金额以分为单位，借记为正、贷记为负
+type LedgerEntry struct {
+	ID            string    `json:"id" gorm:"primaryKey;type:varchar(32)"`
+	TransactionID string    `json:"transaction_id" gorm:"not null"`
+	AccountID     string    `json:"account_id" gorm:"not null"`
+	Amount        int64     `json:"amount" gorm:"not null"`
+	CreatedAt     time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName 指定表名
+func (LedgerEntry) TableName() string {
+	return "ledger_entries"
+}
+
+// LedgerAccountBalance 账户余额，Balance 为借贷相抵后的余额（借记为正），金额单位为分
+type LedgerAccountBalance struct {
+	Code          string `json:"code"`
+	Name          string `json:"name"`
+	AccountType   string `json:"account_type"`
+	NormalBalance string `json:"normal_balance"`
+	OwnerType     string `json:"owner_type"`
+	OwnerID       string `json:"owner_id,omitempty"`
+	Debit         int64  `json:"debit"`
+	Credit        int64  `json:"credit"`
+	Balance       int64  `json:"balance"`
+}
+
+// LedgerReconciliationItem 业务表与账本的核对项，金额单位为分
+type LedgerReconciliationItem struct {
+	Name       string `json:"name"`
+	Source     int64  `json:"source"` // 业务表合计
+	Ledger     int64  `json:"ledger"` // 账本合计
+	Difference int64  `json:"difference"`
+	Matched    bool   `json:"matched"`
+}
+
+// LedgerReconciliationReport 账本对账报告，金额单位为分
+type LedgerReconciliationReport struct {
+	GeneratedAt            time.Time                   `json:"generated_at"`
+	Balanced               bool                        `json:"balanced"` // 全部账户借贷合计为0
+	TotalDebit             int64                       `json:"total_debit"`
+	TotalCredit            int64                       `json:"total_credit"`
+	UnbalancedTransactions []string                    `json:"unbalanced_transactions"`
+	Accounts               []*LedgerAccountBalance     `json:"accounts"`
+	Items                  []*LedgerReconciliationItem `json:"items"`
+	Matched                bool                        `json:"matched"` // 全部核对项一致且账本平衡
+}
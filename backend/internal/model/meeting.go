@@ -0,0 +1,60 @@
+package model
+
+import "time"
+
+// MeetingRoom 视频咨询预约的会议室，预约确认后创建
+type MeetingRoom struct {
+	ID             string    `json:"id" gorm:"primaryKey;type:varchar(32)"`
+	AppointmentID  string    `json:"appointment_id" gorm:"not null"`
+	Provider       string    `json:"provider" gorm:"not null"`
+	ProviderRoomID string    `json:"provider_room_id" gorm:"not null"` // 会议服务侧的会议室ID
+	SignalingURL   string    `json:"signaling_url"`
+	Status         string    `json:"status" gorm:"default:'active'"`
+	JoinOpensAt    time.Time `json:"join_opens_at" gorm:"not null"`
+	JoinClosesAt   time.Time `json:"join_closes_at" gorm:"not null"`
+	CreatedAt      time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+
+	// 关联关系
+	Participants []*MeetingParticipant `json:"participants,omitempty" gorm:"foreignKey:RoomID"`
+}
+
+// TableName 指定表名
+func (MeetingRoom) TableName() string {
+	return "meeting_rooms"
+}
+
+// 会议室状态
+const (
+	MeetingRoomStatusActive = "active"
+	MeetingRoomStatusClosed = "closed" // 预约取消后关闭，不能再入会
+)
+
+// MeetingParticipant 会议参与方的入会地址和凭证，只在入会时间窗口内返回给本人
+type MeetingParticipant struct {
+	ID        string    `json:"id" gorm:"primaryKey;type:varchar(32)"`
+	RoomID    string    `json:"room_id" gorm:"not null"`
+	UserID    string    `json:"user_id" gorm:"not null"`
+	Role      string    `json:"role" gorm:"not null"`
+	JoinURL   string    `json:"-" gorm:"not null"`
+	Token     string    `json:"-" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName 指定表名
+func (MeetingParticipant) TableName() string {
+	return "meeting_participants"
+}
+
+// MeetingJoinResponse 入会信息
+type MeetingJoinResponse struct {
+	AppointmentID string    `json:"appointment_id"`
+	Provider      string    `json:"provider"`
+	RoomID        string    `json:"room_id"`
+	Role          string    `json:"role"`
+	JoinURL       string    `json:"join_url"`
+	Token         string    `json:"token"`
+	SignalingURL  string    `json:"signaling_url,omitempty"` // 自建会议的 WebSocket 信令地址，连接时携带 token 参数
+	JoinOpensAt   time.Time `json:"join_opens_at"`
+	JoinClosesAt  time.Time `json:"join_closes_at"`
+}
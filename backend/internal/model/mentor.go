@@ -13,6 +13,9 @@ type Mentor struct {
 	IsOnline        bool    `json:"is_online" gorm:"default:false"`
 	ExperienceYears int     `json:"experience_years" gorm:"default:0"`
 	Status          string  `json:"status" gorm:"default:'active'"`
+	Timezone        string  `json:"timezone" gorm:"default:'Asia/Shanghai'"` // 可预约时间所用时区
+	BufferMinutes   int     `json:"buffer_minutes" gorm:"default:0"`         // 两次预约之间的间隔
+	Tier            string  `json:"tier" gorm:"default:'standard'"`          // 大师等级，用于匹配费率规则
 
 	// 关联关系
 	Identity *UserIdentity `json:"identity,omitempty" gorm:"foreignKey:IdentityID"`
@@ -1,14 +1,18 @@
 package model
 
+import "time"
+
 // Message 消息模型
 type Message struct {
 	BaseModel
-	FromUserID string `json:"from_user_id" gorm:"not null"`
-	ToUserID   string `json:"to_user_id"`
-	CircleID   string `json:"circle_id"`
-	Content    string `json:"content" gorm:"not null"`
-	Type       string `json:"type" gorm:"default:'text'"`
-	IsRead     bool   `json:"is_read" gorm:"default:false"`
+	FromUserID  string     `json:"from_user_id" gorm:"not null"`
+	ToUserID    string     `json:"to_user_id"`
+	CircleID    string     `json:"circle_id"`
+	Content     string     `json:"content" gorm:"not null"`
+	Type        string     `json:"type" gorm:"default:'text'"`
+	IsRead      bool       `json:"is_read" gorm:"default:false"`
+	DeliveredAt *time.Time `json:"delivered_at"` // 私聊消息送达时间
+	ReadAt      *time.Time `json:"read_at"`      // 私聊消息阅读时间
 
 	// 关联关系
 	FromUser *User   `json:"from_user,omitempty" gorm:"foreignKey:FromUserID"`
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// PasswordResetToken 密码重置令牌模型，仅保存令牌哈希
+type PasswordResetToken struct {
+	BaseModel
+	UserID    string     `json:"user_id" gorm:"not null"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	RequestIP string     `json:"request_ip"`
+}
+
+// TableName 指定表名
+func (PasswordResetToken) TableName() string {
+	return "password_reset_tokens"
+}
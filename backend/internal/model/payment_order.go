@@ -5,6 +5,7 @@ import "time"
 // PaymentOrder 支付订单模型
 type PaymentOrder struct {
 	ID            string     `json:"id" gorm:"primaryKey;type:varchar(32)"`
+	UserID        string     `json:"user_id"` // 下单用户ID
 	OrderType     string     `json:"order_type"`
 	OrderRefID    string     `json:"order_id"`
 	Amount        float64    `json:"amount"`
@@ -16,8 +17,18 @@ type PaymentOrder struct {
 	CreatedAt     time.Time  `json:"created_at"`
 	UpdatedAt     time.Time  `json:"updated_at"`
 	ExpiresAt     *time.Time `json:"expires_at"`
+	FulfilledAt   *time.Time `json:"fulfilled_at"` // 业务订单履约时间，为空表示尚未开通课程或确认预约
+	// 业务订单已取消或时段已被占用而无法履约的时间，此类订单不再履约，支付金额全额退回
+	FulfillmentFailedAt *time.Time `json:"fulfillment_failed_at"`
 }
 
+// 支付订单类型，对应 order_type
+const (
+	PaymentOrderTypeCourseEnrollment   = "course_enrollment"
+	PaymentOrderTypeAppointment        = "appointment"
+	PaymentOrderTypeAppointmentPackage = "appointment_package" // 业务订单ID为已购买套餐ID
+)
+
 func (PaymentOrder) TableName() string {
 	return "payment_orders"
 }
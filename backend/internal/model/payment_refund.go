@@ -13,6 +13,9 @@ type PaymentRefund struct {
 	CreatedAt           time.Time  `json:"created_at"`
 	CompletedAt         *time.Time `json:"completed_at"`
 	RefundTransactionID string     `json:"refund_transaction_id"`
+	Automatic           bool       `json:"automatic"`     // 按退款策略或订单无法履约由系统自动发起
+	Attempts            int        `json:"attempts"`      // 自动退款向网关申请失败的次数
+	NextRetryAt         *time.Time `json:"next_retry_at"` // 自动退款申请失败后的下次重试时间，为空表示不再自动重试
 }
 
 func (PaymentRefund) TableName() string {
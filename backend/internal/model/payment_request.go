@@ -9,7 +9,7 @@ import "time"
 // swagger:parameters CreatePaymentOrderRequest
 //
 type CreatePaymentOrderRequest struct {
-	OrderType     string                 `json:"order_type" binding:"required,oneof=course_enrollment appointment refund"`
+	OrderType     string                 `json:"order_type" binding:"required,oneof=course_enrollment appointment appointment_package refund"`
 	OrderID       string                 `json:"order_id" binding:"required"`
 	Amount        float64                `json:"amount" binding:"required"`
 	Currency      string                 `json:"currency" binding:"required"`
@@ -31,7 +31,7 @@ type QueryPaymentStatusRequest struct {
 // swagger:parameters PaymentHistoryRequest
 //
 type PaymentHistoryRequest struct {
-	Type      string    `form:"type" binding:"omitempty,oneof=course_enrollment appointment refund"`
+	Type      string    `form:"type" binding:"omitempty,oneof=course_enrollment appointment appointment_package refund"`
 	Status    string    `form:"status" binding:"omitempty,oneof=pending completed failed cancelled"`
 	StartDate time.Time `form:"start_date" time_format:"2006-01-02"`
 	EndDate   time.Time `form:"end_date" time_format:"2006-01-02"`
@@ -67,3 +67,34 @@ type PaymentStatsRequest struct {
 	StartDate time.Time `form:"start_date" time_format:"2006-01-02"`
 	EndDate   time.Time `form:"end_date" time_format:"2006-01-02"`
 }
+
+// InvoiceListRequest 获取票据列表请求，role 为 buyer 时返回作为购买方收到的票据，为 seller 时返回作为销售方开具的票据，为空时返回全部
+// GET /payments/invoices
+// swagger:parameters InvoiceListRequest
+//
+type InvoiceListRequest struct {
+	Role         string    `form:"role" binding:"omitempty,oneof=buyer seller"`
+	DocumentType string    `form:"document_type" binding:"omitempty,oneof=invoice credit_note"`
+	Category     string    `form:"category" binding:"omitempty,oneof=service platform_fee"`
+	StartDate    time.Time `form:"start_date" time_format:"2006-01-02"`
+	EndDate      time.Time `form:"end_date" time_format:"2006-01-02"`
+	Page         int       `form:"page"`
+	PageSize     int       `form:"page_size"`
+}
+
+// GetInvoiceRequest 获取票据详情请求
+// GET /payments/invoices/{invoice_id}
+// swagger:parameters GetInvoiceRequest
+//
+type GetInvoiceRequest struct {
+	InvoiceID string `uri:"invoice_id" binding:"required"`
+}
+
+// DownloadInvoiceRequest 下载票据请求
+// GET /payments/invoices/{invoice_id}/download
+// swagger:parameters DownloadInvoiceRequest
+//
+type DownloadInvoiceRequest struct {
+	InvoiceID string `uri:"invoice_id" binding:"required"`
+	Format    string `form:"format" binding:"omitempty,oneof=pdf json"`
+}
@@ -48,10 +48,20 @@ type PaymentHistoryResponse struct {
 // CreateRefundResponse 申请退款响应
 type CreateRefundResponse struct {
 	RefundID                string    `json:"refund_id"`
+	Amount                  float64   `json:"amount"`
 	Status                  string    `json:"status"`
 	EstimatedCompletionTime time.Time `json:"estimated_completion_time"`
 }
 
+// CancellationRefund 取消预约或退课时按退款策略自动发起的退款
+type CancellationRefund struct {
+	Rule     string  `json:"rule"` // 命中的退款规则
+	Rate     float64 `json:"rate"` // 退款比例，0 表示不退款
+	RefundID string  `json:"refund_id,omitempty"`
+	Amount   float64 `json:"amount"`
+	Status   string  `json:"status,omitempty"` // 退款状态，申请失败时为 failed，系统会自动重试，多次失败后需联系客服处理
+}
+
 // QueryRefundStatusResponse 查询退款状态响应
 type QueryRefundStatusResponse struct {
 	RefundID            string     `json:"refund_id"`
@@ -90,3 +100,9 @@ type PaymentDailyStat struct {
 	Amount       float64 `json:"amount"`
 	Transactions int     `json:"transactions"`
 }
+
+// InvoiceListResponse 票据列表响应
+type InvoiceListResponse struct {
+	Invoices   []*Invoice          `json:"invoices"`
+	Pagination *PaginationResponse `json:"pagination"`
+}
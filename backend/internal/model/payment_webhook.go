@@ -1,15 +1,70 @@
 package model
 
-// PaymentWebhookRequest 支付回调请求
-type PaymentWebhookRequest struct {
-	OrderID       string  `json:"order_id" binding:"required"`
-	TransactionID string  `json:"transaction_id" binding:"required"`
-	Status        string  `json:"status" binding:"required"`
-	Amount        float64 `json:"amount" binding:"required"`
-	Signature     string  `json:"signature" binding:"required"`
-}
+import "time"
 
 // PaymentWebhookResponse 支付回调响应
 type PaymentWebhookResponse struct {
-	Processed bool `json:"processed"`
+	Processed   bool   `json:"processed"`
+	Duplicate   bool   `json:"duplicate"` // 重复投递的通知，未再次处理
+	EventID     string `json:"event_id"`
+	OrderID     string `json:"order_id"`
+	Status      string `json:"status"`       // 订单或退款的最新状态
+	InboxStatus string `json:"inbox_status"` // 回调收件箱处理状态
+}
+
+// SimulateSandboxPaymentRequest 沙箱支付模拟请求
+type SimulateSandboxPaymentRequest struct {
+	Status string `json:"status" binding:"required,oneof=succeeded failed"`
+}
+
+// 支付回调收件箱处理状态
+const (
+	WebhookEventStatusReceived  = "received"  // 已接收，尚未处理成功
+	WebhookEventStatusProcessed = "processed" // 已处理
+	WebhookEventStatusIgnored   = "ignored"   // 重复或过期的状态通知，未产生变更
+	WebhookEventStatusRejected  = "rejected"  // 金额、币种等校验未通过
+	WebhookEventStatusFailed    = "failed"    // 处理过程中出错，可重试
+)
+
+// PaymentWebhookEvent 支付回调收件箱，保存已验签的网关通知
+type PaymentWebhookEvent struct {
+	ID            string     `json:"id" gorm:"primaryKey;type:varchar(32)"`
+	Gateway       string     `json:"gateway"`
+	DedupeKey     string     `json:"dedupe_key"`
+	EventID       string     `json:"event_id"`
+	EventType     string     `json:"event_type"`
+	OrderID       string     `json:"order_id"`
+	RefundID      string     `json:"refund_id"`
+	TransactionID string     `json:"transaction_id"`
+	EventStatus   string     `json:"event_status"`
+	Amount        float64    `json:"amount"`
+	Currency      string     `json:"currency"`
+	Payload       string     `json:"-"`
+	Status        string     `json:"status"`
+	LastError     string     `json:"last_error"`
+	Attempts      int        `json:"attempts"`
+	OccurredAt    *time.Time `json:"occurred_at"`
+	ProcessedAt   *time.Time `json:"processed_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (PaymentWebhookEvent) TableName() string {
+	return "payment_webhook_events"
+}
+
+// ListPaymentWebhookEventsRequest 支付回调事件列表请求
+// GET /admin/payments/webhook-events
+type ListPaymentWebhookEventsRequest struct {
+	Gateway  string `form:"gateway"`
+	Status   string `form:"status" binding:"omitempty,oneof=received processed ignored rejected failed"`
+	OrderID  string `form:"order_id"`
+	Page     int    `form:"page"`
+	PageSize int    `form:"page_size"`
+}
+
+// PaymentWebhookEventListResponse 支付回调事件列表响应
+type PaymentWebhookEventListResponse struct {
+	Events     []*PaymentWebhookEvent `json:"events"`
+	Pagination *PaginationResponse    `json:"pagination"`
 }
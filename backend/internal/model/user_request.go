@@ -39,3 +39,14 @@ type UserPreferencesRequest struct {
 	PreferredDomains []string `json:"preferred_domains"`
 	ExperienceLevel  string   `json:"experience_level" binding:"required,oneof=beginner intermediate advanced"`
 }
+
+// SendVerificationCodeRequest 发送验证码请求
+type SendVerificationCodeRequest struct {
+	Channel string `json:"channel" binding:"required,oneof=email phone"`
+}
+
+// ConfirmVerificationCodeRequest 确认验证码请求
+type ConfirmVerificationCodeRequest struct {
+	Channel string `json:"channel" binding:"required,oneof=email phone"`
+	Code    string `json:"code" binding:"required"`
+}
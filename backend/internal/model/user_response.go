@@ -10,11 +10,13 @@ type UserProfileResponse struct {
 
 // UserInfo 用户信息
 type UserInfo struct {
-	ID        string    `json:"id"`
-	Email     string    `json:"email"`
-	Phone     string    `json:"phone"`
-	Status    string    `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
+	ID            string    `json:"id"`
+	Email         string    `json:"email"`
+	Phone         string    `json:"phone"`
+	EmailVerified bool      `json:"email_verified"`
+	PhoneVerified bool      `json:"phone_verified"`
+	Status        string    `json:"status"`
+	CreatedAt     time.Time `json:"created_at"`
 }
 
 // IdentityWithProfile 带档案的身份信息
@@ -103,3 +105,11 @@ type LearningPathStatsResponse struct {
 	PathDistribution  map[string]int     `json:"path_distribution"`
 	SatisfactionRates map[string]float64 `json:"satisfaction_rates"`
 }
+
+// SendVerificationCodeResponse 发送验证码响应
+type SendVerificationCodeResponse struct {
+	Channel     string `json:"channel"`
+	Target      string `json:"target"`       // 脱敏后的邮箱或手机号
+	ExpiresIn   int    `json:"expires_in"`   // 验证码有效期（秒）
+	ResendAfter int    `json:"resend_after"` // 重新发送间隔（秒）
+}
@@ -3,8 +3,10 @@ package model
 import "time"
 
 // WebSocketEvent WebSocket 事件基础结构
+// ID 由客户端为请求事件指定，服务端对该事件的回复和错误事件原样带回，便于客户端对应请求
 type WebSocketEvent struct {
 	Event string      `json:"event"`
+	ID    string      `json:"id,omitempty"`
 	Data  interface{} `json:"data"`
 }
 
@@ -20,6 +22,13 @@ type AuthenticateResponse struct {
 	UserID  string `json:"user_id,omitempty"`
 }
 
+// ErrorEvent 错误事件，如未认证的连接发送业务事件；Event 为出错的请求事件名称
+type ErrorEvent struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Event   string `json:"event,omitempty"`
+}
+
 // MessageEvent 消息事件
 type MessageEvent struct {
 	ID        string        `json:"id"`
@@ -42,15 +51,90 @@ type UserStatusEvent struct {
 	Timestamp time.Time `json:"timestamp"`
 }
 
-// ChatMessage 聊天消息
+// ChatMessage 聊天消息，ID 和发送时间由服务端生成
 type ChatMessage struct {
-	ID        string        `json:"id"`
-	FromUser  *ChatUserInfo `json:"from_user"`
-	Content   string        `json:"content"`
-	Type      string        `json:"type"`
-	CreatedAt time.Time     `json:"created_at"`
-	ToUserID  string        `json:"to_user_id,omitempty"`
-	CircleID  string        `json:"circle_id,omitempty"`
+	ID          string        `json:"id"`
+	FromUser    *ChatUserInfo `json:"from_user"`
+	Content     string        `json:"content"`
+	Type        string        `json:"type"`
+	CreatedAt   time.Time     `json:"created_at"`
+	ToUserID    string        `json:"to_user_id,omitempty"`
+	CircleID    string        `json:"circle_id,omitempty"`
+	Status      string        `json:"status,omitempty"` // 私聊消息状态：sent、delivered、read
+	DeliveredAt *time.Time    `json:"delivered_at,omitempty"`
+	ReadAt      *time.Time    `json:"read_at,omitempty"`
+}
+
+// 私聊消息状态
+const (
+	ChatMessageStatusSent      = "sent"
+	ChatMessageStatusDelivered = "delivered"
+	ChatMessageStatusRead      = "read"
+)
+
+// SendChatMessageRequest 发送聊天消息请求，通过 WebSocket message 事件发送，to_user_id 和 circle_id 二选一
+type SendChatMessageRequest struct {
+	ToUserID string `json:"to_user_id"`
+	CircleID string `json:"circle_id"`
+	Content  string `json:"content"`
+	Type     string `json:"type"` // text（默认）、image、file
+}
+
+// ChatTypingRequest 正在输入状态请求，通过 WebSocket typing 事件发送，to_user_id 和 circle_id 二选一
+type ChatTypingRequest struct {
+	ToUserID string `json:"to_user_id"`
+	CircleID string `json:"circle_id"`
+	IsTyping bool   `json:"is_typing"`
+}
+
+// ChatReadRequest 已读请求，通过 WebSocket read 事件发送，将会话中 message_id 及之前的消息标记为已读
+// target_id 为私聊对方用户ID，与 circle_id 二选一
+type ChatReadRequest struct {
+	TargetID  string `json:"target_id"`
+	CircleID  string `json:"circle_id"`
+	MessageID string `json:"message_id"`
+}
+
+// ChatReadResponse 已读请求的回复，返回会话剩余未读数
+type ChatReadResponse struct {
+	TargetID    string `json:"target_id,omitempty"`
+	CircleID    string `json:"circle_id,omitempty"`
+	UnreadCount int64  `json:"unread_count"`
+}
+
+// ChatTypingEvent 正在输入事件，推送给私聊对方或圈子其他在线成员
+type ChatTypingEvent struct {
+	FromUserID string    `json:"from_user_id"`
+	ToUserID   string    `json:"to_user_id,omitempty"`
+	CircleID   string    `json:"circle_id,omitempty"`
+	IsTyping   bool      `json:"is_typing"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// ChatReceiptEvent 消息回执事件，推送给消息发送方；user_ids 为送达或已读的用户
+type ChatReceiptEvent struct {
+	MessageIDs []string  `json:"message_ids"`
+	ToUserID   string    `json:"to_user_id,omitempty"`
+	CircleID   string    `json:"circle_id,omitempty"`
+	UserIDs    []string  `json:"user_ids"`
+	Status     string    `json:"status"` // delivered、read
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// ChatConversation 聊天会话，私聊为与某个用户的会话，圈子为已加入圈子的群聊
+type ChatConversation struct {
+	Type        string       `json:"type"` // private、circle
+	TargetID    string       `json:"target_id,omitempty"`
+	CircleID    string       `json:"circle_id,omitempty"`
+	Name        string       `json:"name"`
+	LastMessage *ChatMessage `json:"last_message"`
+	UnreadCount int64        `json:"unread_count"`
+}
+
+// ChatConversationsResponse 聊天会话列表响应，按最后一条消息时间倒序
+type ChatConversationsResponse struct {
+	Conversations []*ChatConversation `json:"conversations"`
+	TotalUnread   int64               `json:"total_unread"`
 }
 
 // OnlineUser 在线用户
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+
+	"master-guide-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// AppointmentPackageRepository 咨询套餐数据访问接口
+type AppointmentPackageRepository interface {
+	CreatePackage(ctx context.Context, pkg *model.AppointmentPackage) error
+	UpdatePackage(ctx context.Context, pkg *model.AppointmentPackage) error
+	GetPackageByID(ctx context.Context, packageID string) (*model.AppointmentPackage, error)
+	ListPackagesByMentor(ctx context.Context, mentorID, status string) ([]*model.AppointmentPackage, error)
+	CreatePurchase(ctx context.Context, purchase *model.AppointmentPackagePurchase) error
+	GetPurchaseByID(ctx context.Context, purchaseID string) (*model.AppointmentPackagePurchase, error)
+	ListPurchasesByStudent(ctx context.Context, studentID string) ([]*model.AppointmentPackagePurchase, error)
+	CancelPendingPurchase(ctx context.Context, purchaseID string) error
+}
+
+// appointmentPackageRepository 咨询套餐数据访问实现
+type appointmentPackageRepository struct {
+	db *gorm.DB
+}
+
+// NewAppointmentPackageRepository 创建咨询套餐数据访问实例
+func NewAppointmentPackageRepository(db *gorm.DB) AppointmentPackageRepository {
+	return &appointmentPackageRepository{db: db}
+}
+
+// CreatePackage 创建咨询套餐
+func (r *appointmentPackageRepository) CreatePackage(ctx context.Context, pkg *model.AppointmentPackage) error {
+	return r.db.WithContext(ctx).Create(pkg).Error
+}
+
+// UpdatePackage 更新咨询套餐内容和状态
+func (r *appointmentPackageRepository) UpdatePackage(ctx context.Context, pkg *model.AppointmentPackage) error {
+	return r.db.WithContext(ctx).
+		Model(pkg).
+		Select("title", "description", "session_count", "duration_minutes", "discount_rate", "validity_days", "status").
+		Updates(pkg).Error
+}
+
+// GetPackageByID 根据ID获取咨询套餐
+func (r *appointmentPackageRepository) GetPackageByID(ctx context.Context, packageID string) (*model.AppointmentPackage, error) {
+	var pkg model.AppointmentPackage
+	err := r.db.WithContext(ctx).Where("id = ?", packageID).First(&pkg).Error
+	if err != nil {
+		return nil, err
+	}
+	return &pkg, nil
+}
+
+// ListPackagesByMentor 获取大师的咨询套餐，status 为空时返回全部
+func (r *appointmentPackageRepository) ListPackagesByMentor(ctx context.Context, mentorID, status string) ([]*model.AppointmentPackage, error) {
+	var packages []*model.AppointmentPackage
+	query := r.db.WithContext(ctx).Where("mentor_id = ?", mentorID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("created_at DESC").Find(&packages).Error
+	return packages, err
+}
+
+// CreatePurchase 创建待支付的套餐购买记录
+func (r *appointmentPackageRepository) CreatePurchase(ctx context.Context, purchase *model.AppointmentPackagePurchase) error {
+	return r.db.WithContext(ctx).Create(purchase).Error
+}
+
+// GetPurchaseByID 根据ID获取已购买套餐
+func (r *appointmentPackageRepository) GetPurchaseByID(ctx context.Context, purchaseID string) (*model.AppointmentPackagePurchase, error) {
+	var purchase model.AppointmentPackagePurchase
+	err := r.db.WithContext(ctx).Where("id = ?", purchaseID).First(&purchase).Error
+	if err != nil {
+		return nil, err
+	}
+	return &purchase, nil
+}
+
+// ListPurchasesByStudent 获取学生购买的套餐，不含未完成支付的记录
+func (r *appointmentPackageRepository) ListPurchasesByStudent(ctx context.Context, studentID string) ([]*model.AppointmentPackagePurchase, error) {
+	var purchases []*model.AppointmentPackagePurchase
+	err := r.db.WithContext(ctx).
+		Where("student_id = ? AND status <> ?", studentID, model.PackagePurchaseStatusCancelled).
+		Order("created_at DESC").
+		Find(&purchases).Error
+	return purchases, err
+}
+
+// CancelPendingPurchase 取消仍待支付的套餐购买记录
+func (r *appointmentPackageRepository) CancelPendingPurchase(ctx context.Context, purchaseID string) error {
+	return r.db.WithContext(ctx).
+		Model(&model.AppointmentPackagePurchase{}).
+		Where("id = ? AND status = ?", purchaseID, model.PackagePurchaseStatusPendingPayment).
+		Update("status", model.PackagePurchaseStatusCancelled).Error
+}
@@ -2,24 +2,62 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"master-guide-backend/internal/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // AppointmentRepository 预约数据访问接口
 type AppointmentRepository interface {
 	CreateAppointment(ctx context.Context, appointment *model.AppointmentModel) error
+	BookAppointment(ctx context.Context, appointment *model.AppointmentModel, bufferMinutes int) error
+	BookPackageAppointments(ctx context.Context, appointments []*model.AppointmentModel, bufferMinutes int, series *model.AppointmentSeries) error
+	RescheduleAppointment(ctx context.Context, appointment *model.AppointmentModel, newTime time.Time, bufferMinutes int, history *model.AppointmentStatusHistory) error
+	ReturnPackageCredit(ctx context.Context, appointmentID string) (bool, error)
+	ListMentorBusyAppointments(ctx context.Context, mentorID string, from, to time.Time) ([]*model.AppointmentModel, error)
 	GetAppointments(ctx context.Context, userID, status, appointmentType string, page, pageSize int) ([]*model.AppointmentModel, int64, error)
 	GetAppointmentByID(ctx context.Context, appointmentID string) (*model.AppointmentModel, error)
-	UpdateAppointmentStatus(ctx context.Context, appointmentID, status string) error
-	CancelAppointment(ctx context.Context, appointmentID string) error
+	TransitionStatus(ctx context.Context, appointmentID, fromStatus string, history *model.AppointmentStatusHistory) (bool, error)
+	GetStatusHistory(ctx context.Context, appointmentID string) ([]*model.AppointmentStatusHistory, error)
 	GetMentorAppointmentStats(ctx context.Context, mentorID string) (*model.MentorAppointmentStats, error)
 	GetMentorStudentCount(ctx context.Context, mentorID string) (int64, error)
 	GetMentorTotalHours(ctx context.Context, mentorID string) (int64, error)
 }
 
+// 预约时间冲突
+var (
+	ErrMentorTimeConflict  = errors.New("mentor time conflict")
+	ErrStudentTimeConflict = errors.New("student time conflict")
+)
+
+// 套餐预约和改期失败
+var (
+	ErrPackageUnavailable  = errors.New("package unavailable")
+	ErrPackageInsufficient = errors.New("package credits insufficient")
+	ErrPackageExpired      = errors.New("package expires before appointment")
+	ErrAppointmentChanged  = errors.New("appointment changed")
+)
+
+// BookingConflictError 批量预约中某个预约时间冲突，Err 为 ErrMentorTimeConflict 或 ErrStudentTimeConflict
+type BookingConflictError struct {
+	Time time.Time
+	Err  error
+}
+
+// Error 实现 error 接口
+func (e *BookingConflictError) Error() string {
+	return e.Err.Error() + " at " + e.Time.Format(time.RFC3339)
+}
+
+// Unwrap 返回具体的冲突类型
+func (e *BookingConflictError) Unwrap() error {
+	return e.Err
+}
+
 // appointmentRepository 预约数据访问实现
 type appointmentRepository struct {
 	db *gorm.DB
@@ -35,6 +73,206 @@ func (r *appointmentRepository) CreateAppointment(ctx context.Context, appointme
 	return r.db.WithContext(ctx).Create(appointment).Error
 }
 
+// BookAppointment 在无时间冲突时创建预约
+// 依次锁定大师和学生记录以串行化同一大师或学生的并发预约，再检查与未结束预约的重叠，大师侧需额外留出预约间隔
+func (r *appointmentRepository) BookAppointment(ctx context.Context, appointment *model.AppointmentModel, bufferMinutes int) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockAppointmentParties(tx, appointment.MentorID, appointment.StudentID); err != nil {
+			return err
+		}
+		if err := checkAppointmentConflict(tx, appointment, appointment.AppointmentTime, bufferMinutes); err != nil {
+			return err
+		}
+		return createAppointment(tx, appointment)
+	})
+}
+
+// BookPackageAppointments 使用已购买套餐一次创建一个或多个预约，每个预约扣减一次套餐次数
+// 任一预约时间冲突或套餐次数不足时全部不创建；series 不为空时先创建重复预约系列并关联到每个预约
+func (r *appointmentRepository) BookPackageAppointments(ctx context.Context, appointments []*model.AppointmentModel, bufferMinutes int, series *model.AppointmentSeries) error {
+	if len(appointments) == 0 {
+		return nil
+	}
+	first := appointments[0]
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockAppointmentParties(tx, first.MentorID, first.StudentID); err != nil {
+			return err
+		}
+
+		var purchase model.AppointmentPackagePurchase
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&purchase, "id = ?", *first.PackagePurchaseID).Error; err != nil {
+			return err
+		}
+		if purchase.Status != model.PackagePurchaseStatusActive || purchase.StudentID != first.StudentID || purchase.MentorID != first.MentorID {
+			return ErrPackageUnavailable
+		}
+		if purchase.RemainingSessions() < len(appointments) {
+			return ErrPackageInsufficient
+		}
+		for _, appointment := range appointments {
+			if purchase.ExpiresAt == nil || !appointment.AppointmentTime.Before(*purchase.ExpiresAt) {
+				return ErrPackageExpired
+			}
+		}
+
+		if series != nil {
+			if err := tx.Create(series).Error; err != nil {
+				return err
+			}
+		}
+		for _, appointment := range appointments {
+			// 同一系列内较早创建的预约也参与冲突检查
+			if err := checkAppointmentConflict(tx, appointment, appointment.AppointmentTime, bufferMinutes); err != nil {
+				return &BookingConflictError{Time: appointment.AppointmentTime, Err: err}
+			}
+			if series != nil {
+				appointment.SeriesID = &series.ID
+			}
+			if err := createAppointment(tx, appointment); err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&model.AppointmentPackagePurchase{}).
+			Where("id = ?", purchase.ID).
+			Update("used_sessions", gorm.Expr("used_sessions + ?", len(appointments))).Error
+	})
+}
+
+// RescheduleAppointment 将预约改到 newTime，预约状态和时间需与 appointment 一致
+// 冲突检查排除预约自身；改期后删除已发送的提醒记录，以便按新时间重新提醒
+func (r *appointmentRepository) RescheduleAppointment(ctx context.Context, appointment *model.AppointmentModel, newTime time.Time, bufferMinutes int, history *model.AppointmentStatusHistory) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockAppointmentParties(tx, appointment.MentorID, appointment.StudentID); err != nil {
+			return err
+		}
+
+		var current model.AppointmentModel
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "status", "appointment_time").
+			First(&current, "id = ?", appointment.ID).Error; err != nil {
+			return err
+		}
+		if current.Status != appointment.Status || !current.AppointmentTime.Equal(appointment.AppointmentTime) {
+			return ErrAppointmentChanged
+		}
+
+		if err := checkAppointmentConflict(tx, appointment, newTime, bufferMinutes); err != nil {
+			return err
+		}
+		if err := tx.Model(&model.AppointmentModel{}).
+			Where("id = ?", appointment.ID).
+			Update("appointment_time", newTime).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("appointment_id = ?", appointment.ID).
+			Delete(&model.AppointmentReminder{}).Error; err != nil {
+			return err
+		}
+
+		history.AppointmentID = appointment.ID
+		history.FromStatus = appointment.Status
+		history.ToStatus = appointment.Status
+		return tx.Create(history).Error
+	})
+}
+
+// ReturnPackageCredit 退回已取消预约占用的套餐次数，每个预约只退回一次
+// 预约未使用套餐或已退回时返回 false
+func (r *appointmentRepository) ReturnPackageCredit(ctx context.Context, appointmentID string) (bool, error) {
+	returned := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var appointments []*model.AppointmentModel
+		result := tx.Model(&appointments).
+			Clauses(clause.Returning{Columns: []clause.Column{{Name: "package_purchase_id"}}}).
+			Where("id = ? AND package_purchase_id IS NOT NULL AND package_credit_returned = ?", appointmentID, false).
+			Update("package_credit_returned", true)
+		if result.Error != nil || len(appointments) == 0 || appointments[0].PackagePurchaseID == nil {
+			return result.Error
+		}
+
+		if err := tx.Model(&model.AppointmentPackagePurchase{}).
+			Where("id = ? AND used_sessions > 0", *appointments[0].PackagePurchaseID).
+			Update("used_sessions", gorm.Expr("used_sessions - 1")).Error; err != nil {
+			return err
+		}
+		returned = true
+		return nil
+	})
+	return returned, err
+}
+
+// lockAppointmentParties 依次锁定大师和学生记录，串行化同一大师或学生的并发预约
+func lockAppointmentParties(tx *gorm.DB, mentorID, studentID string) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").
+		First(&model.Mentor{}, "id = ?", mentorID).Error; err != nil {
+		return err
+	}
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").
+		First(&model.User{}, "id = ?", studentID).Error
+}
+
+// checkAppointmentConflict 检查预约改到 start 开始时与大师、学生其他未结束预约的重叠，大师侧需额外留出预约间隔
+func checkAppointmentConflict(tx *gorm.DB, appointment *model.AppointmentModel, start time.Time, bufferMinutes int) error {
+	end := start.Add(time.Duration(appointment.DurationMinutes) * time.Minute)
+	buffer := time.Duration(bufferMinutes) * time.Minute
+
+	var count int64
+	query := tx.Model(&model.AppointmentModel{}).
+		Where("mentor_id = ? AND status IN ?", appointment.MentorID, model.AppointmentActiveStatuses).
+		Where("appointment_time < ? AND appointment_time + make_interval(mins => duration_minutes + ?) > ?", end.Add(buffer), bufferMinutes, start)
+	if appointment.ID != "" {
+		query = query.Where("id <> ?", appointment.ID)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrMentorTimeConflict
+	}
+
+	query = tx.Model(&model.AppointmentModel{}).
+		Where("student_id = ? AND status IN ?", appointment.StudentID, model.AppointmentActiveStatuses).
+		Where("appointment_time < ? AND appointment_time + make_interval(mins => duration_minutes) > ?", end, start)
+	if appointment.ID != "" {
+		query = query.Where("id <> ?", appointment.ID)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrStudentTimeConflict
+	}
+	return nil
+}
+
+// createAppointment 创建预约并记录学生发起的创建操作
+func createAppointment(tx *gorm.DB, appointment *model.AppointmentModel) error {
+	if err := tx.Create(appointment).Error; err != nil {
+		return err
+	}
+	return tx.Create(&model.AppointmentStatusHistory{
+		AppointmentID: appointment.ID,
+		ToStatus:      appointment.Status,
+		ActorType:     model.AppointmentActorStudent,
+		ActorID:       appointment.StudentID,
+	}).Error
+}
+
+// ListMentorBusyAppointments 获取时间范围内占用大师时间的预约
+func (r *appointmentRepository) ListMentorBusyAppointments(ctx context.Context, mentorID string, from, to time.Time) ([]*model.AppointmentModel, error) {
+	var appointments []*model.AppointmentModel
+	err := r.db.WithContext(ctx).
+		Where("mentor_id = ? AND status IN ?", mentorID, model.AppointmentActiveStatuses).
+		Where("appointment_time < ? AND appointment_time + make_interval(mins => duration_minutes) > ?", to, from).
+		Order("appointment_time ASC").
+		Find(&appointments).Error
+	return appointments, err
+}
+
 // GetAppointments 获取预约列表
 func (r *appointmentRepository) GetAppointments(ctx context.Context, userID, status, appointmentType string, page, pageSize int) ([]*model.AppointmentModel, int64, error) {
 	var appointments []*model.AppointmentModel
@@ -42,7 +280,6 @@ func (r *appointmentRepository) GetAppointments(ctx context.Context, userID, sta
 
 	query := r.db.WithContext(ctx).Model(&model.AppointmentModel{}).
 		Preload("Student").
-		Preload("Student.Profile").
 		Preload("Mentor").
 		Preload("Mentor.Profile")
 
@@ -79,7 +316,6 @@ func (r *appointmentRepository) GetAppointmentByID(ctx context.Context, appointm
 	var appointment model.AppointmentModel
 	err := r.db.WithContext(ctx).
 		Preload("Student").
-		Preload("Student.Profile").
 		Preload("Mentor").
 		Preload("Mentor.Profile").
 		Where("id = ?", appointmentID).
@@ -90,20 +326,40 @@ func (r *appointmentRepository) GetAppointmentByID(ctx context.Context, appointm
 	return &appointment, nil
 }
 
-// UpdateAppointmentStatus 更新预约状态
-func (r *appointmentRepository) UpdateAppointmentStatus(ctx context.Context, appointmentID, status string) error {
-	return r.db.WithContext(ctx).
-		Model(&model.AppointmentModel{}).
-		Where("id = ?", appointmentID).
-		Update("status", status).Error
+// TransitionStatus 仅当预约仍处于 fromStatus 时变更为 history.ToStatus 并写入变更记录
+// 预约状态已被并发修改时返回 false
+func (r *appointmentRepository) TransitionStatus(ctx context.Context, appointmentID, fromStatus string, history *model.AppointmentStatusHistory) (bool, error) {
+	changed := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.AppointmentModel{}).
+			Where("id = ? AND status = ?", appointmentID, fromStatus).
+			Update("status", history.ToStatus)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		history.AppointmentID = appointmentID
+		history.FromStatus = fromStatus
+		if err := tx.Create(history).Error; err != nil {
+			return err
+		}
+		changed = true
+		return nil
+	})
+	return changed, err
 }
 
-// CancelAppointment 取消预约
-func (r *appointmentRepository) CancelAppointment(ctx context.Context, appointmentID string) error {
-	return r.db.WithContext(ctx).
-		Model(&model.AppointmentModel{}).
-		Where("id = ?", appointmentID).
-		Update("status", "cancelled").Error
+// GetStatusHistory 获取预约状态变更记录
+func (r *appointmentRepository) GetStatusHistory(ctx context.Context, appointmentID string) ([]*model.AppointmentStatusHistory, error) {
+	var history []*model.AppointmentStatusHistory
+	err := r.db.WithContext(ctx).
+		Where("appointment_id = ?", appointmentID).
+		Order("created_at ASC, id ASC").
+		Find(&history).Error
+	return history, err
 }
 
 // GetMentorAppointmentStats 获取大师预约统计
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"context"
+
+	"master-guide-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// AuditRepository 审计日志数据访问接口
+type AuditRepository interface {
+	Create(ctx context.Context, log *model.AuditLog) error
+}
+
+// auditRepository 审计日志数据访问实现
+type auditRepository struct {
+	db *gorm.DB
+}
+
+// NewAuditRepository 创建审计日志数据访问实例
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+// Create 写入审计日志
+func (r *auditRepository) Create(ctx context.Context, log *model.AuditLog) error {
+	return r.db.WithContext(ctx).Create(log).Error
+}
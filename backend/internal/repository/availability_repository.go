@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"master-guide-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// AvailabilityRepository 大师可预约时间数据访问接口
+type AvailabilityRepository interface {
+	GetRules(ctx context.Context, mentorID string) ([]*model.MentorAvailabilityRule, error)
+	ReplaceRules(ctx context.Context, mentorID, timezone string, bufferMinutes int, rules []*model.MentorAvailabilityRule) error
+	ListExceptions(ctx context.Context, mentorID string, from, to time.Time) ([]*model.MentorAvailabilityException, error)
+	CreateException(ctx context.Context, exception *model.MentorAvailabilityException) error
+	DeleteException(ctx context.Context, mentorID, exceptionID string) (bool, error)
+}
+
+// availabilityRepository 大师可预约时间数据访问实现
+type availabilityRepository struct {
+	db *gorm.DB
+}
+
+// NewAvailabilityRepository 创建大师可预约时间数据访问实例
+func NewAvailabilityRepository(db *gorm.DB) AvailabilityRepository {
+	return &availabilityRepository{db: db}
+}
+
+// GetRules 获取大师每周可预约时段
+func (r *availabilityRepository) GetRules(ctx context.Context, mentorID string) ([]*model.MentorAvailabilityRule, error) {
+	var rules []*model.MentorAvailabilityRule
+	err := r.db.WithContext(ctx).
+		Where("mentor_id = ?", mentorID).
+		Order("weekday ASC, start_time ASC").
+		Find(&rules).Error
+	return rules, err
+}
+
+// ReplaceRules 更新大师时区和预约间隔，并整体替换每周可预约时段
+func (r *availabilityRepository) ReplaceRules(ctx context.Context, mentorID, timezone string, bufferMinutes int, rules []*model.MentorAvailabilityRule) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Mentor{}).
+			Where("id = ?", mentorID).
+			Updates(map[string]interface{}{
+				"timezone":       timezone,
+				"buffer_minutes": bufferMinutes,
+			}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("mentor_id = ?", mentorID).Delete(&model.MentorAvailabilityRule{}).Error; err != nil {
+			return err
+		}
+		if len(rules) == 0 {
+			return nil
+		}
+		return tx.Create(&rules).Error
+	})
+}
+
+// ListExceptions 获取日期范围内的可预约时间例外
+func (r *availabilityRepository) ListExceptions(ctx context.Context, mentorID string, from, to time.Time) ([]*model.MentorAvailabilityException, error) {
+	var exceptions []*model.MentorAvailabilityException
+	err := r.db.WithContext(ctx).
+		Where("mentor_id = ? AND date >= ? AND date <= ?", mentorID, from.Format("2006-01-02"), to.Format("2006-01-02")).
+		Order("date ASC, start_time ASC").
+		Find(&exceptions).Error
+	return exceptions, err
+}
+
+// CreateException 添加可预约时间例外
+func (r *availabilityRepository) CreateException(ctx context.Context, exception *model.MentorAvailabilityException) error {
+	return r.db.WithContext(ctx).Create(exception).Error
+}
+
+// DeleteException 删除大师自己的可预约时间例外
+func (r *availabilityRepository) DeleteException(ctx context.Context, mentorID, exceptionID string) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Where("id = ? AND mentor_id = ?", exceptionID, mentorID).
+		Delete(&model.MentorAvailabilityException{})
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"master-guide-backend/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// calendarFeedMaxEvents 日历订阅最多输出的预约数
+const calendarFeedMaxEvents = 500
+
+// CalendarRepository 日历订阅和预约提醒数据访问接口
+type CalendarRepository interface {
+	SaveFeed(ctx context.Context, feed *model.CalendarFeed) error
+	GetFeedByTokenHash(ctx context.Context, tokenHash string) (*model.CalendarFeed, error)
+	TouchFeed(ctx context.Context, feedID string, accessedAt time.Time) error
+	DeleteFeed(ctx context.Context, userID string) (bool, error)
+	ListUserAppointments(ctx context.Context, userID string, since time.Time) ([]*model.AppointmentModel, error)
+	ListDueReminders(ctx context.Context, offsetMinutes int, now time.Time, limit int) ([]*model.AppointmentModel, error)
+	ClaimReminder(ctx context.Context, reminder *model.AppointmentReminder) (bool, error)
+	ReleaseReminder(ctx context.Context, reminderID string) error
+}
+
+// calendarRepository 日历订阅和预约提醒数据访问实现
+type calendarRepository struct {
+	db *gorm.DB
+}
+
+// NewCalendarRepository 创建日历订阅和预约提醒数据访问实例
+func NewCalendarRepository(db *gorm.DB) CalendarRepository {
+	return &calendarRepository{db: db}
+}
+
+// SaveFeed 保存用户的日历订阅令牌，已有订阅时替换令牌，旧订阅地址随即失效
+func (r *calendarRepository) SaveFeed(ctx context.Context, feed *model.CalendarFeed) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"token_hash":       feed.TokenHash,
+				"last_accessed_at": nil,
+				"updated_at":       time.Now(),
+			}),
+		}).
+		Create(feed).Error
+}
+
+// GetFeedByTokenHash 根据令牌摘要获取日历订阅
+func (r *calendarRepository) GetFeedByTokenHash(ctx context.Context, tokenHash string) (*model.CalendarFeed, error) {
+	var feed model.CalendarFeed
+	err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&feed).Error
+	if err != nil {
+		return nil, err
+	}
+	return &feed, nil
+}
+
+// TouchFeed 记录日历订阅最近一次被访问的时间
+func (r *calendarRepository) TouchFeed(ctx context.Context, feedID string, accessedAt time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&model.CalendarFeed{}).
+		Where("id = ?", feedID).
+		UpdateColumn("last_accessed_at", accessedAt).Error
+}
+
+// DeleteFeed 删除用户的日历订阅
+func (r *calendarRepository) DeleteFeed(ctx context.Context, userID string) (bool, error) {
+	result := r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&model.CalendarFeed{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListUserAppointments 获取用户作为学生或大师参与的预约，包含 since 之后开始的预约
+func (r *calendarRepository) ListUserAppointments(ctx context.Context, userID string, since time.Time) ([]*model.AppointmentModel, error) {
+	var appointments []*model.AppointmentModel
+	err := r.db.WithContext(ctx).
+		Preload("Mentor").
+		Preload("Mentor.Profile").
+		Where("student_id = ? OR mentor_id IN (SELECT id FROM mentors WHERE user_id = ?)", userID, userID).
+		Where("appointment_time >= ?", since).
+		Order("appointment_time ASC").
+		Limit(calendarFeedMaxEvents).
+		Find(&appointments).Error
+	return appointments, err
+}
+
+// ListDueReminders 获取将在 offsetMinutes 分钟内开始、尚未发送该提醒或更晚提醒的已确认预约
+// 已发送更临近开始的提醒时不再补发更早的提醒
+func (r *calendarRepository) ListDueReminders(ctx context.Context, offsetMinutes int, now time.Time, limit int) ([]*model.AppointmentModel, error) {
+	var appointments []*model.AppointmentModel
+	err := r.db.WithContext(ctx).
+		Preload("Mentor").
+		Preload("Mentor.Profile").
+		Where("status = ?", model.AppointmentStatusConfirmed).
+		Where("appointment_time > ? AND appointment_time <= ?", now, now.Add(time.Duration(offsetMinutes)*time.Minute)).
+		Where("NOT EXISTS (SELECT 1 FROM appointment_reminders ar WHERE ar.appointment_id = appointments.id AND ar.offset_minutes <= ?)", offsetMinutes).
+		Order("appointment_time ASC").
+		Limit(limit).
+		Find(&appointments).Error
+	return appointments, err
+}
+
+// ClaimReminder 写入提醒发送记录，已存在时返回 false，避免多个实例重复发送
+func (r *calendarRepository) ClaimReminder(ctx context.Context, reminder *model.AppointmentReminder) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "appointment_id"}, {Name: "offset_minutes"}},
+			DoNothing: true,
+		}).
+		Create(reminder)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ReleaseReminder 发送失败时删除提醒发送记录，下次调度时重试
+func (r *calendarRepository) ReleaseReminder(ctx context.Context, reminderID string) error {
+	return r.db.WithContext(ctx).Where("id = ?", reminderID).Delete(&model.AppointmentReminder{}).Error
+}
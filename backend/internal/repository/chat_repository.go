@@ -2,17 +2,31 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"master-guide-backend/internal/model"
+	"sort"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrChatMessageNotFound 聊天消息不存在
+var ErrChatMessageNotFound = errors.New("chat message not found")
+
 // ChatRepository 聊天数据访问接口
 type ChatRepository interface {
-	GetChatMessages(ctx context.Context, targetID, circleID string, page, pageSize int) ([]*model.ChatMessage, int64, error)
+	GetChatMessages(ctx context.Context, userID, targetID, circleID string, page, pageSize int) ([]*model.ChatMessage, int64, error)
 	SaveMessage(ctx context.Context, message *model.ChatMessage) error
 	GetUserProfile(ctx context.Context, userID string) (*model.ChatUserInfo, error)
+	UserExists(ctx context.Context, userID string) (bool, error)
+	GetCircleMemberIDs(ctx context.Context, circleID string) ([]string, error)
+	MarkDelivered(ctx context.Context, fromUserID, toUserID string, messageIDs []string, at time.Time) ([]string, error)
+	MarkPrivateRead(ctx context.Context, userID, targetID, messageID string, at time.Time) ([]string, error)
+	MarkCircleRead(ctx context.Context, userID, circleID, messageID string) (map[string][]string, error)
+	CountPrivateUnread(ctx context.Context, userID, targetID string) (int64, error)
+	CountCircleUnread(ctx context.Context, userID, circleID string) (int64, error)
+	GetConversations(ctx context.Context, userID string) ([]*model.ChatConversation, error)
 }
 
 type chatRepository struct {
@@ -23,63 +37,61 @@ func NewChatRepository(db *gorm.DB) ChatRepository {
 	return &chatRepository{db: db}
 }
 
-func (r *chatRepository) GetChatMessages(ctx context.Context, targetID, circleID string, page, pageSize int) ([]*model.ChatMessage, int64, error) {
+// chatMessageColumns 查询聊天消息的字段，发送者名称取其最早创建的资料姓名
+const chatMessageColumns = `m.id, m.from_user_id, m.to_user_id, m.circle_id, m.content, m.message_type,
+	m.is_read, m.delivered_at, m.read_at, m.created_at,
+	COALESCE((SELECT p.name FROM user_profiles p WHERE p.user_id = m.from_user_id ORDER BY p.created_at ASC LIMIT 1), '') AS from_user_name`
+
+// GetChatMessages 分页获取聊天记录，私聊为当前用户与 targetID 之间的消息，按发送时间倒序
+func (r *chatRepository) GetChatMessages(ctx context.Context, userID, targetID, circleID string, page, pageSize int) ([]*model.ChatMessage, int64, error) {
 	var messages []*Message
 	var total int64
 
-	query := r.db.WithContext(ctx).Table("messages m").
-		Select(`m.id, m.content, m.message_type as message_type, m.created_at, 
-		        up.name as from_user_name, m.from_user_id as from_user_id`).
-		Joins("LEFT JOIN user_profiles up ON m.from_user_id = up.user_id")
+	query := r.db.WithContext(ctx).Table("messages m")
 
 	// 根据目标类型构建查询条件
 	if targetID != "" {
 		// 私聊消息
-		query = query.Where("(m.from_user_id = ? AND m.to_user_id = ?) OR (m.from_user_id = ? AND m.to_user_id = ?)",
-			targetID, targetID, targetID, targetID)
-	} else if circleID != "" {
+		query = query.Where("m.circle_id IS NULL AND ((m.from_user_id = ? AND m.to_user_id = ?) OR (m.from_user_id = ? AND m.to_user_id = ?))",
+			userID, targetID, targetID, userID)
+	} else {
 		// 圈子消息
 		query = query.Where("m.circle_id = ?", circleID)
 	}
 
 	// 获取总数
-	query.Count(&total)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
 
 	// 分页查询
 	offset := (page - 1) * pageSize
-	err := query.Order("m.created_at DESC").Offset(offset).Limit(pageSize).Find(&messages).Error
+	err := query.Select(chatMessageColumns).
+		Order("m.created_at DESC, m.id DESC").
+		Offset(offset).
+		Limit(pageSize).
+		Find(&messages).Error
 	if err != nil {
 		return nil, 0, err
 	}
 
 	// 转换为 ChatMessage 格式
-	var chatMessages []*model.ChatMessage
+	chatMessages := make([]*model.ChatMessage, 0, len(messages))
 	for _, msg := range messages {
-		createdAt, _ := time.Parse("2006-01-02 15:04:05", msg.CreatedAt)
-		chatMessage := &model.ChatMessage{
-			ID:        msg.ID,
-			Content:   msg.Content,
-			Type:      msg.MessageType,
-			CreatedAt: createdAt,
-			FromUser: &model.ChatUserInfo{
-				ID:   msg.FromUserID,
-				Name: msg.FromUserName,
-			},
-		}
-		chatMessages = append(chatMessages, chatMessage)
+		chatMessages = append(chatMessages, msg.toChatMessage())
 	}
 
 	return chatMessages, total, nil
 }
 
+// SaveMessage 保存消息，消息ID由数据库生成并回填
 func (r *chatRepository) SaveMessage(ctx context.Context, message *model.ChatMessage) error {
 	// 保存到 messages 表
 	dbMessage := &Message{
-		ID:          message.ID,
 		FromUserID:  message.FromUser.ID,
 		Content:     message.Content,
 		MessageType: message.Type,
-		CreatedAt:   message.CreatedAt.Format("2006-01-02 15:04:05"),
+		CreatedAt:   message.CreatedAt,
 	}
 
 	// 如果有目标用户，设置 to_user_id
@@ -92,7 +104,11 @@ func (r *chatRepository) SaveMessage(ctx context.Context, message *model.ChatMes
 		dbMessage.CircleID = &message.CircleID
 	}
 
-	return r.db.WithContext(ctx).Create(dbMessage).Error
+	if err := r.db.WithContext(ctx).Create(dbMessage).Error; err != nil {
+		return err
+	}
+	message.ID = dbMessage.ID
+	return nil
 }
 
 func (r *chatRepository) GetUserProfile(ctx context.Context, userID string) (*model.ChatUserInfo, error) {
@@ -102,6 +118,7 @@ func (r *chatRepository) GetUserProfile(ctx context.Context, userID string) (*mo
 		Table("user_profiles").
 		Select("user_id as id, name").
 		Where("user_id = ?", userID).
+		Order("created_at ASC").
 		First(&userInfo).Error
 
 	if err != nil {
@@ -111,15 +128,333 @@ func (r *chatRepository) GetUserProfile(ctx context.Context, userID string) (*mo
 	return &userInfo, nil
 }
 
+// UserExists 检查用户是否存在
+func (r *chatRepository) UserExists(ctx context.Context, userID string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Table("users").Where("id = ?", userID).Count(&count).Error
+	return count > 0, err
+}
+
+// GetCircleMemberIDs 获取圈子成员的用户ID
+func (r *chatRepository) GetCircleMemberIDs(ctx context.Context, circleID string) ([]string, error) {
+	var userIDs []string
+	err := r.db.WithContext(ctx).
+		Table("circle_members").
+		Where("circle_id = ?", circleID).
+		Pluck("user_id", &userIDs).Error
+	return userIDs, err
+}
+
+// MarkDelivered 将 fromUserID 发给 toUserID 的未送达私聊消息标记为已送达，messageIDs 为空时标记全部，返回本次标记的消息ID
+func (r *chatRepository) MarkDelivered(ctx context.Context, fromUserID, toUserID string, messageIDs []string, at time.Time) ([]string, error) {
+	query := r.db.WithContext(ctx).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
+		Where("from_user_id = ? AND to_user_id = ? AND circle_id IS NULL AND delivered_at IS NULL", fromUserID, toUserID)
+	if len(messageIDs) > 0 {
+		query = query.Where("id IN ?", messageIDs)
+	}
+
+	var updated []*Message
+	if err := query.Model(&updated).Update("delivered_at", at).Error; err != nil {
+		return nil, err
+	}
+	return chatMessageIDs(updated), nil
+}
+
+// MarkPrivateRead 将 targetID 发给 userID、发送时间不晚于 messageID 的未读私聊消息标记为已读，messageID 为空时标记全部
+// 未送达的消息同时记为已送达，返回本次标记的消息ID
+func (r *chatRepository) MarkPrivateRead(ctx context.Context, userID, targetID, messageID string, at time.Time) ([]string, error) {
+	query := r.db.WithContext(ctx).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
+		Where("from_user_id = ? AND to_user_id = ? AND circle_id IS NULL AND is_read = FALSE", targetID, userID)
+	if messageID != "" {
+		var until Message
+		err := r.db.WithContext(ctx).
+			Select("id, created_at").
+			Where("id = ? AND from_user_id = ? AND to_user_id = ? AND circle_id IS NULL", messageID, targetID, userID).
+			First(&until).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrChatMessageNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("created_at <= ?", until.CreatedAt)
+	}
+
+	var updated []*Message
+	err := query.Model(&updated).Updates(map[string]interface{}{
+		"is_read":      true,
+		"read_at":      at,
+		"delivered_at": gorm.Expr("COALESCE(delivered_at, ?)", at),
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+	return chatMessageIDs(updated), nil
+}
+
+// MarkCircleRead 将用户在圈子中的已读位置推进到 messageID，messageID 为空时推进到最新消息；已读位置只前进不后退
+// 返回本次新读到的他人消息ID，按发送者分组
+func (r *chatRepository) MarkCircleRead(ctx context.Context, userID, circleID, messageID string) (map[string][]string, error) {
+	newlyRead := make(map[string][]string)
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var until Message
+		query := tx.Select("id, created_at").Where("circle_id = ?", circleID)
+		if messageID != "" {
+			query = query.Where("id = ?", messageID)
+		}
+		err := query.Order("created_at DESC, id DESC").First(&until).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if messageID != "" {
+				return ErrChatMessageNotFound
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// 锁定成员记录，串行化同一成员的并发已读请求
+		var member model.CircleMember
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("circle_id = ? AND user_id = ?", circleID, userID).
+			First(&member).Error; err != nil {
+			return err
+		}
+
+		var since time.Time
+		if err := tx.Raw(`SELECT COALESCE(
+				(SELECT last_read_at FROM circle_chat_reads WHERE circle_id = ? AND user_id = ?),
+				(SELECT joined_at FROM circle_members WHERE circle_id = ? AND user_id = ?))`,
+			circleID, userID, circleID, userID).
+			Scan(&since).Error; err != nil {
+			return err
+		}
+		if !until.CreatedAt.After(since) {
+			return nil
+		}
+
+		var messages []*Message
+		if err := tx.Select("id, from_user_id").
+			Where("circle_id = ? AND from_user_id <> ? AND created_at > ? AND created_at <= ?", circleID, userID, since, until.CreatedAt).
+			Order("created_at ASC, id ASC").
+			Find(&messages).Error; err != nil {
+			return err
+		}
+		for _, msg := range messages {
+			newlyRead[msg.FromUserID] = append(newlyRead[msg.FromUserID], msg.ID)
+		}
+
+		return tx.Exec(`
+			INSERT INTO circle_chat_reads (circle_id, user_id, last_read_message_id, last_read_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT (circle_id, user_id) DO UPDATE SET
+				last_read_message_id = EXCLUDED.last_read_message_id,
+				last_read_at = EXCLUDED.last_read_at
+			WHERE circle_chat_reads.last_read_at < EXCLUDED.last_read_at`,
+			circleID, userID, until.ID, until.CreatedAt).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newlyRead, nil
+}
+
+// CountPrivateUnread 统计 targetID 发给 userID 的未读私聊消息数
+func (r *chatRepository) CountPrivateUnread(ctx context.Context, userID, targetID string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&Message{}).
+		Where("from_user_id = ? AND to_user_id = ? AND circle_id IS NULL AND is_read = FALSE", targetID, userID).
+		Count(&count).Error
+	return count, err
+}
+
+// CountCircleUnread 统计用户在圈子中已读位置之后的他人消息数，未读过时从加入圈子时开始计算
+func (r *chatRepository) CountCircleUnread(ctx context.Context, userID, circleID string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Table("messages m").
+		Joins("JOIN circle_members cm ON cm.circle_id = m.circle_id AND cm.user_id = ?", userID).
+		Joins("LEFT JOIN circle_chat_reads cr ON cr.circle_id = m.circle_id AND cr.user_id = cm.user_id").
+		Where("m.circle_id = ? AND m.from_user_id <> ?", circleID, userID).
+		Where("m.created_at > COALESCE(cr.last_read_at, cm.joined_at)").
+		Count(&count).Error
+	return count, err
+}
+
+// GetConversations 获取用户的私聊会话和已加入圈子的群聊会话，包含最后一条消息和未读数，按最后一条消息时间倒序
+func (r *chatRepository) GetConversations(ctx context.Context, userID string) ([]*model.ChatConversation, error) {
+	var conversations []*model.ChatConversation
+
+	// 私聊会话：每个对方用户的最后一条消息
+	var privateRows []*chatConversationRow
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT DISTINCT ON (c.partner_id) c.*,
+			COALESCE((SELECT p.name FROM user_profiles p WHERE p.user_id = c.partner_id ORDER BY p.created_at ASC LIMIT 1), '') AS partner_name
+		FROM (
+			SELECT `+chatMessageColumns+`,
+				CASE WHEN m.from_user_id = ? THEN m.to_user_id ELSE m.from_user_id END AS partner_id
+			FROM messages m
+			WHERE m.circle_id IS NULL AND (m.from_user_id = ? OR m.to_user_id = ?)
+		) c
+		ORDER BY c.partner_id, c.created_at DESC, c.id DESC`, userID, userID, userID).
+		Scan(&privateRows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var privateUnread []struct {
+		FromUserID string
+		Count      int64
+	}
+	if err := r.db.WithContext(ctx).
+		Model(&Message{}).
+		Select("from_user_id, COUNT(*) AS count").
+		Where("to_user_id = ? AND circle_id IS NULL AND is_read = FALSE", userID).
+		Group("from_user_id").
+		Scan(&privateUnread).Error; err != nil {
+		return nil, err
+	}
+	unreadByUser := make(map[string]int64, len(privateUnread))
+	for _, row := range privateUnread {
+		unreadByUser[row.FromUserID] = row.Count
+	}
+
+	for _, row := range privateRows {
+		conversations = append(conversations, &model.ChatConversation{
+			Type:        "private",
+			TargetID:    row.PartnerID,
+			Name:        row.PartnerName,
+			LastMessage: row.Message.toChatMessage(),
+			UnreadCount: unreadByUser[row.PartnerID],
+		})
+	}
+
+	// 圈子会话：已加入的圈子及其未读数
+	var circleRows []struct {
+		CircleID    string
+		Name        string
+		UnreadCount int64
+	}
+	err = r.db.WithContext(ctx).Raw(`
+		SELECT c.id AS circle_id, c.name,
+			(SELECT COUNT(*) FROM messages m
+				WHERE m.circle_id = c.id AND m.from_user_id <> cm.user_id
+				AND m.created_at > COALESCE(cr.last_read_at, cm.joined_at)) AS unread_count
+		FROM circle_members cm
+		JOIN circles c ON c.id = cm.circle_id
+		LEFT JOIN circle_chat_reads cr ON cr.circle_id = cm.circle_id AND cr.user_id = cm.user_id
+		WHERE cm.user_id = ?`, userID).
+		Scan(&circleRows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(circleRows) > 0 {
+		circleIDs := make([]string, 0, len(circleRows))
+		for _, row := range circleRows {
+			circleIDs = append(circleIDs, row.CircleID)
+		}
+
+		var lastMessages []*Message
+		if err := r.db.WithContext(ctx).Raw(`
+			SELECT DISTINCT ON (m.circle_id) `+chatMessageColumns+`
+			FROM messages m
+			WHERE m.circle_id IN ?
+			ORDER BY m.circle_id, m.created_at DESC, m.id DESC`, circleIDs).
+			Scan(&lastMessages).Error; err != nil {
+			return nil, err
+		}
+		lastByCircle := make(map[string]*Message, len(lastMessages))
+		for _, msg := range lastMessages {
+			lastByCircle[*msg.CircleID] = msg
+		}
+
+		for _, row := range circleRows {
+			conversation := &model.ChatConversation{
+				Type:        "circle",
+				CircleID:    row.CircleID,
+				Name:        row.Name,
+				UnreadCount: row.UnreadCount,
+			}
+			if msg, ok := lastByCircle[row.CircleID]; ok {
+				conversation.LastMessage = msg.toChatMessage()
+			}
+			conversations = append(conversations, conversation)
+		}
+	}
+
+	// 有消息的会话按最后一条消息时间倒序，没有消息的圈子排在最后
+	sort.SliceStable(conversations, func(i, j int) bool {
+		a, b := conversations[i].LastMessage, conversations[j].LastMessage
+		if a == nil || b == nil {
+			return b == nil && a != nil
+		}
+		return a.CreatedAt.After(b.CreatedAt)
+	})
+	return conversations, nil
+}
+
 // Message 数据库消息模型（用于内部使用）
 type Message struct {
-	ID           string  `json:"id" gorm:"primaryKey;type:varchar(32)"`
-	FromUserID   string  `json:"from_user_id"`
-	FromUserName string  `json:"from_user_name"`
-	ToUserID     *string `json:"to_user_id"`
-	CircleID     *string `json:"circle_id"`
-	Content      string  `json:"content"`
-	MessageType  string  `json:"message_type"`
-	IsRead       bool    `json:"is_read"`
-	CreatedAt    string  `json:"created_at"`
+	ID           string     `json:"id" gorm:"primaryKey;type:varchar(32);default:(-)"`
+	FromUserID   string     `json:"from_user_id"`
+	FromUserName string     `json:"from_user_name" gorm:"->"`
+	ToUserID     *string    `json:"to_user_id"`
+	CircleID     *string    `json:"circle_id"`
+	Content      string     `json:"content"`
+	MessageType  string     `json:"message_type"`
+	IsRead       bool       `json:"is_read"`
+	DeliveredAt  *time.Time `json:"delivered_at"`
+	ReadAt       *time.Time `json:"read_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// toChatMessage 转换为聊天消息，私聊消息附带送达和已读状态
+func (m *Message) toChatMessage() *model.ChatMessage {
+	chatMessage := &model.ChatMessage{
+		ID:        m.ID,
+		Content:   m.Content,
+		Type:      m.MessageType,
+		CreatedAt: m.CreatedAt,
+		FromUser: &model.ChatUserInfo{
+			ID:   m.FromUserID,
+			Name: m.FromUserName,
+		},
+	}
+	if m.CircleID != nil {
+		chatMessage.CircleID = *m.CircleID
+		return chatMessage
+	}
+	if m.ToUserID != nil {
+		chatMessage.ToUserID = *m.ToUserID
+	}
+	chatMessage.DeliveredAt = m.DeliveredAt
+	chatMessage.ReadAt = m.ReadAt
+	switch {
+	case m.IsRead:
+		chatMessage.Status = model.ChatMessageStatusRead
+	case m.DeliveredAt != nil:
+		chatMessage.Status = model.ChatMessageStatusDelivered
+	default:
+		chatMessage.Status = model.ChatMessageStatusSent
+	}
+	return chatMessage
+}
+
+// chatConversationRow 私聊会话查询结果
+type chatConversationRow struct {
+	Message
+	PartnerID   string
+	PartnerName string
+}
+
+// chatMessageIDs 提取消息ID
+func chatMessageIDs(messages []*Message) []string {
+	ids := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		ids = append(ids, msg.ID)
+	}
+	return ids
 }
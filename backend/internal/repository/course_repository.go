@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"errors"
 
 	"master-guide-backend/internal/model"
 
@@ -16,11 +17,17 @@ type CourseRepository interface {
 	SearchCourses(ctx context.Context, query, domain, difficulty string, minPrice, maxPrice float64, sortBy string, page, pageSize int) ([]*model.Course, int64, error)
 	GetRecommendedCourses(ctx context.Context, userID string, limit int) ([]*model.Course, error)
 	GetEnrolledCourses(ctx context.Context, userID, status string, page, pageSize int) ([]*model.Course, int64, error)
-	EnrollCourse(ctx context.Context, userID, courseID string) error
+	EnrollCourse(ctx context.Context, userID, courseID, status string) (*model.LearningRecordModel, error)
+	CancelPendingEnrollment(ctx context.Context, enrollmentID string) error
+	GetActiveEnrollment(ctx context.Context, userID, courseID string) (*model.LearningRecordModel, error)
+	DropEnrollment(ctx context.Context, enrollmentID, fromStatus string) (bool, error)
 	GetCourseProgress(ctx context.Context, userID, courseID string) (*model.LearningRecordModel, error)
 	GetCompletedContents(ctx context.Context, userID, courseID string) ([]string, error)
 }
 
+// ErrEnrollmentExists 已存在未取消的报名记录
+var ErrEnrollmentExists = errors.New("enrollment exists")
+
 // courseRepository 课程数据访问实现
 type courseRepository struct {
 	db *gorm.DB
@@ -186,7 +193,7 @@ func (r *courseRepository) GetEnrolledCourses(ctx context.Context, userID, statu
 		Preload("Mentor").
 		Preload("Mentor.Profile").
 		Joins("JOIN learning_records lr ON courses.id = lr.course_id").
-		Where("lr.user_id = ?", userID)
+		Where("lr.user_id = ? AND lr.status NOT IN ?", userID, []string{model.LearningStatusPendingPayment, model.LearningStatusCancelled, model.LearningStatusDropped})
 
 	if status != "" {
 		query = query.Where("lr.status = ?", status)
@@ -204,36 +211,66 @@ func (r *courseRepository) GetEnrolledCourses(ctx context.Context, userID, statu
 	return courses, total, err
 }
 
-// EnrollCourse 报名课程
-func (r *courseRepository) EnrollCourse(ctx context.Context, userID, courseID string) error {
+// EnrollCourse 报名课程，status 为学习记录初始状态
+// 已存在未取消且未退课的报名时返回已有记录和 ErrEnrollmentExists
+func (r *courseRepository) EnrollCourse(ctx context.Context, userID, courseID, status string) (*model.LearningRecordModel, error) {
 	// 检查是否已经报名
-	var count int64
-	err := r.db.WithContext(ctx).Model(&model.LearningRecordModel{}).
-		Where("user_id = ? AND course_id = ?", userID, courseID).
-		Count(&count).Error
-	if err != nil {
-		return err
+	var existing model.LearningRecordModel
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND course_id = ? AND status NOT IN ?", userID, courseID, []string{model.LearningStatusCancelled, model.LearningStatusDropped}).
+		First(&existing).Error
+	if err == nil {
+		return &existing, ErrEnrollmentExists
 	}
-
-	if count > 0 {
-		return gorm.ErrRecordNotFound // 使用这个错误表示已报名
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
 	}
 
 	// 创建学习记录
 	learningRecord := &model.LearningRecordModel{
 		UserID:   userID,
 		CourseID: courseID,
-		Status:   "enrolled",
+		Status:   status,
+	}
+
+	if err := r.db.WithContext(ctx).Create(learningRecord).Error; err != nil {
+		return nil, err
+	}
+	return learningRecord, nil
+}
+
+// CancelPendingEnrollment 取消待支付的报名
+func (r *courseRepository) CancelPendingEnrollment(ctx context.Context, enrollmentID string) error {
+	return r.db.WithContext(ctx).Model(&model.LearningRecordModel{}).
+		Where("id = ? AND status = ?", enrollmentID, model.LearningStatusPendingPayment).
+		Update("status", model.LearningStatusCancelled).Error
+}
+
+// GetActiveEnrollment 获取用户在课程中未取消且未退课的报名记录
+func (r *courseRepository) GetActiveEnrollment(ctx context.Context, userID, courseID string) (*model.LearningRecordModel, error) {
+	var record model.LearningRecordModel
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND course_id = ? AND status NOT IN ?", userID, courseID, []string{model.LearningStatusCancelled, model.LearningStatusDropped}).
+		First(&record).Error
+	if err != nil {
+		return nil, err
 	}
+	return &record, nil
+}
 
-	return r.db.WithContext(ctx).Create(learningRecord).Error
+// DropEnrollment 退课，报名状态仍为 fromStatus 时才更新，返回是否更新成功
+func (r *courseRepository) DropEnrollment(ctx context.Context, enrollmentID, fromStatus string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&model.LearningRecordModel{}).
+		Where("id = ? AND status = ?", enrollmentID, fromStatus).
+		Update("status", model.LearningStatusDropped)
+	return result.RowsAffected > 0, result.Error
 }
 
 // GetCourseProgress 获取课程进度
 func (r *courseRepository) GetCourseProgress(ctx context.Context, userID, courseID string) (*model.LearningRecordModel, error) {
 	var record model.LearningRecordModel
 	err := r.db.WithContext(ctx).
-		Where("user_id = ? AND course_id = ?", userID, courseID).
+		Where("user_id = ? AND course_id = ? AND status NOT IN ?", userID, courseID, []string{model.LearningStatusPendingPayment, model.LearningStatusCancelled, model.LearningStatusDropped}).
 		First(&record).Error
 	if err != nil {
 		return nil, err
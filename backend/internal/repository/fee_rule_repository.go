@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"master-guide-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// FeeRuleRepository 费率规则数据访问接口
+type FeeRuleRepository interface {
+	CreateRule(ctx context.Context, rule *model.FeeRule) error
+	UpdateRule(ctx context.Context, rule *model.FeeRule) error
+	GetRuleByID(ctx context.Context, ruleID string) (*model.FeeRule, error)
+	ListRules(ctx context.Context, feeType, status string) ([]*model.FeeRule, error)
+	ListEffectiveRules(ctx context.Context, feeType string, at time.Time) ([]*model.FeeRule, error)
+	IsRuleApplied(ctx context.Context, ruleID string) (bool, error)
+	UpdateMentorTier(ctx context.Context, mentorID, tier string) (bool, error)
+}
+
+// feeRuleRepository 费率规则数据访问实现
+type feeRuleRepository struct {
+	db *gorm.DB
+}
+
+// NewFeeRuleRepository 创建费率规则数据访问实例
+func NewFeeRuleRepository(db *gorm.DB) FeeRuleRepository {
+	return &feeRuleRepository{db: db}
+}
+
+// CreateRule 创建费率规则
+func (r *feeRuleRepository) CreateRule(ctx context.Context, rule *model.FeeRule) error {
+	return r.db.WithContext(ctx).Create(rule).Error
+}
+
+// UpdateRule 更新费率规则的全部可编辑字段
+func (r *feeRuleRepository) UpdateRule(ctx context.Context, rule *model.FeeRule) error {
+	return r.db.WithContext(ctx).
+		Model(rule).
+		Select("name", "fee_type", "order_type", "domain", "mentor_tier", "rate", "fixed_fee", "min_fee", "max_fee",
+			"is_promotional", "priority", "effective_from", "effective_to", "status", "description").
+		Updates(rule).Error
+}
+
+// GetRuleByID 根据ID获取费率规则
+func (r *feeRuleRepository) GetRuleByID(ctx context.Context, ruleID string) (*model.FeeRule, error) {
+	var rule model.FeeRule
+	err := r.db.WithContext(ctx).Where("id = ?", ruleID).First(&rule).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// ListRules 获取费率规则，条件为空时不过滤
+func (r *feeRuleRepository) ListRules(ctx context.Context, feeType, status string) ([]*model.FeeRule, error) {
+	var rules []*model.FeeRule
+	query := r.db.WithContext(ctx)
+	if feeType != "" {
+		query = query.Where("fee_type = ?", feeType)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("fee_type, effective_from DESC, created_at DESC").Find(&rules).Error
+	return rules, err
+}
+
+// ListEffectiveRules 获取指定时间生效中的费率规则
+func (r *feeRuleRepository) ListEffectiveRules(ctx context.Context, feeType string, at time.Time) ([]*model.FeeRule, error) {
+	var rules []*model.FeeRule
+	err := r.db.WithContext(ctx).
+		Where("fee_type = ? AND status = ? AND effective_from <= ?", feeType, model.FeeRuleStatusActive, at).
+		Where("effective_to IS NULL OR effective_to > ?", at).
+		Find(&rules).Error
+	return rules, err
+}
+
+// IsRuleApplied 判断费率规则是否已被收入或提现记录使用
+func (r *feeRuleRepository) IsRuleApplied(ctx context.Context, ruleID string) (bool, error) {
+	var applied bool
+	err := r.db.WithContext(ctx).
+		Raw(`SELECT EXISTS (SELECT 1 FROM income_transactions WHERE fee_rule_id = ?)
+			OR EXISTS (SELECT 1 FROM withdrawals WHERE fee_rule_id = ?)`, ruleID, ruleID).
+		Scan(&applied).Error
+	return applied, err
+}
+
+// UpdateMentorTier 设置大师等级，大师不存在时返回 false
+func (r *feeRuleRepository) UpdateMentorTier(ctx context.Context, mentorID, tier string) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&model.Mentor{}).
+		Where("id = ?", mentorID).
+		Update("tier", tier)
+	return result.RowsAffected > 0, result.Error
+}
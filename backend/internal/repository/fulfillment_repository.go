@@ -0,0 +1,405 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"master-guide-backend/internal/ledger"
+	"master-guide-backend/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrOrderUnfulfillable 业务订单已不是可开通的状态，已支付的订单无法履约，需退回支付金额
+var ErrOrderUnfulfillable = errors.New("order unfulfillable")
+
+// FulfillmentRepository 支付履约数据访问接口
+// 履约与释放均以支付订单行锁串行化，并通过 payment_orders.fulfilled_at 保证只履约一次
+// 业务订单无法开通时记录 payment_orders.fulfillment_failed_at 并返回 ErrOrderUnfulfillable，不记录收入
+type FulfillmentRepository interface {
+	GetEnrollmentByID(ctx context.Context, id string) (*model.LearningRecordModel, error)
+	ActivateEnrollment(ctx context.Context, orderID, enrollmentID string, income *model.IncomeTransactionModel) (bool, error)
+	ConfirmAppointment(ctx context.Context, orderID, appointmentID string, income *model.IncomeTransactionModel) (bool, error)
+	ActivatePackagePurchase(ctx context.Context, orderID, purchaseID string, income *model.IncomeTransactionModel) (bool, error)
+	ReleaseEnrollment(ctx context.Context, orderID, enrollmentID string) (bool, error)
+	ReleaseAppointment(ctx context.Context, orderID, appointmentID string) (bool, error)
+	ReleasePackagePurchase(ctx context.Context, orderID, purchaseID string) (bool, error)
+	ReverseIncome(ctx context.Context, orderID, refundID string, build IncomeReversalBuilder) (*IncomeReversalResult, error)
+}
+
+// IncomeReversalResult 收入冲正结果
+type IncomeReversalResult struct {
+	Reversed bool
+	// CancelledAppointmentIDs 咨询套餐退款收回次数时取消的未开始预约
+	CancelledAppointmentIDs []string
+}
+
+// IncomeReversalBuilder 根据原收入记录和此前已冲正的累计金额、平台费（均为正数）生成本次退款的冲正记录
+type IncomeReversalBuilder func(source *model.IncomeTransactionModel, reversedAmount, reversedFee float64) *model.IncomeTransactionModel
+
+// fulfillmentRepository 支付履约数据访问实现
+type fulfillmentRepository struct {
+	db *gorm.DB
+}
+
+// NewFulfillmentRepository 创建支付履约数据访问实例
+func NewFulfillmentRepository(db *gorm.DB) FulfillmentRepository {
+	return &fulfillmentRepository{db: db}
+}
+
+// GetEnrollmentByID 根据ID获取报名学习记录
+func (r *fulfillmentRepository) GetEnrollmentByID(ctx context.Context, id string) (*model.LearningRecordModel, error) {
+	var record model.LearningRecordModel
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&record).Error
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// ActivateEnrollment 开通已支付的课程报名并记录大师收入
+func (r *fulfillmentRepository) ActivateEnrollment(ctx context.Context, orderID, enrollmentID string, income *model.IncomeTransactionModel) (bool, error) {
+	return r.fulfill(ctx, orderID, income, func(tx *gorm.DB, now time.Time) (bool, error) {
+		result := tx.Model(&model.LearningRecordModel{}).
+			Where("id = ? AND status IN ?", enrollmentID, []string{model.LearningStatusPendingPayment, model.LearningStatusCancelled}).
+			Updates(map[string]interface{}{
+				"status":           model.LearningStatusEnrolled,
+				"enrolled_at":      now,
+				"last_accessed_at": now,
+			})
+		return result.RowsAffected > 0, result.Error
+	})
+}
+
+// ConfirmAppointment 确认已支付的预约并记录大师收入
+// 预约已因支付超时取消时，迟到的支付需重新检查时段冲突，时段已被其他预约占用时不确认
+func (r *fulfillmentRepository) ConfirmAppointment(ctx context.Context, orderID, appointmentID string, income *model.IncomeTransactionModel) (bool, error) {
+	return r.fulfill(ctx, orderID, income, func(tx *gorm.DB, now time.Time) (bool, error) {
+		var appointment model.AppointmentModel
+		if err := tx.Select("id", "mentor_id", "student_id").
+			First(&appointment, "id = ?", appointmentID).Error; err != nil {
+			return false, err
+		}
+		// 与预约和改期相同，先锁定大师和学生再锁定预约，串行化同一大师或学生的时段占用
+		if err := lockAppointmentParties(tx, appointment.MentorID, appointment.StudentID); err != nil {
+			return false, err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&appointment, "id = ?", appointmentID).Error; err != nil {
+			return false, err
+		}
+
+		if appointment.Status == model.AppointmentStatusCancelled {
+			var mentor model.Mentor
+			if err := tx.Select("id", "buffer_minutes").
+				First(&mentor, "id = ?", appointment.MentorID).Error; err != nil {
+				return false, err
+			}
+			err := checkAppointmentConflict(tx, &appointment, appointment.AppointmentTime, mentor.BufferMinutes)
+			if errors.Is(err, ErrMentorTimeConflict) || errors.Is(err, ErrStudentTimeConflict) {
+				return false, nil
+			}
+			if err != nil {
+				return false, err
+			}
+		}
+		return transitionAppointment(tx, appointmentID, []string{model.AppointmentStatusPendingPayment, model.AppointmentStatusCancelled}, model.AppointmentStatusConfirmed, "支付完成")
+	})
+}
+
+// ActivatePackagePurchase 开通已支付的咨询套餐并记录大师收入，有效期自开通时起算
+func (r *fulfillmentRepository) ActivatePackagePurchase(ctx context.Context, orderID, purchaseID string, income *model.IncomeTransactionModel) (bool, error) {
+	return r.fulfill(ctx, orderID, income, func(tx *gorm.DB, now time.Time) (bool, error) {
+		result := tx.Model(&model.AppointmentPackagePurchase{}).
+			Where("id = ? AND status IN ?", purchaseID, []string{model.PackagePurchaseStatusPendingPayment, model.PackagePurchaseStatusCancelled}).
+			Updates(map[string]interface{}{
+				"status":       model.PackagePurchaseStatusActive,
+				"activated_at": now,
+				"expires_at":   gorm.Expr("CAST(? AS TIMESTAMP) + make_interval(days => validity_days)", now),
+			})
+		return result.RowsAffected > 0, result.Error
+	})
+}
+
+// ReleaseEnrollment 支付失败或过期后释放待支付的报名
+func (r *fulfillmentRepository) ReleaseEnrollment(ctx context.Context, orderID, enrollmentID string) (bool, error) {
+	return r.release(ctx, orderID, func(tx *gorm.DB) (bool, error) {
+		result := tx.Model(&model.LearningRecordModel{}).
+			Where("id = ? AND status = ?", enrollmentID, model.LearningStatusPendingPayment).
+			Update("status", model.LearningStatusCancelled)
+		return result.RowsAffected > 0, result.Error
+	})
+}
+
+// ReleaseAppointment 支付失败或过期后释放待支付的预约时段
+func (r *fulfillmentRepository) ReleaseAppointment(ctx context.Context, orderID, appointmentID string) (bool, error) {
+	return r.release(ctx, orderID, func(tx *gorm.DB) (bool, error) {
+		return transitionAppointment(tx, appointmentID, []string{model.AppointmentStatusPendingPayment}, model.AppointmentStatusCancelled, "支付未完成")
+	})
+}
+
+// ReleasePackagePurchase 支付失败或过期后取消待支付的套餐购买
+func (r *fulfillmentRepository) ReleasePackagePurchase(ctx context.Context, orderID, purchaseID string) (bool, error) {
+	return r.release(ctx, orderID, func(tx *gorm.DB) (bool, error) {
+		result := tx.Model(&model.AppointmentPackagePurchase{}).
+			Where("id = ? AND status = ?", purchaseID, model.PackagePurchaseStatusPendingPayment).
+			Update("status", model.PackagePurchaseStatusCancelled)
+		return result.RowsAffected > 0, result.Error
+	})
+}
+
+// ReverseIncome 退款完成后写入收入冲正记录和记账凭证，同一退款只冲正一次；订单尚未入账时 Reversed 为 false
+// 原收入行锁与收入结算串行化，结算期内的收入从待结算收入扣回，已结算的从可提现余额扣回
+// 咨询套餐订单同时按累计退款比例收回套餐次数，已预约的次数不足时取消最晚的未开始预约
+func (r *fulfillmentRepository) ReverseIncome(ctx context.Context, orderID, refundID string, build IncomeReversalBuilder) (*IncomeReversalResult, error) {
+	result := &IncomeReversalResult{}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 以支付订单行锁串行化同一订单的多笔退款冲正
+		var order model.PaymentOrder
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", orderID).
+			First(&order).Error; err != nil {
+			return err
+		}
+
+		var source model.IncomeTransactionModel
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("payment_order_id = ?", order.ID).
+			First(&source).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var existing int64
+		if err := tx.Model(&model.IncomeTransactionModel{}).
+			Where("refund_id = ?", refundID).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return nil
+		}
+
+		var totals struct {
+			Amount      float64
+			PlatformFee float64
+		}
+		if err := tx.Model(&model.IncomeTransactionModel{}).
+			Where("source_transaction_id = ?", source.ID).
+			Select("COALESCE(-SUM(amount), 0) AS amount, COALESCE(-SUM(platform_fee), 0) AS platform_fee").
+			Scan(&totals).Error; err != nil {
+			return err
+		}
+
+		reversal := build(&source, totals.Amount, totals.PlatformFee)
+		reversal.RefundID = &refundID
+		reversal.SourceTransactionID = &source.ID
+		reversal.SettlementStatus = source.SettlementStatus
+		if err := tx.Create(reversal).Error; err != nil {
+			return err
+		}
+		settled := source.SettlementStatus == model.IncomeSettlementSettled
+		entry := ledger.IncomeReversed(refundID, order.ID, source.MentorID, -ledger.ToMinor(reversal.Amount), -ledger.ToMinor(reversal.PlatformFee), settled, time.Now())
+		if _, err := postLedgerTransaction(tx, entry); err != nil {
+			return err
+		}
+		result.Reversed = true
+
+		if order.OrderType == model.PaymentOrderTypeAppointmentPackage && source.Amount > 0 {
+			refundedRate := (totals.Amount - reversal.Amount) / source.Amount
+			cancelled, err := revokePackageSessions(tx, order.OrderRefID, refundedRate)
+			if err != nil {
+				return err
+			}
+			result.CancelledAppointmentIDs = cancelled
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// revokePackageSessions 按累计退款比例收回已购买套餐的次数，不足一次的按一次收回，全额退款时套餐标记为已退款
+// 剩余次数不足以覆盖已预约的次数时，从最晚的未开始预约起取消并退回占用的次数，返回取消的预约ID
+func revokePackageSessions(tx *gorm.DB, purchaseID string, refundedRate float64) ([]string, error) {
+	var purchase model.AppointmentPackagePurchase
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&purchase, "id = ?", purchaseID).Error; err != nil {
+		return nil, err
+	}
+
+	revoked := int(math.Ceil(refundedRate*float64(purchase.TotalSessions) - 1e-9))
+	if revoked > purchase.TotalSessions {
+		revoked = purchase.TotalSessions
+	}
+	if revoked <= purchase.RevokedSessions {
+		return nil, nil
+	}
+	updates := map[string]interface{}{"revoked_sessions": revoked}
+	if revoked == purchase.TotalSessions {
+		updates["status"] = model.PackagePurchaseStatusRefunded
+	}
+	if err := tx.Model(&model.AppointmentPackagePurchase{}).
+		Where("id = ?", purchase.ID).
+		Updates(updates).Error; err != nil {
+		return nil, err
+	}
+
+	excess := purchase.UsedSessions - (purchase.TotalSessions - revoked)
+	if excess <= 0 {
+		return nil, nil
+	}
+	var appointments []*model.AppointmentModel
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").
+		Where("package_purchase_id = ? AND package_credit_returned = ? AND status IN ? AND appointment_time > ?",
+			purchase.ID, false, model.AppointmentActiveStatuses, time.Now()).
+		Order("appointment_time DESC").
+		Limit(excess).
+		Find(&appointments).Error; err != nil {
+		return nil, err
+	}
+
+	cancelled := make([]string, 0, len(appointments))
+	for _, appointment := range appointments {
+		changed, err := transitionAppointment(tx, appointment.ID, model.AppointmentActiveStatuses, model.AppointmentStatusCancelled, "套餐已退款")
+		if err != nil {
+			return nil, err
+		}
+		if !changed {
+			continue
+		}
+		if err := tx.Model(&model.AppointmentModel{}).
+			Where("id = ?", appointment.ID).
+			Update("package_credit_returned", true).Error; err != nil {
+			return nil, err
+		}
+		cancelled = append(cancelled, appointment.ID)
+	}
+	if len(cancelled) == 0 {
+		return nil, nil
+	}
+	return cancelled, tx.Model(&model.AppointmentPackagePurchase{}).
+		Where("id = ?", purchase.ID).
+		Update("used_sessions", gorm.Expr("used_sessions - ?", len(cancelled))).Error
+}
+
+// transitionAppointment 锁定预约，当前状态在 from 中时变更为 to 并记录系统操作
+func transitionAppointment(tx *gorm.DB, appointmentID string, from []string, to, reason string) (bool, error) {
+	var appointment model.AppointmentModel
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "status").
+		First(&appointment, "id = ?", appointmentID).Error; err != nil {
+		return false, err
+	}
+
+	allowed := false
+	for _, status := range from {
+		if appointment.Status == status {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return false, nil
+	}
+
+	if err := tx.Model(&model.AppointmentModel{}).
+		Where("id = ?", appointmentID).
+		Update("status", to).Error; err != nil {
+		return false, err
+	}
+	if err := tx.Create(&model.AppointmentStatusHistory{
+		AppointmentID: appointmentID,
+		FromStatus:    appointment.Status,
+		ToStatus:      to,
+		ActorType:     model.AppointmentActorSystem,
+		Reason:        reason,
+	}).Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// fulfill 锁定已完成且未履约的支付订单，执行开通操作、写入收入和记账凭证并标记履约时间
+// 开通操作未变更任何业务记录时不记录收入，标记订单无法履约后返回 ErrOrderUnfulfillable
+func (r *fulfillmentRepository) fulfill(ctx context.Context, orderID string, income *model.IncomeTransactionModel, activate func(tx *gorm.DB, now time.Time) (bool, error)) (bool, error) {
+	fulfilled, unfulfillable := false, false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var order model.PaymentOrder
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", orderID).
+			First(&order).Error; err != nil {
+			return err
+		}
+		if order.Status != "completed" || order.FulfilledAt != nil {
+			return nil
+		}
+		if order.FulfillmentFailedAt != nil {
+			unfulfillable = true
+			return nil
+		}
+
+		now := time.Now()
+		activated, err := activate(tx, now)
+		if err != nil {
+			return err
+		}
+		if !activated {
+			unfulfillable = true
+			return tx.Model(&model.PaymentOrder{}).
+				Where("id = ?", order.ID).
+				Update("fulfillment_failed_at", now).Error
+		}
+		if income != nil {
+			income.PaymentOrderID = &order.ID
+			if err := tx.Create(income).Error; err != nil {
+				return err
+			}
+			entry := ledger.OrderFulfilled(order.ID, income.MentorID, ledger.ToMinor(income.Amount), ledger.ToMinor(income.PlatformFee), now)
+			if _, err := postLedgerTransaction(tx, entry); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&model.PaymentOrder{}).
+			Where("id = ?", order.ID).
+			Update("fulfilled_at", now).Error; err != nil {
+			return err
+		}
+		fulfilled = true
+		return nil
+	})
+	if err == nil && unfulfillable {
+		return false, ErrOrderUnfulfillable
+	}
+	return fulfilled, err
+}
+
+// release 锁定未完成的支付订单并释放对应的业务记录
+func (r *fulfillmentRepository) release(ctx context.Context, orderID string, cancel func(tx *gorm.DB) (bool, error)) (bool, error) {
+	released := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var order model.PaymentOrder
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", orderID).
+			First(&order).Error; err != nil {
+			return err
+		}
+		if order.Status == "completed" || order.FulfilledAt != nil {
+			return nil
+		}
+
+		var err error
+		released, err = cancel(tx)
+		return err
+	})
+	return released, err
+}
@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"master-guide-backend/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IncomeReportRepository 收入报告数据访问接口
+type IncomeReportRepository interface {
+	CreateReport(ctx context.Context, report *model.IncomeReport) error
+	GetReport(ctx context.Context, reportID string) (*model.IncomeReport, error)
+	ClaimPendingReports(ctx context.Context, staleBefore time.Time, limit int) ([]*model.IncomeReport, error)
+	CompleteReport(ctx context.Context, reportID string, file *model.UploadFile, completedAt, expiresAt time.Time) error
+	FailReport(ctx context.Context, reportID, message string, retry bool) error
+	ListExpiredReports(ctx context.Context, now time.Time, limit int) ([]*model.IncomeReport, error)
+	ExpireReport(ctx context.Context, report *model.IncomeReport) error
+	ListReportLines(ctx context.Context, mentorID, incomeType string, startDate, endDate time.Time) ([]*model.IncomeReportLine, error)
+	ListReportWithdrawals(ctx context.Context, mentorID string, startDate, endDate time.Time) ([]*model.WithdrawalModel, error)
+}
+
+// incomeReportRepository 收入报告数据访问实现
+type incomeReportRepository struct {
+	db *gorm.DB
+}
+
+// NewIncomeReportRepository 创建收入报告数据访问实例
+func NewIncomeReportRepository(db *gorm.DB) IncomeReportRepository {
+	return &incomeReportRepository{db: db}
+}
+
+// CreateReport 创建收入报告任务
+func (r *incomeReportRepository) CreateReport(ctx context.Context, report *model.IncomeReport) error {
+	return r.db.WithContext(ctx).Create(report).Error
+}
+
+// GetReport 根据ID获取收入报告及其文件记录
+func (r *incomeReportRepository) GetReport(ctx context.Context, reportID string) (*model.IncomeReport, error) {
+	var report model.IncomeReport
+	if err := r.db.WithContext(ctx).Preload("UploadFile").First(&report, "id = ?", reportID).Error; err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// ClaimPendingReports 领取排队中的报告并标记为生成中，生成中超过 staleBefore 未更新的报告视为实例中断后重新领取
+func (r *incomeReportRepository) ClaimPendingReports(ctx context.Context, staleBefore time.Time, limit int) ([]*model.IncomeReport, error) {
+	var reports []*model.IncomeReport
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? OR (status = ? AND updated_at < ?)", model.IncomeReportStatusPending, model.IncomeReportStatusProcessing, staleBefore).
+			Order("created_at ASC").
+			Limit(limit).
+			Find(&reports).Error; err != nil {
+			return err
+		}
+		if len(reports) == 0 {
+			return nil
+		}
+
+		ids := make([]string, len(reports))
+		for i, report := range reports {
+			ids[i] = report.ID
+			report.Status = model.IncomeReportStatusProcessing
+			report.Attempts++
+		}
+		return tx.Model(&model.IncomeReport{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"status":   model.IncomeReportStatusProcessing,
+				"attempts": gorm.Expr("attempts + 1"),
+			}).Error
+	})
+	return reports, err
+}
+
+// CompleteReport 保存报告文件记录并将报告标记为已完成
+func (r *incomeReportRepository) CompleteReport(ctx context.Context, reportID string, file *model.UploadFile, completedAt, expiresAt time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(file).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.IncomeReport{}).
+			Where("id = ?", reportID).
+			Updates(map[string]interface{}{
+				"status":         model.IncomeReportStatusCompleted,
+				"upload_file_id": file.ID,
+				"error_message":  nil,
+				"completed_at":   completedAt,
+				"expires_at":     expiresAt,
+			}).Error
+	})
+}
+
+// FailReport 记录报告生成失败，retry 为 true 时重新排队等待下次生成
+func (r *incomeReportRepository) FailReport(ctx context.Context, reportID, message string, retry bool) error {
+	status := model.IncomeReportStatusFailed
+	if retry {
+		status = model.IncomeReportStatusPending
+	}
+	return r.db.WithContext(ctx).Model(&model.IncomeReport{}).
+		Where("id = ?", reportID).
+		Updates(map[string]interface{}{
+			"status":        status,
+			"error_message": message,
+		}).Error
+}
+
+// ListExpiredReports 获取下载地址已过期但文件尚未清理的报告
+func (r *incomeReportRepository) ListExpiredReports(ctx context.Context, now time.Time, limit int) ([]*model.IncomeReport, error) {
+	var reports []*model.IncomeReport
+	err := r.db.WithContext(ctx).
+		Preload("UploadFile").
+		Where("status = ? AND expires_at < ?", model.IncomeReportStatusCompleted, now).
+		Order("expires_at ASC").
+		Limit(limit).
+		Find(&reports).Error
+	return reports, err
+}
+
+// ExpireReport 将报告标记为已过期并删除其文件记录
+func (r *incomeReportRepository) ExpireReport(ctx context.Context, report *model.IncomeReport) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.IncomeReport{}).
+			Where("id = ?", report.ID).
+			Updates(map[string]interface{}{
+				"status":         model.IncomeReportStatusExpired,
+				"upload_file_id": nil,
+			}).Error; err != nil {
+			return err
+		}
+		if report.UploadFileID == nil {
+			return nil
+		}
+		return tx.Delete(&model.UploadFile{}, "id = ?", *report.UploadFileID).Error
+	})
+}
+
+// ListReportLines 获取区间内的收入和退款冲正明细，endDate 不含；退款冲正按原收入的类型和服务项目归类
+func (r *incomeReportRepository) ListReportLines(ctx context.Context, mentorID, incomeType string, startDate, endDate time.Time) ([]*model.IncomeReportLine, error) {
+	var lines []*model.IncomeReportLine
+
+	query := r.db.WithContext(ctx).
+		Table("income_transactions it").
+		Select(`
+			it.id, it.transaction_type, src.transaction_type AS category,
+			CASE src.transaction_type
+				WHEN 'course_enrollment' THEN src.course_id
+				WHEN 'appointment' THEN src.appointment_id
+				ELSE po.order_ref_id
+			END AS item_id,
+			CASE src.transaction_type
+				WHEN 'course_enrollment' THEN c.title
+				WHEN 'appointment' THEN '咨询预约 ' || TO_CHAR(a.appointment_time, 'YYYY-MM-DD HH24:MI')
+				ELSE pp.title
+			END AS item_name,
+			u.email AS student_name,
+			it.amount, it.platform_fee, it.net_income, it.status, it.created_at
+		`).
+		Joins("JOIN income_transactions src ON src.id = COALESCE(it.source_transaction_id, it.id)").
+		Joins("LEFT JOIN courses c ON c.id = src.course_id").
+		Joins("LEFT JOIN appointments a ON a.id = src.appointment_id").
+		Joins("LEFT JOIN payment_orders po ON po.id = src.payment_order_id AND po.order_type = 'appointment_package'").
+		Joins("LEFT JOIN appointment_package_purchases pp ON pp.id = po.order_ref_id").
+		Joins("LEFT JOIN users u ON u.id = it.student_id").
+		Where("it.mentor_id = ? AND it.status <> 'failed'", mentorID).
+		Where("it.created_at >= ? AND it.created_at < ?", startDate, endDate)
+
+	if incomeType != "" {
+		query = query.Where("src.transaction_type = ?", incomeType)
+	}
+
+	err := query.Order("it.created_at ASC, it.id ASC").Find(&lines).Error
+	return lines, err
+}
+
+// ListReportWithdrawals 获取区间内申请的提现记录，endDate 不含；不读取加密的银行账号
+func (r *incomeReportRepository) ListReportWithdrawals(ctx context.Context, mentorID string, startDate, endDate time.Time) ([]*model.WithdrawalModel, error) {
+	var withdrawals []*model.WithdrawalModel
+	err := r.db.WithContext(ctx).
+		Omit("bank_account").
+		Where("mentor_id = ? AND created_at >= ? AND created_at < ?", mentorID, startDate, endDate).
+		Order("created_at ASC").
+		Find(&withdrawals).Error
+	return withdrawals, err
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"time"
 
+	"master-guide-backend/internal/ledger"
 	"master-guide-backend/internal/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // IncomeRepository 收入数据访问接口
@@ -17,6 +19,8 @@ type IncomeRepository interface {
 	GetWithdrawals(ctx context.Context, mentorID, status string, startDate, endDate time.Time, page, pageSize int) ([]*model.Withdrawal, int64, error)
 	CreateWithdrawal(ctx context.Context, withdrawal *model.WithdrawalModel) error
 	GetAvailableIncome(ctx context.Context, mentorID string) (*model.AvailableIncome, error)
+	ListSettleableIncomeIDs(ctx context.Context, completedBefore time.Time, courseCompletionDays, limit int) ([]string, error)
+	SettleIncome(ctx context.Context, incomeID string, at time.Time) (bool, error)
 }
 
 // incomeRepository 收入数据访问实现
@@ -178,7 +182,8 @@ func (r *incomeRepository) GetIncomeTransactions(ctx context.Context, mentorID,
 		Select(`
 			it.id, it.transaction_type as type, it.amount, it.status, it.description,
 			u.email as student_name, c.title as course_title,
-			it.created_at, it.completed_at, it.platform_fee, it.net_income
+			it.created_at, it.completed_at, it.platform_fee, it.net_income, it.fee_rule_id,
+			it.settlement_status, it.settled_at
 		`).
 		Joins("LEFT JOIN users u ON it.student_id = u.id").
 		Joins("LEFT JOIN courses c ON it.course_id = c.id")
@@ -261,7 +266,8 @@ func (r *incomeRepository) GetWithdrawals(ctx context.Context, mentorID, status
 	query := r.db.WithContext(ctx).
 		Table("withdrawals w").
 		Select(`
-			w.id, w.amount, w.status, w.bank_account, w.created_at, w.completed_at, w.fee, w.net_amount
+			w.id, w.amount, w.status, w.bank_account_masked AS bank_account, w.bank_name, w.created_at, w.completed_at, w.fee, w.net_amount, w.fee_rule_id,
+			w.reviewed_at, w.reject_reason, w.failure_reason
 		`)
 
 	if mentorID != "" {
@@ -288,47 +294,69 @@ func (r *incomeRepository) GetWithdrawals(ctx context.Context, mentorID, status
 	return withdrawals, total, err
 }
 
-// CreateWithdrawal 创建提现申请
+// CreateWithdrawal 创建提现申请，并将提现金额从大师应付款转入提现在途
+// 以大师应付款账户行锁串行化同一大师的提现，余额不足时返回 ErrInsufficientBalance
 func (r *incomeRepository) CreateWithdrawal(ctx context.Context, withdrawal *model.WithdrawalModel) error {
-	return r.db.WithContext(ctx).Create(withdrawal).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		payable := ledger.MentorPayable(withdrawal.MentorID)
+		if err := lockLedgerAccount(tx, payable, ledger.DefaultCurrency); err != nil {
+			return err
+		}
+		balance, err := ledgerBalance(tx, payable)
+		if err != nil {
+			return err
+		}
+		amount := ledger.ToMinor(withdrawal.Amount)
+		if amount > -balance {
+			return ErrInsufficientBalance
+		}
+
+		if err := tx.Create(withdrawal).Error; err != nil {
+			return err
+		}
+		_, err = postLedgerTransaction(tx, ledger.WithdrawalRequested(withdrawal.ID, withdrawal.MentorID, amount, withdrawal.CreatedAt))
+		return err
+	})
 }
 
-// GetAvailableIncome 获取可提现金额
+// GetAvailableIncome 由账本汇总可提现金额：大师应付款余额为可提现金额，待结算收入余额为冻结金额，提现在途余额为待处理提现
+// 已结算收入被退款扣回后应付款可能为借方余额，此时可提现金额为0
 func (r *incomeRepository) GetAvailableIncome(ctx context.Context, mentorID string) (*model.AvailableIncome, error) {
-	var available model.AvailableIncome
+	db := r.db.WithContext(ctx)
 
-	// 获取总收入
-	err := r.db.WithContext(ctx).
-		Table("income_transactions").
-		Where("mentor_id = ? AND status = ?", mentorID, "completed").
-		Select("COALESCE(SUM(net_income), 0)").
-		Scan(&available.TotalEarned).Error
+	payable, err := ledgerBalance(db, ledger.MentorPayable(mentorID))
 	if err != nil {
 		return nil, err
 	}
-
-	// 获取已提现总额
-	err = r.db.WithContext(ctx).
-		Table("withdrawals").
-		Where("mentor_id = ? AND status = ?", mentorID, "completed").
-		Select("COALESCE(SUM(amount), 0)").
-		Scan(&available.TotalWithdrawn).Error
+	frozen, err := ledgerBalance(db, ledger.MentorFrozen(mentorID))
 	if err != nil {
 		return nil, err
 	}
-
-	// 获取待处理提现总额
-	err = r.db.WithContext(ctx).
-		Table("withdrawals").
-		Where("mentor_id = ? AND status = ?", mentorID, "pending").
-		Select("COALESCE(SUM(amount), 0)").
-		Scan(&available.PendingAmount).Error
+	inTransit, err := ledgerBalance(db, ledger.MentorWithdrawalsInTransit(mentorID))
+	if err != nil {
+		return nil, err
+	}
+	var earned int64
+	for _, account := range []string{ledger.MentorFrozen(mentorID), ledger.MentorPayable(mentorID)} {
+		total, err := sumLedgerEntries(db, account, ledger.TypeOrderFulfilled, ledger.TypeIncomeReversed)
+		if err != nil {
+			return nil, err
+		}
+		earned += total
+	}
+	withdrawn, err := sumLedgerEntries(db, ledger.MentorWithdrawalsInTransit(mentorID), ledger.TypeWithdrawalPaid)
 	if err != nil {
 		return nil, err
 	}
 
-	// 计算可提现金额
-	available.AvailableAmount = available.TotalEarned - available.TotalWithdrawn - available.PendingAmount
+	// 负债账户贷方余额为负数
+	available := model.AvailableIncome{
+		AvailableAmount: ledger.FromMinor(max(-payable, 0)),
+		FrozenAmount:    ledger.FromMinor(-frozen),
+		PendingAmount:   ledger.FromMinor(-inTransit),
+		TotalEarned:     ledger.FromMinor(-earned),
+		TotalWithdrawn:  ledger.FromMinor(withdrawn),
+	}
 
 	// 设置提现限制
 	available.MinWithdrawal = 100.0
@@ -336,3 +364,80 @@ func (r *incomeRepository) GetAvailableIncome(ctx context.Context, mentorID stri
 
 	return &available, nil
 }
+
+// ListSettleableIncomeIDs 查找可以结算的冻结收入：服务在 completedBefore 之前已完成，或已被全额退款
+// 服务完成时间：预约为完成、爽约或取消的时间，课程为学完时间（未学完时为报名后 courseCompletionDays 天），咨询套餐为到期时间
+func (r *incomeRepository) ListSettleableIncomeIDs(ctx context.Context, completedBefore time.Time, courseCompletionDays, limit int) ([]string, error) {
+	var ids []string
+	err := r.db.WithContext(ctx).
+		Raw(`SELECT i.id FROM income_transactions i
+			WHERE i.settlement_status = ? AND i.transaction_type <> ?
+			AND (
+				i.net_income + COALESCE((SELECT SUM(r.net_income) FROM income_transactions r WHERE r.source_transaction_id = i.id), 0) <= 0
+				OR CASE i.transaction_type
+					WHEN 'appointment' THEN (
+						SELECT MAX(h.created_at) FROM appointment_status_history h
+						WHERE h.appointment_id = i.appointment_id AND h.to_status IN ('completed', 'no_show', 'cancelled'))
+					WHEN 'course_enrollment' THEN (
+						SELECT COALESCE(lr.completed_at, lr.enrolled_at + make_interval(days => ?)) FROM learning_records lr
+						WHERE lr.user_id = i.student_id AND lr.course_id = i.course_id
+						ORDER BY lr.enrolled_at DESC LIMIT 1)
+					WHEN 'appointment_package' THEN (
+						SELECT p.expires_at FROM payment_orders o
+						JOIN appointment_package_purchases p ON p.id = o.order_ref_id
+						WHERE o.id = i.payment_order_id)
+				END <= ?
+			)
+			ORDER BY i.created_at
+			LIMIT ?`,
+			model.IncomeSettlementFrozen, model.IncomeTransactionTypeRefund, courseCompletionDays, completedBefore, limit).
+		Scan(&ids).Error
+	return ids, err
+}
+
+// SettleIncome 结算冻结收入：扣除结算前的退款冲正后，将剩余净收入从待结算收入转为可提现的应付款
+// 原收入行锁与退款冲正串行化；已结算时返回 false
+func (r *incomeRepository) SettleIncome(ctx context.Context, incomeID string, at time.Time) (bool, error) {
+	settled := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var income model.IncomeTransactionModel
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&income, "id = ?", incomeID).Error; err != nil {
+			return err
+		}
+		if income.SettlementStatus != model.IncomeSettlementFrozen {
+			return nil
+		}
+
+		var reversed float64
+		if err := tx.Model(&model.IncomeTransactionModel{}).
+			Where("source_transaction_id = ?", income.ID).
+			Select("COALESCE(SUM(net_income), 0)").
+			Scan(&reversed).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&model.IncomeTransactionModel{}).
+			Where("id = ? OR source_transaction_id = ?", income.ID, income.ID).
+			Updates(map[string]interface{}{
+				"settlement_status": model.IncomeSettlementSettled,
+				"settled_at":        at,
+			}).Error; err != nil {
+			return err
+		}
+
+		amount := ledger.ToMinor(income.NetIncome) + ledger.ToMinor(reversed)
+		if amount > 0 {
+			orderID := ""
+			if income.PaymentOrderID != nil {
+				orderID = *income.PaymentOrderID
+			}
+			if _, err := postLedgerTransaction(tx, ledger.IncomeSettled(income.ID, orderID, income.MentorID, amount, at)); err != nil {
+				return err
+			}
+		}
+		settled = true
+		return nil
+	})
+	return settled, err
+}
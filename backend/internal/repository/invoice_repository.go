@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"master-guide-backend/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvoiceNotFound 票据不存在
+var ErrInvoiceNotFound = errors.New("invoice not found")
+
+// InvoiceRepository 票据数据访问接口
+type InvoiceRepository interface {
+	GetIncomeTransaction(ctx context.Context, id string) (*model.IncomeTransactionModel, error)
+	GetIncomeByPaymentOrder(ctx context.Context, orderID string) (*model.IncomeTransactionModel, error)
+	GetIncomeByRefund(ctx context.Context, refundID string) (*model.IncomeTransactionModel, error)
+	ListUninvoicedIncomeIDs(ctx context.Context, before time.Time, limit int) ([]string, error)
+	GetStudentParty(ctx context.Context, userID string) (*model.InvoiceParty, error)
+	GetMentorParty(ctx context.Context, mentorID string) (*model.InvoiceParty, error)
+	GetInvoiceByIncome(ctx context.Context, incomeID, category string) (*model.Invoice, error)
+	CreateInvoice(ctx context.Context, invoice *model.Invoice, series string) (bool, error)
+	GetInvoice(ctx context.Context, invoiceID string) (*model.Invoice, error)
+	ListInvoices(ctx context.Context, userID string, req *model.InvoiceListRequest) ([]*model.Invoice, int64, error)
+}
+
+// invoiceRepository 票据数据访问实现
+type invoiceRepository struct {
+	db *gorm.DB
+}
+
+// NewInvoiceRepository 创建票据数据访问实例
+func NewInvoiceRepository(db *gorm.DB) InvoiceRepository {
+	return &invoiceRepository{db: db}
+}
+
+// GetIncomeTransaction 根据ID获取收入记录
+func (r *invoiceRepository) GetIncomeTransaction(ctx context.Context, id string) (*model.IncomeTransactionModel, error) {
+	var income model.IncomeTransactionModel
+	if err := r.db.WithContext(ctx).First(&income, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &income, nil
+}
+
+// GetIncomeByPaymentOrder 获取支付订单履约时记录的收入
+func (r *invoiceRepository) GetIncomeByPaymentOrder(ctx context.Context, orderID string) (*model.IncomeTransactionModel, error) {
+	var income model.IncomeTransactionModel
+	if err := r.db.WithContext(ctx).First(&income, "payment_order_id = ?", orderID).Error; err != nil {
+		return nil, err
+	}
+	return &income, nil
+}
+
+// GetIncomeByRefund 获取退款对应的收入冲正记录
+func (r *invoiceRepository) GetIncomeByRefund(ctx context.Context, refundID string) (*model.IncomeTransactionModel, error) {
+	var income model.IncomeTransactionModel
+	if err := r.db.WithContext(ctx).First(&income, "refund_id = ?", refundID).Error; err != nil {
+		return nil, err
+	}
+	return &income, nil
+}
+
+// ListUninvoicedIncomeIDs 获取 before 之前入账、尚未开具服务收据或平台服务费账单的支付收入和退款冲正记录，按入账时间排序使原票据先于红字票据开具
+func (r *invoiceRepository) ListUninvoicedIncomeIDs(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	var ids []string
+	err := r.db.WithContext(ctx).
+		Table("income_transactions it").
+		Where("it.status = 'completed' AND it.created_at < ?", before).
+		Where("it.payment_order_id IS NOT NULL OR it.refund_id IS NOT NULL").
+		Where(`(it.amount <> 0 AND NOT EXISTS (SELECT 1 FROM invoices i WHERE i.income_transaction_id = it.id AND i.category = ?))
+			OR (it.platform_fee <> 0 AND NOT EXISTS (SELECT 1 FROM invoices i WHERE i.income_transaction_id = it.id AND i.category = ?))`,
+			model.InvoiceCategoryService, model.InvoiceCategoryPlatformFee).
+		Order("it.created_at ASC").
+		Limit(limit).
+		Pluck("it.id", &ids).Error
+	return ids, err
+}
+
+// GetStudentParty 获取学生的票据信息，名称取用户最早创建的资料姓名，没有资料时使用邮箱
+func (r *invoiceRepository) GetStudentParty(ctx context.Context, userID string) (*model.InvoiceParty, error) {
+	var party model.InvoiceParty
+	err := r.db.WithContext(ctx).
+		Table("users u").
+		Select(`u.id AS user_id, u.email,
+			COALESCE((SELECT p.name FROM user_profiles p WHERE p.user_id = u.id ORDER BY p.created_at ASC LIMIT 1), u.email) AS name`).
+		Where("u.id = ?", userID).
+		Take(&party).Error
+	if err != nil {
+		return nil, err
+	}
+	return &party, nil
+}
+
+// GetMentorParty 获取大师的票据信息，名称取大师身份的资料姓名
+func (r *invoiceRepository) GetMentorParty(ctx context.Context, mentorID string) (*model.InvoiceParty, error) {
+	var party model.InvoiceParty
+	err := r.db.WithContext(ctx).
+		Table("mentors m").
+		Select("u.id AS user_id, u.email, COALESCE(p.name, u.email) AS name").
+		Joins("JOIN users u ON u.id = m.user_id").
+		Joins("LEFT JOIN user_profiles p ON p.identity_id = m.identity_id").
+		Where("m.id = ?", mentorID).
+		Take(&party).Error
+	if err != nil {
+		return nil, err
+	}
+	return &party, nil
+}
+
+// GetInvoiceByIncome 获取收入记录已开具的指定类别票据
+func (r *invoiceRepository) GetInvoiceByIncome(ctx context.Context, incomeID, category string) (*model.Invoice, error) {
+	var invoice model.Invoice
+	err := r.db.WithContext(ctx).
+		Where("income_transaction_id = ? AND category = ?", incomeID, category).
+		First(&invoice).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvoiceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// CreateInvoice 分配票据编号并保存票据及明细，同一收入记录的同类票据只开具一次，已开具时返回 false
+// 编号按系列和开具年度在计数表行锁下递增，与票据在同一事务中提交，编号连续无断号
+func (r *invoiceRepository) CreateInvoice(ctx context.Context, invoice *model.Invoice, series string) (bool, error) {
+	created := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 以收入记录行锁串行化同一收入的并发开具
+		var income model.IncomeTransactionModel
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").
+			Where("id = ?", invoice.IncomeTransactionID).
+			First(&income).Error; err != nil {
+			return err
+		}
+
+		var existing int64
+		if err := tx.Model(&model.Invoice{}).
+			Where("income_transaction_id = ? AND category = ?", invoice.IncomeTransactionID, invoice.Category).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return nil
+		}
+
+		year := invoice.IssuedAt.Year()
+		var number int64
+		if err := tx.Raw(`
+			INSERT INTO invoice_number_sequences (series, year, last_number) VALUES (?, ?, 1)
+			ON CONFLICT (series, year) DO UPDATE SET last_number = invoice_number_sequences.last_number + 1
+			RETURNING last_number`, series, year).
+			Scan(&number).Error; err != nil {
+			return err
+		}
+		invoice.InvoiceNumber = fmt.Sprintf("%s-%d-%06d", series, year, number)
+
+		if err := tx.Create(invoice).Error; err != nil {
+			return err
+		}
+		created = true
+		return nil
+	})
+	return created, err
+}
+
+// GetInvoice 根据ID获取票据及明细
+func (r *invoiceRepository) GetInvoice(ctx context.Context, invoiceID string) (*model.Invoice, error) {
+	var invoice model.Invoice
+	err := r.db.WithContext(ctx).
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("line_no ASC") }).
+		First(&invoice, "id = ?", invoiceID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvoiceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// ListInvoices 分页获取用户作为购买方或销售方的票据，按开具时间倒序；EndDate 当天包含在内
+func (r *invoiceRepository) ListInvoices(ctx context.Context, userID string, req *model.InvoiceListRequest) ([]*model.Invoice, int64, error) {
+	query := r.db.WithContext(ctx).Model(&model.Invoice{})
+	switch req.Role {
+	case "buyer":
+		query = query.Where("buyer_user_id = ?", userID)
+	case "seller":
+		query = query.Where("seller_user_id = ?", userID)
+	default:
+		query = query.Where("buyer_user_id = ? OR seller_user_id = ?", userID, userID)
+	}
+	if req.DocumentType != "" {
+		query = query.Where("document_type = ?", req.DocumentType)
+	}
+	if req.Category != "" {
+		query = query.Where("category = ?", req.Category)
+	}
+	if !req.StartDate.IsZero() {
+		query = query.Where("issued_at >= ?", req.StartDate)
+	}
+	if !req.EndDate.IsZero() {
+		query = query.Where("issued_at < ?", req.EndDate.AddDate(0, 0, 1))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var invoices []*model.Invoice
+	err := query.
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("line_no ASC") }).
+		Order("issued_at DESC, invoice_number DESC").
+		Offset((req.Page - 1) * req.PageSize).
+		Limit(req.PageSize).
+		Find(&invoices).Error
+	return invoices, total, err
+}
@@ -0,0 +1,286 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"master-guide-backend/internal/ledger"
+	"master-guide-backend/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInsufficientBalance 大师应付款余额不足
+var ErrInsufficientBalance = errors.New("insufficient balance")
+
+// LedgerRepository 复式记账数据访问接口
+// 记账凭证按幂等键只写入一次，余额均由分录汇总得出
+type LedgerRepository interface {
+	Post(ctx context.Context, txn *ledger.Transaction) (bool, error)
+	GetBalance(ctx context.Context, code string) (int64, error)
+	ListAccountBalances(ctx context.Context) ([]*model.LedgerAccountBalance, error)
+	ListUnbalancedTransactions(ctx context.Context) ([]string, error)
+	ListReconciliationItems(ctx context.Context) ([]*model.LedgerReconciliationItem, error)
+}
+
+// ledgerRepository 复式记账数据访问实现
+type ledgerRepository struct {
+	db *gorm.DB
+}
+
+// NewLedgerRepository 创建复式记账数据访问实例
+func NewLedgerRepository(db *gorm.DB) LedgerRepository {
+	return &ledgerRepository{db: db}
+}
+
+// Post 在独立事务中写入记账凭证，已记账时返回 false
+func (r *ledgerRepository) Post(ctx context.Context, txn *ledger.Transaction) (bool, error) {
+	posted := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		posted, err = postLedgerTransaction(tx, txn)
+		return err
+	})
+	return posted, err
+}
+
+// GetBalance 获取账户余额（借记为正），账户不存在时为0
+func (r *ledgerRepository) GetBalance(ctx context.Context, code string) (int64, error) {
+	return ledgerBalance(r.db.WithContext(ctx), code)
+}
+
+// ListAccountBalances 汇总全部账户的借方、贷方发生额和余额
+func (r *ledgerRepository) ListAccountBalances(ctx context.Context) ([]*model.LedgerAccountBalance, error) {
+	var balances []*model.LedgerAccountBalance
+	err := r.db.WithContext(ctx).
+		Table("ledger_accounts a").
+		Joins("LEFT JOIN ledger_entries e ON e.account_id = a.id").
+		Select(`a.code, a.name, a.account_type, a.normal_balance, a.owner_type, COALESCE(a.owner_id, '') AS owner_id,
+			COALESCE(SUM(CASE WHEN e.amount > 0 THEN e.amount ELSE 0 END), 0) AS debit,
+			COALESCE(-SUM(CASE WHEN e.amount < 0 THEN e.amount ELSE 0 END), 0) AS credit,
+			COALESCE(SUM(e.amount), 0) AS balance`).
+		Group("a.id").
+		Order("a.owner_type DESC, a.code").
+		Scan(&balances).Error
+	return balances, err
+}
+
+// ListUnbalancedTransactions 查找借贷不平衡的记账凭证
+func (r *ledgerRepository) ListUnbalancedTransactions(ctx context.Context) ([]string, error) {
+	var ids []string
+	err := r.db.WithContext(ctx).
+		Table("ledger_transactions t").
+		Joins("LEFT JOIN ledger_entries e ON e.transaction_id = t.id").
+		Group("t.id").
+		Having("COALESCE(SUM(e.amount), 0) <> 0 OR COUNT(e.id) < 2").
+		Pluck("t.id", &ids).Error
+	return ids, err
+}
+
+// ledgerReconciliationCheck 业务表合计与账本分录合计的核对规则，sign 将账本合计换算为与业务表同向的金额
+type ledgerReconciliationCheck struct {
+	name    string
+	source  string
+	account string
+	types   []string
+	sign    int64
+}
+
+// ledgerReconciliationChecks 支付、退款、大师收入、待结算收入、平台服务费和提现的核对规则
+var ledgerReconciliationChecks = []ledgerReconciliationCheck{
+	{
+		name:    "支付完成金额",
+		source:  "SELECT CAST(COALESCE(ROUND(SUM(amount) * 100), 0) AS BIGINT) FROM payment_records WHERE status = 'completed'",
+		account: ledger.AccountGatewayClearing,
+		types:   []string{ledger.TypePaymentReceived},
+		sign:    1,
+	},
+	{
+		name:    "退款完成金额",
+		source:  "SELECT CAST(COALESCE(ROUND(SUM(amount) * 100), 0) AS BIGINT) FROM payment_refunds WHERE status = 'completed'",
+		account: ledger.AccountGatewayClearing,
+		types:   []string{ledger.TypeRefundPaid},
+		sign:    -1,
+	},
+	{
+		name:    "大师净收入",
+		source:  "SELECT CAST(COALESCE(ROUND(SUM(net_income) * 100), 0) AS BIGINT) FROM income_transactions WHERE status = 'completed'",
+		account: "mentor:%", // 待结算收入和应付款
+		types:   []string{ledger.TypeOrderFulfilled, ledger.TypeIncomeReversed},
+		sign:    -1,
+	},
+	{
+		name:    "待结算收入",
+		source:  "SELECT CAST(COALESCE(ROUND(SUM(net_income) * 100), 0) AS BIGINT) FROM income_transactions WHERE status = 'completed' AND settlement_status = 'frozen'",
+		account: ledger.MentorFrozen("%"),
+		types:   []string{ledger.TypeOrderFulfilled, ledger.TypeIncomeReversed, ledger.TypeIncomeSettled},
+		sign:    -1,
+	},
+	{
+		name:    "平台服务费",
+		source:  "SELECT CAST(COALESCE(ROUND(SUM(platform_fee) * 100), 0) AS BIGINT) FROM income_transactions WHERE status = 'completed'",
+		account: ledger.AccountPlatformFees,
+		types:   []string{ledger.TypeOrderFulfilled, ledger.TypeIncomeReversed},
+		sign:    -1,
+	},
+	{
+		name:    "提现在途金额",
+		source:  "SELECT CAST(COALESCE(ROUND(SUM(amount) * 100), 0) AS BIGINT) FROM withdrawals WHERE status IN ('pending', 'approved', 'processing')",
+		account: ledger.MentorWithdrawalsInTransit("%"),
+		types:   []string{ledger.TypeWithdrawalRequested, ledger.TypeWithdrawalPaid, ledger.TypeWithdrawalFailed},
+		sign:    -1,
+	},
+	{
+		name:    "已完成提现金额",
+		source:  "SELECT CAST(COALESCE(ROUND(SUM(amount) * 100), 0) AS BIGINT) FROM withdrawals WHERE status = 'completed'",
+		account: ledger.MentorWithdrawalsInTransit("%"),
+		types:   []string{ledger.TypeWithdrawalPaid},
+		sign:    1,
+	},
+}
+
+// ListReconciliationItems 逐项核对业务表合计与账本分录合计
+func (r *ledgerRepository) ListReconciliationItems(ctx context.Context) ([]*model.LedgerReconciliationItem, error) {
+	db := r.db.WithContext(ctx)
+	items := make([]*model.LedgerReconciliationItem, 0, len(ledgerReconciliationChecks))
+	for _, check := range ledgerReconciliationChecks {
+		var source int64
+		if err := db.Raw(check.source).Scan(&source).Error; err != nil {
+			return nil, err
+		}
+		total, err := sumLedgerEntries(db, check.account, check.types...)
+		if err != nil {
+			return nil, err
+		}
+		total *= check.sign
+		items = append(items, &model.LedgerReconciliationItem{
+			Name:       check.name,
+			Source:     source,
+			Ledger:     total,
+			Difference: total - source,
+			Matched:    total == source,
+		})
+	}
+	return items, nil
+}
+
+// postLedgerTransaction 在调用方事务中写入记账凭证和分录，账户不存在时自动开户
+// 同一幂等键已记账或金额为0时返回 false；借贷平衡同时由数据库约束触发器在提交时校验
+func postLedgerTransaction(tx *gorm.DB, txn *ledger.Transaction) (bool, error) {
+	if txn.IsZero() {
+		return false, nil
+	}
+	if err := txn.Validate(); err != nil {
+		return false, err
+	}
+
+	record := &model.LedgerTransaction{
+		IdempotencyKey:  txn.IdempotencyKey,
+		TransactionType: txn.Type,
+		Description:     txn.Description,
+		Currency:        txn.Currency,
+		PaymentOrderID:  optionalString(txn.PaymentOrderID),
+		RefundID:        optionalString(txn.RefundID),
+		WithdrawalID:    optionalString(txn.WithdrawalID),
+		MentorID:        optionalString(txn.MentorID),
+		OccurredAt:      txn.OccurredAt,
+	}
+	result := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "idempotency_key"}},
+		DoNothing: true,
+	}).Create(record)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+
+	entries := make([]*model.LedgerEntry, 0, len(txn.Postings))
+	for _, posting := range txn.Postings {
+		accountID, err := ledgerAccountID(tx, posting.Account, txn.Currency)
+		if err != nil {
+			return false, err
+		}
+		entries = append(entries, &model.LedgerEntry{
+			TransactionID: record.ID,
+			AccountID:     accountID,
+			Amount:        posting.Amount,
+		})
+	}
+	if err := tx.Create(&entries).Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ledgerAccountID 获取账户ID，账户不存在时按编码创建
+func ledgerAccountID(tx *gorm.DB, code, currency string) (string, error) {
+	spec, err := ledger.LookupAccount(code)
+	if err != nil {
+		return "", err
+	}
+	account := &model.LedgerAccount{
+		Code:          spec.Code,
+		Name:          spec.Name,
+		AccountType:   spec.AccountType,
+		NormalBalance: spec.NormalBalance,
+		OwnerType:     spec.OwnerType,
+		OwnerID:       optionalString(spec.OwnerID),
+		Currency:      currency,
+	}
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "code"}},
+		DoNothing: true,
+	}).Create(account).Error; err != nil {
+		return "", err
+	}
+	if account.ID != "" {
+		return account.ID, nil
+	}
+
+	var id string
+	err = tx.Model(&model.LedgerAccount{}).Where("code = ?", code).Pluck("id", &id).Error
+	return id, err
+}
+
+// lockLedgerAccount 锁定账户行，串行化同一账户上依赖余额判断的记账
+func lockLedgerAccount(tx *gorm.DB, code, currency string) error {
+	accountID, err := ledgerAccountID(tx, code, currency)
+	if err != nil {
+		return err
+	}
+	var account model.LedgerAccount
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").
+		First(&account, "id = ?", accountID).Error
+}
+
+// ledgerBalance 汇总账户余额（借记为正），账户不存在时为0
+func ledgerBalance(db *gorm.DB, code string) (int64, error) {
+	var balance int64
+	err := db.Table("ledger_entries e").
+		Joins("JOIN ledger_accounts a ON a.id = e.account_id").
+		Where("a.code = ?", code).
+		Select("COALESCE(SUM(e.amount), 0)").
+		Scan(&balance).Error
+	return balance, err
+}
+
+// sumLedgerEntries 汇总指定类型凭证在账户上的分录金额，account 可使用 LIKE 通配符匹配多个账户
+func sumLedgerEntries(db *gorm.DB, account string, transactionTypes ...string) (int64, error) {
+	var total int64
+	err := db.Table("ledger_entries e").
+		Joins("JOIN ledger_accounts a ON a.id = e.account_id").
+		Joins("JOIN ledger_transactions t ON t.id = e.transaction_id").
+		Where("a.code LIKE ? AND t.transaction_type IN ?", account, transactionTypes).
+		Select("COALESCE(SUM(e.amount), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
+// optionalString 空字符串转换为 nil
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
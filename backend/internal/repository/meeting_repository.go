@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"master-guide-backend/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MeetingRepository 会议室数据访问接口
+type MeetingRepository interface {
+	CreateRoom(ctx context.Context, room *model.MeetingRoom) (bool, error)
+	GetRoomByAppointmentID(ctx context.Context, appointmentID string) (*model.MeetingRoom, error)
+	GetRoomByProviderRoomID(ctx context.Context, provider, providerRoomID string) (*model.MeetingRoom, error)
+	CloseRoom(ctx context.Context, appointmentID string) (*model.MeetingRoom, error)
+	DeleteRoom(ctx context.Context, appointmentID string) (*model.MeetingRoom, error)
+	ListAppointmentsWithoutRoom(ctx context.Context, now time.Time, limit int) ([]*model.AppointmentModel, error)
+}
+
+// meetingRepository 会议室数据访问实现
+type meetingRepository struct {
+	db *gorm.DB
+}
+
+// NewMeetingRepository 创建会议室数据访问实例
+func NewMeetingRepository(db *gorm.DB) MeetingRepository {
+	return &meetingRepository{db: db}
+}
+
+// CreateRoom 保存会议室和参与方，预约已有会议室时返回 false
+func (r *meetingRepository) CreateRoom(ctx context.Context, room *model.MeetingRoom) (bool, error) {
+	created := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Omit("Participants").
+			Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "appointment_id"}},
+				DoNothing: true,
+			}).
+			Create(room)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		for _, participant := range room.Participants {
+			participant.RoomID = room.ID
+		}
+		if len(room.Participants) > 0 {
+			if err := tx.Create(&room.Participants).Error; err != nil {
+				return err
+			}
+		}
+		created = true
+		return nil
+	})
+	return created, err
+}
+
+// GetRoomByAppointmentID 获取预约的会议室及参与方
+func (r *meetingRepository) GetRoomByAppointmentID(ctx context.Context, appointmentID string) (*model.MeetingRoom, error) {
+	var room model.MeetingRoom
+	err := r.db.WithContext(ctx).
+		Preload("Participants").
+		Where("appointment_id = ?", appointmentID).
+		First(&room).Error
+	if err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
+// GetRoomByProviderRoomID 根据会议服务侧的会议室ID获取会议室
+func (r *meetingRepository) GetRoomByProviderRoomID(ctx context.Context, provider, providerRoomID string) (*model.MeetingRoom, error) {
+	var room model.MeetingRoom
+	err := r.db.WithContext(ctx).
+		Where("provider = ? AND provider_room_id = ?", provider, providerRoomID).
+		First(&room).Error
+	if err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
+// CloseRoom 关闭预约的会议室，返回被关闭的会议室；没有进行中的会议室时返回 nil
+func (r *meetingRepository) CloseRoom(ctx context.Context, appointmentID string) (*model.MeetingRoom, error) {
+	var rooms []*model.MeetingRoom
+	err := r.db.WithContext(ctx).
+		Model(&rooms).
+		Clauses(clause.Returning{}).
+		Where("appointment_id = ? AND status = ?", appointmentID, model.MeetingRoomStatusActive).
+		Update("status", model.MeetingRoomStatusClosed).Error
+	if err != nil || len(rooms) == 0 {
+		return nil, err
+	}
+	return rooms[0], nil
+}
+
+// DeleteRoom 删除预约的会议室及参与方，返回被删除的会议室；没有会议室时返回 nil
+func (r *meetingRepository) DeleteRoom(ctx context.Context, appointmentID string) (*model.MeetingRoom, error) {
+	var rooms []*model.MeetingRoom
+	err := r.db.WithContext(ctx).
+		Clauses(clause.Returning{}).
+		Where("appointment_id = ?", appointmentID).
+		Delete(&rooms).Error
+	if err != nil || len(rooms) == 0 {
+		return nil, err
+	}
+	return rooms[0], nil
+}
+
+// ListAppointmentsWithoutRoom 获取已确认、尚未结束但还没有会议室的视频咨询预约
+func (r *meetingRepository) ListAppointmentsWithoutRoom(ctx context.Context, now time.Time, limit int) ([]*model.AppointmentModel, error) {
+	var appointments []*model.AppointmentModel
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND meeting_type = ?", model.AppointmentStatusConfirmed, model.MeetingTypeVideo).
+		Where("appointment_time + duration_minutes * INTERVAL '1 minute' > ?", now).
+		Where("NOT EXISTS (SELECT 1 FROM meeting_rooms mr WHERE mr.appointment_id = appointments.id)").
+		Order("appointment_time ASC").
+		Limit(limit).
+		Find(&appointments).Error
+	return appointments, err
+}
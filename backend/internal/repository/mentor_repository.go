@@ -12,6 +12,7 @@ import (
 type MentorRepository interface {
 	GetMentors(ctx context.Context, domain string, minRating, maxPrice float64, isOnline *bool, page, pageSize int) ([]*model.Mentor, int64, error)
 	GetMentorByID(ctx context.Context, mentorID string) (*model.Mentor, error)
+	GetMentorByIdentityID(ctx context.Context, identityID string) (*model.Mentor, error)
 	SearchMentors(ctx context.Context, query, domain string, minRating, maxPrice float64, isOnline *bool, page, pageSize int) ([]*model.Mentor, int64, error)
 	GetRecommendedMentors(ctx context.Context, userID string, limit int) ([]*model.Mentor, error)
 	GetMentorReviews(ctx context.Context, mentorID string, page, pageSize int) ([]*model.MentorReviewModel, int64, error)
@@ -83,6 +84,16 @@ func (r *mentorRepository) GetMentorByID(ctx context.Context, mentorID string) (
 	return &mentor, nil
 }
 
+// GetMentorByIdentityID 根据身份ID获取大师档案
+func (r *mentorRepository) GetMentorByIdentityID(ctx context.Context, identityID string) (*model.Mentor, error) {
+	var mentor model.Mentor
+	err := r.db.WithContext(ctx).Where("identity_id = ?", identityID).First(&mentor).Error
+	if err != nil {
+		return nil, err
+	}
+	return &mentor, nil
+}
+
 // SearchMentors 搜索大师
 func (r *mentorRepository) SearchMentors(ctx context.Context, query, domain string, minRating, maxPrice float64, isOnline *bool, page, pageSize int) ([]*model.Mentor, int64, error) {
 	var mentors []*model.Mentor
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"master-guide-backend/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrPasswordResetTokenInvalid 密码重置令牌不存在、已使用或已过期
+var ErrPasswordResetTokenInvalid = errors.New("password reset token invalid")
+
+// PasswordResetRepository 密码重置令牌数据访问接口
+type PasswordResetRepository interface {
+	CreateToken(ctx context.Context, token *model.PasswordResetToken) error
+	ConsumeToken(ctx context.Context, tokenHash string) (*model.PasswordResetToken, error)
+}
+
+// passwordResetRepository 密码重置令牌数据访问实现
+type passwordResetRepository struct {
+	db *gorm.DB
+}
+
+// NewPasswordResetRepository 创建密码重置令牌数据访问实例
+func NewPasswordResetRepository(db *gorm.DB) PasswordResetRepository {
+	return &passwordResetRepository{db: db}
+}
+
+// CreateToken 创建重置令牌，同时作废该用户此前未使用的令牌
+func (r *passwordResetRepository) CreateToken(ctx context.Context, token *model.PasswordResetToken) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", token.UserID).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+}
+
+// ConsumeToken 校验并消费重置令牌，令牌只能成功使用一次
+func (r *passwordResetRepository) ConsumeToken(ctx context.Context, tokenHash string) (*model.PasswordResetToken, error) {
+	var token model.PasswordResetToken
+	now := time.Now()
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", tokenHash).
+			First(&token).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrPasswordResetTokenInvalid
+		}
+		if err != nil {
+			return err
+		}
+
+		if token.UsedAt != nil || !token.ExpiresAt.After(now) {
+			return ErrPasswordResetTokenInvalid
+		}
+
+		token.UsedAt = &now
+		return tx.Model(&model.PasswordResetToken{}).
+			Where("id = ?", token.ID).
+			Update("used_at", now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
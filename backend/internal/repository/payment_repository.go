@@ -4,9 +4,11 @@ import (
 	"context"
 	"time"
 
+	"master-guide-backend/internal/ledger"
 	"master-guide-backend/internal/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // PaymentRepository 支付数据访问接口
@@ -15,6 +17,9 @@ type PaymentRepository interface {
 	GetOrderByID(ctx context.Context, id string) (*model.PaymentOrder, error)
 	GetOrderByRef(ctx context.Context, orderType, orderRefID string) (*model.PaymentOrder, error)
 	UpdateOrderStatus(ctx context.Context, id, status string) error
+	DeleteOrder(ctx context.Context, id string) error
+	ListExpiredOrders(ctx context.Context, before time.Time, limit int) ([]*model.PaymentOrder, error)
+	ListUnfulfilledOrders(ctx context.Context, before time.Time, limit int) ([]*model.PaymentOrder, error)
 
 	CreatePaymentRecord(ctx context.Context, record *model.PaymentRecord) error
 	GetPaymentRecordByID(ctx context.Context, id string) (*model.PaymentRecord, error)
@@ -25,11 +30,32 @@ type PaymentRepository interface {
 
 	CreateRefund(ctx context.Context, refund *model.PaymentRefund) error
 	GetRefundByID(ctx context.Context, id string) (*model.PaymentRefund, error)
-	GetRefundByPaymentID(ctx context.Context, paymentID string) (*model.PaymentRefund, error)
+	SumRefundAmount(ctx context.Context, paymentID string) (float64, error)
+	ListUnreversedRefunds(ctx context.Context, before time.Time, limit int) ([]*model.PaymentRefund, error)
+	ListRetryableRefunds(ctx context.Context, now time.Time, limit int) ([]*model.PaymentRefund, error)
+	UpdateRefundStatus(ctx context.Context, id, status string, completedAt *time.Time, refundTransactionID string) error
+	MarkRefundFailed(ctx context.Context, id string, nextRetryAt *time.Time) error
+
+	// PostLedger 写入记账凭证，同一幂等键只记账一次，应在 WithTx 中与状态变更一起提交
+	PostLedger(ctx context.Context, txn *ledger.Transaction) error
 
 	ListPaymentMethods(ctx context.Context) ([]*model.PaymentMethod, error)
+	GetPaymentMethodByID(ctx context.Context, id string) (*model.PaymentMethod, error)
 
 	GetPaymentStats(ctx context.Context, req *model.PaymentStatsRequest) (*model.PaymentStats, error)
+
+	// WithTx 在事务中执行，fn 中使用传入的仓储实例完成所有读写
+	WithTx(ctx context.Context, fn func(repo PaymentRepository) error) error
+	// 以下加锁读取仅在 WithTx 中使用
+	GetOrderForUpdate(ctx context.Context, id string) (*model.PaymentOrder, error)
+	GetPaymentRecordByOrderIDForUpdate(ctx context.Context, orderID string) (*model.PaymentRecord, error)
+	GetRefundForUpdate(ctx context.Context, id string) (*model.PaymentRefund, error)
+
+	SaveWebhookEvent(ctx context.Context, event *model.PaymentWebhookEvent) (bool, error)
+	GetWebhookEventByID(ctx context.Context, id string) (*model.PaymentWebhookEvent, error)
+	GetWebhookEventForUpdate(ctx context.Context, id string) (*model.PaymentWebhookEvent, error)
+	UpdateWebhookEventStatus(ctx context.Context, id, status, lastError string) error
+	ListWebhookEvents(ctx context.Context, req *model.ListPaymentWebhookEventsRequest) ([]*model.PaymentWebhookEvent, int64, error)
 }
 
 type paymentRepository struct {
@@ -66,6 +92,35 @@ func (r *paymentRepository) UpdateOrderStatus(ctx context.Context, id, status st
 	return r.db.WithContext(ctx).Model(&model.PaymentOrder{}).Where("id = ?", id).Update("status", status).Error
 }
 
+func (r *paymentRepository) DeleteOrder(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Delete(&model.PaymentOrder{}, "id = ?", id).Error
+}
+
+// ListExpiredOrders 获取已过有效期仍待支付的订单
+func (r *paymentRepository) ListExpiredOrders(ctx context.Context, before time.Time, limit int) ([]*model.PaymentOrder, error) {
+	var orders []*model.PaymentOrder
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND expires_at IS NOT NULL AND expires_at < ?", "pending", before).
+		Order("expires_at ASC").
+		Limit(limit).
+		Find(&orders).Error
+	return orders, err
+}
+
+// ListUnfulfilledOrders 获取已支付但尚未履约的订单，包括无法履约且支付金额尚未全部退回的订单
+func (r *paymentRepository) ListUnfulfilledOrders(ctx context.Context, before time.Time, limit int) ([]*model.PaymentOrder, error) {
+	var orders []*model.PaymentOrder
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND fulfilled_at IS NULL AND order_type IN ? AND updated_at < ?", "completed",
+			[]string{model.PaymentOrderTypeCourseEnrollment, model.PaymentOrderTypeAppointment, model.PaymentOrderTypeAppointmentPackage}, before).
+		Where("fulfillment_failed_at IS NULL OR amount > (SELECT COALESCE(SUM(rf.amount), 0) FROM payment_refunds rf JOIN payment_records pr ON pr.id = rf.payment_id WHERE pr.order_id = payment_orders.id AND "+refundReservedCondition+")",
+			[]string{"pending", "completed"}, "failed").
+		Order("updated_at ASC").
+		Limit(limit).
+		Find(&orders).Error
+	return orders, err
+}
+
 func (r *paymentRepository) CreatePaymentRecord(ctx context.Context, record *model.PaymentRecord) error {
 	return r.db.WithContext(ctx).Create(record).Error
 }
@@ -136,13 +191,65 @@ func (r *paymentRepository) GetRefundByID(ctx context.Context, id string) (*mode
 	return &refund, nil
 }
 
-func (r *paymentRepository) GetRefundByPaymentID(ctx context.Context, paymentID string) (*model.PaymentRefund, error) {
-	var refund model.PaymentRefund
-	err := r.db.WithContext(ctx).First(&refund, "payment_id = ?", paymentID).Error
-	if err != nil {
-		return nil, err
-	}
-	return &refund, nil
+// refundReservedCondition 占用可退金额的退款：处理中、已完成，以及申请失败但仍会自动重试的退款
+const refundReservedCondition = "(rf.status IN ? OR (rf.status = ? AND rf.next_retry_at IS NOT NULL))"
+
+// SumRefundAmount 统计支付记录已申请的退款金额，包括处理中、已完成和等待自动重试的退款
+func (r *paymentRepository) SumRefundAmount(ctx context.Context, paymentID string) (float64, error) {
+	var total float64
+	err := r.db.WithContext(ctx).Table("payment_refunds rf").
+		Where("rf.payment_id = ? AND "+refundReservedCondition, paymentID, []string{"pending", "completed"}, "failed").
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
+// ListUnreversedRefunds 获取已完成但尚未冲正大师收入的退款，仅包括已履约的订单
+func (r *paymentRepository) ListUnreversedRefunds(ctx context.Context, before time.Time, limit int) ([]*model.PaymentRefund, error) {
+	var refunds []*model.PaymentRefund
+	err := r.db.WithContext(ctx).Table("payment_refunds rf").
+		Select("rf.*").
+		Joins("JOIN payment_records pr ON pr.id = rf.payment_id").
+		Joins("JOIN payment_orders po ON po.id = pr.order_id").
+		Where("rf.status = ? AND rf.completed_at < ? AND po.fulfilled_at IS NOT NULL", "completed", before).
+		Where("NOT EXISTS (SELECT 1 FROM income_transactions it WHERE it.refund_id = rf.id)").
+		Order("rf.completed_at ASC").
+		Limit(limit).
+		Find(&refunds).Error
+	return refunds, err
+}
+
+// ListRetryableRefunds 获取申请失败且已到重试时间的自动退款
+func (r *paymentRepository) ListRetryableRefunds(ctx context.Context, now time.Time, limit int) ([]*model.PaymentRefund, error) {
+	var refunds []*model.PaymentRefund
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND next_retry_at IS NOT NULL AND next_retry_at <= ?", "failed", now).
+		Order("next_retry_at ASC").
+		Limit(limit).
+		Find(&refunds).Error
+	return refunds, err
+}
+
+func (r *paymentRepository) UpdateRefundStatus(ctx context.Context, id, status string, completedAt *time.Time, refundTransactionID string) error {
+	return r.db.WithContext(ctx).Model(&model.PaymentRefund{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":                status,
+		"completed_at":          completedAt,
+		"refund_transaction_id": refundTransactionID,
+	}).Error
+}
+
+// MarkRefundFailed 记录退款申请失败并累计失败次数，nextRetryAt 为空表示不再自动重试
+func (r *paymentRepository) MarkRefundFailed(ctx context.Context, id string, nextRetryAt *time.Time) error {
+	return r.db.WithContext(ctx).Model(&model.PaymentRefund{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":        "failed",
+		"attempts":      gorm.Expr("attempts + 1"),
+		"next_retry_at": nextRetryAt,
+	}).Error
+}
+
+func (r *paymentRepository) PostLedger(ctx context.Context, txn *ledger.Transaction) error {
+	_, err := postLedgerTransaction(r.db.WithContext(ctx), txn)
+	return err
 }
 
 func (r *paymentRepository) ListPaymentMethods(ctx context.Context) ([]*model.PaymentMethod, error) {
@@ -151,7 +258,127 @@ func (r *paymentRepository) ListPaymentMethods(ctx context.Context) ([]*model.Pa
 	return methods, err
 }
 
+func (r *paymentRepository) GetPaymentMethodByID(ctx context.Context, id string) (*model.PaymentMethod, error) {
+	var method model.PaymentMethod
+	err := r.db.WithContext(ctx).First(&method, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &method, nil
+}
+
 func (r *paymentRepository) GetPaymentStats(ctx context.Context, req *model.PaymentStatsRequest) (*model.PaymentStats, error) {
 	// 统计实现略，返回空结构体，后续可补充
 	return &model.PaymentStats{}, nil
 }
+
+func (r *paymentRepository) WithTx(ctx context.Context, fn func(repo PaymentRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&paymentRepository{db: tx})
+	})
+}
+
+func (r *paymentRepository) GetOrderForUpdate(ctx context.Context, id string) (*model.PaymentOrder, error) {
+	var order model.PaymentOrder
+	err := r.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (r *paymentRepository) GetPaymentRecordByOrderIDForUpdate(ctx context.Context, orderID string) (*model.PaymentRecord, error) {
+	var record model.PaymentRecord
+	err := r.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).First(&record, "order_id = ?", orderID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (r *paymentRepository) GetRefundForUpdate(ctx context.Context, id string) (*model.PaymentRefund, error) {
+	var refund model.PaymentRefund
+	err := r.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).First(&refund, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// SaveWebhookEvent 写入回调收件箱，按 gateway+dedupe_key 去重
+// 返回 true 表示新写入；已存在时返回 false，并将已有记录回填到 event
+func (r *paymentRepository) SaveWebhookEvent(ctx context.Context, event *model.PaymentWebhookEvent) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "gateway"}, {Name: "dedupe_key"}},
+			DoNothing: true,
+		}).
+		Create(event)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	err := r.db.WithContext(ctx).First(event, "gateway = ? AND dedupe_key = ?", event.Gateway, event.DedupeKey).Error
+	return false, err
+}
+
+func (r *paymentRepository) GetWebhookEventByID(ctx context.Context, id string) (*model.PaymentWebhookEvent, error) {
+	var event model.PaymentWebhookEvent
+	err := r.db.WithContext(ctx).First(&event, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (r *paymentRepository) GetWebhookEventForUpdate(ctx context.Context, id string) (*model.PaymentWebhookEvent, error) {
+	var event model.PaymentWebhookEvent
+	err := r.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// UpdateWebhookEventStatus 更新回调处理结果并累加处理次数
+func (r *paymentRepository) UpdateWebhookEventStatus(ctx context.Context, id, status, lastError string) error {
+	updates := map[string]interface{}{
+		"status":     status,
+		"last_error": lastError,
+		"attempts":   gorm.Expr("attempts + 1"),
+	}
+	if status == model.WebhookEventStatusProcessed || status == model.WebhookEventStatusIgnored {
+		updates["processed_at"] = time.Now()
+	}
+	return r.db.WithContext(ctx).Model(&model.PaymentWebhookEvent{}).Where("id = ?", id).Updates(updates).Error
+}
+
+func (r *paymentRepository) ListWebhookEvents(ctx context.Context, req *model.ListPaymentWebhookEventsRequest) ([]*model.PaymentWebhookEvent, int64, error) {
+	var events []*model.PaymentWebhookEvent
+	var total int64
+	query := r.db.WithContext(ctx).Model(&model.PaymentWebhookEvent{})
+	if req.Gateway != "" {
+		query = query.Where("gateway = ?", req.Gateway)
+	}
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	}
+	if req.OrderID != "" {
+		query = query.Where("order_id = ?", req.OrderID)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+	offset := (req.Page - 1) * req.PageSize
+	err := query.Order("created_at DESC").Offset(offset).Limit(req.PageSize).Find(&events).Error
+	return events, total, err
+}
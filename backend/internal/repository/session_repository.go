@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"master-guide-backend/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 刷新令牌轮换错误
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token invalid")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+)
+
+// SessionRepository 登录会话数据访问接口
+type SessionRepository interface {
+	CreateSession(ctx context.Context, session *model.AuthSession, token *model.RefreshToken) error
+	GetSessionByID(ctx context.Context, id string) (*model.AuthSession, error)
+	ListActiveSessions(ctx context.Context, userID string) ([]*model.AuthSession, error)
+	IsSessionActive(ctx context.Context, id string) (bool, error)
+	UpdateSessionIdentity(ctx context.Context, id, identityID string) error
+	RotateRefreshToken(ctx context.Context, tokenHash string, next *model.RefreshToken) (*model.AuthSession, error)
+	RevokeSession(ctx context.Context, id, reason string) error
+	RevokeUserSessions(ctx context.Context, userID, exceptSessionID, reason string) error
+}
+
+// sessionRepository 登录会话数据访问实现
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+// NewSessionRepository 创建登录会话数据访问实例
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+// CreateSession 创建会话及其首个刷新令牌
+func (r *sessionRepository) CreateSession(ctx context.Context, session *model.AuthSession, token *model.RefreshToken) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(session).Error; err != nil {
+			return err
+		}
+		token.SessionID = session.ID
+		return tx.Create(token).Error
+	})
+}
+
+// GetSessionByID 根据ID获取会话
+func (r *sessionRepository) GetSessionByID(ctx context.Context, id string) (*model.AuthSession, error) {
+	var session model.AuthSession
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ListActiveSessions 获取用户所有有效会话
+func (r *sessionRepository) ListActiveSessions(ctx context.Context, userID string) ([]*model.AuthSession, error) {
+	var sessions []*model.AuthSession
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// IsSessionActive 检查会话是否有效
+func (r *sessionRepository) IsSessionActive(ctx context.Context, id string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&model.AuthSession{}).
+		Where("id = ? AND revoked_at IS NULL AND expires_at > ?", id, time.Now()).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// UpdateSessionIdentity 更新会话当前身份
+func (r *sessionRepository) UpdateSessionIdentity(ctx context.Context, id, identityID string) error {
+	return r.db.WithContext(ctx).
+		Model(&model.AuthSession{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"identity_id":  identityID,
+			"last_used_at": time.Now(),
+		}).Error
+}
+
+// RotateRefreshToken 轮换刷新令牌
+// 旧令牌被标记为已使用并签发新令牌；若旧令牌此前已被使用，视为令牌泄露，注销整个会话并返回 ErrRefreshTokenReused
+func (r *sessionRepository) RotateRefreshToken(ctx context.Context, tokenHash string, next *model.RefreshToken) (*model.AuthSession, error) {
+	var session model.AuthSession
+	reused := false
+	now := time.Now()
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var current model.RefreshToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", tokenHash).
+			First(&current).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRefreshTokenInvalid
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", current.SessionID).
+			First(&session).Error; err != nil {
+			return ErrRefreshTokenInvalid
+		}
+
+		if current.UsedAt != nil {
+			reused = true
+			if session.RevokedAt != nil {
+				return nil
+			}
+			return tx.Model(&model.AuthSession{}).
+				Where("id = ?", session.ID).
+				Updates(map[string]interface{}{
+					"revoked_at":    now,
+					"revoke_reason": "refresh_token_reused",
+				}).Error
+		}
+
+		if !current.ExpiresAt.After(now) || !session.IsActive(now) {
+			return ErrRefreshTokenInvalid
+		}
+
+		if err := tx.Model(&model.RefreshToken{}).
+			Where("id = ?", current.ID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		next.SessionID = session.ID
+		if err := tx.Create(next).Error; err != nil {
+			return err
+		}
+
+		session.LastUsedAt = now
+		session.ExpiresAt = next.ExpiresAt
+		return tx.Model(&model.AuthSession{}).
+			Where("id = ?", session.ID).
+			Updates(map[string]interface{}{
+				"last_used_at": session.LastUsedAt,
+				"expires_at":   session.ExpiresAt,
+			}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, ErrRefreshTokenReused
+	}
+	return &session, nil
+}
+
+// RevokeSession 注销会话
+func (r *sessionRepository) RevokeSession(ctx context.Context, id, reason string) error {
+	return r.db.WithContext(ctx).
+		Model(&model.AuthSession{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"revoked_at":    time.Now(),
+			"revoke_reason": reason,
+		}).Error
+}
+
+// RevokeUserSessions 注销用户的所有会话，可保留指定会话
+func (r *sessionRepository) RevokeUserSessions(ctx context.Context, userID, exceptSessionID, reason string) error {
+	query := r.db.WithContext(ctx).
+		Model(&model.AuthSession{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID)
+	if exceptSessionID != "" {
+		query = query.Where("id <> ?", exceptSessionID)
+	}
+	return query.Updates(map[string]interface{}{
+		"revoked_at":    time.Now(),
+		"revoke_reason": reason,
+	}).Error
+}
@@ -27,7 +27,7 @@ func (r *statsRepository) GetUserLearningStats(ctx context.Context, userID strin
 	// 获取已报名课程数
 	err := r.db.WithContext(ctx).
 		Table("learning_records").
-		Where("user_id = ?", userID).
+		Where("user_id = ? AND status NOT IN ?", userID, []string{"pending_payment", "cancelled"}).
 		Count(&stats.EnrolledCourses).Error
 	if err != nil {
 		return nil, err
@@ -117,7 +117,7 @@ func (r *statsRepository) GetUserTeachingStats(ctx context.Context, userID strin
 	err = r.db.WithContext(ctx).
 		Table("learning_records lr").
 		Joins("LEFT JOIN courses c ON lr.course_id = c.id").
-		Where("c.mentor_id = ?", mentorID).
+		Where("c.mentor_id = ? AND lr.status NOT IN ?", mentorID, []string{"pending_payment", "cancelled"}).
 		Distinct("lr.user_id").
 		Count(&stats.TotalStudents).Error
 	if err != nil {
@@ -21,6 +21,8 @@ type StudentRepository interface {
 	UpdateStudentProgress(ctx context.Context, studentID, courseID string, progressPercentage float64, notes string) error
 	GradeAssignment(ctx context.Context, assignmentID string, score float64, feedback, comments string) error
 	GetStudentReport(ctx context.Context, studentID, period string) (*model.StudentReport, error)
+	IsMentorStudent(ctx context.Context, mentorID, studentID, courseID string) (bool, error)
+	IsMentorAssignment(ctx context.Context, mentorID, studentID, assignmentID string) (bool, error)
 }
 
 // studentRepository 学生数据访问实现
@@ -51,7 +53,7 @@ func (r *studentRepository) GetStudents(ctx context.Context, mentorID, status, c
 		`).
 		Joins("JOIN user_identities ui ON u.id = ui.user_id").
 		Joins("LEFT JOIN user_profiles up ON ui.id = up.identity_id").
-		Joins("LEFT JOIN learning_records lr ON u.id = lr.user_id").
+		Joins("LEFT JOIN learning_records lr ON u.id = lr.user_id AND lr.status NOT IN ('pending_payment', 'cancelled')").
 		Joins("LEFT JOIN courses c ON lr.course_id = c.id").
 		Where("ui.identity_type = ?", "apprentice")
 
@@ -160,7 +162,7 @@ func (r *studentRepository) GetStudentStats(ctx context.Context, mentorID string
 	baseQuery := r.db.WithContext(ctx).
 		Table("users u").
 		Joins("JOIN user_identities ui ON u.id = ui.user_id").
-		Joins("LEFT JOIN learning_records lr ON u.id = lr.user_id").
+		Joins("LEFT JOIN learning_records lr ON u.id = lr.user_id AND lr.status NOT IN ('pending_payment', 'cancelled')").
 		Joins("LEFT JOIN courses c ON lr.course_id = c.id").
 		Where("ui.identity_type = ?", "apprentice")
 
@@ -224,16 +226,20 @@ func (r *studentRepository) GetStudentStats(ctx context.Context, mentorID string
 
 	// 获取按课程分组的统计
 	var courseStats []*model.StudentCourseStats
-	err = r.db.WithContext(ctx).
+	courseStatsQuery := r.db.WithContext(ctx).
 		Table("courses c").
 		Select(`
 			c.id as course_id, c.title as course_title,
 			COUNT(DISTINCT lr.user_id) as student_count,
 			COALESCE(AVG(lr.progress_percentage), 0) as average_progress
 		`).
-		Joins("LEFT JOIN learning_records lr ON c.id = lr.course_id").
+		Joins("LEFT JOIN learning_records lr ON c.id = lr.course_id AND lr.status NOT IN ('pending_payment', 'cancelled')").
 		Joins("LEFT JOIN user_identities ui ON lr.user_id = ui.user_id").
-		Where("ui.identity_type = ?", "apprentice").
+		Where("ui.identity_type = ?", "apprentice")
+	if mentorID != "" {
+		courseStatsQuery = courseStatsQuery.Where("c.mentor_id = ?", mentorID)
+	}
+	err = courseStatsQuery.
 		Group("c.id, c.title").
 		Find(&courseStats).Error
 	if err == nil {
@@ -420,3 +426,39 @@ func (r *studentRepository) GetStudentReport(ctx context.Context, studentID, per
 
 	return &report, nil
 }
+
+// IsMentorStudent 判断学生是否报名了该大师的课程（指定courseID时仅判断该课程）或预约过该大师
+func (r *studentRepository) IsMentorStudent(ctx context.Context, mentorID, studentID, courseID string) (bool, error) {
+	var count int64
+	query := r.db.WithContext(ctx).
+		Table("learning_records lr").
+		Joins("JOIN courses c ON lr.course_id = c.id").
+		Where("lr.user_id = ? AND c.mentor_id = ?", studentID, mentorID)
+	if courseID != "" {
+		query = query.Where("lr.course_id = ?", courseID)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 || courseID != "" {
+		return count > 0, nil
+	}
+
+	err := r.db.WithContext(ctx).
+		Table("appointments").
+		Where("student_id = ? AND mentor_id = ?", studentID, mentorID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// IsMentorAssignment 判断作业是否属于该学生在该大师课程中的学习记录
+func (r *studentRepository) IsMentorAssignment(ctx context.Context, mentorID, studentID, assignmentID string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Table("assignments a").
+		Joins("JOIN learning_records lr ON a.learning_record_id = lr.id").
+		Joins("JOIN courses c ON lr.course_id = c.id").
+		Where("a.id = ? AND lr.user_id = ? AND c.mentor_id = ?", assignmentID, studentID, mentorID).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,327 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"master-guide-backend/internal/ledger"
+	"master-guide-backend/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrWithdrawalStatusConflict = errors.New("withdrawal status conflict")
+	ErrNoApprovedWithdrawals    = errors.New("no approved withdrawals")
+)
+
+// WithdrawalRepository 提现审核与打款数据访问接口
+// 状态变更均在事务中锁定提现行，并同步记账：驳回和打款失败将金额退回大师应付款，打款成功结转提现在途
+type WithdrawalRepository interface {
+	ListWithdrawals(ctx context.Context, status, mentorID string, page, pageSize int) ([]*model.WithdrawalModel, int64, error)
+	GetWithdrawalByID(ctx context.Context, withdrawalID string) (*model.WithdrawalModel, error)
+	ApproveWithdrawal(ctx context.Context, withdrawalID, reviewerID string, at time.Time) (*model.WithdrawalModel, error)
+	RejectWithdrawal(ctx context.Context, withdrawalID, reviewerID, reason string, at time.Time) (*model.WithdrawalModel, error)
+	CreatePayoutBatch(ctx context.Context, createdBy string, withdrawalIDs []string, limit int) (*model.PayoutBatch, error)
+	GetPayoutBatch(ctx context.Context, batchID string) (*model.PayoutBatch, error)
+	ListPayoutBatches(ctx context.Context, status string, page, pageSize int) ([]*model.PayoutBatch, int64, error)
+	ListBatchWithdrawals(ctx context.Context, batchID string) ([]*model.WithdrawalModel, error)
+	MarkBatchExported(ctx context.Context, batchID string, at time.Time) error
+	CompletePayout(ctx context.Context, batchID, withdrawalID, reference string, at time.Time) (*model.WithdrawalModel, bool, error)
+	FailPayout(ctx context.Context, batchID, withdrawalID, reason string, at time.Time) (*model.WithdrawalModel, bool, error)
+}
+
+// withdrawalRepository 提现审核与打款数据访问实现
+type withdrawalRepository struct {
+	db *gorm.DB
+}
+
+// NewWithdrawalRepository 创建提现审核与打款数据访问实例
+func NewWithdrawalRepository(db *gorm.DB) WithdrawalRepository {
+	return &withdrawalRepository{db: db}
+}
+
+// ListWithdrawals 按状态和大师分页获取提现申请，待审核的按申请时间先后排列
+func (r *withdrawalRepository) ListWithdrawals(ctx context.Context, status, mentorID string, page, pageSize int) ([]*model.WithdrawalModel, int64, error) {
+	var withdrawals []*model.WithdrawalModel
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&model.WithdrawalModel{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if mentorID != "" {
+		query = query.Where("mentor_id = ?", mentorID)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	err := query.Order("created_at ASC").Offset(offset).Limit(pageSize).Find(&withdrawals).Error
+	return withdrawals, total, err
+}
+
+// GetWithdrawalByID 根据ID获取提现申请
+func (r *withdrawalRepository) GetWithdrawalByID(ctx context.Context, withdrawalID string) (*model.WithdrawalModel, error) {
+	var withdrawal model.WithdrawalModel
+	if err := r.db.WithContext(ctx).First(&withdrawal, "id = ?", withdrawalID).Error; err != nil {
+		return nil, err
+	}
+	return &withdrawal, nil
+}
+
+// ApproveWithdrawal 审核通过待审核的提现申请
+func (r *withdrawalRepository) ApproveWithdrawal(ctx context.Context, withdrawalID, reviewerID string, at time.Time) (*model.WithdrawalModel, error) {
+	var withdrawal *model.WithdrawalModel
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		withdrawal, err = lockWithdrawal(tx, withdrawalID)
+		if err != nil {
+			return err
+		}
+		if withdrawal.Status != model.WithdrawalStatusPending {
+			return ErrWithdrawalStatusConflict
+		}
+
+		withdrawal.Status = model.WithdrawalStatusApproved
+		withdrawal.ReviewedBy = optionalString(reviewerID)
+		withdrawal.ReviewedAt = &at
+		return tx.Model(withdrawal).
+			Select("status", "reviewed_by", "reviewed_at").
+			Updates(withdrawal).Error
+	})
+	return withdrawal, err
+}
+
+// RejectWithdrawal 驳回待审核的提现申请，并将提现金额从提现在途退回大师应付款
+func (r *withdrawalRepository) RejectWithdrawal(ctx context.Context, withdrawalID, reviewerID, reason string, at time.Time) (*model.WithdrawalModel, error) {
+	var withdrawal *model.WithdrawalModel
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		withdrawal, err = lockWithdrawal(tx, withdrawalID)
+		if err != nil {
+			return err
+		}
+		if withdrawal.Status != model.WithdrawalStatusPending {
+			return ErrWithdrawalStatusConflict
+		}
+
+		withdrawal.Status = model.WithdrawalStatusRejected
+		withdrawal.ReviewedBy = optionalString(reviewerID)
+		withdrawal.ReviewedAt = &at
+		withdrawal.RejectReason = &reason
+		if err := tx.Model(withdrawal).
+			Select("status", "reviewed_by", "reviewed_at", "reject_reason").
+			Updates(withdrawal).Error; err != nil {
+			return err
+		}
+		_, err = postLedgerTransaction(tx, ledger.WithdrawalFailed(withdrawal.ID, withdrawal.MentorID, ledger.ToMinor(withdrawal.Amount), at))
+		return err
+	})
+	return withdrawal, err
+}
+
+// CreatePayoutBatch 将已审核通过的提现放入新的打款批次并标记为打款中
+// withdrawalIDs 为空时按审核时间先后选取，最多 limit 笔；已被其他批次锁定的提现会被跳过
+func (r *withdrawalRepository) CreatePayoutBatch(ctx context.Context, createdBy string, withdrawalIDs []string, limit int) (*model.PayoutBatch, error) {
+	var batch *model.PayoutBatch
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var withdrawals []*model.WithdrawalModel
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ?", model.WithdrawalStatusApproved)
+		if len(withdrawalIDs) > 0 {
+			query = query.Where("id IN ?", withdrawalIDs)
+		}
+		if err := query.Order("reviewed_at ASC, created_at ASC").Limit(limit).Find(&withdrawals).Error; err != nil {
+			return err
+		}
+		if len(withdrawals) == 0 {
+			return ErrNoApprovedWithdrawals
+		}
+
+		batch = &model.PayoutBatch{
+			Status:          model.PayoutBatchStatusProcessing,
+			WithdrawalCount: len(withdrawals),
+			CreatedBy:       optionalString(createdBy),
+		}
+		var totalAmount, totalNetAmount int64
+		ids := make([]string, 0, len(withdrawals))
+		for _, withdrawal := range withdrawals {
+			totalAmount += ledger.ToMinor(withdrawal.Amount)
+			totalNetAmount += ledger.ToMinor(withdrawal.NetAmount)
+			ids = append(ids, withdrawal.ID)
+		}
+		batch.TotalAmount = ledger.FromMinor(totalAmount)
+		batch.TotalNetAmount = ledger.FromMinor(totalNetAmount)
+		if err := tx.Create(batch).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&model.WithdrawalModel{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"status":          model.WithdrawalStatusProcessing,
+				"payout_batch_id": batch.ID,
+			}).Error
+	})
+	return batch, err
+}
+
+// GetPayoutBatch 根据ID获取打款批次
+func (r *withdrawalRepository) GetPayoutBatch(ctx context.Context, batchID string) (*model.PayoutBatch, error) {
+	var batch model.PayoutBatch
+	if err := r.db.WithContext(ctx).First(&batch, "id = ?", batchID).Error; err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// ListPayoutBatches 分页获取打款批次
+func (r *withdrawalRepository) ListPayoutBatches(ctx context.Context, status string, page, pageSize int) ([]*model.PayoutBatch, int64, error) {
+	var batches []*model.PayoutBatch
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&model.PayoutBatch{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&batches).Error
+	return batches, total, err
+}
+
+// ListBatchWithdrawals 获取打款批次中的全部提现
+func (r *withdrawalRepository) ListBatchWithdrawals(ctx context.Context, batchID string) ([]*model.WithdrawalModel, error) {
+	var withdrawals []*model.WithdrawalModel
+	err := r.db.WithContext(ctx).
+		Where("payout_batch_id = ?", batchID).
+		Order("created_at ASC").
+		Find(&withdrawals).Error
+	return withdrawals, err
+}
+
+// MarkBatchExported 记录打款批次的导出时间
+func (r *withdrawalRepository) MarkBatchExported(ctx context.Context, batchID string, at time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&model.PayoutBatch{}).
+		Where("id = ?", batchID).
+		Update("exported_at", at).Error
+}
+
+// CompletePayout 确认批次中的提现打款成功，提现在途结转为已打款，手续费计入平台服务费
+// 已确认成功时不重复处理并返回 false
+func (r *withdrawalRepository) CompletePayout(ctx context.Context, batchID, withdrawalID, reference string, at time.Time) (*model.WithdrawalModel, bool, error) {
+	return r.settlePayout(ctx, batchID, withdrawalID, model.WithdrawalStatusCompleted, at, func(tx *gorm.DB, withdrawal *model.WithdrawalModel) error {
+		withdrawal.PayoutReference = optionalString(reference)
+		withdrawal.CompletedAt = &at
+		if err := tx.Model(withdrawal).
+			Select("status", "payout_reference", "completed_at").
+			Updates(withdrawal).Error; err != nil {
+			return err
+		}
+		_, err := postLedgerTransaction(tx, ledger.WithdrawalPaid(withdrawal.ID, withdrawal.MentorID,
+			ledger.ToMinor(withdrawal.Amount), ledger.ToMinor(withdrawal.Fee), at))
+		return err
+	})
+}
+
+// FailPayout 确认批次中的提现打款失败，并将提现金额退回大师应付款
+// 已确认失败时不重复处理并返回 false
+func (r *withdrawalRepository) FailPayout(ctx context.Context, batchID, withdrawalID, reason string, at time.Time) (*model.WithdrawalModel, bool, error) {
+	return r.settlePayout(ctx, batchID, withdrawalID, model.WithdrawalStatusFailed, at, func(tx *gorm.DB, withdrawal *model.WithdrawalModel) error {
+		withdrawal.FailureReason = optionalString(reason)
+		if err := tx.Model(withdrawal).
+			Select("status", "failure_reason").
+			Updates(withdrawal).Error; err != nil {
+			return err
+		}
+		_, err := postLedgerTransaction(tx, ledger.WithdrawalFailed(withdrawal.ID, withdrawal.MentorID, ledger.ToMinor(withdrawal.Amount), at))
+		return err
+	})
+}
+
+// settlePayout 锁定打款中的提现并写入打款结果，随后刷新批次统计
+func (r *withdrawalRepository) settlePayout(ctx context.Context, batchID, withdrawalID, status string, at time.Time, apply func(tx *gorm.DB, withdrawal *model.WithdrawalModel) error) (*model.WithdrawalModel, bool, error) {
+	var withdrawal *model.WithdrawalModel
+	settled := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var batch model.PayoutBatch
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&batch, "id = ?", batchID).Error; err != nil {
+			return err
+		}
+
+		var err error
+		withdrawal, err = lockWithdrawal(tx, withdrawalID)
+		if err != nil {
+			return err
+		}
+		if withdrawal.PayoutBatchID == nil || *withdrawal.PayoutBatchID != batchID {
+			return gorm.ErrRecordNotFound
+		}
+		if withdrawal.Status == status {
+			return nil
+		}
+		if withdrawal.Status != model.WithdrawalStatusProcessing {
+			return ErrWithdrawalStatusConflict
+		}
+
+		withdrawal.Status = status
+		if err := apply(tx, withdrawal); err != nil {
+			return err
+		}
+		settled = true
+		return refreshPayoutBatch(tx, &batch, at)
+	})
+	return withdrawal, settled, err
+}
+
+// refreshPayoutBatch 重新统计批次的成功和失败笔数，全部提现都有结果时批次完成
+func refreshPayoutBatch(tx *gorm.DB, batch *model.PayoutBatch, at time.Time) error {
+	var counts []struct {
+		Status string
+		Count  int
+	}
+	if err := tx.Model(&model.WithdrawalModel{}).
+		Select("status, COUNT(*) AS count").
+		Where("payout_batch_id = ?", batch.ID).
+		Group("status").
+		Scan(&counts).Error; err != nil {
+		return err
+	}
+
+	batch.SucceededCount, batch.FailedCount = 0, 0
+	processing := 0
+	for _, count := range counts {
+		switch count.Status {
+		case model.WithdrawalStatusCompleted:
+			batch.SucceededCount = count.Count
+		case model.WithdrawalStatusFailed:
+			batch.FailedCount = count.Count
+		case model.WithdrawalStatusProcessing:
+			processing = count.Count
+		}
+	}
+	if processing == 0 {
+		batch.Status = model.PayoutBatchStatusCompleted
+		batch.CompletedAt = &at
+	}
+	return tx.Model(batch).
+		Select("status", "succeeded_count", "failed_count", "completed_at").
+		Updates(batch).Error
+}
+
+// lockWithdrawal 锁定提现行
+func lockWithdrawal(tx *gorm.DB, withdrawalID string) (*model.WithdrawalModel, error) {
+	var withdrawal model.WithdrawalModel
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&withdrawal, "id = ?", withdrawalID).Error; err != nil {
+		return nil, err
+	}
+	return &withdrawal, nil
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"master-guide-backend/internal/model"
+	"master-guide-backend/internal/repository"
+)
+
+// AppointmentPackageService 咨询套餐服务接口
+// 大师发布包含多次咨询的套餐，学生购买并支付后在有效期内按次预约
+type AppointmentPackageService interface {
+	CreatePackage(ctx context.Context, mentorID string, req *model.AppointmentPackageRequest) (*model.AppointmentPackageInfo, error)
+	UpdatePackage(ctx context.Context, mentorID, packageID string, req *model.AppointmentPackageRequest) (*model.AppointmentPackageInfo, error)
+	ListPackages(ctx context.Context, mentorID string, activeOnly bool) (*model.AppointmentPackageListResponse, error)
+	PurchasePackage(ctx context.Context, studentID, packageID string, req *model.PurchaseAppointmentPackageRequest, client *model.ClientInfo) (*model.PurchaseAppointmentPackageResponse, error)
+	ListPurchases(ctx context.Context, studentID string) (*model.PackagePurchaseListResponse, error)
+}
+
+// appointmentPackageService 咨询套餐服务实现
+type appointmentPackageService struct {
+	packageRepo    repository.AppointmentPackageRepository
+	mentorRepo     repository.MentorRepository
+	paymentService PaymentService
+}
+
+// NewAppointmentPackageService 创建咨询套餐服务实例
+func NewAppointmentPackageService(packageRepo repository.AppointmentPackageRepository, mentorRepo repository.MentorRepository, paymentService PaymentService) AppointmentPackageService {
+	return &appointmentPackageService{
+		packageRepo:    packageRepo,
+		mentorRepo:     mentorRepo,
+		paymentService: paymentService,
+	}
+}
+
+// CreatePackage 大师发布咨询套餐
+func (s *appointmentPackageService) CreatePackage(ctx context.Context, mentorID string, req *model.AppointmentPackageRequest) (*model.AppointmentPackageInfo, error) {
+	mentor, err := s.mentorRepo.GetMentorByID(ctx, mentorID)
+	if err != nil {
+		return nil, errors.New("大师不存在")
+	}
+
+	pkg := &model.AppointmentPackage{MentorID: mentor.ID}
+	applyPackageRequest(pkg, req)
+	if err := s.packageRepo.CreatePackage(ctx, pkg); err != nil {
+		return nil, err
+	}
+	return packageInfo(pkg, mentor.HourlyRate), nil
+}
+
+// UpdatePackage 大师修改或下架自己的咨询套餐，已购买的套餐不受影响
+func (s *appointmentPackageService) UpdatePackage(ctx context.Context, mentorID, packageID string, req *model.AppointmentPackageRequest) (*model.AppointmentPackageInfo, error) {
+	mentor, err := s.mentorRepo.GetMentorByID(ctx, mentorID)
+	if err != nil {
+		return nil, errors.New("大师不存在")
+	}
+	pkg, err := s.packageRepo.GetPackageByID(ctx, packageID)
+	if err != nil || pkg.MentorID != mentor.ID {
+		return nil, errors.New("套餐不存在")
+	}
+
+	applyPackageRequest(pkg, req)
+	if err := s.packageRepo.UpdatePackage(ctx, pkg); err != nil {
+		return nil, err
+	}
+	return packageInfo(pkg, mentor.HourlyRate), nil
+}
+
+// ListPackages 获取大师的咨询套餐，activeOnly 时只返回在售套餐
+func (s *appointmentPackageService) ListPackages(ctx context.Context, mentorID string, activeOnly bool) (*model.AppointmentPackageListResponse, error) {
+	mentor, err := s.mentorRepo.GetMentorByID(ctx, mentorID)
+	if err != nil {
+		return nil, errors.New("大师不存在")
+	}
+
+	status := ""
+	if activeOnly {
+		status = model.AppointmentPackageStatusActive
+	}
+	packages, err := s.packageRepo.ListPackagesByMentor(ctx, mentor.ID, status)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]*model.AppointmentPackageInfo, len(packages))
+	for i, pkg := range packages {
+		infos[i] = packageInfo(pkg, mentor.HourlyRate)
+	}
+	return &model.AppointmentPackageListResponse{Packages: infos}, nil
+}
+
+// PurchasePackage 学生购买咨询套餐，按大师当前时薪和套餐优惠计算价格并下单，支付完成后由履约流程开通
+func (s *appointmentPackageService) PurchasePackage(ctx context.Context, studentID, packageID string, req *model.PurchaseAppointmentPackageRequest, client *model.ClientInfo) (*model.PurchaseAppointmentPackageResponse, error) {
+	pkg, err := s.packageRepo.GetPackageByID(ctx, packageID)
+	if err != nil {
+		return nil, errors.New("套餐不存在")
+	}
+	if pkg.Status != model.AppointmentPackageStatusActive {
+		return nil, errors.New("套餐已下架")
+	}
+	mentor, err := s.mentorRepo.GetMentorByID(ctx, pkg.MentorID)
+	if err != nil {
+		return nil, errors.New("大师不存在")
+	}
+	if mentor.UserID == studentID {
+		return nil, errors.New("不能购买自己的套餐")
+	}
+
+	info := packageInfo(pkg, mentor.HourlyRate)
+	if info.Price <= 0 {
+		return nil, errors.New("大师未设置咨询价格，暂不能购买套餐")
+	}
+
+	purchase := &model.AppointmentPackagePurchase{
+		PackageID:       pkg.ID,
+		MentorID:        pkg.MentorID,
+		StudentID:       studentID,
+		Title:           pkg.Title,
+		TotalSessions:   pkg.SessionCount,
+		DurationMinutes: pkg.DurationMinutes,
+		ValidityDays:    pkg.ValidityDays,
+		Price:           info.Price,
+		Status:          model.PackagePurchaseStatusPendingPayment,
+	}
+	if err := s.packageRepo.CreatePurchase(ctx, purchase); err != nil {
+		return nil, err
+	}
+
+	payment, err := s.paymentService.CreatePaymentOrder(ctx, studentID, &model.CreatePaymentOrderRequest{
+		OrderType:     model.PaymentOrderTypeAppointmentPackage,
+		OrderID:       purchase.ID,
+		Amount:        purchase.Price,
+		Currency:      "CNY",
+		PaymentMethod: req.PaymentMethod,
+	}, client)
+	if err != nil {
+		_ = s.packageRepo.CancelPendingPurchase(ctx, purchase.ID)
+		return nil, err
+	}
+
+	return &model.PurchaseAppointmentPackageResponse{
+		PurchaseID: purchase.ID,
+		Status:     purchase.Status,
+		Price:      purchase.Price,
+		Payment:    payment,
+	}, nil
+}
+
+// ListPurchases 获取学生已购买的套餐及剩余次数
+func (s *appointmentPackageService) ListPurchases(ctx context.Context, studentID string) (*model.PackagePurchaseListResponse, error) {
+	purchases, err := s.packageRepo.ListPurchasesByStudent(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.PackagePurchaseListResponse{Purchases: purchases}, nil
+}
+
+// applyPackageRequest 将请求内容写入套餐，未指定状态时为在售
+func applyPackageRequest(pkg *model.AppointmentPackage, req *model.AppointmentPackageRequest) {
+	pkg.Title = req.Title
+	pkg.Description = req.Description
+	pkg.SessionCount = req.SessionCount
+	pkg.DurationMinutes = req.DurationMinutes
+	pkg.DiscountRate = req.DiscountRate
+	pkg.ValidityDays = req.ValidityDays
+	pkg.Status = req.Status
+	if pkg.Status == "" {
+		pkg.Status = model.AppointmentPackageStatusActive
+	}
+}
+
+// packageInfo 按大师时薪计算套餐原价和优惠价，按分取整
+func packageInfo(pkg *model.AppointmentPackage, hourlyRate float64) *model.AppointmentPackageInfo {
+	original := math.Round(float64(pkg.SessionCount)*float64(pkg.DurationMinutes)/60.0*hourlyRate*100) / 100
+	return &model.AppointmentPackageInfo{
+		AppointmentPackage: pkg,
+		OriginalPrice:      original,
+		Price:              math.Round(original*(1-pkg.DiscountRate)*100) / 100,
+	}
+}
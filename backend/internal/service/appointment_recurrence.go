@@ -0,0 +1,105 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"master-guide-backend/internal/model"
+)
+
+// maxRecurrenceCount 一次重复预约最多展开的次数
+const maxRecurrenceCount = 52
+
+// recurrenceWeekdays RRULE BYDAY 取值，按周一至周日排列
+var recurrenceWeekdays = []struct {
+	Code    string
+	Weekday time.Weekday
+}{
+	{"MO", time.Monday},
+	{"TU", time.Tuesday},
+	{"WE", time.Wednesday},
+	{"TH", time.Thursday},
+	{"FR", time.Friday},
+	{"SA", time.Saturday},
+	{"SU", time.Sunday},
+}
+
+// expandWeeklyRecurrence 在大师时区按周展开重复规则，返回各次预约的 UTC 开始时间和规范化的 RRULE
+// 每周从周一开始计算间隔，各次预约沿用首次预约的当地时刻，跨夏令时不随 UTC 偏移
+// 展开到 COUNT 次或 UNTIL 为止，同时提供时以先达到的为准
+func expandWeeklyRecurrence(first time.Time, loc *time.Location, rule *model.RecurrenceRule) ([]time.Time, string, error) {
+	interval := rule.Interval
+	if interval <= 0 {
+		interval = 1
+	}
+
+	selected := make(map[time.Weekday]bool, len(rule.ByDay))
+	for _, code := range rule.ByDay {
+		for _, day := range recurrenceWeekdays {
+			if day.Code == code {
+				selected[day.Weekday] = true
+			}
+		}
+	}
+	var codes []string
+	for _, day := range recurrenceWeekdays {
+		if selected[day.Weekday] {
+			codes = append(codes, day.Code)
+		}
+	}
+	if len(codes) == 0 {
+		return nil, "", errors.New("重复规则不合法")
+	}
+
+	local := first.In(loc)
+	if !selected[local.Weekday()] {
+		return nil, "", errors.New("首次预约时间需落在重复规则的日期内")
+	}
+
+	count := rule.Count
+	if count <= 0 {
+		if rule.Until == nil {
+			return nil, "", errors.New("重复规则不合法")
+		}
+		// 只提供 UNTIL 时多展开一次，用于判断是否超过次数上限
+		count = maxRecurrenceCount + 1
+	}
+	if rule.Until != nil && rule.Until.Before(first) {
+		return nil, "", errors.New("重复规则的截止时间不能早于首次预约时间")
+	}
+
+	weekStart := startOfDay(local).AddDate(0, 0, -((int(local.Weekday()) + 6) % 7))
+	times := make([]time.Time, 0, count)
+	for week, ended := 0, false; len(times) < count && !ended; week += interval {
+		for offset, day := range recurrenceWeekdays {
+			if !selected[day.Weekday] || len(times) == count || ended {
+				continue
+			}
+			date := weekStart.AddDate(0, 0, week*7+offset)
+			start := time.Date(date.Year(), date.Month(), date.Day(), local.Hour(), local.Minute(), 0, 0, loc)
+			if start.Before(local.Truncate(time.Minute)) {
+				continue
+			}
+			if rule.Until != nil && start.After(*rule.Until) {
+				ended = true
+				continue
+			}
+			times = append(times, start.UTC())
+		}
+	}
+	if len(times) > maxRecurrenceCount {
+		return nil, "", errors.New("重复预约不能超过52次")
+	}
+	if len(times) < 2 {
+		return nil, "", errors.New("重复规则至少需要包含两次预约")
+	}
+
+	// RRULE 不允许同时出现 COUNT 和 UNTIL，只保留实际生效的限制
+	limit := fmt.Sprintf("COUNT=%d", len(times))
+	if rule.Until != nil && (rule.Count <= 0 || len(times) < rule.Count) {
+		limit = "UNTIL=" + rule.Until.UTC().Format("20060102T150405Z")
+	}
+	return times, fmt.Sprintf("FREQ=WEEKLY;INTERVAL=%d;BYDAY=%s;%s", interval, strings.Join(codes, ","), limit), nil
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"master-guide-backend/internal/model"
+)
+
+func TestExpandWeeklyRecurrence(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	utc := func(value string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatalf("parse %q: %v", value, err)
+		}
+		return parsed
+	}
+	until := func(value string) *time.Time {
+		parsed := utc(value)
+		return &parsed
+	}
+
+	tests := []struct {
+		name     string
+		first    string
+		loc      *time.Location
+		rule     model.RecurrenceRule
+		want     []string
+		wantRule string
+		wantErr  string
+	}{
+		{
+			name:     "weekly count",
+			first:    "2024-01-01T10:00:00Z",
+			loc:      time.UTC,
+			rule:     model.RecurrenceRule{ByDay: []string{"MO"}, Count: 3},
+			want:     []string{"2024-01-01T10:00:00Z", "2024-01-08T10:00:00Z", "2024-01-15T10:00:00Z"},
+			wantRule: "FREQ=WEEKLY;INTERVAL=1;BYDAY=MO;COUNT=3",
+		},
+		{
+			name:     "every other week",
+			first:    "2024-01-01T10:00:00Z",
+			loc:      time.UTC,
+			rule:     model.RecurrenceRule{Interval: 2, ByDay: []string{"MO"}, Count: 3},
+			want:     []string{"2024-01-01T10:00:00Z", "2024-01-15T10:00:00Z", "2024-01-29T10:00:00Z"},
+			wantRule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO;COUNT=3",
+		},
+		{
+			name:     "several days starting mid-week",
+			first:    "2024-01-05T10:00:00Z",
+			loc:      time.UTC,
+			rule:     model.RecurrenceRule{ByDay: []string{"FR", "MO"}, Count: 3},
+			want:     []string{"2024-01-05T10:00:00Z", "2024-01-08T10:00:00Z", "2024-01-12T10:00:00Z"},
+			wantRule: "FREQ=WEEKLY;INTERVAL=1;BYDAY=MO,FR;COUNT=3",
+		},
+		{
+			name:     "interval counts from the week start",
+			first:    "2024-01-05T10:00:00Z",
+			loc:      time.UTC,
+			rule:     model.RecurrenceRule{Interval: 2, ByDay: []string{"MO", "FR"}, Count: 3},
+			want:     []string{"2024-01-05T10:00:00Z", "2024-01-15T10:00:00Z", "2024-01-19T10:00:00Z"},
+			wantRule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR;COUNT=3",
+		},
+		{
+			name:     "keeps local time when daylight saving starts",
+			first:    "2024-03-04T15:00:00Z", // 纽约 10:00 EST
+			loc:      newYork,
+			rule:     model.RecurrenceRule{ByDay: []string{"MO"}, Count: 3},
+			want:     []string{"2024-03-04T15:00:00Z", "2024-03-11T14:00:00Z", "2024-03-18T14:00:00Z"},
+			wantRule: "FREQ=WEEKLY;INTERVAL=1;BYDAY=MO;COUNT=3",
+		},
+		{
+			name:     "keeps local time when daylight saving ends",
+			first:    "2024-10-28T14:00:00Z", // 纽约 10:00 EDT
+			loc:      newYork,
+			rule:     model.RecurrenceRule{ByDay: []string{"MO"}, Count: 2},
+			want:     []string{"2024-10-28T14:00:00Z", "2024-11-04T15:00:00Z"},
+			wantRule: "FREQ=WEEKLY;INTERVAL=1;BYDAY=MO;COUNT=2",
+		},
+		{
+			name:     "weekday follows the mentor time zone",
+			first:    "2024-01-02T03:00:00Z", // 纽约周一 22:00
+			loc:      newYork,
+			rule:     model.RecurrenceRule{ByDay: []string{"MO"}, Count: 2},
+			want:     []string{"2024-01-02T03:00:00Z", "2024-01-09T03:00:00Z"},
+			wantRule: "FREQ=WEEKLY;INTERVAL=1;BYDAY=MO;COUNT=2",
+		},
+		{
+			name:     "until is inclusive",
+			first:    "2024-01-01T10:00:00Z",
+			loc:      time.UTC,
+			rule:     model.RecurrenceRule{ByDay: []string{"MO"}, Until: until("2024-01-22T10:00:00Z")},
+			want:     []string{"2024-01-01T10:00:00Z", "2024-01-08T10:00:00Z", "2024-01-15T10:00:00Z", "2024-01-22T10:00:00Z"},
+			wantRule: "FREQ=WEEKLY;INTERVAL=1;BYDAY=MO;UNTIL=20240122T100000Z",
+		},
+		{
+			name:     "until reached before count",
+			first:    "2024-01-01T10:00:00Z",
+			loc:      time.UTC,
+			rule:     model.RecurrenceRule{ByDay: []string{"MO"}, Count: 10, Until: until("2024-01-10T00:00:00Z")},
+			want:     []string{"2024-01-01T10:00:00Z", "2024-01-08T10:00:00Z"},
+			wantRule: "FREQ=WEEKLY;INTERVAL=1;BYDAY=MO;UNTIL=20240110T000000Z",
+		},
+		{
+			name:     "count reached before until",
+			first:    "2024-01-01T10:00:00Z",
+			loc:      time.UTC,
+			rule:     model.RecurrenceRule{ByDay: []string{"MO"}, Count: 2, Until: until("2024-12-31T00:00:00Z")},
+			want:     []string{"2024-01-01T10:00:00Z", "2024-01-08T10:00:00Z"},
+			wantRule: "FREQ=WEEKLY;INTERVAL=1;BYDAY=MO;COUNT=2",
+		},
+		{
+			name:    "until allows at most 52 occurrences",
+			first:   "2024-01-01T10:00:00Z",
+			loc:     time.UTC,
+			rule:    model.RecurrenceRule{ByDay: []string{"MO", "TH"}, Until: until("2024-12-31T23:59:59Z")},
+			wantErr: "重复预约不能超过52次",
+		},
+		{
+			name:    "until yields a single occurrence",
+			first:   "2024-01-01T10:00:00Z",
+			loc:     time.UTC,
+			rule:    model.RecurrenceRule{ByDay: []string{"MO"}, Until: until("2024-01-07T00:00:00Z")},
+			wantErr: "重复规则至少需要包含两次预约",
+		},
+		{
+			name:    "until before first occurrence",
+			first:   "2024-01-01T10:00:00Z",
+			loc:     time.UTC,
+			rule:    model.RecurrenceRule{ByDay: []string{"MO"}, Until: until("2023-12-31T00:00:00Z")},
+			wantErr: "重复规则的截止时间不能早于首次预约时间",
+		},
+		{
+			name:    "no count or until",
+			first:   "2024-01-01T10:00:00Z",
+			loc:     time.UTC,
+			rule:    model.RecurrenceRule{ByDay: []string{"MO"}},
+			wantErr: "重复规则不合法",
+		},
+		{
+			name:    "unknown weekday",
+			first:   "2024-01-01T10:00:00Z",
+			loc:     time.UTC,
+			rule:    model.RecurrenceRule{ByDay: []string{"XX"}, Count: 2},
+			wantErr: "重复规则不合法",
+		},
+		{
+			name:    "first occurrence not on a selected day",
+			first:   "2024-01-02T10:00:00Z",
+			loc:     time.UTC,
+			rule:    model.RecurrenceRule{ByDay: []string{"MO"}, Count: 2},
+			wantErr: "首次预约时间需落在重复规则的日期内",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := tt.rule
+			times, normalized, err := expandWeeklyRecurrence(utc(tt.first), tt.loc, &rule)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("expandWeeklyRecurrence() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expandWeeklyRecurrence() error = %v", err)
+			}
+
+			got := make([]string, len(times))
+			for i, start := range times {
+				got[i] = start.Format(time.RFC3339)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("times = %v, want %v", got, tt.want)
+			}
+			if normalized != tt.wantRule {
+				t.Fatalf("rule = %q, want %q", normalized, tt.wantRule)
+			}
+		})
+	}
+}
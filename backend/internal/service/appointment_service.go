@@ -3,63 +3,159 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 	"math"
+	"time"
 
 	"master-guide-backend/internal/model"
 	"master-guide-backend/internal/repository"
+	"master-guide-backend/pkg/config"
+	"master-guide-backend/pkg/logger"
 )
 
+// errPackageDurationMismatch 使用套餐预约时预约时长与套餐不一致
+var errPackageDurationMismatch = errors.New("预约时长需与套餐一致")
+
 // AppointmentService 预约服务接口
 type AppointmentService interface {
-	CreateAppointment(ctx context.Context, studentID string, req *model.CreateAppointmentRequest) (*model.CreateAppointmentResponse, error)
+	CreateAppointment(ctx context.Context, studentID string, req *model.CreateAppointmentRequest, client *model.ClientInfo) (*model.CreateAppointmentResponse, error)
+	CreateRecurringAppointment(ctx context.Context, studentID string, req *model.CreateRecurringAppointmentRequest) (*model.CreateRecurringAppointmentResponse, error)
+	RescheduleAppointment(ctx context.Context, appointmentID, userID string, req *model.RescheduleAppointmentRequest) (*model.RescheduleAppointmentResponse, error)
 	GetAppointments(ctx context.Context, userID string, req *model.AppointmentListRequest) (*model.AppointmentListResponse, error)
 	GetAppointmentDetail(ctx context.Context, appointmentID string) (*model.AppointmentDetailResponse, error)
-	UpdateAppointmentStatus(ctx context.Context, appointmentID string, req *model.UpdateAppointmentStatusRequest) (*model.UpdateAppointmentStatusResponse, error)
-	CancelAppointment(ctx context.Context, appointmentID string) (*model.CancelAppointmentResponse, error)
+	UpdateAppointmentStatus(ctx context.Context, appointmentID, userID string, req *model.UpdateAppointmentStatusRequest) (*model.UpdateAppointmentStatusResponse, error)
+	CancelAppointment(ctx context.Context, appointmentID, userID, reason string) (*model.CancelAppointmentResponse, error)
+	GetAppointmentStatusHistory(ctx context.Context, appointmentID, userID string) (*model.AppointmentStatusHistoryResponse, error)
 	GetMentorAppointmentStats(ctx context.Context, mentorID string) (*model.MentorAppointmentStatsResponse, error)
 }
 
 // appointmentService 预约服务实现
 type appointmentService struct {
-	appointmentRepo repository.AppointmentRepository
-	mentorRepo      repository.MentorRepository
+	appointmentRepo     repository.AppointmentRepository
+	mentorRepo          repository.MentorRepository
+	packageRepo         repository.AppointmentPackageRepository
+	availabilityService AvailabilityService
+	paymentService      PaymentService
+	meetingService      MeetingService
+	refundPolicy        *refundPolicy
 }
 
 // NewAppointmentService 创建预约服务实例
-func NewAppointmentService(appointmentRepo repository.AppointmentRepository, mentorRepo repository.MentorRepository) AppointmentService {
+func NewAppointmentService(appointmentRepo repository.AppointmentRepository, mentorRepo repository.MentorRepository, packageRepo repository.AppointmentPackageRepository, availabilityService AvailabilityService, paymentService PaymentService, meetingService MeetingService, refundPolicyConfig config.RefundPolicyConfig) AppointmentService {
 	return &appointmentService{
-		appointmentRepo: appointmentRepo,
-		mentorRepo:      mentorRepo,
+		appointmentRepo:     appointmentRepo,
+		mentorRepo:          mentorRepo,
+		packageRepo:         packageRepo,
+		availabilityService: availabilityService,
+		paymentService:      paymentService,
+		meetingService:      meetingService,
+		refundPolicy:        newRefundPolicy(refundPolicyConfig),
 	}
 }
 
 // CreateAppointment 创建预约
-func (s *appointmentService) CreateAppointment(ctx context.Context, studentID string, req *model.CreateAppointmentRequest) (*model.CreateAppointmentResponse, error) {
+// 付费预约先以待支付状态占用时段并下单，支付完成后由履约流程确认；使用已购买套餐时扣减一次套餐次数并直接确认
+func (s *appointmentService) CreateAppointment(ctx context.Context, studentID string, req *model.CreateAppointmentRequest, client *model.ClientInfo) (*model.CreateAppointmentResponse, error) {
 	// 获取大师信息以计算价格
 	mentor, err := s.mentorRepo.GetMentorByID(ctx, req.MentorID)
 	if err != nil {
 		return nil, errors.New("大师不存在")
 	}
+	if mentor.UserID == studentID {
+		return nil, errors.New("不能预约自己")
+	}
+
+	// 预约时间统一以 UTC 保存，需落在大师的可预约时段内
+	appointmentTime := req.AppointmentTime.UTC()
+	if err := s.availabilityService.CheckBookable(ctx, mentor, appointmentTime, req.DurationMinutes); err != nil {
+		return nil, err
+	}
 
-	// 计算价格（基于时长和大师时薪）
-	price := float64(req.DurationMinutes) / 60.0 * mentor.HourlyRate
+	if req.PackagePurchaseID != "" {
+		return s.createPackageAppointment(ctx, mentor, studentID, req, appointmentTime)
+	}
+
+	// 计算价格（基于时长和大师时薪），按分取整
+	price := math.Round(float64(req.DurationMinutes)/60.0*mentor.HourlyRate*100) / 100
+
+	// 免费预约需大师确认
+	status := model.AppointmentStatusPendingPayment
+	if price <= 0 {
+		status = model.AppointmentStatusPending
+	}
 
 	// 创建预约
 	appointment := &model.AppointmentModel{
 		StudentID:       studentID,
 		MentorID:        req.MentorID,
-		AppointmentTime: req.AppointmentTime,
+		AppointmentTime: appointmentTime,
 		DurationMinutes: req.DurationMinutes,
 		MeetingType:     req.MeetingType,
-		Status:          "pending",
+		Status:          status,
 		Price:           price,
 		Notes:           req.Notes,
 	}
 
-	err = s.appointmentRepo.CreateAppointment(ctx, appointment)
+	// 加锁检查与大师、学生已有预约的冲突后创建
+	if err := s.appointmentRepo.BookAppointment(ctx, appointment, mentor.BufferMinutes); err != nil {
+		return nil, bookingError(err, mentorLocation(mentor))
+	}
+
+	response := &model.CreateAppointmentResponse{
+		AppointmentID: appointment.ID,
+		Status:        appointment.Status,
+		Price:         appointment.Price,
+	}
+	if appointment.Status != model.AppointmentStatusPendingPayment {
+		return response, nil
+	}
+
+	payment, err := s.paymentService.CreatePaymentOrder(ctx, studentID, &model.CreatePaymentOrderRequest{
+		OrderType:     model.PaymentOrderTypeAppointment,
+		OrderID:       appointment.ID,
+		Amount:        appointment.Price,
+		Currency:      "CNY",
+		PaymentMethod: req.PaymentMethod,
+	}, client)
+	if err != nil {
+		// 下单失败时释放时段
+		_, _ = s.appointmentRepo.TransitionStatus(ctx, appointment.ID, appointment.Status, &model.AppointmentStatusHistory{
+			ToStatus:  model.AppointmentStatusCancelled,
+			ActorType: model.AppointmentActorSystem,
+			Reason:    "创建支付订单失败",
+		})
+		return nil, err
+	}
+
+	response.Payment = payment
+	return response, nil
+}
+
+// createPackageAppointment 使用已购买套餐创建预约，按套餐均价记录价格
+func (s *appointmentService) createPackageAppointment(ctx context.Context, mentor *model.Mentor, studentID string, req *model.CreateAppointmentRequest, appointmentTime time.Time) (*model.CreateAppointmentResponse, error) {
+	purchase, err := s.usablePurchase(ctx, studentID, mentor.ID, req.PackagePurchaseID)
 	if err != nil {
 		return nil, err
 	}
+	if req.DurationMinutes != purchase.DurationMinutes {
+		return nil, fmt.Errorf("%w（%d分钟）", errPackageDurationMismatch, purchase.DurationMinutes)
+	}
+
+	appointment := &model.AppointmentModel{
+		StudentID:         studentID,
+		MentorID:          mentor.ID,
+		AppointmentTime:   appointmentTime,
+		DurationMinutes:   purchase.DurationMinutes,
+		MeetingType:       req.MeetingType,
+		Status:            model.AppointmentStatusConfirmed,
+		Price:             purchase.SessionPrice(),
+		Notes:             req.Notes,
+		PackagePurchaseID: &purchase.ID,
+	}
+	if err := s.appointmentRepo.BookPackageAppointments(ctx, []*model.AppointmentModel{appointment}, mentor.BufferMinutes, nil); err != nil {
+		return nil, bookingError(err, mentorLocation(mentor))
+	}
+	s.syncMeetingRoom(ctx, appointment)
 
 	return &model.CreateAppointmentResponse{
 		AppointmentID: appointment.ID,
@@ -68,6 +164,164 @@ func (s *appointmentService) CreateAppointment(ctx context.Context, studentID st
 	}, nil
 }
 
+// CreateRecurringAppointment 按每周重复规则使用已购买套餐批量创建预约，每次预约扣减一次套餐次数
+// 每次预约都需落在大师可预约时段内，任一预约不可预约或冲突时全部不创建
+func (s *appointmentService) CreateRecurringAppointment(ctx context.Context, studentID string, req *model.CreateRecurringAppointmentRequest) (*model.CreateRecurringAppointmentResponse, error) {
+	mentor, err := s.mentorRepo.GetMentorByID(ctx, req.MentorID)
+	if err != nil {
+		return nil, errors.New("大师不存在")
+	}
+	if mentor.UserID == studentID {
+		return nil, errors.New("不能预约自己")
+	}
+
+	purchase, err := s.usablePurchase(ctx, studentID, mentor.ID, req.PackagePurchaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := mentorLocation(mentor)
+	times, rule, err := expandWeeklyRecurrence(req.FirstAppointmentTime, loc, req.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+	if purchase.RemainingSessions() < len(times) {
+		return nil, errors.New("套餐剩余次数不足")
+	}
+
+	appointments := make([]*model.AppointmentModel, len(times))
+	for i, start := range times {
+		if err := s.availabilityService.CheckBookable(ctx, mentor, start, purchase.DurationMinutes); err != nil {
+			return nil, fmt.Errorf("%s：%w", start.In(loc).Format("2006-01-02 15:04"), err)
+		}
+		appointments[i] = &model.AppointmentModel{
+			StudentID:         studentID,
+			MentorID:          mentor.ID,
+			AppointmentTime:   start,
+			DurationMinutes:   purchase.DurationMinutes,
+			MeetingType:       req.MeetingType,
+			Status:            model.AppointmentStatusConfirmed,
+			Price:             purchase.SessionPrice(),
+			Notes:             req.Notes,
+			PackagePurchaseID: &purchase.ID,
+		}
+	}
+
+	series := &model.AppointmentSeries{
+		StudentID:         studentID,
+		MentorID:          mentor.ID,
+		PackagePurchaseID: purchase.ID,
+		Rule:              rule,
+		Timezone:          loc.String(),
+	}
+	if err := s.appointmentRepo.BookPackageAppointments(ctx, appointments, mentor.BufferMinutes, series); err != nil {
+		return nil, bookingError(err, loc)
+	}
+
+	response := &model.CreateRecurringAppointmentResponse{
+		SeriesID:          series.ID,
+		Rule:              series.Rule,
+		Appointments:      make([]*model.CreateAppointmentResponse, len(appointments)),
+		RemainingSessions: purchase.RemainingSessions() - len(appointments),
+	}
+	for i, appointment := range appointments {
+		s.syncMeetingRoom(ctx, appointment)
+		response.Appointments[i] = &model.CreateAppointmentResponse{
+			AppointmentID: appointment.ID,
+			Status:        appointment.Status,
+			Price:         appointment.Price,
+		}
+	}
+	return response, nil
+}
+
+// RescheduleAppointment 学生或大师在预约开始前将待确认或已确认的预约改到新的时间，仅影响该次预约
+// 新时间需在大师可预约时段内且不冲突，套餐预约需在套餐有效期内；已确认的视频咨询按新时间重建会议室
+func (s *appointmentService) RescheduleAppointment(ctx context.Context, appointmentID, userID string, req *model.RescheduleAppointmentRequest) (*model.RescheduleAppointmentResponse, error) {
+	appointment, err := s.appointmentRepo.GetAppointmentByID(ctx, appointmentID)
+	if err != nil {
+		return nil, errors.New("预约不存在")
+	}
+	actor := appointmentActor(appointment, userID)
+	if actor == "" {
+		return nil, errors.New("无权操作该预约")
+	}
+	if appointment.Status != model.AppointmentStatusPending && appointment.Status != model.AppointmentStatusConfirmed {
+		return nil, errors.New("预约当前状态不能改期")
+	}
+	if !time.Now().Before(appointment.AppointmentTime) {
+		return nil, errors.New("预约已开始，不能改期")
+	}
+
+	newTime := req.AppointmentTime.UTC()
+	if newTime.Equal(appointment.AppointmentTime) {
+		return nil, errors.New("新的预约时间与原时间相同")
+	}
+	if err := s.availabilityService.CheckBookable(ctx, appointment.Mentor, newTime, appointment.DurationMinutes); err != nil {
+		return nil, err
+	}
+	if appointment.PackagePurchaseID != nil {
+		purchase, err := s.packageRepo.GetPurchaseByID(ctx, *appointment.PackagePurchaseID)
+		if err != nil {
+			return nil, err
+		}
+		if purchase.ExpiresAt != nil && !newTime.Before(*purchase.ExpiresAt) {
+			return nil, errors.New("预约时间超出套餐有效期")
+		}
+	}
+
+	loc := mentorLocation(appointment.Mentor)
+	reason := fmt.Sprintf("改期：%s → %s", appointment.AppointmentTime.In(loc).Format("2006-01-02 15:04"), newTime.In(loc).Format("2006-01-02 15:04"))
+	if req.Reason != "" {
+		reason += "，" + req.Reason
+	}
+	err = s.appointmentRepo.RescheduleAppointment(ctx, appointment, newTime, appointment.Mentor.BufferMinutes, &model.AppointmentStatusHistory{
+		ActorType: actor,
+		ActorID:   userID,
+		Reason:    reason,
+	})
+	if errors.Is(err, repository.ErrAppointmentChanged) {
+		return nil, errors.New("预约状态已变化，请刷新后重试")
+	}
+	if errors.Is(err, repository.ErrStudentTimeConflict) && actor == model.AppointmentActorMentor {
+		return nil, errors.New("学生在该时段已有其他预约")
+	}
+	if err != nil {
+		return nil, bookingError(err, loc)
+	}
+
+	appointment.AppointmentTime = newTime
+	if appointment.Status == model.AppointmentStatusConfirmed {
+		if err := s.meetingService.ResetRoom(ctx, appointment.ID); err != nil {
+			logger.Error("改期后重建会议室失败", logger.String("appointment_id", appointment.ID), logger.String("error", err.Error()))
+		}
+	}
+
+	return &model.RescheduleAppointmentResponse{
+		AppointmentID:   appointment.ID,
+		Status:          appointment.Status,
+		AppointmentTime: appointment.AppointmentTime,
+	}, nil
+}
+
+// usablePurchase 获取学生可用于预约该大师的已购买套餐
+func (s *appointmentService) usablePurchase(ctx context.Context, studentID, mentorID, purchaseID string) (*model.AppointmentPackagePurchase, error) {
+	purchase, err := s.packageRepo.GetPurchaseByID(ctx, purchaseID)
+	if err != nil || purchase.StudentID != studentID {
+		return nil, errors.New("套餐不存在")
+	}
+	if purchase.MentorID != mentorID {
+		return nil, errors.New("该套餐不能预约此大师")
+	}
+	if purchase.Status != model.PackagePurchaseStatusActive {
+		return nil, errors.New("套餐未支付或已失效")
+	}
+	if purchase.ExpiresAt != nil && !time.Now().Before(*purchase.ExpiresAt) {
+		return nil, errors.New("套餐已过期")
+	}
+	return purchase, nil
+}
+
 // GetAppointments 获取预约列表
 func (s *appointmentService) GetAppointments(ctx context.Context, userID string, req *model.AppointmentListRequest) (*model.AppointmentListResponse, error) {
 	appointments, total, err := s.appointmentRepo.GetAppointments(ctx, userID, req.Status, req.Type, req.Page, req.PageSize)
@@ -110,31 +364,168 @@ func (s *appointmentService) GetAppointmentDetail(ctx context.Context, appointme
 	}, nil
 }
 
-// UpdateAppointmentStatus 更新预约状态
-func (s *appointmentService) UpdateAppointmentStatus(ctx context.Context, appointmentID string, req *model.UpdateAppointmentStatusRequest) (*model.UpdateAppointmentStatusResponse, error) {
-	err := s.appointmentRepo.UpdateAppointmentStatus(ctx, appointmentID, req.Status)
+// UpdateAppointmentStatus 更新预约状态，按状态流转规则校验操作方和时间
+// 已支付的预约被取消时按退款策略自动退款或退回套餐次数；视频咨询确认后创建会议室，取消后关闭会议室
+func (s *appointmentService) UpdateAppointmentStatus(ctx context.Context, appointmentID, userID string, req *model.UpdateAppointmentStatusRequest) (*model.UpdateAppointmentStatusResponse, error) {
+	appointment, err := s.transition(ctx, appointmentID, userID, req.Status, req.Reason)
 	if err != nil {
 		return nil, err
 	}
+	s.syncMeetingRoom(ctx, appointment)
 
+	actor := appointmentActor(appointment, userID)
 	return &model.UpdateAppointmentStatusResponse{
-		AppointmentID: appointmentID,
-		Status:        req.Status,
+		AppointmentID:         appointment.ID,
+		Status:                appointment.Status,
+		Refund:                s.refundOnCancel(ctx, appointment, actor),
+		PackageCreditReturned: s.returnCreditOnCancel(ctx, appointment, actor),
 	}, nil
 }
 
-// CancelAppointment 取消预约
-func (s *appointmentService) CancelAppointment(ctx context.Context, appointmentID string) (*model.CancelAppointmentResponse, error) {
-	err := s.appointmentRepo.CancelAppointment(ctx, appointmentID)
+// CancelAppointment 学生或大师在预约开始前取消预约，已支付的预约按退款策略自动退款，套餐预约可全额退款时退回套餐次数
+func (s *appointmentService) CancelAppointment(ctx context.Context, appointmentID, userID, reason string) (*model.CancelAppointmentResponse, error) {
+	appointment, err := s.transition(ctx, appointmentID, userID, model.AppointmentStatusCancelled, reason)
 	if err != nil {
 		return nil, err
 	}
+	s.syncMeetingRoom(ctx, appointment)
 
+	actor := appointmentActor(appointment, userID)
 	return &model.CancelAppointmentResponse{
-		AppointmentID: appointmentID,
+		AppointmentID:         appointment.ID,
+		Status:                appointment.Status,
+		Refund:                s.refundOnCancel(ctx, appointment, actor),
+		PackageCreditReturned: s.returnCreditOnCancel(ctx, appointment, actor),
+	}, nil
+}
+
+// GetAppointmentStatusHistory 获取预约状态变更记录，仅预约双方可查看
+func (s *appointmentService) GetAppointmentStatusHistory(ctx context.Context, appointmentID, userID string) (*model.AppointmentStatusHistoryResponse, error) {
+	appointment, err := s.appointmentRepo.GetAppointmentByID(ctx, appointmentID)
+	if err != nil {
+		return nil, errors.New("预约不存在")
+	}
+	if appointmentActor(appointment, userID) == "" {
+		return nil, errors.New("无权操作该预约")
+	}
+
+	history, err := s.appointmentRepo.GetStatusHistory(ctx, appointment.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.AppointmentStatusHistoryResponse{
+		AppointmentID: appointment.ID,
+		History:       history,
 	}, nil
 }
 
+// transition 校验并执行预约状态变更，同时记录变更历史
+func (s *appointmentService) transition(ctx context.Context, appointmentID, userID, to, reason string) (*model.AppointmentModel, error) {
+	appointment, err := s.appointmentRepo.GetAppointmentByID(ctx, appointmentID)
+	if err != nil {
+		return nil, errors.New("预约不存在")
+	}
+
+	actor := appointmentActor(appointment, userID)
+	if actor == "" {
+		return nil, errors.New("无权操作该预约")
+	}
+	if err := checkAppointmentTransition(appointment, actor, to, reason, time.Now()); err != nil {
+		return nil, err
+	}
+
+	changed, err := s.appointmentRepo.TransitionStatus(ctx, appointment.ID, appointment.Status, &model.AppointmentStatusHistory{
+		ToStatus:  to,
+		ActorType: actor,
+		ActorID:   userID,
+		Reason:    reason,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !changed {
+		return nil, errors.New("预约状态已变化，请刷新后重试")
+	}
+
+	appointment.Status = to
+	return appointment, nil
+}
+
+// refundOnCancel 已支付的预约被拒绝或取消后按退款策略退款，未支付的预约和套餐预约返回 nil
+// 退款申请失败不影响状态变更，退款记录保留为 failed 由补偿任务重试；退款记录未能写入时返回不带退款单号的 failed 状态
+func (s *appointmentService) refundOnCancel(ctx context.Context, appointment *model.AppointmentModel, actor string) *model.CancellationRefund {
+	if appointment.Status != model.AppointmentStatusCancelled && appointment.Status != model.AppointmentStatusRejected {
+		return nil
+	}
+	if appointment.PackagePurchaseID != nil {
+		return nil
+	}
+
+	decision := s.refundPolicy.forAppointment(appointment, actor, appointment.Status, time.Now())
+	refund, err := s.paymentService.RefundOrderByRef(ctx, model.PaymentOrderTypeAppointment, appointment.ID, decision.Rate, decision.Rule)
+	if err != nil {
+		logger.Error("预约取消自动退款失败", logger.String("appointment_id", appointment.ID), logger.String("error", err.Error()))
+		return &model.CancellationRefund{Rule: decision.Rule, Rate: decision.Rate, Status: "failed"}
+	}
+	return cancellationRefund(decision, refund)
+}
+
+// returnCreditOnCancel 套餐预约被拒绝或取消后，按退款策略可全额退款时退回一次套餐次数
+// 退回失败不影响状态变更
+func (s *appointmentService) returnCreditOnCancel(ctx context.Context, appointment *model.AppointmentModel, actor string) bool {
+	if appointment.PackagePurchaseID == nil {
+		return false
+	}
+	if appointment.Status != model.AppointmentStatusCancelled && appointment.Status != model.AppointmentStatusRejected {
+		return false
+	}
+	if decision := s.refundPolicy.forAppointment(appointment, actor, appointment.Status, time.Now()); decision.Rate < 1 {
+		return false
+	}
+
+	returned, err := s.appointmentRepo.ReturnPackageCredit(ctx, appointment.ID)
+	if err != nil {
+		logger.Error("退回套餐次数失败", logger.String("appointment_id", appointment.ID), logger.String("error", err.Error()))
+		return false
+	}
+	return returned
+}
+
+// bookingError 将预约冲突和套餐扣减失败转换为提示信息
+func bookingError(err error, loc *time.Location) error {
+	var conflict *repository.BookingConflictError
+	if errors.As(err, &conflict) {
+		return &AppointmentConflictError{Time: conflict.Time.In(loc), Err: bookingError(conflict.Err, loc)}
+	}
+	switch {
+	case errors.Is(err, repository.ErrMentorTimeConflict):
+		return errors.New("该时段已被预约")
+	case errors.Is(err, repository.ErrStudentTimeConflict):
+		return errors.New("您在该时段已有其他预约")
+	case errors.Is(err, repository.ErrPackageUnavailable):
+		return errors.New("套餐未支付或已失效")
+	case errors.Is(err, repository.ErrPackageInsufficient):
+		return errors.New("套餐剩余次数不足")
+	case errors.Is(err, repository.ErrPackageExpired):
+		return errors.New("预约时间超出套餐有效期")
+	}
+	return err
+}
+
+// syncMeetingRoom 预约确认后创建会议室，取消后关闭会议室
+// 创建失败不影响状态变更，由补建任务重试或在入会时创建
+func (s *appointmentService) syncMeetingRoom(ctx context.Context, appointment *model.AppointmentModel) {
+	switch appointment.Status {
+	case model.AppointmentStatusConfirmed:
+		_ = s.meetingService.ProvisionRoom(ctx, appointment.ID)
+	case model.AppointmentStatusCancelled:
+		if err := s.meetingService.CloseRoom(ctx, appointment.ID); err != nil {
+			logger.Error("关闭会议室失败", logger.String("appointment_id", appointment.ID), logger.String("error", err.Error()))
+		}
+	}
+}
+
 // GetMentorAppointmentStats 获取大师预约统计
 func (s *appointmentService) GetMentorAppointmentStats(ctx context.Context, mentorID string) (*model.MentorAppointmentStatsResponse, error) {
 	stats, err := s.appointmentRepo.GetMentorAppointmentStats(ctx, mentorID)
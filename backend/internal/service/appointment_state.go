@@ -0,0 +1,112 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"master-guide-backend/internal/model"
+)
+
+// appointmentEarlyStart 预约开始前可提前进入进行中状态的时间
+const appointmentEarlyStart = 15 * time.Minute
+
+// appointmentTransitions 预约状态流转规则：当前状态 -> 目标状态 -> 允许的操作方
+var appointmentTransitions = map[string]map[string][]string{
+	model.AppointmentStatusPendingPayment: {
+		model.AppointmentStatusConfirmed: {model.AppointmentActorSystem},
+		model.AppointmentStatusCancelled: {model.AppointmentActorStudent, model.AppointmentActorSystem},
+	},
+	model.AppointmentStatusPending: {
+		model.AppointmentStatusConfirmed: {model.AppointmentActorMentor},
+		model.AppointmentStatusRejected:  {model.AppointmentActorMentor},
+		model.AppointmentStatusCancelled: {model.AppointmentActorStudent, model.AppointmentActorSystem},
+	},
+	model.AppointmentStatusConfirmed: {
+		model.AppointmentStatusInProgress: {model.AppointmentActorMentor, model.AppointmentActorSystem},
+		model.AppointmentStatusCancelled:  {model.AppointmentActorStudent, model.AppointmentActorMentor},
+		model.AppointmentStatusNoShow:     {model.AppointmentActorStudent, model.AppointmentActorMentor},
+	},
+	model.AppointmentStatusInProgress: {
+		model.AppointmentStatusCompleted: {model.AppointmentActorMentor, model.AppointmentActorSystem},
+	},
+}
+
+// AppointmentTransitionError 预约当前状态不允许变更为目标状态
+type AppointmentTransitionError struct {
+	From string
+	To   string
+}
+
+// Error 实现 error 接口
+func (e *AppointmentTransitionError) Error() string {
+	return fmt.Sprintf("预约状态不能从 %s 变更为 %s", e.From, e.To)
+}
+
+// AppointmentConflictError 重复预约中某一次预约与已有预约冲突
+type AppointmentConflictError struct {
+	Time time.Time // 冲突预约在大师时区的开始时间
+	Err  error
+}
+
+// Error 实现 error 接口
+func (e *AppointmentConflictError) Error() string {
+	return fmt.Sprintf("%s：%s", e.Time.Format("2006-01-02 15:04"), e.Err.Error())
+}
+
+// appointmentActor 判断用户在预约中的身份，非预约双方返回空
+func appointmentActor(appointment *model.AppointmentModel, userID string) string {
+	if userID == "" {
+		return ""
+	}
+	if appointment.StudentID == userID {
+		return model.AppointmentActorStudent
+	}
+	if appointment.Mentor != nil && appointment.Mentor.UserID == userID {
+		return model.AppointmentActorMentor
+	}
+	return ""
+}
+
+// checkAppointmentTransition 校验状态流转、操作方、时间和原因
+func checkAppointmentTransition(appointment *model.AppointmentModel, actor, to, reason string, now time.Time) error {
+	actors, ok := appointmentTransitions[appointment.Status][to]
+	if !ok {
+		return &AppointmentTransitionError{From: appointment.Status, To: to}
+	}
+
+	allowed := false
+	for _, a := range actors {
+		if a == actor {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return errors.New("无权执行该状态变更")
+	}
+
+	start := appointment.AppointmentTime
+	switch to {
+	case model.AppointmentStatusInProgress:
+		if now.Before(start.Add(-appointmentEarlyStart)) {
+			return errors.New("预约尚未开始")
+		}
+	case model.AppointmentStatusNoShow:
+		if now.Before(start) {
+			return errors.New("预约尚未开始")
+		}
+	case model.AppointmentStatusCancelled:
+		if actor != model.AppointmentActorSystem && !now.Before(start) {
+			return errors.New("预约已开始，不能取消")
+		}
+	}
+
+	reasonRequired := to == model.AppointmentStatusRejected ||
+		to == model.AppointmentStatusNoShow ||
+		(to == model.AppointmentStatusCancelled && actor == model.AppointmentActorMentor)
+	if reasonRequired && reason == "" {
+		return errors.New("请填写原因")
+	}
+	return nil
+}
@@ -3,42 +3,77 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/url"
 	"time"
 
 	"master-guide-backend/internal/model"
 	"master-guide-backend/internal/repository"
 	"master-guide-backend/internal/utils"
+	"master-guide-backend/pkg/cache"
+	"master-guide-backend/pkg/config"
+	"master-guide-backend/pkg/logger"
+	"master-guide-backend/pkg/sender"
 )
 
+// refreshTokenBytes 刷新令牌随机字节数
+const refreshTokenBytes = 32
+
 // AuthService 认证服务接口
 type AuthService interface {
-	Register(ctx context.Context, req *model.RegisterRequest) (*model.AuthResponse, error)
-	Login(ctx context.Context, req *model.LoginRequest) (*model.AuthResponse, error)
-	SwitchIdentity(ctx context.Context, userID, identityID string) (*model.AuthResponse, error)
-	RefreshToken(ctx context.Context, userID string) (*model.TokenResponse, error)
-	ChangePassword(ctx context.Context, userID, currentPassword, newPassword string) error
+	Register(ctx context.Context, req *model.RegisterRequest, client *model.ClientInfo) (*model.AuthResponse, error)
+	Login(ctx context.Context, req *model.LoginRequest, client *model.ClientInfo) (*model.AuthResponse, error)
+	SwitchIdentity(ctx context.Context, userID, sessionID, identityID string) (*model.AuthResponse, error)
+	RefreshToken(ctx context.Context, refreshToken string) (*model.TokenResponse, error)
+	Logout(ctx context.Context, sessionID string) error
+	ListSessions(ctx context.Context, userID, currentSessionID string) (*model.SessionListResponse, error)
+	RevokeSession(ctx context.Context, userID, sessionID string) error
+	IsSessionActive(ctx context.Context, sessionID string) (bool, error)
+	ChangePassword(ctx context.Context, userID, sessionID, currentPassword, newPassword string) error
+	ForgotPassword(ctx context.Context, email string, client *model.ClientInfo) error
+	ResetPassword(ctx context.Context, token, newPassword string, client *model.ClientInfo) error
 }
 
 // authService 认证服务实现
 type authService struct {
-	userRepo     repository.UserRepository
-	identityRepo repository.IdentityRepository
-	jwtSecret    string
-	jwtExpire    int
+	userRepo      repository.UserRepository
+	identityRepo  repository.IdentityRepository
+	sessionRepo   repository.SessionRepository
+	resetRepo     repository.PasswordResetRepository
+	auditRepo     repository.AuditRepository
+	sender        sender.Sender
+	jwtSecret     string
+	jwtExpire     int
+	refreshExpire int
+	resetConfig   config.PasswordResetConfig
+	loginGuard    *loginGuard
 }
 
 // NewAuthService 创建认证服务实例
-func NewAuthService(userRepo repository.UserRepository, identityRepo repository.IdentityRepository, jwtSecret string, jwtExpire int) AuthService {
+func NewAuthService(userRepo repository.UserRepository, identityRepo repository.IdentityRepository, sessionRepo repository.SessionRepository, resetRepo repository.PasswordResetRepository, auditRepo repository.AuditRepository, msgSender sender.Sender, jwtSecret string, jwtExpire, refreshExpire int, resetConfig config.PasswordResetConfig, loginConfig config.LoginProtectionConfig) AuthService {
+	if resetConfig.ExpireMinutes <= 0 {
+		resetConfig.ExpireMinutes = 30
+	}
+	if resetConfig.ResendInterval <= 0 {
+		resetConfig.ResendInterval = 60
+	}
 	return &authService{
-		userRepo:     userRepo,
-		identityRepo: identityRepo,
-		jwtSecret:    jwtSecret,
-		jwtExpire:    jwtExpire,
+		userRepo:      userRepo,
+		identityRepo:  identityRepo,
+		sessionRepo:   sessionRepo,
+		resetRepo:     resetRepo,
+		auditRepo:     auditRepo,
+		sender:        msgSender,
+		jwtSecret:     jwtSecret,
+		jwtExpire:     jwtExpire,
+		refreshExpire: refreshExpire,
+		resetConfig:   resetConfig,
+		loginGuard:    newLoginGuard(loginConfig),
 	}
 }
 
 // Register 用户注册
-func (s *authService) Register(ctx context.Context, req *model.RegisterRequest) (*model.AuthResponse, error) {
+func (s *authService) Register(ctx context.Context, req *model.RegisterRequest, client *model.ClientInfo) (*model.AuthResponse, error) {
 	// 检查邮箱是否已存在
 	exists, err := s.userRepo.ExistsByEmail(ctx, req.Email)
 	if err != nil {
@@ -76,47 +111,47 @@ func (s *authService) Register(ctx context.Context, req *model.RegisterRequest)
 		return nil, err
 	}
 
-	// 生成Token
-	token, err := utils.GenerateToken(user.ID, identity.ID, s.jwtSecret, s.jwtExpire)
+	// 创建会话并生成Token
+	response, err := s.startSession(ctx, user.ID, identity, client)
 	if err != nil {
 		return nil, err
 	}
 
-	// 构建响应
-	response := &model.AuthResponse{
-		UserID: user.ID,
-		Token:  token,
-		CurrentIdentity: &model.IdentityInfo{
+	response.Identities = []model.IdentityInfo{
+		{
 			ID:           identity.ID,
 			IdentityType: identity.IdentityType,
 			Domain:       identity.Domain,
 			Status:       identity.Status,
 		},
-		Identities: []model.IdentityInfo{
-			{
-				ID:           identity.ID,
-				IdentityType: identity.IdentityType,
-				Domain:       identity.Domain,
-				Status:       identity.Status,
-			},
-		},
 	}
 
 	return response, nil
 }
 
 // Login 用户登录
-func (s *authService) Login(ctx context.Context, req *model.LoginRequest) (*model.AuthResponse, error) {
+func (s *authService) Login(ctx context.Context, req *model.LoginRequest, client *model.ClientInfo) (*model.AuthResponse, error) {
+	clientIP := ""
+	if client != nil {
+		clientIP = client.IPAddress
+	}
+
+	// 账户或IP处于锁定期时直接拒绝
+	if err := s.loginGuard.check(ctx, req.Email, clientIP); err != nil {
+		return nil, err
+	}
+
 	// 获取用户
 	user, err := s.userRepo.GetByEmail(ctx, req.Email)
 	if err != nil {
-		return nil, errors.New("用户名或密码错误")
+		return nil, s.loginFailed(ctx, req.Email, clientIP, nil)
 	}
 
 	// 验证密码
 	if !utils.CheckPassword(req.Password, user.PasswordHash) {
-		return nil, errors.New("用户名或密码错误")
+		return nil, s.loginFailed(ctx, req.Email, clientIP, user)
 	}
+	s.loginGuard.reset(ctx, req.Email)
 
 	// 检查用户状态
 	if user.Status != "active" {
@@ -153,8 +188,8 @@ func (s *authService) Login(ctx context.Context, req *model.LoginRequest) (*mode
 		return nil, err
 	}
 
-	// 生成Token
-	token, err := utils.GenerateToken(user.ID, currentIdentity.ID, s.jwtSecret, s.jwtExpire)
+	// 创建会话并生成Token
+	response, err := s.startSession(ctx, user.ID, currentIdentity, client)
 	if err != nil {
 		return nil, err
 	}
@@ -169,25 +204,13 @@ func (s *authService) Login(ctx context.Context, req *model.LoginRequest) (*mode
 			Status:       identity.Status,
 		}
 	}
-
-	// 构建响应
-	response := &model.AuthResponse{
-		UserID: user.ID,
-		Token:  token,
-		CurrentIdentity: &model.IdentityInfo{
-			ID:           currentIdentity.ID,
-			IdentityType: currentIdentity.IdentityType,
-			Domain:       currentIdentity.Domain,
-			Status:       currentIdentity.Status,
-		},
-		Identities: identityInfos,
-	}
+	response.Identities = identityInfos
 
 	return response, nil
 }
 
 // SwitchIdentity 身份切换
-func (s *authService) SwitchIdentity(ctx context.Context, userID, identityID string) (*model.AuthResponse, error) {
+func (s *authService) SwitchIdentity(ctx context.Context, userID, sessionID, identityID string) (*model.AuthResponse, error) {
 	// 获取身份
 	identity, err := s.identityRepo.GetByID(ctx, identityID)
 	if err != nil {
@@ -204,16 +227,23 @@ func (s *authService) SwitchIdentity(ctx context.Context, userID, identityID str
 		return nil, errors.New("身份未激活")
 	}
 
+	// 会话记录当前身份，后续刷新Token沿用该身份
+	if err := s.sessionRepo.UpdateSessionIdentity(ctx, sessionID, identity.ID); err != nil {
+		return nil, err
+	}
+
 	// 生成新Token
-	token, err := utils.GenerateToken(userID, identity.ID, s.jwtSecret, s.jwtExpire)
+	token, err := utils.GenerateToken(userID, identity.ID, identity.IdentityType, sessionID, s.jwtSecret, s.jwtExpire)
 	if err != nil {
 		return nil, err
 	}
 
 	// 构建响应
 	response := &model.AuthResponse{
-		UserID: userID,
-		Token:  token,
+		UserID:    userID,
+		Token:     token,
+		ExpiresIn: s.jwtExpire * 3600,
+		SessionID: sessionID,
 		CurrentIdentity: &model.IdentityInfo{
 			ID:           identity.ID,
 			IdentityType: identity.IdentityType,
@@ -225,10 +255,31 @@ func (s *authService) SwitchIdentity(ctx context.Context, userID, identityID str
 	return response, nil
 }
 
-// RefreshToken 刷新Token
-func (s *authService) RefreshToken(ctx context.Context, userID string) (*model.TokenResponse, error) {
+// RefreshToken 使用刷新令牌换取新的Token对，旧刷新令牌随即失效
+func (s *authService) RefreshToken(ctx context.Context, refreshToken string) (*model.TokenResponse, error) {
+	newRefreshToken, err := utils.GenerateRandomToken(refreshTokenBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	next := &model.RefreshToken{
+		TokenHash: utils.HashToken(newRefreshToken),
+		ExpiresAt: time.Now().Add(time.Duration(s.refreshExpire) * time.Hour),
+	}
+
+	session, err := s.sessionRepo.RotateRefreshToken(ctx, utils.HashToken(refreshToken), next)
+	if errors.Is(err, repository.ErrRefreshTokenReused) {
+		return nil, errors.New("刷新令牌已被使用，会话已注销")
+	}
+	if errors.Is(err, repository.ErrRefreshTokenInvalid) {
+		return nil, errors.New("刷新令牌无效或已过期")
+	}
+	if err != nil {
+		return nil, err
+	}
+
 	// 获取用户
-	user, err := s.userRepo.GetByID(ctx, userID)
+	user, err := s.userRepo.GetByID(ctx, session.UserID)
 	if err != nil {
 		return nil, errors.New("用户不存在")
 	}
@@ -238,35 +289,71 @@ func (s *authService) RefreshToken(ctx context.Context, userID string) (*model.T
 		return nil, errors.New("账户已被禁用")
 	}
 
-	// 获取用户第一个活跃身份
-	identities, err := s.identityRepo.GetByUserID(ctx, userID)
+	// 沿用会话当前身份
+	identity, err := s.identityRepo.GetByID(ctx, session.IdentityID)
+	if err != nil || identity.Status != "active" {
+		return nil, errors.New("用户没有活跃身份")
+	}
+
+	// 生成新Token
+	token, err := utils.GenerateToken(user.ID, identity.ID, identity.IdentityType, session.ID, s.jwtSecret, s.jwtExpire)
 	if err != nil {
 		return nil, err
 	}
 
-	var currentIdentity *model.UserIdentity
-	for _, identity := range identities {
-		if identity.Status == "active" {
-			currentIdentity = identity
-			break
-		}
-	}
+	return &model.TokenResponse{
+		Token:        token,
+		RefreshToken: newRefreshToken,
+		ExpiresIn:    s.jwtExpire * 3600,
+	}, nil
+}
 
-	if currentIdentity == nil {
-		return nil, errors.New("用户没有活跃身份")
-	}
+// Logout 退出登录，注销当前会话
+func (s *authService) Logout(ctx context.Context, sessionID string) error {
+	return s.sessionRepo.RevokeSession(ctx, sessionID, "logout")
+}
 
-	// 生成新Token
-	token, err := utils.GenerateToken(userID, currentIdentity.ID, s.jwtSecret, s.jwtExpire)
+// ListSessions 获取用户的有效登录会话
+func (s *authService) ListSessions(ctx context.Context, userID, currentSessionID string) (*model.SessionListResponse, error) {
+	sessions, err := s.sessionRepo.ListActiveSessions(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
 
-	return &model.TokenResponse{Token: token}, nil
+	sessionInfos := make([]model.SessionInfo, len(sessions))
+	for i, session := range sessions {
+		sessionInfos[i] = model.SessionInfo{
+			ID:         session.ID,
+			IdentityID: session.IdentityID,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+			CreatedAt:  session.CreatedAt,
+			IsCurrent:  session.ID == currentSessionID,
+		}
+	}
+
+	return &model.SessionListResponse{Sessions: sessionInfos}, nil
 }
 
-// ChangePassword 修改密码
-func (s *authService) ChangePassword(ctx context.Context, userID, currentPassword, newPassword string) error {
+// RevokeSession 注销用户的指定会话（如移除登录设备）
+func (s *authService) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	session, err := s.sessionRepo.GetSessionByID(ctx, sessionID)
+	if err != nil || session.UserID != userID {
+		return errors.New("会话不存在")
+	}
+
+	return s.sessionRepo.RevokeSession(ctx, sessionID, "revoked_by_user")
+}
+
+// IsSessionActive 检查会话是否有效
+func (s *authService) IsSessionActive(ctx context.Context, sessionID string) (bool, error) {
+	return s.sessionRepo.IsSessionActive(ctx, sessionID)
+}
+
+// ChangePassword 修改密码，并注销当前会话之外的所有会话
+func (s *authService) ChangePassword(ctx context.Context, userID, sessionID, currentPassword, newPassword string) error {
 	// 获取用户
 	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
@@ -286,5 +373,195 @@ func (s *authService) ChangePassword(ctx context.Context, userID, currentPasswor
 
 	// 更新密码
 	user.PasswordHash = newPasswordHash
-	return s.userRepo.Update(ctx, user)
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+
+	return s.sessionRepo.RevokeUserSessions(ctx, userID, sessionID, "password_changed")
+}
+
+// ForgotPassword 忘记密码，向注册邮箱发送重置链接
+// 无论邮箱是否存在均返回成功，避免泄露账户是否注册
+func (s *authService) ForgotPassword(ctx context.Context, email string, client *model.ClientInfo) error {
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil || user.Status != "active" {
+		return nil
+	}
+
+	// 同一用户限制发送频率
+	cooldownKey := "password_reset:cooldown:" + user.ID
+	ok, err := cache.SetNX(ctx, cooldownKey, 1, time.Duration(s.resetConfig.ResendInterval)*time.Second)
+	if err == nil && !ok {
+		return nil
+	}
+
+	resetToken, err := utils.GenerateRandomToken(refreshTokenBytes)
+	if err != nil {
+		return err
+	}
+
+	record := &model.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(resetToken),
+		ExpiresAt: time.Now().Add(time.Duration(s.resetConfig.ExpireMinutes) * time.Minute),
+	}
+	if client != nil {
+		record.RequestIP = client.IPAddress
+	}
+	if err := s.resetRepo.CreateToken(ctx, record); err != nil {
+		return err
+	}
+
+	s.audit(ctx, user.ID, "password_reset_tokens", record.ID, model.AuditOperationCreate, model.JSONMap{"action": "password_reset_requested"}, client)
+
+	msg := &sender.Message{
+		Channel: sender.ChannelEmail,
+		To:      user.Email,
+		Subject: "Master Guide 重置密码",
+		Content: fmt.Sprintf("请点击以下链接重置密码，链接 %d 分钟内有效且只能使用一次：\n%s\n如非本人操作请忽略此邮件。", s.resetConfig.ExpireMinutes, s.resetLink(resetToken)),
+	}
+	if err := s.sender.Send(ctx, msg); err != nil {
+		logger.Error("发送重置密码邮件失败", logger.String("user_id", user.ID), logger.String("error", err.Error()))
+	}
+
+	return nil
+}
+
+// ResetPassword 使用重置令牌设置新密码，并注销该用户的全部登录会话
+func (s *authService) ResetPassword(ctx context.Context, token, newPassword string, client *model.ClientInfo) error {
+	record, err := s.resetRepo.ConsumeToken(ctx, utils.HashToken(token))
+	if errors.Is(err, repository.ErrPasswordResetTokenInvalid) {
+		return errors.New("重置令牌无效或已过期")
+	}
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, record.UserID)
+	if err != nil || user.Status != "active" {
+		return errors.New("重置令牌无效或已过期")
+	}
+
+	newPasswordHash, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	user.PasswordHash = newPasswordHash
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+
+	if err := s.sessionRepo.RevokeUserSessions(ctx, user.ID, "", "password_reset"); err != nil {
+		return err
+	}
+
+	// 重置密码同时解除登录锁定
+	s.loginGuard.unlock(ctx, user.Email)
+
+	s.audit(ctx, user.ID, "users", user.ID, model.AuditOperationUpdate, model.JSONMap{"action": "password_reset", "reset_token_id": record.ID}, client)
+
+	return nil
+}
+
+// loginFailed 记录登录失败并按失败次数延迟响应，达到阈值时锁定账户并邮件提醒用户
+func (s *authService) loginFailed(ctx context.Context, email, clientIP string, user *model.User) error {
+	failures, lockErr := s.loginGuard.recordFailure(ctx, email, clientIP)
+	if lockErr != nil {
+		if lockErr.Scope == LoginLockScopeAccount && user != nil {
+			logger.Warn("登录失败次数过多，账户已临时锁定", logger.String("user_id", user.ID), logger.String("ip", clientIP))
+			msg := &sender.Message{
+				Channel: sender.ChannelEmail,
+				To:      user.Email,
+				Subject: "Master Guide 账户安全提醒",
+				Content: fmt.Sprintf("检测到您的账户多次登录失败，已临时锁定 %d 分钟。如非本人操作，建议立即通过找回密码重置密码，重置成功后账户将自动解锁。", int(lockErr.RetryAfter.Minutes())),
+			}
+			if err := s.sender.Send(ctx, msg); err != nil {
+				logger.Error("发送账户锁定提醒失败", logger.String("user_id", user.ID), logger.String("error", err.Error()))
+			}
+		}
+		return lockErr
+	}
+
+	s.loginGuard.delay(ctx, failures)
+	return errors.New("用户名或密码错误")
+}
+
+// resetLink 生成密码重置链接
+func (s *authService) resetLink(token string) string {
+	link, err := url.Parse(s.resetConfig.ResetURL)
+	if err != nil {
+		return s.resetConfig.ResetURL + "?token=" + url.QueryEscape(token)
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String()
+}
+
+// audit 写入审计日志，失败仅记录日志不影响主流程
+func (s *authService) audit(ctx context.Context, userID, table, recordID, operation string, newValues model.JSONMap, client *model.ClientInfo) {
+	entry := &model.AuditLog{
+		UserID:    &userID,
+		Table:     table,
+		RecordID:  recordID,
+		Operation: operation,
+		NewValues: newValues,
+	}
+	if client != nil {
+		if client.IPAddress != "" {
+			entry.IPAddress = &client.IPAddress
+		}
+		entry.UserAgent = client.UserAgent
+	}
+	if err := s.auditRepo.Create(ctx, entry); err != nil {
+		logger.Error("写入审计日志失败", logger.String("table_name", table), logger.String("record_id", recordID), logger.String("error", err.Error()))
+	}
+}
+
+// startSession 创建登录会话并签发访问令牌与刷新令牌
+func (s *authService) startSession(ctx context.Context, userID string, identity *model.UserIdentity, client *model.ClientInfo) (*model.AuthResponse, error) {
+	refreshToken, err := utils.GenerateRandomToken(refreshTokenBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(time.Duration(s.refreshExpire) * time.Hour)
+	session := &model.AuthSession{
+		UserID:     userID,
+		IdentityID: identity.ID,
+		LastUsedAt: now,
+		ExpiresAt:  expiresAt,
+	}
+	if client != nil {
+		session.UserAgent = client.UserAgent
+		session.IPAddress = client.IPAddress
+	}
+	token := &model.RefreshToken{
+		TokenHash: utils.HashToken(refreshToken),
+		ExpiresAt: expiresAt,
+	}
+	if err := s.sessionRepo.CreateSession(ctx, session, token); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := utils.GenerateToken(userID, identity.ID, identity.IdentityType, session.ID, s.jwtSecret, s.jwtExpire)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.AuthResponse{
+		UserID:       userID,
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    s.jwtExpire * 3600,
+		SessionID:    session.ID,
+		CurrentIdentity: &model.IdentityInfo{
+			ID:           identity.ID,
+			IdentityType: identity.IdentityType,
+			Domain:       identity.Domain,
+			Status:       identity.Status,
+		},
+	}, nil
 }
@@ -0,0 +1,424 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // 容器镜像可能不带时区数据库
+
+	"master-guide-backend/internal/model"
+	"master-guide-backend/internal/repository"
+)
+
+const (
+	defaultMentorTimezone   = "Asia/Shanghai"
+	availabilitySlotStep    = 30 * time.Minute // 可预约时段的起始时间粒度
+	availabilityDefaultDays = 14               // 默认查询天数
+	availabilityMaxDays     = 31               // 单次最多查询天数
+	appointmentMinNotice    = time.Hour        // 预约需提前的最短时间
+	defaultSlotDuration     = 60               // 默认预约时长（分钟）
+)
+
+// AvailabilityService 大师可预约时间服务接口
+type AvailabilityService interface {
+	GetAvailability(ctx context.Context, mentorID string) (*model.AvailabilityResponse, error)
+	UpdateAvailability(ctx context.Context, mentorID string, req *model.UpdateAvailabilityRequest) (*model.AvailabilityResponse, error)
+	CreateException(ctx context.Context, mentorID string, req *model.CreateAvailabilityExceptionRequest) (*model.MentorAvailabilityException, error)
+	DeleteException(ctx context.Context, mentorID, exceptionID string) error
+	ListAvailableSlots(ctx context.Context, mentorID string, req *model.AvailableSlotsRequest) (*model.AvailableSlotsResponse, error)
+	CheckBookable(ctx context.Context, mentor *model.Mentor, start time.Time, durationMinutes int) error
+}
+
+// availabilityService 大师可预约时间服务实现
+type availabilityService struct {
+	availabilityRepo repository.AvailabilityRepository
+	mentorRepo       repository.MentorRepository
+	appointmentRepo  repository.AppointmentRepository
+}
+
+// NewAvailabilityService 创建大师可预约时间服务实例
+func NewAvailabilityService(availabilityRepo repository.AvailabilityRepository, mentorRepo repository.MentorRepository, appointmentRepo repository.AppointmentRepository) AvailabilityService {
+	return &availabilityService{
+		availabilityRepo: availabilityRepo,
+		mentorRepo:       mentorRepo,
+		appointmentRepo:  appointmentRepo,
+	}
+}
+
+// timeRange 左闭右开的时间区间
+type timeRange struct {
+	start time.Time
+	end   time.Time
+}
+
+// GetAvailability 获取大师可预约时间设置
+func (s *availabilityService) GetAvailability(ctx context.Context, mentorID string) (*model.AvailabilityResponse, error) {
+	mentor, err := s.mentorRepo.GetMentorByID(ctx, mentorID)
+	if err != nil {
+		return nil, errors.New("大师不存在")
+	}
+
+	rules, err := s.availabilityRepo.GetRules(ctx, mentor.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	today := startOfDay(time.Now().In(mentorLocation(mentor)))
+	exceptions, err := s.availabilityRepo.ListExceptions(ctx, mentor.ID, today, today.AddDate(1, 0, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.AvailabilityResponse{
+		MentorID:      mentor.ID,
+		Timezone:      mentorLocation(mentor).String(),
+		BufferMinutes: mentor.BufferMinutes,
+		Rules:         rules,
+		Exceptions:    exceptions,
+	}, nil
+}
+
+// UpdateAvailability 设置时区、预约间隔和每周可预约时段
+func (s *availabilityService) UpdateAvailability(ctx context.Context, mentorID string, req *model.UpdateAvailabilityRequest) (*model.AvailabilityResponse, error) {
+	if _, err := time.LoadLocation(req.Timezone); err != nil || req.Timezone == "" || req.Timezone == "Local" {
+		return nil, errors.New("时区不合法")
+	}
+
+	rules := make([]*model.MentorAvailabilityRule, 0, len(req.Rules))
+	byWeekday := make(map[int][][2]int)
+	for _, input := range req.Rules {
+		start, end, err := parseClockRange(input.StartTime, input.EndTime)
+		if err != nil {
+			return nil, err
+		}
+		for _, existing := range byWeekday[input.Weekday] {
+			if start < existing[1] && existing[0] < end {
+				return nil, errors.New("可预约时段存在重叠")
+			}
+		}
+		byWeekday[input.Weekday] = append(byWeekday[input.Weekday], [2]int{start, end})
+		rules = append(rules, &model.MentorAvailabilityRule{
+			MentorID:  mentorID,
+			Weekday:   input.Weekday,
+			StartTime: formatClock(start),
+			EndTime:   formatClock(end),
+		})
+	}
+
+	if err := s.availabilityRepo.ReplaceRules(ctx, mentorID, req.Timezone, req.BufferMinutes, rules); err != nil {
+		return nil, err
+	}
+	return s.GetAvailability(ctx, mentorID)
+}
+
+// CreateException 添加请假或加开时段
+func (s *availabilityService) CreateException(ctx context.Context, mentorID string, req *model.CreateAvailabilityExceptionRequest) (*model.MentorAvailabilityException, error) {
+	mentor, err := s.mentorRepo.GetMentorByID(ctx, mentorID)
+	if err != nil {
+		return nil, errors.New("大师不存在")
+	}
+
+	loc := mentorLocation(mentor)
+	date, err := time.ParseInLocation("2006-01-02", req.Date, loc)
+	if err != nil {
+		return nil, errors.New("日期格式错误")
+	}
+	if date.Before(startOfDay(time.Now().In(loc))) {
+		return nil, errors.New("不能修改过去日期的可预约时间")
+	}
+
+	exception := &model.MentorAvailabilityException{
+		MentorID:  mentor.ID,
+		Date:      time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC),
+		Available: req.Available,
+		Reason:    req.Reason,
+	}
+	if req.StartTime != "" || req.EndTime != "" {
+		start, end, err := parseClockRange(req.StartTime, req.EndTime)
+		if err != nil {
+			return nil, err
+		}
+		exception.StartTime = formatClock(start)
+		exception.EndTime = formatClock(end)
+	} else if req.Available {
+		return nil, errors.New("加开时段需指定开始和结束时间")
+	}
+
+	if err := s.availabilityRepo.CreateException(ctx, exception); err != nil {
+		return nil, err
+	}
+	return exception, nil
+}
+
+// DeleteException 删除可预约时间例外
+func (s *availabilityService) DeleteException(ctx context.Context, mentorID, exceptionID string) error {
+	deleted, err := s.availabilityRepo.DeleteException(ctx, mentorID, exceptionID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errors.New("可预约时间例外不存在")
+	}
+	return nil
+}
+
+// ListAvailableSlots 按大师的每周时段、例外、预约间隔和已有预约计算可预约时段
+func (s *availabilityService) ListAvailableSlots(ctx context.Context, mentorID string, req *model.AvailableSlotsRequest) (*model.AvailableSlotsResponse, error) {
+	mentor, err := s.mentorRepo.GetMentorByID(ctx, mentorID)
+	if err != nil {
+		return nil, errors.New("大师不存在")
+	}
+
+	durationMinutes := req.DurationMinutes
+	if durationMinutes == 0 {
+		durationMinutes = defaultSlotDuration
+	}
+	if durationMinutes < 15 || durationMinutes > 480 {
+		return nil, errors.New("预约时长不合法")
+	}
+	duration := time.Duration(durationMinutes) * time.Minute
+
+	loc := mentorLocation(mentor)
+	fromDay := startOfDay(time.Now().In(loc))
+	if req.From != "" {
+		if fromDay, err = time.ParseInLocation("2006-01-02", req.From, loc); err != nil {
+			return nil, errors.New("日期格式错误")
+		}
+	}
+	toDay := fromDay.AddDate(0, 0, availabilityDefaultDays-1)
+	if req.To != "" {
+		if toDay, err = time.ParseInLocation("2006-01-02", req.To, loc); err != nil {
+			return nil, errors.New("日期格式错误")
+		}
+	}
+	if toDay.Before(fromDay) || toDay.After(fromDay.AddDate(0, 0, availabilityMaxDays-1)) {
+		return nil, errors.New("查询日期范围不能超过31天")
+	}
+
+	windows, err := s.availableWindows(ctx, mentor, fromDay, toDay)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := time.Duration(mentor.BufferMinutes) * time.Minute
+	busy, err := s.appointmentRepo.ListMentorBusyAppointments(ctx, mentor.ID, fromDay.Add(-buffer).UTC(), toDay.AddDate(0, 0, 1).Add(buffer).UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	earliest := time.Now().Add(appointmentMinNotice)
+	slots := make([]*model.AvailableSlot, 0)
+	for _, window := range windows {
+		for start := window.start; !start.Add(duration).After(window.end); start = start.Add(availabilitySlotStep) {
+			end := start.Add(duration)
+			if start.Before(earliest) || overlapsBusy(busy, start, end, buffer) {
+				continue
+			}
+			slots = append(slots, &model.AvailableSlot{StartTime: start, EndTime: end})
+		}
+	}
+
+	return &model.AvailableSlotsResponse{
+		MentorID:        mentor.ID,
+		Timezone:        loc.String(),
+		DurationMinutes: durationMinutes,
+		Slots:           slots,
+	}, nil
+}
+
+// CheckBookable 校验预约时间是否落在大师的可预约时段内，与已有预约的冲突在创建时加锁检查
+func (s *availabilityService) CheckBookable(ctx context.Context, mentor *model.Mentor, start time.Time, durationMinutes int) error {
+	if start.Before(time.Now().Add(appointmentMinNotice)) {
+		return errors.New("预约时间需至少提前1小时")
+	}
+
+	end := start.Add(time.Duration(durationMinutes) * time.Minute)
+	loc := mentorLocation(mentor)
+	windows, err := s.availableWindows(ctx, mentor, startOfDay(start.In(loc)), startOfDay(end.In(loc)))
+	if err != nil {
+		return err
+	}
+	for _, window := range windows {
+		if !start.Before(window.start) && !end.After(window.end) {
+			return nil
+		}
+	}
+	return errors.New("所选时间不在大师可预约时段内")
+}
+
+// availableWindows 计算 fromDay 到 toDay（含）每天的可预约区间，相邻区间合并，以支持跨零点的预约
+func (s *availabilityService) availableWindows(ctx context.Context, mentor *model.Mentor, fromDay, toDay time.Time) ([]timeRange, error) {
+	rules, err := s.availabilityRepo.GetRules(ctx, mentor.ID)
+	if err != nil {
+		return nil, err
+	}
+	exceptions, err := s.availabilityRepo.ListExceptions(ctx, mentor.ID, fromDay, toDay)
+	if err != nil {
+		return nil, err
+	}
+
+	var windows []timeRange
+	for day := fromDay; !day.After(toDay); day = day.AddDate(0, 0, 1) {
+		windows = append(windows, dayWindows(day, rules, exceptions)...)
+	}
+	return mergeRanges(windows), nil
+}
+
+// dayWindows 计算某一天的可预约区间：每周时段加上加开时段，再扣除请假时段
+func dayWindows(day time.Time, rules []*model.MentorAvailabilityRule, exceptions []*model.MentorAvailabilityException) []timeRange {
+	var windows []timeRange
+	for _, rule := range rules {
+		if rule.Weekday != int(day.Weekday()) {
+			continue
+		}
+		if window, ok := clockRangeOn(day, rule.StartTime, rule.EndTime); ok {
+			windows = append(windows, window)
+		}
+	}
+
+	dateKey := day.Format("2006-01-02")
+	var blocked []timeRange
+	for _, exception := range exceptions {
+		if exception.Date.Format("2006-01-02") != dateKey {
+			continue
+		}
+		window := timeRange{start: day, end: day.AddDate(0, 0, 1)}
+		if exception.StartTime != "" {
+			var ok bool
+			if window, ok = clockRangeOn(day, exception.StartTime, exception.EndTime); !ok {
+				continue
+			}
+		}
+		if exception.Available {
+			windows = append(windows, window)
+		} else {
+			blocked = append(blocked, window)
+		}
+	}
+
+	windows = mergeRanges(windows)
+	for _, block := range blocked {
+		windows = subtractRange(windows, block)
+	}
+	return windows
+}
+
+// overlapsBusy 判断时段是否与已有预约冲突，已有预约前后需留出预约间隔
+func overlapsBusy(busy []*model.AppointmentModel, start, end time.Time, buffer time.Duration) bool {
+	for _, appointment := range busy {
+		busyStart := appointment.AppointmentTime
+		busyEnd := busyStart.Add(time.Duration(appointment.DurationMinutes) * time.Minute)
+		if start.Before(busyEnd.Add(buffer)) && busyStart.Before(end.Add(buffer)) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeRanges 排序并合并重叠或相邻的区间
+func mergeRanges(ranges []timeRange) []timeRange {
+	if len(ranges) == 0 {
+		return ranges
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start.Before(ranges[j].start) })
+	merged := []timeRange{ranges[0]}
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r.start.After(last.end) {
+			merged = append(merged, r)
+			continue
+		}
+		if r.end.After(last.end) {
+			last.end = r.end
+		}
+	}
+	return merged
+}
+
+// subtractRange 从区间列表中扣除 block
+func subtractRange(ranges []timeRange, block timeRange) []timeRange {
+	result := make([]timeRange, 0, len(ranges))
+	for _, r := range ranges {
+		if !block.start.Before(r.end) || !r.start.Before(block.end) {
+			result = append(result, r)
+			continue
+		}
+		if r.start.Before(block.start) {
+			result = append(result, timeRange{start: r.start, end: block.start})
+		}
+		if block.end.Before(r.end) {
+			result = append(result, timeRange{start: block.end, end: r.end})
+		}
+	}
+	return result
+}
+
+// clockRangeOn 将 HH:MM 时间段换算为某一天的绝对时间
+func clockRangeOn(day time.Time, startClock, endClock string) (timeRange, bool) {
+	start, end, err := parseClockRange(startClock, endClock)
+	if err != nil {
+		return timeRange{}, false
+	}
+	return timeRange{
+		start: time.Date(day.Year(), day.Month(), day.Day(), 0, start, 0, 0, day.Location()),
+		end:   time.Date(day.Year(), day.Month(), day.Day(), 0, end, 0, 0, day.Location()),
+	}, true
+}
+
+// parseClockRange 解析 HH:MM 时间段，返回当天的分钟数
+func parseClockRange(startClock, endClock string) (int, int, error) {
+	start, err := parseClock(startClock)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parseClock(endClock)
+	if err != nil {
+		return 0, 0, err
+	}
+	if start >= end {
+		return 0, 0, errors.New("可预约时段结束时间需晚于开始时间")
+	}
+	return start, end, nil
+}
+
+// parseClock 解析 HH:MM，允许 24:00 表示当天结束
+func parseClock(clock string) (int, error) {
+	parts := strings.Split(clock, ":")
+	if len(parts) != 2 || len(parts[0]) != 2 || len(parts[1]) != 2 {
+		return 0, errors.New("时间格式错误，应为 HH:MM")
+	}
+	hour, err1 := strconv.Atoi(parts[0])
+	minute, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || hour < 0 || hour > 24 || minute < 0 || minute > 59 || (hour == 24 && minute != 0) {
+		return 0, errors.New("时间格式错误，应为 HH:MM")
+	}
+	return hour*60 + minute, nil
+}
+
+// formatClock 将分钟数格式化为 HH:MM
+func formatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// startOfDay 返回同一时区当天零点
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// mentorLocation 获取大师所在时区，未设置或无效时使用默认时区
+func mentorLocation(mentor *model.Mentor) *time.Location {
+	if mentor.Timezone != "" {
+		if loc, err := time.LoadLocation(mentor.Timezone); err == nil {
+			return loc
+		}
+	}
+	loc, err := time.LoadLocation(defaultMentorTimezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
@@ -0,0 +1,303 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"master-guide-backend/internal/model"
+	"master-guide-backend/internal/repository"
+	"master-guide-backend/internal/utils"
+	"master-guide-backend/pkg/config"
+	"master-guide-backend/pkg/ical"
+	"master-guide-backend/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// calendarFeedTokenBytes 日历订阅令牌的随机字节数
+const calendarFeedTokenBytes = 32
+
+// calendarProdID 日历文件的产品标识
+const calendarProdID = "-//Master Guide//Appointments//ZH"
+
+// calendarStudentLabel 大师日历事件中学员的显示名称
+const calendarStudentLabel = "学员"
+
+// meetingTypeNames 预约方式的显示名称
+var meetingTypeNames = map[string]string{
+	model.MeetingTypeVideo: "视频咨询",
+	model.MeetingTypeVoice: "语音咨询",
+	model.MeetingTypeText:  "文字咨询",
+}
+
+// CalendarService 日历订阅和预约提醒服务接口
+type CalendarService interface {
+	CreateFeed(ctx context.Context, userID string) (*model.CalendarFeedResponse, error)
+	DeleteFeed(ctx context.Context, userID string) error
+	GetFeed(ctx context.Context, token string) ([]byte, error)
+	GetAppointmentICS(ctx context.Context, appointmentID, userID string) ([]byte, error)
+	SendDueReminders(ctx context.Context, limit int) (int, error)
+}
+
+// calendarService 日历订阅和预约提醒服务实现
+type calendarService struct {
+	calendarRepo        repository.CalendarRepository
+	appointmentRepo     repository.AppointmentRepository
+	notificationService NotificationService
+	config              config.CalendarConfig
+}
+
+// NewCalendarService 创建日历订阅和预约提醒服务实例，未配置的项使用默认值
+func NewCalendarService(calendarRepo repository.CalendarRepository, appointmentRepo repository.AppointmentRepository, notificationService NotificationService, cfg config.CalendarConfig) CalendarService {
+	cfg.APIBaseURL = strings.TrimRight(cfg.APIBaseURL, "/")
+	if cfg.PastDays <= 0 {
+		cfg.PastDays = 30
+	}
+	offsets := make([]int, 0, len(cfg.ReminderOffsets))
+	for _, offset := range cfg.ReminderOffsets {
+		if offset > 0 {
+			offsets = append(offsets, offset)
+		}
+	}
+	if len(offsets) == 0 {
+		offsets = []int{24 * 60, 15}
+	}
+	// 提醒从最临近开始的时间点开始处理
+	sort.Ints(offsets)
+	cfg.ReminderOffsets = offsets
+
+	return &calendarService{
+		calendarRepo:        calendarRepo,
+		appointmentRepo:     appointmentRepo,
+		notificationService: notificationService,
+		config:              cfg,
+	}
+}
+
+// CreateFeed 生成日历订阅地址，已有订阅时替换令牌，旧地址失效；令牌只在此时返回
+func (s *calendarService) CreateFeed(ctx context.Context, userID string) (*model.CalendarFeedResponse, error) {
+	token, err := utils.GenerateRandomToken(calendarFeedTokenBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	feed := &model.CalendarFeed{
+		UserID:    userID,
+		TokenHash: utils.HashToken(token),
+	}
+	if err := s.calendarRepo.SaveFeed(ctx, feed); err != nil {
+		return nil, err
+	}
+
+	return &model.CalendarFeedResponse{
+		URL:       fmt.Sprintf("%s/calendar/feeds/%s", s.config.APIBaseURL, token),
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// DeleteFeed 取消日历订阅
+func (s *calendarService) DeleteFeed(ctx context.Context, userID string) error {
+	deleted, err := s.calendarRepo.DeleteFeed(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errors.New("日历订阅不存在")
+	}
+	return nil
+}
+
+// GetFeed 根据订阅令牌生成用户的预约日历
+func (s *calendarService) GetFeed(ctx context.Context, token string) ([]byte, error) {
+	if token == "" {
+		return nil, errors.New("日历订阅不存在")
+	}
+	feed, err := s.calendarRepo.GetFeedByTokenHash(ctx, utils.HashToken(token))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("日历订阅不存在")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	appointments, err := s.calendarRepo.ListUserAppointments(ctx, feed.UserID, now.AddDate(0, 0, -s.config.PastDays))
+	if err != nil {
+		return nil, err
+	}
+	_ = s.calendarRepo.TouchFeed(ctx, feed.ID, now)
+
+	calendar := &ical.Calendar{
+		ProdID: calendarProdID,
+		Name:   "咨询预约",
+		Events: make([]*ical.Event, 0, len(appointments)),
+	}
+	for _, appointment := range appointments {
+		calendar.Events = append(calendar.Events, s.appointmentEvent(appointment, feed.UserID))
+	}
+	return calendar.Encode(), nil
+}
+
+// GetAppointmentICS 生成单个预约的日历文件，仅预约双方可下载
+func (s *calendarService) GetAppointmentICS(ctx context.Context, appointmentID, userID string) ([]byte, error) {
+	appointment, err := s.appointmentRepo.GetAppointmentByID(ctx, appointmentID)
+	if err != nil {
+		return nil, errors.New("预约不存在")
+	}
+	if appointmentActor(appointment, userID) == "" {
+		return nil, errors.New("无权操作该预约")
+	}
+
+	calendar := &ical.Calendar{
+		ProdID: calendarProdID,
+		Method: "PUBLISH",
+		Events: []*ical.Event{s.appointmentEvent(appointment, userID)},
+	}
+	return calendar.Encode(), nil
+}
+
+// SendDueReminders 向即将开始的已确认预约双方发送提醒，返回本次发送提醒的预约数
+// 每个提醒时间点先写入发送记录再发送通知，多个实例并发执行时不会重复提醒
+func (s *calendarService) SendDueReminders(ctx context.Context, limit int) (int, error) {
+	sent := 0
+	for _, offset := range s.config.ReminderOffsets {
+		appointments, err := s.calendarRepo.ListDueReminders(ctx, offset, time.Now(), limit)
+		if err != nil {
+			return sent, err
+		}
+
+		for _, appointment := range appointments {
+			if err := ctx.Err(); err != nil {
+				return sent, err
+			}
+
+			reminder := &model.AppointmentReminder{
+				AppointmentID: appointment.ID,
+				OffsetMinutes: offset,
+			}
+			claimed, err := s.calendarRepo.ClaimReminder(ctx, reminder)
+			if err != nil {
+				return sent, err
+			}
+			if !claimed {
+				continue
+			}
+
+			if err := s.sendReminder(ctx, appointment); err != nil {
+				logger.Error("发送预约提醒失败", logger.String("appointment_id", appointment.ID), logger.Int("offset_minutes", offset), logger.String("error", err.Error()))
+				_ = s.calendarRepo.ReleaseReminder(ctx, reminder.ID)
+				continue
+			}
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// sendReminder 通知预约双方咨询即将开始，时间按大师时区显示
+func (s *calendarService) sendReminder(ctx context.Context, appointment *model.AppointmentModel) error {
+	userIDs := []string{appointment.StudentID}
+	loc := time.UTC
+	if appointment.Mentor != nil {
+		userIDs = append(userIDs, appointment.Mentor.UserID)
+		loc = mentorLocation(appointment.Mentor)
+	}
+
+	start := appointment.AppointmentTime.In(loc)
+	content := fmt.Sprintf("%s将于 %s（%s）开始，时长%d分钟",
+		meetingTypeName(appointment.MeetingType), start.Format("2006-01-02 15:04"), loc.String(), appointment.DurationMinutes)
+	if minutes := int(time.Until(appointment.AppointmentTime).Minutes()); minutes > 0 {
+		content += fmt.Sprintf("，距开始还有%s", formatMinutes(minutes))
+	}
+
+	_, err := s.notificationService.SendNotification(ctx, &model.SendNotificationRequest{
+		UserIDs: userIDs,
+		Type:    model.NotificationTypeAppointmentReminder,
+		Title:   "预约即将开始",
+		Content: content,
+		RelatedData: map[string]interface{}{
+			"appointment_id":   appointment.ID,
+			"appointment_time": appointment.AppointmentTime,
+		},
+	})
+	return err
+}
+
+// appointmentEvent 将预约转换为日历事件，标题按查看者身份显示对方
+// 日历订阅常被同步到第三方日历或共享给他人，大师的日历中学员只显示为“学员”，不暴露邮箱等联系方式
+func (s *calendarService) appointmentEvent(appointment *model.AppointmentModel, userID string) *ical.Event {
+	counterpart := ""
+	if appointmentActor(appointment, userID) == model.AppointmentActorMentor {
+		counterpart = calendarStudentLabel
+	} else if appointment.Mentor != nil && appointment.Mentor.Profile != nil {
+		counterpart = appointment.Mentor.Profile.Name
+	}
+
+	summary := "咨询预约"
+	if counterpart != "" {
+		summary += "：" + counterpart
+	}
+
+	meetingType := meetingTypeName(appointment.MeetingType)
+	description := fmt.Sprintf("预约方式：%s\n时长：%d分钟", meetingType, appointment.DurationMinutes)
+	if appointment.Notes != "" {
+		description += "\n备注：" + appointment.Notes
+	}
+
+	alarms := make([]time.Duration, 0, len(s.config.ReminderOffsets))
+	for _, offset := range s.config.ReminderOffsets {
+		alarms = append(alarms, time.Duration(offset)*time.Minute)
+	}
+
+	return &ical.Event{
+		UID:         appointment.ID + "@master-guide",
+		Start:       appointment.AppointmentTime,
+		End:         appointmentEnd(appointment),
+		Summary:     summary,
+		Description: description,
+		Location:    meetingType,
+		Status:      appointmentEventStatus(appointment.Status),
+		// 预约每次变更都会刷新更新时间，以此得到递增的版本号
+		Sequence:     int(appointment.UpdatedAt.Sub(appointment.CreatedAt) / time.Second),
+		Created:      appointment.CreatedAt,
+		LastModified: appointment.UpdatedAt,
+		Alarms:       alarms,
+	}
+}
+
+// appointmentEventStatus 预约状态对应的日历事件状态
+func appointmentEventStatus(status string) string {
+	switch status {
+	case model.AppointmentStatusPendingPayment, model.AppointmentStatusPending:
+		return ical.StatusTentative
+	case model.AppointmentStatusCancelled, model.AppointmentStatusRejected, model.AppointmentStatusNoShow:
+		return ical.StatusCancelled
+	default:
+		return ical.StatusConfirmed
+	}
+}
+
+// meetingTypeName 预约方式的显示名称
+func meetingTypeName(meetingType string) string {
+	if name, ok := meetingTypeNames[meetingType]; ok {
+		return name
+	}
+	return "咨询"
+}
+
+// formatMinutes 将分钟数显示为“X小时Y分钟”
+func formatMinutes(minutes int) string {
+	hours, rest := minutes/60, minutes%60
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("%d分钟", rest)
+	case rest == 0:
+		return fmt.Sprintf("%d小时", hours)
+	default:
+		return fmt.Sprintf("%d小时%d分钟", hours, rest)
+	}
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"master-guide-backend/internal/model"
+)
+
+func TestAppointmentEventSummary(t *testing.T) {
+	appointment := &model.AppointmentModel{
+		StudentID: "student-1",
+		Student:   &model.User{Email: "student@example.com"},
+		Mentor: &model.Mentor{
+			UserID:  "mentor-1",
+			Profile: &model.UserProfile{Name: "王老师"},
+		},
+		MeetingType:     model.MeetingTypeVideo,
+		DurationMinutes: 60,
+	}
+	s := &calendarService{}
+
+	tests := []struct {
+		name   string
+		viewer string
+		want   string
+	}{
+		{name: "student sees mentor name", viewer: "student-1", want: "咨询预约：王老师"},
+		{name: "mentor sees neutral label", viewer: "mentor-1", want: "咨询预约：" + calendarStudentLabel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := s.appointmentEvent(appointment, tt.viewer)
+			if event.Summary != tt.want {
+				t.Fatalf("Summary = %q, want %q", event.Summary, tt.want)
+			}
+			if strings.Contains(event.Summary+event.Description, "student@example.com") {
+				t.Fatalf("event exposes student email: %+v", event)
+			}
+		})
+	}
+}
@@ -2,33 +2,56 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
 	"master-guide-backend/internal/model"
 	"master-guide-backend/internal/repository"
 	"master-guide-backend/internal/utils"
+	"master-guide-backend/pkg/logger"
 )
 
+// maxChatMessageLength 聊天消息内容最大字符数
+const maxChatMessageLength = 2000
+
 // ChatService 聊天服务接口
 type ChatService interface {
 	GetOnlineUsers(ctx context.Context) (*model.OnlineUsersResponse, error)
-	GetChatMessages(ctx context.Context, req *model.ChatMessagesRequest) (*model.ChatMessagesResponse, error)
-	SendMessage(ctx context.Context, message *model.ChatMessage) error
+	GetChatMessages(ctx context.Context, userID string, req *model.ChatMessagesRequest) (*model.ChatMessagesResponse, error)
+	GetConversations(ctx context.Context, userID string) (*model.ChatConversationsResponse, error)
+	SendMessage(ctx context.Context, userID string, req *model.SendChatMessageRequest) (*model.ChatMessage, error)
+	SendTyping(ctx context.Context, userID string, req *model.ChatTypingRequest) error
+	MarkRead(ctx context.Context, userID string, req *model.ChatReadRequest) (*model.ChatReadResponse, error)
 }
 
 type chatService struct {
 	chatRepo     repository.ChatRepository
+	circleRepo   repository.CircleRepository
 	websocketMgr *utils.WebSocketManager
 }
 
-func NewChatService(chatRepo repository.ChatRepository, websocketMgr *utils.WebSocketManager) ChatService {
-	return &chatService{
+// NewChatService 创建聊天服务，并注册 WebSocket 的 message、typing 和 read 事件处理
+func NewChatService(chatRepo repository.ChatRepository, circleRepo repository.CircleRepository, websocketMgr *utils.WebSocketManager) ChatService {
+	s := &chatService{
 		chatRepo:     chatRepo,
+		circleRepo:   circleRepo,
 		websocketMgr: websocketMgr,
 	}
+
+	websocketMgr.HandleEvent("message", chatEventHandler(s.handleMessageEvent))
+	websocketMgr.HandleEvent("typing", chatEventHandler(s.handleTypingEvent))
+	websocketMgr.HandleEvent("read", chatEventHandler(s.handleReadEvent))
+
+	return s
 }
 
 func (s *chatService) GetOnlineUsers(ctx context.Context) (*model.OnlineUsersResponse, error) {
 	// 从 WebSocket 管理器获取在线用户
-	onlineUsers := s.websocketMgr.GetOnlineUsers()
+	onlineUsers := s.websocketMgr.GetOnlineUsers(ctx)
 
 	// 补充用户信息
 	for _, user := range onlineUsers {
@@ -46,7 +69,9 @@ func (s *chatService) GetOnlineUsers(ctx context.Context) (*model.OnlineUsersRes
 	}, nil
 }
 
-func (s *chatService) GetChatMessages(ctx context.Context, req *model.ChatMessagesRequest) (*model.ChatMessagesResponse, error) {
+// GetChatMessages 获取与 target_id 的私聊记录或已加入圈子的聊天记录
+// 获取私聊记录时，对方发来的未送达消息记为已送达并通知对方
+func (s *chatService) GetChatMessages(ctx context.Context, userID string, req *model.ChatMessagesRequest) (*model.ChatMessagesResponse, error) {
 	// 设置默认分页参数
 	if req.Page <= 0 {
 		req.Page = 1
@@ -55,12 +80,44 @@ func (s *chatService) GetChatMessages(ctx context.Context, req *model.ChatMessag
 		req.PageSize = 50
 	}
 
+	if req.TargetID == "" {
+		if err := s.checkCircleMember(ctx, userID, req.CircleID); err != nil {
+			return nil, err
+		}
+	}
+
 	// 获取聊天记录
-	messages, total, err := s.chatRepo.GetChatMessages(ctx, req.TargetID, req.CircleID, req.Page, req.PageSize)
+	messages, total, err := s.chatRepo.GetChatMessages(ctx, userID, req.TargetID, req.CircleID, req.Page, req.PageSize)
 	if err != nil {
 		return nil, err
 	}
 
+	if req.TargetID != "" {
+		now := time.Now()
+		deliveredIDs, err := s.chatRepo.MarkDelivered(ctx, req.TargetID, userID, nil, now)
+		if err != nil {
+			logger.Warn("标记聊天消息送达失败", logger.String("user_id", userID), logger.String("target_id", req.TargetID), logger.String("error", err.Error()))
+		} else if len(deliveredIDs) > 0 {
+			delivered := make(map[string]bool, len(deliveredIDs))
+			for _, id := range deliveredIDs {
+				delivered[id] = true
+			}
+			for _, message := range messages {
+				if delivered[message.ID] {
+					message.Status = model.ChatMessageStatusDelivered
+					message.DeliveredAt = &now
+				}
+			}
+			s.sendReceipt(req.TargetID, &model.ChatReceiptEvent{
+				MessageIDs: deliveredIDs,
+				ToUserID:   userID,
+				UserIDs:    []string{userID},
+				Status:     model.ChatMessageStatusDelivered,
+				Timestamp:  now,
+			})
+		}
+	}
+
 	// 计算分页信息
 	totalPages := (int(total) + req.PageSize - 1) / req.PageSize
 
@@ -78,26 +135,339 @@ func (s *chatService) GetChatMessages(ctx context.Context, req *model.ChatMessag
 	}, nil
 }
 
-func (s *chatService) SendMessage(ctx context.Context, message *model.ChatMessage) error {
-	// 保存消息到数据库
-	err := s.chatRepo.SaveMessage(ctx, message)
+// GetConversations 获取用户的会话列表及每个会话的未读数
+func (s *chatService) GetConversations(ctx context.Context, userID string) (*model.ChatConversationsResponse, error) {
+	conversations, err := s.chatRepo.GetConversations(ctx, userID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	var totalUnread int64
+	for _, conversation := range conversations {
+		totalUnread += conversation.UnreadCount
+	}
+
+	if conversations == nil {
+		conversations = []*model.ChatConversation{}
+	}
+	return &model.ChatConversationsResponse{
+		Conversations: conversations,
+		TotalUnread:   totalUnread,
+	}, nil
+}
+
+// SendMessage 保存并投递聊天消息，消息ID和发送时间由服务端生成
+// 私聊消息投递给接收方，接收方在线时记为已送达并通知发送方；圈子消息投递给在线的圈子成员，并将送达的成员通知发送方
+// 发送方的其他连接同样收到该消息
+func (s *chatService) SendMessage(ctx context.Context, userID string, req *model.SendChatMessageRequest) (*model.ChatMessage, error) {
+	message, err := s.validateMessage(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if profile, err := s.chatRepo.GetUserProfile(ctx, userID); err == nil {
+		message.FromUser.Name = profile.Name
+	}
+
+	// 保存消息到数据库
+	if err := s.chatRepo.SaveMessage(ctx, message); err != nil {
+		return nil, err
 	}
 
-	// 通过 WebSocket 广播消息
 	event := &model.WebSocketEvent{
 		Event: "message",
 		Data:  message,
 	}
 
-	// 如果是私聊消息，只发送给目标用户
 	if message.ToUserID != "" {
-		s.websocketMgr.SendToUser(message.ToUserID, event)
-	} else {
-		// 如果是圈子消息，广播给所有在线用户
-		s.websocketMgr.BroadcastEvent(event)
+		// 私聊消息，只发送给目标用户
+		if s.websocketMgr.SendToUser(message.ToUserID, event) {
+			now := time.Now()
+			deliveredIDs, err := s.chatRepo.MarkDelivered(ctx, userID, message.ToUserID, []string{message.ID}, now)
+			if err != nil {
+				logger.Warn("标记聊天消息送达失败", logger.String("message_id", message.ID), logger.String("error", err.Error()))
+			} else if len(deliveredIDs) > 0 {
+				message.Status = model.ChatMessageStatusDelivered
+				message.DeliveredAt = &now
+			}
+		}
+		s.websocketMgr.SendToUser(userID, event)
+		if message.Status == model.ChatMessageStatusDelivered {
+			s.sendReceipt(userID, &model.ChatReceiptEvent{
+				MessageIDs: []string{message.ID},
+				ToUserID:   message.ToUserID,
+				UserIDs:    []string{message.ToUserID},
+				Status:     model.ChatMessageStatusDelivered,
+				Timestamp:  *message.DeliveredAt,
+			})
+		}
+		return message, nil
+	}
+
+	// 圈子消息，只发送给在线的圈子成员
+	memberIDs, err := s.chatRepo.GetCircleMemberIDs(ctx, message.CircleID)
+	if err != nil {
+		return nil, err
+	}
+	var deliveredTo []string
+	for _, memberID := range memberIDs {
+		if memberID == userID {
+			continue
+		}
+		if s.websocketMgr.SendToUser(memberID, event) {
+			deliveredTo = append(deliveredTo, memberID)
+		}
+	}
+	s.websocketMgr.SendToUser(userID, event)
+	if len(deliveredTo) > 0 {
+		s.sendReceipt(userID, &model.ChatReceiptEvent{
+			MessageIDs: []string{message.ID},
+			CircleID:   message.CircleID,
+			UserIDs:    deliveredTo,
+			Status:     model.ChatMessageStatusDelivered,
+			Timestamp:  time.Now(),
+		})
+	}
+	return message, nil
+}
+
+// SendTyping 将正在输入状态转发给私聊对方或圈子中其他在线成员，不保存
+func (s *chatService) SendTyping(ctx context.Context, userID string, req *model.ChatTypingRequest) error {
+	if err := s.checkTarget(ctx, userID, req.ToUserID, req.CircleID); err != nil {
+		return err
+	}
+
+	event := &model.WebSocketEvent{
+		Event: "typing",
+		Data: &model.ChatTypingEvent{
+			FromUserID: userID,
+			ToUserID:   req.ToUserID,
+			CircleID:   req.CircleID,
+			IsTyping:   req.IsTyping,
+			Timestamp:  time.Now(),
+		},
+	}
+
+	if req.ToUserID != "" {
+		s.websocketMgr.SendToUser(req.ToUserID, event)
+		return nil
+	}
+
+	memberIDs, err := s.chatRepo.GetCircleMemberIDs(ctx, req.CircleID)
+	if err != nil {
+		return err
+	}
+	for _, memberID := range memberIDs {
+		if memberID != userID {
+			s.websocketMgr.SendToUser(memberID, event)
+		}
+	}
+	return nil
+}
+
+// MarkRead 将会话中 message_id 及之前的消息标记为已读，未指定 message_id 时标记全部
+// 私聊消息更新消息的已读状态，圈子消息推进用户的已读位置；已读回执发送给消息的发送方，返回会话剩余未读数
+func (s *chatService) MarkRead(ctx context.Context, userID string, req *model.ChatReadRequest) (*model.ChatReadResponse, error) {
+	if (req.TargetID == "") == (req.CircleID == "") {
+		return nil, errors.New("必须指定 target_id 或 circle_id 之一")
+	}
+
+	now := time.Now()
+	response := &model.ChatReadResponse{TargetID: req.TargetID, CircleID: req.CircleID}
+
+	if req.TargetID != "" {
+		readIDs, err := s.chatRepo.MarkPrivateRead(ctx, userID, req.TargetID, req.MessageID, now)
+		if err != nil {
+			return nil, chatRepositoryError(err)
+		}
+		if len(readIDs) > 0 {
+			s.sendReceipt(req.TargetID, &model.ChatReceiptEvent{
+				MessageIDs: readIDs,
+				ToUserID:   userID,
+				UserIDs:    []string{userID},
+				Status:     model.ChatMessageStatusRead,
+				Timestamp:  now,
+			})
+		}
+		if response.UnreadCount, err = s.chatRepo.CountPrivateUnread(ctx, userID, req.TargetID); err != nil {
+			return nil, err
+		}
+		return response, nil
+	}
+
+	if err := s.checkCircleMember(ctx, userID, req.CircleID); err != nil {
+		return nil, err
+	}
+	readBySender, err := s.chatRepo.MarkCircleRead(ctx, userID, req.CircleID, req.MessageID)
+	if err != nil {
+		return nil, chatRepositoryError(err)
+	}
+	for senderID, messageIDs := range readBySender {
+		s.sendReceipt(senderID, &model.ChatReceiptEvent{
+			MessageIDs: messageIDs,
+			CircleID:   req.CircleID,
+			UserIDs:    []string{userID},
+			Status:     model.ChatMessageStatusRead,
+			Timestamp:  now,
+		})
+	}
+	if response.UnreadCount, err = s.chatRepo.CountCircleUnread(ctx, userID, req.CircleID); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// handleMessageEvent 处理 WebSocket message 事件，回复 message_ack 事件，包含服务端生成的消息ID、发送时间和送达状态
+func (s *chatService) handleMessageEvent(ctx context.Context, userID string, data json.RawMessage) (*model.WebSocketEvent, error) {
+	var req model.SendChatMessageRequest
+	if err := decodeChatEvent(data, &req); err != nil {
+		return nil, err
+	}
+	message, err := s.SendMessage(ctx, userID, &req)
+	if err != nil {
+		return nil, err
+	}
+	return &model.WebSocketEvent{Event: "message_ack", Data: message}, nil
+}
+
+// handleTypingEvent 处理 WebSocket typing 事件，不回复
+func (s *chatService) handleTypingEvent(ctx context.Context, userID string, data json.RawMessage) (*model.WebSocketEvent, error) {
+	var req model.ChatTypingRequest
+	if err := decodeChatEvent(data, &req); err != nil {
+		return nil, err
+	}
+	return nil, s.SendTyping(ctx, userID, &req)
+}
+
+// handleReadEvent 处理 WebSocket read 事件，回复 read_ack 事件，包含会话剩余未读数
+func (s *chatService) handleReadEvent(ctx context.Context, userID string, data json.RawMessage) (*model.WebSocketEvent, error) {
+	var req model.ChatReadRequest
+	if err := decodeChatEvent(data, &req); err != nil {
+		return nil, err
+	}
+	response, err := s.MarkRead(ctx, userID, &req)
+	if err != nil {
+		return nil, err
+	}
+	return &model.WebSocketEvent{Event: "read_ack", Data: response}, nil
+}
+
+// validateMessage 校验发送消息请求并构建待保存的消息
+func (s *chatService) validateMessage(ctx context.Context, userID string, req *model.SendChatMessageRequest) (*model.ChatMessage, error) {
+	content := strings.TrimSpace(req.Content)
+	if content == "" {
+		return nil, errors.New("消息内容不能为空")
+	}
+	if utf8.RuneCountInString(content) > maxChatMessageLength {
+		return nil, errors.New("消息内容不能超过2000个字符")
+	}
+
+	messageType := req.Type
+	if messageType == "" {
+		messageType = "text"
+	}
+	if messageType != "text" && messageType != "image" && messageType != "file" {
+		return nil, errors.New("不支持的消息类型")
+	}
+
+	if err := s.checkTarget(ctx, userID, req.ToUserID, req.CircleID); err != nil {
+		return nil, err
+	}
+
+	message := &model.ChatMessage{
+		FromUser:  &model.ChatUserInfo{ID: userID},
+		Content:   content,
+		Type:      messageType,
+		CreatedAt: time.Now(),
+		ToUserID:  req.ToUserID,
+		CircleID:  req.CircleID,
+	}
+	if message.ToUserID != "" {
+		message.Status = model.ChatMessageStatusSent
+	}
+	return message, nil
+}
+
+// checkTarget 校验私聊对象或圈子：私聊对象须为其他存在的用户，圈子须已加入
+func (s *chatService) checkTarget(ctx context.Context, userID, toUserID, circleID string) error {
+	if (toUserID == "") == (circleID == "") {
+		return errors.New("必须指定 to_user_id 或 circle_id 之一")
+	}
+	if circleID != "" {
+		return s.checkCircleMember(ctx, userID, circleID)
+	}
+
+	if toUserID == userID {
+		return errors.New("不能给自己发送消息")
+	}
+	exists, err := s.chatRepo.UserExists(ctx, toUserID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("接收用户不存在")
 	}
+	return nil
+}
 
+// checkCircleMember 校验用户已加入圈子
+func (s *chatService) checkCircleMember(ctx context.Context, userID, circleID string) error {
+	joined, err := s.circleRepo.IsUserJoinedCircle(ctx, userID, circleID)
+	if err != nil {
+		return err
+	}
+	if !joined {
+		return errors.New("未加入该圈子")
+	}
 	return nil
 }
+
+// sendReceipt 向消息发送方推送送达或已读回执
+func (s *chatService) sendReceipt(userID string, receipt *model.ChatReceiptEvent) {
+	s.websocketMgr.SendToUser(userID, &model.WebSocketEvent{
+		Event: "receipt",
+		Data:  receipt,
+	})
+}
+
+// decodeChatEvent 解析 WebSocket 事件数据
+func decodeChatEvent(data json.RawMessage, v interface{}) error {
+	if len(data) == 0 || json.Unmarshal(data, v) != nil {
+		return errors.New("消息格式错误")
+	}
+	return nil
+}
+
+// chatEventHandler 将 WebSocket 事件处理函数返回的聊天业务错误转换为带错误码的 WebSocket 错误
+func chatEventHandler(handler utils.WebSocketEventHandler) utils.WebSocketEventHandler {
+	return func(ctx context.Context, userID string, data json.RawMessage) (*model.WebSocketEvent, error) {
+		reply, err := handler(ctx, userID, data)
+		if err != nil {
+			return nil, chatEventError(err)
+		}
+		return reply, nil
+	}
+}
+
+// chatEventError 将聊天业务错误映射为 WebSocket 错误码，未知错误原样返回，由 WebSocket 管理器记录日志并回复通用错误
+func chatEventError(err error) error {
+	switch err.Error() {
+	case "消息格式错误", "消息内容不能为空", "消息内容不能超过2000个字符", "不支持的消息类型",
+		"必须指定 to_user_id 或 circle_id 之一", "必须指定 target_id 或 circle_id 之一", "不能给自己发送消息":
+		return utils.NewWebSocketError(http.StatusBadRequest, err.Error())
+	case "未加入该圈子":
+		return utils.NewWebSocketError(http.StatusForbidden, err.Error())
+	case "接收用户不存在", "消息不存在":
+		return utils.NewWebSocketError(http.StatusNotFound, err.Error())
+	default:
+		return err
+	}
+}
+
+// chatRepositoryError 将数据访问层错误转换为业务错误
+func chatRepositoryError(err error) error {
+	if errors.Is(err, repository.ErrChatMessageNotFound) {
+		return errors.New("消息不存在")
+	}
+	return err
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"master-guide-backend/internal/utils"
+)
+
+func TestChatEventError(t *testing.T) {
+	tests := []struct {
+		err      string
+		wantCode int
+	}{
+		{err: "消息格式错误", wantCode: http.StatusBadRequest},
+		{err: "消息内容不能为空", wantCode: http.StatusBadRequest},
+		{err: "消息内容不能超过2000个字符", wantCode: http.StatusBadRequest},
+		{err: "不支持的消息类型", wantCode: http.StatusBadRequest},
+		{err: "必须指定 to_user_id 或 circle_id 之一", wantCode: http.StatusBadRequest},
+		{err: "必须指定 target_id 或 circle_id 之一", wantCode: http.StatusBadRequest},
+		{err: "不能给自己发送消息", wantCode: http.StatusBadRequest},
+		{err: "未加入该圈子", wantCode: http.StatusForbidden},
+		{err: "接收用户不存在", wantCode: http.StatusNotFound},
+		{err: "消息不存在", wantCode: http.StatusNotFound},
+		{err: "pq: connection refused"},
+	}
+	for _, tt := range tests {
+		err := chatEventError(errors.New(tt.err))
+		var eventErr *utils.WebSocketError
+		if !errors.As(err, &eventErr) {
+			if tt.wantCode != 0 {
+				t.Errorf("chatEventError(%q) = %v, want code %d", tt.err, err, tt.wantCode)
+			}
+			continue
+		}
+		if tt.wantCode == 0 {
+			t.Errorf("chatEventError(%q) exposed as %d, want unmapped", tt.err, eventErr.Code)
+			continue
+		}
+		if eventErr.Code != tt.wantCode || eventErr.Message != tt.err {
+			t.Errorf("chatEventError(%q) = %d %q, want %d", tt.err, eventErr.Code, eventErr.Message, tt.wantCode)
+		}
+	}
+}
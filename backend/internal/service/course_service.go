@@ -4,11 +4,12 @@ import (
 	"context"
 	"errors"
 	"math"
+	"time"
 
 	"master-guide-backend/internal/model"
 	"master-guide-backend/internal/repository"
-
-	"gorm.io/gorm"
+	"master-guide-backend/pkg/config"
+	"master-guide-backend/pkg/logger"
 )
 
 // CourseService 课程服务接口
@@ -16,7 +17,8 @@ type CourseService interface {
 	GetCourses(ctx context.Context, req *model.CourseListRequest) (*model.CourseListResponse, error)
 	GetCourseDetail(ctx context.Context, courseID string) (*model.CourseDetailResponse, error)
 	CreateCourse(ctx context.Context, mentorID string, req *model.CreateCourseRequest) (*model.CreateCourseResponse, error)
-	EnrollCourse(ctx context.Context, userID, courseID string, req *model.EnrollCourseRequest) (*model.EnrollCourseResponse, error)
+	EnrollCourse(ctx context.Context, userID, courseID string, req *model.EnrollCourseRequest, client *model.ClientInfo) (*model.EnrollCourseResponse, error)
+	CancelEnrollment(ctx context.Context, userID, courseID string) (*model.CancelEnrollmentResponse, error)
 	GetCourseProgress(ctx context.Context, userID, courseID string) (*model.CourseProgressResponse, error)
 	SearchCourses(ctx context.Context, req *model.CourseSearchRequest) (*model.CourseSearchResponse, error)
 	GetRecommendedCourses(ctx context.Context, userID string) (*model.RecommendedCoursesResponse, error)
@@ -27,13 +29,17 @@ type CourseService interface {
 type courseService struct {
 	courseRepo        repository.CourseRepository
 	courseContentRepo repository.CourseContentRepository
+	paymentService    PaymentService
+	refundPolicy      *refundPolicy
 }
 
 // NewCourseService 创建课程服务实例
-func NewCourseService(courseRepo repository.CourseRepository, courseContentRepo repository.CourseContentRepository) CourseService {
+func NewCourseService(courseRepo repository.CourseRepository, courseContentRepo repository.CourseContentRepository, paymentService PaymentService, refundPolicyConfig config.RefundPolicyConfig) CourseService {
 	return &courseService{
 		courseRepo:        courseRepo,
 		courseContentRepo: courseContentRepo,
+		paymentService:    paymentService,
+		refundPolicy:      newRefundPolicy(refundPolicyConfig),
 	}
 }
 
@@ -132,24 +138,106 @@ func (s *courseService) CreateCourse(ctx context.Context, mentorID string, req *
 }
 
 // EnrollCourse 报名课程
-func (s *courseService) EnrollCourse(ctx context.Context, userID, courseID string, req *model.EnrollCourseRequest) (*model.EnrollCourseResponse, error) {
-	err := s.courseRepo.EnrollCourse(ctx, userID, courseID)
+// 付费课程先创建待支付的学习记录并下单，支付完成后由履约流程开通；免费课程直接开通
+func (s *courseService) EnrollCourse(ctx context.Context, userID, courseID string, req *model.EnrollCourseRequest, client *model.ClientInfo) (*model.EnrollCourseResponse, error) {
+	course, err := s.courseRepo.GetCourseByID(ctx, courseID)
+	if err != nil {
+		return nil, errors.New("课程不存在")
+	}
+
+	status := model.LearningStatusPendingPayment
+	if course.Price <= 0 {
+		status = model.LearningStatusEnrolled
+	}
+
+	record, err := s.courseRepo.EnrollCourse(ctx, userID, courseID, status)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+		if errors.Is(err, repository.ErrEnrollmentExists) {
+			if record.Status == model.LearningStatusPendingPayment {
+				return nil, errors.New("报名待支付，请先完成支付")
+			}
 			return nil, errors.New("已报名该课程")
 		}
 		return nil, err
 	}
 
-	// 这里应该调用支付接口，目前返回模拟的支付URL
-	paymentURL := "https://example.com/payment?course_id=" + courseID
+	response := &model.EnrollCourseResponse{
+		EnrollmentID: record.ID,
+		CourseID:     courseID,
+		Status:       record.Status,
+	}
+	if record.Status != model.LearningStatusPendingPayment {
+		return response, nil
+	}
+
+	payment, err := s.paymentService.CreatePaymentOrder(ctx, userID, &model.CreatePaymentOrderRequest{
+		OrderType:     model.PaymentOrderTypeCourseEnrollment,
+		OrderID:       record.ID,
+		Amount:        course.Price,
+		Currency:      "CNY",
+		PaymentMethod: req.PaymentMethod,
+	}, client)
+	if err != nil {
+		// 下单失败时释放报名，允许用户重新报名
+		_ = s.courseRepo.CancelPendingEnrollment(ctx, record.ID)
+		return nil, err
+	}
+
+	response.PaymentURL = payment.PaymentURL
+	response.Payment = payment
+	return response, nil
+}
+
+// CancelEnrollment 退课
+// 待支付的报名直接取消；已开通的报名按报名时间和学习进度计算退款比例并自动退款
+func (s *courseService) CancelEnrollment(ctx context.Context, userID, courseID string) (*model.CancelEnrollmentResponse, error) {
+	record, err := s.courseRepo.GetActiveEnrollment(ctx, userID, courseID)
+	if err != nil {
+		return nil, errors.New("未报名该课程")
+	}
 
-	return &model.EnrollCourseResponse{
-		EnrollmentID: "ENROLL_" + courseID + "_" + userID,
+	response := &model.CancelEnrollmentResponse{
+		EnrollmentID: record.ID,
 		CourseID:     courseID,
-		Status:       "enrolled",
-		PaymentURL:   paymentURL,
-	}, nil
+	}
+	if record.Status == model.LearningStatusPendingPayment {
+		if err := s.courseRepo.CancelPendingEnrollment(ctx, record.ID); err != nil {
+			return nil, err
+		}
+		response.Status = model.LearningStatusCancelled
+		return response, nil
+	}
+
+	droppable := false
+	for _, status := range model.LearningDroppableStatuses {
+		if record.Status == status {
+			droppable = true
+			break
+		}
+	}
+	if !droppable {
+		return nil, errors.New("课程已学完，不能退课")
+	}
+
+	decision := s.refundPolicy.forCourse(record, time.Now())
+	dropped, err := s.courseRepo.DropEnrollment(ctx, record.ID, record.Status)
+	if err != nil {
+		return nil, err
+	}
+	if !dropped {
+		return nil, errors.New("报名状态已变化，请刷新后重试")
+	}
+	response.Status = model.LearningStatusDropped
+
+	refund, err := s.paymentService.RefundOrderByRef(ctx, model.PaymentOrderTypeCourseEnrollment, record.ID, decision.Rate, decision.Rule)
+	if err != nil {
+		// 退款记录未能写入，不影响退课结果；网关申请失败时退款记录已保留，由补偿任务重试
+		logger.Error("退课自动退款失败", logger.String("enrollment_id", record.ID), logger.String("error", err.Error()))
+		response.Refund = &model.CancellationRefund{Rule: decision.Rule, Rate: decision.Rate, Status: "failed"}
+		return response, nil
+	}
+	response.Refund = cancellationRefund(decision, refund)
+	return response, nil
 }
 
 // GetCourseProgress 获取课程进度
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"master-guide-backend/internal/model"
+	"master-guide-backend/internal/repository"
+)
+
+// FeeQuote 按费率规则计算的费用，RuleID 为空表示使用默认费率
+type FeeQuote struct {
+	Fee    float64
+	RuleID *string
+}
+
+// FeeRuleService 费率规则服务接口
+// 确认大师收入时计算平台佣金，申请提现时计算手续费，并返回所用规则
+type FeeRuleService interface {
+	PlatformFee(ctx context.Context, mentorID, orderType string, amount float64, at time.Time) (*FeeQuote, error)
+	WithdrawalFee(ctx context.Context, mentorID string, amount float64, at time.Time) (*FeeQuote, error)
+	ListRules(ctx context.Context, req *model.ListFeeRulesRequest) (*model.FeeRuleListResponse, error)
+	CreateRule(ctx context.Context, req *model.FeeRuleRequest) (*model.FeeRule, error)
+	UpdateRule(ctx context.Context, ruleID string, req *model.FeeRuleRequest) (*model.FeeRule, error)
+	UpdateMentorTier(ctx context.Context, mentorID, tier string) error
+}
+
+// defaultWithdrawalFeeRule 未配置提现手续费规则时的默认费率：金额的0.2%，最低1元，最高50元
+var defaultWithdrawalFeeRule = &model.FeeRule{
+	FeeType: model.FeeTypeWithdrawal,
+	Rate:    0.002,
+	MinFee:  floatPtr(1),
+	MaxFee:  floatPtr(50),
+}
+
+// feeRuleService 费率规则服务实现
+type feeRuleService struct {
+	feeRuleRepo     repository.FeeRuleRepository
+	mentorRepo      repository.MentorRepository
+	platformFeeRate float64
+}
+
+// NewFeeRuleService 创建费率规则服务实例，platformFeeRate 为未匹配到规则时的默认平台佣金费率
+func NewFeeRuleService(feeRuleRepo repository.FeeRuleRepository, mentorRepo repository.MentorRepository, platformFeeRate float64) FeeRuleService {
+	return &feeRuleService{
+		feeRuleRepo:     feeRuleRepo,
+		mentorRepo:      mentorRepo,
+		platformFeeRate: platformFeeRate,
+	}
+}
+
+// PlatformFee 按订单类型、大师领域和等级匹配平台佣金规则并计算佣金
+func (s *feeRuleService) PlatformFee(ctx context.Context, mentorID, orderType string, amount float64, at time.Time) (*FeeQuote, error) {
+	fallback := &model.FeeRule{FeeType: model.FeeTypePlatformCommission, Rate: math.Max(s.platformFeeRate, 0)}
+	return s.quote(ctx, model.FeeTypePlatformCommission, mentorID, orderType, amount, at, fallback)
+}
+
+// WithdrawalFee 按大师领域和等级匹配提现手续费规则并计算手续费
+func (s *feeRuleService) WithdrawalFee(ctx context.Context, mentorID string, amount float64, at time.Time) (*FeeQuote, error) {
+	return s.quote(ctx, model.FeeTypeWithdrawal, mentorID, "", amount, at, defaultWithdrawalFeeRule)
+}
+
+// quote 匹配生效中的规则计算费用，未匹配到时使用默认规则
+func (s *feeRuleService) quote(ctx context.Context, feeType, mentorID, orderType string, amount float64, at time.Time, fallback *model.FeeRule) (*FeeQuote, error) {
+	mentor, err := s.mentorRepo.GetMentorByID(ctx, mentorID)
+	if err != nil {
+		return nil, err
+	}
+	domain := ""
+	if mentor.Identity != nil {
+		domain = mentor.Identity.Domain
+	}
+
+	rules, err := s.feeRuleRepo.ListEffectiveRules(ctx, feeType, at)
+	if err != nil {
+		return nil, err
+	}
+	rule := matchFeeRule(rules, orderType, domain, mentor.Tier)
+	if rule == nil {
+		return &FeeQuote{Fee: computeFee(fallback, amount)}, nil
+	}
+	return &FeeQuote{Fee: computeFee(rule, amount), RuleID: &rule.ID}, nil
+}
+
+// ListRules 获取费率规则列表
+func (s *feeRuleService) ListRules(ctx context.Context, req *model.ListFeeRulesRequest) (*model.FeeRuleListResponse, error) {
+	rules, err := s.feeRuleRepo.ListRules(ctx, req.FeeType, req.Status)
+	if err != nil {
+		return nil, err
+	}
+	return &model.FeeRuleListResponse{Rules: rules}, nil
+}
+
+// CreateRule 创建费率规则
+func (s *feeRuleService) CreateRule(ctx context.Context, req *model.FeeRuleRequest) (*model.FeeRule, error) {
+	rule := &model.FeeRule{}
+	if err := applyFeeRuleRequest(rule, req); err != nil {
+		return nil, err
+	}
+	if err := s.feeRuleRepo.CreateRule(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// UpdateRule 修改费率规则
+// 已被收入或提现记录使用的规则只能修改名称、说明、状态、优先级和失效时间，调整费率需新建规则
+func (s *feeRuleService) UpdateRule(ctx context.Context, ruleID string, req *model.FeeRuleRequest) (*model.FeeRule, error) {
+	rule, err := s.feeRuleRepo.GetRuleByID(ctx, ruleID)
+	if err != nil {
+		return nil, errors.New("费率规则不存在")
+	}
+
+	updated := *rule
+	if err := applyFeeRuleRequest(&updated, req); err != nil {
+		return nil, err
+	}
+
+	applied, err := s.feeRuleRepo.IsRuleApplied(ctx, rule.ID)
+	if err != nil {
+		return nil, err
+	}
+	if applied && !sameFeeTerms(rule, &updated) {
+		return nil, errors.New("费率规则已被使用，不能修改计费条件，请新建规则")
+	}
+
+	if err := s.feeRuleRepo.UpdateRule(ctx, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// UpdateMentorTier 设置大师等级
+func (s *feeRuleService) UpdateMentorTier(ctx context.Context, mentorID, tier string) error {
+	updated, err := s.feeRuleRepo.UpdateMentorTier(ctx, mentorID, tier)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return errors.New("大师不存在")
+	}
+	return nil
+}
+
+// applyFeeRuleRequest 校验请求并写入规则，未指定生效时间时立即生效
+func applyFeeRuleRequest(rule *model.FeeRule, req *model.FeeRuleRequest) error {
+	if req.FeeType == model.FeeTypeWithdrawal && req.OrderType != "" {
+		return errors.New("提现手续费规则不能指定订单类型")
+	}
+	if req.MinFee != nil && req.MaxFee != nil && *req.MinFee > *req.MaxFee {
+		return errors.New("最低手续费不能高于最高手续费")
+	}
+
+	effectiveFrom := time.Now()
+	if req.EffectiveFrom != nil {
+		effectiveFrom = *req.EffectiveFrom
+	} else if !rule.EffectiveFrom.IsZero() {
+		effectiveFrom = rule.EffectiveFrom
+	}
+	if req.EffectiveTo != nil && !req.EffectiveTo.After(effectiveFrom) {
+		return errors.New("失效时间必须晚于生效时间")
+	}
+
+	rule.Name = req.Name
+	rule.FeeType = req.FeeType
+	rule.OrderType = optionalString(req.OrderType)
+	rule.Domain = optionalString(req.Domain)
+	rule.MentorTier = optionalString(req.MentorTier)
+	rule.Rate = req.Rate
+	rule.FixedFee = req.FixedFee
+	rule.MinFee = req.MinFee
+	rule.MaxFee = req.MaxFee
+	rule.IsPromotional = req.IsPromotional
+	rule.Priority = req.Priority
+	rule.EffectiveFrom = effectiveFrom
+	rule.EffectiveTo = req.EffectiveTo
+	rule.Status = req.Status
+	if rule.Status == "" {
+		rule.Status = model.FeeRuleStatusActive
+	}
+	rule.Description = req.Description
+	return nil
+}
+
+// sameFeeTerms 判断两条规则的匹配条件和计费方式是否一致
+func sameFeeTerms(a, b *model.FeeRule) bool {
+	return a.FeeType == b.FeeType &&
+		stringValue(a.OrderType) == stringValue(b.OrderType) &&
+		stringValue(a.Domain) == stringValue(b.Domain) &&
+		stringValue(a.MentorTier) == stringValue(b.MentorTier) &&
+		toCents(a.Rate*100) == toCents(b.Rate*100) &&
+		toCents(a.FixedFee) == toCents(b.FixedFee) &&
+		sameOptionalAmount(a.MinFee, b.MinFee) &&
+		sameOptionalAmount(a.MaxFee, b.MaxFee) &&
+		a.IsPromotional == b.IsPromotional &&
+		a.EffectiveFrom.Equal(b.EffectiveFrom)
+}
+
+// matchFeeRule 从生效中的规则里选出适用的一条
+// 规则的订单类型、领域、等级须为空或与条件一致；促销规则优先，其次优先级高、匹配条件多、生效时间晚的规则
+func matchFeeRule(rules []*model.FeeRule, orderType, domain, tier string) *model.FeeRule {
+	var candidates []*model.FeeRule
+	for _, rule := range rules {
+		if !criterionMatches(rule.OrderType, orderType) ||
+			!criterionMatches(rule.Domain, domain) ||
+			!criterionMatches(rule.MentorTier, tier) {
+			continue
+		}
+		candidates = append(candidates, rule)
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.IsPromotional != b.IsPromotional {
+			return a.IsPromotional
+		}
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if sa, sb := feeRuleSpecificity(a), feeRuleSpecificity(b); sa != sb {
+			return sa > sb
+		}
+		if !a.EffectiveFrom.Equal(b.EffectiveFrom) {
+			return a.EffectiveFrom.After(b.EffectiveFrom)
+		}
+		return a.ID < b.ID
+	})
+	return candidates[0]
+}
+
+// criterionMatches 规则条件为空时匹配全部
+func criterionMatches(criterion *string, value string) bool {
+	return criterion == nil || *criterion == value
+}
+
+// feeRuleSpecificity 规则指定的匹配条件数量
+func feeRuleSpecificity(rule *model.FeeRule) int {
+	count := 0
+	for _, criterion := range []*string{rule.OrderType, rule.Domain, rule.MentorTier} {
+		if criterion != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// computeFee 按分计算费用：比例费用加固定费用，再按最低、最高手续费限制，且不超过金额本身
+func computeFee(rule *model.FeeRule, amount float64) float64 {
+	amountCents := toCents(amount)
+	if amountCents <= 0 {
+		return 0
+	}
+
+	fee := int64(math.Round(float64(amountCents)*rule.Rate)) + toCents(rule.FixedFee)
+	if rule.MinFee != nil && fee < toCents(*rule.MinFee) {
+		fee = toCents(*rule.MinFee)
+	}
+	if rule.MaxFee != nil && fee > toCents(*rule.MaxFee) {
+		fee = toCents(*rule.MaxFee)
+	}
+	if fee > amountCents {
+		fee = amountCents
+	}
+	return float64(fee) / 100
+}
+
+// sameOptionalAmount 比较两个可为空的金额
+func sameOptionalAmount(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return toCents(*a) == toCents(*b)
+}
+
+// stringValue 取字符串指针的值，nil 视为空字符串
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+// optionalString 空字符串转换为 nil
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// floatPtr 返回浮点数指针
+func floatPtr(value float64) *float64 {
+	return &value
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"master-guide-backend/internal/model"
+)
+
+func TestComputeFee(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   *model.FeeRule
+		amount float64
+		want   float64
+	}{
+		{name: "rate", rule: &model.FeeRule{Rate: 0.1}, amount: 199, want: 19.9},
+		{name: "rate rounds to cent", rule: &model.FeeRule{Rate: 0.035}, amount: 9.99, want: 0.35},
+		{name: "rate plus fixed", rule: &model.FeeRule{Rate: 0.05, FixedFee: 2}, amount: 100, want: 7},
+		{name: "fixed only", rule: &model.FeeRule{FixedFee: 3}, amount: 100, want: 3},
+		{name: "zero rate", rule: &model.FeeRule{}, amount: 100, want: 0},
+		{name: "min fee", rule: &model.FeeRule{Rate: 0.002, MinFee: floatPtr(1), MaxFee: floatPtr(50)}, amount: 100, want: 1},
+		{name: "within bounds", rule: &model.FeeRule{Rate: 0.002, MinFee: floatPtr(1), MaxFee: floatPtr(50)}, amount: 10000, want: 20},
+		{name: "max fee", rule: &model.FeeRule{Rate: 0.002, MinFee: floatPtr(1), MaxFee: floatPtr(50)}, amount: 100000, want: 50},
+		{name: "capped at amount", rule: &model.FeeRule{MinFee: floatPtr(5)}, amount: 3, want: 3},
+		{name: "fixed capped at amount", rule: &model.FeeRule{FixedFee: 10}, amount: 0.5, want: 0.5},
+		{name: "zero amount", rule: &model.FeeRule{Rate: 0.1, MinFee: floatPtr(1)}, amount: 0, want: 0},
+		{name: "negative amount", rule: &model.FeeRule{Rate: 0.1, FixedFee: 1}, amount: -10, want: 0},
+		{name: "sub-cent amount", rule: &model.FeeRule{Rate: 0.1, MinFee: floatPtr(1)}, amount: 0.004, want: 0},
+		{name: "default withdrawal rule", rule: defaultWithdrawalFeeRule, amount: 1234.56, want: 2.47},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := computeFee(tt.rule, tt.amount); toCents(got) != toCents(tt.want) {
+				t.Fatalf("computeFee(%v) = %v, want %v", tt.amount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchFeeRule(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rule := func(id string, mutate func(rule *model.FeeRule)) *model.FeeRule {
+		r := &model.FeeRule{ID: id, FeeType: model.FeeTypePlatformCommission, EffectiveFrom: base}
+		if mutate != nil {
+			mutate(r)
+		}
+		return r
+	}
+
+	general := rule("general", nil)
+	course := rule("course", func(r *model.FeeRule) { r.OrderType = optionalString(model.PaymentOrderTypeCourseEnrollment) })
+	courseMusic := rule("course-music", func(r *model.FeeRule) {
+		r.OrderType = optionalString(model.PaymentOrderTypeCourseEnrollment)
+		r.Domain = optionalString("music")
+	})
+	gold := rule("gold", func(r *model.FeeRule) { r.MentorTier = optionalString("gold") })
+	priority := rule("priority", func(r *model.FeeRule) { r.Priority = 10 })
+	promotion := rule("promotion", func(r *model.FeeRule) {
+		r.IsPromotional = true
+		r.Domain = optionalString("music")
+	})
+	newer := rule("newer", func(r *model.FeeRule) { r.EffectiveFrom = base.AddDate(0, 1, 0) })
+	twinA := rule("twin-a", nil)
+	twinB := rule("twin-b", nil)
+
+	tests := []struct {
+		name      string
+		rules     []*model.FeeRule
+		orderType string
+		domain    string
+		tier      string
+		want      string
+	}{
+		{name: "no rules", want: ""},
+		{name: "no matching criteria", rules: []*model.FeeRule{courseMusic, gold}, orderType: model.PaymentOrderTypeAppointment, domain: "art", tier: "silver", want: ""},
+		{name: "general matches everything", rules: []*model.FeeRule{general}, orderType: model.PaymentOrderTypeAppointment, domain: "art", want: "general"},
+		{name: "more specific wins", rules: []*model.FeeRule{general, course, courseMusic}, orderType: model.PaymentOrderTypeCourseEnrollment, domain: "music", want: "course-music"},
+		{name: "specific rule skipped when criteria differ", rules: []*model.FeeRule{general, course, courseMusic}, orderType: model.PaymentOrderTypeCourseEnrollment, domain: "art", want: "course"},
+		{name: "tier rule", rules: []*model.FeeRule{general, gold}, tier: "gold", want: "gold"},
+		{name: "priority beats specificity", rules: []*model.FeeRule{courseMusic, priority}, orderType: model.PaymentOrderTypeCourseEnrollment, domain: "music", want: "priority"},
+		{name: "promotion beats priority", rules: []*model.FeeRule{priority, courseMusic, promotion}, orderType: model.PaymentOrderTypeCourseEnrollment, domain: "music", want: "promotion"},
+		{name: "promotion only for its domain", rules: []*model.FeeRule{priority, promotion}, domain: "art", want: "priority"},
+		{name: "later effective date wins", rules: []*model.FeeRule{general, newer}, want: "newer"},
+		{name: "id breaks ties", rules: []*model.FeeRule{twinB, twinA}, want: "twin-a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := matchFeeRule(tt.rules, tt.orderType, tt.domain, tt.tier)
+			if tt.want == "" {
+				if got != nil {
+					t.Fatalf("matchFeeRule() = %s, want nil", got.ID)
+				}
+				return
+			}
+			if got == nil || got.ID != tt.want {
+				t.Fatalf("matchFeeRule() = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
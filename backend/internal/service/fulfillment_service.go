@@ -0,0 +1,304 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"master-guide-backend/internal/model"
+	"master-guide-backend/internal/repository"
+	"master-guide-backend/pkg/logger"
+)
+
+// OrderQuote 业务订单应付信息，以服务端价格为准
+type OrderQuote struct {
+	Amount      float64
+	Currency    string
+	Description string
+}
+
+// FulfillmentService 支付履约服务接口
+// 课程报名、预约和咨询套餐购买创建后处于待支付状态，支付完成后开通并记录大师收入，支付失败或过期后释放，退款完成后冲正收入
+// 记录收入和冲正收入后开具对应票据
+type FulfillmentService interface {
+	QuoteOrder(ctx context.Context, userID, orderType, refID string) (*OrderQuote, error)
+	FulfillOrder(ctx context.Context, orderID string) error
+	ReleaseOrder(ctx context.Context, orderID string) error
+	ReverseIncome(ctx context.Context, refundID string) error
+}
+
+// fulfillmentService 支付履约服务实现
+type fulfillmentService struct {
+	fulfillmentRepo repository.FulfillmentRepository
+	paymentRepo     repository.PaymentRepository
+	courseRepo      repository.CourseRepository
+	appointmentRepo repository.AppointmentRepository
+	packageRepo     repository.AppointmentPackageRepository
+	meetingService  MeetingService
+	feeRuleService  FeeRuleService
+	invoiceService  InvoiceService
+}
+
+// NewFulfillmentService 创建支付履约服务实例
+func NewFulfillmentService(fulfillmentRepo repository.FulfillmentRepository, paymentRepo repository.PaymentRepository, courseRepo repository.CourseRepository, appointmentRepo repository.AppointmentRepository, packageRepo repository.AppointmentPackageRepository, meetingService MeetingService, feeRuleService FeeRuleService, invoiceService InvoiceService) FulfillmentService {
+	return &fulfillmentService{
+		fulfillmentRepo: fulfillmentRepo,
+		paymentRepo:     paymentRepo,
+		courseRepo:      courseRepo,
+		appointmentRepo: appointmentRepo,
+		packageRepo:     packageRepo,
+		meetingService:  meetingService,
+		feeRuleService:  feeRuleService,
+		invoiceService:  invoiceService,
+	}
+}
+
+// QuoteOrder 校验业务订单归属和状态，返回应付金额
+func (s *fulfillmentService) QuoteOrder(ctx context.Context, userID, orderType, refID string) (*OrderQuote, error) {
+	switch orderType {
+	case model.PaymentOrderTypeCourseEnrollment:
+		record, err := s.fulfillmentRepo.GetEnrollmentByID(ctx, refID)
+		if err != nil || record.UserID != userID {
+			return nil, errors.New("业务订单不存在")
+		}
+		if record.Status != model.LearningStatusPendingPayment {
+			return nil, errors.New("业务订单状态不允许支付")
+		}
+		course, err := s.courseRepo.GetCourseByID(ctx, record.CourseID)
+		if err != nil {
+			return nil, errors.New("课程不存在")
+		}
+		return &OrderQuote{
+			Amount:      course.Price,
+			Currency:    "CNY",
+			Description: "课程报名：" + course.Title,
+		}, nil
+
+	case model.PaymentOrderTypeAppointment:
+		appointment, err := s.appointmentRepo.GetAppointmentByID(ctx, refID)
+		if err != nil || appointment.StudentID != userID {
+			return nil, errors.New("业务订单不存在")
+		}
+		if appointment.Status != model.AppointmentStatusPendingPayment {
+			return nil, errors.New("业务订单状态不允许支付")
+		}
+		return &OrderQuote{
+			Amount:      appointment.Price,
+			Currency:    "CNY",
+			Description: "咨询预约：" + appointment.AppointmentTime.Format("2006-01-02 15:04"),
+		}, nil
+
+	case model.PaymentOrderTypeAppointmentPackage:
+		purchase, err := s.packageRepo.GetPurchaseByID(ctx, refID)
+		if err != nil || purchase.StudentID != userID {
+			return nil, errors.New("业务订单不存在")
+		}
+		if purchase.Status != model.PackagePurchaseStatusPendingPayment {
+			return nil, errors.New("业务订单状态不允许支付")
+		}
+		return &OrderQuote{
+			Amount:      purchase.Price,
+			Currency:    "CNY",
+			Description: "咨询套餐：" + purchase.Title,
+		}, nil
+	}
+	return nil, errors.New("不支持的订单类型")
+}
+
+// FulfillOrder 支付完成后开通课程、确认预约或开通咨询套餐，并按费率规则扣除平台佣金记录大师收入，重复调用不会重复履约
+// 视频咨询预约确认后创建会议室；业务订单已无法开通时返回 repository.ErrOrderUnfulfillable，由调用方退回支付金额
+func (s *fulfillmentService) FulfillOrder(ctx context.Context, orderID string) error {
+	order, err := s.paymentRepo.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	if order.Status != "completed" || order.FulfilledAt != nil {
+		return nil
+	}
+	if order.FulfillmentFailedAt != nil {
+		return repository.ErrOrderUnfulfillable
+	}
+
+	now := time.Now()
+	income := &model.IncomeTransactionModel{
+		TransactionType:  order.OrderType,
+		Amount:           order.Amount,
+		Status:           "completed",
+		SettlementStatus: model.IncomeSettlementFrozen,
+		CompletedAt:      &now,
+	}
+
+	var fulfilled bool
+	switch order.OrderType {
+	case model.PaymentOrderTypeCourseEnrollment:
+		record, err := s.fulfillmentRepo.GetEnrollmentByID(ctx, order.OrderRefID)
+		if err != nil {
+			return err
+		}
+		course, err := s.courseRepo.GetCourseByID(ctx, record.CourseID)
+		if err != nil {
+			return err
+		}
+		income.MentorID = course.MentorID
+		income.StudentID = record.UserID
+		income.CourseID = &course.ID
+		income.Description = "课程报名：" + course.Title
+		if err := s.applyPlatformFee(ctx, income, now); err != nil {
+			return err
+		}
+		fulfilled, err = s.fulfillmentRepo.ActivateEnrollment(ctx, order.ID, record.ID, income)
+		if err != nil {
+			return err
+		}
+
+	case model.PaymentOrderTypeAppointment:
+		appointment, err := s.appointmentRepo.GetAppointmentByID(ctx, order.OrderRefID)
+		if err != nil {
+			return err
+		}
+		income.MentorID = appointment.MentorID
+		income.StudentID = appointment.StudentID
+		income.AppointmentID = &appointment.ID
+		income.Description = "咨询预约：" + appointment.AppointmentTime.Format("2006-01-02 15:04")
+		if err := s.applyPlatformFee(ctx, income, now); err != nil {
+			return err
+		}
+		fulfilled, err = s.fulfillmentRepo.ConfirmAppointment(ctx, order.ID, appointment.ID, income)
+		if err != nil {
+			return err
+		}
+		if fulfilled {
+			// 会议室创建失败不影响履约，由补建任务重试
+			_ = s.meetingService.ProvisionRoom(ctx, appointment.ID)
+		}
+
+	case model.PaymentOrderTypeAppointmentPackage:
+		purchase, err := s.packageRepo.GetPurchaseByID(ctx, order.OrderRefID)
+		if err != nil {
+			return err
+		}
+		income.MentorID = purchase.MentorID
+		income.StudentID = purchase.StudentID
+		income.Description = "咨询套餐：" + purchase.Title
+		if err := s.applyPlatformFee(ctx, income, now); err != nil {
+			return err
+		}
+		fulfilled, err = s.fulfillmentRepo.ActivatePackagePurchase(ctx, order.ID, purchase.ID, income)
+		if err != nil {
+			return err
+		}
+
+	default:
+		return nil
+	}
+
+	if fulfilled {
+		logger.Info("支付订单已履约", logger.String("order_id", order.ID), logger.String("order_type", order.OrderType), logger.String("ref_id", order.OrderRefID))
+		// 票据开具失败不影响履约，由补开任务重试
+		if err := s.invoiceService.IssueOrderInvoices(ctx, order.ID); err != nil {
+			logger.Warn("支付订单票据开具失败", logger.String("order_id", order.ID), logger.String("error", err.Error()))
+		}
+	}
+	return nil
+}
+
+// ReleaseOrder 支付失败或过期后释放待支付的报名、预约或套餐购买
+func (s *fulfillmentService) ReleaseOrder(ctx context.Context, orderID string) error {
+	order, err := s.paymentRepo.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	if order.Status == "completed" {
+		return nil
+	}
+
+	var released bool
+	switch order.OrderType {
+	case model.PaymentOrderTypeCourseEnrollment:
+		released, err = s.fulfillmentRepo.ReleaseEnrollment(ctx, order.ID, order.OrderRefID)
+	case model.PaymentOrderTypeAppointment:
+		released, err = s.fulfillmentRepo.ReleaseAppointment(ctx, order.ID, order.OrderRefID)
+	case model.PaymentOrderTypeAppointmentPackage:
+		released, err = s.fulfillmentRepo.ReleasePackagePurchase(ctx, order.ID, order.OrderRefID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if released {
+		logger.Info("支付未完成，已释放业务订单", logger.String("order_id", order.ID), logger.String("order_type", order.OrderType), logger.String("ref_id", order.OrderRefID))
+	}
+	return nil
+}
+
+// ReverseIncome 退款完成后按退款金额冲正大师收入，重复调用不会重复冲正
+// 平台费按累计退款占原收入的比例退还，多次部分退款时不会累积舍入误差
+// 咨询套餐同时收回退款对应的次数，并关闭因此取消的预约的会议室
+func (s *fulfillmentService) ReverseIncome(ctx context.Context, refundID string) error {
+	refund, err := s.paymentRepo.GetRefundByID(ctx, refundID)
+	if err != nil {
+		return err
+	}
+	if refund.Status != "completed" {
+		return nil
+	}
+	paymentRecord, err := s.paymentRepo.GetPaymentRecordByID(ctx, refund.PaymentID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	result, err := s.fulfillmentRepo.ReverseIncome(ctx, paymentRecord.OrderID, refund.ID, func(source *model.IncomeTransactionModel, reversedAmount, reversedFee float64) *model.IncomeTransactionModel {
+		amount := math.Min(refund.Amount, math.Round((source.Amount-reversedAmount)*100)/100)
+		fee := 0.0
+		if source.Amount > 0 {
+			totalFee := math.Min(math.Round(source.PlatformFee*(reversedAmount+amount)/source.Amount*100)/100, source.PlatformFee)
+			fee = math.Round((totalFee-reversedFee)*100) / 100
+		}
+		return &model.IncomeTransactionModel{
+			MentorID:        source.MentorID,
+			StudentID:       source.StudentID,
+			TransactionType: model.IncomeTransactionTypeRefund,
+			Amount:          -amount,
+			PlatformFee:     -fee,
+			NetIncome:       -math.Round((amount-fee)*100) / 100,
+			Status:          "completed",
+			Description:     "退款：" + source.Description,
+			CourseID:        source.CourseID,
+			AppointmentID:   source.AppointmentID,
+			FeeRuleID:       source.FeeRuleID,
+			CompletedAt:     &now,
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, appointmentID := range result.CancelledAppointmentIDs {
+		logger.Info("套餐退款已取消未开始的预约", logger.String("refund_id", refund.ID), logger.String("appointment_id", appointmentID))
+		if err := s.meetingService.CloseRoom(ctx, appointmentID); err != nil {
+			logger.Error("关闭会议室失败", logger.String("appointment_id", appointmentID), logger.String("error", err.Error()))
+		}
+	}
+	if result.Reversed {
+		logger.Info("退款已冲正大师收入", logger.String("refund_id", refund.ID), logger.String("order_id", paymentRecord.OrderID), logger.Float64("amount", refund.Amount))
+		// 红字票据开具失败不影响冲正，由补开任务重试
+		if err := s.invoiceService.IssueRefundCreditNotes(ctx, refund.ID); err != nil {
+			logger.Warn("退款红字票据开具失败", logger.String("refund_id", refund.ID), logger.String("error", err.Error()))
+		}
+	}
+	return nil
+}
+
+// applyPlatformFee 按订单类型、大师领域和等级匹配费率规则，计算平台佣金和大师净收入并记录所用规则
+func (s *fulfillmentService) applyPlatformFee(ctx context.Context, income *model.IncomeTransactionModel, at time.Time) error {
+	quote, err := s.feeRuleService.PlatformFee(ctx, income.MentorID, income.TransactionType, income.Amount, at)
+	if err != nil {
+		return err
+	}
+	income.PlatformFee = quote.Fee
+	income.NetIncome = math.Round((income.Amount-quote.Fee)*100) / 100
+	income.FeeRuleID = quote.RuleID
+	return nil
+}
@@ -0,0 +1,556 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"master-guide-backend/internal/model"
+	"master-guide-backend/internal/repository"
+	"master-guide-backend/internal/utils"
+	"master-guide-backend/pkg/config"
+	"master-guide-backend/pkg/logger"
+	"master-guide-backend/pkg/report"
+
+	"gorm.io/gorm"
+)
+
+// IncomeReportService 收入报告服务接口
+type IncomeReportService interface {
+	ExportIncomeReport(ctx context.Context, mentorID string, req *model.IncomeExportRequest) (*model.IncomeExportResponse, error)
+	GetIncomeReport(ctx context.Context, mentorID, reportID string) (*model.IncomeExportResponse, error)
+	OpenReportDownload(ctx context.Context, reportID, expires, signature string) (*IncomeReportFile, error)
+	GenerateQueuedReports(ctx context.Context, limit int) (int, error)
+	CleanupExpiredReports(ctx context.Context, limit int) (int, error)
+}
+
+// IncomeReportFile 待下载的收入报告文件
+type IncomeReportFile struct {
+	FileName    string
+	ContentType string
+	Data        []byte
+}
+
+// 收入报告默认配置
+const (
+	defaultReportLinkExpireHours = 168
+	defaultReportSyncMaxDays     = 31
+	defaultReportStorageDir      = "storage/reports"
+	maxIncomeReportDays          = 366 * 3
+	incomeReportMaxAttempts      = 3
+	incomeReportStaleAfter       = 30 * time.Minute
+)
+
+// incomeCategoryLabels 收入类型名称
+var incomeCategoryLabels = map[string]string{
+	"all":                 "全部",
+	"course_enrollment":   "课程报名",
+	"appointment":         "咨询预约",
+	"appointment_package": "咨询套餐",
+}
+
+// withdrawalStatusLabels 提现状态名称
+var withdrawalStatusLabels = map[string]string{
+	model.WithdrawalStatusPending:    "待审核",
+	model.WithdrawalStatusApproved:   "已审核",
+	model.WithdrawalStatusRejected:   "已驳回",
+	model.WithdrawalStatusProcessing: "打款中",
+	model.WithdrawalStatusCompleted:  "已到账",
+	model.WithdrawalStatusFailed:     "打款失败",
+}
+
+// incomeReportService 收入报告服务实现
+type incomeReportService struct {
+	reportRepo          repository.IncomeReportRepository
+	mentorRepo          repository.MentorRepository
+	notificationService NotificationService
+	fileUtils           *utils.FileUtils
+	config              config.IncomeReportConfig
+}
+
+// NewIncomeReportService 创建收入报告服务实例
+func NewIncomeReportService(reportRepo repository.IncomeReportRepository, mentorRepo repository.MentorRepository, notificationService NotificationService, cfg config.IncomeReportConfig) IncomeReportService {
+	if cfg.LinkExpireHours <= 0 {
+		cfg.LinkExpireHours = defaultReportLinkExpireHours
+	}
+	if cfg.SyncMaxDays <= 0 {
+		cfg.SyncMaxDays = defaultReportSyncMaxDays
+	}
+	if cfg.StorageDir == "" {
+		cfg.StorageDir = defaultReportStorageDir
+	}
+	return &incomeReportService{
+		reportRepo:          reportRepo,
+		mentorRepo:          mentorRepo,
+		notificationService: notificationService,
+		fileUtils:           utils.NewFileUtils(),
+		config:              cfg,
+	}
+}
+
+// ExportIncomeReport 导出收入报告，区间不超过同步天数时立即生成，否则排队后台生成并在完成后通知大师
+func (s *incomeReportService) ExportIncomeReport(ctx context.Context, mentorID string, req *model.IncomeExportRequest) (*model.IncomeExportResponse, error) {
+	if s.config.SigningSecret == "" {
+		return nil, errors.New("报告导出服务未配置")
+	}
+
+	startDate := truncateToDate(req.StartDate)
+	endDate := truncateToDate(req.EndDate)
+	if endDate.Before(startDate) {
+		return nil, errors.New("结束日期不能早于开始日期")
+	}
+	days := int(endDate.Sub(startDate).Hours()/24) + 1
+	if days > maxIncomeReportDays {
+		return nil, errors.New("导出区间不能超过3年")
+	}
+
+	incomeReport := &model.IncomeReport{
+		MentorID:   mentorID,
+		Format:     req.Format,
+		IncomeType: req.Type,
+		StartDate:  startDate,
+		EndDate:    endDate,
+		Status:     model.IncomeReportStatusPending,
+	}
+	if err := s.reportRepo.CreateReport(ctx, incomeReport); err != nil {
+		return nil, err
+	}
+
+	if days <= s.config.SyncMaxDays {
+		if err := s.generate(ctx, incomeReport); err != nil {
+			logger.Error("收入报告生成失败", logger.String("report_id", incomeReport.ID), logger.String("error", err.Error()))
+			if err := s.reportRepo.FailReport(ctx, incomeReport.ID, err.Error(), false); err != nil {
+				logger.Error("记录收入报告失败状态失败", logger.String("report_id", incomeReport.ID), logger.String("error", err.Error()))
+			}
+			return nil, errors.New("报告生成失败")
+		}
+	}
+
+	return s.toResponse(incomeReport), nil
+}
+
+// GetIncomeReport 获取收入报告状态，已生成的报告返回签名下载地址
+func (s *incomeReportService) GetIncomeReport(ctx context.Context, mentorID, reportID string) (*model.IncomeExportResponse, error) {
+	incomeReport, err := s.reportRepo.GetReport(ctx, reportID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && incomeReport.MentorID != mentorID) {
+		return nil, errors.New("报告不存在")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.toResponse(incomeReport), nil
+}
+
+// OpenReportDownload 校验签名下载地址并读取报告文件
+func (s *incomeReportService) OpenReportDownload(ctx context.Context, reportID, expires, signature string) (*IncomeReportFile, error) {
+	expiresUnix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || s.config.SigningSecret == "" {
+		return nil, errors.New("下载地址无效")
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(s.sign(reportID, expiresUnix), expected) {
+		return nil, errors.New("下载地址无效")
+	}
+	now := time.Now()
+	if now.Unix() > expiresUnix {
+		return nil, errors.New("下载地址已过期")
+	}
+
+	incomeReport, err := s.reportRepo.GetReport(ctx, reportID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("报告不存在")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if incomeReport.Status == model.IncomeReportStatusExpired || (incomeReport.ExpiresAt != nil && now.After(*incomeReport.ExpiresAt)) {
+		return nil, errors.New("下载地址已过期")
+	}
+	if incomeReport.Status != model.IncomeReportStatusCompleted || incomeReport.UploadFile == nil {
+		return nil, errors.New("报告不存在")
+	}
+
+	data, err := os.ReadFile(incomeReport.UploadFile.FilePath)
+	if os.IsNotExist(err) {
+		return nil, errors.New("报告文件不存在")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &IncomeReportFile{
+		FileName:    incomeReport.UploadFile.OriginalName,
+		ContentType: incomeReport.UploadFile.MimeType,
+		Data:        data,
+	}, nil
+}
+
+// GenerateQueuedReports 生成排队中的收入报告，返回成功生成的数量
+// 生成失败的报告重新排队，超过最大尝试次数后标记为失败并通知大师
+func (s *incomeReportService) GenerateQueuedReports(ctx context.Context, limit int) (int, error) {
+	reports, err := s.reportRepo.ClaimPendingReports(ctx, time.Now().Add(-incomeReportStaleAfter), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, incomeReport := range reports {
+		if err := s.generate(ctx, incomeReport); err != nil {
+			retry := incomeReport.Attempts < incomeReportMaxAttempts
+			logger.Error("收入报告生成失败", logger.String("report_id", incomeReport.ID), logger.Int("attempts", incomeReport.Attempts), logger.String("error", err.Error()))
+			if err := s.reportRepo.FailReport(ctx, incomeReport.ID, err.Error(), retry); err != nil {
+				logger.Error("记录收入报告失败状态失败", logger.String("report_id", incomeReport.ID), logger.String("error", err.Error()))
+			}
+			if !retry {
+				incomeReport.Status = model.IncomeReportStatusFailed
+				s.notify(ctx, incomeReport, "收入报告生成失败",
+					fmt.Sprintf("您导出的%s收入报告生成失败，请稍后重新导出", s.periodText(incomeReport)))
+			}
+			continue
+		}
+		count++
+		s.notify(ctx, incomeReport, "收入报告已生成",
+			fmt.Sprintf("您导出的%s收入报告已生成，请在%s前下载", s.periodText(incomeReport), incomeReport.ExpiresAt.Format("2006-01-02 15:04")))
+	}
+	if count > 0 {
+		logger.Info("收入报告已生成", logger.Int("count", count))
+	}
+	return count, nil
+}
+
+// CleanupExpiredReports 删除下载地址已过期的报告文件，返回清理数量
+func (s *incomeReportService) CleanupExpiredReports(ctx context.Context, limit int) (int, error) {
+	reports, err := s.reportRepo.ListExpiredReports(ctx, time.Now(), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, incomeReport := range reports {
+		if incomeReport.UploadFile != nil {
+			if err := os.Remove(incomeReport.UploadFile.FilePath); err != nil && !os.IsNotExist(err) {
+				logger.Error("删除过期收入报告文件失败", logger.String("report_id", incomeReport.ID), logger.String("error", err.Error()))
+				continue
+			}
+		}
+		if err := s.reportRepo.ExpireReport(ctx, incomeReport); err != nil {
+			logger.Error("标记收入报告过期失败", logger.String("report_id", incomeReport.ID), logger.String("error", err.Error()))
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+// generate 查询报告区间内的收入和提现，生成文件并保存，成功后更新 incomeReport 的状态
+func (s *incomeReportService) generate(ctx context.Context, incomeReport *model.IncomeReport) error {
+	mentor, err := s.mentorRepo.GetMentorByID(ctx, incomeReport.MentorID)
+	if err != nil {
+		return err
+	}
+
+	incomeType := incomeReport.IncomeType
+	if incomeType == "all" {
+		incomeType = ""
+	}
+	endExclusive := incomeReport.EndDate.AddDate(0, 0, 1)
+	lines, err := s.reportRepo.ListReportLines(ctx, incomeReport.MentorID, incomeType, incomeReport.StartDate, endExclusive)
+	if err != nil {
+		return err
+	}
+	withdrawals, err := s.reportRepo.ListReportWithdrawals(ctx, incomeReport.MentorID, incomeReport.StartDate, endExclusive)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	doc := buildIncomeReportDocument(incomeReport, mentor, lines, withdrawals, now)
+	data, err := report.Encode(doc, incomeReport.Format)
+	if err != nil {
+		return err
+	}
+
+	ext := report.Extension(incomeReport.Format)
+	path, err := s.fileUtils.SaveFile(s.config.StorageDir, ext, data)
+	if err != nil {
+		return err
+	}
+
+	file := &model.UploadFile{
+		OriginalName: fmt.Sprintf("income_report_%s_%s%s", incomeReport.StartDate.Format("20060102"), incomeReport.EndDate.Format("20060102"), ext),
+		FilePath:     path,
+		FileURL:      fmt.Sprintf("/income/reports/%s/download", incomeReport.ID),
+		FileType:     ext[1:],
+		FileSize:     int64(len(data)),
+		MimeType:     report.ContentType(incomeReport.Format),
+		UserID:       mentor.UserID,
+		UploadType:   model.UploadTypeIncomeReport,
+	}
+	expiresAt := now.Add(time.Duration(s.config.LinkExpireHours) * time.Hour).Truncate(time.Second)
+	if err := s.reportRepo.CompleteReport(ctx, incomeReport.ID, file, now, expiresAt); err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	incomeReport.Status = model.IncomeReportStatusCompleted
+	incomeReport.UploadFileID = &file.ID
+	incomeReport.UploadFile = file
+	incomeReport.CompletedAt = &now
+	incomeReport.ExpiresAt = &expiresAt
+	return nil
+}
+
+// toResponse 转换为导出响应，已生成且未过期的报告附带签名下载地址
+func (s *incomeReportService) toResponse(incomeReport *model.IncomeReport) *model.IncomeExportResponse {
+	response := &model.IncomeExportResponse{
+		ReportID:  incomeReport.ID,
+		Status:    incomeReport.Status,
+		Format:    incomeReport.Format,
+		StartDate: incomeReport.StartDate.Format("2006-01-02"),
+		EndDate:   incomeReport.EndDate.Format("2006-01-02"),
+		CreatedAt: incomeReport.CreatedAt,
+	}
+	switch incomeReport.Status {
+	case model.IncomeReportStatusCompleted:
+		if incomeReport.ExpiresAt == nil || time.Now().After(*incomeReport.ExpiresAt) {
+			response.Status = model.IncomeReportStatusExpired
+			break
+		}
+		response.ExpiresAt = incomeReport.ExpiresAt
+		response.DownloadURL = s.downloadURL(incomeReport.ID, *incomeReport.ExpiresAt)
+	case model.IncomeReportStatusFailed:
+		response.ErrorMessage = "报告生成失败，请稍后重新导出"
+	}
+	return response
+}
+
+// downloadURL 生成在 expiresAt 前有效的签名下载地址
+func (s *incomeReportService) downloadURL(reportID string, expiresAt time.Time) string {
+	expires := expiresAt.Unix()
+	return fmt.Sprintf("%s/income/reports/%s/download?expires=%d&signature=%s",
+		strings.TrimRight(s.config.APIBaseURL, "/"), reportID, expires, hex.EncodeToString(s.sign(reportID, expires)))
+}
+
+// sign 计算下载地址的 HMAC-SHA256 签名，签名覆盖报告ID和过期时间
+func (s *incomeReportService) sign(reportID string, expires int64) []byte {
+	mac := hmac.New(sha256.New, []byte(s.config.SigningSecret))
+	mac.Write([]byte(reportID + ":" + strconv.FormatInt(expires, 10)))
+	return mac.Sum(nil)
+}
+
+// notify 发送收入报告生成结果通知
+func (s *incomeReportService) notify(ctx context.Context, incomeReport *model.IncomeReport, title, content string) {
+	mentor, err := s.mentorRepo.GetMentorByID(ctx, incomeReport.MentorID)
+	if err == nil {
+		_, err = s.notificationService.SendNotification(ctx, &model.SendNotificationRequest{
+			UserIDs: []string{mentor.UserID},
+			Type:    model.NotificationTypeIncomeReport,
+			Title:   title,
+			Content: content,
+			RelatedData: map[string]interface{}{
+				"report_id": incomeReport.ID,
+				"status":    incomeReport.Status,
+				"format":    incomeReport.Format,
+			},
+		})
+	}
+	if err != nil {
+		logger.Error("发送收入报告通知失败", logger.String("report_id", incomeReport.ID), logger.String("error", err.Error()))
+	}
+}
+
+// periodText 报告区间的展示文本
+func (s *incomeReportService) periodText(incomeReport *model.IncomeReport) string {
+	return incomeReport.StartDate.Format("2006-01-02") + "至" + incomeReport.EndDate.Format("2006-01-02")
+}
+
+// incomeReportItem 按服务项目汇总的收入
+type incomeReportItem struct {
+	category    string
+	name        string
+	count       int
+	income      float64
+	refund      float64
+	platformFee float64
+	netIncome   float64
+}
+
+// buildIncomeReportDocument 组装收入报告：汇总、按服务项目汇总、收入明细和提现记录
+func buildIncomeReportDocument(incomeReport *model.IncomeReport, mentor *model.Mentor, lines []*model.IncomeReportLine, withdrawals []*model.WithdrawalModel, generatedAt time.Time) *report.Document {
+	mentorName := mentor.ID
+	if mentor.Profile != nil && mentor.Profile.Name != "" {
+		mentorName = mentor.Profile.Name
+	}
+
+	var (
+		incomeCount, refundCount       int
+		income, refund, fee, netIncome float64
+		items                          = make(map[string]*incomeReportItem)
+		itemKeys                       []string
+		detailRows                     = make([][]string, 0, len(lines))
+	)
+	for _, line := range lines {
+		key := line.Category + "/" + line.ItemID
+		item, ok := items[key]
+		if !ok {
+			item = &incomeReportItem{category: line.Category, name: line.ItemName}
+			if item.name == "" {
+				item.name = line.ItemID
+			}
+			items[key] = item
+			itemKeys = append(itemKeys, key)
+		}
+
+		kind := "收入"
+		if line.TransactionType == model.IncomeTransactionTypeRefund {
+			kind = "退款"
+			refundCount++
+			refund += line.Amount
+			item.refund += line.Amount
+		} else {
+			incomeCount++
+			income += line.Amount
+			item.count++
+			item.income += line.Amount
+		}
+		fee += line.PlatformFee
+		netIncome += line.NetIncome
+		item.platformFee += line.PlatformFee
+		item.netIncome += line.NetIncome
+
+		detailRows = append(detailRows, []string{
+			line.CreatedAt.Format("2006-01-02 15:04"),
+			line.ID,
+			incomeCategoryLabels[line.Category] + kind,
+			item.name,
+			line.StudentName,
+			formatAmount(line.Amount),
+			formatAmount(line.PlatformFee),
+			formatAmount(line.NetIncome),
+		})
+	}
+
+	sort.SliceStable(itemKeys, func(i, j int) bool {
+		a, b := items[itemKeys[i]], items[itemKeys[j]]
+		if a.category != b.category {
+			return a.category < b.category
+		}
+		return a.netIncome > b.netIncome
+	})
+	itemRows := make([][]string, 0, len(itemKeys))
+	for _, key := range itemKeys {
+		item := items[key]
+		itemRows = append(itemRows, []string{
+			incomeCategoryLabels[item.category],
+			item.name,
+			strconv.Itoa(item.count),
+			formatAmount(item.income),
+			formatAmount(item.refund),
+			formatAmount(item.platformFee),
+			formatAmount(item.netIncome),
+		})
+	}
+
+	var (
+		requestedCount, paidCount      int
+		requested, withdrawalFee, paid float64
+		withdrawalRows                 = make([][]string, 0, len(withdrawals))
+	)
+	for _, withdrawal := range withdrawals {
+		if withdrawal.Status != model.WithdrawalStatusRejected && withdrawal.Status != model.WithdrawalStatusFailed {
+			requestedCount++
+			requested += withdrawal.Amount
+			withdrawalFee += withdrawal.Fee
+		}
+		completedAt := ""
+		if withdrawal.Status == model.WithdrawalStatusCompleted {
+			paidCount++
+			paid += withdrawal.NetAmount
+			if withdrawal.CompletedAt != nil {
+				completedAt = withdrawal.CompletedAt.Format("2006-01-02 15:04")
+			}
+		}
+		withdrawalRows = append(withdrawalRows, []string{
+			withdrawal.CreatedAt.Format("2006-01-02 15:04"),
+			withdrawal.ID,
+			formatAmount(withdrawal.Amount),
+			formatAmount(withdrawal.Fee),
+			formatAmount(withdrawal.NetAmount),
+			withdrawal.BankName,
+			withdrawal.BankAccountMasked,
+			withdrawalStatusLabels[withdrawal.Status],
+			completedAt,
+		})
+	}
+
+	amountColumns := func(titles ...string) []report.Column {
+		columns := make([]report.Column, len(titles))
+		for i, title := range titles {
+			columns[i] = report.Column{Title: title, Numeric: true}
+		}
+		return columns
+	}
+
+	return &report.Document{
+		Title: "收入报告",
+		Lines: []string{
+			"大师：" + mentorName,
+			fmt.Sprintf("统计区间：%s 至 %s", incomeReport.StartDate.Format("2006-01-02"), incomeReport.EndDate.Format("2006-01-02")),
+			"收入类型：" + incomeCategoryLabels[incomeReport.IncomeType],
+			"生成时间：" + generatedAt.Format("2006-01-02 15:04:05"),
+			"金额单位：元；退款及其冲回的平台服务费以负数表示，提现统计不含已驳回和打款失败的申请",
+		},
+		Tables: []*report.Table{
+			{
+				Title:   "汇总",
+				Columns: append([]report.Column{{Title: "项目"}}, amountColumns("笔数", "金额")...),
+				Rows: [][]string{
+					{"收入", strconv.Itoa(incomeCount), formatAmount(income)},
+					{"退款", strconv.Itoa(refundCount), formatAmount(refund)},
+					{"平台服务费", "", formatAmount(fee)},
+					{"净收入", "", formatAmount(netIncome)},
+					{"提现申请", strconv.Itoa(requestedCount), formatAmount(requested)},
+					{"提现手续费", "", formatAmount(withdrawalFee)},
+					{"提现已到账", strconv.Itoa(paidCount), formatAmount(paid)},
+				},
+			},
+			{
+				Title:   "按服务项目",
+				Columns: append([]report.Column{{Title: "类型"}, {Title: "项目"}}, amountColumns("笔数", "收入", "退款", "平台服务费", "净收入")...),
+				Rows:    itemRows,
+			},
+			{
+				Title:   "收入明细",
+				Columns: append([]report.Column{{Title: "时间"}, {Title: "流水号"}, {Title: "类型"}, {Title: "项目"}, {Title: "学员"}}, amountColumns("金额", "平台服务费", "净收入")...),
+				Rows:    detailRows,
+			},
+			{
+				Title:   "提现记录",
+				Columns: append(append([]report.Column{{Title: "申请时间"}, {Title: "提现单号"}}, amountColumns("提现金额", "手续费", "到账金额")...), report.Column{Title: "银行"}, report.Column{Title: "账号"}, report.Column{Title: "状态"}, report.Column{Title: "到账时间"}),
+				Rows:    withdrawalRows,
+			},
+		},
+	}
+}
+
+// truncateToDate 取日期部分
+func truncateToDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// formatAmount 金额保留两位小数
+func formatAmount(amount float64) string {
+	rounded := math.Round(amount*100) / 100
+	if rounded == 0 {
+		rounded = 0 // 避免输出 -0.00
+	}
+	return strconv.FormatFloat(rounded, 'f', 2, 64)
+}
@@ -3,11 +3,15 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 	"math"
 	"time"
 
 	"master-guide-backend/internal/model"
 	"master-guide-backend/internal/repository"
+	"master-guide-backend/internal/utils"
+	"master-guide-backend/pkg/config"
+	"master-guide-backend/pkg/logger"
 )
 
 // IncomeService 收入服务接口
@@ -15,21 +19,38 @@ type IncomeService interface {
 	GetIncomeStats(ctx context.Context, mentorID string, req *model.IncomeStatsRequest) (*model.IncomeStatsResponse, error)
 	GetIncomeTransactions(ctx context.Context, mentorID string, req *model.IncomeTransactionsRequest) (*model.IncomeTransactionsResponse, error)
 	GetIncomeTrends(ctx context.Context, mentorID string, req *model.IncomeTrendsRequest) (*model.IncomeTrendsResponse, error)
-	ExportIncomeReport(ctx context.Context, mentorID string, req *model.IncomeExportRequest) (*model.IncomeExportResponse, error)
 	GetWithdrawals(ctx context.Context, mentorID string, req *model.WithdrawalsRequest) (*model.WithdrawalsResponse, error)
 	CreateWithdrawal(ctx context.Context, mentorID string, req *model.CreateWithdrawalRequest) (*model.CreateWithdrawalResponse, error)
 	GetAvailableIncome(ctx context.Context, mentorID string) (*model.AvailableIncomeResponse, error)
+	SettleMaturedIncome(ctx context.Context, limit int) (int, error)
 }
 
+// defaultCourseCompletionDays 未配置时学员报名后视为课程服务完成的天数
+const defaultCourseCompletionDays = 30
+
 // incomeService 收入服务实现
 type incomeService struct {
-	incomeRepo repository.IncomeRepository
+	incomeRepo        repository.IncomeRepository
+	feeRuleService    FeeRuleService
+	bankAccountCipher *utils.FieldCipher
+	notifier          *withdrawalNotifier
+	settlement        config.SettlementConfig
 }
 
-// NewIncomeService 创建收入服务实例
-func NewIncomeService(incomeRepo repository.IncomeRepository) IncomeService {
+// NewIncomeService 创建收入服务实例，bankAccountCipher 用于加密提现银行账号
+func NewIncomeService(incomeRepo repository.IncomeRepository, mentorRepo repository.MentorRepository, feeRuleService FeeRuleService, notificationService NotificationService, bankAccountCipher *utils.FieldCipher, settlement config.SettlementConfig) IncomeService {
+	if settlement.HoldingDays < 0 {
+		settlement.HoldingDays = 0
+	}
+	if settlement.CourseCompletionDays <= 0 {
+		settlement.CourseCompletionDays = defaultCourseCompletionDays
+	}
 	return &incomeService{
-		incomeRepo: incomeRepo,
+		settlement:        settlement,
+		incomeRepo:        incomeRepo,
+		feeRuleService:    feeRuleService,
+		bankAccountCipher: bankAccountCipher,
+		notifier:          &withdrawalNotifier{mentorRepo: mentorRepo, notificationService: notificationService},
 	}
 }
 
@@ -89,23 +110,6 @@ func (s *incomeService) GetIncomeTrends(ctx context.Context, mentorID string, re
 	}, nil
 }
 
-// ExportIncomeReport 导出收入报告
-func (s *incomeService) ExportIncomeReport(ctx context.Context, mentorID string, req *model.IncomeExportRequest) (*model.IncomeExportResponse, error) {
-	// 生成报告文件名
-	fileName := s.generateReportFileName(req.Format, req.StartDate, req.EndDate)
-
-	// 生成下载URL（这里简化处理，实际应该上传到文件存储服务）
-	downloadURL := "https://example.com/reports/" + fileName
-
-	// 设置过期时间（7天后）
-	expiresAt := time.Now().AddDate(0, 0, 7)
-
-	return &model.IncomeExportResponse{
-		DownloadURL: downloadURL,
-		ExpiresAt:   expiresAt,
-	}, nil
-}
-
 // GetWithdrawals 获取提现记录
 func (s *incomeService) GetWithdrawals(ctx context.Context, mentorID string, req *model.WithdrawalsRequest) (*model.WithdrawalsResponse, error) {
 	// 设置默认值
@@ -155,26 +159,47 @@ func (s *incomeService) CreateWithdrawal(ctx context.Context, mentorID string, r
 		return nil, errors.New("提现金额超过最大提现限制")
 	}
 
-	// 计算手续费（简化处理，实际应该有复杂的费率计算）
-	fee := s.calculateWithdrawalFee(req.Amount)
-	netAmount := req.Amount - fee
+	if s.bankAccountCipher == nil {
+		return nil, errors.New("提现服务未配置")
+	}
+
+	// 按费率规则计算手续费
+	quote, err := s.feeRuleService.WithdrawalFee(ctx, mentorID, req.Amount, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	netAmount := math.Round((req.Amount-quote.Fee)*100) / 100
+
+	// 银行账号加密存储，另存脱敏账号用于展示
+	bankAccount, err := s.bankAccountCipher.Encrypt(req.BankAccount)
+	if err != nil {
+		return nil, err
+	}
 
 	// 创建提现记录
 	withdrawal := &model.WithdrawalModel{
-		MentorID:    mentorID,
-		Amount:      req.Amount,
-		Fee:         fee,
-		NetAmount:   netAmount,
-		Status:      "pending",
-		BankAccount: req.BankAccount,
-		BankName:    req.BankName,
+		MentorID:          mentorID,
+		Amount:            req.Amount,
+		Fee:               quote.Fee,
+		NetAmount:         netAmount,
+		Status:            model.WithdrawalStatusPending,
+		BankAccount:       bankAccount,
+		BankAccountMasked: utils.MaskAccountNumber(req.BankAccount),
+		BankName:          req.BankName,
+		FeeRuleID:         quote.RuleID,
 	}
 
 	err = s.incomeRepo.CreateWithdrawal(ctx, withdrawal)
+	if errors.Is(err, repository.ErrInsufficientBalance) {
+		return nil, errors.New("可提现金额不足")
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	s.notifier.notify(ctx, withdrawal, "提现申请已提交",
+		fmt.Sprintf("您申请提现¥%.2f至%s（%s），平台审核通过后将安排打款", withdrawal.Amount, withdrawal.BankName, withdrawal.BankAccountMasked))
+
 	// 估算完成时间（2-3个工作日）
 	estimatedCompletionTime := time.Now().AddDate(0, 0, 3)
 
@@ -193,6 +218,8 @@ func (s *incomeService) GetAvailableIncome(ctx context.Context, mentorID string)
 
 	return &model.AvailableIncomeResponse{
 		AvailableAmount: available.AvailableAmount,
+		FrozenAmount:    available.FrozenAmount,
+		SettlementDays:  s.settlement.HoldingDays,
 		PendingAmount:   available.PendingAmount,
 		TotalEarned:     available.TotalEarned,
 		TotalWithdrawn:  available.TotalWithdrawn,
@@ -201,6 +228,33 @@ func (s *incomeService) GetAvailableIncome(ctx context.Context, mentorID string)
 	}, nil
 }
 
+// SettleMaturedIncome 结算到期的冻结收入，返回结算笔数
+// 服务完成满结算天数的收入扣除退款后转为可提现，已全额退款的收入直接关闭；单笔失败不影响其余收入，下次执行时重试
+func (s *incomeService) SettleMaturedIncome(ctx context.Context, limit int) (int, error) {
+	now := time.Now()
+	completedBefore := now.AddDate(0, 0, -s.settlement.HoldingDays)
+	ids, err := s.incomeRepo.ListSettleableIncomeIDs(ctx, completedBefore, s.settlement.CourseCompletionDays, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, id := range ids {
+		settled, err := s.incomeRepo.SettleIncome(ctx, id, now)
+		if err != nil {
+			logger.Error("收入结算失败", logger.String("income_id", id), logger.String("error", err.Error()))
+			continue
+		}
+		if settled {
+			count++
+		}
+	}
+	if count > 0 {
+		logger.Info("到期收入已结算", logger.Int("count", count))
+	}
+	return count, nil
+}
+
 // getDateRange 根据周期获取时间范围
 func (s *incomeService) getDateRange(period string, startDate, endDate time.Time) (time.Time, time.Time) {
 	now := time.Now()
@@ -228,23 +282,3 @@ func (s *incomeService) getDateRange(period string, startDate, endDate time.Time
 		return time.Date(2020, 1, 1, 0, 0, 0, 0, now.Location()), now
 	}
 }
-
-// generateReportFileName 生成报告文件名
-func (s *incomeService) generateReportFileName(format string, startDate, endDate time.Time) string {
-	startStr := startDate.Format("20060102")
-	endStr := endDate.Format("20060102")
-	return "income_report_" + startStr + "_" + endStr + "." + format
-}
-
-// calculateWithdrawalFee 计算提现手续费
-func (s *incomeService) calculateWithdrawalFee(amount float64) float64 {
-	// 简化费率计算：金额的0.2%，最低1元，最高50元
-	fee := amount * 0.002
-	if fee < 1 {
-		fee = 1
-	}
-	if fee > 50 {
-		fee = 50
-	}
-	return fee
-}
@@ -0,0 +1,419 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"master-guide-backend/internal/model"
+	"master-guide-backend/internal/repository"
+	"master-guide-backend/pkg/config"
+	"master-guide-backend/pkg/logger"
+	"master-guide-backend/pkg/report"
+)
+
+// InvoiceService 票据服务接口
+// 支付订单履约后为学生开具服务收据、为大师开具平台服务费账单，退款冲正后开具对应的红字票据；票据开具后不可修改
+type InvoiceService interface {
+	IssueOrderInvoices(ctx context.Context, orderID string) error
+	IssueRefundCreditNotes(ctx context.Context, refundID string) error
+	IssueMissingInvoices(ctx context.Context, limit int) (int, error)
+	ListInvoices(ctx context.Context, userID string, req *model.InvoiceListRequest) (*model.InvoiceListResponse, error)
+	GetInvoice(ctx context.Context, userID, invoiceID string) (*model.Invoice, error)
+	RenderInvoice(ctx context.Context, userID, invoiceID, format string) (*InvoiceFile, error)
+}
+
+// InvoiceFile 待下载的票据文件
+type InvoiceFile struct {
+	FileName    string
+	ContentType string
+	Data        []byte
+}
+
+// 票据下载格式
+const (
+	InvoiceFormatPDF  = "pdf"
+	InvoiceFormatJSON = "json"
+)
+
+// invoiceSeries 票据编号系列，按类别和票据类型分别连续编号
+var invoiceSeries = map[string]map[string]string{
+	model.InvoiceCategoryService: {
+		model.InvoiceDocumentTypeInvoice:    "RC",
+		model.InvoiceDocumentTypeCreditNote: "RCN",
+	},
+	model.InvoiceCategoryPlatformFee: {
+		model.InvoiceDocumentTypeInvoice:    "PF",
+		model.InvoiceDocumentTypeCreditNote: "PFCN",
+	},
+}
+
+// invoiceTitles 票据标题
+var invoiceTitles = map[string]map[string]string{
+	model.InvoiceCategoryService: {
+		model.InvoiceDocumentTypeInvoice:    "服务收据",
+		model.InvoiceDocumentTypeCreditNote: "服务收据（红字）",
+	},
+	model.InvoiceCategoryPlatformFee: {
+		model.InvoiceDocumentTypeInvoice:    "平台服务费账单",
+		model.InvoiceDocumentTypeCreditNote: "平台服务费账单（红字）",
+	},
+}
+
+// invoiceOrderTypeLabels 订单类型名称
+var invoiceOrderTypeLabels = map[string]string{
+	model.PaymentOrderTypeCourseEnrollment:   "课程报名",
+	model.PaymentOrderTypeAppointment:        "咨询预约",
+	model.PaymentOrderTypeAppointmentPackage: "咨询套餐",
+}
+
+// invoiceService 票据服务实现
+type invoiceService struct {
+	invoiceRepo repository.InvoiceRepository
+	cfg         config.InvoiceConfig
+}
+
+// NewInvoiceService 创建票据服务实例
+func NewInvoiceService(invoiceRepo repository.InvoiceRepository, cfg config.InvoiceConfig) InvoiceService {
+	return &invoiceService{
+		invoiceRepo: invoiceRepo,
+		cfg:         cfg,
+	}
+}
+
+// IssueOrderInvoices 为已履约的支付订单开具服务收据和平台服务费账单，重复调用不会重复开具
+func (s *invoiceService) IssueOrderInvoices(ctx context.Context, orderID string) error {
+	income, err := s.invoiceRepo.GetIncomeByPaymentOrder(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	return s.issueForIncome(ctx, income)
+}
+
+// IssueRefundCreditNotes 为已冲正收入的退款开具红字票据，原票据尚未开具时先补开原票据，重复调用不会重复开具
+func (s *invoiceService) IssueRefundCreditNotes(ctx context.Context, refundID string) error {
+	income, err := s.invoiceRepo.GetIncomeByRefund(ctx, refundID)
+	if err != nil {
+		return err
+	}
+	return s.issueForIncome(ctx, income)
+}
+
+// IssueMissingInvoices 补开履约或退款冲正后未能及时开具的票据，返回本次检查的收入记录数
+func (s *invoiceService) IssueMissingInvoices(ctx context.Context, limit int) (int, error) {
+	ids, err := s.invoiceRepo.ListUninvoicedIncomeIDs(ctx, time.Now().Add(-fulfillmentRetryDelay), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		income, err := s.invoiceRepo.GetIncomeTransaction(ctx, id)
+		if err == nil {
+			err = s.issueForIncome(ctx, income)
+		}
+		if err != nil {
+			logger.Error("补开票据失败", logger.String("income_transaction_id", id), logger.String("error", err.Error()))
+		}
+	}
+	return len(ids), nil
+}
+
+// ListInvoices 分页获取用户作为购买方或销售方的票据
+func (s *invoiceService) ListInvoices(ctx context.Context, userID string, req *model.InvoiceListRequest) (*model.InvoiceListResponse, error) {
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 || req.PageSize > 100 {
+		req.PageSize = 20
+	}
+
+	invoices, total, err := s.invoiceRepo.ListInvoices(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.InvoiceListResponse{
+		Invoices: invoices,
+		Pagination: &model.PaginationResponse{
+			Total:      total,
+			Page:       req.Page,
+			PageSize:   req.PageSize,
+			TotalPages: int(math.Ceil(float64(total) / float64(req.PageSize))),
+		},
+	}, nil
+}
+
+// GetInvoice 获取票据详情，只有票据的购买方或销售方可以查看
+func (s *invoiceService) GetInvoice(ctx context.Context, userID, invoiceID string) (*model.Invoice, error) {
+	invoice, err := s.invoiceRepo.GetInvoice(ctx, invoiceID)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvoiceNotFound) {
+			return nil, errors.New("票据不存在")
+		}
+		return nil, err
+	}
+	if !invoiceVisibleTo(invoice, userID) {
+		return nil, errors.New("票据不存在")
+	}
+	return invoice, nil
+}
+
+// RenderInvoice 生成票据的 PDF 或 JSON 文件
+func (s *invoiceService) RenderInvoice(ctx context.Context, userID, invoiceID, format string) (*InvoiceFile, error) {
+	invoice, err := s.GetInvoice(ctx, userID, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case "", InvoiceFormatPDF:
+		var original *model.Invoice
+		if invoice.OriginalInvoiceID != nil {
+			original, err = s.invoiceRepo.GetInvoice(ctx, *invoice.OriginalInvoiceID)
+			if err != nil {
+				return nil, err
+			}
+		}
+		data, err := report.Encode(s.buildInvoiceDocument(invoice, original), report.FormatPDF)
+		if err != nil {
+			return nil, err
+		}
+		return &InvoiceFile{
+			FileName:    invoice.InvoiceNumber + report.Extension(report.FormatPDF),
+			ContentType: report.ContentType(report.FormatPDF),
+			Data:        data,
+		}, nil
+
+	case InvoiceFormatJSON:
+		data, err := json.MarshalIndent(invoice, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return &InvoiceFile{
+			FileName:    invoice.InvoiceNumber + ".json",
+			ContentType: "application/json; charset=utf-8",
+			Data:        data,
+		}, nil
+	}
+	return nil, errors.New("不支持的票据格式")
+}
+
+// issueForIncome 为收入记录开具服务收据和平台服务费账单；退款冲正记录开具红字票据，并关联原收入记录的同类票据
+func (s *invoiceService) issueForIncome(ctx context.Context, income *model.IncomeTransactionModel) error {
+	if income.Status != "completed" {
+		return nil
+	}
+
+	source := income
+	documentType := model.InvoiceDocumentTypeInvoice
+	if income.SourceTransactionID != nil {
+		var err error
+		source, err = s.invoiceRepo.GetIncomeTransaction(ctx, *income.SourceTransactionID)
+		if err != nil {
+			return err
+		}
+		if err := s.issueForIncome(ctx, source); err != nil {
+			return err
+		}
+		documentType = model.InvoiceDocumentTypeCreditNote
+	}
+	if source.PaymentOrderID == nil {
+		return nil
+	}
+
+	student, err := s.invoiceRepo.GetStudentParty(ctx, income.StudentID)
+	if err != nil {
+		return err
+	}
+	mentor, err := s.invoiceRepo.GetMentorParty(ctx, income.MentorID)
+	if err != nil {
+		return err
+	}
+	platform := &model.InvoiceParty{
+		Name:    s.cfg.PlatformName,
+		Email:   s.cfg.PlatformEmail,
+		TaxID:   s.cfg.PlatformTaxID,
+		Address: s.cfg.PlatformAddress,
+	}
+
+	documents := []struct {
+		category    string
+		amount      float64
+		taxRate     float64
+		description string
+		buyer       *model.InvoiceParty
+		seller      *model.InvoiceParty
+	}{
+		{model.InvoiceCategoryService, income.Amount, s.cfg.ServiceTaxRate, income.Description, student, mentor},
+		{model.InvoiceCategoryPlatformFee, income.PlatformFee, s.cfg.PlatformFeeTaxRate, "平台服务费：" + income.Description, mentor, platform},
+	}
+	for _, doc := range documents {
+		if doc.amount == 0 {
+			continue
+		}
+
+		invoice := &model.Invoice{
+			DocumentType:        documentType,
+			Category:            doc.category,
+			IncomeTransactionID: income.ID,
+			PaymentOrderID:      source.PaymentOrderID,
+			RefundID:            income.RefundID,
+			OrderType:           source.TransactionType,
+			MentorID:            income.MentorID,
+			Currency:            "CNY",
+			IssuedAt:            time.Now(),
+		}
+		if documentType == model.InvoiceDocumentTypeCreditNote {
+			// 红字票据沿用原票据的买卖双方和税率
+			original, err := s.invoiceRepo.GetInvoiceByIncome(ctx, source.ID, doc.category)
+			if err != nil {
+				return err
+			}
+			invoice.OriginalInvoiceID = &original.ID
+			copyInvoiceParties(invoice, original)
+			setInvoiceAmounts(invoice, doc.description, doc.amount, original.TaxRate)
+		} else {
+			setInvoiceParties(invoice, doc.buyer, doc.seller)
+			setInvoiceAmounts(invoice, doc.description, doc.amount, doc.taxRate)
+		}
+
+		created, err := s.invoiceRepo.CreateInvoice(ctx, invoice, invoiceSeries[doc.category][documentType])
+		if err != nil {
+			return err
+		}
+		if created {
+			logger.Info("票据已开具", logger.String("invoice_number", invoice.InvoiceNumber), logger.String("income_transaction_id", income.ID), logger.Float64("total", invoice.Total))
+		}
+	}
+	return nil
+}
+
+// setInvoiceParties 记录开具时的买卖双方信息快照，平台作为销售方时不关联用户
+func setInvoiceParties(invoice *model.Invoice, buyer, seller *model.InvoiceParty) {
+	if buyer.UserID != "" {
+		invoice.BuyerUserID = &buyer.UserID
+	}
+	invoice.BuyerName = buyer.Name
+	invoice.BuyerEmail = buyer.Email
+	invoice.BuyerTaxID = buyer.TaxID
+	invoice.BuyerAddress = buyer.Address
+
+	if seller.UserID != "" {
+		invoice.SellerUserID = &seller.UserID
+	}
+	invoice.SellerName = seller.Name
+	invoice.SellerEmail = seller.Email
+	invoice.SellerTaxID = seller.TaxID
+	invoice.SellerAddress = seller.Address
+}
+
+// copyInvoiceParties 复制原票据的买卖双方信息
+func copyInvoiceParties(invoice, original *model.Invoice) {
+	invoice.BuyerUserID = original.BuyerUserID
+	invoice.BuyerName = original.BuyerName
+	invoice.BuyerEmail = original.BuyerEmail
+	invoice.BuyerTaxID = original.BuyerTaxID
+	invoice.BuyerAddress = original.BuyerAddress
+	invoice.SellerUserID = original.SellerUserID
+	invoice.SellerName = original.SellerName
+	invoice.SellerEmail = original.SellerEmail
+	invoice.SellerTaxID = original.SellerTaxID
+	invoice.SellerAddress = original.SellerAddress
+}
+
+// setInvoiceAmounts 按含税金额价税分离计算税额和不含税金额，生成单行明细
+func setInvoiceAmounts(invoice *model.Invoice, description string, total, taxRate float64) {
+	tax := 0.0
+	if taxRate > 0 {
+		tax = math.Round(total*taxRate/(1+taxRate)*100) / 100
+	}
+	invoice.Total = total
+	invoice.TaxRate = taxRate
+	invoice.TaxAmount = tax
+	invoice.Subtotal = math.Round((total-tax)*100) / 100
+	invoice.Items = []*model.InvoiceItem{{
+		LineNo:      1,
+		Description: description,
+		Quantity:    1,
+		UnitPrice:   total,
+		Amount:      total,
+		TaxAmount:   tax,
+	}}
+}
+
+// invoiceVisibleTo 判断用户是否为票据的购买方或销售方
+func invoiceVisibleTo(invoice *model.Invoice, userID string) bool {
+	return (invoice.BuyerUserID != nil && *invoice.BuyerUserID == userID) ||
+		(invoice.SellerUserID != nil && *invoice.SellerUserID == userID)
+}
+
+// buildInvoiceDocument 生成票据的版面内容：票据信息、买卖双方、明细和合计
+func (s *invoiceService) buildInvoiceDocument(invoice, original *model.Invoice) *report.Document {
+	lines := []string{
+		"票据编号：" + invoice.InvoiceNumber,
+		"开具日期：" + invoice.IssuedAt.Format("2006-01-02 15:04"),
+	}
+	if original != nil {
+		lines = append(lines, "冲销原票据："+original.InvoiceNumber)
+	}
+	if invoice.PaymentOrderID != nil {
+		lines = append(lines, fmt.Sprintf("支付订单：%s（%s）", *invoice.PaymentOrderID, invoiceOrderTypeLabels[invoice.OrderType]))
+	}
+	if invoice.RefundID != nil {
+		lines = append(lines, "退款单："+*invoice.RefundID)
+	}
+	if invoice.Category == model.InvoiceCategoryService {
+		lines = append(lines, fmt.Sprintf("本收据由%s代销售方开具", s.cfg.PlatformName))
+	}
+
+	parties := &report.Table{
+		Title:   "买卖双方",
+		Columns: []report.Column{{Title: "项目"}, {Title: "购买方"}, {Title: "销售方"}},
+		Rows: [][]string{
+			{"名称", invoice.BuyerName, invoice.SellerName},
+			{"邮箱", invoice.BuyerEmail, invoice.SellerEmail},
+			{"纳税人识别号", invoice.BuyerTaxID, invoice.SellerTaxID},
+			{"地址", invoice.BuyerAddress, invoice.SellerAddress},
+		},
+	}
+
+	items := &report.Table{
+		Title: "明细",
+		Columns: []report.Column{
+			{Title: "序号"}, {Title: "项目"},
+			{Title: "数量", Numeric: true}, {Title: "含税单价", Numeric: true},
+			{Title: "税额", Numeric: true}, {Title: "含税金额", Numeric: true},
+		},
+	}
+	for _, item := range invoice.Items {
+		items.Rows = append(items.Rows, []string{
+			strconv.Itoa(item.LineNo), item.Description,
+			strconv.Itoa(item.Quantity), formatAmount(item.UnitPrice),
+			formatAmount(item.TaxAmount), formatAmount(item.Amount),
+		})
+	}
+
+	totals := &report.Table{
+		Title:   "合计（" + invoice.Currency + "）",
+		Columns: []report.Column{{Title: "项目"}, {Title: "金额", Numeric: true}},
+		Rows: [][]string{
+			{"不含税金额", formatAmount(invoice.Subtotal)},
+			{"税率", strconv.FormatFloat(invoice.TaxRate*100, 'f', -1, 64) + "%"},
+			{"税额", formatAmount(invoice.TaxAmount)},
+			{"价税合计", formatAmount(invoice.Total)},
+		},
+	}
+
+	return &report.Document{
+		Title:  invoiceTitles[invoice.Category][invoice.DocumentType],
+		Lines:  lines,
+		Tables: []*report.Table{parties, items, totals},
+	}
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"master-guide-backend/internal/model"
+	"master-guide-backend/internal/repository"
+)
+
+// LedgerService 复式记账服务接口
+type LedgerService interface {
+	GetReconciliationReport(ctx context.Context) (*model.LedgerReconciliationReport, error)
+}
+
+// ledgerService 复式记账服务实现
+type ledgerService struct {
+	ledgerRepo repository.LedgerRepository
+}
+
+// NewLedgerService 创建复式记账服务实例
+func NewLedgerService(ledgerRepo repository.LedgerRepository) LedgerService {
+	return &ledgerService{ledgerRepo: ledgerRepo}
+}
+
+// GetReconciliationReport 生成对账报告：试算平衡、不平衡凭证，以及支付、退款、收入、服务费和提现与业务表的逐项核对
+func (s *ledgerService) GetReconciliationReport(ctx context.Context) (*model.LedgerReconciliationReport, error) {
+	accounts, err := s.ledgerRepo.ListAccountBalances(ctx)
+	if err != nil {
+		return nil, err
+	}
+	unbalanced, err := s.ledgerRepo.ListUnbalancedTransactions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items, err := s.ledgerRepo.ListReconciliationItems(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &model.LedgerReconciliationReport{
+		GeneratedAt:            time.Now(),
+		UnbalancedTransactions: unbalanced,
+		Accounts:               accounts,
+		Items:                  items,
+	}
+	if report.UnbalancedTransactions == nil {
+		report.UnbalancedTransactions = []string{}
+	}
+	for _, account := range accounts {
+		report.TotalDebit += account.Debit
+		report.TotalCredit += account.Credit
+	}
+	report.Balanced = report.TotalDebit == report.TotalCredit && len(unbalanced) == 0
+
+	report.Matched = report.Balanced
+	for _, item := range items {
+		if !item.Matched {
+			report.Matched = false
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"master-guide-backend/pkg/cache"
+	"master-guide-backend/pkg/config"
+	"master-guide-backend/pkg/logger"
+)
+
+// 登录锁定范围
+const (
+	LoginLockScopeAccount = "account"
+	LoginLockScopeIP      = "ip"
+)
+
+// LoginLockedError 登录被临时锁定
+type LoginLockedError struct {
+	Scope      string
+	RetryAfter time.Duration
+}
+
+// Error 实现error接口
+func (e *LoginLockedError) Error() string {
+	if e.Scope == LoginLockScopeIP {
+		return "登录尝试过于频繁，请稍后再试"
+	}
+	return "账户已被临时锁定，请稍后再试或通过找回密码解锁"
+}
+
+// loginGuard 登录暴力破解防护：按账户和IP统计失败次数，失败越多响应越慢，超过阈值临时锁定
+type loginGuard struct {
+	config config.LoginProtectionConfig
+}
+
+// newLoginGuard 创建登录防护
+func newLoginGuard(cfg config.LoginProtectionConfig) *loginGuard {
+	if cfg.MaxAccountFailures <= 0 {
+		cfg.MaxAccountFailures = 5
+	}
+	if cfg.MaxIPFailures <= 0 {
+		cfg.MaxIPFailures = 20
+	}
+	if cfg.FailureWindow <= 0 {
+		cfg.FailureWindow = 900
+	}
+	if cfg.LockoutDuration <= 0 {
+		cfg.LockoutDuration = 900
+	}
+	if cfg.BaseDelayMs <= 0 {
+		cfg.BaseDelayMs = 500
+	}
+	if cfg.MaxDelayMs <= 0 {
+		cfg.MaxDelayMs = 5000
+	}
+	return &loginGuard{config: cfg}
+}
+
+// check 检查账户或IP是否处于锁定状态
+func (g *loginGuard) check(ctx context.Context, email, ip string) error {
+	if lockErr := g.lockedFor(ctx, accountLockKey(email), LoginLockScopeAccount); lockErr != nil {
+		return lockErr
+	}
+	if ip != "" {
+		if lockErr := g.lockedFor(ctx, ipLockKey(ip), LoginLockScopeIP); lockErr != nil {
+			return lockErr
+		}
+	}
+	return nil
+}
+
+// recordFailure 记录一次登录失败，返回账户累计失败次数；达到阈值时加锁并返回锁定错误
+func (g *loginGuard) recordFailure(ctx context.Context, email, ip string) (int64, *LoginLockedError) {
+	lockout := time.Duration(g.config.LockoutDuration) * time.Second
+
+	failures, err := g.incrFailure(ctx, accountFailureKey(email))
+	if err != nil {
+		logger.Warn("记录登录失败次数失败", logger.String("error", err.Error()))
+		return 0, nil
+	}
+
+	var lockErr *LoginLockedError
+	if failures >= int64(g.config.MaxAccountFailures) {
+		_ = cache.Set(ctx, accountLockKey(email), 1, lockout)
+		_ = cache.Del(ctx, accountFailureKey(email))
+		lockErr = &LoginLockedError{Scope: LoginLockScopeAccount, RetryAfter: lockout}
+	}
+
+	if ip != "" {
+		ipFailures, err := g.incrFailure(ctx, ipFailureKey(ip))
+		if err == nil && ipFailures >= int64(g.config.MaxIPFailures) {
+			_ = cache.Set(ctx, ipLockKey(ip), 1, lockout)
+			_ = cache.Del(ctx, ipFailureKey(ip))
+			if lockErr == nil {
+				lockErr = &LoginLockedError{Scope: LoginLockScopeIP, RetryAfter: lockout}
+			}
+		}
+	}
+
+	return failures, lockErr
+}
+
+// reset 登录成功后清除账户失败计数
+func (g *loginGuard) reset(ctx context.Context, email string) {
+	_ = cache.Del(ctx, accountFailureKey(email))
+}
+
+// unlock 解除账户锁定并清除失败计数
+func (g *loginGuard) unlock(ctx context.Context, email string) {
+	_ = cache.Del(ctx, accountLockKey(email), accountFailureKey(email))
+}
+
+// delay 根据失败次数递增响应延迟，超过阈值后每次失败延迟翻倍
+func (g *loginGuard) delay(ctx context.Context, failures int64) {
+	over := failures - int64(g.config.DelayThreshold)
+	if over <= 0 {
+		return
+	}
+
+	maxDelay := time.Duration(g.config.MaxDelayMs) * time.Millisecond
+	d := time.Duration(g.config.BaseDelayMs) * time.Millisecond
+	for i := int64(1); i < over && d < maxDelay; i++ {
+		d *= 2
+	}
+	if d > maxDelay {
+		d = maxDelay
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// lockedFor 检查锁定键，处于锁定时返回剩余时间
+func (g *loginGuard) lockedFor(ctx context.Context, key, scope string) *LoginLockedError {
+	ttl, err := cache.TTL(ctx, key)
+	if err != nil || ttl <= 0 {
+		return nil
+	}
+	return &LoginLockedError{Scope: scope, RetryAfter: ttl}
+}
+
+// incrFailure 失败计数加一，首次计数时设置统计窗口
+func (g *loginGuard) incrFailure(ctx context.Context, key string) (int64, error) {
+	count, err := cache.Incr(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 {
+		_ = cache.Expire(ctx, key, time.Duration(g.config.FailureWindow)*time.Second)
+	}
+	return count, nil
+}
+
+// normalizeEmail 统一邮箱格式作为计数键
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func accountFailureKey(email string) string {
+	return "login:fail:account:" + normalizeEmail(email)
+}
+
+func accountLockKey(email string) string {
+	return "login:lock:account:" + normalizeEmail(email)
+}
+
+func ipFailureKey(ip string) string {
+	return "login:fail:ip:" + ip
+}
+
+func ipLockKey(ip string) string {
+	return "login:lock:ip:" + ip
+}
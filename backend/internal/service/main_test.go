@@ -0,0 +1,16 @@
+package service
+
+import (
+	"os"
+	"testing"
+
+	"master-guide-backend/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	// 服务层直接调用全局日志，测试中只输出错误日志
+	if err := logger.Init("error", "json", "stdout", ""); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"master-guide-backend/internal/meeting"
+	"master-guide-backend/internal/model"
+	"master-guide-backend/internal/repository"
+	"master-guide-backend/pkg/config"
+	"master-guide-backend/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// MeetingService 视频咨询会议服务接口
+// 视频咨询预约确认后创建会议室，预约双方只能在入会时间窗口内获取入会地址和凭证
+type MeetingService interface {
+	ProvisionRoom(ctx context.Context, appointmentID string) error
+	ProvisionPendingRooms(ctx context.Context, limit int) (int, error)
+	CloseRoom(ctx context.Context, appointmentID string) error
+	ResetRoom(ctx context.Context, appointmentID string) error
+	GetJoinInfo(ctx context.Context, appointmentID, userID string) (*model.MeetingJoinResponse, error)
+	AuthorizeSignaling(ctx context.Context, roomID, token string) (*meeting.Claims, error)
+}
+
+// meetingService 视频咨询会议服务实现
+type meetingService struct {
+	meetingRepo     repository.MeetingRepository
+	appointmentRepo repository.AppointmentRepository
+	provider        meeting.MeetingProvider
+	joinBefore      time.Duration
+	joinAfter       time.Duration
+}
+
+// NewMeetingService 创建视频咨询会议服务实例，provider 为空时不创建会议室
+func NewMeetingService(meetingRepo repository.MeetingRepository, appointmentRepo repository.AppointmentRepository, provider meeting.MeetingProvider, cfg config.MeetingConfig) MeetingService {
+	if cfg.JoinBeforeMinutes <= 0 {
+		cfg.JoinBeforeMinutes = int(appointmentEarlyStart / time.Minute)
+	}
+	if cfg.JoinAfterMinutes < 0 {
+		cfg.JoinAfterMinutes = 0
+	}
+	return &meetingService{
+		meetingRepo:     meetingRepo,
+		appointmentRepo: appointmentRepo,
+		provider:        provider,
+		joinBefore:      time.Duration(cfg.JoinBeforeMinutes) * time.Minute,
+		joinAfter:       time.Duration(cfg.JoinAfterMinutes) * time.Minute,
+	}
+}
+
+// ProvisionRoom 为已确认的视频咨询预约创建会议室，已有会议室或不是视频咨询时直接返回
+// 并发创建时只保留先写入的会议室，其余在会议服务侧关闭
+func (s *meetingService) ProvisionRoom(ctx context.Context, appointmentID string) error {
+	if s.provider == nil {
+		return nil
+	}
+
+	appointment, err := s.appointmentRepo.GetAppointmentByID(ctx, appointmentID)
+	if err != nil {
+		return err
+	}
+	if appointment.Status != model.AppointmentStatusConfirmed || appointment.MeetingType != model.MeetingTypeVideo || appointment.Mentor == nil {
+		return nil
+	}
+	if _, err := s.meetingRepo.GetRoomByAppointmentID(ctx, appointment.ID); err == nil {
+		return nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	opensAt, closesAt := s.joinWindow(appointment)
+	title := "咨询预约"
+	if appointment.Mentor.Profile != nil {
+		title += "：" + appointment.Mentor.Profile.Name
+	}
+	room, err := s.provider.CreateRoom(ctx, &meeting.RoomRequest{
+		AppointmentID: appointment.ID,
+		Title:         title,
+		StartsAt:      appointment.AppointmentTime,
+		EndsAt:        appointmentEnd(appointment),
+		NotBefore:     opensAt,
+		ExpiresAt:     closesAt,
+		Participants: []meeting.Participant{
+			{UserID: appointment.Mentor.UserID, Role: meeting.RoleHost},
+			{UserID: appointment.StudentID, Role: meeting.RoleGuest},
+		},
+	})
+	if err != nil {
+		logger.Error("创建会议室失败", logger.String("appointment_id", appointment.ID), logger.String("provider", s.provider.Name()), logger.String("error", err.Error()))
+		return err
+	}
+
+	record := &model.MeetingRoom{
+		AppointmentID:  appointment.ID,
+		Provider:       s.provider.Name(),
+		ProviderRoomID: room.RoomID,
+		SignalingURL:   room.SignalingURL,
+		Status:         model.MeetingRoomStatusActive,
+		JoinOpensAt:    opensAt,
+		JoinClosesAt:   closesAt,
+	}
+	for _, access := range room.Participants {
+		record.Participants = append(record.Participants, &model.MeetingParticipant{
+			UserID:  access.UserID,
+			Role:    access.Role,
+			JoinURL: access.JoinURL,
+			Token:   access.Token,
+		})
+	}
+
+	created, err := s.meetingRepo.CreateRoom(ctx, record)
+	if err != nil || !created {
+		_ = s.provider.CloseRoom(ctx, room.RoomID)
+		return err
+	}
+	logger.Info("会议室已创建", logger.String("appointment_id", appointment.ID), logger.String("provider", s.provider.Name()), logger.String("room_id", room.RoomID))
+	return nil
+}
+
+// ProvisionPendingRooms 为缺少会议室的已确认视频咨询预约补建会议室，返回本次检查的预约数
+func (s *meetingService) ProvisionPendingRooms(ctx context.Context, limit int) (int, error) {
+	if s.provider == nil {
+		return 0, nil
+	}
+
+	appointments, err := s.meetingRepo.ListAppointmentsWithoutRoom(ctx, time.Now(), limit)
+	if err != nil {
+		return 0, err
+	}
+	for _, appointment := range appointments {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		_ = s.ProvisionRoom(ctx, appointment.ID)
+	}
+	return len(appointments), nil
+}
+
+// CloseRoom 预约取消后关闭会议室，已入会的参与方被断开
+func (s *meetingService) CloseRoom(ctx context.Context, appointmentID string) error {
+	room, err := s.meetingRepo.CloseRoom(ctx, appointmentID)
+	if err != nil || room == nil {
+		return err
+	}
+	if s.provider == nil || room.Provider != s.provider.Name() {
+		return nil
+	}
+	return s.provider.CloseRoom(ctx, room.ProviderRoomID)
+}
+
+// ResetRoom 预约改期后按新的时间重建会议室，原入会凭证随之失效
+func (s *meetingService) ResetRoom(ctx context.Context, appointmentID string) error {
+	room, err := s.meetingRepo.DeleteRoom(ctx, appointmentID)
+	if err != nil {
+		return err
+	}
+	if room != nil && s.provider != nil && room.Provider == s.provider.Name() && room.Status == model.MeetingRoomStatusActive {
+		if err := s.provider.CloseRoom(ctx, room.ProviderRoomID); err != nil {
+			logger.Warn("关闭改期前的会议室失败", logger.String("appointment_id", appointmentID), logger.String("error", err.Error()))
+		}
+	}
+	return s.ProvisionRoom(ctx, appointmentID)
+}
+
+// GetJoinInfo 获取本人的入会地址和凭证，仅预约双方在入会时间窗口内可获取
+func (s *meetingService) GetJoinInfo(ctx context.Context, appointmentID, userID string) (*model.MeetingJoinResponse, error) {
+	appointment, err := s.appointmentRepo.GetAppointmentByID(ctx, appointmentID)
+	if err != nil {
+		return nil, errors.New("预约不存在")
+	}
+	if appointmentActor(appointment, userID) == "" {
+		return nil, errors.New("无权操作该预约")
+	}
+	if appointment.MeetingType != model.MeetingTypeVideo {
+		return nil, errors.New("该预约不是视频咨询")
+	}
+	if appointment.Status != model.AppointmentStatusConfirmed && appointment.Status != model.AppointmentStatusInProgress {
+		return nil, errors.New("预约当前状态不能进入会议")
+	}
+
+	opensAt, closesAt := s.joinWindow(appointment)
+	now := time.Now()
+	if now.Before(opensAt) {
+		return nil, errors.New("会议尚未开放")
+	}
+	if !now.Before(closesAt) {
+		return nil, errors.New("会议已结束")
+	}
+
+	room, err := s.meetingRepo.GetRoomByAppointmentID(ctx, appointment.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 确认时创建失败且补建任务尚未执行
+		if err := s.ProvisionRoom(ctx, appointment.ID); err != nil {
+			return nil, errors.New("会议室暂不可用，请稍后重试")
+		}
+		room, err = s.meetingRepo.GetRoomByAppointmentID(ctx, appointment.ID)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("会议室暂不可用，请稍后重试")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if room.Status != model.MeetingRoomStatusActive {
+		return nil, errors.New("会议已结束")
+	}
+
+	for _, participant := range room.Participants {
+		if participant.UserID != userID {
+			continue
+		}
+		return &model.MeetingJoinResponse{
+			AppointmentID: appointment.ID,
+			Provider:      room.Provider,
+			RoomID:        room.ProviderRoomID,
+			Role:          participant.Role,
+			JoinURL:       participant.JoinURL,
+			Token:         participant.Token,
+			SignalingURL:  room.SignalingURL,
+			JoinOpensAt:   room.JoinOpensAt,
+			JoinClosesAt:  room.JoinClosesAt,
+		}, nil
+	}
+	return nil, errors.New("无权操作该预约")
+}
+
+// AuthorizeSignaling 校验自建会议信令连接的入会凭证，会议室需存在且未关闭
+func (s *meetingService) AuthorizeSignaling(ctx context.Context, roomID, token string) (*meeting.Claims, error) {
+	verifier, ok := s.provider.(meeting.TokenVerifier)
+	if !ok {
+		return nil, errors.New("会议室不存在")
+	}
+
+	claims, err := verifier.VerifyToken(token, time.Now())
+	switch {
+	case errors.Is(err, meeting.ErrTokenNotYet):
+		return nil, errors.New("会议尚未开放")
+	case errors.Is(err, meeting.ErrTokenExpired):
+		return nil, errors.New("会议已结束")
+	case err != nil || claims.RoomID != roomID:
+		return nil, errors.New("入会凭证无效")
+	}
+
+	room, err := s.meetingRepo.GetRoomByProviderRoomID(ctx, s.provider.Name(), roomID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("会议室不存在")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if room.Status != model.MeetingRoomStatusActive {
+		return nil, errors.New("会议已结束")
+	}
+	return claims, nil
+}
+
+// joinWindow 入会时间窗口：开始前 joinBefore 至结束后 joinAfter
+func (s *meetingService) joinWindow(appointment *model.AppointmentModel) (time.Time, time.Time) {
+	return appointment.AppointmentTime.Add(-s.joinBefore), appointmentEnd(appointment).Add(s.joinAfter)
+}
+
+// appointmentEnd 预约结束时间
+func appointmentEnd(appointment *model.AppointmentModel) time.Time {
+	return appointment.AppointmentTime.Add(time.Duration(appointment.DurationMinutes) * time.Minute)
+}
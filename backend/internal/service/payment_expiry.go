@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"master-guide-backend/internal/gateway"
+	"master-guide-backend/internal/model"
+	"master-guide-backend/internal/repository"
+	"master-guide-backend/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// fulfillmentRetryDelay 支付完成后等待提交后履约的时间，超过后由补偿任务重试
+const fulfillmentRetryDelay = time.Minute
+
+// ExpirePendingOrders 关闭已过有效期仍待支付的订单，并释放对应的报名或预约
+// 关闭前先向网关查询一次，用户已支付但回调尚未到达时按支付成功处理；返回本次关闭的订单数
+func (s *paymentService) ExpirePendingOrders(ctx context.Context, limit int) (int, error) {
+	orders, err := s.paymentRepo.ListExpiredOrders(ctx, time.Now(), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, order := range orders {
+		if err := ctx.Err(); err != nil {
+			return expired, err
+		}
+		ok, err := s.expireOrder(ctx, order)
+		if err != nil {
+			logger.Warn("关闭过期支付订单失败", logger.String("order_id", order.ID), logger.String("error", err.Error()))
+			continue
+		}
+		if ok {
+			expired++
+		}
+	}
+	if expired > 0 {
+		logger.Info("已关闭过期支付订单", logger.Int("count", expired))
+	}
+	return expired, nil
+}
+
+// RetryUnfulfilledOrders 重试已支付但履约失败的订单，返回本次检查的订单数
+func (s *paymentService) RetryUnfulfilledOrders(ctx context.Context, limit int) (int, error) {
+	orders, err := s.paymentRepo.ListUnfulfilledOrders(ctx, time.Now().Add(-fulfillmentRetryDelay), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, order := range orders {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		_ = s.onPaymentStatusChanged(ctx, order.ID, order.Status)
+	}
+	return len(orders), nil
+}
+
+// expireOrder 关闭单个过期订单，订单已不再待支付时返回 false
+func (s *paymentService) expireOrder(ctx context.Context, order *model.PaymentOrder) (bool, error) {
+	// 网关下单时已传入相同的过期时间，由网关自行关闭交易，这里只需确认是否已支付
+	charge := &gateway.ChargeStatus{OrderID: order.ID, Status: gateway.ChargeStatusClosed}
+	if gw, err := s.gateways.ForMethod(order.PaymentMethod); err == nil {
+		status, err := gw.QueryCharge(ctx, order.ID)
+		switch {
+		case err == nil && (status.Status == gateway.ChargeStatusSucceeded || status.Status == gateway.ChargeStatusFailed):
+			charge = status
+		case err != nil && !errors.Is(err, gateway.ErrChargeNotFound):
+			// 网关暂不可用时仍然关闭订单，之后到达的支付成功通知可将订单从已取消恢复为已完成
+			logger.Warn("过期订单查询支付状态失败", logger.String("order_id", order.ID), logger.String("error", err.Error()))
+		}
+	}
+
+	// 先释放业务订单再关闭支付订单，关闭失败时下次执行会重新处理，释放可重复执行
+	if charge.Status != gateway.ChargeStatusSucceeded {
+		if err := s.fulfillment.ReleaseOrder(ctx, order.ID); err != nil {
+			return false, err
+		}
+	}
+
+	var result *webhookApplyResult
+	err := s.paymentRepo.WithTx(ctx, func(repo repository.PaymentRepository) error {
+		lockedOrder, err := repo.GetOrderForUpdate(ctx, order.ID)
+		if err != nil {
+			return err
+		}
+		if lockedOrder.Status != "pending" {
+			result = &webhookApplyResult{status: lockedOrder.Status}
+			return nil
+		}
+
+		lockedRecord, err := repo.GetPaymentRecordByOrderIDForUpdate(ctx, order.ID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 网关下单成功但支付记录未写入，订单不会再被支付
+			if err := repo.UpdateOrderStatus(ctx, order.ID, "cancelled"); err != nil {
+				return err
+			}
+			result = &webhookApplyResult{status: "cancelled", changed: true}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		result, err = s.applyChargeStatus(ctx, repo, lockedOrder, lockedRecord, charge)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	if !result.changed {
+		return false, nil
+	}
+	if err := s.onPaymentStatusChanged(ctx, order.ID, result.status); err != nil {
+		return false, err
+	}
+	return result.status != "completed", nil
+}
@@ -0,0 +1,304 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"master-guide-backend/internal/gateway"
+	"master-guide-backend/internal/ledger"
+	"master-guide-backend/internal/model"
+	"master-guide-backend/internal/repository"
+	"master-guide-backend/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// refundEstimatedDays 异步退款的预计到账天数
+const refundEstimatedDays = 3
+
+// unfulfillableRefundReason 无法履约订单的自动退款原因
+const unfulfillableRefundReason = "业务订单已取消或时段已被占用，自动退回支付金额"
+
+// refundRetryDelays 自动退款申请失败后的重试间隔，按失败次数依次取值，用完后不再自动重试
+var refundRetryDelays = []time.Duration{5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 12 * time.Hour}
+
+// RefundOrderByRef 按比例退还业务订单的支付金额，用于取消预约或退课时按退款策略自动退款
+// 业务订单未支付或尚未履约时返回 nil；退款金额为0或已全部退款时不发起退款，返回金额为0的结果
+// 网关申请失败时不返回错误，退款记录保留为 failed 并由补偿任务重试
+func (s *paymentService) RefundOrderByRef(ctx context.Context, orderType, refID string, rate float64, reason string) (*model.CreateRefundResponse, error) {
+	order, err := s.paymentRepo.GetOrderByRef(ctx, orderType, refID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 已支付未履约的订单由履约流程处理，此时不退款
+	if order.Status != "completed" || order.FulfilledAt == nil {
+		return nil, nil
+	}
+
+	paymentRecord, err := s.paymentRepo.GetPaymentRecordByOrderID(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	refunded, err := s.paymentRepo.SumRefundAmount(ctx, paymentRecord.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 已有人工部分退款时，按策略计算的金额以剩余可退金额为上限
+	amountCents := toCents(paymentRecord.Amount * math.Max(0, math.Min(rate, 1)))
+	if remaining := toCents(paymentRecord.Amount) - toCents(refunded); amountCents > remaining {
+		amountCents = remaining
+	}
+	if amountCents <= 0 {
+		return &model.CreateRefundResponse{}, nil
+	}
+	return s.refundPayment(ctx, paymentRecord.ID, float64(amountCents)/100, reason, "", true)
+}
+
+// refundUnfulfillableOrder 退回无法履约订单的剩余可退金额，已全部退回、退款处理中或等待重试时不再发起
+// 自动重试次数用完后订单仍由履约补偿任务列出，下次执行时重新发起
+func (s *paymentService) refundUnfulfillableOrder(ctx context.Context, orderID string) error {
+	paymentRecord, err := s.paymentRepo.GetPaymentRecordByOrderID(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	refunded, err := s.paymentRepo.SumRefundAmount(ctx, paymentRecord.ID)
+	if err != nil {
+		return err
+	}
+	amountCents := toCents(paymentRecord.Amount) - toCents(refunded)
+	if amountCents <= 0 {
+		return nil
+	}
+
+	logger.Warn("业务订单无法履约，自动退回支付金额", logger.String("order_id", orderID), logger.Float64("amount", float64(amountCents)/100))
+	_, err = s.refundPayment(ctx, paymentRecord.ID, float64(amountCents)/100, unfulfillableRefundReason, "", true)
+	return err
+}
+
+// RetryRefundReversals 重试已完成但尚未冲正大师收入的退款，返回本次检查的退款数
+func (s *paymentService) RetryRefundReversals(ctx context.Context, limit int) (int, error) {
+	refunds, err := s.paymentRepo.ListUnreversedRefunds(ctx, time.Now().Add(-fulfillmentRetryDelay), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, refund := range refunds {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		_ = s.onRefundCompleted(ctx, refund.ID)
+	}
+	return len(refunds), nil
+}
+
+// RetryFailedRefunds 重新向网关申请已到重试时间的自动退款，返回本次检查的退款数
+// 重试沿用原退款单号，网关按退款单号幂等，不会重复退款
+func (s *paymentService) RetryFailedRefunds(ctx context.Context, limit int) (int, error) {
+	refunds, err := s.paymentRepo.ListRetryableRefunds(ctx, time.Now(), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, candidate := range refunds {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		var refund *model.PaymentRefund
+		var paymentRecord *model.PaymentRecord
+		err := s.paymentRepo.WithTx(ctx, func(repo repository.PaymentRepository) error {
+			record, err := repo.GetPaymentRecordByID(ctx, candidate.PaymentID)
+			if err != nil {
+				return err
+			}
+			if _, err := repo.GetOrderForUpdate(ctx, record.OrderID); err != nil {
+				return err
+			}
+			locked, err := repo.GetRefundForUpdate(ctx, candidate.ID)
+			if err != nil {
+				return err
+			}
+			// 加锁后重新检查，退款可能已被其他节点重试或由回调更新
+			if locked.Status != "failed" || locked.NextRetryAt == nil {
+				return nil
+			}
+			// 等待重试的退款已计入可退金额，重新置为处理中不会超退
+			if err := repo.UpdateRefundStatus(ctx, locked.ID, "pending", nil, ""); err != nil {
+				return err
+			}
+			locked.Status = "pending"
+			refund, paymentRecord = locked, record
+			return nil
+		})
+		if err != nil {
+			logger.Error("重试自动退款失败", logger.String("refund_id", candidate.ID), logger.String("error", err.Error()))
+			continue
+		}
+		if refund == nil {
+			continue
+		}
+
+		logger.Info("重试自动退款", logger.String("refund_id", refund.ID), logger.Int("attempts", refund.Attempts))
+		_, _ = s.submitRefund(ctx, refund, paymentRecord)
+	}
+	return len(refunds), nil
+}
+
+// refundPayment 校验可退金额后创建退款记录并向网关发起退款
+// 退款记录在支付订单行锁下创建，并发申请时累计退款金额不会超过支付金额
+// automatic 表示系统自动发起的退款，申请失败时保留退款记录等待重试，不返回错误
+func (s *paymentService) refundPayment(ctx context.Context, paymentID string, amount float64, reason, description string, automatic bool) (*model.CreateRefundResponse, error) {
+	if toCents(amount) <= 0 {
+		return nil, errors.New("退款金额必须大于0")
+	}
+
+	var paymentRecord *model.PaymentRecord
+	refund := &model.PaymentRefund{
+		PaymentID:   paymentID,
+		Amount:      amount,
+		Status:      "pending",
+		Reason:      reason,
+		Description: description,
+		Automatic:   automatic,
+	}
+	err := s.paymentRepo.WithTx(ctx, func(repo repository.PaymentRepository) error {
+		record, err := repo.GetPaymentRecordByID(ctx, paymentID)
+		if err != nil {
+			return errors.New("支付记录不存在")
+		}
+		if _, err := repo.GetOrderForUpdate(ctx, record.OrderID); err != nil {
+			return err
+		}
+		// 加锁后重新读取，避免与支付状态回调并发
+		record, err = repo.GetPaymentRecordByOrderIDForUpdate(ctx, record.OrderID)
+		if err != nil {
+			return errors.New("支付记录不存在")
+		}
+
+		if record.Status != "completed" {
+			return errors.New("支付未完成，无法退款")
+		}
+		if toCents(amount) > toCents(record.Amount) {
+			return errors.New("退款金额不能超过支付金额")
+		}
+		refunded, err := repo.SumRefundAmount(ctx, record.ID)
+		if err != nil {
+			return err
+		}
+		if toCents(refunded)+toCents(amount) > toCents(record.Amount) {
+			return errors.New("退款金额超过剩余可退金额")
+		}
+
+		if err := repo.CreateRefund(ctx, refund); err != nil {
+			return err
+		}
+		paymentRecord = record
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.submitRefund(ctx, refund, paymentRecord)
+}
+
+// submitRefund 向支付网关发起已创建的退款，网关同步退款成功时完成退款并冲正收入
+func (s *paymentService) submitRefund(ctx context.Context, refund *model.PaymentRefund, paymentRecord *model.PaymentRecord) (*model.CreateRefundResponse, error) {
+	gw, err := s.gateways.ForMethod(paymentRecord.PaymentMethod)
+	if err != nil {
+		return s.refundFailed(ctx, refund, errors.New("支付方式暂不可用"))
+	}
+
+	// 向支付网关发起退款
+	result, err := gw.Refund(ctx, &gateway.RefundRequest{
+		OrderID:       paymentRecord.OrderID,
+		RefundID:      refund.ID,
+		TransactionID: paymentRecord.TransactionID,
+		TotalAmount:   paymentRecord.Amount,
+		Amount:        refund.Amount,
+		Reason:        refund.Reason,
+	})
+	if err != nil || result.Status == gateway.RefundStatusFailed {
+		if err != nil {
+			logger.Error("支付网关退款失败", logger.String("gateway", gw.Name()), logger.String("refund_id", refund.ID), logger.String("error", err.Error()))
+		}
+		return s.refundFailed(ctx, refund, errors.New("退款申请失败"))
+	}
+
+	estimatedCompletionTime := time.Now().AddDate(0, 0, refundEstimatedDays)
+	if result.Status == gateway.RefundStatusSucceeded {
+		now := time.Now()
+		err := s.paymentRepo.WithTx(ctx, func(repo repository.PaymentRepository) error {
+			if err := repo.UpdateRefundStatus(ctx, refund.ID, "completed", &now, result.RefundTransactionID); err != nil {
+				return err
+			}
+			return repo.PostLedger(ctx, ledger.RefundPaid(refund.ID, paymentRecord.OrderID, ledger.ToMinor(refund.Amount), now))
+		})
+		if err != nil {
+			return nil, err
+		}
+		refund.Status = "completed"
+		estimatedCompletionTime = now
+		// 冲正失败时由补偿任务重试
+		_ = s.onRefundCompleted(ctx, refund.ID)
+	}
+
+	return &model.CreateRefundResponse{
+		RefundID:                refund.ID,
+		Amount:                  refund.Amount,
+		Status:                  refund.Status,
+		EstimatedCompletionTime: estimatedCompletionTime,
+	}, nil
+}
+
+// refundFailed 记录退款申请失败
+// 人工退款返回 cause；自动退款安排下次重试，返回 failed 状态的结果
+func (s *paymentService) refundFailed(ctx context.Context, refund *model.PaymentRefund, cause error) (*model.CreateRefundResponse, error) {
+	var nextRetryAt *time.Time
+	if refund.Automatic {
+		nextRetryAt = nextRefundRetry(refund.Attempts, time.Now())
+	}
+	if err := s.paymentRepo.MarkRefundFailed(ctx, refund.ID, nextRetryAt); err != nil {
+		logger.Error("记录退款失败状态失败", logger.String("refund_id", refund.ID), logger.String("error", err.Error()))
+	}
+	if !refund.Automatic {
+		return nil, cause
+	}
+	if nextRetryAt == nil {
+		logger.Error("自动退款多次申请失败，需人工处理", logger.String("refund_id", refund.ID), logger.Int("attempts", refund.Attempts+1))
+	}
+	return &model.CreateRefundResponse{
+		RefundID: refund.ID,
+		Amount:   refund.Amount,
+		Status:   "failed",
+	}, nil
+}
+
+// nextRefundRetry 计算自动退款第 attempts+1 次申请失败后的重试时间，重试次数用完时返回 nil
+func nextRefundRetry(attempts int, now time.Time) *time.Time {
+	if attempts < 0 || attempts >= len(refundRetryDelays) {
+		return nil
+	}
+	next := now.Add(refundRetryDelays[attempts])
+	return &next
+}
+
+// onRefundCompleted 退款完成后冲正大师收入
+// 在退款事务提交后执行；失败时不影响退款结果，可再次触发且不会重复冲正
+func (s *paymentService) onRefundCompleted(ctx context.Context, refundID string) error {
+	err := s.fulfillment.ReverseIncome(ctx, refundID)
+	if err != nil {
+		logger.Error("退款冲正收入失败", logger.String("refund_id", refundID), logger.String("error", err.Error()))
+	}
+	return err
+}
+
+// toCents 金额转换为分
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
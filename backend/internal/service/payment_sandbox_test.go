@@ -0,0 +1,662 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"master-guide-backend/internal/gateway"
+	"master-guide-backend/internal/ledger"
+	"master-guide-backend/internal/model"
+	"master-guide-backend/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// memoryPaymentRepository 进程内支付仓储，只实现沙箱支付流程用到的方法
+type memoryPaymentRepository struct {
+	repository.PaymentRepository
+
+	mu       sync.Mutex
+	nextID   int
+	methods  map[string]*model.PaymentMethod
+	orders   map[string]*model.PaymentOrder
+	records  map[string]*model.PaymentRecord
+	refunds  map[string]*model.PaymentRefund
+	webhooks map[string]*model.PaymentWebhookEvent
+	ledger   map[string]*ledger.Transaction
+}
+
+func newMemoryPaymentRepository(methods ...*model.PaymentMethod) *memoryPaymentRepository {
+	repo := &memoryPaymentRepository{
+		methods:  make(map[string]*model.PaymentMethod),
+		orders:   make(map[string]*model.PaymentOrder),
+		records:  make(map[string]*model.PaymentRecord),
+		refunds:  make(map[string]*model.PaymentRefund),
+		webhooks: make(map[string]*model.PaymentWebhookEvent),
+		ledger:   make(map[string]*ledger.Transaction),
+	}
+	for _, method := range methods {
+		repo.methods[method.ID] = method
+	}
+	return repo
+}
+
+func (r *memoryPaymentRepository) id(prefix string) string {
+	r.nextID++
+	return fmt.Sprintf("%s%d", prefix, r.nextID)
+}
+
+func (r *memoryPaymentRepository) CreateOrder(ctx context.Context, order *model.PaymentOrder) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	order.ID = r.id("PO")
+	copied := *order
+	r.orders[order.ID] = &copied
+	return nil
+}
+
+func (r *memoryPaymentRepository) GetOrderByID(ctx context.Context, id string) (*model.PaymentOrder, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	order, ok := r.orders[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *order
+	return &copied, nil
+}
+
+func (r *memoryPaymentRepository) GetOrderForUpdate(ctx context.Context, id string) (*model.PaymentOrder, error) {
+	return r.GetOrderByID(ctx, id)
+}
+
+func (r *memoryPaymentRepository) GetOrderByRef(ctx context.Context, orderType, orderRefID string) (*model.PaymentOrder, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, order := range r.orders {
+		if order.OrderType == orderType && order.OrderRefID == orderRefID {
+			copied := *order
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryPaymentRepository) UpdateOrderStatus(ctx context.Context, id, status string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.orders[id].Status = status
+	return nil
+}
+
+func (r *memoryPaymentRepository) DeleteOrder(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.orders, id)
+	return nil
+}
+
+func (r *memoryPaymentRepository) CreatePaymentRecord(ctx context.Context, record *model.PaymentRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record.ID = r.id("PR")
+	copied := *record
+	r.records[record.ID] = &copied
+	return nil
+}
+
+func (r *memoryPaymentRepository) GetPaymentRecordByID(ctx context.Context, id string) (*model.PaymentRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record, ok := r.records[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *record
+	return &copied, nil
+}
+
+func (r *memoryPaymentRepository) GetPaymentRecordByOrderID(ctx context.Context, orderID string) (*model.PaymentRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, record := range r.records {
+		if record.OrderID == orderID {
+			copied := *record
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryPaymentRepository) GetPaymentRecordByOrderIDForUpdate(ctx context.Context, orderID string) (*model.PaymentRecord, error) {
+	return r.GetPaymentRecordByOrderID(ctx, orderID)
+}
+
+func (r *memoryPaymentRepository) UpdatePaymentRecordStatus(ctx context.Context, id, status string, paidAt *time.Time, transactionID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record := r.records[id]
+	record.Status = status
+	record.PaidAt = paidAt
+	if transactionID != "" {
+		record.TransactionID = transactionID
+	}
+	return nil
+}
+
+func (r *memoryPaymentRepository) CreateRefund(ctx context.Context, refund *model.PaymentRefund) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	refund.ID = r.id("RF")
+	copied := *refund
+	r.refunds[refund.ID] = &copied
+	return nil
+}
+
+func (r *memoryPaymentRepository) GetRefundByID(ctx context.Context, id string) (*model.PaymentRefund, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	refund, ok := r.refunds[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *refund
+	return &copied, nil
+}
+
+func (r *memoryPaymentRepository) SumRefundAmount(ctx context.Context, paymentID string) (float64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var cents int64
+	for _, refund := range r.refunds {
+		if refund.PaymentID == paymentID && (refund.Status != "failed" || refund.NextRetryAt != nil) {
+			cents += toCents(refund.Amount)
+		}
+	}
+	return float64(cents) / 100, nil
+}
+
+func (r *memoryPaymentRepository) UpdateRefundStatus(ctx context.Context, id, status string, completedAt *time.Time, refundTransactionID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	refund := r.refunds[id]
+	refund.Status = status
+	refund.CompletedAt = completedAt
+	refund.RefundTransactionID = refundTransactionID
+	return nil
+}
+
+func (r *memoryPaymentRepository) GetRefundForUpdate(ctx context.Context, id string) (*model.PaymentRefund, error) {
+	return r.GetRefundByID(ctx, id)
+}
+
+func (r *memoryPaymentRepository) ListRetryableRefunds(ctx context.Context, now time.Time, limit int) ([]*model.PaymentRefund, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var refunds []*model.PaymentRefund
+	for _, refund := range r.refunds {
+		if refund.Status == "failed" && refund.NextRetryAt != nil && !refund.NextRetryAt.After(now) && len(refunds) < limit {
+			copied := *refund
+			refunds = append(refunds, &copied)
+		}
+	}
+	return refunds, nil
+}
+
+func (r *memoryPaymentRepository) MarkRefundFailed(ctx context.Context, id string, nextRetryAt *time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	refund := r.refunds[id]
+	refund.Status = "failed"
+	refund.Attempts++
+	refund.NextRetryAt = nextRetryAt
+	return nil
+}
+
+func (r *memoryPaymentRepository) PostLedger(ctx context.Context, txn *ledger.Transaction) error {
+	if err := txn.Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.ledger[txn.IdempotencyKey]; !ok {
+		r.ledger[txn.IdempotencyKey] = txn
+	}
+	return nil
+}
+
+func (r *memoryPaymentRepository) GetPaymentMethodByID(ctx context.Context, id string) (*model.PaymentMethod, error) {
+	method, ok := r.methods[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return method, nil
+}
+
+// WithTx 测试中串行执行，不做回滚
+func (r *memoryPaymentRepository) WithTx(ctx context.Context, fn func(repo repository.PaymentRepository) error) error {
+	return fn(r)
+}
+
+func (r *memoryPaymentRepository) SaveWebhookEvent(ctx context.Context, event *model.PaymentWebhookEvent) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.webhooks {
+		if existing.Gateway == event.Gateway && existing.DedupeKey == event.DedupeKey {
+			*event = *existing
+			return false, nil
+		}
+	}
+	event.ID = r.id("WH")
+	copied := *event
+	r.webhooks[event.ID] = &copied
+	return true, nil
+}
+
+func (r *memoryPaymentRepository) GetWebhookEventByID(ctx context.Context, id string) (*model.PaymentWebhookEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	event, ok := r.webhooks[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *event
+	return &copied, nil
+}
+
+func (r *memoryPaymentRepository) GetWebhookEventForUpdate(ctx context.Context, id string) (*model.PaymentWebhookEvent, error) {
+	return r.GetWebhookEventByID(ctx, id)
+}
+
+func (r *memoryPaymentRepository) UpdateWebhookEventStatus(ctx context.Context, id, status, lastError string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	event := r.webhooks[id]
+	event.Status = status
+	event.LastError = lastError
+	event.Attempts++
+	return nil
+}
+
+// recordingFulfillment 记录履约调用的履约服务
+type recordingFulfillment struct {
+	quote      *OrderQuote
+	fulfillErr error
+	fulfilled  []string
+	released   []string
+	reversed   []string
+}
+
+func (f *recordingFulfillment) QuoteOrder(ctx context.Context, userID, orderType, refID string) (*OrderQuote, error) {
+	return f.quote, nil
+}
+
+func (f *recordingFulfillment) FulfillOrder(ctx context.Context, orderID string) error {
+	f.fulfilled = append(f.fulfilled, orderID)
+	return f.fulfillErr
+}
+
+func (f *recordingFulfillment) ReleaseOrder(ctx context.Context, orderID string) error {
+	f.released = append(f.released, orderID)
+	return nil
+}
+
+func (f *recordingFulfillment) ReverseIncome(ctx context.Context, refundID string) error {
+	f.reversed = append(f.reversed, refundID)
+	return nil
+}
+
+// flakyRefundGateway 前 failures 次退款请求返回错误的沙箱网关
+type flakyRefundGateway struct {
+	*gateway.SandboxGateway
+	failures int
+}
+
+func (g *flakyRefundGateway) Refund(ctx context.Context, req *gateway.RefundRequest) (*gateway.RefundResult, error) {
+	if g.failures > 0 {
+		g.failures--
+		return nil, fmt.Errorf("gateway unavailable")
+	}
+	return g.SandboxGateway.Refund(ctx, req)
+}
+
+// sandboxPaymentFixture 接入沙箱网关的支付服务
+type sandboxPaymentFixture struct {
+	service     PaymentService
+	repo        *memoryPaymentRepository
+	fulfillment *recordingFulfillment
+	sandbox     *gateway.SandboxGateway
+}
+
+func newSandboxPaymentFixture(t *testing.T) *sandboxPaymentFixture {
+	t.Helper()
+	repo := newMemoryPaymentRepository(&model.PaymentMethod{ID: gateway.SandboxName, Name: "沙箱支付", Enabled: true, MaxAmount: 50000})
+	fulfillment := &recordingFulfillment{quote: &OrderQuote{Amount: 199, Currency: "CNY", Description: "测试课程"}}
+	sandbox, err := gateway.NewSandboxGateway("test-secret", "http://localhost:8080/api/v1")
+	if err != nil {
+		t.Fatalf("NewSandboxGateway: %v", err)
+	}
+	registry := gateway.NewRegistry()
+	registry.Register(gateway.SandboxName, sandbox)
+	return &sandboxPaymentFixture{
+		service:     NewPaymentService(repo, registry, fulfillment),
+		repo:        repo,
+		fulfillment: fulfillment,
+		sandbox:     sandbox,
+	}
+}
+
+// createOrder 以沙箱支付方式下单，返回下单结果和沙箱支付单号
+func (f *sandboxPaymentFixture) createOrder(t *testing.T) (*model.CreatePaymentOrderResponse, string) {
+	t.Helper()
+	resp, err := f.service.CreatePaymentOrder(context.Background(), "student-1", &model.CreatePaymentOrderRequest{
+		OrderType:     model.PaymentOrderTypeCourseEnrollment,
+		OrderID:       "enrollment-1",
+		Amount:        199,
+		Currency:      "CNY",
+		PaymentMethod: gateway.SandboxName,
+	}, nil)
+	if err != nil {
+		t.Fatalf("CreatePaymentOrder: %v", err)
+	}
+	prefix, suffix := "http://localhost:8080/api/v1/payments/sandbox/charges/", "/simulate"
+	if !strings.HasPrefix(resp.PaymentURL, prefix) || !strings.HasSuffix(resp.PaymentURL, suffix) {
+		t.Fatalf("unexpected payment url %q", resp.PaymentURL)
+	}
+	return resp, strings.TrimSuffix(strings.TrimPrefix(resp.PaymentURL, prefix), suffix)
+}
+
+func TestSandboxPaymentSucceeded(t *testing.T) {
+	f := newSandboxPaymentFixture(t)
+	ctx := context.Background()
+	order, chargeID := f.createOrder(t)
+
+	resp, err := f.service.SimulateSandboxPayment(ctx, chargeID, gateway.ChargeStatusSucceeded)
+	if err != nil {
+		t.Fatalf("SimulateSandboxPayment: %v", err)
+	}
+	if !resp.Processed || resp.Status != "completed" || resp.OrderID != order.OrderID {
+		t.Fatalf("unexpected webhook response %+v", resp)
+	}
+
+	status, err := f.service.QueryPaymentStatus(ctx, "student-1", false, order.OrderID)
+	if err != nil {
+		t.Fatalf("QueryPaymentStatus: %v", err)
+	}
+	if status.Status != "completed" || status.TransactionID == "" || status.PaidAt == nil {
+		t.Fatalf("unexpected payment status %+v", status)
+	}
+	if len(f.fulfillment.fulfilled) != 1 || f.fulfillment.fulfilled[0] != order.OrderID {
+		t.Fatalf("order fulfilled %v, want [%s]", f.fulfillment.fulfilled, order.OrderID)
+	}
+	if _, ok := f.repo.ledger[ledger.PaymentReceived(order.OrderID, 0, time.Time{}).IdempotencyKey]; !ok {
+		t.Fatalf("payment not posted to ledger")
+	}
+
+	// 沙箱对已完成的支付单重复模拟会生成新的通知ID，按交易号去重后不再重复入账和履约
+	resp, err = f.service.SimulateSandboxPayment(ctx, chargeID, gateway.ChargeStatusSucceeded)
+	if err != nil {
+		t.Fatalf("repeated SimulateSandboxPayment: %v", err)
+	}
+	if !resp.Duplicate || resp.Status != "completed" {
+		t.Fatalf("unexpected repeated webhook response %+v", resp)
+	}
+	if len(f.repo.ledger) != 1 {
+		t.Fatalf("ledger has %d transactions, want 1", len(f.repo.ledger))
+	}
+}
+
+func TestSandboxPaymentFailed(t *testing.T) {
+	f := newSandboxPaymentFixture(t)
+	order, chargeID := f.createOrder(t)
+
+	resp, err := f.service.SimulateSandboxPayment(context.Background(), chargeID, gateway.ChargeStatusFailed)
+	if err != nil {
+		t.Fatalf("SimulateSandboxPayment: %v", err)
+	}
+	if !resp.Processed || resp.Status != "failed" {
+		t.Fatalf("unexpected webhook response %+v", resp)
+	}
+	if len(f.fulfillment.released) != 1 || f.fulfillment.released[0] != order.OrderID {
+		t.Fatalf("order released %v, want [%s]", f.fulfillment.released, order.OrderID)
+	}
+	if len(f.fulfillment.fulfilled) != 0 || len(f.repo.ledger) != 0 {
+		t.Fatalf("failed payment must not be fulfilled or posted")
+	}
+}
+
+func TestSandboxPaymentRefund(t *testing.T) {
+	f := newSandboxPaymentFixture(t)
+	ctx := context.Background()
+	order, chargeID := f.createOrder(t)
+	if _, err := f.service.SimulateSandboxPayment(ctx, chargeID, gateway.ChargeStatusSucceeded); err != nil {
+		t.Fatalf("SimulateSandboxPayment: %v", err)
+	}
+
+	refund, err := f.service.CreateRefund(ctx, &model.CreateRefundRequest{PaymentID: order.PaymentID, Amount: 99.5, Reason: "测试退款"})
+	if err != nil {
+		t.Fatalf("CreateRefund: %v", err)
+	}
+	if refund.Status != "completed" {
+		t.Fatalf("refund status %q, want completed", refund.Status)
+	}
+	if len(f.fulfillment.reversed) != 1 || f.fulfillment.reversed[0] != refund.RefundID {
+		t.Fatalf("income reversed %v, want [%s]", f.fulfillment.reversed, refund.RefundID)
+	}
+
+	if _, err := f.service.CreateRefund(ctx, &model.CreateRefundRequest{PaymentID: order.PaymentID, Amount: 100}); err == nil || err.Error() != "退款金额超过剩余可退金额" {
+		t.Fatalf("over refund error = %v", err)
+	}
+}
+
+func TestPaymentStatusQueriesRequireOwnership(t *testing.T) {
+	f := newSandboxPaymentFixture(t)
+	ctx := context.Background()
+	order, chargeID := f.createOrder(t)
+	if _, err := f.service.SimulateSandboxPayment(ctx, chargeID, gateway.ChargeStatusSucceeded); err != nil {
+		t.Fatalf("SimulateSandboxPayment: %v", err)
+	}
+	refund, err := f.service.CreateRefund(ctx, &model.CreateRefundRequest{PaymentID: order.PaymentID, Amount: 50, Reason: "测试退款"})
+	if err != nil {
+		t.Fatalf("CreateRefund: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		userID  string
+		isAdmin bool
+		wantErr bool
+	}{
+		{name: "buyer", userID: "student-1"},
+		{name: "admin", userID: "admin-1", isAdmin: true},
+		{name: "other user", userID: "student-2", wantErr: true},
+		{name: "anonymous", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, err := f.service.QueryPaymentStatus(ctx, tt.userID, tt.isAdmin, order.OrderID)
+			if tt.wantErr {
+				if err == nil || err.Error() != "订单不存在" || status != nil {
+					t.Fatalf("QueryPaymentStatus = %+v, %v; want 订单不存在", status, err)
+				}
+			} else if err != nil || status.OrderID != order.OrderID {
+				t.Fatalf("QueryPaymentStatus = %+v, %v", status, err)
+			}
+
+			refundStatus, err := f.service.QueryRefundStatus(ctx, tt.userID, tt.isAdmin, refund.RefundID)
+			if tt.wantErr {
+				if err == nil || err.Error() != "退款记录不存在" || refundStatus != nil {
+					t.Fatalf("QueryRefundStatus = %+v, %v; want 退款记录不存在", refundStatus, err)
+				}
+			} else if err != nil || refundStatus.RefundID != refund.RefundID {
+				t.Fatalf("QueryRefundStatus = %+v, %v", refundStatus, err)
+			}
+		})
+	}
+}
+
+func TestSandboxPaymentRefundsUnfulfillableOrder(t *testing.T) {
+	f := newSandboxPaymentFixture(t)
+	f.fulfillment.fulfillErr = repository.ErrOrderUnfulfillable
+	ctx := context.Background()
+	order, chargeID := f.createOrder(t)
+
+	if _, err := f.service.SimulateSandboxPayment(ctx, chargeID, gateway.ChargeStatusSucceeded); err != nil {
+		t.Fatalf("SimulateSandboxPayment: %v", err)
+	}
+	// 重复投递和补偿任务再次处理时不重复退款
+	if _, err := f.service.SimulateSandboxPayment(ctx, chargeID, gateway.ChargeStatusSucceeded); err != nil {
+		t.Fatalf("repeated SimulateSandboxPayment: %v", err)
+	}
+
+	if len(f.repo.refunds) != 1 {
+		t.Fatalf("created %d refunds, want 1", len(f.repo.refunds))
+	}
+	for _, refund := range f.repo.refunds {
+		if refund.PaymentID != order.PaymentID || refund.Amount != 199 || refund.Status != "completed" || refund.Reason != unfulfillableRefundReason {
+			t.Fatalf("unexpected refund %+v", refund)
+		}
+	}
+}
+
+func TestSandboxPaymentRejectsForgedWebhook(t *testing.T) {
+	f := newSandboxPaymentFixture(t)
+	ctx := context.Background()
+	order, chargeID := f.createOrder(t)
+
+	header, body, err := f.sandbox.Simulate(chargeID, gateway.ChargeStatusSucceeded)
+	if err != nil {
+		t.Fatalf("Simulate: %v", err)
+	}
+	stale := header.Clone()
+	stale.Set(gateway.SandboxTimestampHeader, fmt.Sprint(time.Now().Add(-time.Hour).Unix()))
+
+	tests := []struct {
+		name   string
+		header http.Header
+		body   []byte
+	}{
+		{"tampered body", header, []byte(string(body[:len(body)-1]) + " }")},
+		{"stale timestamp", stale, body},
+		{"missing signature", http.Header{}, body},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := f.service.ProcessPaymentWebhook(ctx, gateway.SandboxName, tt.header, tt.body)
+			if err == nil || err.Error() != "签名验证失败" {
+				t.Fatalf("ProcessPaymentWebhook error = %v, want 签名验证失败", err)
+			}
+		})
+	}
+
+	if len(f.repo.webhooks) != 0 || len(f.fulfillment.fulfilled) != 0 {
+		t.Fatalf("forged webhook must not be processed")
+	}
+
+	status, err := f.service.QueryPaymentStatus(ctx, "student-1", false, order.OrderID)
+	if err != nil {
+		t.Fatalf("QueryPaymentStatus: %v", err)
+	}
+	// 主动查询会同步沙箱中已模拟成功的支付结果
+	if status.Status != "completed" {
+		t.Fatalf("synced status %q, want completed", status.Status)
+	}
+}
+
+func TestSandboxPaymentDisabled(t *testing.T) {
+	service := NewPaymentService(newMemoryPaymentRepository(), gateway.NewRegistry(), &recordingFulfillment{})
+	if service.SandboxEnabled() {
+		t.Fatalf("SandboxEnabled() = true without sandbox gateway")
+	}
+	_, err := service.SimulateSandboxPayment(context.Background(), "SBX_x", gateway.ChargeStatusSucceeded)
+	if err == nil || err.Error() != "沙箱支付未启用" {
+		t.Fatalf("SimulateSandboxPayment error = %v", err)
+	}
+}
+
+func TestAutomaticRefundRetriedAfterGatewayFailure(t *testing.T) {
+	f := newSandboxPaymentFixture(t)
+	ctx := context.Background()
+	order, chargeID := f.createOrder(t)
+	if _, err := f.service.SimulateSandboxPayment(ctx, chargeID, gateway.ChargeStatusSucceeded); err != nil {
+		t.Fatalf("SimulateSandboxPayment: %v", err)
+	}
+	fulfilledAt := time.Now()
+	f.repo.orders[order.OrderID].FulfilledAt = &fulfilledAt
+
+	registry := gateway.NewRegistry()
+	registry.Register(gateway.SandboxName, &flakyRefundGateway{SandboxGateway: f.sandbox, failures: 1})
+	service := NewPaymentService(f.repo, registry, f.fulfillment)
+
+	refund, err := service.RefundOrderByRef(ctx, model.PaymentOrderTypeCourseEnrollment, "enrollment-1", 1, "测试规则")
+	if err != nil {
+		t.Fatalf("RefundOrderByRef: %v", err)
+	}
+	if refund.Status != "failed" || refund.RefundID == "" || refund.Amount != 199 {
+		t.Fatalf("unexpected refund %+v", refund)
+	}
+	stored := f.repo.refunds[refund.RefundID]
+	if !stored.Automatic || stored.Attempts != 1 || stored.NextRetryAt == nil {
+		t.Fatalf("failed refund not scheduled for retry: %+v", stored)
+	}
+
+	// 等待重试的退款占用可退金额，再次取消不会重复发起
+	again, err := service.RefundOrderByRef(ctx, model.PaymentOrderTypeCourseEnrollment, "enrollment-1", 1, "测试规则")
+	if err != nil || again.Amount != 0 {
+		t.Fatalf("repeated RefundOrderByRef = %+v, %v; want zero amount", again, err)
+	}
+
+	if checked, err := service.RetryFailedRefunds(ctx, 10); err != nil || checked != 0 {
+		t.Fatalf("RetryFailedRefunds before due = %d, %v; want 0", checked, err)
+	}
+	due := time.Now().Add(-time.Second)
+	stored.NextRetryAt = &due
+	if checked, err := service.RetryFailedRefunds(ctx, 10); err != nil || checked != 1 {
+		t.Fatalf("RetryFailedRefunds = %d, %v; want 1", checked, err)
+	}
+	if stored.Status != "completed" {
+		t.Fatalf("retried refund status %q, want completed", stored.Status)
+	}
+	if len(f.fulfillment.reversed) != 1 || f.fulfillment.reversed[0] != refund.RefundID {
+		t.Fatalf("income reversed %v, want [%s]", f.fulfillment.reversed, refund.RefundID)
+	}
+}
+
+func TestManualRefundFailureNotRetried(t *testing.T) {
+	f := newSandboxPaymentFixture(t)
+	ctx := context.Background()
+	order, chargeID := f.createOrder(t)
+	if _, err := f.service.SimulateSandboxPayment(ctx, chargeID, gateway.ChargeStatusSucceeded); err != nil {
+		t.Fatalf("SimulateSandboxPayment: %v", err)
+	}
+
+	registry := gateway.NewRegistry()
+	registry.Register(gateway.SandboxName, &flakyRefundGateway{SandboxGateway: f.sandbox, failures: 1})
+	service := NewPaymentService(f.repo, registry, f.fulfillment)
+
+	if _, err := service.CreateRefund(ctx, &model.CreateRefundRequest{PaymentID: order.PaymentID, Amount: 50}); err == nil || err.Error() != "退款申请失败" {
+		t.Fatalf("CreateRefund error = %v, want 退款申请失败", err)
+	}
+	for _, refund := range f.repo.refunds {
+		if refund.Status != "failed" || refund.NextRetryAt != nil {
+			t.Fatalf("manual refund scheduled for retry: %+v", refund)
+		}
+	}
+}
+
+func TestNextRefundRetry(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for attempts, delay := range refundRetryDelays {
+		if next := nextRefundRetry(attempts, now); next == nil || !next.Equal(now.Add(delay)) {
+			t.Fatalf("nextRefundRetry(%d) = %v, want %v", attempts, next, now.Add(delay))
+		}
+	}
+	if next := nextRefundRetry(len(refundRetryDelays), now); next != nil {
+		t.Fatalf("nextRefundRetry after last attempt = %v, want nil", next)
+	}
+}
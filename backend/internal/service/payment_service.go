@@ -4,35 +4,87 @@ import (
 	"context"
 	"errors"
 	"math"
+	"net/http"
 	"time"
 
+	"master-guide-backend/internal/gateway"
 	"master-guide-backend/internal/model"
 	"master-guide-backend/internal/repository"
+	"master-guide-backend/pkg/logger"
 )
 
+// paymentOrderExpire 支付订单有效期
+const paymentOrderExpire = 30 * time.Minute
+
 // PaymentService 支付服务接口
 type PaymentService interface {
-	CreatePaymentOrder(ctx context.Context, req *model.CreatePaymentOrderRequest) (*model.CreatePaymentOrderResponse, error)
-	QueryPaymentStatus(ctx context.Context, orderID string) (*model.QueryPaymentStatusResponse, error)
+	CreatePaymentOrder(ctx context.Context, userID string, req *model.CreatePaymentOrderRequest, client *model.ClientInfo) (*model.CreatePaymentOrderResponse, error)
+	QueryPaymentStatus(ctx context.Context, userID string, isAdmin bool, orderID string) (*model.QueryPaymentStatusResponse, error)
 	ListPaymentHistory(ctx context.Context, req *model.PaymentHistoryRequest) (*model.PaymentHistoryResponse, error)
 	CreateRefund(ctx context.Context, req *model.CreateRefundRequest) (*model.CreateRefundResponse, error)
-	QueryRefundStatus(ctx context.Context, refundID string) (*model.QueryRefundStatusResponse, error)
+	RefundOrderByRef(ctx context.Context, orderType, refID string, rate float64, reason string) (*model.CreateRefundResponse, error)
+	QueryRefundStatus(ctx context.Context, userID string, isAdmin bool, refundID string) (*model.QueryRefundStatusResponse, error)
 	ListPaymentMethods(ctx context.Context) (*model.PaymentMethodListResponse, error)
 	GetPaymentStats(ctx context.Context, req *model.PaymentStatsRequest) (*model.PaymentStatsResponse, error)
-	ProcessPaymentWebhook(ctx context.Context, gateway string, req *model.PaymentWebhookRequest) (*model.PaymentWebhookResponse, error)
+	ProcessPaymentWebhook(ctx context.Context, gatewayName string, header http.Header, body []byte) (*model.PaymentWebhookResponse, error)
+	PaymentWebhookAck(gatewayName string, success bool) (contentType string, body []byte)
+	SimulateSandboxPayment(ctx context.Context, chargeID, status string) (*model.PaymentWebhookResponse, error)
+	SandboxEnabled() bool
+	ReplayWebhookEvent(ctx context.Context, eventID string) (*model.PaymentWebhookResponse, error)
+	ListWebhookEvents(ctx context.Context, req *model.ListPaymentWebhookEventsRequest) (*model.PaymentWebhookEventListResponse, error)
+	ExpirePendingOrders(ctx context.Context, limit int) (int, error)
+	RetryUnfulfilledOrders(ctx context.Context, limit int) (int, error)
+	RetryRefundReversals(ctx context.Context, limit int) (int, error)
+	RetryFailedRefunds(ctx context.Context, limit int) (int, error)
 }
 
 type paymentService struct {
 	paymentRepo repository.PaymentRepository
+	gateways    *gateway.Registry
+	fulfillment FulfillmentService
 }
 
-func NewPaymentService(paymentRepo repository.PaymentRepository) PaymentService {
+func NewPaymentService(paymentRepo repository.PaymentRepository, gateways *gateway.Registry, fulfillment FulfillmentService) PaymentService {
 	return &paymentService{
 		paymentRepo: paymentRepo,
+		gateways:    gateways,
+		fulfillment: fulfillment,
 	}
 }
 
-func (s *paymentService) CreatePaymentOrder(ctx context.Context, req *model.CreatePaymentOrderRequest) (*model.CreatePaymentOrderResponse, error) {
+func (s *paymentService) CreatePaymentOrder(ctx context.Context, userID string, req *model.CreatePaymentOrderRequest, client *model.ClientInfo) (*model.CreatePaymentOrderResponse, error) {
+	// 校验支付方式及金额限制
+	method, err := s.paymentRepo.GetPaymentMethodByID(ctx, req.PaymentMethod)
+	if err != nil {
+		return nil, errors.New("不支持的支付方式")
+	}
+	if !method.Enabled {
+		return nil, errors.New("支付方式未启用")
+	}
+	if req.Amount <= 0 || req.Amount < method.MinAmount || (method.MaxAmount > 0 && req.Amount > method.MaxAmount) {
+		return nil, errors.New("支付金额超出限制")
+	}
+
+	gw, err := s.gateways.ForMethod(req.PaymentMethod)
+	if err != nil {
+		return nil, errors.New("支付方式暂不可用")
+	}
+
+	// 金额以业务订单的服务端价格为准
+	quote, err := s.fulfillment.QuoteOrder(ctx, userID, req.OrderType, req.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	if !amountEqual(req.Amount, quote.Amount) {
+		return nil, errors.New("支付金额与订单不符")
+	}
+	if !currencyEqual(req.Currency, quote.Currency) {
+		return nil, errors.New("支付币种与订单不符")
+	}
+	if req.Description == "" {
+		req.Description = quote.Description
+	}
+
 	// 检查是否已存在订单
 	existingOrder, err := s.paymentRepo.GetOrderByRef(ctx, req.OrderType, req.OrderID)
 	if err == nil && existingOrder != nil {
@@ -40,7 +92,9 @@ func (s *paymentService) CreatePaymentOrder(ctx context.Context, req *model.Crea
 	}
 
 	// 创建支付订单
+	expiresAt := time.Now().Add(paymentOrderExpire)
 	order := &model.PaymentOrder{
+		UserID:        userID,
 		OrderType:     req.OrderType,
 		OrderRefID:    req.OrderID,
 		Amount:        req.Amount,
@@ -48,7 +102,7 @@ func (s *paymentService) CreatePaymentOrder(ctx context.Context, req *model.Crea
 		PaymentMethod: req.PaymentMethod,
 		Description:   req.Description,
 		Status:        "pending",
-		ExpiresAt:     &[]time.Time{time.Now().Add(30 * time.Minute)}[0], // 30分钟过期
+		ExpiresAt:     &expiresAt,
 	}
 
 	err = s.paymentRepo.CreateOrder(ctx, order)
@@ -56,11 +110,34 @@ func (s *paymentService) CreatePaymentOrder(ctx context.Context, req *model.Crea
 		return nil, err
 	}
 
+	// 向支付网关下单
+	subject := req.Description
+	if subject == "" {
+		subject = "Master Guide 订单 " + order.ID
+	}
+	chargeReq := &gateway.ChargeRequest{
+		OrderID:   order.ID,
+		Amount:    order.Amount,
+		Currency:  order.Currency,
+		Subject:   subject,
+		ExpiresAt: expiresAt,
+	}
+	if client != nil {
+		chargeReq.ClientIP = client.IPAddress
+	}
+	charge, err := gw.CreateCharge(ctx, chargeReq)
+	if err != nil {
+		logger.Error("支付网关下单失败", logger.String("gateway", gw.Name()), logger.String("order_id", order.ID), logger.String("error", err.Error()))
+		// 删除未成功下单的订单，允许用户重试
+		_ = s.paymentRepo.DeleteOrder(ctx, order.ID)
+		return nil, errors.New("创建支付失败，请稍后重试")
+	}
+
 	// 创建支付记录
 	paymentRecord := &model.PaymentRecord{
 		OrderID:       order.ID,
-		PaymentURL:    s.generatePaymentURL(order.ID, req.PaymentMethod),
-		QRCode:        s.generateQRCode(order.ID),
+		PaymentURL:    charge.PaymentURL,
+		QRCode:        charge.QRCode,
 		Status:        "pending",
 		Amount:        req.Amount,
 		Currency:      req.Currency,
@@ -81,9 +158,10 @@ func (s *paymentService) CreatePaymentOrder(ctx context.Context, req *model.Crea
 	}, nil
 }
 
-func (s *paymentService) QueryPaymentStatus(ctx context.Context, orderID string) (*model.QueryPaymentStatusResponse, error) {
+// QueryPaymentStatus 查询支付状态，仅下单用户和管理员可查询，其他用户视为订单不存在
+func (s *paymentService) QueryPaymentStatus(ctx context.Context, userID string, isAdmin bool, orderID string) (*model.QueryPaymentStatusResponse, error) {
 	order, err := s.paymentRepo.GetOrderByID(ctx, orderID)
-	if err != nil {
+	if err != nil || (!isAdmin && order.UserID != userID) {
 		return nil, errors.New("订单不存在")
 	}
 
@@ -92,6 +170,15 @@ func (s *paymentService) QueryPaymentStatus(ctx context.Context, orderID string)
 		return nil, errors.New("支付记录不存在")
 	}
 
+	// 尚未收到回调时主动向网关查询，避免回调丢失导致状态停留在待支付
+	if paymentRecord.Status == "pending" {
+		if synced, err := s.syncChargeStatus(ctx, order, paymentRecord); err != nil {
+			logger.Warn("主动查询支付状态失败", logger.String("order_id", order.ID), logger.String("error", err.Error()))
+		} else {
+			paymentRecord = synced
+		}
+	}
+
 	return &model.QueryPaymentStatusResponse{
 		OrderID:       order.ID,
 		PaymentID:     paymentRecord.ID,
@@ -123,57 +210,27 @@ func (s *paymentService) ListPaymentHistory(ctx context.Context, req *model.Paym
 	}, nil
 }
 
+// CreateRefund 对已完成的支付发起退款，同一笔支付可多次部分退款，累计不超过支付金额
 func (s *paymentService) CreateRefund(ctx context.Context, req *model.CreateRefundRequest) (*model.CreateRefundResponse, error) {
-	// 检查支付记录是否存在
-	paymentRecord, err := s.paymentRepo.GetPaymentRecordByID(ctx, req.PaymentID)
-	if err != nil {
-		return nil, errors.New("支付记录不存在")
-	}
-
-	// 检查支付状态
-	if paymentRecord.Status != "completed" {
-		return nil, errors.New("支付未完成，无法退款")
-	}
-
-	// 检查退款金额
-	if req.Amount > paymentRecord.Amount {
-		return nil, errors.New("退款金额不能超过支付金额")
-	}
-
-	// 检查是否已有退款
-	existingRefund, err := s.paymentRepo.GetRefundByPaymentID(ctx, req.PaymentID)
-	if err == nil && existingRefund != nil {
-		return nil, errors.New("该支付已有退款记录")
-	}
-
-	// 创建退款记录
-	refund := &model.PaymentRefund{
-		PaymentID:   req.PaymentID,
-		Amount:      req.Amount,
-		Status:      "pending",
-		Reason:      req.Reason,
-		Description: req.Description,
-	}
-
-	err = s.paymentRepo.CreateRefund(ctx, refund)
-	if err != nil {
-		return nil, err
-	}
-
-	estimatedCompletionTime := time.Now().AddDate(0, 0, 3) // 3天后
-
-	return &model.CreateRefundResponse{
-		RefundID:                refund.ID,
-		Status:                  refund.Status,
-		EstimatedCompletionTime: estimatedCompletionTime,
-	}, nil
+	return s.refundPayment(ctx, req.PaymentID, req.Amount, req.Reason, req.Description, false)
 }
 
-func (s *paymentService) QueryRefundStatus(ctx context.Context, refundID string) (*model.QueryRefundStatusResponse, error) {
+// QueryRefundStatus 查询退款状态，仅原订单的下单用户和管理员可查询，其他用户视为退款记录不存在
+func (s *paymentService) QueryRefundStatus(ctx context.Context, userID string, isAdmin bool, refundID string) (*model.QueryRefundStatusResponse, error) {
 	refund, err := s.paymentRepo.GetRefundByID(ctx, refundID)
 	if err != nil {
 		return nil, errors.New("退款记录不存在")
 	}
+	if !isAdmin {
+		paymentRecord, err := s.paymentRepo.GetPaymentRecordByID(ctx, refund.PaymentID)
+		if err != nil {
+			return nil, errors.New("退款记录不存在")
+		}
+		order, err := s.paymentRepo.GetOrderByID(ctx, paymentRecord.OrderID)
+		if err != nil || order.UserID != userID {
+			return nil, errors.New("退款记录不存在")
+		}
+	}
 
 	return &model.QueryRefundStatusResponse{
 		RefundID:            refund.ID,
@@ -193,8 +250,16 @@ func (s *paymentService) ListPaymentMethods(ctx context.Context) (*model.Payment
 		return nil, err
 	}
 
+	// 只返回已接入网关的支付方式
+	available := make([]*model.PaymentMethod, 0, len(methods))
+	for _, method := range methods {
+		if _, err := s.gateways.ForMethod(method.ID); err == nil {
+			available = append(available, method)
+		}
+	}
+
 	return &model.PaymentMethodListResponse{
-		PaymentMethods: methods,
+		PaymentMethods: available,
 	}, nil
 }
 
@@ -208,48 +273,3 @@ func (s *paymentService) GetPaymentStats(ctx context.Context, req *model.Payment
 		Stats: stats,
 	}, nil
 }
-
-func (s *paymentService) ProcessPaymentWebhook(ctx context.Context, gateway string, req *model.PaymentWebhookRequest) (*model.PaymentWebhookResponse, error) {
-	// 验证签名（简化处理）
-	if !s.verifySignature(req) {
-		return nil, errors.New("签名验证失败")
-	}
-
-	// 更新支付状态
-	now := time.Now()
-	err := s.paymentRepo.UpdatePaymentRecordStatus(ctx, req.OrderID, req.Status, &now, req.TransactionID)
-	if err != nil {
-		return nil, err
-	}
-
-	// 更新订单状态
-	orderStatus := "pending"
-	if req.Status == "success" {
-		orderStatus = "completed"
-	} else if req.Status == "failed" {
-		orderStatus = "failed"
-	}
-
-	err = s.paymentRepo.UpdateOrderStatus(ctx, req.OrderID, orderStatus)
-	if err != nil {
-		return nil, err
-	}
-
-	return &model.PaymentWebhookResponse{
-		Processed: true,
-	}, nil
-}
-
-// 辅助方法
-func (s *paymentService) generatePaymentURL(orderID, paymentMethod string) string {
-	return "https://example.com/payment/gateway?order_id=" + orderID + "&method=" + paymentMethod
-}
-
-func (s *paymentService) generateQRCode(orderID string) string {
-	return "https://example.com/qr-code/" + orderID + ".png"
-}
-
-func (s *paymentService) verifySignature(req *model.PaymentWebhookRequest) bool {
-	// 简化签名验证，实际应该使用加密算法
-	return req.Signature != ""
-}
@@ -0,0 +1,533 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"master-guide-backend/internal/gateway"
+	"master-guide-backend/internal/ledger"
+	"master-guide-backend/internal/model"
+	"master-guide-backend/internal/repository"
+	"master-guide-backend/pkg/logger"
+)
+
+// 回调数据与订单不符时的错误，此类回调会被标记为 rejected，重复投递不再处理，需人工核实后重放
+var (
+	errWebhookOrderNotFound    = errors.New("订单不存在")
+	errWebhookRecordNotFound   = errors.New("支付记录不存在")
+	errWebhookRefundNotFound   = errors.New("退款记录不存在")
+	errWebhookGatewayMismatch  = errors.New("支付网关不匹配")
+	errWebhookOrderMismatch    = errors.New("退款订单不匹配")
+	errWebhookAmountMismatch   = errors.New("支付金额不一致")
+	errWebhookCurrencyMismatch = errors.New("支付币种不一致")
+)
+
+// paymentStatusTransitions 支付状态流转规则
+// 已完成的支付不会再被回调改为其他状态；关闭或失败后迟到的支付成功仍需入账
+var paymentStatusTransitions = map[string][]string{
+	"pending":   {"completed", "failed", "cancelled"},
+	"failed":    {"completed"},
+	"cancelled": {"completed"},
+}
+
+// refundStatusTransitions 退款状态流转规则
+var refundStatusTransitions = map[string][]string{
+	"pending": {"completed", "failed"},
+}
+
+// webhookApplyResult 回调事件处理结果
+type webhookApplyResult struct {
+	status  string // 订单或退款的最新状态
+	changed bool
+	note    string // 未产生变更的原因
+}
+
+func (s *paymentService) ProcessPaymentWebhook(ctx context.Context, gatewayName string, header http.Header, body []byte) (*model.PaymentWebhookResponse, error) {
+	gw, err := s.gateways.ByName(gatewayName)
+	if err != nil {
+		return nil, errors.New("不支持的支付网关")
+	}
+
+	event, err := gw.VerifyWebhook(ctx, header, body)
+	if errors.Is(err, gateway.ErrInvalidSignature) {
+		logger.Warn("支付回调验签失败", logger.String("gateway", gatewayName))
+		return nil, errors.New("签名验证失败")
+	}
+	if err != nil || (event.TransactionID == "" && event.EventID == "") {
+		return nil, errors.New("回调数据无效")
+	}
+
+	// 先落入收件箱，同一通知重复投递时命中唯一约束
+	inbox := newWebhookInboxEvent(gw.Name(), event, body)
+	created, err := s.paymentRepo.SaveWebhookEvent(ctx, inbox)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		switch inbox.Status {
+		case model.WebhookEventStatusProcessed, model.WebhookEventStatusIgnored:
+			logger.Info("重复的支付回调", logger.String("gateway", inbox.Gateway), logger.String("event_id", inbox.ID))
+			response := s.duplicateWebhookResponse(ctx, inbox)
+			// 此前履约或冲正失败的订单借重复投递再次处理，已完成的不会重复处理
+			if inbox.Status == model.WebhookEventStatusProcessed && inbox.EventType == gateway.EventTypePayment {
+				if err := s.onPaymentStatusChanged(ctx, inbox.OrderID, response.Status); err != nil {
+					return nil, errors.New("订单履约失败")
+				}
+			}
+			if inbox.Status == model.WebhookEventStatusProcessed && inbox.EventType == gateway.EventTypeRefund && response.Status == "completed" {
+				if err := s.onRefundCompleted(ctx, inbox.RefundID); err != nil {
+					return nil, errors.New("退款冲正失败")
+				}
+			}
+			return response, nil
+		case model.WebhookEventStatusRejected:
+			return nil, errors.New(inbox.LastError)
+		}
+		// received 或 failed：上次处理未成功，继续处理
+	}
+
+	return s.processWebhookEvent(ctx, inbox.ID)
+}
+
+func (s *paymentService) PaymentWebhookAck(gatewayName string, success bool) (string, []byte) {
+	gw, err := s.gateways.ByName(gatewayName)
+	if err != nil {
+		return "", nil
+	}
+	return gw.WebhookAck(success)
+}
+
+// SandboxEnabled 是否注册了沙箱网关
+func (s *paymentService) SandboxEnabled() bool {
+	_, ok := s.gateways.Sandbox()
+	return ok
+}
+
+func (s *paymentService) SimulateSandboxPayment(ctx context.Context, chargeID, status string) (*model.PaymentWebhookResponse, error) {
+	sandbox, ok := s.gateways.Sandbox()
+	if !ok {
+		return nil, errors.New("沙箱支付未启用")
+	}
+
+	header, body, err := sandbox.Simulate(chargeID, status)
+	if errors.Is(err, gateway.ErrChargeNotFound) {
+		return nil, errors.New("沙箱支付单不存在")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 走与真实回调相同的验签和处理流程
+	return s.ProcessPaymentWebhook(ctx, sandbox.Name(), header, body)
+}
+
+func (s *paymentService) ReplayWebhookEvent(ctx context.Context, eventID string) (*model.PaymentWebhookResponse, error) {
+	inbox, err := s.paymentRepo.GetWebhookEventByID(ctx, eventID)
+	if err != nil {
+		return nil, errors.New("回调事件不存在")
+	}
+	if inbox.Status == model.WebhookEventStatusProcessed {
+		return nil, errors.New("回调事件已处理")
+	}
+
+	logger.Info("重放支付回调", logger.String("gateway", inbox.Gateway), logger.String("event_id", inbox.ID), logger.String("status", inbox.Status))
+	return s.processWebhookEvent(ctx, inbox.ID)
+}
+
+func (s *paymentService) ListWebhookEvents(ctx context.Context, req *model.ListPaymentWebhookEventsRequest) (*model.PaymentWebhookEventListResponse, error) {
+	events, total, err := s.paymentRepo.ListWebhookEvents(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(req.PageSize)))
+
+	return &model.PaymentWebhookEventListResponse{
+		Events: events,
+		Pagination: &model.PaginationResponse{
+			Total:      total,
+			Page:       req.Page,
+			PageSize:   req.PageSize,
+			TotalPages: totalPages,
+		},
+	}, nil
+}
+
+// processWebhookEvent 在同一事务中锁定收件箱记录并更新订单、支付记录或退款，保证每条回调只生效一次
+func (s *paymentService) processWebhookEvent(ctx context.Context, inboxID string) (*model.PaymentWebhookResponse, error) {
+	var response *model.PaymentWebhookResponse
+	isRefundEvent := false
+	refundID := ""
+
+	err := s.paymentRepo.WithTx(ctx, func(repo repository.PaymentRepository) error {
+		inbox, err := repo.GetWebhookEventForUpdate(ctx, inboxID)
+		if err != nil {
+			return err
+		}
+		// 并发投递时另一请求已处理完成
+		if inbox.Status == model.WebhookEventStatusProcessed {
+			response = s.duplicateWebhookResponse(ctx, inbox)
+			return nil
+		}
+
+		var result *webhookApplyResult
+		isRefundEvent = inbox.EventType == gateway.EventTypeRefund
+		refundID = inbox.RefundID
+		if isRefundEvent {
+			result, err = s.applyRefundEvent(ctx, repo, inbox)
+		} else {
+			result, err = s.applyPaymentEvent(ctx, repo, inbox)
+		}
+		if err != nil {
+			return err
+		}
+
+		inboxStatus := model.WebhookEventStatusProcessed
+		if !result.changed {
+			inboxStatus = model.WebhookEventStatusIgnored
+		}
+		if err := repo.UpdateWebhookEventStatus(ctx, inbox.ID, inboxStatus, result.note); err != nil {
+			return err
+		}
+
+		response = &model.PaymentWebhookResponse{
+			Processed:   result.changed,
+			EventID:     inbox.ID,
+			OrderID:     inbox.OrderID,
+			Status:      result.status,
+			InboxStatus: inboxStatus,
+		}
+		return nil
+	})
+	if err == nil {
+		if response.Processed && !isRefundEvent {
+			// 履约失败时应答失败，由网关重新投递触发补偿
+			if err := s.onPaymentStatusChanged(ctx, response.OrderID, response.Status); err != nil {
+				return nil, errors.New("订单履约失败")
+			}
+		}
+		if response.Processed && isRefundEvent && response.Status == "completed" {
+			if err := s.onRefundCompleted(ctx, refundID); err != nil {
+				return nil, errors.New("退款冲正失败")
+			}
+		}
+		return response, nil
+	}
+
+	// 事务已回滚，单独记录处理失败原因
+	inboxStatus := model.WebhookEventStatusFailed
+	if isWebhookRejection(err) {
+		inboxStatus = model.WebhookEventStatusRejected
+	}
+	if updateErr := s.paymentRepo.UpdateWebhookEventStatus(ctx, inboxID, inboxStatus, err.Error()); updateErr != nil {
+		logger.Error("更新支付回调状态失败", logger.String("event_id", inboxID), logger.String("error", updateErr.Error()))
+	}
+	logger.Warn("支付回调处理失败", logger.String("event_id", inboxID), logger.String("status", inboxStatus), logger.String("error", err.Error()))
+
+	if inboxStatus == model.WebhookEventStatusFailed {
+		return nil, errors.New("回调处理失败")
+	}
+	return nil, err
+}
+
+// applyPaymentEvent 处理支付结果通知
+func (s *paymentService) applyPaymentEvent(ctx context.Context, repo repository.PaymentRepository, inbox *model.PaymentWebhookEvent) (*webhookApplyResult, error) {
+	order, err := repo.GetOrderForUpdate(ctx, inbox.OrderID)
+	if err != nil {
+		return nil, errWebhookOrderNotFound
+	}
+
+	paymentRecord, err := repo.GetPaymentRecordByOrderIDForUpdate(ctx, order.ID)
+	if err != nil {
+		return nil, errWebhookRecordNotFound
+	}
+
+	// 回调网关必须与订单支付方式对应的网关一致
+	if expected, err := s.gateways.ForMethod(paymentRecord.PaymentMethod); err != nil || expected.Name() != inbox.Gateway {
+		return nil, errWebhookGatewayMismatch
+	}
+
+	return s.applyChargeStatus(ctx, repo, order, paymentRecord, &gateway.ChargeStatus{
+		OrderID:       inbox.OrderID,
+		TransactionID: inbox.TransactionID,
+		Status:        inbox.EventStatus,
+		Amount:        inbox.Amount,
+		Currency:      inbox.Currency,
+		PaidAt:        inbox.OccurredAt,
+	})
+}
+
+// applyRefundEvent 处理退款结果通知，退款完成时记账
+func (s *paymentService) applyRefundEvent(ctx context.Context, repo repository.PaymentRepository, inbox *model.PaymentWebhookEvent) (*webhookApplyResult, error) {
+	refund, err := repo.GetRefundForUpdate(ctx, inbox.RefundID)
+	if err != nil {
+		return nil, errWebhookRefundNotFound
+	}
+
+	paymentRecord, err := repo.GetPaymentRecordByID(ctx, refund.PaymentID)
+	if err != nil {
+		return nil, errWebhookRecordNotFound
+	}
+	if inbox.OrderID != "" && inbox.OrderID != paymentRecord.OrderID {
+		return nil, errWebhookOrderMismatch
+	}
+	if expected, err := s.gateways.ForMethod(paymentRecord.PaymentMethod); err != nil || expected.Name() != inbox.Gateway {
+		return nil, errWebhookGatewayMismatch
+	}
+	// 部分网关的退款通知只携带累计退款金额，此时金额为0，不做核对
+	if inbox.Amount > 0 && !amountEqual(inbox.Amount, refund.Amount) {
+		return nil, errWebhookAmountMismatch
+	}
+	if !currencyEqual(inbox.Currency, paymentRecord.Currency) {
+		return nil, errWebhookCurrencyMismatch
+	}
+
+	var to string
+	switch inbox.EventStatus {
+	case gateway.RefundStatusSucceeded:
+		to = "completed"
+	case gateway.RefundStatusFailed:
+		to = "failed"
+	default:
+		return &webhookApplyResult{status: refund.Status, note: "退款处理中"}, nil
+	}
+
+	if refund.Status == to {
+		return &webhookApplyResult{status: refund.Status, note: "状态未变化"}, nil
+	}
+	if !canTransition(refundStatusTransitions, refund.Status, to) {
+		logger.Warn("忽略非法的退款状态变更", logger.String("refund_id", refund.ID), logger.String("from", refund.Status), logger.String("to", to))
+		return &webhookApplyResult{status: refund.Status, note: fmt.Sprintf("非法状态变更 %s -> %s", refund.Status, to)}, nil
+	}
+
+	var completedAt *time.Time
+	if to == "completed" {
+		completedAt = inbox.OccurredAt
+		if completedAt == nil {
+			now := time.Now()
+			completedAt = &now
+		}
+	}
+	// 自动退款被网关异步拒绝时同样安排重试
+	if to == "failed" && refund.Automatic {
+		if err := repo.MarkRefundFailed(ctx, refund.ID, nextRefundRetry(refund.Attempts, time.Now())); err != nil {
+			return nil, err
+		}
+		return &webhookApplyResult{status: to, changed: true}, nil
+	}
+	if err := repo.UpdateRefundStatus(ctx, refund.ID, to, completedAt, inbox.TransactionID); err != nil {
+		return nil, err
+	}
+	if to == "completed" {
+		if err := repo.PostLedger(ctx, ledger.RefundPaid(refund.ID, paymentRecord.OrderID, ledger.ToMinor(refund.Amount), *completedAt)); err != nil {
+			return nil, err
+		}
+	}
+	return &webhookApplyResult{status: to, changed: true}, nil
+}
+
+// syncChargeStatus 主动向网关查询并同步支付状态
+func (s *paymentService) syncChargeStatus(ctx context.Context, order *model.PaymentOrder, paymentRecord *model.PaymentRecord) (*model.PaymentRecord, error) {
+	gw, err := s.gateways.ForMethod(paymentRecord.PaymentMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	charge, err := gw.QueryCharge(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *webhookApplyResult
+	err = s.paymentRepo.WithTx(ctx, func(repo repository.PaymentRepository) error {
+		lockedOrder, err := repo.GetOrderForUpdate(ctx, order.ID)
+		if err != nil {
+			return err
+		}
+		lockedRecord, err := repo.GetPaymentRecordByOrderIDForUpdate(ctx, order.ID)
+		if err != nil {
+			return err
+		}
+		result, err = s.applyChargeStatus(ctx, repo, lockedOrder, lockedRecord, charge)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.changed {
+		_ = s.onPaymentStatusChanged(ctx, order.ID, result.status)
+	}
+	return s.paymentRepo.GetPaymentRecordByID(ctx, paymentRecord.ID)
+}
+
+// applyChargeStatus 校验金额、币种和状态流转后更新支付记录和订单，支付完成时记账，需在事务中调用且记录已加锁
+func (s *paymentService) applyChargeStatus(ctx context.Context, repo repository.PaymentRepository, order *model.PaymentOrder, paymentRecord *model.PaymentRecord, charge *gateway.ChargeStatus) (*webhookApplyResult, error) {
+	var to string
+	switch charge.Status {
+	case gateway.ChargeStatusSucceeded:
+		to = "completed"
+	case gateway.ChargeStatusFailed:
+		to = "failed"
+	case gateway.ChargeStatusClosed:
+		to = "cancelled"
+	default:
+		return &webhookApplyResult{status: paymentRecord.Status, note: "等待支付"}, nil
+	}
+
+	if to == "completed" {
+		if !amountEqual(charge.Amount, paymentRecord.Amount) {
+			logger.Warn("支付金额不一致", logger.String("order_id", order.ID), logger.Float64("expected", paymentRecord.Amount), logger.Float64("actual", charge.Amount))
+			return nil, errWebhookAmountMismatch
+		}
+		if !currencyEqual(charge.Currency, paymentRecord.Currency) {
+			logger.Warn("支付币种不一致", logger.String("order_id", order.ID), logger.String("expected", paymentRecord.Currency), logger.String("actual", charge.Currency))
+			return nil, errWebhookCurrencyMismatch
+		}
+	}
+
+	if paymentRecord.Status == to {
+		return &webhookApplyResult{status: paymentRecord.Status, note: "状态未变化"}, nil
+	}
+	if !canTransition(paymentStatusTransitions, paymentRecord.Status, to) {
+		logger.Warn("忽略非法的支付状态变更", logger.String("order_id", order.ID), logger.String("from", paymentRecord.Status), logger.String("to", to))
+		return &webhookApplyResult{status: paymentRecord.Status, note: fmt.Sprintf("非法状态变更 %s -> %s", paymentRecord.Status, to)}, nil
+	}
+	if paymentRecord.Status != "pending" {
+		logger.Warn("订单关闭后收到支付成功通知", logger.String("order_id", order.ID), logger.String("from", paymentRecord.Status))
+	}
+
+	var paidAt *time.Time
+	if to == "completed" {
+		paidAt = charge.PaidAt
+		if paidAt == nil {
+			now := time.Now()
+			paidAt = &now
+		}
+	}
+
+	if err := repo.UpdatePaymentRecordStatus(ctx, paymentRecord.ID, to, paidAt, charge.TransactionID); err != nil {
+		return nil, err
+	}
+	if err := repo.UpdateOrderStatus(ctx, order.ID, to); err != nil {
+		return nil, err
+	}
+	if to == "completed" {
+		if err := repo.PostLedger(ctx, ledger.PaymentReceived(order.ID, ledger.ToMinor(paymentRecord.Amount), *paidAt)); err != nil {
+			return nil, err
+		}
+	}
+	return &webhookApplyResult{status: to, changed: true}, nil
+}
+
+// onPaymentStatusChanged 支付状态变更后开通或释放业务订单，业务订单已无法开通时退回支付金额
+// 在支付事务提交后执行；失败时订单保持未履约（fulfilled_at 为空），可再次触发且不会重复履约或重复退款
+func (s *paymentService) onPaymentStatusChanged(ctx context.Context, orderID, status string) error {
+	var err error
+	switch status {
+	case "completed":
+		err = s.fulfillment.FulfillOrder(ctx, orderID)
+		if errors.Is(err, repository.ErrOrderUnfulfillable) {
+			err = s.refundUnfulfillableOrder(ctx, orderID)
+		}
+	case "failed", "cancelled":
+		err = s.fulfillment.ReleaseOrder(ctx, orderID)
+	default:
+		return nil
+	}
+	if err != nil {
+		logger.Error("支付订单履约失败", logger.String("order_id", orderID), logger.String("status", status), logger.String("error", err.Error()))
+	}
+	return err
+}
+
+// duplicateWebhookResponse 构造重复回调的响应，返回订单或退款的当前状态
+func (s *paymentService) duplicateWebhookResponse(ctx context.Context, inbox *model.PaymentWebhookEvent) *model.PaymentWebhookResponse {
+	response := &model.PaymentWebhookResponse{
+		Duplicate:   true,
+		EventID:     inbox.ID,
+		OrderID:     inbox.OrderID,
+		InboxStatus: inbox.Status,
+	}
+	if inbox.EventType == gateway.EventTypeRefund {
+		if refund, err := s.paymentRepo.GetRefundByID(ctx, inbox.RefundID); err == nil {
+			response.Status = refund.Status
+		}
+	} else if order, err := s.paymentRepo.GetOrderByID(ctx, inbox.OrderID); err == nil {
+		response.Status = order.Status
+	}
+	return response
+}
+
+// newWebhookInboxEvent 由已验签的网关事件构造收件箱记录
+func newWebhookInboxEvent(gatewayName string, event *gateway.WebhookEvent, body []byte) *model.PaymentWebhookEvent {
+	inbox := &model.PaymentWebhookEvent{
+		Gateway:       gatewayName,
+		DedupeKey:     webhookDedupeKey(event),
+		EventID:       event.EventID,
+		EventType:     event.Type,
+		OrderID:       event.OrderID,
+		RefundID:      event.RefundID,
+		TransactionID: event.TransactionID,
+		EventStatus:   event.Status,
+		Amount:        event.Amount,
+		Currency:      event.Currency,
+		Payload:       string(body),
+		Status:        model.WebhookEventStatusReceived,
+	}
+	if !event.OccurredAt.IsZero() {
+		occurredAt := event.OccurredAt
+		inbox.OccurredAt = &occurredAt
+	}
+	return inbox
+}
+
+// webhookDedupeKey 同一网关下按交易号去重
+// 同一交易的不同状态通知（如先待支付后成功）以及不同退款单分别处理；没有交易号时退化为网关通知ID
+func webhookDedupeKey(event *gateway.WebhookEvent) string {
+	if event.TransactionID == "" {
+		return "event:" + event.EventID
+	}
+	key := event.Type + ":" + event.TransactionID
+	if event.RefundID != "" {
+		key += ":" + event.RefundID
+	}
+	return key + ":" + event.Status
+}
+
+// isWebhookRejection 判断是否为回调数据与订单不符导致的错误
+func isWebhookRejection(err error) bool {
+	switch err {
+	case errWebhookOrderNotFound, errWebhookRecordNotFound, errWebhookRefundNotFound,
+		errWebhookGatewayMismatch, errWebhookOrderMismatch, errWebhookAmountMismatch, errWebhookCurrencyMismatch:
+		return true
+	}
+	return false
+}
+
+// canTransition 判断状态流转是否合法
+func canTransition(rules map[string][]string, from, to string) bool {
+	for _, next := range rules[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// amountEqual 按分比较金额
+func amountEqual(a, b float64) bool {
+	return math.Round(a*100) == math.Round(b*100)
+}
+
+// currencyEqual 比较币种，网关未返回币种时不做校验
+func currencyEqual(actual, expected string) bool {
+	if actual == "" || expected == "" {
+		return true
+	}
+	return strings.EqualFold(actual, expected)
+}
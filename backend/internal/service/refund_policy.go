@@ -0,0 +1,103 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"master-guide-backend/internal/model"
+	"master-guide-backend/pkg/config"
+)
+
+// refundDecision 取消时按退款策略计算的退款比例
+type refundDecision struct {
+	Rate float64 // 0 表示不退款，1 表示全额退款
+	Rule string  // 命中的规则说明，记录为退款原因
+}
+
+// refundPolicy 取消预约和退课的退款策略
+type refundPolicy struct {
+	config config.RefundPolicyConfig
+}
+
+// newRefundPolicy 创建退款策略，未配置或与全额退款档位矛盾的项使用默认值
+// 默认值会按全额退款档位调整，保证部分退款档位不会被全额退款档位覆盖而无法命中
+func newRefundPolicy(cfg config.RefundPolicyConfig) *refundPolicy {
+	if cfg.Appointment.FullRefundHours <= 0 {
+		cfg.Appointment.FullRefundHours = 24
+	}
+	if cfg.Appointment.PartialRefundHours <= 0 || cfg.Appointment.PartialRefundHours > cfg.Appointment.FullRefundHours {
+		cfg.Appointment.PartialRefundHours = min(2, cfg.Appointment.FullRefundHours)
+	}
+	if cfg.Appointment.PartialRefundRate <= 0 || cfg.Appointment.PartialRefundRate > 1 {
+		cfg.Appointment.PartialRefundRate = 0.5
+	}
+	if cfg.Course.RefundWindowDays <= 0 {
+		cfg.Course.RefundWindowDays = 14
+	}
+	if cfg.Course.FullRefundMaxProgress <= 0 {
+		cfg.Course.FullRefundMaxProgress = 10
+	}
+	if cfg.Course.PartialRefundMaxProgress <= 0 || cfg.Course.PartialRefundMaxProgress < cfg.Course.FullRefundMaxProgress {
+		cfg.Course.PartialRefundMaxProgress = max(50, cfg.Course.FullRefundMaxProgress)
+	}
+	if cfg.Course.PartialRefundRate <= 0 || cfg.Course.PartialRefundRate > 1 {
+		cfg.Course.PartialRefundRate = 0.5
+	}
+	return &refundPolicy{config: cfg}
+}
+
+// forAppointment 计算预约取消或被拒绝后的退款比例
+// 大师或系统取消、大师拒绝时全额退款；学生取消时按距开始的时间退款；缺席等其他状态不自动退款
+func (p *refundPolicy) forAppointment(appointment *model.AppointmentModel, actor, to string, now time.Time) refundDecision {
+	if to == model.AppointmentStatusRejected {
+		return refundDecision{Rate: 1, Rule: "大师拒绝预约，全额退款"}
+	}
+	if to != model.AppointmentStatusCancelled {
+		return refundDecision{Rule: "该状态不自动退款"}
+	}
+	if actor != model.AppointmentActorStudent {
+		return refundDecision{Rate: 1, Rule: "大师取消预约，全额退款"}
+	}
+
+	cfg := p.config.Appointment
+	before := appointment.AppointmentTime.Sub(now)
+	switch {
+	case before >= time.Duration(cfg.FullRefundHours)*time.Hour:
+		return refundDecision{Rate: 1, Rule: fmt.Sprintf("开始前%d小时以上取消，全额退款", cfg.FullRefundHours)}
+	case before >= time.Duration(cfg.PartialRefundHours)*time.Hour:
+		return refundDecision{Rate: cfg.PartialRefundRate, Rule: fmt.Sprintf("开始前%d~%d小时取消，退还%.0f%%", cfg.PartialRefundHours, cfg.FullRefundHours, cfg.PartialRefundRate*100)}
+	default:
+		return refundDecision{Rule: fmt.Sprintf("开始前%d小时内取消，不退款", cfg.PartialRefundHours)}
+	}
+}
+
+// forCourse 计算学生退课的退款比例，超过退款期限或学习进度过高时不退款
+func (p *refundPolicy) forCourse(record *model.LearningRecordModel, now time.Time) refundDecision {
+	cfg := p.config.Course
+	if now.Sub(record.EnrolledAt) > time.Duration(cfg.RefundWindowDays)*24*time.Hour {
+		return refundDecision{Rule: fmt.Sprintf("报名超过%d天，不退款", cfg.RefundWindowDays)}
+	}
+
+	switch progress := record.ProgressPercentage; {
+	case progress <= cfg.FullRefundMaxProgress:
+		return refundDecision{Rate: 1, Rule: fmt.Sprintf("学习进度不超过%.0f%%，全额退款", cfg.FullRefundMaxProgress)}
+	case progress <= cfg.PartialRefundMaxProgress:
+		return refundDecision{Rate: cfg.PartialRefundRate, Rule: fmt.Sprintf("学习进度不超过%.0f%%，退还%.0f%%", cfg.PartialRefundMaxProgress, cfg.PartialRefundRate*100)}
+	default:
+		return refundDecision{Rule: fmt.Sprintf("学习进度超过%.0f%%，不退款", cfg.PartialRefundMaxProgress)}
+	}
+}
+
+// cancellationRefund 组合退款策略和退款结果，业务订单未支付时返回 nil
+func cancellationRefund(decision refundDecision, refund *model.CreateRefundResponse) *model.CancellationRefund {
+	if refund == nil {
+		return nil
+	}
+	return &model.CancellationRefund{
+		Rule:     decision.Rule,
+		Rate:     decision.Rate,
+		RefundID: refund.RefundID,
+		Amount:   refund.Amount,
+		Status:   refund.Status,
+	}
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"master-guide-backend/internal/model"
+	"master-guide-backend/pkg/config"
+)
+
+func TestRefundPolicyForAppointment(t *testing.T) {
+	policy := newRefundPolicy(config.RefundPolicyConfig{})
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		before time.Duration
+		actor  string
+		to     string
+		want   float64
+	}{
+		{name: "mentor rejected", before: time.Hour, actor: model.AppointmentActorMentor, to: model.AppointmentStatusRejected, want: 1},
+		{name: "mentor cancelled late", before: time.Minute, actor: model.AppointmentActorMentor, to: model.AppointmentStatusCancelled, want: 1},
+		{name: "system cancelled after start", before: -time.Hour, actor: model.AppointmentActorSystem, to: model.AppointmentStatusCancelled, want: 1},
+		{name: "no show", before: -time.Hour, actor: model.AppointmentActorSystem, to: model.AppointmentStatusNoShow, want: 0},
+		{name: "well ahead", before: 72 * time.Hour, actor: model.AppointmentActorStudent, to: model.AppointmentStatusCancelled, want: 1},
+		{name: "exactly full refund hours", before: 24 * time.Hour, actor: model.AppointmentActorStudent, to: model.AppointmentStatusCancelled, want: 1},
+		{name: "just inside full refund hours", before: 24*time.Hour - time.Second, actor: model.AppointmentActorStudent, to: model.AppointmentStatusCancelled, want: 0.5},
+		{name: "exactly partial refund hours", before: 2 * time.Hour, actor: model.AppointmentActorStudent, to: model.AppointmentStatusCancelled, want: 0.5},
+		{name: "just inside partial refund hours", before: 2*time.Hour - time.Second, actor: model.AppointmentActorStudent, to: model.AppointmentStatusCancelled, want: 0},
+		{name: "at start", before: 0, actor: model.AppointmentActorStudent, to: model.AppointmentStatusCancelled, want: 0},
+		{name: "after start", before: -time.Minute, actor: model.AppointmentActorStudent, to: model.AppointmentStatusCancelled, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appointment := &model.AppointmentModel{AppointmentTime: now.Add(tt.before)}
+			decision := policy.forAppointment(appointment, tt.actor, tt.to, now)
+			if decision.Rate != tt.want {
+				t.Fatalf("forAppointment rate = %v (%s), want %v", decision.Rate, decision.Rule, tt.want)
+			}
+			if decision.Rule == "" {
+				t.Fatal("forAppointment returned an empty rule")
+			}
+		})
+	}
+}
+
+func TestRefundPolicyForCourse(t *testing.T) {
+	policy := newRefundPolicy(config.RefundPolicyConfig{})
+	now := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	window := 14 * 24 * time.Hour
+
+	tests := []struct {
+		name     string
+		enrolled time.Duration
+		progress float64
+		want     float64
+	}{
+		{name: "just enrolled", enrolled: time.Hour, want: 1},
+		{name: "exactly window days", enrolled: window, want: 1},
+		{name: "just past window days", enrolled: window + time.Second, want: 0},
+		{name: "exactly full refund progress", enrolled: time.Hour, progress: 10, want: 1},
+		{name: "just above full refund progress", enrolled: time.Hour, progress: 10.01, want: 0.5},
+		{name: "exactly partial refund progress", enrolled: time.Hour, progress: 50, want: 0.5},
+		{name: "just above partial refund progress", enrolled: time.Hour, progress: 50.01, want: 0},
+		{name: "completed", enrolled: time.Hour, progress: 100, want: 0},
+		{name: "past window with no progress", enrolled: 30 * 24 * time.Hour, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := &model.LearningRecordModel{EnrolledAt: now.Add(-tt.enrolled), ProgressPercentage: tt.progress}
+			decision := policy.forCourse(record, now)
+			if decision.Rate != tt.want {
+				t.Fatalf("forCourse rate = %v (%s), want %v", decision.Rate, decision.Rule, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRefundPolicyDefaults(t *testing.T) {
+	tests := []struct {
+		name             string
+		cfg              config.RefundPolicyConfig
+		wantPartialHours int
+		wantPartialMax   float64
+	}{
+		{name: "all defaults", wantPartialHours: 2, wantPartialMax: 50},
+		{
+			name:             "configured values kept",
+			cfg:              config.RefundPolicyConfig{Appointment: config.AppointmentRefundPolicyConfig{FullRefundHours: 48, PartialRefundHours: 12}, Course: config.CourseRefundPolicyConfig{FullRefundMaxProgress: 20, PartialRefundMaxProgress: 40}},
+			wantPartialHours: 12,
+			wantPartialMax:   40,
+		},
+		{
+			name:             "full refund tier above partial default",
+			cfg:              config.RefundPolicyConfig{Appointment: config.AppointmentRefundPolicyConfig{FullRefundHours: 1}, Course: config.CourseRefundPolicyConfig{FullRefundMaxProgress: 60}},
+			wantPartialHours: 1,
+			wantPartialMax:   60,
+		},
+		{
+			name:             "partial tier below full tier",
+			cfg:              config.RefundPolicyConfig{Appointment: config.AppointmentRefundPolicyConfig{FullRefundHours: 12, PartialRefundHours: 24}, Course: config.CourseRefundPolicyConfig{FullRefundMaxProgress: 30, PartialRefundMaxProgress: 20}},
+			wantPartialHours: 2,
+			wantPartialMax:   50,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newRefundPolicy(tt.cfg).config
+			if cfg.Appointment.PartialRefundHours != tt.wantPartialHours || cfg.Appointment.PartialRefundHours > cfg.Appointment.FullRefundHours {
+				t.Errorf("partial refund hours = %d (full %d), want %d", cfg.Appointment.PartialRefundHours, cfg.Appointment.FullRefundHours, tt.wantPartialHours)
+			}
+			if cfg.Course.PartialRefundMaxProgress != tt.wantPartialMax || cfg.Course.PartialRefundMaxProgress < cfg.Course.FullRefundMaxProgress {
+				t.Errorf("partial refund max progress = %v (full %v), want %v", cfg.Course.PartialRefundMaxProgress, cfg.Course.FullRefundMaxProgress, tt.wantPartialMax)
+			}
+		})
+	}
+}
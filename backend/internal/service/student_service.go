@@ -11,7 +11,7 @@ import (
 // StudentService 学生服务接口
 type StudentService interface {
 	GetStudents(ctx context.Context, mentorID string, req *model.StudentListRequest) (*model.StudentListResponse, error)
-	GetStudentByID(ctx context.Context, studentID string) (*model.StudentDetailResponse, error)
+	GetStudentByID(ctx context.Context, mentorID, studentID string) (*model.StudentDetailResponse, error)
 	GetStudentStats(ctx context.Context, mentorID string) (*model.StudentStatsResponse, error)
 	SendMessage(ctx context.Context, mentorID, studentID string, req *model.SendMessageRequest) (*model.SendMessageResponse, error)
 	GetMessages(ctx context.Context, mentorID, studentID string, page, pageSize int) (*model.MessageListResponse, error)
@@ -27,41 +27,23 @@ type studentService struct {
 	identityRepo    repository.IdentityRepository
 	appointmentRepo repository.AppointmentRepository
 	messageRepo     repository.MessageRepository
+	mentorRepo      repository.MentorRepository
 }
 
 // NewStudentService 创建学生服务实例
-func NewStudentService(studentRepo repository.StudentRepository, userRepo repository.UserRepository, identityRepo repository.IdentityRepository, appointmentRepo repository.AppointmentRepository, messageRepo repository.MessageRepository) StudentService {
+func NewStudentService(studentRepo repository.StudentRepository, userRepo repository.UserRepository, identityRepo repository.IdentityRepository, appointmentRepo repository.AppointmentRepository, messageRepo repository.MessageRepository, mentorRepo repository.MentorRepository) StudentService {
 	return &studentService{
 		studentRepo:     studentRepo,
 		userRepo:        userRepo,
 		identityRepo:    identityRepo,
 		appointmentRepo: appointmentRepo,
 		messageRepo:     messageRepo,
+		mentorRepo:      mentorRepo,
 	}
 }
 
 // GetStudents 获取学生列表
 func (s *studentService) GetStudents(ctx context.Context, mentorID string, req *model.StudentListRequest) (*model.StudentListResponse, error) {
-	// 验证导师身份
-	if mentorID != "" {
-		identities, err := s.identityRepo.GetIdentitiesWithProfile(ctx, mentorID)
-		if err != nil {
-			return nil, err
-		}
-
-		isMentor := false
-		for _, identity := range identities {
-			if identity.IdentityType == "master" && identity.Status == "active" {
-				isMentor = true
-				break
-			}
-		}
-
-		if !isMentor {
-			return nil, errors.New("只有导师可以查看学生列表")
-		}
-	}
-
 	// 获取学生列表
 	students, total, err := s.studentRepo.GetStudents(ctx, mentorID, req.Status, req.CourseID, req.Search, req.SortBy, req.Page, req.PageSize)
 	if err != nil {
@@ -84,11 +66,10 @@ func (s *studentService) GetStudents(ctx context.Context, mentorID string, req *
 }
 
 // GetStudentByID 根据ID获取学生详情
-func (s *studentService) GetStudentByID(ctx context.Context, studentID string) (*model.StudentDetailResponse, error) {
-	// 验证学生是否存在
-	_, err := s.userRepo.GetByID(ctx, studentID)
-	if err != nil {
-		return nil, errors.New("学生不存在")
+func (s *studentService) GetStudentByID(ctx context.Context, mentorID, studentID string) (*model.StudentDetailResponse, error) {
+	// 验证学生归属
+	if err := s.ensureMentorStudent(ctx, mentorID, studentID, ""); err != nil {
+		return nil, err
 	}
 
 	// 验证学生身份
@@ -122,26 +103,6 @@ func (s *studentService) GetStudentByID(ctx context.Context, studentID string) (
 
 // GetStudentStats 获取学生统计
 func (s *studentService) GetStudentStats(ctx context.Context, mentorID string) (*model.StudentStatsResponse, error) {
-	// 验证导师身份
-	if mentorID != "" {
-		identities, err := s.identityRepo.GetIdentitiesWithProfile(ctx, mentorID)
-		if err != nil {
-			return nil, err
-		}
-
-		isMentor := false
-		for _, identity := range identities {
-			if identity.IdentityType == "master" && identity.Status == "active" {
-				isMentor = true
-				break
-			}
-		}
-
-		if !isMentor {
-			return nil, errors.New("只有导师可以查看学生统计")
-		}
-	}
-
 	// 获取学生统计
 	stats, err := s.studentRepo.GetStudentStats(ctx, mentorID)
 	if err != nil {
@@ -155,33 +116,19 @@ func (s *studentService) GetStudentStats(ctx context.Context, mentorID string) (
 
 // SendMessage 发送消息给学生
 func (s *studentService) SendMessage(ctx context.Context, mentorID, studentID string, req *model.SendMessageRequest) (*model.SendMessageResponse, error) {
-	// 验证导师身份
-	identities, err := s.identityRepo.GetIdentitiesWithProfile(ctx, mentorID)
-	if err != nil {
+	// 验证学生归属
+	if err := s.ensureMentorStudent(ctx, mentorID, studentID, ""); err != nil {
 		return nil, err
 	}
 
-	isMentor := false
-	for _, identity := range identities {
-		if identity.IdentityType == "master" && identity.Status == "active" {
-			isMentor = true
-			break
-		}
-	}
-
-	if !isMentor {
-		return nil, errors.New("只有导师可以发送消息")
-	}
-
-	// 验证学生是否存在
-	_, err = s.userRepo.GetByID(ctx, studentID)
+	mentor, err := s.mentorRepo.GetMentorByID(ctx, mentorID)
 	if err != nil {
-		return nil, errors.New("学生不存在")
+		return nil, err
 	}
 
 	// 创建消息
 	message := &model.Message{
-		FromUserID: mentorID,
+		FromUserID: mentor.UserID,
 		ToUserID:   studentID,
 		Content:    req.Content,
 		Type:       req.Type,
@@ -199,32 +146,18 @@ func (s *studentService) SendMessage(ctx context.Context, mentorID, studentID st
 
 // GetMessages 获取与学生聊天记录
 func (s *studentService) GetMessages(ctx context.Context, mentorID, studentID string, page, pageSize int) (*model.MessageListResponse, error) {
-	// 验证导师身份
-	identities, err := s.identityRepo.GetIdentitiesWithProfile(ctx, mentorID)
-	if err != nil {
+	// 验证学生归属
+	if err := s.ensureMentorStudent(ctx, mentorID, studentID, ""); err != nil {
 		return nil, err
 	}
 
-	isMentor := false
-	for _, identity := range identities {
-		if identity.IdentityType == "master" && identity.Status == "active" {
-			isMentor = true
-			break
-		}
-	}
-
-	if !isMentor {
-		return nil, errors.New("只有导师可以查看聊天记录")
-	}
-
-	// 验证学生是否存在
-	_, err = s.userRepo.GetByID(ctx, studentID)
+	mentor, err := s.mentorRepo.GetMentorByID(ctx, mentorID)
 	if err != nil {
-		return nil, errors.New("学生不存在")
+		return nil, err
 	}
 
 	// 获取消息列表
-	messages, total, err := s.messageRepo.GetMessages(ctx, mentorID, studentID, page, pageSize)
+	messages, total, err := s.messageRepo.GetMessages(ctx, mentor.UserID, studentID, page, pageSize)
 	if err != nil {
 		return nil, err
 	}
@@ -246,32 +179,13 @@ func (s *studentService) GetMessages(ctx context.Context, mentorID, studentID st
 
 // UpdateStudentProgress 更新学生学习进度
 func (s *studentService) UpdateStudentProgress(ctx context.Context, mentorID, studentID, courseID string, req *model.StudentProgressRequest) (*model.StudentProgressResponse, error) {
-	// 验证导师身份
-	identities, err := s.identityRepo.GetIdentitiesWithProfile(ctx, mentorID)
-	if err != nil {
+	// 验证学生归属（仅限本人课程）
+	if err := s.ensureMentorStudent(ctx, mentorID, studentID, courseID); err != nil {
 		return nil, err
 	}
 
-	isMentor := false
-	for _, identity := range identities {
-		if identity.IdentityType == "master" && identity.Status == "active" {
-			isMentor = true
-			break
-		}
-	}
-
-	if !isMentor {
-		return nil, errors.New("只有导师可以更新学生进度")
-	}
-
-	// 验证学生是否存在
-	_, err = s.userRepo.GetByID(ctx, studentID)
-	if err != nil {
-		return nil, errors.New("学生不存在")
-	}
-
 	// 更新学习进度
-	err = s.studentRepo.UpdateStudentProgress(ctx, studentID, courseID, req.ProgressPercentage, req.Notes)
+	err := s.studentRepo.UpdateStudentProgress(ctx, studentID, courseID, req.ProgressPercentage, req.Notes)
 	if err != nil {
 		return nil, err
 	}
@@ -285,28 +199,18 @@ func (s *studentService) UpdateStudentProgress(ctx context.Context, mentorID, st
 
 // GradeAssignment 评价学生作业
 func (s *studentService) GradeAssignment(ctx context.Context, mentorID, studentID, assignmentID string, req *model.GradeAssignmentRequest) (*model.GradeAssignmentResponse, error) {
-	// 验证导师身份
-	identities, err := s.identityRepo.GetIdentitiesWithProfile(ctx, mentorID)
-	if err != nil {
+	// 验证学生归属
+	if err := s.ensureMentorStudent(ctx, mentorID, studentID, ""); err != nil {
 		return nil, err
 	}
 
-	isMentor := false
-	for _, identity := range identities {
-		if identity.IdentityType == "master" && identity.Status == "active" {
-			isMentor = true
-			break
-		}
-	}
-
-	if !isMentor {
-		return nil, errors.New("只有导师可以评价作业")
-	}
-
-	// 验证学生是否存在
-	_, err = s.userRepo.GetByID(ctx, studentID)
+	// 验证作业归属
+	owned, err := s.studentRepo.IsMentorAssignment(ctx, mentorID, studentID, assignmentID)
 	if err != nil {
-		return nil, errors.New("学生不存在")
+		return nil, err
+	}
+	if !owned {
+		return nil, errors.New("作业不存在")
 	}
 
 	// 评价作业
@@ -323,30 +227,11 @@ func (s *studentService) GradeAssignment(ctx context.Context, mentorID, studentI
 
 // GetStudentReport 获取学生学习报告
 func (s *studentService) GetStudentReport(ctx context.Context, mentorID, studentID string, req *model.StudentReportRequest) (*model.StudentReportResponse, error) {
-	// 验证导师身份
-	identities, err := s.identityRepo.GetIdentitiesWithProfile(ctx, mentorID)
-	if err != nil {
+	// 验证学生归属
+	if err := s.ensureMentorStudent(ctx, mentorID, studentID, ""); err != nil {
 		return nil, err
 	}
 
-	isMentor := false
-	for _, identity := range identities {
-		if identity.IdentityType == "master" && identity.Status == "active" {
-			isMentor = true
-			break
-		}
-	}
-
-	if !isMentor {
-		return nil, errors.New("只有导师可以查看学习报告")
-	}
-
-	// 验证学生是否存在
-	_, err = s.userRepo.GetByID(ctx, studentID)
-	if err != nil {
-		return nil, errors.New("学生不存在")
-	}
-
 	// 获取学习报告
 	report, err := s.studentRepo.GetStudentReport(ctx, studentID, req.Period)
 	if err != nil {
@@ -357,3 +242,19 @@ func (s *studentService) GetStudentReport(ctx context.Context, mentorID, student
 		Report: report,
 	}, nil
 }
+
+// ensureMentorStudent 校验学生存在且是该大师的学生
+func (s *studentService) ensureMentorStudent(ctx context.Context, mentorID, studentID, courseID string) error {
+	if _, err := s.userRepo.GetByID(ctx, studentID); err != nil {
+		return errors.New("学生不存在")
+	}
+
+	owned, err := s.studentRepo.IsMentorStudent(ctx, mentorID, studentID, courseID)
+	if err != nil {
+		return err
+	}
+	if !owned {
+		return errors.New("无权访问该学生")
+	}
+	return nil
+}
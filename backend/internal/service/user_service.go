@@ -80,11 +80,13 @@ func (s *userService) GetUserProfile(ctx context.Context, userID string) (*model
 	// 构建响应
 	response := &model.UserProfileResponse{
 		User: &model.UserInfo{
-			ID:        user.ID,
-			Email:     user.Email,
-			Phone:     user.Phone,
-			Status:    user.Status,
-			CreatedAt: user.CreatedAt,
+			ID:            user.ID,
+			Email:         user.Email,
+			Phone:         user.Phone,
+			EmailVerified: user.EmailVerified,
+			PhoneVerified: user.PhoneVerified,
+			Status:        user.Status,
+			CreatedAt:     user.CreatedAt,
 		},
 		CurrentIdentity: &model.IdentityWithProfile{
 			ID:           currentIdentity.ID,
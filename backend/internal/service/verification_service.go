@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"master-guide-backend/internal/model"
+	"master-guide-backend/internal/repository"
+	"master-guide-backend/internal/utils"
+	"master-guide-backend/pkg/cache"
+	"master-guide-backend/pkg/config"
+	"master-guide-backend/pkg/logger"
+	"master-guide-backend/pkg/sender"
+)
+
+// 验证方式
+const (
+	VerificationChannelEmail = "email"
+	VerificationChannelPhone = "phone"
+)
+
+// 验证码用途
+const (
+	verificationPurposeContact = "contact"
+)
+
+// VerificationService 邮箱/手机号验证服务接口
+type VerificationService interface {
+	SendCode(ctx context.Context, userID, channel string) (*model.SendVerificationCodeResponse, error)
+	ConfirmCode(ctx context.Context, userID, channel, code string) error
+}
+
+// verificationService 邮箱/手机号验证服务实现
+type verificationService struct {
+	userRepo repository.UserRepository
+	sender   sender.Sender
+	config   config.VerificationConfig
+}
+
+// NewVerificationService 创建验证服务实例
+func NewVerificationService(userRepo repository.UserRepository, msgSender sender.Sender, cfg config.VerificationConfig) VerificationService {
+	if cfg.CodeLength <= 0 {
+		cfg.CodeLength = 6
+	}
+	if cfg.CodeTTL <= 0 {
+		cfg.CodeTTL = 600
+	}
+	if cfg.ResendInterval <= 0 {
+		cfg.ResendInterval = 60
+	}
+	if cfg.DailyLimit <= 0 {
+		cfg.DailyLimit = 10
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	return &verificationService{
+		userRepo: userRepo,
+		sender:   msgSender,
+		config:   cfg,
+	}
+}
+
+// SendCode 向用户的邮箱或手机号发送验证码
+func (s *verificationService) SendCode(ctx context.Context, userID, channel string) (*model.SendVerificationCodeResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, errors.New("用户不存在")
+	}
+
+	target, err := contactTarget(user, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := s.issueCode(ctx, verificationPurposeContact, channel, target)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &sender.Message{
+		To:      target,
+		Subject: "Master Guide 验证码",
+		Content: fmt.Sprintf("您的验证码为 %s，%d 分钟内有效。如非本人操作请忽略。", code, s.config.CodeTTL/60),
+	}
+	if channel == VerificationChannelEmail {
+		msg.Channel = sender.ChannelEmail
+	} else {
+		msg.Channel = sender.ChannelSMS
+	}
+	if err := s.sender.Send(ctx, msg); err != nil {
+		logger.Error("发送验证码失败", logger.String("channel", channel), logger.String("error", err.Error()))
+		_ = cache.Del(ctx, verificationCodeKey(verificationPurposeContact, channel, target))
+		return nil, errors.New("验证码发送失败")
+	}
+
+	return &model.SendVerificationCodeResponse{
+		Channel:     channel,
+		Target:      maskContact(channel, target),
+		ExpiresIn:   s.config.CodeTTL,
+		ResendAfter: s.config.ResendInterval,
+	}, nil
+}
+
+// ConfirmCode 校验验证码并标记邮箱或手机号已验证
+func (s *verificationService) ConfirmCode(ctx context.Context, userID, channel, code string) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return errors.New("用户不存在")
+	}
+
+	target, err := contactTarget(user, channel)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkCode(ctx, verificationPurposeContact, channel, target, code); err != nil {
+		return err
+	}
+
+	if channel == VerificationChannelEmail {
+		user.EmailVerified = true
+	} else {
+		user.PhoneVerified = true
+	}
+	return s.userRepo.Update(ctx, user)
+}
+
+// issueCode 生成并保存验证码，同时执行发送频率和每日次数限制
+func (s *verificationService) issueCode(ctx context.Context, purpose, channel, target string) (string, error) {
+	cooldownKey := fmt.Sprintf("verification:cooldown:%s:%s:%s", purpose, channel, target)
+	ok, err := cache.SetNX(ctx, cooldownKey, 1, time.Duration(s.config.ResendInterval)*time.Second)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", errors.New("验证码发送过于频繁，请稍后再试")
+	}
+
+	dailyKey := fmt.Sprintf("verification:daily:%s:%s:%s", channel, target, time.Now().Format("20060102"))
+	count, err := cache.Incr(ctx, dailyKey)
+	if err != nil {
+		return "", err
+	}
+	if count == 1 {
+		_ = cache.Expire(ctx, dailyKey, 24*time.Hour)
+	}
+	if count > int64(s.config.DailyLimit) {
+		return "", errors.New("今日验证码发送次数已达上限")
+	}
+
+	code, err := utils.GenerateNumericCode(s.config.CodeLength)
+	if err != nil {
+		return "", err
+	}
+
+	codeKey := verificationCodeKey(purpose, channel, target)
+	if err := cache.Set(ctx, codeKey, utils.HashToken(code), time.Duration(s.config.CodeTTL)*time.Second); err != nil {
+		return "", err
+	}
+	_ = cache.Del(ctx, verificationAttemptsKey(purpose, channel, target))
+
+	return code, nil
+}
+
+// checkCode 校验验证码，错误次数超限后验证码作废；校验成功后验证码立即失效
+func (s *verificationService) checkCode(ctx context.Context, purpose, channel, target, code string) error {
+	codeKey := verificationCodeKey(purpose, channel, target)
+	attemptsKey := verificationAttemptsKey(purpose, channel, target)
+
+	stored, err := cache.Get(ctx, codeKey)
+	if errors.Is(err, cache.Nil) {
+		return errors.New("验证码无效或已过期")
+	}
+	if err != nil {
+		return err
+	}
+
+	attempts, err := cache.Incr(ctx, attemptsKey)
+	if err != nil {
+		return err
+	}
+	if attempts == 1 {
+		_ = cache.Expire(ctx, attemptsKey, time.Duration(s.config.CodeTTL)*time.Second)
+	}
+	if attempts > int64(s.config.MaxAttempts) {
+		_ = cache.Del(ctx, codeKey, attemptsKey)
+		return errors.New("验证码错误次数过多，请重新获取")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(stored), []byte(utils.HashToken(strings.TrimSpace(code)))) != 1 {
+		return errors.New("验证码错误")
+	}
+
+	return cache.Del(ctx, codeKey, attemptsKey)
+}
+
+// contactTarget 获取待验证的邮箱或手机号
+func contactTarget(user *model.User, channel string) (string, error) {
+	switch channel {
+	case VerificationChannelEmail:
+		if user.EmailVerified {
+			return "", errors.New("邮箱已验证")
+		}
+		return user.Email, nil
+	case VerificationChannelPhone:
+		if user.Phone == "" {
+			return "", errors.New("未绑定手机号")
+		}
+		if user.PhoneVerified {
+			return "", errors.New("手机号已验证")
+		}
+		return user.Phone, nil
+	default:
+		return "", errors.New("不支持的验证方式")
+	}
+}
+
+// verificationCodeKey 验证码缓存键
+func verificationCodeKey(purpose, channel, target string) string {
+	return fmt.Sprintf("verification:code:%s:%s:%s", purpose, channel, target)
+}
+
+// verificationAttemptsKey 验证码错误次数缓存键
+func verificationAttemptsKey(purpose, channel, target string) string {
+	return fmt.Sprintf("verification:attempts:%s:%s:%s", purpose, channel, target)
+}
+
+// maskContact 对邮箱或手机号脱敏
+func maskContact(channel, target string) string {
+	if channel == VerificationChannelEmail {
+		at := strings.Index(target, "@")
+		if at <= 1 {
+			return target
+		}
+		return target[:1] + "***" + target[at:]
+	}
+	if len(target) <= 7 {
+		return target
+	}
+	return target[:3] + "****" + target[len(target)-4:]
+}
@@ -0,0 +1,362 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"master-guide-backend/internal/model"
+	"master-guide-backend/internal/repository"
+	"master-guide-backend/internal/utils"
+	"master-guide-backend/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// defaultPayoutBatchSize 未配置时每个打款批次最多包含的提现数量
+const defaultPayoutBatchSize = 200
+
+// payoutExportHeader 打款批次导出文件的列，提交银行转账使用
+var payoutExportHeader = []string{"withdrawal_id", "mentor_id", "bank_name", "bank_account", "amount", "fee", "net_amount"}
+
+// 打款结果导入文件的结果取值
+const (
+	payoutResultSuccess = "success"
+	payoutResultFailed  = "failed"
+)
+
+// WithdrawalService 提现审核与打款服务接口
+// 待审核的提现由管理员审核通过或驳回，通过的提现按批次导出给银行转账，再导入打款结果完成或退回
+type WithdrawalService interface {
+	ListWithdrawals(ctx context.Context, req *model.AdminWithdrawalsRequest) (*model.AdminWithdrawalsResponse, error)
+	ApproveWithdrawal(ctx context.Context, withdrawalID, reviewerID string) (*model.WithdrawalModel, error)
+	RejectWithdrawal(ctx context.Context, withdrawalID, reviewerID, reason string) (*model.WithdrawalModel, error)
+	CreatePayoutBatch(ctx context.Context, createdBy string, req *model.CreatePayoutBatchRequest) (*model.PayoutBatch, error)
+	ListPayoutBatches(ctx context.Context, req *model.PayoutBatchesRequest) (*model.PayoutBatchesResponse, error)
+	ExportPayoutBatch(ctx context.Context, batchID string) ([]byte, error)
+	ImportPayoutResults(ctx context.Context, batchID string, file io.Reader) (*model.PayoutImportResponse, error)
+}
+
+// withdrawalService 提现审核与打款服务实现
+type withdrawalService struct {
+	withdrawalRepo    repository.WithdrawalRepository
+	bankAccountCipher *utils.FieldCipher
+	notifier          *withdrawalNotifier
+	batchSize         int
+}
+
+// NewWithdrawalService 创建提现审核与打款服务实例，bankAccountCipher 用于导出时解密银行账号
+func NewWithdrawalService(withdrawalRepo repository.WithdrawalRepository, mentorRepo repository.MentorRepository, notificationService NotificationService, bankAccountCipher *utils.FieldCipher, batchSize int) WithdrawalService {
+	if batchSize <= 0 {
+		batchSize = defaultPayoutBatchSize
+	}
+	return &withdrawalService{
+		withdrawalRepo:    withdrawalRepo,
+		bankAccountCipher: bankAccountCipher,
+		notifier:          &withdrawalNotifier{mentorRepo: mentorRepo, notificationService: notificationService},
+		batchSize:         batchSize,
+	}
+}
+
+// ListWithdrawals 获取提现审核列表
+func (s *withdrawalService) ListWithdrawals(ctx context.Context, req *model.AdminWithdrawalsRequest) (*model.AdminWithdrawalsResponse, error) {
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+
+	withdrawals, total, err := s.withdrawalRepo.ListWithdrawals(ctx, req.Status, req.MentorID, req.Page, req.PageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.AdminWithdrawalsResponse{
+		Withdrawals: withdrawals,
+		Pagination: &model.PaginationResponse{
+			Total:      total,
+			Page:       req.Page,
+			PageSize:   req.PageSize,
+			TotalPages: int(math.Ceil(float64(total) / float64(req.PageSize))),
+		},
+	}, nil
+}
+
+// ApproveWithdrawal 审核通过提现申请
+func (s *withdrawalService) ApproveWithdrawal(ctx context.Context, withdrawalID, reviewerID string) (*model.WithdrawalModel, error) {
+	withdrawal, err := s.withdrawalRepo.ApproveWithdrawal(ctx, withdrawalID, reviewerID, time.Now())
+	if err != nil {
+		return nil, withdrawalError(err)
+	}
+
+	s.notifier.notify(ctx, withdrawal, "提现审核通过",
+		fmt.Sprintf("您申请的¥%.2f提现已审核通过，将在下一打款批次中转账", withdrawal.Amount))
+	return withdrawal, nil
+}
+
+// RejectWithdrawal 驳回提现申请，提现金额退回可提现余额
+func (s *withdrawalService) RejectWithdrawal(ctx context.Context, withdrawalID, reviewerID, reason string) (*model.WithdrawalModel, error) {
+	withdrawal, err := s.withdrawalRepo.RejectWithdrawal(ctx, withdrawalID, reviewerID, reason, time.Now())
+	if err != nil {
+		return nil, withdrawalError(err)
+	}
+
+	s.notifier.notify(ctx, withdrawal, "提现申请被驳回",
+		fmt.Sprintf("您申请的¥%.2f提现未通过审核：%s。金额已退回可提现余额", withdrawal.Amount, reason))
+	return withdrawal, nil
+}
+
+// CreatePayoutBatch 将已审核通过的提现放入新的打款批次
+func (s *withdrawalService) CreatePayoutBatch(ctx context.Context, createdBy string, req *model.CreatePayoutBatchRequest) (*model.PayoutBatch, error) {
+	batch, err := s.withdrawalRepo.CreatePayoutBatch(ctx, createdBy, req.WithdrawalIDs, s.batchSize)
+	if errors.Is(err, repository.ErrNoApprovedWithdrawals) {
+		return nil, errors.New("没有可打款的提现申请")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	withdrawals, err := s.withdrawalRepo.ListBatchWithdrawals(ctx, batch.ID)
+	if err != nil {
+		logger.Error("获取打款批次提现失败", logger.String("batch_id", batch.ID), logger.String("error", err.Error()))
+		return batch, nil
+	}
+	for _, withdrawal := range withdrawals {
+		s.notifier.notify(ctx, withdrawal, "提现打款中",
+			fmt.Sprintf("您申请的¥%.2f提现已提交银行转账，预计1-2个工作日到账", withdrawal.Amount))
+	}
+	return batch, nil
+}
+
+// ListPayoutBatches 获取打款批次列表
+func (s *withdrawalService) ListPayoutBatches(ctx context.Context, req *model.PayoutBatchesRequest) (*model.PayoutBatchesResponse, error) {
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+
+	batches, total, err := s.withdrawalRepo.ListPayoutBatches(ctx, req.Status, req.Page, req.PageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.PayoutBatchesResponse{
+		Batches: batches,
+		Pagination: &model.PaginationResponse{
+			Total:      total,
+			Page:       req.Page,
+			PageSize:   req.PageSize,
+			TotalPages: int(math.Ceil(float64(total) / float64(req.PageSize))),
+		},
+	}, nil
+}
+
+// ExportPayoutBatch 导出打款批次的银行转账文件（CSV），银行账号在导出时解密
+func (s *withdrawalService) ExportPayoutBatch(ctx context.Context, batchID string) ([]byte, error) {
+	if s.bankAccountCipher == nil {
+		return nil, errors.New("提现服务未配置")
+	}
+	if _, err := s.withdrawalRepo.GetPayoutBatch(ctx, batchID); err != nil {
+		return nil, payoutBatchError(err)
+	}
+	withdrawals, err := s.withdrawalRepo.ListBatchWithdrawals(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	// 写入 UTF-8 BOM，便于表格软件正确识别中文
+	buf.WriteString("\xEF\xBB\xBF")
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write(payoutExportHeader); err != nil {
+		return nil, err
+	}
+	for _, withdrawal := range withdrawals {
+		account, err := s.bankAccountCipher.Decrypt(withdrawal.BankAccount)
+		if err != nil {
+			return nil, fmt.Errorf("提现 %s 银行账号解密失败", withdrawal.ID)
+		}
+		if err := writer.Write([]string{
+			withdrawal.ID,
+			withdrawal.MentorID,
+			csvSafeCell(withdrawal.BankName),
+			csvSafeCell(account),
+			strconv.FormatFloat(withdrawal.Amount, 'f', 2, 64),
+			strconv.FormatFloat(withdrawal.Fee, 'f', 2, 64),
+			strconv.FormatFloat(withdrawal.NetAmount, 'f', 2, 64),
+		}); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+
+	if err := s.withdrawalRepo.MarkBatchExported(ctx, batchID, time.Now()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// csvSafeCell 中和大师填写的单元格内容，以公式字符开头时加单引号前缀，避免表格软件打开导出文件时将其作为公式执行
+func csvSafeCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// ImportPayoutResults 导入银行返回的打款结果（CSV）
+// 文件需包含 withdrawal_id、result 列，result 取 success 或 failed，可选 reference（银行流水号）和 reason（失败原因）列；
+// 每行单独处理，重复导入已处理的结果会被跳过，出错的行记录在 Errors 中
+func (s *withdrawalService) ImportPayoutResults(ctx context.Context, batchID string, file io.Reader) (*model.PayoutImportResponse, error) {
+	if _, err := s.withdrawalRepo.GetPayoutBatch(ctx, batchID); err != nil {
+		return nil, payoutBatchError(err)
+	}
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil || len(rows) == 0 {
+		return nil, errors.New("打款结果文件格式错误")
+	}
+	columns := make(map[string]int)
+	for i, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\xEF\xBB\xBF")))] = i
+	}
+	if _, ok := columns["withdrawal_id"]; !ok {
+		return nil, errors.New("打款结果文件格式错误")
+	}
+	if _, ok := columns["result"]; !ok {
+		return nil, errors.New("打款结果文件格式错误")
+	}
+	field := func(row []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	response := &model.PayoutImportResponse{Errors: []string{}}
+	for i, row := range rows[1:] {
+		line := i + 2
+		withdrawalID := field(row, "withdrawal_id")
+		if withdrawalID == "" {
+			continue
+		}
+
+		var withdrawal *model.WithdrawalModel
+		var settled bool
+		now := time.Now()
+		switch result := strings.ToLower(field(row, "result")); result {
+		case payoutResultSuccess:
+			withdrawal, settled, err = s.withdrawalRepo.CompletePayout(ctx, batchID, withdrawalID, field(row, "reference"), now)
+		case payoutResultFailed:
+			reason := field(row, "reason")
+			if reason == "" {
+				reason = "银行打款失败"
+			}
+			withdrawal, settled, err = s.withdrawalRepo.FailPayout(ctx, batchID, withdrawalID, reason, now)
+		default:
+			response.Errors = append(response.Errors, fmt.Sprintf("第%d行：打款结果 %q 无效", line, result))
+			continue
+		}
+		if err != nil {
+			response.Errors = append(response.Errors, fmt.Sprintf("第%d行：%s", line, payoutImportError(err)))
+			continue
+		}
+		if !settled {
+			response.Skipped++
+			continue
+		}
+
+		if withdrawal.Status == model.WithdrawalStatusCompleted {
+			response.Succeeded++
+			s.notifier.notify(ctx, withdrawal, "提现已到账",
+				fmt.Sprintf("您申请的¥%.2f提现已打款，扣除手续费¥%.2f后实际到账¥%.2f", withdrawal.Amount, withdrawal.Fee, withdrawal.NetAmount))
+		} else {
+			response.Failed++
+			s.notifier.notify(ctx, withdrawal, "提现打款失败",
+				fmt.Sprintf("您申请的¥%.2f提现打款失败：%s。金额已退回可提现余额，请核对银行账户后重新申请", withdrawal.Amount, *withdrawal.FailureReason))
+		}
+	}
+
+	batch, err := s.withdrawalRepo.GetPayoutBatch(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+	response.Batch = batch
+	return response, nil
+}
+
+// withdrawalError 转换提现审核的数据访问错误
+func withdrawalError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return errors.New("提现申请不存在")
+	case errors.Is(err, repository.ErrWithdrawalStatusConflict):
+		return errors.New("提现申请状态已变更")
+	default:
+		return err
+	}
+}
+
+// payoutBatchError 转换打款批次的数据访问错误
+func payoutBatchError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errors.New("打款批次不存在")
+	}
+	return err
+}
+
+// payoutImportError 导入打款结果时单行错误的说明
+func payoutImportError(err error) string {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return "提现不在该打款批次中"
+	case errors.Is(err, repository.ErrWithdrawalStatusConflict):
+		return "提现状态不是打款中，或已确认为相反的结果"
+	default:
+		return err.Error()
+	}
+}
+
+// withdrawalNotifier 向大师发送提现进度通知，发送失败只记录日志
+type withdrawalNotifier struct {
+	mentorRepo          repository.MentorRepository
+	notificationService NotificationService
+}
+
+// notify 发送提现进度通知
+func (n *withdrawalNotifier) notify(ctx context.Context, withdrawal *model.WithdrawalModel, title, content string) {
+	mentor, err := n.mentorRepo.GetMentorByID(ctx, withdrawal.MentorID)
+	if err == nil {
+		_, err = n.notificationService.SendNotification(ctx, &model.SendNotificationRequest{
+			UserIDs: []string{mentor.UserID},
+			Type:    model.NotificationTypeWithdrawal,
+			Title:   title,
+			Content: content,
+			RelatedData: map[string]interface{}{
+				"withdrawal_id": withdrawal.ID,
+				"status":        withdrawal.Status,
+				"amount":        withdrawal.Amount,
+			},
+		})
+	}
+	if err != nil {
+		logger.Error("发送提现通知失败", logger.String("withdrawal_id", withdrawal.ID), logger.String("error", err.Error()))
+	}
+}
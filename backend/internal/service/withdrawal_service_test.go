@@ -0,0 +1,26 @@
+package service
+
+import "testing"
+
+func TestCSVSafeCell(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "", want: ""},
+		{value: "招商银行", want: "招商银行"},
+		{value: "6222020200112233", want: "6222020200112233"},
+		{value: "=HYPERLINK(\"http://evil\")", want: "'=HYPERLINK(\"http://evil\")"},
+		{value: "+1+1", want: "'+1+1"},
+		{value: "-2+3", want: "'-2+3"},
+		{value: "@SUM(A1)", want: "'@SUM(A1)"},
+		{value: "\t=1", want: "'\t=1"},
+		{value: "\r=1", want: "'\r=1"},
+		{value: "银行=1", want: "银行=1"},
+	}
+	for _, tt := range tests {
+		if got := csvSafeCell(tt.value); got != tt.want {
+			t.Errorf("csvSafeCell(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
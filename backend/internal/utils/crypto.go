@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldCipher 敏感字段加密器，使用 AES-256-GCM，密文为 base64 编码的随机数与密文拼接
+type FieldCipher struct {
+	aead cipher.AEAD
+}
+
+// NewFieldCipher 根据 base64 编码的32字节密钥创建加密器
+func NewFieldCipher(encodedKey string) (*FieldCipher, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("加密密钥必须为 base64 编码的32字节")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &FieldCipher{aead: aead}, nil
+}
+
+// Encrypt 加密明文，每次加密使用新的随机数
+func (c *FieldCipher) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt 解密 Encrypt 生成的密文
+func (c *FieldCipher) Decrypt(ciphertext string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", errors.New("密文格式错误")
+	}
+	nonce, data := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, data, nil)
+	if err != nil {
+		return "", errors.New("密文解密失败")
+	}
+	return string(plaintext), nil
+}
+
+// MaskAccountNumber 脱敏账号，只保留后4位，如 **** **** 1234
+func MaskAccountNumber(account string) string {
+	account = strings.ReplaceAll(account, " ", "")
+	if utf8.RuneCountInString(account) <= 4 {
+		return "****"
+	}
+	runes := []rune(account)
+	return "**** **** " + string(runes[len(runes)-4:])
+}
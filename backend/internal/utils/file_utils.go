@@ -71,6 +71,21 @@ func (f *FileUtils) SaveUploadedFile(file *multipart.FileHeader, uploadType stri
 	return targetPath, fileURL, nil
 }
 
+// SaveFile 将生成的文件保存到 baseDir 下，目录结构与上传文件一致：baseDir/format/MM/DD，返回文件路径
+func (f *FileUtils) SaveFile(baseDir, ext string, data []byte) (string, error) {
+	now := time.Now()
+	targetDir := filepath.Join(baseDir, ext[1:], fmt.Sprintf("%02d", now.Month()), fmt.Sprintf("%02d", now.Day()))
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return "", fmt.Errorf("创建目标目录失败: %w", err)
+	}
+
+	targetPath := filepath.Join(targetDir, f.generateGUID()+ext)
+	if err := os.WriteFile(targetPath, data, 0644); err != nil {
+		return "", fmt.Errorf("写入文件失败: %w", err)
+	}
+	return targetPath, nil
+}
+
 // generateGUID 生成GUID
 func (f *FileUtils) generateGUID() string {
 	b := make([]byte, 16)
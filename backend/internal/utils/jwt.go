@@ -9,16 +9,20 @@ import (
 
 // JWTClaims JWT声明
 type JWTClaims struct {
-	UserID     string `json:"user_id"`
-	IdentityID string `json:"identity_id"`
+	UserID       string `json:"user_id"`
+	IdentityID   string `json:"identity_id"`
+	IdentityType string `json:"identity_type,omitempty"`
+	SessionID    string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
 // GenerateToken 生成JWT Token
-func GenerateToken(userID, identityID, secret string, expireHours int) (string, error) {
+func GenerateToken(userID, identityID, identityType, sessionID, secret string, expireHours int) (string, error) {
 	claims := JWTClaims{
-		UserID:     userID,
-		IdentityID: identityID,
+		UserID:       userID,
+		IdentityID:   identityID,
+		IdentityType: identityType,
+		SessionID:    sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(expireHours) * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -34,7 +38,7 @@ func GenerateToken(userID, identityID, secret string, expireHours int) (string,
 func ParseToken(tokenString, secret string) (*JWTClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
 		return []byte(secret), nil
-	})
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 
 	if err != nil {
 		return nil, err
@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"master-guide-backend/internal/model"
+)
+
+// WebSocketBackplane 在多个后端节点间分发 WebSocket 事件
+// 每个节点将需要推送的事件发布到背板，并订阅其他节点发布的事件投递给本节点的连接
+type WebSocketBackplane interface {
+	Publish(ctx context.Context, message *BackplaneMessage) error
+	Subscribe(handler func(message *BackplaneMessage)) (stop func(), err error)
+}
+
+// WebSocketPresence 多个后端节点共享的在线状态登记
+// 节点定期上报本节点已认证连接的用户作为心跳，超过有效期未上报的用户视为离线
+type WebSocketPresence interface {
+	Refresh(ctx context.Context, nodeID string, userIDs []string) error
+	Leave(ctx context.Context, nodeID, userID string) error
+	OnlineUsers(ctx context.Context) ([]*model.OnlineUser, error)
+	IsOnline(ctx context.Context, userID string) (bool, error)
+}
+
+// BackplaneMessage 背板消息，UserID 为空表示广播给所有已认证连接
+type BackplaneMessage struct {
+	NodeID string          `json:"node_id"` // 发布消息的节点，发布节点自身不再重复投递
+	UserID string          `json:"user_id,omitempty"`
+	Event  json.RawMessage `json:"event"`
+}
+
+// memoryBackplane 进程内背板，用于单节点部署和测试；同一进程内的多个管理器共享同一实例即可模拟多节点
+type memoryBackplane struct {
+	mutex    sync.RWMutex
+	handlers map[int]func(message *BackplaneMessage)
+	nextID   int
+}
+
+// NewMemoryBackplane 创建进程内背板
+func NewMemoryBackplane() WebSocketBackplane {
+	return &memoryBackplane{handlers: make(map[int]func(message *BackplaneMessage))}
+}
+
+// Publish 将消息同步交给所有订阅者
+func (b *memoryBackplane) Publish(ctx context.Context, message *BackplaneMessage) error {
+	b.mutex.RLock()
+	handlers := make([]func(message *BackplaneMessage), 0, len(b.handlers))
+	for _, handler := range b.handlers {
+		handlers = append(handlers, handler)
+	}
+	b.mutex.RUnlock()
+
+	for _, handler := range handlers {
+		handler(message)
+	}
+	return nil
+}
+
+// Subscribe 订阅背板消息，返回取消订阅函数
+func (b *memoryBackplane) Subscribe(handler func(message *BackplaneMessage)) (func(), error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = handler
+	return func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		delete(b.handlers, id)
+	}, nil
+}
+
+// memoryPresence 进程内在线状态登记，用于单节点部署和测试
+type memoryPresence struct {
+	mutex sync.Mutex
+	ttl   time.Duration
+	users map[string]map[string]time.Time // 用户ID -> 节点ID -> 最近心跳时间
+}
+
+// NewMemoryPresence 创建进程内在线状态登记，ttl 为心跳有效期
+func NewMemoryPresence(ttl time.Duration) WebSocketPresence {
+	return &memoryPresence{
+		ttl:   ttl,
+		users: make(map[string]map[string]time.Time),
+	}
+}
+
+// Refresh 记录节点上在线用户的心跳
+func (p *memoryPresence) Refresh(ctx context.Context, nodeID string, userIDs []string) error {
+	now := time.Now()
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, userID := range userIDs {
+		nodes, ok := p.users[userID]
+		if !ok {
+			nodes = make(map[string]time.Time)
+			p.users[userID] = nodes
+		}
+		nodes[nodeID] = now
+	}
+	p.expire(now)
+	return nil
+}
+
+// Leave 移除用户在节点上的在线记录
+func (p *memoryPresence) Leave(ctx context.Context, nodeID, userID string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if nodes, ok := p.users[userID]; ok {
+		delete(nodes, nodeID)
+		if len(nodes) == 0 {
+			delete(p.users, userID)
+		}
+	}
+	return nil
+}
+
+// OnlineUsers 获取心跳未过期的用户，按最近心跳时间倒序
+func (p *memoryPresence) OnlineUsers(ctx context.Context) ([]*model.OnlineUser, error) {
+	now := time.Now()
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.expire(now)
+	onlineUsers := make([]*model.OnlineUser, 0, len(p.users))
+	for userID, nodes := range p.users {
+		user := &model.OnlineUser{UserID: userID, IsOnline: true}
+		for _, lastSeen := range nodes {
+			if lastSeen.After(user.LastSeen) {
+				user.LastSeen = lastSeen
+			}
+		}
+		onlineUsers = append(onlineUsers, user)
+	}
+	sort.Slice(onlineUsers, func(i, j int) bool {
+		return onlineUsers[i].LastSeen.After(onlineUsers[j].LastSeen)
+	})
+	return onlineUsers, nil
+}
+
+// IsOnline 检查用户是否在任一节点有未过期的心跳
+func (p *memoryPresence) IsOnline(ctx context.Context, userID string) (bool, error) {
+	now := time.Now()
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, lastSeen := range p.users[userID] {
+		if now.Sub(lastSeen) < p.ttl {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// expire 清理过期的心跳记录，调用方须持有 p.mutex
+func (p *memoryPresence) expire(now time.Time) {
+	for userID, nodes := range p.users {
+		for nodeID, lastSeen := range nodes {
+			if now.Sub(lastSeen) >= p.ttl {
+				delete(nodes, nodeID)
+			}
+		}
+		if len(nodes) == 0 {
+			delete(p.users, userID)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"master-guide-backend/internal/model"
+	"master-guide-backend/pkg/config"
+)
+
+// newTestNode 创建接入共享背板和在线状态登记的管理器，模拟一个后端节点
+func newTestNode(t *testing.T, backplane WebSocketBackplane, presence WebSocketPresence) *WebSocketManager {
+	t.Helper()
+	manager := NewWebSocketManager(nil, backplane, presence, config.WebSocketConfig{})
+	stop, err := backplane.Subscribe(manager.handleBackplaneMessage)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	t.Cleanup(stop)
+	return manager
+}
+
+// connect 在节点上登记一个已认证的连接并上报在线状态
+func connect(manager *WebSocketManager, clientID, userID string) *WebSocketClient {
+	client := &WebSocketClient{
+		ID:       clientID,
+		UserID:   userID,
+		Send:     make(chan []byte, 8),
+		Manager:  manager,
+		IsOnline: true,
+		LastSeen: time.Now(),
+	}
+	manager.mutex.Lock()
+	manager.clients[client.ID] = client
+	manager.mutex.Unlock()
+	manager.refreshPresence([]string{userID})
+	return client
+}
+
+// disconnect 移除节点上的连接，用户在该节点没有其他连接时登记离开
+func disconnect(manager *WebSocketManager, client *WebSocketClient) {
+	manager.mutex.Lock()
+	delete(manager.clients, client.ID)
+	left := !manager.hasUserClient(client.UserID)
+	manager.mutex.Unlock()
+	if left {
+		manager.leavePresence(client.UserID)
+	}
+}
+
+// received 取出连接发送队列中的所有事件名称
+func received(t *testing.T, client *WebSocketClient) []string {
+	t.Helper()
+	var events []string
+	for {
+		select {
+		case message := <-client.Send:
+			var event model.WebSocketEvent
+			if err := json.Unmarshal(message, &event); err != nil {
+				t.Fatalf("unmarshal event: %v", err)
+			}
+			events = append(events, event.Event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestBackplaneSendToUserAcrossNodes(t *testing.T) {
+	backplane, presence := NewMemoryBackplane(), NewMemoryPresence(time.Minute)
+	nodeA := newTestNode(t, backplane, presence)
+	nodeB := newTestNode(t, backplane, presence)
+
+	alice := connect(nodeA, "client-a", "alice")
+	bob := connect(nodeB, "client-b", "bob")
+
+	tests := []struct {
+		name      string
+		sender    *WebSocketManager
+		userID    string
+		want      bool
+		wantAlice int
+		wantBob   int
+	}{
+		{name: "user on other node", sender: nodeA, userID: "bob", want: true, wantBob: 1},
+		{name: "user on same node", sender: nodeA, userID: "alice", want: true, wantAlice: 1},
+		{name: "user on other node from node B", sender: nodeB, userID: "alice", want: true, wantAlice: 1},
+		{name: "offline user", sender: nodeA, userID: "carol", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.sender.SendToUser(tt.userID, &model.WebSocketEvent{Event: "new_message"})
+			if got != tt.want {
+				t.Fatalf("SendToUser(%s) = %v, want %v", tt.userID, got, tt.want)
+			}
+			if events := received(t, alice); len(events) != tt.wantAlice {
+				t.Fatalf("alice received %v, want %d events", events, tt.wantAlice)
+			}
+			if events := received(t, bob); len(events) != tt.wantBob {
+				t.Fatalf("bob received %v, want %d events", events, tt.wantBob)
+			}
+		})
+	}
+}
+
+func TestBackplaneBroadcastAcrossNodes(t *testing.T) {
+	backplane, presence := NewMemoryBackplane(), NewMemoryPresence(time.Minute)
+	nodeA := newTestNode(t, backplane, presence)
+	nodeB := newTestNode(t, backplane, presence)
+
+	alice := connect(nodeA, "client-a", "alice")
+	bob := connect(nodeB, "client-b", "bob")
+	// 未认证的连接不接收广播
+	anonymous := &WebSocketClient{ID: "client-anonymous", Send: make(chan []byte, 8), Manager: nodeB}
+	nodeB.mutex.Lock()
+	nodeB.clients[anonymous.ID] = anonymous
+	nodeB.mutex.Unlock()
+
+	nodeA.broadcastEvent(&model.WebSocketEvent{Event: "announcement"})
+
+	for _, tt := range []struct {
+		client *WebSocketClient
+		want   int
+	}{{alice, 1}, {bob, 1}, {anonymous, 0}} {
+		if events := received(t, tt.client); len(events) != tt.want {
+			t.Fatalf("%s received %v, want %d events", tt.client.ID, events, tt.want)
+		}
+	}
+}
+
+func TestPresenceAcrossNodes(t *testing.T) {
+	ctx := context.Background()
+	backplane, presence := NewMemoryBackplane(), NewMemoryPresence(time.Minute)
+	nodeA := newTestNode(t, backplane, presence)
+	nodeB := newTestNode(t, backplane, presence)
+
+	onA := connect(nodeA, "client-a", "alice")
+	onB := connect(nodeB, "client-b", "alice")
+	connect(nodeB, "client-c", "bob")
+
+	if !nodeA.IsUserOnline(ctx, "bob") {
+		t.Fatalf("bob connected to node B is offline on node A")
+	}
+	if users := nodeA.GetOnlineUsers(ctx); len(users) != 2 {
+		t.Fatalf("GetOnlineUsers on node A = %d users, want 2", len(users))
+	}
+
+	// 用户在其他节点仍有连接时保持在线
+	disconnect(nodeA, onA)
+	if !nodeA.IsUserOnline(ctx, "alice") {
+		t.Fatalf("alice still connected to node B is offline")
+	}
+	disconnect(nodeB, onB)
+	if nodeA.IsUserOnline(ctx, "alice") || nodeB.IsUserOnline(ctx, "alice") {
+		t.Fatalf("alice disconnected from all nodes is still online")
+	}
+	if users := nodeA.GetOnlineUsers(ctx); len(users) != 1 || users[0].UserID != "bob" {
+		t.Fatalf("GetOnlineUsers after alice left = %+v, want [bob]", users)
+	}
+}
+
+func TestMemoryPresenceExpiresStoppedNode(t *testing.T) {
+	ctx := context.Background()
+	ttl := 50 * time.Millisecond
+	presence := NewMemoryPresence(ttl)
+
+	if err := presence.Refresh(ctx, "node-a", []string{"alice"}); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if err := presence.Refresh(ctx, "node-b", []string{"bob"}); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	// node-a 停止心跳，node-b 继续上报
+	time.Sleep(ttl / 2)
+	if err := presence.Refresh(ctx, "node-b", []string{"bob"}); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	time.Sleep(ttl/2 + 10*time.Millisecond)
+
+	if online, _ := presence.IsOnline(ctx, "alice"); online {
+		t.Fatalf("alice on stopped node is still online")
+	}
+	if online, _ := presence.IsOnline(ctx, "bob"); !online {
+		t.Fatalf("bob on live node is offline")
+	}
+	if users, _ := presence.OnlineUsers(ctx); len(users) != 1 || users[0].UserID != "bob" {
+		t.Fatalf("OnlineUsers = %+v, want [bob]", users)
+	}
+}
@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"master-guide-backend/internal/model"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// redisBackplaneChannel 背板消息的 Redis 发布订阅频道
+	redisBackplaneChannel = "ws:events"
+	// redisPresenceUsersKey 在线用户有序集合，成员为用户ID，分值为最近心跳的 Unix 时间
+	redisPresenceUsersKey = "ws:presence:users"
+	// redisPresenceUserKeyPrefix 用户在线节点哈希的键前缀，字段为节点ID，值为该节点最近心跳的 Unix 时间
+	redisPresenceUserKeyPrefix = "ws:presence:user:"
+)
+
+// redisBackplane 基于 Redis 发布订阅的背板，用于多节点部署
+type redisBackplane struct {
+	client *redis.Client
+}
+
+// NewRedisBackplane 创建 Redis 背板，client 为 pkg/cache 管理的 Redis 连接
+func NewRedisBackplane(client *redis.Client) WebSocketBackplane {
+	return &redisBackplane{client: client}
+}
+
+// Publish 发布消息到背板频道
+func (b *redisBackplane) Publish(ctx context.Context, message *BackplaneMessage) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, redisBackplaneChannel, payload).Err()
+}
+
+// Subscribe 订阅背板频道，连接断开后由客户端自动重连重新订阅；返回取消订阅函数
+func (b *redisBackplane) Subscribe(handler func(message *BackplaneMessage)) (func(), error) {
+	ctx := context.Background()
+	pubsub := b.client.Subscribe(ctx, redisBackplaneChannel)
+	// 等待订阅确认，确保返回后发布的消息都能收到
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	go func() {
+		for msg := range pubsub.Channel() {
+			var message BackplaneMessage
+			if err := json.Unmarshal([]byte(msg.Payload), &message); err != nil {
+				log.Printf("Error unmarshaling backplane message: %v", err)
+				continue
+			}
+			handler(&message)
+		}
+	}()
+	return func() { pubsub.Close() }, nil
+}
+
+// redisPresence 基于 Redis 的在线状态登记，用于多节点部署
+// 在线用户有序集合用于列出在线用户，用户节点哈希记录用户在哪些节点上有连接；节点停止心跳后其记录在有效期后失效
+type redisPresence struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// NewRedisPresence 创建 Redis 在线状态登记，client 为 pkg/cache 管理的 Redis 连接，ttl 为心跳有效期
+func NewRedisPresence(client *redis.Client, ttl time.Duration) WebSocketPresence {
+	return &redisPresence{client: client, ttl: ttl}
+}
+
+// Refresh 记录节点上在线用户的心跳，并清理心跳已过期的用户
+func (p *redisPresence) Refresh(ctx context.Context, nodeID string, userIDs []string) error {
+	now := time.Now()
+	_, err := p.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, userID := range userIDs {
+			key := redisPresenceUserKeyPrefix + userID
+			pipe.HSet(ctx, key, nodeID, now.Unix())
+			pipe.Expire(ctx, key, p.ttl)
+			pipe.ZAdd(ctx, redisPresenceUsersKey, &redis.Z{Score: float64(now.Unix()), Member: userID})
+		}
+		pipe.ZRemRangeByScore(ctx, redisPresenceUsersKey, "-inf", "("+p.cutoff(now))
+		return nil
+	})
+	return err
+}
+
+// Leave 移除用户在节点上的在线记录，用户在其他节点也没有未过期的心跳时从在线用户中移除
+func (p *redisPresence) Leave(ctx context.Context, nodeID, userID string) error {
+	key := redisPresenceUserKeyPrefix + userID
+	if err := p.client.HDel(ctx, key, nodeID).Err(); err != nil {
+		return err
+	}
+
+	nodes, err := p.client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().Add(-p.ttl).Unix()
+	for _, value := range nodes {
+		if lastSeen, err := strconv.ParseInt(value, 10, 64); err == nil && lastSeen >= cutoff {
+			return nil
+		}
+	}
+	return p.client.ZRem(ctx, redisPresenceUsersKey, userID).Err()
+}
+
+// OnlineUsers 获取心跳未过期的用户，按最近心跳时间倒序
+func (p *redisPresence) OnlineUsers(ctx context.Context) ([]*model.OnlineUser, error) {
+	members, err := p.client.ZRevRangeByScoreWithScores(ctx, redisPresenceUsersKey, &redis.ZRangeBy{
+		Min: p.cutoff(time.Now()),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	onlineUsers := make([]*model.OnlineUser, 0, len(members))
+	for _, member := range members {
+		userID, ok := member.Member.(string)
+		if !ok {
+			continue
+		}
+		onlineUsers = append(onlineUsers, &model.OnlineUser{
+			UserID:   userID,
+			IsOnline: true,
+			LastSeen: time.Unix(int64(member.Score), 0),
+		})
+	}
+	return onlineUsers, nil
+}
+
+// IsOnline 检查用户是否在任一节点有未过期的心跳
+func (p *redisPresence) IsOnline(ctx context.Context, userID string) (bool, error) {
+	score, err := p.client.ZScore(ctx, redisPresenceUsersKey, userID).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return int64(score) >= time.Now().Add(-p.ttl).Unix(), nil
+}
+
+// cutoff 心跳有效期的起点，早于该时间的心跳已过期
+func (p *redisPresence) cutoff(now time.Time) string {
+	return strconv.FormatInt(now.Add(-p.ttl).Unix(), 10)
+}
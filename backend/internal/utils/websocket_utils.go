@@ -1,63 +1,173 @@
 package utils
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
+	"os"
 	"sync"
 	"time"
 
 	"master-guide-backend/internal/model"
+	"master-guide-backend/pkg/config"
 
 	"github.com/gorilla/websocket"
 )
 
+// WebSocketAuthenticator 校验 WebSocket 连接携带的访问令牌，并在连接期间复查登录会话是否仍有效
+type WebSocketAuthenticator interface {
+	AuthenticateToken(ctx context.Context, token string) (*JWTClaims, error)
+	IsSessionActive(ctx context.Context, sessionID string) (bool, error)
+}
+
+// WebSocketEventHandler WebSocket 业务事件处理函数，userID 为发送事件的已认证用户
+// 返回的事件作为回复发送给发起连接，返回 nil 时不回复；返回错误时向发起连接发送 error 事件
+// 错误为 *WebSocketError 时按其错误码和消息回复，其他错误只记录日志，回复通用的服务器错误
+type WebSocketEventHandler func(ctx context.Context, userID string, data json.RawMessage) (*model.WebSocketEvent, error)
+
+// WebSocketError 可告知客户端的业务错误，Code 沿用 HTTP 状态码
+type WebSocketError struct {
+	Code    int
+	Message string
+}
+
+// NewWebSocketError 创建可告知客户端的业务错误
+func NewWebSocketError(code int, message string) *WebSocketError {
+	return &WebSocketError{Code: code, Message: message}
+}
+
+func (e *WebSocketError) Error() string {
+	return e.Message
+}
+
 // WebSocketManager WebSocket 连接管理器
+// 连接可在握手时携带令牌完成认证，也可在连接后发送 authenticate 事件认证；未认证的连接不接收推送，超时后关闭
+// 推送给用户和广播的事件经背板分发到其他节点，在线状态通过心跳登记到共享的在线状态登记中
+// 每次心跳复查已认证连接的登录会话，退出登录或会话被撤销后关闭连接
 type WebSocketManager struct {
-	clients    map[string]*WebSocketClient
-	broadcast  chan *model.WebSocketEvent
-	register   chan *WebSocketClient
-	unregister chan *WebSocketClient
-	mutex      sync.RWMutex
+	nodeID        string
+	clients       map[string]*WebSocketClient
+	broadcast     chan *model.WebSocketEvent
+	register      chan *WebSocketClient
+	unregister    chan *WebSocketClient
+	mutex         sync.RWMutex
+	authenticator WebSocketAuthenticator
+	handlers      map[string]WebSocketEventHandler
+	backplane     WebSocketBackplane
+	presence      WebSocketPresence
+
+	maxMessageSize int64
+	pongWait       time.Duration
+	pingPeriod     time.Duration
+	writeWait      time.Duration
+	authTimeout    time.Duration
+	heartbeat      time.Duration
 }
 
 // WebSocketClient WebSocket 客户端连接
 type WebSocketClient struct {
-	ID       string
-	UserID   string
-	Conn     *websocket.Conn
-	Send     chan []byte
-	Manager  *WebSocketManager
-	IsOnline bool
-	LastSeen time.Time
+	ID           string
+	UserID       string
+	IdentityID   string
+	IdentityType string
+	SessionID    string // 访问令牌所属的登录会话
+	Conn         *websocket.Conn
+	Send         chan []byte
+	Manager      *WebSocketManager
+	IsOnline     bool // 是否已认证
+	LastSeen     time.Time
+	expiresAt    time.Time // 访问令牌过期时间，过期后关闭连接
 }
 
-// NewWebSocketManager 创建 WebSocket 管理器
-func NewWebSocketManager() *WebSocketManager {
+// NewWebSocketManager 创建 WebSocket 管理器，未配置的时间参数使用默认值
+// backplane 和 presence 为空时使用进程内实现，仅支持单节点部署
+func NewWebSocketManager(authenticator WebSocketAuthenticator, backplane WebSocketBackplane, presence WebSocketPresence, cfg config.WebSocketConfig) *WebSocketManager {
+	seconds := func(value, fallback int) time.Duration {
+		if value <= 0 {
+			value = fallback
+		}
+		return time.Duration(value) * time.Second
+	}
+	maxMessageSize := int64(cfg.MaxMessageSize)
+	if maxMessageSize <= 0 {
+		maxMessageSize = 512
+	}
+
+	if backplane == nil {
+		backplane = NewMemoryBackplane()
+	}
+	if presence == nil {
+		presence = NewMemoryPresence(seconds(cfg.PresenceTTL, 45))
+	}
+
 	return &WebSocketManager{
-		clients:    make(map[string]*WebSocketClient),
-		broadcast:  make(chan *model.WebSocketEvent),
-		register:   make(chan *WebSocketClient),
-		unregister: make(chan *WebSocketClient),
+		nodeID:         newNodeID(),
+		clients:        make(map[string]*WebSocketClient),
+		broadcast:      make(chan *model.WebSocketEvent),
+		register:       make(chan *WebSocketClient),
+		unregister:     make(chan *WebSocketClient),
+		authenticator:  authenticator,
+		handlers:       make(map[string]WebSocketEventHandler),
+		backplane:      backplane,
+		presence:       presence,
+		maxMessageSize: maxMessageSize,
+		pongWait:       seconds(cfg.PongWait, 60),
+		pingPeriod:     seconds(cfg.PingPeriod, 54),
+		writeWait:      seconds(cfg.WriteWait, 10),
+		authTimeout:    seconds(cfg.AuthTimeout, 10),
+		heartbeat:      seconds(cfg.HeartbeatInterval, 15),
+	}
+}
+
+// newNodeID 生成本节点标识，由主机名和随机后缀组成，同一主机上的多个进程也不会重复
+func newNodeID() string {
+	hostname, _ := os.Hostname()
+	suffix, err := GenerateRandomToken(6)
+	if err != nil {
+		suffix = time.Now().Format("150405.000000")
 	}
+	return hostname + "-" + suffix
 }
 
-// Start 启动 WebSocket 管理器
+// Start 启动 WebSocket 管理器，订阅背板并定期上报在线状态心跳
 func (manager *WebSocketManager) Start() {
+	stop, err := manager.backplane.Subscribe(manager.handleBackplaneMessage)
+	if err != nil {
+		log.Printf("WebSocket backplane subscribe failed: %v", err)
+	} else {
+		defer stop()
+	}
+	go manager.heartbeatPresence()
+
 	for {
 		select {
 		case client := <-manager.register:
 			manager.mutex.Lock()
 			manager.clients[client.ID] = client
+			authenticated := client.IsOnline
 			manager.mutex.Unlock()
+			if !authenticated {
+				time.AfterFunc(manager.authTimeout, func() {
+					manager.closeIfUnauthenticated(client)
+				})
+			}
 			log.Printf("Client registered: %s", client.ID)
 
 		case client := <-manager.unregister:
 			manager.mutex.Lock()
+			left := ""
 			if _, ok := manager.clients[client.ID]; ok {
 				delete(manager.clients, client.ID)
 				close(client.Send)
+				if client.UserID != "" && !manager.hasUserClient(client.UserID) {
+					left = client.UserID
+				}
 			}
 			manager.mutex.Unlock()
+			if left != "" {
+				go manager.leavePresence(left)
+			}
 			log.Printf("Client unregistered: %s", client.ID)
 
 		case event := <-manager.broadcast:
@@ -76,87 +186,325 @@ func (manager *WebSocketManager) UnregisterClient(client *WebSocketClient) {
 	manager.unregister <- client
 }
 
+// AuthenticateToken 校验访问令牌
+func (manager *WebSocketManager) AuthenticateToken(ctx context.Context, token string) (*JWTClaims, error) {
+	if token == "" {
+		return nil, errors.New("未提供认证令牌")
+	}
+	if manager.authenticator == nil {
+		return nil, errors.New("认证服务不可用")
+	}
+	return manager.authenticator.AuthenticateToken(ctx, token)
+}
+
+// BindClient 将认证通过的用户和身份绑定到客户端连接
+func (manager *WebSocketManager) BindClient(client *WebSocketClient, claims *JWTClaims) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	client.UserID = claims.UserID
+	client.IdentityID = claims.IdentityID
+	client.IdentityType = claims.IdentityType
+	client.SessionID = claims.SessionID
+	client.IsOnline = true
+	client.LastSeen = time.Now()
+	client.expiresAt = time.Time{}
+	if claims.ExpiresAt != nil {
+		client.expiresAt = claims.ExpiresAt.Time
+	}
+
+	// 立即登记在线状态，不等待下一次心跳
+	go manager.refreshPresence([]string{claims.UserID})
+}
+
+// HandleEvent 注册业务事件处理函数，同名事件后注册的覆盖先注册的
+func (manager *WebSocketManager) HandleEvent(name string, handler WebSocketEventHandler) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	manager.handlers[name] = handler
+}
+
 // BroadcastEvent 广播事件
 func (manager *WebSocketManager) BroadcastEvent(event *model.WebSocketEvent) {
 	manager.broadcast <- event
 }
 
-// SendToUser 发送消息给指定用户
-func (manager *WebSocketManager) SendToUser(userID string, event *model.WebSocketEvent) {
+// SendToUser 发送消息给指定用户在所有节点上的已认证连接，返回是否投递到本节点的连接或用户在其他节点在线
+func (manager *WebSocketManager) SendToUser(userID string, event *model.WebSocketEvent) bool {
+	message, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error marshaling event: %v", err)
+		return false
+	}
+
+	delivered := manager.deliverToUser(userID, message)
+	manager.publish(&BackplaneMessage{UserID: userID, Event: message})
+	if delivered {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), manager.writeWait)
+	defer cancel()
+	online, err := manager.presence.IsOnline(ctx, userID)
+	if err != nil {
+		log.Printf("WebSocket presence lookup failed: %v", err)
+		return false
+	}
+	return online
+}
+
+// GetOnlineUsers 获取所有节点的在线用户列表，在线状态登记不可用时只返回本节点的在线用户
+func (manager *WebSocketManager) GetOnlineUsers(ctx context.Context) []*model.OnlineUser {
+	onlineUsers, err := manager.presence.OnlineUsers(ctx)
+	if err == nil {
+		return onlineUsers
+	}
+	log.Printf("WebSocket presence lookup failed: %v", err)
+	return manager.localOnlineUsers()
+}
+
+// localOnlineUsers 获取本节点的在线用户列表，同一用户的多个连接只返回一次
+func (manager *WebSocketManager) localOnlineUsers() []*model.OnlineUser {
 	manager.mutex.RLock()
 	defer manager.mutex.RUnlock()
 
+	var onlineUsers []*model.OnlineUser
+	seen := make(map[string]*model.OnlineUser)
 	for _, client := range manager.clients {
-		if client.UserID == userID && client.IsOnline {
-			message, err := json.Marshal(event)
-			if err != nil {
-				log.Printf("Error marshaling event: %v", err)
-				continue
-			}
-			select {
-			case client.Send <- message:
-			default:
-				close(client.Send)
-				delete(manager.clients, client.ID)
+		if !client.IsOnline {
+			continue
+		}
+		if user, ok := seen[client.UserID]; ok {
+			if client.LastSeen.After(user.LastSeen) {
+				user.LastSeen = client.LastSeen
 			}
+			continue
 		}
+		user := &model.OnlineUser{
+			UserID:   client.UserID,
+			IsOnline: client.IsOnline,
+			LastSeen: client.LastSeen,
+		}
+		seen[client.UserID] = user
+		onlineUsers = append(onlineUsers, user)
+	}
+	return onlineUsers
+}
+
+// IsUserOnline 检查用户是否在任一节点在线
+func (manager *WebSocketManager) IsUserOnline(ctx context.Context, userID string) bool {
+	manager.mutex.RLock()
+	local := manager.hasUserClient(userID)
+	manager.mutex.RUnlock()
+	if local {
+		return true
+	}
+
+	online, err := manager.presence.IsOnline(ctx, userID)
+	if err != nil {
+		log.Printf("WebSocket presence lookup failed: %v", err)
+		return false
+	}
+	return online
+}
+
+// broadcastEvent 广播事件到所有节点上已认证的客户端
+func (manager *WebSocketManager) broadcastEvent(event *model.WebSocketEvent) {
+	message, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error marshaling event: %v", err)
+		return
 	}
+
+	manager.deliverToAll(message)
+	manager.publish(&BackplaneMessage{Event: message})
 }
 
-// GetOnlineUsers 获取在线用户列表
-func (manager *WebSocketManager) GetOnlineUsers() []*model.OnlineUser {
+// deliverToUser 投递消息给本节点上指定用户的已认证连接，返回是否至少投递到一个连接
+func (manager *WebSocketManager) deliverToUser(userID string, message []byte) bool {
 	manager.mutex.RLock()
 	defer manager.mutex.RUnlock()
 
-	var onlineUsers []*model.OnlineUser
+	delivered := false
 	for _, client := range manager.clients {
-		if client.IsOnline {
-			onlineUsers = append(onlineUsers, &model.OnlineUser{
-				UserID:   client.UserID,
-				IsOnline: client.IsOnline,
-				LastSeen: client.LastSeen,
-			})
+		if client.UserID == userID && client.IsOnline && manager.deliver(client, message) {
+			delivered = true
 		}
 	}
-	return onlineUsers
+	return delivered
 }
 
-// IsUserOnline 检查用户是否在线
-func (manager *WebSocketManager) IsUserOnline(userID string) bool {
+// deliverToAll 投递消息给本节点上所有已认证的连接
+func (manager *WebSocketManager) deliverToAll(message []byte) {
 	manager.mutex.RLock()
 	defer manager.mutex.RUnlock()
 
 	for _, client := range manager.clients {
-		if client.UserID == userID && client.IsOnline {
-			return true
+		if client.IsOnline {
+			manager.deliver(client, message)
 		}
 	}
-	return false
 }
 
-// broadcastEvent 广播事件到所有客户端
-func (manager *WebSocketManager) broadcastEvent(event *model.WebSocketEvent) {
-	manager.mutex.RLock()
-	defer manager.mutex.RUnlock()
+// publish 将事件发布到背板，由其他节点投递给各自的连接
+func (manager *WebSocketManager) publish(message *BackplaneMessage) {
+	message.NodeID = manager.nodeID
+	ctx, cancel := context.WithTimeout(context.Background(), manager.writeWait)
+	defer cancel()
+	if err := manager.backplane.Publish(ctx, message); err != nil {
+		log.Printf("WebSocket backplane publish failed: %v", err)
+	}
+}
 
-	message, err := json.Marshal(event)
-	if err != nil {
-		log.Printf("Error marshaling event: %v", err)
+// handleBackplaneMessage 投递其他节点经背板发布的事件，本节点发布的事件已在发布时投递
+func (manager *WebSocketManager) handleBackplaneMessage(message *BackplaneMessage) {
+	if message.NodeID == manager.nodeID {
+		return
+	}
+	if message.UserID != "" {
+		manager.deliverToUser(message.UserID, message.Event)
+		return
+	}
+	manager.deliverToAll(message.Event)
+}
+
+// heartbeatPresence 定期复查登录会话并上报本节点在线用户的心跳
+func (manager *WebSocketManager) heartbeatPresence() {
+	ticker := time.NewTicker(manager.heartbeat)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		manager.closeRevokedSessions()
+
+		manager.mutex.RLock()
+		seen := make(map[string]bool)
+		var userIDs []string
+		for _, client := range manager.clients {
+			if client.IsOnline && !seen[client.UserID] {
+				seen[client.UserID] = true
+				userIDs = append(userIDs, client.UserID)
+			}
+		}
+		manager.mutex.RUnlock()
+
+		if len(userIDs) > 0 {
+			manager.refreshPresence(userIDs)
+		}
+	}
+}
+
+// closeRevokedSessions 复查本节点已认证连接的登录会话，关闭已退出登录或被撤销会话的连接
+// 会话状态查询失败时保留连接，等待下次心跳重试
+func (manager *WebSocketManager) closeRevokedSessions() {
+	if manager.authenticator == nil {
 		return
 	}
 
+	manager.mutex.RLock()
+	sessions := make(map[string][]*WebSocketClient)
 	for _, client := range manager.clients {
-		if client.IsOnline {
-			select {
-			case client.Send <- message:
-			default:
-				close(client.Send)
-				delete(manager.clients, client.ID)
+		if client.IsOnline && client.SessionID != "" {
+			sessions[client.SessionID] = append(sessions[client.SessionID], client)
+		}
+	}
+	manager.mutex.RUnlock()
+
+	for sessionID, clients := range sessions {
+		ctx, cancel := context.WithTimeout(context.Background(), manager.writeWait)
+		active, err := manager.authenticator.IsSessionActive(ctx, sessionID)
+		cancel()
+		if err != nil {
+			log.Printf("WebSocket session check failed: %v", err)
+			continue
+		}
+		if active {
+			continue
+		}
+
+		var revoked []*WebSocketClient
+		manager.mutex.Lock()
+		for _, client := range clients {
+			// 查询期间已用其他会话的令牌续期的连接不关闭
+			if client.SessionID == sessionID {
+				client.IsOnline = false
+				revoked = append(revoked, client)
 			}
 		}
+		manager.mutex.Unlock()
+		for _, client := range revoked {
+			client.closeWithReason(websocket.ClosePolicyViolation, "登录会话已失效")
+		}
+	}
+}
+
+// refreshPresence 登记用户在本节点在线
+func (manager *WebSocketManager) refreshPresence(userIDs []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), manager.writeWait)
+	defer cancel()
+	if err := manager.presence.Refresh(ctx, manager.nodeID, userIDs); err != nil {
+		log.Printf("WebSocket presence refresh failed: %v", err)
 	}
 }
 
+// leavePresence 移除用户在本节点的在线记录
+func (manager *WebSocketManager) leavePresence(userID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), manager.writeWait)
+	defer cancel()
+	if err := manager.presence.Leave(ctx, manager.nodeID, userID); err != nil {
+		log.Printf("WebSocket presence leave failed: %v", err)
+	}
+}
+
+// hasUserClient 检查本节点是否有该用户的已认证连接，调用方须持有 manager.mutex
+func (manager *WebSocketManager) hasUserClient(userID string) bool {
+	for _, client := range manager.clients {
+		if client.UserID == userID && client.IsOnline {
+			return true
+		}
+	}
+	return false
+}
+
+// deliver 将消息放入客户端发送队列，队列已满时断开该连接，由读协程退出后注销
+// 调用方须持有 manager.mutex
+func (manager *WebSocketManager) deliver(client *WebSocketClient, message []byte) bool {
+	select {
+	case client.Send <- message:
+		return true
+	default:
+		log.Printf("Client send buffer full, closing: %s", client.ID)
+		client.Conn.Close()
+		return false
+	}
+}
+
+// eventHandler 获取业务事件处理函数
+func (manager *WebSocketManager) eventHandler(name string) (WebSocketEventHandler, bool) {
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+	handler, ok := manager.handlers[name]
+	return handler, ok
+}
+
+// closeIfUnauthenticated 关闭超时仍未认证的连接
+func (manager *WebSocketManager) closeIfUnauthenticated(client *WebSocketClient) {
+	manager.mutex.RLock()
+	_, registered := manager.clients[client.ID]
+	authenticated := client.IsOnline
+	manager.mutex.RUnlock()
+
+	if registered && !authenticated {
+		client.closeWithReason(websocket.ClosePolicyViolation, "认证超时")
+	}
+}
+
+// isAuthenticated 检查连接是否已认证且令牌未过期
+func (manager *WebSocketManager) isAuthenticated(client *WebSocketClient) bool {
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+	return client.IsOnline && (client.expiresAt.IsZero() || time.Now().Before(client.expiresAt))
+}
+
 // ReadPump 读取客户端消息
 func (c *WebSocketClient) ReadPump() {
 	defer func() {
@@ -164,10 +512,10 @@ func (c *WebSocketClient) ReadPump() {
 		c.Conn.Close()
 	}()
 
-	c.Conn.SetReadLimit(512)
-	c.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	c.Conn.SetReadLimit(c.Manager.maxMessageSize)
+	c.Conn.SetReadDeadline(time.Now().Add(c.Manager.pongWait))
 	c.Conn.SetPongHandler(func(string) error {
-		c.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		c.Conn.SetReadDeadline(time.Now().Add(c.Manager.pongWait))
 		return nil
 	})
 
@@ -185,9 +533,9 @@ func (c *WebSocketClient) ReadPump() {
 	}
 }
 
-// WritePump 向客户端发送消息
+// WritePump 向客户端发送消息，访问令牌过期后关闭连接
 func (c *WebSocketClient) WritePump() {
-	ticker := time.NewTicker(54 * time.Second)
+	ticker := time.NewTicker(c.Manager.pingPeriod)
 	defer func() {
 		ticker.Stop()
 		c.Conn.Close()
@@ -196,7 +544,7 @@ func (c *WebSocketClient) WritePump() {
 	for {
 		select {
 		case message, ok := <-c.Send:
-			c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			c.Conn.SetWriteDeadline(time.Now().Add(c.Manager.writeWait))
 			if !ok {
 				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
 				return
@@ -212,7 +560,11 @@ func (c *WebSocketClient) WritePump() {
 				return
 			}
 		case <-ticker.C:
-			c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if !c.Manager.isAuthenticated(c) && c.isBound() {
+				c.closeWithReason(websocket.ClosePolicyViolation, "认证令牌已过期")
+				return
+			}
+			c.Conn.SetWriteDeadline(time.Now().Add(c.Manager.writeWait))
 			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
@@ -220,52 +572,138 @@ func (c *WebSocketClient) WritePump() {
 	}
 }
 
-// handleMessage 处理接收到的消息
+// handleMessage 处理接收到的消息，未认证的连接只能发送 authenticate 事件
+// 业务事件交给注册的处理函数，处理函数的回复和错误事件带回请求事件的 ID
 func (c *WebSocketClient) handleMessage(message []byte) {
-	var event model.WebSocketEvent
+	var event struct {
+		Event string          `json:"event"`
+		ID    string          `json:"id"`
+		Data  json.RawMessage `json:"data"`
+	}
 	if err := json.Unmarshal(message, &event); err != nil {
 		log.Printf("Error unmarshaling message: %v", err)
+		c.sendError("", "", 400, "消息格式错误")
 		return
 	}
 
-	switch event.Event {
-	case "authenticate":
+	if event.Event == "authenticate" {
 		c.handleAuthenticate(event.Data)
-	case "message":
-		c.handleChatMessage(event.Data)
-	default:
+		return
+	}
+	if !c.Manager.isAuthenticated(c) {
+		c.sendError(event.Event, event.ID, 401, "连接未认证")
+		return
+	}
+
+	handler, ok := c.Manager.eventHandler(event.Event)
+	if !ok {
 		log.Printf("Unknown event type: %s", event.Event)
+		c.sendError(event.Event, event.ID, 400, "不支持的事件")
+		return
 	}
-}
 
-// handleAuthenticate 处理认证消息
-func (c *WebSocketClient) handleAuthenticate(data interface{}) {
-	// 这里应该验证 JWT token
-	// 简化处理，实际应该调用认证服务
-	c.IsOnline = true
-	c.LastSeen = time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), c.Manager.writeWait)
+	defer cancel()
+	reply, err := handler(ctx, c.boundUserID(), event.Data)
+	if err != nil {
+		var eventErr *WebSocketError
+		if errors.As(err, &eventErr) {
+			c.sendError(event.Event, event.ID, eventErr.Code, eventErr.Message)
+			return
+		}
+		// 未知错误可能包含数据库等内部信息，不返回给客户端
+		log.Printf("Error handling event %s: %v", event.Event, err)
+		c.sendError(event.Event, event.ID, 500, "服务器内部错误")
+		return
+	}
+	if reply != nil {
+		reply.ID = event.ID
+		c.send(reply)
+	}
+}
 
-	response := model.WebSocketEvent{
-		Event: "authenticate",
-		Data: model.AuthenticateResponse{
-			Success: true,
-			Message: "认证成功",
-			UserID:  c.UserID,
-		},
+// handleAuthenticate 校验 authenticate 事件携带的访问令牌并绑定用户；已认证的连接可用同一用户的新令牌续期
+// 未认证的连接认证失败后关闭
+func (c *WebSocketClient) handleAuthenticate(data json.RawMessage) {
+	var req model.AuthenticateRequest
+	json.Unmarshal(data, &req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.Manager.writeWait)
+	defer cancel()
+	claims, err := c.Manager.AuthenticateToken(ctx, req.Token)
+	if err == nil && c.isBound() && claims.UserID != c.boundUserID() {
+		err = errors.New("令牌与当前连接的用户不一致")
+	}
+	if err != nil {
+		c.sendEvent("authenticate", model.AuthenticateResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		if !c.isBound() {
+			c.closeWithReason(websocket.ClosePolicyViolation, err.Error())
+		}
+		return
 	}
 
-	message, _ := json.Marshal(response)
-	c.Send <- message
+	c.Manager.BindClient(c, claims)
+	c.sendEvent("authenticate", model.AuthenticateResponse{
+		Success: true,
+		Message: "认证成功",
+		UserID:  claims.UserID,
+	})
+}
+
+// AnnounceAuthenticated 通知握手时已认证的连接认证结果
+func (c *WebSocketClient) AnnounceAuthenticated() {
+	c.sendEvent("authenticate", model.AuthenticateResponse{
+		Success: true,
+		Message: "认证成功",
+		UserID:  c.boundUserID(),
+	})
+}
+
+// sendEvent 向本连接发送事件，发送队列已满时丢弃
+func (c *WebSocketClient) sendEvent(name string, data interface{}) {
+	c.send(&model.WebSocketEvent{Event: name, Data: data})
+}
+
+// sendError 向本连接发送 error 事件，event 和 id 为出错的请求事件
+func (c *WebSocketClient) sendError(event, id string, code int, message string) {
+	c.send(&model.WebSocketEvent{
+		Event: "error",
+		ID:    id,
+		Data:  model.ErrorEvent{Code: code, Message: message, Event: event},
+	})
 }
 
-// handleChatMessage 处理聊天消息
-func (c *WebSocketClient) handleChatMessage(data interface{}) {
-	// 这里应该保存消息到数据库并广播给其他用户
-	// 简化处理，直接广播
-	event := model.WebSocketEvent{
-		Event: "message",
-		Data:  data,
+// send 向本连接发送事件，发送队列已满时丢弃
+func (c *WebSocketClient) send(event *model.WebSocketEvent) {
+	message, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error marshaling event: %v", err)
+		return
+	}
+	select {
+	case c.Send <- message:
+	default:
 	}
+}
+
+// isBound 检查连接是否曾认证成功
+func (c *WebSocketClient) isBound() bool {
+	return c.boundUserID() != ""
+}
+
+// boundUserID 获取连接绑定的用户ID
+func (c *WebSocketClient) boundUserID() string {
+	c.Manager.mutex.RLock()
+	defer c.Manager.mutex.RUnlock()
+	return c.UserID
+}
 
-	c.Manager.BroadcastEvent(&event)
+// closeWithReason 发送关闭帧后断开连接，读协程退出后注销客户端
+func (c *WebSocketClient) closeWithReason(code int, reason string) {
+	deadline := time.Now().Add(c.Manager.writeWait)
+	c.Conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+	c.Conn.Close()
 }
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"master-guide-backend/internal/model"
+	"master-guide-backend/pkg/config"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestHandleMessageErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		handlerErr  error
+		wantCode    int
+		wantMessage string
+	}{
+		{name: "business error", handlerErr: NewWebSocketError(403, "未加入该圈子"), wantCode: 403, wantMessage: "未加入该圈子"},
+		{name: "wrapped business error", handlerErr: errors.Join(errors.New("context"), NewWebSocketError(404, "消息不存在")), wantCode: 404, wantMessage: "消息不存在"},
+		{name: "internal error", handlerErr: errors.New(`ERROR: relation "chat_messages" does not exist (SQLSTATE 42P01)`), wantCode: 500, wantMessage: "服务器内部错误"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewWebSocketManager(nil, nil, nil, config.WebSocketConfig{})
+			manager.HandleEvent("message", func(ctx context.Context, userID string, data json.RawMessage) (*model.WebSocketEvent, error) {
+				return nil, tt.handlerErr
+			})
+			client := &WebSocketClient{ID: "client", UserID: "alice", Send: make(chan []byte, 1), Manager: manager, IsOnline: true, LastSeen: time.Now()}
+
+			client.handleMessage([]byte(`{"event":"message","id":"req-1","data":{}}`))
+
+			var reply struct {
+				Event string           `json:"event"`
+				ID    string           `json:"id"`
+				Data  model.ErrorEvent `json:"data"`
+			}
+			select {
+			case message := <-client.Send:
+				if err := json.Unmarshal(message, &reply); err != nil {
+					t.Fatalf("unmarshal reply: %v", err)
+				}
+			default:
+				t.Fatalf("no reply sent")
+			}
+			if reply.Event != "error" || reply.ID != "req-1" || reply.Data.Event != "message" {
+				t.Fatalf("unexpected reply %+v", reply)
+			}
+			if reply.Data.Code != tt.wantCode || reply.Data.Message != tt.wantMessage {
+				t.Fatalf("error = %d %q, want %d %q", reply.Data.Code, reply.Data.Message, tt.wantCode, tt.wantMessage)
+			}
+		})
+	}
+}
+
+// sessionAuthenticator 按会话ID返回会话状态的认证器
+type sessionAuthenticator struct {
+	mu       sync.Mutex
+	inactive map[string]bool
+	err      error
+}
+
+func (a *sessionAuthenticator) AuthenticateToken(ctx context.Context, token string) (*JWTClaims, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *sessionAuthenticator) IsSessionActive(ctx context.Context, sessionID string) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.err != nil {
+		return false, a.err
+	}
+	return !a.inactive[sessionID], nil
+}
+
+// connectedClient 建立真实的 WebSocket 连接，返回服务端客户端和浏览器端连接
+func connectedClient(t *testing.T, manager *WebSocketManager, clientID, userID, sessionID string) (*WebSocketClient, *websocket.Conn) {
+	t.Helper()
+	conns := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		conns <- conn
+	}))
+	t.Cleanup(server.Close)
+
+	peer, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { peer.Close() })
+
+	client := &WebSocketClient{ID: clientID, Conn: <-conns, Send: make(chan []byte, 8), Manager: manager}
+	manager.mutex.Lock()
+	manager.clients[client.ID] = client
+	manager.mutex.Unlock()
+	manager.BindClient(client, &JWTClaims{UserID: userID, IdentityID: userID + "-identity", SessionID: sessionID})
+	return client, peer
+}
+
+// closeCode 读取浏览器端收到的关闭码，超时未关闭时返回 0
+func closeCode(t *testing.T, peer *websocket.Conn) int {
+	t.Helper()
+	peer.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	_, _, err := peer.ReadMessage()
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		return closeErr.Code
+	}
+	return 0
+}
+
+func TestCloseRevokedSessions(t *testing.T) {
+	authenticator := &sessionAuthenticator{inactive: map[string]bool{"session-revoked": true}}
+	manager := NewWebSocketManager(authenticator, nil, nil, config.WebSocketConfig{})
+	revoked, revokedPeer := connectedClient(t, manager, "client-1", "alice", "session-revoked")
+	active, activePeer := connectedClient(t, manager, "client-2", "bob", "session-active")
+
+	manager.closeRevokedSessions()
+
+	if code := closeCode(t, revokedPeer); code != websocket.ClosePolicyViolation {
+		t.Fatalf("revoked connection close code = %d, want %d", code, websocket.ClosePolicyViolation)
+	}
+	if manager.isAuthenticated(revoked) {
+		t.Fatal("revoked connection still authenticated")
+	}
+	if code := closeCode(t, activePeer); code != 0 {
+		t.Fatalf("active connection closed with code %d", code)
+	}
+	if !manager.isAuthenticated(active) {
+		t.Fatal("active connection no longer authenticated")
+	}
+}
+
+func TestCloseRevokedSessionsKeepsConnectionsWhenCheckFails(t *testing.T) {
+	authenticator := &sessionAuthenticator{err: errors.New("database unavailable")}
+	manager := NewWebSocketManager(authenticator, nil, nil, config.WebSocketConfig{})
+	client, peer := connectedClient(t, manager, "client-1", "alice", "session-1")
+
+	manager.closeRevokedSessions()
+
+	if code := closeCode(t, peer); code != 0 {
+		t.Fatalf("connection closed with code %d while the session check failed", code)
+	}
+	if !manager.isAuthenticated(client) {
+		t.Fatal("connection no longer authenticated while the session check failed")
+	}
+}
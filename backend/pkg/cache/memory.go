@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// memoryCleanupInterval 内存缓存过期键清理间隔
+const memoryCleanupInterval = time.Minute
+
+// memoryItem 内存缓存条目
+type memoryItem struct {
+	value    string
+	expireAt time.Time // 零值表示永不过期
+}
+
+// expired 条目是否已过期
+func (i *memoryItem) expired(now time.Time) bool {
+	return !i.expireAt.IsZero() && !i.expireAt.After(now)
+}
+
+// memoryStore Redis不可用时使用的进程内缓存，仅保证单实例内的语义
+type memoryStore struct {
+	mu          sync.Mutex
+	items       map[string]*memoryItem
+	cleanupOnce sync.Once
+}
+
+// newMemoryStore 创建内存缓存
+func newMemoryStore() *memoryStore {
+	return &memoryStore{items: make(map[string]*memoryItem)}
+}
+
+// startCleanup 启动过期键清理，重复调用只会启动一次
+func (m *memoryStore) startCleanup() {
+	m.cleanupOnce.Do(func() {
+		go m.cleanup()
+	})
+}
+
+// cleanup 定期清理过期键
+func (m *memoryStore) cleanup() {
+	ticker := time.NewTicker(memoryCleanupInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		m.mu.Lock()
+		for key, item := range m.items {
+			if item.expired(now) {
+				delete(m.items, key)
+			}
+		}
+		m.mu.Unlock()
+	}
+}
+
+// lookup 获取未过期的条目，调用方需持有锁
+func (m *memoryStore) lookup(key string, now time.Time) (*memoryItem, bool) {
+	item, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	if item.expired(now) {
+		delete(m.items, key)
+		return nil, false
+	}
+	return item, true
+}
+
+// expireAt 根据过期时长计算过期时间
+func expireAt(now time.Time, expiration time.Duration) time.Time {
+	if expiration <= 0 {
+		return time.Time{}
+	}
+	return now.Add(expiration)
+}
+
+func (m *memoryStore) get(key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.lookup(key, time.Now())
+	if !ok {
+		return "", Nil
+	}
+	return item.value, nil
+}
+
+func (m *memoryStore) set(key string, value interface{}, expiration time.Duration) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items[key] = &memoryItem{value: fmt.Sprint(value), expireAt: expireAt(now, expiration)}
+}
+
+func (m *memoryStore) del(keys ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		delete(m.items, key)
+	}
+}
+
+func (m *memoryStore) exists(key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.lookup(key, time.Now())
+	return ok
+}
+
+func (m *memoryStore) incr(key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.lookup(key, time.Now())
+	if !ok {
+		m.items[key] = &memoryItem{value: "1"}
+		return 1, nil
+	}
+	n, err := strconv.ParseInt(item.value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("value is not an integer")
+	}
+	n++
+	item.value = strconv.FormatInt(n, 10)
+	return n, nil
+}
+
+func (m *memoryStore) expire(key string, expiration time.Duration) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if item, ok := m.lookup(key, now); ok {
+		item.expireAt = expireAt(now, expiration)
+	}
+}
+
+func (m *memoryStore) ttl(key string) time.Duration {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.lookup(key, now)
+	if !ok {
+		return -2
+	}
+	if item.expireAt.IsZero() {
+		return -1
+	}
+	return item.expireAt.Sub(now)
+}
+
+func (m *memoryStore) setNX(key string, value interface{}, expiration time.Duration) bool {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.lookup(key, now); ok {
+		return false
+	}
+	m.items[key] = &memoryItem{value: fmt.Sprint(value), expireAt: expireAt(now, expiration)}
+	return true
+}
@@ -10,6 +10,12 @@ import (
 
 var rdb *redis.Client
 
+// mem Redis不可用时的内存缓存
+var mem = newMemoryStore()
+
+// Nil 键不存在时 Get 返回的错误
+var Nil = redis.Nil
+
 // Config Redis配置
 type Config struct {
 	Host         string
@@ -35,6 +41,10 @@ func Connect(config *Config) error {
 
 	_, err := rdb.Ping(ctx).Result()
 	if err != nil {
+		// 连接失败时回退到内存缓存
+		rdb.Close()
+		rdb = nil
+		mem.startCleanup()
 		return fmt.Errorf("failed to connect to redis: %w", err)
 	}
 
@@ -51,36 +61,78 @@ func Close() error {
 
 // Get 获取值
 func Get(ctx context.Context, key string) (string, error) {
+	if rdb == nil {
+		return mem.get(key)
+	}
 	return rdb.Get(ctx, key).Result()
 }
 
 // Set 设置值
 func Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	if rdb == nil {
+		mem.set(key, value, expiration)
+		return nil
+	}
 	return rdb.Set(ctx, key, value, expiration).Err()
 }
 
 // Del 删除键
 func Del(ctx context.Context, keys ...string) error {
+	if rdb == nil {
+		mem.del(keys...)
+		return nil
+	}
 	return rdb.Del(ctx, keys...).Err()
 }
 
 // Exists 检查键是否存在
 func Exists(ctx context.Context, key string) (bool, error) {
+	if rdb == nil {
+		return mem.exists(key), nil
+	}
 	result, err := rdb.Exists(ctx, key).Result()
 	return result > 0, err
 }
 
 // Incr 递增
 func Incr(ctx context.Context, key string) (int64, error) {
+	if rdb == nil {
+		return mem.incr(key)
+	}
 	return rdb.Incr(ctx, key).Result()
 }
 
+// Expire 设置过期时间
+func Expire(ctx context.Context, key string, expiration time.Duration) error {
+	if rdb == nil {
+		mem.expire(key, expiration)
+		return nil
+	}
+	return rdb.Expire(ctx, key, expiration).Err()
+}
+
+// TTL 获取剩余过期时间，键不存在返回-2，未设置过期返回-1
+func TTL(ctx context.Context, key string) (time.Duration, error) {
+	if rdb == nil {
+		return mem.ttl(key), nil
+	}
+	return rdb.TTL(ctx, key).Result()
+}
+
 // SetNX 设置值（如果不存在）
 func SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	if rdb == nil {
+		return mem.setNX(key, value, expiration), nil
+	}
 	return rdb.SetNX(ctx, key, value, expiration).Result()
 }
 
-// GetClient 获取Redis客户端
+// IsRedisAvailable Redis是否可用，不可用时缓存读写由进程内存承担
+func IsRedisAvailable() bool {
+	return rdb != nil
+}
+
+// GetClient 获取Redis客户端，Redis不可用时返回nil
 func GetClient() *redis.Client {
 	return rdb
 }
@@ -6,14 +6,28 @@ import (
 
 // Config 应用配置结构
 type Config struct {
-	Server    ServerConfig    `mapstructure:"server"`
-	Database  DatabaseConfig  `mapstructure:"database"`
-	Redis     RedisConfig     `mapstructure:"redis"`
-	JWT       JWTConfig       `mapstructure:"jwt"`
-	Log       LogConfig       `mapstructure:"log"`
-	CORS      CORSConfig      `mapstructure:"cors"`
-	Upload    UploadConfig    `mapstructure:"upload"`
-	WebSocket WebSocketConfig `mapstructure:"websocket"`
+	Server          ServerConfig          `mapstructure:"server"`
+	Database        DatabaseConfig        `mapstructure:"database"`
+	Redis           RedisConfig           `mapstructure:"redis"`
+	JWT             JWTConfig             `mapstructure:"jwt"`
+	Log             LogConfig             `mapstructure:"log"`
+	CORS            CORSConfig            `mapstructure:"cors"`
+	Upload          UploadConfig          `mapstructure:"upload"`
+	WebSocket       WebSocketConfig       `mapstructure:"websocket"`
+	Sender          SenderConfig          `mapstructure:"sender"`
+	Verification    VerificationConfig    `mapstructure:"verification"`
+	PasswordReset   PasswordResetConfig   `mapstructure:"password_reset"`
+	LoginProtection LoginProtectionConfig `mapstructure:"login_protection"`
+	Payment         PaymentConfig         `mapstructure:"payment"`
+	Admin           AdminConfig           `mapstructure:"admin"`
+	Scheduler       SchedulerConfig       `mapstructure:"scheduler"`
+	RefundPolicy    RefundPolicyConfig    `mapstructure:"refund_policy"`
+	Calendar        CalendarConfig        `mapstructure:"calendar"`
+	Meeting         MeetingConfig         `mapstructure:"meeting"`
+	Withdrawal      WithdrawalConfig      `mapstructure:"withdrawal"`
+	Settlement      SettlementConfig      `mapstructure:"settlement"`
+	IncomeReport    IncomeReportConfig    `mapstructure:"income_report"`
+	Invoice         InvoiceConfig         `mapstructure:"invoice"`
 }
 
 // ServerConfig 服务器配置
@@ -87,12 +101,190 @@ type WebSocketConfig struct {
 	PongWait        int `mapstructure:"pong_wait"`
 	PingPeriod      int `mapstructure:"ping_period"`
 	WriteWait       int `mapstructure:"write_wait"`
+	AuthTimeout     int `mapstructure:"auth_timeout"` // 未携带令牌的连接须在该秒数内发送 authenticate 事件完成认证，否则关闭
+
+	// 多实例部署
+	Backplane         string `mapstructure:"backplane"`          // 跨实例分发事件和共享在线状态：redis（Redis 不可用时拒绝启动）、memory（仅单实例），默认 memory
+	HeartbeatInterval int    `mapstructure:"heartbeat_interval"` // 在线状态心跳上报间隔（秒）
+	PresenceTTL       int    `mapstructure:"presence_ttl"`       // 在线状态有效期（秒），应大于心跳间隔，实例停止后其在线用户在该时间后失效
+}
+
+// SenderConfig 邮件/短信发送配置
+type SenderConfig struct {
+	Driver   string `mapstructure:"driver"`
+	FilePath string `mapstructure:"file_path"`
+}
+
+// VerificationConfig 验证码配置
+type VerificationConfig struct {
+	CodeLength     int `mapstructure:"code_length"`
+	CodeTTL        int `mapstructure:"code_ttl"`
+	ResendInterval int `mapstructure:"resend_interval"`
+	DailyLimit     int `mapstructure:"daily_limit"`
+	MaxAttempts    int `mapstructure:"max_attempts"`
+}
+
+// PasswordResetConfig 密码重置配置
+type PasswordResetConfig struct {
+	ResetURL       string `mapstructure:"reset_url"`
+	ExpireMinutes  int    `mapstructure:"expire_minutes"`
+	ResendInterval int    `mapstructure:"resend_interval"`
+}
+
+// LoginProtectionConfig 登录防暴力破解配置
+type LoginProtectionConfig struct {
+	MaxAccountFailures int `mapstructure:"max_account_failures"`
+	MaxIPFailures      int `mapstructure:"max_ip_failures"`
+	FailureWindow      int `mapstructure:"failure_window"`
+	LockoutDuration    int `mapstructure:"lockout_duration"`
+	DelayThreshold     int `mapstructure:"delay_threshold"`
+	BaseDelayMs        int `mapstructure:"base_delay_ms"`
+	MaxDelayMs         int `mapstructure:"max_delay_ms"`
+}
+
+// AdminConfig 管理员配置
+type AdminConfig struct {
+	UserIDs []string `mapstructure:"user_ids"` // 拥有运维管理权限的用户ID
+}
+
+// SchedulerConfig 定时任务配置
+type SchedulerConfig struct {
+	Enabled                     bool `mapstructure:"enabled"`
+	BatchSize                   int  `mapstructure:"batch_size"`                    // 每次执行处理的最大记录数
+	PaymentExpireInterval       int  `mapstructure:"payment_expire_interval"`       // 过期支付订单检查间隔（秒）
+	FulfillmentRetryInterval    int  `mapstructure:"fulfillment_retry_interval"`    // 履约失败订单、退款收入冲正及票据补开重试间隔（秒）
+	AppointmentReminderInterval int  `mapstructure:"appointment_reminder_interval"` // 预约提醒检查间隔（秒）
+	MeetingProvisionInterval    int  `mapstructure:"meeting_provision_interval"`    // 补建会议室检查间隔（秒）
+	IncomeSettlementInterval    int  `mapstructure:"income_settlement_interval"`    // 到期收入结算检查间隔（秒）
+	IncomeReportInterval        int  `mapstructure:"income_report_interval"`        // 生成排队中的收入报告及清理过期报告的间隔（秒）
+}
+
+// CalendarConfig 日历订阅与预约提醒配置
+type CalendarConfig struct {
+	APIBaseURL      string `mapstructure:"api_base_url"`     // 订阅地址为 {api_base_url}/calendar/feeds/{token}
+	PastDays        int    `mapstructure:"past_days"`        // 订阅中包含的历史预约天数
+	ReminderOffsets []int  `mapstructure:"reminder_offsets"` // 预约开始前多少分钟发送提醒，同时写入日历事件的提醒
+}
+
+// MeetingConfig 视频咨询会议配置
+type MeetingConfig struct {
+	Provider          string                  `mapstructure:"provider"`            // 会议服务，目前支持 selfhosted
+	JoinBeforeMinutes int                     `mapstructure:"join_before_minutes"` // 开始前多少分钟可进入会议
+	JoinAfterMinutes  int                     `mapstructure:"join_after_minutes"`  // 结束后多少分钟内仍可进入会议
+	SelfHosted        SelfHostedMeetingConfig `mapstructure:"selfhosted"`
+}
+
+// SelfHostedMeetingConfig 自建会议服务配置
+type SelfHostedMeetingConfig struct {
+	Secret       string `mapstructure:"secret"`        // 入会凭证签名密钥，由环境变量 MEETING_SELFHOSTED_SECRET 提供
+	JoinPageURL  string `mapstructure:"join_page_url"` // 前端会议页面，入会地址为 {join_page_url}/{room_id}?token={token}
+	SignalingURL string `mapstructure:"signaling_url"` // 信令地址为 {signaling_url}/{room_id}
+}
+
+// RefundPolicyConfig 取消退款策略配置
+type RefundPolicyConfig struct {
+	Appointment AppointmentRefundPolicyConfig `mapstructure:"appointment"`
+	Course      CourseRefundPolicyConfig      `mapstructure:"course"`
+}
+
+// AppointmentRefundPolicyConfig 学生取消预约的退款策略，大师取消或拒绝时始终全额退款
+type AppointmentRefundPolicyConfig struct {
+	FullRefundHours    int     `mapstructure:"full_refund_hours"`    // 距开始不少于该小时数取消全额退款
+	PartialRefundHours int     `mapstructure:"partial_refund_hours"` // 距开始不少于该小时数取消按比例退款，之后不退款
+	PartialRefundRate  float64 `mapstructure:"partial_refund_rate"`  // 部分退款比例，如 0.5 表示退还50%
+}
+
+// CourseRefundPolicyConfig 学生退课的退款策略
+type CourseRefundPolicyConfig struct {
+	RefundWindowDays         int     `mapstructure:"refund_window_days"`          // 报名后可退款的天数
+	FullRefundMaxProgress    float64 `mapstructure:"full_refund_max_progress"`    // 学习进度不超过该百分比时全额退款
+	PartialRefundMaxProgress float64 `mapstructure:"partial_refund_max_progress"` // 学习进度不超过该百分比时按比例退款，未配置或低于全额退款进度时取50与全额退款进度中的较大者
+	PartialRefundRate        float64 `mapstructure:"partial_refund_rate"`
+}
+
+// PaymentConfig 支付配置
+type PaymentConfig struct {
+	APIBaseURL      string               `mapstructure:"api_base_url"`      // 回调地址为 {api_base_url}/payments/webhook/{gateway}
+	PlatformFeeRate float64              `mapstructure:"platform_fee_rate"` // 默认平台服务费率，未匹配到费率规则时使用，如 0.1 表示收取10%
+	Sandbox         SandboxPaymentConfig `mapstructure:"sandbox"`
+	Alipay          AlipayPaymentConfig  `mapstructure:"alipay"`
+	Wechat          WechatPaymentConfig  `mapstructure:"wechat"`
+}
+
+// SandboxPaymentConfig 沙箱支付配置
+type SandboxPaymentConfig struct {
+	Enabled bool     `mapstructure:"enabled"`
+	Secret  string   `mapstructure:"secret"`
+	Methods []string `mapstructure:"methods"` // 由沙箱处理的支付方式，不能包含 alipay、wechat 等真实支付方式
+}
+
+// AlipayPaymentConfig 支付宝配置
+type AlipayPaymentConfig struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	AppID      string `mapstructure:"app_id"`
+	PrivateKey string `mapstructure:"private_key"`
+	PublicKey  string `mapstructure:"public_key"`
+	GatewayURL string `mapstructure:"gateway_url"`
+}
+
+// WechatPaymentConfig 微信支付配置
+type WechatPaymentConfig struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	AppID      string `mapstructure:"app_id"`
+	MchID      string `mapstructure:"mch_id"`
+	APIKey     string `mapstructure:"api_key"`
+	CertFile   string `mapstructure:"cert_file"`
+	KeyFile    string `mapstructure:"key_file"`
+	GatewayURL string `mapstructure:"gateway_url"`
+}
+
+// WithdrawalConfig 提现配置
+type WithdrawalConfig struct {
+	BankAccountKey  string `mapstructure:"bank_account_key"`  // 银行账号加密密钥，base64 编码的32字节，由环境变量 WITHDRAWAL_BANK_ACCOUNT_KEY 提供
+	PayoutBatchSize int    `mapstructure:"payout_batch_size"` // 每个打款批次最多包含的提现数量
+}
+
+// SettlementConfig 收入结算配置，大师收入在服务完成后冻结一段时间才可提现
+type SettlementConfig struct {
+	HoldingDays          int `mapstructure:"holding_days"`           // 服务完成后的冻结天数，如 7 表示 T+7
+	CourseCompletionDays int `mapstructure:"course_completion_days"` // 学员未学完课程时，报名满该天数视为服务完成
+}
+
+// IncomeReportConfig 收入报告导出配置
+type IncomeReportConfig struct {
+	APIBaseURL      string `mapstructure:"api_base_url"`      // 下载地址为 {api_base_url}/income/reports/{report_id}/download
+	SigningSecret   string `mapstructure:"signing_secret"`    // 下载地址签名密钥，由环境变量 INCOME_REPORT_SIGNING_SECRET 提供
+	StorageDir      string `mapstructure:"storage_dir"`       // 报告文件存放目录，不应位于静态文件目录下
+	LinkExpireHours int    `mapstructure:"link_expire_hours"` // 报告生成后下载地址的有效小时数，过期后删除文件
+	SyncMaxDays     int    `mapstructure:"sync_max_days"`     // 不超过该天数的区间立即生成，更长的区间排队后台生成
+}
+
+// InvoiceConfig 票据配置，平台为大师平台服务费账单的开具方，学生服务收据由平台代大师开具
+type InvoiceConfig struct {
+	PlatformName       string  `mapstructure:"platform_name"`         // 平台名称
+	PlatformTaxID      string  `mapstructure:"platform_tax_id"`       // 平台纳税人识别号
+	PlatformAddress    string  `mapstructure:"platform_address"`      // 平台地址
+	PlatformEmail      string  `mapstructure:"platform_email"`        // 平台联系邮箱
+	ServiceTaxRate     float64 `mapstructure:"service_tax_rate"`      // 学生服务收据税率，价格为含税价
+	PlatformFeeTaxRate float64 `mapstructure:"platform_fee_tax_rate"` // 平台服务费账单税率，平台服务费为含税价
+}
+
+// secretEnvBindings 密钥配置项与环境变量的对应关系，密钥不写入配置文件，只从环境变量读取
+var secretEnvBindings = [][2]string{
+	{"withdrawal.bank_account_key", "WITHDRAWAL_BANK_ACCOUNT_KEY"},
+	{"income_report.signing_secret", "INCOME_REPORT_SIGNING_SECRET"},
+	{"meeting.selfhosted.secret", "MEETING_SELFHOSTED_SECRET"},
 }
 
 // Load 加载配置文件
 func Load(configPath string) (*Config, error) {
 	viper.SetConfigFile(configPath)
 	viper.AutomaticEnv()
+	for _, binding := range secretEnvBindings {
+		if err := viper.BindEnv(binding[0], binding[1]); err != nil {
+			return nil, err
+		}
+	}
 
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, err
@@ -0,0 +1,164 @@
+package ical
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ContentType iCalendar 文件的 MIME 类型
+const ContentType = "text/calendar; charset=utf-8"
+
+// 事件状态，对应 STATUS 属性
+const (
+	StatusTentative = "TENTATIVE"
+	StatusConfirmed = "CONFIRMED"
+	StatusCancelled = "CANCELLED"
+)
+
+// maxLineOctets 内容行折行前的最大字节数（RFC 5545 3.1）
+const maxLineOctets = 75
+
+// Calendar 日历，对应 VCALENDAR 组件
+type Calendar struct {
+	ProdID string // 生成日历的产品标识
+	Name   string // 日历显示名称，对应 X-WR-CALNAME
+	Method string // 为空时不输出，单个事件下载时使用 PUBLISH
+	Events []*Event
+}
+
+// Event 日历事件，对应 VEVENT 组件，时间统一以 UTC 输出
+type Event struct {
+	UID          string
+	Start        time.Time
+	End          time.Time
+	Summary      string
+	Description  string
+	Location     string
+	URL          string
+	Status       string
+	Sequence     int // 事件变更次数，日历客户端据此判断是否需要更新
+	Created      time.Time
+	LastModified time.Time
+	Alarms       []time.Duration // 开始前多久提醒
+}
+
+// Encode 按 RFC 5545 生成 iCalendar 文本，行以 CRLF 结尾并按 75 字节折行
+func (c *Calendar) Encode() []byte {
+	w := &writer{}
+	now := time.Now()
+
+	w.line("BEGIN", "VCALENDAR")
+	w.line("VERSION", "2.0")
+	w.line("PRODID", c.ProdID)
+	w.line("CALSCALE", "GREGORIAN")
+	if c.Method != "" {
+		w.line("METHOD", c.Method)
+	}
+	if c.Name != "" {
+		w.line("X-WR-CALNAME", escapeText(c.Name))
+	}
+
+	for _, event := range c.Events {
+		w.line("BEGIN", "VEVENT")
+		w.line("UID", event.UID)
+		w.line("DTSTAMP", formatTime(now))
+		w.line("DTSTART", formatTime(event.Start))
+		w.line("DTEND", formatTime(event.End))
+		w.line("SUMMARY", escapeText(event.Summary))
+		if event.Description != "" {
+			w.line("DESCRIPTION", escapeText(event.Description))
+		}
+		if event.Location != "" {
+			w.line("LOCATION", escapeText(event.Location))
+		}
+		if event.URL != "" {
+			w.line("URL", event.URL)
+		}
+		if event.Status != "" {
+			w.line("STATUS", event.Status)
+		}
+		w.line("SEQUENCE", fmt.Sprintf("%d", event.Sequence))
+		if !event.Created.IsZero() {
+			w.line("CREATED", formatTime(event.Created))
+		}
+		if !event.LastModified.IsZero() {
+			w.line("LAST-MODIFIED", formatTime(event.LastModified))
+		}
+		// 已取消的事件不再提醒
+		if event.Status != StatusCancelled {
+			for _, before := range event.Alarms {
+				w.line("BEGIN", "VALARM")
+				w.line("ACTION", "DISPLAY")
+				w.line("DESCRIPTION", escapeText(event.Summary))
+				w.line("TRIGGER", formatTrigger(before))
+				w.line("END", "VALARM")
+			}
+		}
+		w.line("END", "VEVENT")
+	}
+
+	w.line("END", "VCALENDAR")
+	return w.buf.Bytes()
+}
+
+// writer 输出折行后的内容行
+type writer struct {
+	buf bytes.Buffer
+}
+
+// line 写入一个内容行，超过 75 字节时在 UTF-8 字符边界折行，续行以空格开头
+func (w *writer) line(name, value string) {
+	content := name + ":" + value
+	limit := maxLineOctets
+	for len(content) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+		w.buf.WriteString(content[:cut])
+		w.buf.WriteString("\r\n ")
+		content = content[cut:]
+		// 续行开头的空格占用一个字节
+		limit = maxLineOctets - 1
+	}
+	w.buf.WriteString(content)
+	w.buf.WriteString("\r\n")
+}
+
+// textEscaper 转义 TEXT 类型值中的特殊字符（RFC 5545 3.3.11）
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", `\n`,
+)
+
+// escapeText 转义文本值
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// formatTime 以 UTC 格式输出时间
+func formatTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// formatTrigger 输出开始前提醒的相对时间，如 -PT15M、-P1D
+func formatTrigger(before time.Duration) string {
+	minutes := int(before / time.Minute)
+	if minutes <= 0 {
+		return "PT0M"
+	}
+	if minutes%(24*60) == 0 {
+		return fmt.Sprintf("-P%dD", minutes/(24*60))
+	}
+	if minutes%60 == 0 {
+		return fmt.Sprintf("-PT%dH", minutes/60)
+	}
+	return fmt.Sprintf("-PT%dM", minutes)
+}
@@ -0,0 +1,163 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// physicalLines 按 CRLF 拆分输出，去掉末尾的空行
+func physicalLines(t *testing.T, output string) []string {
+	t.Helper()
+	if !strings.HasSuffix(output, "\r\n") {
+		t.Fatalf("output does not end with CRLF: %q", output)
+	}
+	return strings.Split(strings.TrimSuffix(output, "\r\n"), "\r\n")
+}
+
+// unfold 还原折行后的内容行（RFC 5545 3.1）
+func unfold(output string) string {
+	return strings.ReplaceAll(output, "\r\n ", "")
+}
+
+func TestWriterLineFolding(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "short", value: "大师咨询"},
+		{name: "ascii", value: strings.Repeat("abcdefghij", 20)},
+		{name: "chinese", value: strings.Repeat("与大师的一对一咨询预约，", 20)},
+		// 前缀改变多字节字符相对折行位置的对齐方式
+		{name: "chinese offset by one", value: "a" + strings.Repeat("与大师的一对一咨询预约，", 20)},
+		{name: "chinese offset by two", value: "ab" + strings.Repeat("与大师的一对一咨询预约，", 20)},
+		{name: "four byte runes", value: strings.Repeat("🎓课程", 40)},
+		{name: "exactly 75 octets", value: strings.Repeat("x", maxLineOctets-len("SUMMARY:"))},
+		{name: "76 octets", value: strings.Repeat("x", maxLineOctets-len("SUMMARY:")+1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &writer{}
+			w.line("SUMMARY", tt.value)
+			output := w.buf.String()
+
+			lines := physicalLines(t, output)
+			for i, line := range lines {
+				if len(line) > maxLineOctets {
+					t.Errorf("line %d has %d octets, want at most %d: %q", i, len(line), maxLineOctets, line)
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("line %d splits a multi-byte character: %q", i, line)
+				}
+				if i > 0 && !strings.HasPrefix(line, " ") {
+					t.Errorf("continuation line %d does not start with a space: %q", i, line)
+				}
+			}
+			if wantFolded := len("SUMMARY:"+tt.value) > maxLineOctets; wantFolded != (len(lines) > 1) {
+				t.Errorf("folded into %d lines, want folded = %v", len(lines), wantFolded)
+			}
+			if got := unfold(output); got != "SUMMARY:"+tt.value+"\r\n" {
+				t.Errorf("unfolded line = %q, want original content", got)
+			}
+		})
+	}
+}
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "plain", value: "大师咨询", want: "大师咨询"},
+		{name: "semicolon", value: "课程;预约", want: `课程\;预约`},
+		{name: "comma", value: "上午,下午", want: `上午\,下午`},
+		{name: "backslash", value: `C:\path`, want: `C:\\path`},
+		{name: "escaped backslash before comma", value: `a\,b`, want: `a\\\,b`},
+		{name: "lf", value: "第一行\n第二行", want: `第一行\n第二行`},
+		{name: "crlf", value: "第一行\r\n第二行", want: `第一行\n第二行`},
+		{name: "cr", value: "第一行\r第二行", want: `第一行\n第二行`},
+		{name: "colon is not escaped", value: "时间: 10:00", want: "时间: 10:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := escapeText(tt.value); got != tt.want {
+				t.Fatalf("escapeText(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalendarEncode(t *testing.T) {
+	start := time.Date(2024, 3, 1, 18, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	calendar := &Calendar{
+		ProdID: "-//Master Guide//Calendar//ZH",
+		Name:   "我的预约",
+		Events: []*Event{
+			{
+				UID:         "APPT_1@master-guide",
+				Start:       start,
+				End:         start.Add(time.Hour),
+				Summary:     "职业规划咨询; 第一次",
+				Description: strings.Repeat("请提前准备简历，并列出想讨论的问题。\n", 5),
+				Status:      StatusConfirmed,
+				Alarms:      []time.Duration{24 * time.Hour, 15 * time.Minute},
+			},
+			{
+				UID:     "APPT_2@master-guide",
+				Start:   start.Add(48 * time.Hour),
+				End:     start.Add(49 * time.Hour),
+				Summary: "已取消的咨询",
+				Status:  StatusCancelled,
+				Alarms:  []time.Duration{15 * time.Minute},
+			},
+		},
+	}
+
+	output := string(calendar.Encode())
+	for i, line := range physicalLines(t, output) {
+		if len(line) > maxLineOctets || !utf8.ValidString(line) {
+			t.Errorf("line %d is not folded correctly: %q", i, line)
+		}
+	}
+
+	unfolded := unfold(output)
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"X-WR-CALNAME:我的预约\r\n",
+		"DTSTART:20240301T100000Z\r\n",
+		"DTEND:20240301T110000Z\r\n",
+		`SUMMARY:职业规划咨询\; 第一次` + "\r\n",
+		"DESCRIPTION:" + strings.Repeat(`请提前准备简历，并列出想讨论的问题。\n`, 5) + "\r\n",
+		"TRIGGER:-P1D\r\n",
+		"TRIGGER:-PT15M\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(unfolded, want) {
+			t.Errorf("encoded calendar missing %q", want)
+		}
+	}
+	if got := strings.Count(unfolded, "BEGIN:VALARM"); got != 2 {
+		t.Errorf("encoded calendar has %d alarms, want 2 (cancelled events have none)", got)
+	}
+}
+
+func TestFormatTrigger(t *testing.T) {
+	tests := []struct {
+		before time.Duration
+		want   string
+	}{
+		{before: 0, want: "PT0M"},
+		{before: 15 * time.Minute, want: "-PT15M"},
+		{before: 90 * time.Minute, want: "-PT90M"},
+		{before: 2 * time.Hour, want: "-PT2H"},
+		{before: 24 * time.Hour, want: "-P1D"},
+	}
+	for _, tt := range tests {
+		if got := formatTrigger(tt.before); got != tt.want {
+			t.Errorf("formatTrigger(%v) = %q, want %q", tt.before, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+)
+
+// utf8BOM 写在 CSV 开头，使 Excel 按 UTF-8 打开中文内容
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// encodeCSV 生成 CSV 报表，各表格之间以空行分隔，表格标题单独占一行
+func encodeCSV(doc *Document) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(utf8BOM)
+	w := csv.NewWriter(&buf)
+
+	if doc.Title != "" {
+		w.Write([]string{doc.Title})
+	}
+	for _, line := range doc.Lines {
+		w.Write([]string{line})
+	}
+
+	for _, table := range doc.Tables {
+		w.Write(nil)
+		if table.Title != "" {
+			w.Write([]string{table.Title})
+		}
+		header := make([]string, len(table.Columns))
+		for i, column := range table.Columns {
+			header[i] = column.Title
+		}
+		w.Write(header)
+		for _, row := range table.Rows {
+			record := make([]string, len(table.Columns))
+			for i := range table.Columns {
+				record[i] = cell(row, i)
+			}
+			w.Write(record)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
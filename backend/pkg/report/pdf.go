@@ -0,0 +1,247 @@
+package report
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// PDF 版式：A4 横向，单位为点（1/72 英寸）
+const (
+	pdfPageWidth    = 842.0
+	pdfPageHeight   = 595.0
+	pdfMargin       = 36.0
+	pdfTitleSize    = 16.0
+	pdfHeadingSize  = 12.0
+	pdfTextSize     = 10.0
+	pdfCellSize     = 9.0
+	pdfRowHeight    = 16.0
+	pdfCellPadding  = 4.0
+	pdfMaxColumnPct = 0.4 // 单列最多占可用宽度的比例
+)
+
+// pdfFontObjects 使用 Adobe-GB1 预定义的 STSong-Light 字体，阅读器自带该 CJK 字体，文件中无需嵌入字形；
+// 文本按 UCS-2 编码写入，ASCII 字符（CID 1-95）按半角宽度排版
+const pdfFontObjects = `<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UCS2-H /DescendantFonts [4 0 R] >>
+<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light /CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 2 >> /FontDescriptor 5 0 R /DW 1000 /W [1 95 500] >>
+<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] /ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>`
+
+// encodePDF 生成 PDF 报表，表格跨页时在新页重复表头，页脚标注页码
+func encodePDF(doc *Document) ([]byte, error) {
+	l := &pdfLayout{}
+	l.newPage()
+
+	if doc.Title != "" {
+		l.ensure(pdfTitleSize + 8)
+		l.text(pdfMargin, l.y-pdfTitleSize, pdfTitleSize, doc.Title)
+		l.y -= pdfTitleSize + 8
+	}
+	for _, line := range doc.Lines {
+		l.ensure(pdfTextSize + 6)
+		l.text(pdfMargin, l.y-pdfTextSize, pdfTextSize, line)
+		l.y -= pdfTextSize + 6
+	}
+
+	for _, table := range doc.Tables {
+		l.y -= 10
+		l.table(table)
+	}
+
+	return l.encode()
+}
+
+// pdfLayout 逐页排版报表内容
+type pdfLayout struct {
+	pages []*bytes.Buffer
+	page  *bytes.Buffer
+	y     float64 // 当前可写区域顶部的纵坐标
+}
+
+// newPage 开始新的一页
+func (l *pdfLayout) newPage() {
+	l.page = &bytes.Buffer{}
+	l.pages = append(l.pages, l.page)
+	l.y = pdfPageHeight - pdfMargin
+}
+
+// ensure 当前页剩余高度不足时换页，返回是否换页
+func (l *pdfLayout) ensure(height float64) bool {
+	if l.y-height >= pdfMargin+pdfCellSize {
+		return false
+	}
+	l.newPage()
+	return true
+}
+
+// text 在指定基线位置写入一行文本
+func (l *pdfLayout) text(x, y, size float64, s string) {
+	fmt.Fprintf(l.page, "BT /F1 %.1f Tf %.2f %.2f Td <%s> Tj ET\n", size, x, y, pdfHexString(s))
+}
+
+// hline 绘制水平线
+func (l *pdfLayout) hline(x1, x2, y float64) {
+	fmt.Fprintf(l.page, "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, y, x2, y)
+}
+
+// table 排版一个表格，列宽按内容宽度在可用宽度内按比例分配，超出列宽的文本截断
+func (l *pdfLayout) table(table *Table) {
+	if table.Title != "" {
+		l.ensure(pdfHeadingSize + 6 + pdfRowHeight*2)
+		l.text(pdfMargin, l.y-pdfHeadingSize, pdfHeadingSize, table.Title)
+		l.y -= pdfHeadingSize + 6
+	}
+	if len(table.Columns) == 0 {
+		return
+	}
+
+	widths := pdfColumnWidths(table)
+	right := pdfMargin
+	for _, w := range widths {
+		right += w
+	}
+
+	drawRow := func(values []string, numeric func(int) bool) {
+		baseline := l.y - pdfRowHeight + (pdfRowHeight-pdfCellSize)/2 + 1
+		x := pdfMargin
+		for i, w := range widths {
+			value := pdfFitText(cell(values, i), w-2*pdfCellPadding, pdfCellSize)
+			if numeric(i) {
+				l.text(x+w-pdfCellPadding-pdfTextWidth(value, pdfCellSize), baseline, pdfCellSize, value)
+			} else {
+				l.text(x+pdfCellPadding, baseline, pdfCellSize, value)
+			}
+			x += w
+		}
+		l.y -= pdfRowHeight
+	}
+	header := make([]string, len(table.Columns))
+	for i, column := range table.Columns {
+		header[i] = column.Title
+	}
+	drawHeader := func() {
+		l.hline(pdfMargin, right, l.y)
+		drawRow(header, func(int) bool { return false })
+		l.hline(pdfMargin, right, l.y)
+	}
+	isNumeric := func(i int) bool { return table.Columns[i].Numeric }
+
+	l.ensure(pdfRowHeight * 2)
+	drawHeader()
+	for _, row := range table.Rows {
+		if l.ensure(pdfRowHeight) {
+			drawHeader()
+		}
+		drawRow(row, isNumeric)
+	}
+	l.hline(pdfMargin, right, l.y)
+}
+
+// encode 输出 PDF 文件：1 目录，2 页面树，3-5 字体，之后每页依次为页面对象和内容流
+func (l *pdfLayout) encode() ([]byte, error) {
+	var objects []string
+	objects = append(objects, "<< /Type /Catalog /Pages 2 0 R >>")
+
+	kids := make([]string, len(l.pages))
+	for i := range l.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 6+i*2)
+	}
+	objects = append(objects, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(l.pages)))
+	objects = append(objects, strings.Split(pdfFontObjects, "\n")...)
+
+	for i, page := range l.pages {
+		footer := fmt.Sprintf("%d / %d", i+1, len(l.pages))
+		fmt.Fprintf(page, "BT /F1 %.1f Tf %.2f %.2f Td <%s> Tj ET\n", pdfCellSize, (pdfPageWidth-pdfTextWidth(footer, pdfCellSize))/2, pdfMargin/2, pdfHexString(footer))
+
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		if _, err := zw.Write(page.Bytes()); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+
+		contentID := 7 + i*2
+		objects = append(objects, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", pdfPageWidth, pdfPageHeight, contentID))
+		objects = append(objects, fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", compressed.Len(), compressed.Bytes()))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n%\xE2\xE3\xCF\xD3\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes(), nil
+}
+
+// pdfColumnWidths 按各列内容的最大宽度分配列宽，总宽度撑满可用宽度
+func pdfColumnWidths(table *Table) []float64 {
+	available := pdfPageWidth - 2*pdfMargin
+	widths := make([]float64, len(table.Columns))
+	total := 0.0
+	for i, column := range table.Columns {
+		w := pdfTextWidth(column.Title, pdfCellSize)
+		for _, row := range table.Rows {
+			w = max(w, pdfTextWidth(cell(row, i), pdfCellSize))
+		}
+		widths[i] = min(w+2*pdfCellPadding, available*pdfMaxColumnPct)
+		total += widths[i]
+	}
+	for i := range widths {
+		widths[i] = widths[i] * available / total
+	}
+	return widths
+}
+
+// pdfFitText 截断超出宽度的文本并以省略号结尾
+func pdfFitText(s string, width, size float64) string {
+	if pdfTextWidth(s, size) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for n := len(runes) - 1; n > 0; n-- {
+		truncated := string(runes[:n]) + "…"
+		if pdfTextWidth(truncated, size) <= width {
+			return truncated
+		}
+	}
+	return ""
+}
+
+// pdfTextWidth 估算文本宽度：ASCII 字符为半角，其余为全角
+func pdfTextWidth(s string, size float64) float64 {
+	width := 0.0
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			width += 0.5
+		} else {
+			width += 1
+		}
+	}
+	return width * size
+}
+
+// pdfHexString 将文本编码为 UCS-2 十六进制字符串，控制字符替换为空格，BMP 之外的字符替换为问号
+func pdfHexString(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch {
+		case r < 0x20:
+			r = ' '
+		case r > 0xFFFF:
+			r = '?'
+		}
+		fmt.Fprintf(&sb, "%04X", r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,92 @@
+package report
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// 报表格式
+const (
+	FormatCSV   = "csv"
+	FormatExcel = "excel"
+	FormatPDF   = "pdf"
+)
+
+// Document 报表文档，由标题、说明行和若干表格组成
+type Document struct {
+	Title  string
+	Lines  []string // 标题下方的说明行，如统计区间、生成时间
+	Tables []*Table
+}
+
+// Table 报表中的一个表格，Excel 中对应一个工作表
+type Table struct {
+	Title   string
+	Columns []Column
+	Rows    [][]string
+}
+
+// Column 表格列，数值列在 Excel 中按数字写入，在 PDF 中右对齐
+type Column struct {
+	Title   string
+	Numeric bool
+}
+
+// Encode 按格式生成报表文件内容
+func Encode(doc *Document, format string) ([]byte, error) {
+	switch format {
+	case FormatCSV:
+		return encodeCSV(doc)
+	case FormatExcel:
+		return encodeXLSX(doc)
+	case FormatPDF:
+		return encodePDF(doc)
+	default:
+		return nil, fmt.Errorf("report: unsupported format %q", format)
+	}
+}
+
+// ContentType 返回报表格式对应的 MIME 类型
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatExcel:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case FormatPDF:
+		return "application/pdf"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// Extension 返回报表格式对应的文件扩展名（含点号）
+func Extension(format string) string {
+	switch format {
+	case FormatCSV:
+		return ".csv"
+	case FormatExcel:
+		return ".xlsx"
+	case FormatPDF:
+		return ".pdf"
+	default:
+		return ".bin"
+	}
+}
+
+// cell 返回行中指定列的值，行长度不足时返回空字符串
+func cell(row []string, i int) string {
+	if i < len(row) {
+		return row[i]
+	}
+	return ""
+}
+
+// truncateRunes 截断超过 n 个字符的文本并以省略号结尾
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:n-1]) + "…"
+}
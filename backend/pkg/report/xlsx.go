@@ -0,0 +1,215 @@
+package report
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxSheetNameRunes Excel 工作表名称的最大长度
+const maxSheetNameRunes = 31
+
+// xlsx 包内的固定部件
+const (
+	xlsxContentTypesHead = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`
+
+	xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`
+
+	// 样式 0 为默认，1 为加粗（标题和表头），2 为两位小数的数字
+	xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<numFmts count="1"><numFmt numFmtId="164" formatCode="#,##0.00"/></numFmts>` +
+		`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+		`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+		`<cellXfs count="3"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+		`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+		`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs>` +
+		`</styleSheet>`
+)
+
+// xlsx 单元格样式
+const (
+	xlsxStyleDefault = 0
+	xlsxStyleBold    = 1
+	xlsxStyleNumber  = 2
+)
+
+// encodeXLSX 生成 Office Open XML 工作簿，每个表格一个工作表，文档标题和说明行写在第一个工作表顶部
+func encodeXLSX(doc *Document) ([]byte, error) {
+	tables := doc.Tables
+	if len(tables) == 0 {
+		tables = []*Table{{Title: doc.Title}}
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	write := func(name, content string) error {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write([]byte(content))
+		return err
+	}
+
+	var contentTypes, workbook, workbookRels strings.Builder
+	contentTypes.WriteString(xlsxContentTypesHead)
+	workbook.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	workbookRels.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+
+	usedNames := make(map[string]bool)
+	for i, table := range tables {
+		sheetNo := i + 1
+		var preface []string
+		if i == 0 {
+			if doc.Title != "" {
+				preface = append(preface, doc.Title)
+			}
+			preface = append(preface, doc.Lines...)
+		}
+		if err := write(fmt.Sprintf("xl/worksheets/sheet%d.xml", sheetNo), xlsxSheet(table, preface)); err != nil {
+			return nil, err
+		}
+
+		name := xlsxSheetName(table.Title, sheetNo, usedNames)
+		fmt.Fprintf(&contentTypes, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, sheetNo)
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(name), sheetNo, sheetNo)
+		fmt.Fprintf(&workbookRels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, sheetNo, sheetNo)
+	}
+	contentTypes.WriteString(`</Types>`)
+	workbook.WriteString(`</sheets></workbook>`)
+	fmt.Fprintf(&workbookRels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(tables)+1)
+	workbookRels.WriteString(`</Relationships>`)
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", contentTypes.String()},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", workbook.String()},
+		{"xl/_rels/workbook.xml.rels", workbookRels.String()},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for _, part := range parts {
+		if err := write(part.name, part.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// xlsxSheet 生成工作表 XML，preface 为表头前的说明行，其后空一行
+func xlsxSheet(table *Table, preface []string) string {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+
+	if len(table.Columns) > 0 {
+		sb.WriteString(`<cols>`)
+		for i, column := range table.Columns {
+			width := 14
+			if !column.Numeric {
+				width = 22
+			}
+			fmt.Fprintf(&sb, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, i+1, i+1, width)
+		}
+		sb.WriteString(`</cols>`)
+	}
+
+	sb.WriteString(`<sheetData>`)
+	rowNo := 0
+	for i, line := range preface {
+		rowNo++
+		style := xlsxStyleDefault
+		if i == 0 {
+			style = xlsxStyleBold
+		}
+		fmt.Fprintf(&sb, `<row r="%d">%s</row>`, rowNo, xlsxStringCell(0, rowNo, line, style))
+	}
+	if len(preface) > 0 {
+		rowNo++
+	}
+
+	if len(table.Columns) > 0 {
+		rowNo++
+		fmt.Fprintf(&sb, `<row r="%d">`, rowNo)
+		for i, column := range table.Columns {
+			sb.WriteString(xlsxStringCell(i, rowNo, column.Title, xlsxStyleBold))
+		}
+		sb.WriteString(`</row>`)
+	}
+	for _, row := range table.Rows {
+		rowNo++
+		fmt.Fprintf(&sb, `<row r="%d">`, rowNo)
+		for i, column := range table.Columns {
+			value := cell(row, i)
+			if column.Numeric {
+				if _, err := strconv.ParseFloat(value, 64); err == nil {
+					fmt.Fprintf(&sb, `<c r="%s" s="%d"><v>%s</v></c>`, xlsxCellRef(i, rowNo), xlsxStyleNumber, value)
+					continue
+				}
+			}
+			sb.WriteString(xlsxStringCell(i, rowNo, value, xlsxStyleDefault))
+		}
+		sb.WriteString(`</row>`)
+	}
+	sb.WriteString(`</sheetData></worksheet>`)
+	return sb.String()
+}
+
+// xlsxStringCell 生成内联字符串单元格
+func xlsxStringCell(col, row int, value string, style int) string {
+	if value == "" {
+		return ""
+	}
+	return fmt.Sprintf(`<c r="%s" t="inlineStr" s="%d"><is><t xml:space="preserve">%s</t></is></c>`, xlsxCellRef(col, row), style, xmlEscape(value))
+}
+
+// xlsxCellRef 将从 0 开始的列号和从 1 开始的行号转换为 A1 形式的单元格引用
+func xlsxCellRef(col, row int) string {
+	name := ""
+	for n := col + 1; n > 0; n = (n - 1) / 26 {
+		name = string(rune('A'+(n-1)%26)) + name
+	}
+	return name + strconv.Itoa(row)
+}
+
+// xlsxSheetName 生成合法且不重复的工作表名称
+func xlsxSheetName(title string, sheetNo int, used map[string]bool) string {
+	name := strings.Map(func(r rune) rune {
+		switch r {
+		case '[', ']', ':', '*', '?', '/', '\\':
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(title))
+	name = truncateRunes(name, maxSheetNameRunes)
+	if name == "" || used[name] {
+		name = fmt.Sprintf("Sheet%d", sheetNo)
+	}
+	used[name] = true
+	return name
+}
+
+// xmlEscape 转义 XML 文本，XML 1.0 不允许的字符替换为 U+FFFD
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,34 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DBLocker 基于 scheduler_locks 表的任务锁
+// 锁以租约形式保存，过期时间使用数据库时间计算，避免各实例时钟不一致
+type DBLocker struct {
+	db *gorm.DB
+}
+
+// NewDBLocker 创建数据库任务锁
+func NewDBLocker(db *gorm.DB) *DBLocker {
+	return &DBLocker{db: db}
+}
+
+// TryLock 锁不存在或已过期时抢占，并记录本次执行时间
+func (l *DBLocker) TryLock(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	result := l.db.WithContext(ctx).Exec(`
+		INSERT INTO scheduler_locks (name, holder, locked_until, last_run_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP + make_interval(secs => ?), CURRENT_TIMESTAMP)
+		ON CONFLICT (name) DO UPDATE
+		SET holder = EXCLUDED.holder, locked_until = EXCLUDED.locked_until, last_run_at = EXCLUDED.last_run_at
+		WHERE scheduler_locks.locked_until <= CURRENT_TIMESTAMP`,
+		name, holder, ttl.Seconds())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
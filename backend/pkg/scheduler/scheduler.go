@@ -0,0 +1,171 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"master-guide-backend/pkg/logger"
+)
+
+// Job 周期任务
+type Job struct {
+	Name     string        // 任务名称，同时作为分布式锁的键
+	Interval time.Duration // 执行间隔
+	Timeout  time.Duration // 单次执行超时，为空时等于执行间隔
+	Run      func(ctx context.Context) error
+}
+
+// Locker 分布式任务锁，保证多副本部署时同一任务在一个间隔内只由一个实例执行
+type Locker interface {
+	// TryLock 尝试以 holder 身份持有名为 name 的锁 ttl 时长，锁被其他实例持有且未过期时返回 false
+	TryLock(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+}
+
+// Scheduler 周期任务调度器
+type Scheduler struct {
+	locker Locker
+	holder string
+	jobs   []Job
+
+	mu      sync.Mutex
+	started bool
+	stop    chan struct{}
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// New 创建调度器，locker 为空时不加锁，仅适用于单实例部署
+func New(locker Locker) *Scheduler {
+	return &Scheduler{
+		locker: locker,
+		holder: newHolderID(),
+		stop:   make(chan struct{}),
+	}
+}
+
+// Register 注册周期任务，需在 Start 之前调用
+func (s *Scheduler) Register(job Job) {
+	if job.Name == "" || job.Run == nil || job.Interval <= 0 {
+		logger.Warn("忽略无效的定时任务", logger.String("job", job.Name))
+		return
+	}
+	if job.Timeout <= 0 || job.Timeout > job.Interval {
+		job.Timeout = job.Interval
+	}
+	s.jobs = append(s.jobs, job)
+}
+
+// Start 启动所有已注册任务
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return
+	}
+	s.started = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(ctx, job)
+	}
+	if len(s.jobs) > 0 {
+		logger.Info("定时任务调度器已启动", logger.Int("jobs", len(s.jobs)), logger.String("holder", s.holder))
+	}
+}
+
+// Stop 停止调度并等待正在执行的任务结束，ctx 到期后中断仍在执行的任务
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.started {
+		s.mu.Unlock()
+		return nil
+	}
+	s.started = false
+	close(s.stop)
+	cancel := s.cancel
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		cancel()
+		return nil
+	case <-ctx.Done():
+		cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// loop 按间隔执行单个任务，直到调度器停止
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.runOnce(ctx, job)
+		}
+	}
+}
+
+// runOnce 获取任务锁后执行一次任务
+// 锁持有至本次执行开始后的一个间隔，其他实例在此期间跳过该任务；任务结束不主动释放，避免同一间隔内被重复执行
+func (s *Scheduler) runOnce(ctx context.Context, job Job) {
+	if s.locker != nil {
+		// 预留一秒余量，避免与下一次触发时间相同导致本实例也无法续锁
+		ttl := job.Interval - time.Second
+		if ttl <= 0 {
+			ttl = job.Interval
+		}
+		ok, err := s.locker.TryLock(ctx, job.Name, s.holder, ttl)
+		if err != nil {
+			logger.Warn("获取定时任务锁失败", logger.String("job", job.Name), logger.String("error", err.Error()))
+			return
+		}
+		if !ok {
+			return
+		}
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, job.Timeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("定时任务异常退出", logger.String("job", job.Name), logger.Any("panic", r))
+		}
+	}()
+
+	start := time.Now()
+	if err := job.Run(runCtx); err != nil {
+		logger.Error("定时任务执行失败", logger.String("job", job.Name), logger.String("error", err.Error()), logger.String("duration", time.Since(start).String()))
+		return
+	}
+	logger.Debug("定时任务执行完成", logger.String("job", job.Name), logger.String("duration", time.Since(start).String()))
+}
+
+// newHolderID 生成当前实例的锁持有者标识
+func newHolderID() string {
+	host, _ := os.Hostname()
+	buf := make([]byte, 4)
+	_, _ = rand.Read(buf)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(buf))
+}
@@ -0,0 +1,101 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"master-guide-backend/pkg/logger"
+)
+
+// 发送渠道
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+)
+
+// Message 待发送的消息
+type Message struct {
+	Channel string `json:"channel"`
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Content string `json:"content"`
+}
+
+// Sender 邮件/短信发送接口，生产环境可接入具体服务商实现
+type Sender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Config 发送器配置
+type Config struct {
+	Driver   string // log, file
+	FilePath string
+}
+
+// New 根据配置创建发送器，未知驱动回退为日志发送器
+func New(config *Config) Sender {
+	if config != nil && config.Driver == "file" && config.FilePath != "" {
+		return NewFileSender(config.FilePath)
+	}
+	return NewLogSender()
+}
+
+// LogSender 将消息写入应用日志，仅用于本地开发
+type LogSender struct{}
+
+// NewLogSender 创建日志发送器
+func NewLogSender() *LogSender {
+	return &LogSender{}
+}
+
+// Send 发送消息
+func (s *LogSender) Send(ctx context.Context, msg *Message) error {
+	logger.Info("模拟发送消息",
+		logger.String("channel", msg.Channel),
+		logger.String("to", msg.To),
+		logger.String("subject", msg.Subject),
+		logger.String("content", msg.Content),
+	)
+	return nil
+}
+
+// FileSender 将消息以JSON行追加写入本地文件，便于开发和联调时查看验证码
+type FileSender struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileSender 创建文件发送器
+func NewFileSender(path string) *FileSender {
+	return &FileSender{path: path}
+}
+
+// Send 发送消息
+func (s *FileSender) Send(ctx context.Context, msg *Message) error {
+	line, err := json.Marshal(struct {
+		*Message
+		SentAt time.Time `json:"sent_at"`
+	}{Message: msg, SentAt: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
@@ -134,6 +134,9 @@ CREATE TABLE mentors (
     is_online BOOLEAN DEFAULT FALSE,
     experience_years INTEGER DEFAULT 0,
     status VARCHAR(20) DEFAULT 'active' CHECK (status IN ('active', 'inactive', 'suspended')),
+    timezone VARCHAR(64) NOT NULL DEFAULT 'Asia/Shanghai', -- 可预约时间所用时区
+    buffer_minutes INTEGER NOT NULL DEFAULT 0 CHECK (buffer_minutes >= 0), -- 两次预约之间的间隔（分钟）
+    tier VARCHAR(20) NOT NULL DEFAULT 'standard' CHECK (tier IN ('standard', 'senior', 'expert')), -- 大师等级，用于匹配费率规则
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
@@ -204,7 +207,7 @@ CREATE TABLE learning_records (
     user_id VARCHAR(32) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
     course_id VARCHAR(32) NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
     progress_percentage DECIMAL(5,2) DEFAULT 0,
-    status VARCHAR(20) DEFAULT 'enrolled' CHECK (status IN ('enrolled', 'learning', 'completed', 'dropped', 'paused')),
+    status VARCHAR(20) DEFAULT 'enrolled' CHECK (status IN ('pending_payment', 'enrolled', 'learning', 'completed', 'dropped', 'paused', 'cancelled')),
     enrolled_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     completed_at TIMESTAMP,
     last_accessed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
@@ -251,9 +254,12 @@ CREATE TABLE appointments (
     appointment_time TIMESTAMP NOT NULL,
     duration_minutes INTEGER NOT NULL,
     meeting_type VARCHAR(20) DEFAULT 'video' CHECK (meeting_type IN ('video', 'voice', 'text')),
-    status VARCHAR(20) DEFAULT 'pending' CHECK (status IN ('pending', 'confirmed', 'completed', 'cancelled')),
+    status VARCHAR(20) DEFAULT 'pending' CHECK (status IN ('pending_payment', 'pending', 'confirmed', 'in_progress', 'completed', 'rejected', 'cancelled', 'no_show')),
     price DECIMAL(10,2) NOT NULL,
     notes TEXT,
+    package_purchase_id VARCHAR(32), -- 使用套餐预约时扣减次数的套餐，外键见咨询套餐相关表
+    package_credit_returned BOOLEAN NOT NULL DEFAULT FALSE, -- 取消后已退回套餐次数
+    series_id VARCHAR(32), -- 重复预约系列
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
@@ -263,7 +269,7 @@ CREATE TABLE income_transactions (
     id VARCHAR(32) PRIMARY KEY DEFAULT generate_table_id('INCOME_', 'income_transaction_id_num_seq'),
     mentor_id VARCHAR(32) NOT NULL REFERENCES mentors(id) ON DELETE CASCADE,
     student_id VARCHAR(32) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-    transaction_type VARCHAR(20) NOT NULL CHECK (transaction_type IN ('course_enrollment', 'appointment', 'refund')),
+    transaction_type VARCHAR(20) NOT NULL CHECK (transaction_type IN ('course_enrollment', 'appointment', 'appointment_package', 'refund')),
     amount DECIMAL(10,2) NOT NULL,
     platform_fee DECIMAL(10,2) NOT NULL DEFAULT 0,
     net_income DECIMAL(10,2) NOT NULL,
@@ -271,6 +277,9 @@ CREATE TABLE income_transactions (
     description TEXT,
     course_id VARCHAR(32) REFERENCES courses(id) ON DELETE SET NULL,
     appointment_id VARCHAR(32) REFERENCES appointments(id) ON DELETE SET NULL,
+    payment_order_id VARCHAR(32) UNIQUE, -- 来源支付订单，保证每笔支付只入账一次
+    refund_id VARCHAR(32) UNIQUE, -- 退款冲正对应的退款单，保证每笔退款只冲正一次
+    source_transaction_id VARCHAR(32) REFERENCES income_transactions(id) ON DELETE CASCADE, -- 退款冲正对应的原收入记录
     completed_at TIMESTAMP,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
@@ -283,9 +292,15 @@ CREATE TABLE withdrawals (
     amount DECIMAL(10,2) NOT NULL,
     fee DECIMAL(10,2) NOT NULL DEFAULT 0,
     net_amount DECIMAL(10,2) NOT NULL,
-    status VARCHAR(20) DEFAULT 'pending' CHECK (status IN ('pending', 'completed', 'failed')),
-    bank_account VARCHAR(50) NOT NULL,
+    status VARCHAR(20) DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected', 'processing', 'completed', 'failed')),
+    bank_account TEXT NOT NULL, -- AES-GCM 加密后的银行账号
+    bank_account_masked VARCHAR(50) NOT NULL, -- 脱敏银行账号，仅保留后4位
     bank_name VARCHAR(100) NOT NULL,
+    reviewed_by VARCHAR(32) REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP,
+    reject_reason TEXT,
+    payout_reference VARCHAR(100), -- 银行打款流水号
+    failure_reason TEXT,
     completed_at TIMESTAMP,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
@@ -396,6 +411,8 @@ CREATE TABLE messages (
     content TEXT NOT NULL,
     message_type VARCHAR(20) DEFAULT 'text' CHECK (message_type IN ('text', 'image', 'file', 'system')),
     is_read BOOLEAN DEFAULT FALSE,
+    delivered_at TIMESTAMP, -- 私聊消息送达接收方的时间，圈子消息不记录
+    read_at TIMESTAMP, -- 私聊消息被接收方阅读的时间，圈子消息按成员已读位置记录
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
@@ -516,6 +533,7 @@ CREATE INDEX idx_income_transactions_status ON income_transactions(status);
 CREATE INDEX idx_income_transactions_created_at ON income_transactions(created_at);
 CREATE INDEX idx_income_transactions_course_id ON income_transactions(course_id);
 CREATE INDEX idx_income_transactions_appointment_id ON income_transactions(appointment_id);
+CREATE INDEX idx_income_transactions_source_transaction_id ON income_transactions(source_transaction_id);
 
 -- 提现索引
 CREATE INDEX idx_withdrawals_mentor_id ON withdrawals(mentor_id);
@@ -561,6 +579,8 @@ CREATE INDEX idx_appointments_mentor_id ON appointments(mentor_id);
 CREATE INDEX idx_appointments_appointment_time ON appointments(appointment_time);
 CREATE INDEX idx_appointments_status ON appointments(status);
 CREATE INDEX idx_appointments_meeting_type ON appointments(meeting_type);
+CREATE INDEX idx_appointments_mentor_time ON appointments(mentor_id, appointment_time);
+CREATE INDEX idx_appointments_student_time ON appointments(student_id, appointment_time);
 
 -- 评价表索引
 CREATE INDEX idx_reviews_reviewer_id ON reviews(reviewer_id);
@@ -577,6 +597,8 @@ CREATE INDEX idx_messages_to_user_id ON messages(to_user_id);
 CREATE INDEX idx_messages_circle_id ON messages(circle_id);
 CREATE INDEX idx_messages_is_read ON messages(is_read);
 CREATE INDEX idx_messages_created_at ON messages(created_at);
+CREATE INDEX idx_messages_unread ON messages(to_user_id, from_user_id) WHERE is_read = FALSE;
+CREATE INDEX idx_messages_circle_created_at ON messages(circle_id, created_at);
 
 -- 通知表索引
 CREATE INDEX idx_notifications_user_id ON notifications(user_id);
@@ -745,8 +767,9 @@ INSERT INTO system_configs (config_key, config_value, description) VALUES
 -- 支付订单表
 CREATE TABLE payment_orders (
     id VARCHAR(32) PRIMARY KEY DEFAULT generate_table_id('PAYORDER_', 'payment_order_id_num_seq'),
-    order_type VARCHAR(32) NOT NULL CHECK (order_type IN ('course_enrollment', 'appointment', 'refund')),
-    order_ref_id VARCHAR(32) NOT NULL, -- 业务订单ID，如课程报名ID、预约ID等
+    user_id VARCHAR(32) NOT NULL REFERENCES users(id), -- 下单用户ID，订单和退款状态仅下单用户和管理员可查询
+    order_type VARCHAR(32) NOT NULL CHECK (order_type IN ('course_enrollment', 'appointment', 'appointment_package', 'refund')),
+    order_ref_id VARCHAR(32) NOT NULL, -- 业务订单ID，如课程报名ID、预约ID、已购买套餐ID等
     amount DECIMAL(10,2) NOT NULL,
     currency VARCHAR(10) NOT NULL DEFAULT 'CNY',
     payment_method VARCHAR(32) NOT NULL,
@@ -756,6 +779,8 @@ CREATE TABLE payment_orders (
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     expires_at TIMESTAMP,
+    fulfilled_at TIMESTAMP, -- 支付完成后开通课程或确认预约的时间
+    fulfillment_failed_at TIMESTAMP, -- 业务订单已无法开通的时间，此类订单全额退款
     UNIQUE(order_type, order_ref_id)
 );
 
@@ -785,7 +810,10 @@ CREATE TABLE payment_refunds (
     description TEXT,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     completed_at TIMESTAMP,
-    refund_transaction_id VARCHAR(64)
+    refund_transaction_id VARCHAR(64),
+    automatic BOOLEAN NOT NULL DEFAULT FALSE, -- 按退款策略或订单无法履约由系统自动发起
+    attempts INTEGER NOT NULL DEFAULT 0, -- 自动退款向网关申请失败的次数
+    next_retry_at TIMESTAMP -- 自动退款申请失败后的下次重试时间，为空表示不再自动重试
 );
 
 -- 支付方式表
@@ -799,15 +827,25 @@ CREATE TABLE payment_methods (
 );
 
 -- 支付相关索引
+CREATE INDEX idx_payment_orders_user_id ON payment_orders(user_id);
 CREATE INDEX idx_payment_orders_status ON payment_orders(status);
 CREATE INDEX idx_payment_orders_created_at ON payment_orders(created_at);
+CREATE INDEX idx_payment_orders_pending_expires_at ON payment_orders(expires_at) WHERE status = 'pending';
 CREATE INDEX idx_payment_records_order_id ON payment_records(order_id);
 CREATE INDEX idx_payment_records_status ON payment_records(status);
 CREATE INDEX idx_payment_records_paid_at ON payment_records(paid_at);
 CREATE INDEX idx_payment_refunds_payment_id ON payment_refunds(payment_id);
 CREATE INDEX idx_payment_refunds_status ON payment_refunds(status);
+CREATE INDEX idx_payment_refunds_retry ON payment_refunds(next_retry_at) WHERE status = 'failed' AND next_retry_at IS NOT NULL;
 CREATE INDEX idx_payment_methods_enabled ON payment_methods(enabled);
 
+-- 默认支付方式，沙箱支付仅用于联调，默认停用，联调时执行 UPDATE payment_methods SET enabled = TRUE WHERE id = 'sandbox' 启用
+INSERT INTO payment_methods (id, name, icon, enabled, min_amount, max_amount) VALUES
+    ('alipay', '支付宝', NULL, TRUE, 0.01, 100000.00),
+    ('wechat', '微信支付', NULL, TRUE, 0.01, 100000.00),
+    ('sandbox', '沙箱支付', NULL, FALSE, 0.01, 100000.00)
+ON CONFLICT (id) DO NOTHING;
+
 -- 支付相关序列
 CREATE SEQUENCE IF NOT EXISTS payment_order_id_num_seq INCREMENT BY 1 START 1 MINVALUE 1 MAXVALUE 99999999999 CACHE 1;
 CREATE SEQUENCE IF NOT EXISTS payment_record_id_num_seq INCREMENT BY 1 START 1 MINVALUE 1 MAXVALUE 99999999999 CACHE 1;
@@ -826,7 +864,7 @@ CREATE TABLE upload_files (
     file_size BIGINT NOT NULL,
     mime_type VARCHAR(100),
     user_id VARCHAR(32) REFERENCES users(id) ON DELETE CASCADE,
-    upload_type VARCHAR(50) NOT NULL CHECK (upload_type IN ('avatar', 'course_cover', 'post_image')),
+    upload_type VARCHAR(50) NOT NULL CHECK (upload_type IN ('avatar', 'course_cover', 'post_image', 'income_report')),
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
@@ -840,6 +878,561 @@ CREATE INDEX idx_upload_files_file_type ON upload_files(file_type);
 -- 文件上传触发器
 CREATE TRIGGER update_upload_files_updated_at BEFORE UPDATE ON upload_files FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
+-- 登录会话相关序列
+CREATE SEQUENCE IF NOT EXISTS auth_session_id_num_seq INCREMENT BY 1 START 1 MINVALUE 1 MAXVALUE 99999999999 CACHE 1;
+CREATE SEQUENCE IF NOT EXISTS refresh_token_id_num_seq INCREMENT BY 1 START 1 MINVALUE 1 MAXVALUE 99999999999 CACHE 1;
+
+-- 登录会话表
+CREATE TABLE auth_sessions (
+    id VARCHAR(32) PRIMARY KEY DEFAULT generate_table_id('AUTHSESS_', 'auth_session_id_num_seq'),
+    user_id VARCHAR(32) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    identity_id VARCHAR(32) NOT NULL REFERENCES user_identities(id) ON DELETE CASCADE,
+    user_agent TEXT,
+    ip_address VARCHAR(64),
+    last_used_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    revoke_reason VARCHAR(50),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 刷新令牌表（仅保存令牌哈希）
+CREATE TABLE refresh_tokens (
+    id VARCHAR(32) PRIMARY KEY DEFAULT generate_table_id('REFRESH_', 'refresh_token_id_num_seq'),
+    session_id VARCHAR(32) NOT NULL REFERENCES auth_sessions(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 登录会话相关索引
+CREATE INDEX idx_auth_sessions_user_id ON auth_sessions(user_id);
+CREATE INDEX idx_auth_sessions_expires_at ON auth_sessions(expires_at);
+CREATE INDEX idx_refresh_tokens_session_id ON refresh_tokens(session_id);
+
+-- 登录会话触发器
+CREATE TRIGGER update_auth_sessions_updated_at BEFORE UPDATE ON auth_sessions FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_refresh_tokens_updated_at BEFORE UPDATE ON refresh_tokens FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- 密码重置相关序列
+CREATE SEQUENCE IF NOT EXISTS password_reset_token_id_num_seq INCREMENT BY 1 START 1 MINVALUE 1 MAXVALUE 99999999999 CACHE 1;
+
+-- 密码重置令牌表（仅保存令牌哈希）
+CREATE TABLE password_reset_tokens (
+    id VARCHAR(32) PRIMARY KEY DEFAULT generate_table_id('PWRESET_', 'password_reset_token_id_num_seq'),
+    user_id VARCHAR(32) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    request_ip VARCHAR(64),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 密码重置相关索引
+CREATE INDEX idx_password_reset_tokens_user_id ON password_reset_tokens(user_id);
+CREATE INDEX idx_password_reset_tokens_expires_at ON password_reset_tokens(expires_at);
+
+-- 密码重置触发器
+CREATE TRIGGER update_password_reset_tokens_updated_at BEFORE UPDATE ON password_reset_tokens FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- 支付回调收件箱相关序列
+CREATE SEQUENCE IF NOT EXISTS payment_webhook_event_id_num_seq INCREMENT BY 1 START 1 MINVALUE 1 MAXVALUE 99999999999 CACHE 1;
+
+-- 支付回调收件箱表（按网关+去重键保证同一回调只处理一次）
+CREATE TABLE payment_webhook_events (
+    id VARCHAR(32) PRIMARY KEY DEFAULT generate_table_id('PAYEVT_', 'payment_webhook_event_id_num_seq'),
+    gateway VARCHAR(32) NOT NULL,
+    dedupe_key VARCHAR(200) NOT NULL, -- 默认为 事件类型:交易号[:退款单号]:状态，无交易号时使用网关通知ID
+    event_id VARCHAR(128),
+    event_type VARCHAR(20) NOT NULL CHECK (event_type IN ('payment', 'refund')),
+    order_id VARCHAR(32),
+    refund_id VARCHAR(32),
+    transaction_id VARCHAR(64),
+    event_status VARCHAR(20) NOT NULL,
+    amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+    currency VARCHAR(10),
+    payload TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'received' CHECK (status IN ('received', 'processed', 'ignored', 'rejected', 'failed')),
+    last_error TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    occurred_at TIMESTAMP,
+    processed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(gateway, dedupe_key)
+);
+
+-- 支付回调收件箱相关索引
+CREATE INDEX idx_payment_webhook_events_status ON payment_webhook_events(status);
+CREATE INDEX idx_payment_webhook_events_order_id ON payment_webhook_events(order_id);
+CREATE INDEX idx_payment_webhook_events_created_at ON payment_webhook_events(created_at);
+
+-- 支付回调收件箱触发器
+CREATE TRIGGER update_payment_webhook_events_updated_at BEFORE UPDATE ON payment_webhook_events FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- 定时任务锁表（多副本部署时保证同一任务在一个执行间隔内只由一个实例执行）
+CREATE TABLE scheduler_locks (
+    name VARCHAR(64) PRIMARY KEY, -- 任务名称
+    holder VARCHAR(128) NOT NULL, -- 持有锁的实例标识
+    locked_until TIMESTAMP NOT NULL, -- 锁过期时间
+    last_run_at TIMESTAMP, -- 最近一次执行时间
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 定时任务锁触发器
+CREATE TRIGGER update_scheduler_locks_updated_at BEFORE UPDATE ON scheduler_locks FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- 大师可预约时间ID序列
+CREATE SEQUENCE IF NOT EXISTS mentor_availability_rule_id_num_seq INCREMENT BY 1 START 1 MINVALUE 1 MAXVALUE 99999999999 CACHE 1;
+CREATE SEQUENCE IF NOT EXISTS mentor_availability_exception_id_num_seq INCREMENT BY 1 START 1 MINVALUE 1 MAXVALUE 99999999999 CACHE 1;
+
+-- 大师每周可预约时段表（时间为大师所在时区的本地时间）
+CREATE TABLE mentor_availability_rules (
+    id VARCHAR(32) PRIMARY KEY DEFAULT generate_table_id('AVAILRULE_', 'mentor_availability_rule_id_num_seq'),
+    mentor_id VARCHAR(32) NOT NULL REFERENCES mentors(id) ON DELETE CASCADE,
+    weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6), -- 0 表示周日
+    start_time VARCHAR(5) NOT NULL, -- HH:MM
+    end_time VARCHAR(5) NOT NULL, -- HH:MM，24:00 表示当天结束
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK (start_time < end_time)
+);
+
+-- 大师可预约时间例外表（请假或临时加开时段）
+CREATE TABLE mentor_availability_exceptions (
+    id VARCHAR(32) PRIMARY KEY DEFAULT generate_table_id('AVAILEXC_', 'mentor_availability_exception_id_num_seq'),
+    mentor_id VARCHAR(32) NOT NULL REFERENCES mentors(id) ON DELETE CASCADE,
+    date DATE NOT NULL, -- 大师所在时区的日期
+    start_time VARCHAR(5), -- 为空表示全天
+    end_time VARCHAR(5),
+    available BOOLEAN NOT NULL DEFAULT FALSE, -- TRUE 为加开时段，FALSE 为不可预约
+    reason VARCHAR(200),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 大师可预约时间相关索引
+CREATE INDEX idx_mentor_availability_rules_mentor_id ON mentor_availability_rules(mentor_id, weekday);
+CREATE INDEX idx_mentor_availability_exceptions_mentor_date ON mentor_availability_exceptions(mentor_id, date);
+
+-- 大师可预约时间触发器
+CREATE TRIGGER update_mentor_availability_rules_updated_at BEFORE UPDATE ON mentor_availability_rules FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_mentor_availability_exceptions_updated_at BEFORE UPDATE ON mentor_availability_exceptions FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- 预约状态变更记录ID序列
+CREATE SEQUENCE IF NOT EXISTS appointment_status_history_id_num_seq INCREMENT BY 1 START 1 MINVALUE 1 MAXVALUE 99999999999 CACHE 1;
+
+-- 预约状态变更记录表
+CREATE TABLE appointment_status_history (
+    id VARCHAR(32) PRIMARY KEY DEFAULT generate_table_id('APPTHIST_', 'appointment_status_history_id_num_seq'),
+    appointment_id VARCHAR(32) NOT NULL REFERENCES appointments(id) ON DELETE CASCADE,
+    from_status VARCHAR(20), -- 创建预约时为空
+    to_status VARCHAR(20) NOT NULL,
+    actor_type VARCHAR(20) NOT NULL CHECK (actor_type IN ('student', 'mentor', 'system')),
+    actor_id VARCHAR(32), -- 操作用户ID，系统操作时为空
+    reason TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 预约状态变更记录索引
+CREATE INDEX idx_appointment_status_history_appointment_id ON appointment_status_history(appointment_id, created_at);
+
+-- 日历订阅和预约提醒ID序列
+CREATE SEQUENCE IF NOT EXISTS calendar_feed_id_num_seq INCREMENT BY 1 START 1 MINVALUE 1 MAXVALUE 99999999999 CACHE 1;
+CREATE SEQUENCE IF NOT EXISTS appointment_reminder_id_num_seq INCREMENT BY 1 START 1 MINVALUE 1 MAXVALUE 99999999999 CACHE 1;
+
+-- 日历订阅表（每个用户一个订阅令牌，重新生成时替换）
+CREATE TABLE calendar_feeds (
+    id VARCHAR(32) PRIMARY KEY DEFAULT generate_table_id('CALFEED_', 'calendar_feed_id_num_seq'),
+    user_id VARCHAR(32) NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE, -- 订阅令牌的 SHA-256 摘要
+    last_accessed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 预约提醒发送记录表（每个预约的每个提醒时间点只发送一次）
+CREATE TABLE appointment_reminders (
+    id VARCHAR(32) PRIMARY KEY DEFAULT generate_table_id('APPTREM_', 'appointment_reminder_id_num_seq'),
+    appointment_id VARCHAR(32) NOT NULL REFERENCES appointments(id) ON DELETE CASCADE,
+    offset_minutes INTEGER NOT NULL CHECK (offset_minutes > 0), -- 预约开始前多少分钟
+    sent_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (appointment_id, offset_minutes)
+);
+
+-- 日历订阅触发器
+CREATE TRIGGER update_calendar_feeds_updated_at BEFORE UPDATE ON calendar_feeds FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- 会议室和会议参与方ID序列
+CREATE SEQUENCE IF NOT EXISTS meeting_room_id_num_seq INCREMENT BY 1 START 1 MINVALUE 1 MAXVALUE 99999999999 CACHE 1;
+CREATE SEQUENCE IF NOT EXISTS meeting_participant_id_num_seq INCREMENT BY 1 START 1 MINVALUE 1 MAXVALUE 99999999999 CACHE 1;
+
+-- 会议室表（视频咨询预约确认后创建，每个预约一个）
+CREATE TABLE meeting_rooms (
+    id VARCHAR(32) PRIMARY KEY DEFAULT generate_table_id('MEETROOM_', 'meeting_room_id_num_seq'),
+    appointment_id VARCHAR(32) NOT NULL UNIQUE REFERENCES appointments(id) ON DELETE CASCADE,
+    provider VARCHAR(32) NOT NULL, -- 会议服务，如 selfhosted
+    provider_room_id VARCHAR(128) NOT NULL, -- 会议服务侧的会议室ID
+    signaling_url VARCHAR(500),
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'closed')),
+    join_opens_at TIMESTAMP NOT NULL,
+    join_closes_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (provider, provider_room_id)
+);
+
+-- 会议参与方表（入会地址和凭证）
+CREATE TABLE meeting_participants (
+    id VARCHAR(32) PRIMARY KEY DEFAULT generate_table_id('MEETPART_', 'meeting_participant_id_num_seq'),
+    room_id VARCHAR(32) NOT NULL REFERENCES meeting_rooms(id) ON DELETE CASCADE,
+    user_id VARCHAR(32) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('host', 'guest')),
+    join_url TEXT NOT NULL,
+    token TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (room_id, user_id)
+);
+
+-- 会议室触发器
+CREATE TRIGGER update_meeting_rooms_updated_at BEFORE UPDATE ON meeting_rooms FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- 咨询套餐相关ID序列
+CREATE SEQUENCE IF NOT EXISTS appointment_package_id_num_seq INCREMENT BY 1 START 1 MINVALUE 1 MAXVALUE 99999999999 CACHE 1;
+CREATE SEQUENCE IF NOT EXISTS appointment_package_purchase_id_num_seq INCREMENT BY 1 START 1 MINVALUE 1 MAXVALUE 99999999999 CACHE 1;
+CREATE SEQUENCE IF NOT EXISTS appointment_series_id_num_seq INCREMENT BY 1 START 1 MINVALUE 1 MAXVALUE 99999999999 CACHE 1;
+
+-- 咨询套餐表（大师发布）
+CREATE TABLE appointment_packages (
+    id VARCHAR(32) PRIMARY KEY DEFAULT generate_table_id('APPTPKG_', 'appointment_package_id_num_seq'),
+    mentor_id VARCHAR(32) NOT NULL REFERENCES mentors(id) ON DELETE CASCADE,
+    title VARCHAR(200) NOT NULL,
+    description TEXT,
+    session_count INTEGER NOT NULL CHECK (session_count > 0),
+    duration_minutes INTEGER NOT NULL CHECK (duration_minutes > 0),
+    discount_rate DECIMAL(4,3) NOT NULL DEFAULT 0 CHECK (discount_rate >= 0 AND discount_rate < 1), -- 0.1 表示按原价九折
+    validity_days INTEGER NOT NULL CHECK (validity_days > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'inactive')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 已购买套餐表（记录购买时的次数、时长、价格和有效期）
+CREATE TABLE appointment_package_purchases (
+    id VARCHAR(32) PRIMARY KEY DEFAULT generate_table_id('PKGBUY_', 'appointment_package_purchase_id_num_seq'),
+    package_id VARCHAR(32) NOT NULL REFERENCES appointment_packages(id) ON DELETE RESTRICT,
+    mentor_id VARCHAR(32) NOT NULL REFERENCES mentors(id) ON DELETE CASCADE,
+    student_id VARCHAR(32) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    title VARCHAR(200) NOT NULL,
+    total_sessions INTEGER NOT NULL CHECK (total_sessions > 0),
+    used_sessions INTEGER NOT NULL DEFAULT 0,
+    revoked_sessions INTEGER NOT NULL DEFAULT 0, -- 退款后按退款比例收回的次数
+    duration_minutes INTEGER NOT NULL,
+    validity_days INTEGER NOT NULL,
+    price DECIMAL(10,2) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending_payment' CHECK (status IN ('pending_payment', 'active', 'cancelled', 'refunded')),
+    activated_at TIMESTAMP,
+    expires_at TIMESTAMP, -- 支付完成后按有效天数计算
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK (used_sessions >= 0 AND used_sessions <= total_sessions),
+    CHECK (revoked_sessions >= 0 AND revoked_sessions <= total_sessions)
+);
+
+-- 重复预约系列表
+CREATE TABLE appointment_series (
+    id VARCHAR(32) PRIMARY KEY DEFAULT generate_table_id('APPTSER_', 'appointment_series_id_num_seq'),
+    student_id VARCHAR(32) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    mentor_id VARCHAR(32) NOT NULL REFERENCES mentors(id) ON DELETE CASCADE,
+    package_purchase_id VARCHAR(32) NOT NULL REFERENCES appointment_package_purchases(id) ON DELETE CASCADE,
+    rule VARCHAR(200) NOT NULL, -- RRULE 格式，如 FREQ=WEEKLY;INTERVAL=1;BYDAY=MO,WE;COUNT=10
+    timezone VARCHAR(64) NOT NULL, -- 重复规则按大师时区展开
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 预约关联套餐和重复预约系列
+ALTER TABLE appointments ADD CONSTRAINT fk_appointments_package_purchase FOREIGN KEY (package_purchase_id) REFERENCES appointment_package_purchases(id) ON DELETE SET NULL;
+ALTER TABLE appointments ADD CONSTRAINT fk_appointments_series FOREIGN KEY (series_id) REFERENCES appointment_series(id) ON DELETE SET NULL;
+
+-- 咨询套餐相关索引
+CREATE INDEX idx_appointment_packages_mentor_id ON appointment_packages(mentor_id, status);
+CREATE INDEX idx_appointment_package_purchases_student_id ON appointment_package_purchases(student_id, status);
+CREATE INDEX idx_appointments_package_purchase_id ON appointments(package_purchase_id);
+CREATE INDEX idx_appointments_series_id ON appointments(series_id);
+
+-- 咨询套餐触发器
+CREATE TRIGGER update_appointment_packages_updated_at BEFORE UPDATE ON appointment_packages FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_appointment_package_purchases_updated_at BEFORE UPDATE ON appointment_package_purchases FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- 复式记账相关ID序列
+CREATE SEQUENCE IF NOT EXISTS ledger_account_id_num_seq INCREMENT BY 1 START 1 MINVALUE 1 MAXVALUE 99999999999 CACHE 1;
+CREATE SEQUENCE IF NOT EXISTS ledger_transaction_id_num_seq INCREMENT BY 1 START 1 MINVALUE 1 MAXVALUE 99999999999 CACHE 1;
+CREATE SEQUENCE IF NOT EXISTS ledger_entry_id_num_seq INCREMENT BY 1 START 1 MINVALUE 1 MAXVALUE 99999999999 CACHE 1;
+
+-- 记账账户表（平台清算、学生预付款、平台服务费、大师应付款、大师提现在途）
+CREATE TABLE ledger_accounts (
+    id VARCHAR(32) PRIMARY KEY DEFAULT generate_table_id('LEDACC_', 'ledger_account_id_num_seq'),
+    code VARCHAR(100) NOT NULL UNIQUE, -- 如 platform:gateway_clearing、mentor:<mentor_id>:payable
+    name VARCHAR(100) NOT NULL,
+    account_type VARCHAR(20) NOT NULL CHECK (account_type IN ('asset', 'liability', 'revenue')),
+    normal_balance VARCHAR(10) NOT NULL CHECK (normal_balance IN ('debit', 'credit')),
+    owner_type VARCHAR(20) NOT NULL CHECK (owner_type IN ('platform', 'mentor')),
+    owner_id VARCHAR(32) REFERENCES mentors(id) ON DELETE RESTRICT,
+    currency VARCHAR(10) NOT NULL DEFAULT 'CNY',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 记账凭证表（每个资金业务事件一笔，按幂等键只记账一次）
+CREATE TABLE ledger_transactions (
+    id VARCHAR(32) PRIMARY KEY DEFAULT generate_table_id('LEDTX_', 'ledger_transaction_id_num_seq'),
+    idempotency_key VARCHAR(100) NOT NULL UNIQUE,
+    transaction_type VARCHAR(30) NOT NULL CHECK (transaction_type IN ('payment_received', 'order_fulfilled', 'income_reversed', 'refund_paid', 'withdrawal_requested', 'withdrawal_paid', 'withdrawal_failed')),
+    description TEXT,
+    currency VARCHAR(10) NOT NULL DEFAULT 'CNY',
+    payment_order_id VARCHAR(32) REFERENCES payment_orders(id) ON DELETE RESTRICT,
+    refund_id VARCHAR(32) REFERENCES payment_refunds(id) ON DELETE RESTRICT,
+    withdrawal_id VARCHAR(32) REFERENCES withdrawals(id) ON DELETE RESTRICT,
+    mentor_id VARCHAR(32) REFERENCES mentors(id) ON DELETE RESTRICT,
+    occurred_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 记账分录表（金额以分为单位，借记为正、贷记为负，只增不改）
+CREATE TABLE ledger_entries (
+    id VARCHAR(32) PRIMARY KEY DEFAULT generate_table_id('LEDENT_', 'ledger_entry_id_num_seq'),
+    transaction_id VARCHAR(32) NOT NULL REFERENCES ledger_transactions(id) ON DELETE RESTRICT,
+    account_id VARCHAR(32) NOT NULL REFERENCES ledger_accounts(id) ON DELETE RESTRICT,
+    amount BIGINT NOT NULL CHECK (amount <> 0),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 复式记账相关索引
+CREATE INDEX idx_ledger_accounts_owner ON ledger_accounts(owner_type, owner_id);
+CREATE INDEX idx_ledger_transactions_type ON ledger_transactions(transaction_type, occurred_at);
+CREATE INDEX idx_ledger_transactions_payment_order_id ON ledger_transactions(payment_order_id);
+CREATE INDEX idx_ledger_transactions_refund_id ON ledger_transactions(refund_id);
+CREATE INDEX idx_ledger_transactions_withdrawal_id ON ledger_transactions(withdrawal_id);
+CREATE INDEX idx_ledger_transactions_mentor_id ON ledger_transactions(mentor_id);
+CREATE INDEX idx_ledger_entries_transaction_id ON ledger_entries(transaction_id);
+CREATE INDEX idx_ledger_entries_account_id ON ledger_entries(account_id);
+
+-- 记账凭证借贷平衡校验，事务提交时检查
+CREATE OR REPLACE FUNCTION check_ledger_transaction_balanced()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF (SELECT COALESCE(SUM(amount), 0) FROM ledger_entries WHERE transaction_id = NEW.transaction_id) <> 0 THEN
+        RAISE EXCEPTION '记账凭证 % 借贷不平衡', NEW.transaction_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ language 'plpgsql';
+
+CREATE CONSTRAINT TRIGGER check_ledger_entries_balanced
+AFTER INSERT ON ledger_entries
+DEFERRABLE INITIALLY DEFERRED
+FOR EACH ROW EXECUTE FUNCTION check_ledger_transaction_balanced();
+
+-- 费率规则ID序列
+CREATE SEQUENCE IF NOT EXISTS fee_rule_id_num_seq INCREMENT BY 1 START 1 MINVALUE 1 MAXVALUE 99999999999 CACHE 1;
+
+-- 费率规则表（平台佣金和提现手续费，按订单类型、领域、大师等级和生效时间匹配）
+CREATE TABLE fee_rules (
+    id VARCHAR(32) PRIMARY KEY DEFAULT generate_table_id('FEERULE_', 'fee_rule_id_num_seq'),
+    name VARCHAR(100) NOT NULL,
+    fee_type VARCHAR(30) NOT NULL CHECK (fee_type IN ('platform_commission', 'withdrawal')),
+    order_type VARCHAR(30) CHECK (order_type IN ('course_enrollment', 'appointment', 'appointment_package')), -- 为空匹配全部订单类型，仅平台佣金使用
+    domain VARCHAR(50), -- 为空匹配全部领域
+    mentor_tier VARCHAR(20) CHECK (mentor_tier IN ('standard', 'senior', 'expert')), -- 为空匹配全部等级
+    rate DECIMAL(6,4) NOT NULL DEFAULT 0 CHECK (rate >= 0 AND rate <= 1),
+    fixed_fee DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (fixed_fee >= 0),
+    min_fee DECIMAL(10,2) CHECK (min_fee >= 0),
+    max_fee DECIMAL(10,2) CHECK (max_fee >= 0),
+    is_promotional BOOLEAN NOT NULL DEFAULT FALSE, -- 促销规则优先于普通规则
+    priority INTEGER NOT NULL DEFAULT 0,
+    effective_from TIMESTAMP NOT NULL,
+    effective_to TIMESTAMP,
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'inactive')),
+    description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK (effective_to IS NULL OR effective_to > effective_from),
+    CHECK (min_fee IS NULL OR max_fee IS NULL OR min_fee <= max_fee),
+    CHECK (fee_type = 'platform_commission' OR order_type IS NULL)
+);
+
+-- 收入和提现记录所用的费率规则
+ALTER TABLE income_transactions ADD COLUMN fee_rule_id VARCHAR(32) REFERENCES fee_rules(id) ON DELETE RESTRICT;
+ALTER TABLE withdrawals ADD COLUMN fee_rule_id VARCHAR(32) REFERENCES fee_rules(id) ON DELETE RESTRICT;
+
+-- 费率规则相关索引
+CREATE INDEX idx_fee_rules_effective ON fee_rules(fee_type, status, effective_from);
+CREATE INDEX idx_income_transactions_fee_rule_id ON income_transactions(fee_rule_id);
+CREATE INDEX idx_withdrawals_fee_rule_id ON withdrawals(fee_rule_id);
+
+-- 费率规则触发器
+CREATE TRIGGER update_fee_rules_updated_at BEFORE UPDATE ON fee_rules FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- 打款批次ID序列
+CREATE SEQUENCE IF NOT EXISTS payout_batch_id_num_seq INCREMENT BY 1 START 1 MINVALUE 1 MAXVALUE 99999999999 CACHE 1;
+
+-- 打款批次表（已审核通过的提现按批次导出给银行转账，再导入打款结果）
+CREATE TABLE payout_batches (
+    id VARCHAR(32) PRIMARY KEY DEFAULT generate_table_id('PAYOUTB_', 'payout_batch_id_num_seq'),
+    status VARCHAR(20) NOT NULL DEFAULT 'processing' CHECK (status IN ('processing', 'completed')),
+    withdrawal_count INTEGER NOT NULL DEFAULT 0,
+    total_amount DECIMAL(12,2) NOT NULL DEFAULT 0,
+    total_net_amount DECIMAL(12,2) NOT NULL DEFAULT 0,
+    succeeded_count INTEGER NOT NULL DEFAULT 0,
+    failed_count INTEGER NOT NULL DEFAULT 0,
+    created_by VARCHAR(32) REFERENCES users(id) ON DELETE SET NULL,
+    exported_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 提现所属打款批次
+ALTER TABLE withdrawals ADD COLUMN payout_batch_id VARCHAR(32) REFERENCES payout_batches(id) ON DELETE SET NULL;
+
+-- 打款批次相关索引
+CREATE INDEX idx_payout_batches_status ON payout_batches(status);
+CREATE INDEX idx_payout_batches_created_at ON payout_batches(created_at DESC);
+CREATE INDEX idx_withdrawals_payout_batch_id ON withdrawals(payout_batch_id);
+
+-- 打款批次触发器
+CREATE TRIGGER update_payout_batches_updated_at BEFORE UPDATE ON payout_batches FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- 收入结算状态：履约入账后冻结，服务完成并过结算期后转为可提现；退款冲正记录与其冲正时原收入的状态一致
+ALTER TABLE income_transactions ADD COLUMN settlement_status VARCHAR(20) NOT NULL DEFAULT 'frozen' CHECK (settlement_status IN ('frozen', 'settled'));
+ALTER TABLE income_transactions ADD COLUMN settled_at TIMESTAMP;
+
+-- 收入结算相关索引
+CREATE INDEX idx_income_transactions_frozen ON income_transactions(created_at) WHERE settlement_status = 'frozen' AND transaction_type <> 'refund';
+
+-- 收入报告相关序列
+CREATE SEQUENCE IF NOT EXISTS income_report_id_num_seq INCREMENT BY 1 START 1 MINVALUE 1 MAXVALUE 99999999999 CACHE 1;
+
+-- 收入报告表（区间较长的报告排队后台生成，文件记录在 upload_files 中，通过签名地址下载）
+CREATE TABLE income_reports (
+    id VARCHAR(32) PRIMARY KEY DEFAULT generate_table_id('INCRPT_', 'income_report_id_num_seq'),
+    mentor_id VARCHAR(32) NOT NULL REFERENCES mentors(id) ON DELETE CASCADE,
+    format VARCHAR(10) NOT NULL CHECK (format IN ('csv', 'excel', 'pdf')),
+    income_type VARCHAR(30) NOT NULL DEFAULT 'all',
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'processing', 'completed', 'failed', 'expired')),
+    upload_file_id VARCHAR(32) REFERENCES upload_files(id) ON DELETE SET NULL,
+    error_message TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    completed_at TIMESTAMP,
+    expires_at TIMESTAMP, -- 下载地址过期时间，过期后删除报告文件
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK (end_date >= start_date)
+);
+
+-- 收入报告相关索引
+CREATE INDEX idx_income_reports_mentor_id ON income_reports(mentor_id, created_at DESC);
+CREATE INDEX idx_income_reports_pending ON income_reports(created_at) WHERE status IN ('pending', 'processing');
+CREATE INDEX idx_income_reports_expires_at ON income_reports(expires_at) WHERE status = 'completed';
+
+-- 收入报告触发器
+CREATE TRIGGER update_income_reports_updated_at BEFORE UPDATE ON income_reports FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- 发票与收据相关ID序列
+CREATE SEQUENCE IF NOT EXISTS invoice_id_num_seq INCREMENT BY 1 START 1 MINVALUE 1 MAXVALUE 99999999999 CACHE 1;
+CREATE SEQUENCE IF NOT EXISTS invoice_item_id_num_seq INCREMENT BY 1 START 1 MINVALUE 1 MAXVALUE 99999999999 CACHE 1;
+
+-- 票据编号计数表（按票据系列和年度连续编号，在开具票据的事务中加锁递增，事务回滚时编号一并回滚，保证编号无断号）
+CREATE TABLE invoice_number_sequences (
+    series VARCHAR(10) NOT NULL,
+    year INTEGER NOT NULL,
+    last_number BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (series, year)
+);
+
+-- 票据表（学生服务收据、大师平台服务费账单及退款对应的红字票据，开具后不可修改或删除；买卖双方信息为开具时的快照）
+CREATE TABLE invoices (
+    id VARCHAR(32) PRIMARY KEY DEFAULT generate_table_id('INVOICE_', 'invoice_id_num_seq'),
+    invoice_number VARCHAR(30) NOT NULL UNIQUE, -- 如 RC-2026-000001
+    document_type VARCHAR(20) NOT NULL CHECK (document_type IN ('invoice', 'credit_note')),
+    category VARCHAR(20) NOT NULL CHECK (category IN ('service', 'platform_fee')), -- service 为学生购买服务的收据，platform_fee 为大师的平台服务费账单
+    income_transaction_id VARCHAR(32) NOT NULL REFERENCES income_transactions(id) ON DELETE RESTRICT,
+    payment_order_id VARCHAR(32) REFERENCES payment_orders(id) ON DELETE RESTRICT,
+    refund_id VARCHAR(32) REFERENCES payment_refunds(id) ON DELETE RESTRICT,
+    original_invoice_id VARCHAR(32) REFERENCES invoices(id) ON DELETE RESTRICT, -- 红字票据冲销的原票据
+    order_type VARCHAR(30) NOT NULL,
+    mentor_id VARCHAR(32) NOT NULL REFERENCES mentors(id) ON DELETE RESTRICT,
+    buyer_user_id VARCHAR(32) REFERENCES users(id) ON DELETE RESTRICT,
+    buyer_name VARCHAR(100) NOT NULL,
+    buyer_email VARCHAR(255),
+    buyer_tax_id VARCHAR(50),
+    buyer_address VARCHAR(255),
+    seller_user_id VARCHAR(32) REFERENCES users(id) ON DELETE RESTRICT, -- 为空表示平台
+    seller_name VARCHAR(100) NOT NULL,
+    seller_email VARCHAR(255),
+    seller_tax_id VARCHAR(50),
+    seller_address VARCHAR(255),
+    currency VARCHAR(10) NOT NULL DEFAULT 'CNY',
+    subtotal DECIMAL(10,2) NOT NULL, -- 不含税金额，红字票据为负数
+    tax_rate DECIMAL(5,4) NOT NULL DEFAULT 0,
+    tax_amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+    total DECIMAL(10,2) NOT NULL, -- 含税金额
+    issued_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (income_transaction_id, category)
+);
+
+-- 票据明细表
+CREATE TABLE invoice_items (
+    id VARCHAR(32) PRIMARY KEY DEFAULT generate_table_id('INVITEM_', 'invoice_item_id_num_seq'),
+    invoice_id VARCHAR(32) NOT NULL REFERENCES invoices(id) ON DELETE RESTRICT,
+    line_no INTEGER NOT NULL,
+    description VARCHAR(255) NOT NULL,
+    quantity INTEGER NOT NULL DEFAULT 1,
+    unit_price DECIMAL(10,2) NOT NULL, -- 含税单价
+    amount DECIMAL(10,2) NOT NULL, -- 含税金额
+    tax_amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (invoice_id, line_no)
+);
+
+-- 发票与收据相关索引
+CREATE INDEX idx_invoices_buyer_user_id ON invoices(buyer_user_id, issued_at DESC);
+CREATE INDEX idx_invoices_seller_user_id ON invoices(seller_user_id, issued_at DESC);
+CREATE INDEX idx_invoices_mentor_id ON invoices(mentor_id, issued_at DESC);
+CREATE INDEX idx_invoices_payment_order_id ON invoices(payment_order_id);
+CREATE INDEX idx_invoices_refund_id ON invoices(refund_id);
+
+-- 票据开具后不可修改或删除，更正通过开具红字票据完成
+CREATE OR REPLACE FUNCTION prevent_invoice_modification()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION '票据开具后不可修改或删除';
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER prevent_invoices_modification BEFORE UPDATE OR DELETE ON invoices FOR EACH ROW EXECUTE FUNCTION prevent_invoice_modification();
+CREATE TRIGGER prevent_invoice_items_modification BEFORE UPDATE OR DELETE ON invoice_items FOR EACH ROW EXECUTE FUNCTION prevent_invoice_modification();
+
+-- 圈子聊天已读位置表（每个成员在每个圈子中读到的最后一条消息，之后他人发送的消息计为未读）
+CREATE TABLE circle_chat_reads (
+    circle_id VARCHAR(32) NOT NULL REFERENCES circles(id) ON DELETE CASCADE,
+    user_id VARCHAR(32) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    last_read_message_id VARCHAR(32) REFERENCES messages(id) ON DELETE SET NULL,
+    last_read_at TIMESTAMP NOT NULL, -- 已读到的消息的发送时间
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (circle_id, user_id)
+);
+
+-- 圈子聊天已读位置触发器
+CREATE TRIGGER update_circle_chat_reads_updated_at BEFORE UPDATE ON circle_chat_reads FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
 -- 创建用户和权限管理
 -- 创建用户（PostgreSQL 语法）
 DO $$
@@ -893,6 +1486,28 @@ ALTER SEQUENCE payment_order_id_num_seq OWNER TO master_guide;
 ALTER SEQUENCE payment_record_id_num_seq OWNER TO master_guide;
 ALTER SEQUENCE payment_refund_id_num_seq OWNER TO master_guide;
 ALTER SEQUENCE upload_file_id_num_seq OWNER TO master_guide;
+ALTER SEQUENCE auth_session_id_num_seq OWNER TO master_guide;
+ALTER SEQUENCE refresh_token_id_num_seq OWNER TO master_guide;
+ALTER SEQUENCE password_reset_token_id_num_seq OWNER TO master_guide;
+ALTER SEQUENCE payment_webhook_event_id_num_seq OWNER TO master_guide;
+ALTER SEQUENCE mentor_availability_rule_id_num_seq OWNER TO master_guide;
+ALTER SEQUENCE mentor_availability_exception_id_num_seq OWNER TO master_guide;
+ALTER SEQUENCE appointment_status_history_id_num_seq OWNER TO master_guide;
+ALTER SEQUENCE calendar_feed_id_num_seq OWNER TO master_guide;
+ALTER SEQUENCE appointment_reminder_id_num_seq OWNER TO master_guide;
+ALTER SEQUENCE meeting_room_id_num_seq OWNER TO master_guide;
+ALTER SEQUENCE meeting_participant_id_num_seq OWNER TO master_guide;
+ALTER SEQUENCE appointment_package_id_num_seq OWNER TO master_guide;
+ALTER SEQUENCE appointment_package_purchase_id_num_seq OWNER TO master_guide;
+ALTER SEQUENCE appointment_series_id_num_seq OWNER TO master_guide;
+ALTER SEQUENCE ledger_account_id_num_seq OWNER TO master_guide;
+ALTER SEQUENCE ledger_transaction_id_num_seq OWNER TO master_guide;
+ALTER SEQUENCE ledger_entry_id_num_seq OWNER TO master_guide;
+ALTER SEQUENCE fee_rule_id_num_seq OWNER TO master_guide;
+ALTER SEQUENCE payout_batch_id_num_seq OWNER TO master_guide;
+ALTER SEQUENCE income_report_id_num_seq OWNER TO master_guide;
+ALTER SEQUENCE invoice_id_num_seq OWNER TO master_guide;
+ALTER SEQUENCE invoice_item_id_num_seq OWNER TO master_guide;
 
 -- 赋予所有表的所有权
 ALTER TABLE users OWNER TO master_guide;
@@ -926,9 +1541,36 @@ ALTER TABLE payment_records OWNER TO master_guide;
 ALTER TABLE payment_refunds OWNER TO master_guide;
 ALTER TABLE payment_methods OWNER TO master_guide;
 ALTER TABLE upload_files OWNER TO master_guide;
+ALTER TABLE auth_sessions OWNER TO master_guide;
+ALTER TABLE refresh_tokens OWNER TO master_guide;
+ALTER TABLE password_reset_tokens OWNER TO master_guide;
+ALTER TABLE payment_webhook_events OWNER TO master_guide;
+ALTER TABLE scheduler_locks OWNER TO master_guide;
+ALTER TABLE mentor_availability_rules OWNER TO master_guide;
+ALTER TABLE mentor_availability_exceptions OWNER TO master_guide;
+ALTER TABLE appointment_status_history OWNER TO master_guide;
+ALTER TABLE calendar_feeds OWNER TO master_guide;
+ALTER TABLE appointment_reminders OWNER TO master_guide;
+ALTER TABLE meeting_rooms OWNER TO master_guide;
+ALTER TABLE meeting_participants OWNER TO master_guide;
+ALTER TABLE appointment_packages OWNER TO master_guide;
+ALTER TABLE appointment_package_purchases OWNER TO master_guide;
+ALTER TABLE appointment_series OWNER TO master_guide;
+ALTER TABLE ledger_accounts OWNER TO master_guide;
+ALTER TABLE ledger_transactions OWNER TO master_guide;
+ALTER TABLE ledger_entries OWNER TO master_guide;
+ALTER TABLE fee_rules OWNER TO master_guide;
+ALTER TABLE payout_batches OWNER TO master_guide;
+ALTER TABLE income_reports OWNER TO master_guide;
+ALTER TABLE invoice_number_sequences OWNER TO master_guide;
+ALTER TABLE invoices OWNER TO master_guide;
+ALTER TABLE invoice_items OWNER TO master_guide;
+ALTER TABLE circle_chat_reads OWNER TO master_guide;
 
 -- 赋予函数所有权
 ALTER FUNCTION generate_table_id(VARCHAR(32), VARCHAR(50)) OWNER TO master_guide;
 ALTER FUNCTION update_updated_at_column() OWNER TO master_guide;
 ALTER FUNCTION update_course_stats() OWNER TO master_guide;
-ALTER FUNCTION update_post_stats() OWNER TO master_guide; 
\ No newline at end of file
+ALTER FUNCTION update_post_stats() OWNER TO master_guide;
+ALTER FUNCTION check_ledger_transaction_balanced() OWNER TO master_guide;
+ALTER FUNCTION prevent_invoice_modification() OWNER TO master_guide;
\ No newline at end of file